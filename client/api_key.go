@@ -0,0 +1,10 @@
+package client
+
+// apiKeyHeader is the metadata key of the API key of a call.
+const apiKeyHeader = "x-api-key"
+
+// WithAPIKey authenticates every call of the connection with the API key, for the machine
+// callers that don't log in. The server must be configured with the key.
+func WithAPIKey(key string) Option {
+	return withHeader(apiKeyHeader, key)
+}
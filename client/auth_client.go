@@ -8,6 +8,9 @@ import (
 	"google.golang.org/grpc"
 )
 
+// loginMethod is the full name of the login RPC, which never needs a token.
+const loginMethod = "/grpc_app.proto.AuthService/Login"
+
 // AuthClient is a client to call authentication RPC.
 type AuthClient struct {
 	service  pb.AuthServiceClient
@@ -2,32 +2,45 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
 
 // AuthInterceptor is a client interceptor for authentication.
+// It logs in with the auth client, caches the access token and attaches it
+// to the outgoing metadata, refreshing the token before it expires.
 type AuthInterceptor struct {
-	authClient  *AuthClient
-	authMethods map[string]bool
+	authClient    *AuthClient
+	authMethods   map[string]bool
+	refreshBefore time.Duration
+
+	mutex       sync.RWMutex
 	accessToken string
+	expiresAt   time.Time
 }
 
 // NewAuthInterceptor returns a new auth interceptor.
+// If authMethods is nil, the token is attached to every call.
+// The token is refreshed refreshBefore its expiry time, or every refreshBefore
+// if the token doesn't carry an expiry time.
 func NewAuthInterceptor(
 	authClient *AuthClient,
 	authMethods map[string]bool,
-	refreshDuration time.Duration,
+	refreshBefore time.Duration,
 ) (*AuthInterceptor, error) {
 	interceptor := &AuthInterceptor{
-		authClient:  authClient,
-		authMethods: authMethods,
+		authClient:    authClient,
+		authMethods:   authMethods,
+		refreshBefore: refreshBefore,
 	}
 
-	err := interceptor.scheduleRefreshToken(refreshDuration)
+	err := interceptor.scheduleRefreshToken()
 	if err != nil {
 		return nil, err
 	}
@@ -47,15 +60,19 @@ func (interceptor *AuthInterceptor) Unary() grpc.UnaryClientInterceptor {
 	) error {
 		log.Printf("---> unary interceptor: %s", method)
 
-		if interceptor.authMethods[method] {
-			return invoker(interceptor.attachToken(ctx), method, req, reply, cc, opts...)
+		if interceptor.needsToken(method) {
+			ctx, err := interceptor.attachToken(ctx)
+			if err != nil {
+				return err
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
 		}
 
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
 
-// Stream returns a client interceptor to authenticate unary RPC
+// Stream returns a client interceptor to authenticate stream RPC
 func (interceptor *AuthInterceptor) Stream() grpc.StreamClientInterceptor {
 	return func(
 		ctx context.Context,
@@ -67,33 +84,69 @@ func (interceptor *AuthInterceptor) Stream() grpc.StreamClientInterceptor {
 	) (grpc.ClientStream, error) {
 		log.Printf("---> stream interceptor: %s", method)
 
-		if interceptor.authMethods[method] {
-			return streamer(interceptor.attachToken(ctx), desc, cc, method, opts...)
+		if interceptor.needsToken(method) {
+			ctx, err := interceptor.attachToken(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return streamer(ctx, desc, cc, method, opts...)
 		}
 
 		return streamer(ctx, desc, cc, method, opts...)
 	}
 }
 
-func (interceptor *AuthInterceptor) attachToken(ctx context.Context) context.Context {
-	return metadata.AppendToOutgoingContext(ctx, "authorization", interceptor.accessToken)
+func (interceptor *AuthInterceptor) needsToken(method string) bool {
+	if interceptor.authMethods == nil {
+		return method != loginMethod
+	}
+	return interceptor.authMethods[method]
 }
 
-func (interceptor *AuthInterceptor) scheduleRefreshToken(refreshDuration time.Duration) error {
+func (interceptor *AuthInterceptor) attachToken(ctx context.Context) (context.Context, error) {
+	accessToken, err := interceptor.token()
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", accessToken), nil
+}
+
+// token returns the cached access token, logging in again if it has already expired.
+func (interceptor *AuthInterceptor) token() (string, error) {
+	interceptor.mutex.RLock()
+	accessToken, expiresAt := interceptor.accessToken, interceptor.expiresAt
+	interceptor.mutex.RUnlock()
+
+	if expiresAt.IsZero() || time.Now().Before(expiresAt) {
+		return accessToken, nil
+	}
+
+	err := interceptor.refreshToken()
+	if err != nil {
+		return "", fmt.Errorf("cannot refresh expired token: %w", err)
+	}
+
+	interceptor.mutex.RLock()
+	defer interceptor.mutex.RUnlock()
+	return interceptor.accessToken, nil
+}
+
+func (interceptor *AuthInterceptor) scheduleRefreshToken() error {
 	err := interceptor.refreshToken()
 	if err != nil {
 		return err
 	}
 
 	go func() {
-		wait := refreshDuration
+		wait := interceptor.nextRefresh()
 		for {
 			time.Sleep(wait)
 			err := interceptor.refreshToken()
 			if err != nil {
+				log.Printf("cannot refresh token: %v", err)
 				wait = time.Second
 			} else {
-				wait = refreshDuration
+				wait = interceptor.nextRefresh()
 			}
 		}
 	}()
@@ -101,14 +154,54 @@ func (interceptor *AuthInterceptor) scheduleRefreshToken(refreshDuration time.Du
 	return nil
 }
 
+// nextRefresh returns how long to wait before refreshing the current token.
+func (interceptor *AuthInterceptor) nextRefresh() time.Duration {
+	interceptor.mutex.RLock()
+	defer interceptor.mutex.RUnlock()
+
+	if interceptor.expiresAt.IsZero() {
+		return interceptor.refreshBefore
+	}
+
+	wait := time.Until(interceptor.expiresAt) - interceptor.refreshBefore
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
 func (interceptor *AuthInterceptor) refreshToken() error {
 	accessToken, err := interceptor.authClient.Login()
 	if err != nil {
 		return err
 	}
 
+	expiresAt, err := tokenExpiry(accessToken)
+	if err != nil {
+		return err
+	}
+
+	interceptor.mutex.Lock()
+	defer interceptor.mutex.Unlock()
+
 	interceptor.accessToken = accessToken
-	log.Printf("token refreshed: %v", accessToken)
+	interceptor.expiresAt = expiresAt
+	log.Printf("token refreshed, expires at: %v", expiresAt)
 
 	return nil
 }
+
+// tokenExpiry reads the expiry time of an access token.
+// The signature is not verified since only the server knows the secret key.
+func tokenExpiry(accessToken string) (time.Time, error) {
+	claims := &jwt.StandardClaims{}
+	_, _, err := new(jwt.Parser).ParseUnverified(accessToken, claims)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse access token: %w", err)
+	}
+
+	if claims.ExpiresAt == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(claims.ExpiresAt, 0), nil
+}
@@ -0,0 +1,91 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeAuthServer issues numbered tokens expiring after its token duration.
+type fakeAuthServer struct {
+	pb.UnimplementedAuthServiceServer
+	tokenDuration time.Duration
+	logins        int32
+}
+
+func (server *fakeAuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	login := atomic.AddInt32(&server.logins, 1)
+	claims := jwt.StandardClaims{
+		Id:        fmt.Sprint(login),
+		ExpiresAt: time.Now().Add(server.tokenDuration).Unix(),
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.LoginResponse{AccessToken: accessToken}, nil
+}
+
+func TestAuthInterceptorRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	authServer := &fakeAuthServer{tokenDuration: 2 * time.Second}
+	tokens := make(chan string, 1)
+	laptopServer := &fakeLaptopServer{create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		tokens <- md.Get("authorization")[0]
+		return laptop.GetId(), nil
+	}}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterAuthServiceServer(grpcServer, authServer)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	authConn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer authConn.Close()
+	authClient := client.NewAuthClient(authConn, "admin", "secret")
+	interceptor, err := client.NewAuthInterceptor(authClient, nil, 1500*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&authServer.logins), "the interceptor logs in at once")
+
+	conn, err := grpc.Dial(listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(interceptor.Unary()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	laptopService := pb.NewLaptopServiceClient(conn)
+
+	tokenID := func() string {
+		_, err := laptopService.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+		require.NoError(t, err)
+		claims := &jwt.StandardClaims{}
+		_, _, err = new(jwt.Parser).ParseUnverified(<-tokens, claims)
+		require.NoError(t, err)
+		return claims.Id
+	}
+	require.Equal(t, "1", tokenID())
+
+	// The token is refreshed 1.5s before it expires, so after 0.5s, with the refresh waiting at least 1s.
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&authServer.logins) >= 2
+	}, 3*time.Second, 50*time.Millisecond)
+	require.NotEqual(t, "1", tokenID(), "the calls get the refreshed token")
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+// staticScheme is the scheme of the targets that list the addresses of their servers.
+const staticScheme = "static"
+
+// StaticTarget returns the target of a connection to the servers of the addresses, e.g. static:///host1:8080,host2:8080,
+// whose calls are balanced with round robin by the default service config.
+// Targets of other schemes, e.g. dns:///laptops.example.com:8080, are resolved by gRPC.
+func StaticTarget(addresses []string) string {
+	return staticScheme + ":///" + strings.Join(addresses, ",")
+}
+
+// balancedAddresses returns the addresses of the servers of the address if it lists several of them,
+// either as a static target or as comma-separated addresses.
+func balancedAddresses(address string) []string {
+	address = strings.TrimPrefix(address, staticScheme+":///")
+	if !strings.Contains(address, ",") {
+		return nil
+	}
+
+	var addresses []string
+	for _, addr := range strings.Split(address, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
+// staticDialOptions returns the dial options of a connection to the servers of the addresses.
+// The authority of the connection, which names the server certificate unless TLSConfig.ServerName
+// overrides it, is the first address, so all the servers are expected to share their certificate name.
+func staticDialOptions(addresses []string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithResolvers(&staticResolverBuilder{addresses: addresses}),
+		grpc.WithAuthority(addresses[0]),
+	}
+}
+
+// staticResolverBuilder builds the resolvers of the static targets, which resolve to a fixed list of addresses.
+type staticResolverBuilder struct {
+	addresses []string
+}
+
+func (builder *staticResolverBuilder) Build(
+	target resolver.Target,
+	cc resolver.ClientConn,
+	opts resolver.BuildOptions,
+) (resolver.Resolver, error) {
+	state := resolver.State{Addresses: make([]resolver.Address, len(builder.addresses))}
+	for i, addr := range builder.addresses {
+		state.Addresses[i] = resolver.Address{Addr: addr}
+	}
+	err := cc.UpdateState(state)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s: %w", StaticTarget(builder.addresses), err)
+	}
+	return staticResolver{}, nil
+}
+
+func (builder *staticResolverBuilder) Scheme() string {
+	return staticScheme
+}
+
+// staticResolver is a resolver whose addresses never change.
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (staticResolver) Close() {}
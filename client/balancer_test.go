@@ -0,0 +1,65 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestDialBalancesCalls(t *testing.T) {
+	t.Parallel()
+
+	const n = 3
+	calls := make([]int32, n)
+	addresses := make([]string, n)
+	for i := range addresses {
+		i := i
+		server := grpc.NewServer(grpc.UnaryInterceptor(func(
+			ctx context.Context,
+			req interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			atomic.AddInt32(&calls[i], 1)
+			return handler(ctx, req)
+		}))
+		grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go server.Serve(listener)
+		t.Cleanup(server.Stop)
+		addresses[i] = listener.Addr().String()
+	}
+
+	for _, address := range []string{strings.Join(addresses, ","), client.StaticTarget(addresses)} {
+		for i := range calls {
+			atomic.StoreInt32(&calls[i], 0)
+		}
+
+		conn, err := client.Dial(address)
+		require.NoError(t, err)
+		healthClient := grpc_health_v1.NewHealthClient(conn)
+		// The calls are balanced across the servers as soon as their connections are ready.
+		require.Eventually(t, func() bool {
+			_, err := healthClient.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.WaitForReady(true))
+			require.NoError(t, err)
+			for i := range calls {
+				if atomic.LoadInt32(&calls[i]) == 0 {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, time.Millisecond, "every server of %s is called", address)
+		conn.Close()
+	}
+}
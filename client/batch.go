@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"grpc_app/pb"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// BatchError contains the errors of the items that failed in a batch call, keyed by item index.
+type BatchError struct {
+	Total  int
+	Errors map[int]error
+}
+
+func (batchErr *BatchError) Error() string {
+	indexes := make([]int, 0, len(batchErr.Errors))
+	for i := range batchErr.Errors {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	messages := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		messages = append(messages, fmt.Sprintf("item %d: %v", i, batchErr.Errors[i]))
+	}
+	return fmt.Sprintf("%d of %d items failed: %s", len(indexes), batchErr.Total, strings.Join(messages, "; "))
+}
+
+// CreateLaptops creates the laptops with at most concurrency requests in flight,
+// and returns their IDs in the same order. If some of the laptops cannot be created,
+// their IDs are left empty and a *BatchError is returned.
+func (laptopClient *LaptopClient) CreateLaptops(
+	ctx context.Context,
+	laptops []*pb.Laptop,
+	concurrency int,
+) ([]string, error) {
+	ids := make([]string, len(laptops))
+	if laptopClient.cache != nil {
+		defer laptopClient.cache.InvalidateSearches()
+	}
+
+	err := runBatch(ctx, len(laptops), concurrency, func(ctx context.Context, i int) error {
+		req := &pb.CreateLaptopRequest{Laptop: laptops[i]}
+		res, err := laptopClient.service.CreateLaptop(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		ids[i] = res.GetId()
+		if laptopClient.cache != nil {
+			created := proto.Clone(laptops[i]).(*pb.Laptop)
+			created.Id = res.GetId()
+			laptopClient.cache.Set(created)
+		}
+		return nil
+	})
+
+	return ids, err
+}
+
+// BatchCreateLaptops creates the laptops with a single batch create RPC, and returns their results
+// in the same order. The laptops with an error in their result are not created.
+func (laptopClient *LaptopClient) BatchCreateLaptops(
+	ctx context.Context,
+	laptops []*pb.Laptop,
+) ([]*pb.BatchCreateLaptopResult, error) {
+	if laptopClient.cache != nil {
+		defer laptopClient.cache.InvalidateSearches()
+	}
+
+	res, err := laptopClient.service.BatchCreateLaptops(ctx, &pb.BatchCreateLaptopsRequest{Laptops: laptops})
+	if err != nil {
+		return nil, err
+	}
+
+	if laptopClient.cache != nil {
+		for i, result := range res.GetResults() {
+			if result.GetError() != "" {
+				continue
+			}
+			created := proto.Clone(laptops[i]).(*pb.Laptop)
+			created.Id = result.GetId()
+			laptopClient.cache.Set(created)
+		}
+	}
+	return res.GetResults(), nil
+}
+
+// RateLaptops rates each laptop with a unary-like round trip on the rating stream,
+// with at most concurrency streams open at the same time.
+func (laptopClient *LaptopClient) RateLaptops(
+	ctx context.Context,
+	laptopIDs []string,
+	scores []float64,
+	concurrency int,
+) ([]*pb.RateLaptopResponse, error) {
+	if len(laptopIDs) != len(scores) {
+		return nil, fmt.Errorf("got %d laptop IDs but %d scores", len(laptopIDs), len(scores))
+	}
+
+	responses := make([]*pb.RateLaptopResponse, len(laptopIDs))
+
+	err := runBatch(ctx, len(laptopIDs), concurrency, func(ctx context.Context, i int) error {
+		stream, err := laptopClient.service.RateLaptop(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = stream.Send(&pb.RatelaptopRequest{LaptopId: laptopIDs[i], Score: scores[i]})
+		if err != nil {
+			return err
+		}
+
+		err = stream.CloseSend()
+		if err != nil {
+			return err
+		}
+
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		responses[i] = res
+		return nil
+	})
+
+	return responses, err
+}
+
+// runBatch calls do for every index in [0, n) from a pool of concurrency workers.
+// Items that haven't started when the context is done fail with the context error.
+func runBatch(ctx context.Context, n int, concurrency int, do func(ctx context.Context, i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, n)
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// The index may be received after the context is done, as select picks at random.
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				errs[i] = do(ctx, i)
+			}
+		}()
+	}
+
+	sent := 0
+	for sent < n && ctx.Err() == nil {
+		select {
+		case indexes <- sent:
+			sent++
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i := sent; i < n; i++ {
+		errs[i] = ctx.Err()
+	}
+
+	batchErr := &BatchError{Total: n, Errors: make(map[int]error)}
+	for i, err := range errs {
+		if err != nil {
+			batchErr.Errors[i] = err
+		}
+	}
+
+	if len(batchErr.Errors) > 0 {
+		return batchErr
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"math/rand"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// fakeLaptopServer creates the laptops with its create function, counting the calls in flight,
+// and searches for laptops with its search function.
+type fakeLaptopServer struct {
+	pb.UnimplementedLaptopServiceServer
+	create   func(ctx context.Context, laptop *pb.Laptop) (string, error)
+	search   func(req *pb.SearchLaptopRequest, stream pb.LaptopService_SearchLaptopServer) error
+	inFlight int32
+	maxCalls int32
+	calls    int32
+}
+
+func (server *fakeLaptopServer) CreateLaptop(ctx context.Context, req *pb.CreateLaptopRequest) (*pb.CreateLaptopResponse, error) {
+	atomic.AddInt32(&server.calls, 1)
+	inFlight := atomic.AddInt32(&server.inFlight, 1)
+	defer atomic.AddInt32(&server.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&server.maxCalls)
+		if inFlight <= max || atomic.CompareAndSwapInt32(&server.maxCalls, max, inFlight) {
+			break
+		}
+	}
+
+	id, err := server.create(ctx, req.GetLaptop())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateLaptopResponse{Id: id}, nil
+}
+
+func (server *fakeLaptopServer) SearchLaptop(req *pb.SearchLaptopRequest, stream pb.LaptopService_SearchLaptopServer) error {
+	return server.search(req, stream)
+}
+
+// dialFakeLaptopServer serves the fake server and returns a connection to it.
+func dialFakeLaptopServer(t *testing.T, server pb.LaptopServiceServer) *grpc.ClientConn {
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, server)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestCreateLaptops(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeLaptopServer{create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+		// The laptops are created out of order.
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		if laptop.GetBrand() == "invalid" {
+			return "", status.Error(codes.InvalidArgument, "invalid brand")
+		}
+		return "created-" + laptop.GetId(), nil
+	}}
+	laptopClient := client.NewLaptopClient(dialFakeLaptopServer(t, server))
+
+	laptops := make([]*pb.Laptop, 20)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+	}
+	laptops[3].Brand = "invalid"
+	laptops[11].Brand = "invalid"
+
+	ids, err := laptopClient.CreateLaptops(context.Background(), laptops, 4)
+	var batchErr *client.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Equal(t, len(laptops), batchErr.Total)
+	require.Len(t, batchErr.Errors, 2)
+	require.Equal(t, codes.InvalidArgument, status.Code(batchErr.Errors[3]))
+	require.Equal(t, codes.InvalidArgument, status.Code(batchErr.Errors[11]))
+	require.True(t, strings.HasPrefix(err.Error(), "2 of 20 items failed: item 3: "))
+
+	require.Len(t, ids, len(laptops))
+	for i, laptop := range laptops {
+		if i == 3 || i == 11 {
+			require.Empty(t, ids[i])
+			continue
+		}
+		require.Equal(t, "created-"+laptop.GetId(), ids[i], "the IDs are in the order of the laptops")
+	}
+	require.LessOrEqual(t, atomic.LoadInt32(&server.maxCalls), int32(4), "at most concurrency calls are in flight")
+}
+
+func TestCreateLaptopsCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := &fakeLaptopServer{create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+		cancel()
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}
+	laptopClient := client.NewLaptopClient(dialFakeLaptopServer(t, server))
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()}
+	ids, err := laptopClient.CreateLaptops(ctx, laptops, 1)
+	var batchErr *client.BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Len(t, batchErr.Errors, len(laptops), "the laptops not sent fail with the context error")
+	require.Equal(t, codes.Canceled, status.Code(batchErr.Errors[0]))
+	for _, i := range []int{1, 2} {
+		require.ErrorIs(t, batchErr.Errors[i], context.Canceled)
+	}
+	require.Equal(t, []string{"", "", ""}, ids)
+	require.Equal(t, int32(1), atomic.LoadInt32(&server.calls), "no call is started after the cancellation")
+}
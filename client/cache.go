@@ -0,0 +1,202 @@
+package client
+
+import (
+	"grpc_app/pb"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// LaptopCache is a small in-memory TTL cache of laptops, keyed by laptop ID,
+// and of the results of the searches, keyed by search request.
+type LaptopCache struct {
+	mutex      sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*cacheEntry
+	searches   map[string]*searchEntry
+}
+
+type cacheEntry struct {
+	laptop    *pb.Laptop
+	expiresAt time.Time
+}
+
+type searchEntry struct {
+	laptops   []*pb.Laptop
+	expiresAt time.Time
+}
+
+// NewLaptopCache returns a new laptop cache.
+// Entries expire after ttl, and at most maxEntries laptops and maxEntries search results are kept.
+func NewLaptopCache(ttl time.Duration, maxEntries int) *LaptopCache {
+	return &LaptopCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*cacheEntry),
+		searches:   make(map[string]*searchEntry),
+	}
+}
+
+// Get returns a copy of the cached laptop with the given ID, or nil if it's missing or expired.
+func (cache *LaptopCache) Get(id string) *pb.Laptop {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry := cache.entries[id]
+	if entry == nil {
+		return nil
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(cache.entries, id)
+		return nil
+	}
+
+	return proto.Clone(entry.laptop).(*pb.Laptop)
+}
+
+// Set stores a copy of the laptop in the cache.
+func (cache *LaptopCache) Set(laptop *pb.Laptop) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	now := time.Now()
+	if cache.entries[laptop.GetId()] == nil && len(cache.entries) >= cache.maxEntries {
+		cache.evict(now)
+	}
+
+	cache.entries[laptop.GetId()] = &cacheEntry{
+		laptop:    proto.Clone(laptop).(*pb.Laptop),
+		expiresAt: now.Add(cache.ttl),
+	}
+}
+
+// Invalidate removes the laptop with the given ID from the cache,
+// and the search results, which may no longer match the changed laptop.
+func (cache *LaptopCache) Invalidate(id string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	delete(cache.entries, id)
+	cache.searches = make(map[string]*searchEntry)
+}
+
+// Clear removes all laptops and search results from the cache.
+func (cache *LaptopCache) Clear() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.entries = make(map[string]*cacheEntry)
+	cache.searches = make(map[string]*searchEntry)
+}
+
+// GetSearch returns copies of the cached results of the search request,
+// or false if they are missing or expired.
+func (cache *LaptopCache) GetSearch(req *pb.SearchLaptopRequest) ([]*pb.Laptop, bool) {
+	key, ok := searchKey(req)
+	if !ok {
+		return nil, false
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry := cache.searches[key]
+	if entry == nil {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(cache.searches, key)
+		return nil, false
+	}
+
+	laptops := make([]*pb.Laptop, len(entry.laptops))
+	for i, laptop := range entry.laptops {
+		laptops[i] = proto.Clone(laptop).(*pb.Laptop)
+	}
+	return laptops, true
+}
+
+// SetSearch stores copies of the results of the search request in the cache.
+func (cache *LaptopCache) SetSearch(req *pb.SearchLaptopRequest, laptops []*pb.Laptop) {
+	key, ok := searchKey(req)
+	if !ok {
+		return
+	}
+
+	entry := &searchEntry{laptops: make([]*pb.Laptop, len(laptops))}
+	for i, laptop := range laptops {
+		entry.laptops[i] = proto.Clone(laptop).(*pb.Laptop)
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	now := time.Now()
+	if cache.searches[key] == nil && len(cache.searches) >= cache.maxEntries {
+		cache.evictSearch(now)
+	}
+
+	entry.expiresAt = now.Add(cache.ttl)
+	cache.searches[key] = entry
+}
+
+// InvalidateSearches removes the search results from the cache, e.g. after a laptop is created.
+func (cache *LaptopCache) InvalidateSearches() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.searches = make(map[string]*searchEntry)
+}
+
+// searchKey returns the key of the search request in the cache, its deterministic encoding.
+func searchKey(req *pb.SearchLaptopRequest) (string, bool) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// evict drops expired entries, or the entry closest to expiry if none has expired.
+func (cache *LaptopCache) evict(now time.Time) {
+	oldestID := ""
+	var oldest time.Time
+
+	for id, entry := range cache.entries {
+		if now.After(entry.expiresAt) {
+			delete(cache.entries, id)
+			continue
+		}
+		if oldestID == "" || entry.expiresAt.Before(oldest) {
+			oldestID, oldest = id, entry.expiresAt
+		}
+	}
+
+	if len(cache.entries) >= cache.maxEntries {
+		delete(cache.entries, oldestID)
+	}
+}
+
+// evictSearch drops expired search results, or the results closest to expiry if none has expired.
+func (cache *LaptopCache) evictSearch(now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+
+	for key, entry := range cache.searches {
+		if now.After(entry.expiresAt) {
+			delete(cache.searches, key)
+			continue
+		}
+		if oldestKey == "" || entry.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, entry.expiresAt
+		}
+	}
+
+	if len(cache.searches) >= cache.maxEntries {
+		delete(cache.searches, oldestKey)
+	}
+}
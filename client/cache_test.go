@@ -0,0 +1,112 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLaptopCache(t *testing.T) {
+	t.Parallel()
+
+	cache := client.NewLaptopCache(50*time.Millisecond, 2)
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	laptop3 := sample.NewLaptop()
+
+	require.Nil(t, cache.Get(laptop1.GetId()))
+	cache.Set(laptop1)
+	laptop1.Brand = "changed"
+	found := cache.Get(laptop1.GetId())
+	require.NotNil(t, found)
+	require.NotEqual(t, "changed", found.GetBrand(), "the cache keeps a copy")
+	found.Brand = "changed"
+	require.NotEqual(t, "changed", cache.Get(laptop1.GetId()).GetBrand(), "the cache returns copies")
+
+	time.Sleep(10 * time.Millisecond)
+	cache.Set(laptop2)
+	cache.Set(laptop3)
+	require.Nil(t, cache.Get(laptop1.GetId()), "the laptop closest to expiry is evicted")
+	require.NotNil(t, cache.Get(laptop2.GetId()))
+	require.NotNil(t, cache.Get(laptop3.GetId()))
+
+	cache.Invalidate(laptop2.GetId())
+	require.Nil(t, cache.Get(laptop2.GetId()))
+	cache.Clear()
+	require.Nil(t, cache.Get(laptop3.GetId()))
+
+	cache.Set(laptop1)
+	time.Sleep(60 * time.Millisecond)
+	require.Nil(t, cache.Get(laptop1.GetId()), "the laptop has expired")
+}
+
+func TestCachedLaptopClient(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeLaptopServer{create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+		return laptop.GetId(), nil
+	}}
+	cache := client.NewLaptopCache(time.Minute, 10)
+	laptopClient := client.NewCachedLaptopClient(dialFakeLaptopServer(t, server), cache)
+	require.Same(t, cache, laptopClient.Cache())
+
+	laptop := sample.NewLaptop()
+	id, err := laptopClient.CreateLaptop(context.Background(), laptop)
+	require.NoError(t, err)
+
+	// The fake server doesn't implement GetLaptop, so the laptop can only come from the cache.
+	found, err := laptopClient.GetLaptop(context.Background(), id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, found))
+
+	_, err = laptopClient.GetLaptop(context.Background(), id, "id")
+	require.Error(t, err, "the partial laptops are not served from the cache")
+}
+
+func TestLaptopCacheSearches(t *testing.T) {
+	t.Parallel()
+
+	cache := client.NewLaptopCache(time.Minute, 2)
+	laptop := sample.NewLaptop()
+	req := &pb.SearchLaptopRequest{Filter: &pb.Filter{MaxPriceUsd: 3000}}
+
+	_, ok := cache.GetSearch(req)
+	require.False(t, ok)
+
+	cache.SetSearch(req, []*pb.Laptop{laptop})
+	laptops, ok := cache.GetSearch(&pb.SearchLaptopRequest{Filter: &pb.Filter{MaxPriceUsd: 3000}})
+	require.True(t, ok, "the results are found by an equal request")
+	require.Len(t, laptops, 1)
+	require.True(t, proto.Equal(laptop, laptops[0]))
+
+	laptops[0].Brand = "changed"
+	laptops, _ = cache.GetSearch(req)
+	require.Equal(t, laptop.GetBrand(), laptops[0].GetBrand(), "the results are copies")
+
+	_, ok = cache.GetSearch(&pb.SearchLaptopRequest{Filter: &pb.Filter{MaxPriceUsd: 2000}})
+	require.False(t, ok)
+
+	cache.Invalidate(laptop.GetId())
+	_, ok = cache.GetSearch(req)
+	require.False(t, ok, "a changed laptop invalidates the searches")
+
+	cache.SetSearch(req, nil)
+	laptops, ok = cache.GetSearch(req)
+	require.True(t, ok, "empty results are cached")
+	require.Empty(t, laptops)
+	cache.InvalidateSearches()
+	_, ok = cache.GetSearch(req)
+	require.False(t, ok)
+
+	expired := client.NewLaptopCache(0, 2)
+	expired.SetSearch(req, []*pb.Laptop{laptop})
+	time.Sleep(time.Millisecond)
+	_, ok = expired.GetSearch(req)
+	require.False(t, ok, "the results expire after the TTL")
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"grpc_app/compression"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/stats"
+)
+
+// Gzip and Zstd are the names of the compressors registered by default, which the server registers as well.
+// Other compressors can be used once registered with encoding.RegisterCompressor.
+const (
+	Gzip = gzip.Name
+	Zstd = compression.Zstd
+)
+
+// WithCompression makes every call of the connection compress its messages with the named compressor.
+func WithCompression(name string) Option {
+	return func(options *dialOptions) {
+		options.compressor = name
+	}
+}
+
+// WithCompressionStats records the uncompressed and wire sizes of the messages into stats.
+func WithCompressionStats(compressionStats *CompressionStats) Option {
+	return func(options *dialOptions) {
+		options.grpcOptions = append(options.grpcOptions, grpc.WithStatsHandler(compressionStats))
+	}
+}
+
+// Compress returns a call option to compress the messages of a single call.
+func Compress(name string) grpc.CallOption {
+	return grpc.UseCompressor(name)
+}
+
+func checkCompressor(name string) error {
+	if encoding.GetCompressor(name) == nil {
+		return fmt.Errorf("compressor %q is not registered", name)
+	}
+	return nil
+}
+
+// MessageSizes contains the total sizes of the messages sent and received by a method.
+type MessageSizes struct {
+	SentBytes         int64
+	SentWireBytes     int64
+	ReceivedBytes     int64
+	ReceivedWireBytes int64
+}
+
+// CompressionStats is a stats handler that measures message sizes before and after compression.
+type CompressionStats struct {
+	mutex   sync.Mutex
+	methods map[string]*MessageSizes
+}
+
+// NewCompressionStats returns a new compression stats.
+func NewCompressionStats() *CompressionStats {
+	return &CompressionStats{methods: make(map[string]*MessageSizes)}
+}
+
+type methodKey struct{}
+
+// TagRPC attaches the method name to the context.
+func (compressionStats *CompressionStats) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, methodKey{}, info.FullMethodName)
+}
+
+// HandleRPC accumulates the payload sizes of the method.
+func (compressionStats *CompressionStats) HandleRPC(ctx context.Context, rpcStats stats.RPCStats) {
+	method, _ := ctx.Value(methodKey{}).(string)
+
+	compressionStats.mutex.Lock()
+	defer compressionStats.mutex.Unlock()
+
+	sizes := compressionStats.methods[method]
+	if sizes == nil {
+		sizes = &MessageSizes{}
+		compressionStats.methods[method] = sizes
+	}
+
+	switch payload := rpcStats.(type) {
+	case *stats.OutPayload:
+		sizes.SentBytes += int64(payload.Length)
+		sizes.SentWireBytes += int64(payload.WireLength)
+	case *stats.InPayload:
+		sizes.ReceivedBytes += int64(payload.Length)
+		sizes.ReceivedWireBytes += int64(payload.WireLength)
+	}
+}
+
+// TagConn is a no-op.
+func (compressionStats *CompressionStats) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn is a no-op.
+func (compressionStats *CompressionStats) HandleConn(ctx context.Context, connStats stats.ConnStats) {
+}
+
+// Sizes returns a copy of the message sizes of each method.
+func (compressionStats *CompressionStats) Sizes() map[string]MessageSizes {
+	compressionStats.mutex.Lock()
+	defer compressionStats.mutex.Unlock()
+
+	sizes := make(map[string]MessageSizes, len(compressionStats.methods))
+	for method, methodSizes := range compressionStats.methods {
+		sizes[method] = *methodSizes
+	}
+	return sizes
+}
+
+// Report returns a human-readable summary of the message sizes of each method.
+func (compressionStats *CompressionStats) Report() string {
+	sizes := compressionStats.Sizes()
+
+	methods := make([]string, 0, len(sizes))
+	for method := range sizes {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	lines := make([]string, 0, len(methods))
+	for _, method := range methods {
+		s := sizes[method]
+		lines = append(lines, fmt.Sprintf(
+			"%s: sent %d bytes (%d on the wire), received %d bytes (%d on the wire)",
+			method, s.SentBytes, s.SentWireBytes, s.ReceivedBytes, s.ReceivedWireBytes,
+		))
+	}
+	return strings.Join(lines, "\n")
+}
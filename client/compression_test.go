@@ -0,0 +1,53 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	// The server returns the name of the laptop as its ID, so that the response is as compressible as the request.
+	server := &fakeLaptopServer{create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+		return laptop.GetName(), nil
+	}}
+	target := dialFakeLaptopServer(t, server).Target()
+
+	createLaptop := func(opts ...client.Option) client.MessageSizes {
+		compressionStats := client.NewCompressionStats()
+		conn, err := client.Dial(target, append(opts, client.WithCompressionStats(compressionStats))...)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		laptop := sample.NewLaptop()
+		laptop.Name = strings.Repeat("compressible ", 100)
+		id, err := client.NewLaptopClient(conn).CreateLaptop(context.Background(), laptop)
+		require.NoError(t, err)
+		require.Equal(t, laptop.GetName(), id, "the messages are decompressed intact")
+
+		sizes := compressionStats.Sizes()["/grpc_app.proto.LaptopService/CreateLaptop"]
+		require.Contains(t, compressionStats.Report(), "/grpc_app.proto.LaptopService/CreateLaptop: sent ")
+		return sizes
+	}
+
+	uncompressed := createLaptop()
+	require.Greater(t, uncompressed.SentBytes, int64(1300))
+	require.Greater(t, uncompressed.SentWireBytes, uncompressed.SentBytes, "the wire size includes the message header")
+	require.Greater(t, uncompressed.ReceivedWireBytes, uncompressed.ReceivedBytes)
+
+	for _, compressor := range []string{client.Gzip, client.Zstd} {
+		compressed := createLaptop(client.WithCompression(compressor))
+		require.Less(t, compressed.SentWireBytes, compressed.SentBytes/2, "the request is compressed with %s", compressor)
+		require.Less(t, compressed.ReceivedWireBytes, compressed.ReceivedBytes/2, "the response is compressed with %s", compressor)
+	}
+
+	_, err := client.Dial(target, client.WithCompression("snappy"))
+	require.EqualError(t, err, `compressor "snappy" is not registered`)
+}
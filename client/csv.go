@@ -0,0 +1,268 @@
+package client
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var memoryName = (&pb.Memory{}).ProtoReflect().Descriptor().FullName()
+
+// memoryUnits are the units of the memory cells, by their upper-case suffix.
+var memoryUnits = map[string]pb.Memory_Unit{
+	"BIT":      pb.Memory_BIT,
+	"B":        pb.Memory_BYTE,
+	"BYTE":     pb.Memory_BYTE,
+	"KB":       pb.Memory_KILOBYTE,
+	"KILOBYTE": pb.Memory_KILOBYTE,
+	"MB":       pb.Memory_MEGABYTE,
+	"MEGABYTE": pb.Memory_MEGABYTE,
+	"GB":       pb.Memory_GIGABYTE,
+	"GIGABYTE": pb.Memory_GIGABYTE,
+	"TB":       pb.Memory_TERABYTE,
+	"TERABYTE": pb.Memory_TERABYTE,
+}
+
+// CSVRowError is the error of a row of a CSV file that can't be read as a laptop.
+// The next rows can still be read.
+type CSVRowError struct {
+	// Line is the line of the row in the file, starting at 1 with the header.
+	Line int
+	Err  error
+}
+
+func (rowErr *CSVRowError) Error() string {
+	return fmt.Sprintf("line %d: %v", rowErr.Line, rowErr.Err)
+}
+
+func (rowErr *CSVRowError) Unwrap() error {
+	return rowErr.Err
+}
+
+// csvPathSegment is a field of the path of a column, with the index of its element for a repeated field.
+type csvPathSegment struct {
+	field protoreflect.FieldDescriptor
+	index int
+}
+
+// LaptopCSVReader reads laptops from a CSV file whose header names the fields of each column by their
+// path in the Laptop message, e.g. brand, price_usd or cpu.number_cores, with the index of the element
+// for the repeated fields, e.g. gpus[0].brand. The memory fields take a value with its unit, e.g. 16GB
+// for ram or 1TB for storage[0].memory, and the enum fields a name of their values. The empty cells are left unset.
+type LaptopCSVReader struct {
+	reader  *csv.Reader
+	columns [][]csvPathSegment
+	line    int
+}
+
+// NewLaptopCSVReader returns a new LaptopCSVReader of the CSV file of the reader,
+// or an error if its header doesn't name fields of the Laptop message.
+func NewLaptopCSVReader(r io.Reader) (*LaptopCSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read header: %w", err)
+	}
+
+	descriptor := (&pb.Laptop{}).ProtoReflect().Descriptor()
+	columns := make([][]csvPathSegment, len(header))
+	for i, name := range header {
+		columns[i], err = parseCSVPath(descriptor, strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid column %q: %w", name, err)
+		}
+	}
+	return &LaptopCSVReader{reader: reader, columns: columns}, nil
+}
+
+// parseCSVPath returns the path of the fields of the column name, whose last field must hold a value.
+func parseCSVPath(message protoreflect.MessageDescriptor, name string) ([]csvPathSegment, error) {
+	parts := strings.Split(name, ".")
+	path := make([]csvPathSegment, 0, len(parts))
+	for i, part := range parts {
+		if message == nil {
+			return nil, fmt.Errorf("field %q has no fields", parts[i-1])
+		}
+
+		segment := csvPathSegment{index: -1}
+		if open := strings.IndexByte(part, '['); open >= 0 && strings.HasSuffix(part, "]") {
+			index, err := strconv.Atoi(part[open+1 : len(part)-1])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index of %q", part)
+			}
+			part, segment.index = part[:open], index
+		}
+
+		segment.field = message.Fields().ByName(protoreflect.Name(part))
+		if segment.field == nil {
+			return nil, fmt.Errorf("unknown field %q of %s", part, message.Name())
+		}
+		if segment.field.IsMap() {
+			return nil, fmt.Errorf("map field %q is not supported", part)
+		}
+		if segment.field.IsList() != (segment.index >= 0) {
+			if segment.index >= 0 {
+				return nil, fmt.Errorf("field %q is not repeated", part)
+			}
+			return nil, fmt.Errorf("repeated field %q needs the index of its element, e.g. %s[0]", part, part)
+		}
+		path = append(path, segment)
+		message = segment.field.Message()
+	}
+
+	if message != nil && message.FullName() != memoryName {
+		return nil, fmt.Errorf("field %q is a message, only its fields hold values", parts[len(parts)-1])
+	}
+	return path, nil
+}
+
+// Read returns the laptop of the next row, io.EOF after the last row,
+// or a *CSVRowError if the row can't be read as a laptop.
+func (reader *LaptopCSVReader) Read() (*pb.Laptop, error) {
+	record, err := reader.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &CSVRowError{Line: parseErr.Line, Err: parseErr.Err}
+		}
+		return nil, err
+	}
+	line, _ := reader.reader.FieldPos(0)
+	reader.line = line
+
+	laptop := &pb.Laptop{}
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		err := setCSVField(laptop.ProtoReflect(), reader.columns[i], cell)
+		if err != nil {
+			return nil, &CSVRowError{Line: line, Err: fmt.Errorf("column %d: %w", i+1, err)}
+		}
+	}
+	return laptop, nil
+}
+
+// Line returns the line of the last row read, starting at 1 with the header.
+func (reader *LaptopCSVReader) Line() int {
+	return reader.line
+}
+
+// setCSVField sets the field at the end of the path to the value of the cell,
+// adding the missing elements of the repeated fields on the way.
+func setCSVField(message protoreflect.Message, path []csvPathSegment, cell string) error {
+	segment := path[0]
+	field := segment.field
+
+	if segment.index >= 0 {
+		list := message.Mutable(field).List()
+		if field.Message() != nil {
+			for list.Len() <= segment.index {
+				list.AppendMutable()
+			}
+			if len(path) > 1 {
+				return setCSVField(list.Get(segment.index).Message(), path[1:], cell)
+			}
+			return setCSVMemory(list.Get(segment.index).Message(), cell)
+		}
+		for list.Len() <= segment.index {
+			list.Append(list.NewElement())
+		}
+		value, err := parseCSVValue(field, cell)
+		if err != nil {
+			return err
+		}
+		list.Set(segment.index, value)
+		return nil
+	}
+
+	if len(path) > 1 {
+		return setCSVField(message.Mutable(field).Message(), path[1:], cell)
+	}
+	if field.Message() != nil {
+		return setCSVMemory(message.Mutable(field).Message(), cell)
+	}
+	value, err := parseCSVValue(field, cell)
+	if err != nil {
+		return err
+	}
+	message.Set(field, value)
+	return nil
+}
+
+// setCSVMemory sets the memory to the value of the cell, a number followed by a unit, e.g. 16GB or 512 MB.
+func setCSVMemory(message protoreflect.Message, cell string) error {
+	memory := message.Interface().(*pb.Memory)
+	i := strings.IndexFunc(cell, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return fmt.Errorf("invalid memory %q, must be a number followed by a unit, e.g. 16GB", cell)
+	}
+	value, err := strconv.ParseUint(cell[:i], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid memory %q: %w", cell, err)
+	}
+	unit, ok := memoryUnits[strings.ToUpper(strings.TrimSpace(cell[i:]))]
+	if !ok {
+		return fmt.Errorf("invalid memory %q, unknown unit %q", cell, strings.TrimSpace(cell[i:]))
+	}
+	memory.Value, memory.Unit = value, unit
+	return nil
+}
+
+// parseCSVValue parses the value of a scalar or enum field, an enum by name in any case.
+func parseCSVValue(field protoreflect.FieldDescriptor, cell string) (protoreflect.Value, error) {
+	var value protoreflect.Value
+	var err error
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(cell)
+		value = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(cell, 10, 32)
+		value = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var n int64
+		n, err = strconv.ParseInt(cell, 10, 64)
+		value = protoreflect.ValueOfInt64(n)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var n uint64
+		n, err = strconv.ParseUint(cell, 10, 32)
+		value = protoreflect.ValueOfUint32(uint32(n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var n uint64
+		n, err = strconv.ParseUint(cell, 10, 64)
+		value = protoreflect.ValueOfUint64(n)
+	case protoreflect.FloatKind:
+		var f float64
+		f, err = strconv.ParseFloat(cell, 32)
+		value = protoreflect.ValueOfFloat32(float32(f))
+	case protoreflect.DoubleKind:
+		var f float64
+		f, err = strconv.ParseFloat(cell, 64)
+		value = protoreflect.ValueOfFloat64(f)
+	case protoreflect.StringKind:
+		value = protoreflect.ValueOfString(cell)
+	case protoreflect.EnumKind:
+		enumValue := field.Enum().Values().ByName(protoreflect.Name(strings.ToUpper(cell)))
+		if enumValue == nil {
+			return value, fmt.Errorf("unknown value %q of field %s", cell, field.Name())
+		}
+		value = protoreflect.ValueOfEnum(enumValue.Number())
+	default:
+		return value, fmt.Errorf("field %s of kind %s is not supported", field.Name(), field.Kind())
+	}
+	if err != nil {
+		return value, fmt.Errorf("invalid value %q of field %s", cell, field.Name())
+	}
+	return value, nil
+}
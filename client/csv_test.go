@@ -0,0 +1,56 @@
+package client_test
+
+import (
+	"errors"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLaptopCSVReader(t *testing.T) {
+	t.Parallel()
+
+	reader, err := client.NewLaptopCSVReader(strings.NewReader(`brand, name, price_usd, cpu.number_cores, ram, gpus[0].brand, storage[1].driver, storage[1].memory
+Apple,"MacBook Pro, 14""",1999.99,8,16GB,Apple,ssd,1 TB
+Dell,XPS 13,cheap,4,8GB,,,
+Lenovo,Thinkpad,1000,,32gb,Nvidia,,
+`))
+	require.NoError(t, err)
+
+	laptop, err := reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, 2, reader.Line())
+	require.Equal(t, "Apple", laptop.GetBrand())
+	require.Equal(t, `MacBook Pro, 14"`, laptop.GetName())
+	require.Equal(t, 1999.99, laptop.GetPriceUsd())
+	require.Equal(t, uint32(8), laptop.GetCpu().GetNumberCores())
+	require.True(t, proto.Equal(&pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}, laptop.GetRam()))
+	require.Equal(t, "Apple", laptop.GetGpus()[0].GetBrand())
+	require.Len(t, laptop.GetStorage(), 2, "the missing elements are added")
+	require.Equal(t, pb.Storage_SSD, laptop.GetStorage()[1].GetDriver())
+	require.True(t, proto.Equal(&pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE}, laptop.GetStorage()[1].GetMemory()))
+
+	_, err = reader.Read()
+	var rowErr *client.CSVRowError
+	require.True(t, errors.As(err, &rowErr))
+	require.Equal(t, 3, rowErr.Line)
+	require.Contains(t, rowErr.Error(), "price_usd")
+
+	laptop, err = reader.Read()
+	require.NoError(t, err, "the rows after a rejected one are read")
+	require.Equal(t, "Thinkpad", laptop.GetName())
+	require.Nil(t, laptop.GetCpu(), "the empty cells are left unset")
+
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+
+	for _, header := range []string{"unknown", "gpus.brand", "cpu", "brand[0]", "ram.value.unit"} {
+		_, err := client.NewLaptopCSVReader(strings.NewReader(header + "\n"))
+		require.Error(t, err, header)
+	}
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const laptopServicePath = "/grpc_app.proto.LaptopService/"
+
+// DefaultTimeout is the timeout applied to unary RPCs without a method-specific timeout.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultMethodTimeouts returns the default timeouts of the laptop service RPCs.
+func DefaultMethodTimeouts() map[string]time.Duration {
+	return map[string]time.Duration{
+		loginMethod:                              5 * time.Second,
+		laptopServicePath + "CreateLaptop":       5 * time.Second,
+		laptopServicePath + "BatchCreateLaptops": 10 * time.Second,
+		laptopServicePath + "GetLaptop":          5 * time.Second,
+		laptopServicePath + "SearchLaptop":       30 * time.Second,
+		laptopServicePath + "UploadImage":        30 * time.Second,
+		laptopServicePath + "RateLaptop":         30 * time.Second,
+	}
+}
+
+// timeoutCallOption overrides the default timeout of a single call.
+type timeoutCallOption struct {
+	grpc.EmptyCallOption
+	timeout time.Duration
+}
+
+// WithTimeout returns a call option that overrides the default timeout of the call.
+func WithTimeout(timeout time.Duration) grpc.CallOption {
+	return timeoutCallOption{timeout: timeout}
+}
+
+// WithDefaultTimeouts makes the connection apply a timeout to calls whose context has no deadline.
+// Unary RPCs fall back to defaultTimeout, streaming RPCs only get a timeout if listed in methodTimeouts.
+func WithDefaultTimeouts(defaultTimeout time.Duration, methodTimeouts map[string]time.Duration) Option {
+	interceptor := &DeadlineInterceptor{defaultTimeout: defaultTimeout, methodTimeouts: methodTimeouts}
+	return func(options *dialOptions) {
+		options.unaryInterceptors = append(options.unaryInterceptors, interceptor.Unary())
+		options.streamInterceptors = append(options.streamInterceptors, interceptor.Stream())
+	}
+}
+
+// DeadlineInterceptor is a client interceptor that applies default deadlines.
+type DeadlineInterceptor struct {
+	defaultTimeout time.Duration
+	methodTimeouts map[string]time.Duration
+}
+
+// Unary returns a client interceptor to apply the default deadline to unary RPC.
+func (interceptor *DeadlineInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx, cancel := interceptor.withDeadline(ctx, method, interceptor.defaultTimeout, opts)
+		defer cancel()
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		return deadlineError(ctx, method, start, err)
+	}
+}
+
+// Stream returns a client interceptor to apply the default deadline to stream RPC.
+func (interceptor *DeadlineInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx, cancel := interceptor.withDeadline(ctx, method, 0, opts)
+
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, deadlineError(ctx, method, start, err)
+		}
+
+		return &deadlineClientStream{ClientStream: stream, ctx: ctx, cancel: cancel, method: method, start: start}, nil
+	}
+}
+
+// withDeadline returns a context with the per-call, method or fallback timeout applied.
+func (interceptor *DeadlineInterceptor) withDeadline(
+	ctx context.Context,
+	method string,
+	fallback time.Duration,
+	opts []grpc.CallOption,
+) (context.Context, context.CancelFunc) {
+	for _, opt := range opts {
+		if timeoutOpt, ok := opt.(timeoutCallOption); ok {
+			return context.WithTimeout(ctx, timeoutOpt.timeout)
+		}
+	}
+
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+
+	timeout, ok := interceptor.methodTimeouts[method]
+	if !ok {
+		timeout = fallback
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	log.Printf("warning: %s is called without a deadline, applying default timeout %v", method, timeout)
+	return context.WithTimeout(ctx, timeout)
+}
+
+// deadlineError reports the elapsed time and the budget when a call exceeds its deadline.
+func deadlineError(ctx context.Context, method string, start time.Time, err error) error {
+	if status.Code(err) != codes.DeadlineExceeded {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return status.Errorf(codes.DeadlineExceeded, "%s: deadline exceeded after %v: %v", method, elapsed, err)
+	}
+
+	budget := deadline.Sub(start)
+	return status.Errorf(
+		codes.DeadlineExceeded,
+		"%s: deadline exceeded after %v of %v budget: %v",
+		method, elapsed.Round(time.Millisecond), budget.Round(time.Millisecond), status.Convert(err).Message(),
+	)
+}
+
+// deadlineClientStream releases the stream context once the stream is finished.
+type deadlineClientStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	cancel context.CancelFunc
+	method string
+	start  time.Time
+}
+
+func (stream *deadlineClientStream) RecvMsg(m interface{}) error {
+	err := stream.ClientStream.RecvMsg(m)
+	if err != nil {
+		stream.cancel()
+		return deadlineError(stream.ctx, stream.method, stream.start, err)
+	}
+	return nil
+}
+
+func (stream *deadlineClientStream) SendMsg(m interface{}) error {
+	err := stream.ClientStream.SendMsg(m)
+	return deadlineError(stream.ctx, stream.method, stream.start, err)
+}
@@ -0,0 +1,87 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDefaultTimeouts(t *testing.T) {
+	t.Parallel()
+
+	// remaining receives the time left before the deadline of each call, or 0 if it has none.
+	remaining := make(chan time.Duration, 1)
+	timeLeft := func(ctx context.Context) time.Duration {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return 0
+		}
+		return time.Until(deadline)
+	}
+	server := &fakeLaptopServer{
+		create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+			remaining <- timeLeft(ctx)
+			if laptop.GetBrand() == "slow" {
+				<-ctx.Done()
+				return "", status.FromContextError(ctx.Err()).Err()
+			}
+			return laptop.GetId(), nil
+		},
+		search: func(req *pb.SearchLaptopRequest, stream pb.LaptopService_SearchLaptopServer) error {
+			remaining <- timeLeft(stream.Context())
+			return nil
+		},
+	}
+	const createLaptop = "/grpc_app.proto.LaptopService/CreateLaptop"
+	conn, err := client.Dial(dialFakeLaptopServer(t, server).Target(),
+		client.WithServiceConfig(`{}`),
+		client.WithDefaultTimeouts(time.Minute, map[string]time.Duration{createLaptop: 200 * time.Millisecond}),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	laptopService := pb.NewLaptopServiceClient(conn)
+
+	create := func(ctx context.Context, laptop *pb.Laptop, timeout time.Duration) (time.Duration, error) {
+		var err error
+		if timeout > 0 {
+			_, err = laptopService.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: laptop}, client.WithTimeout(timeout))
+		} else {
+			_, err = laptopService.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: laptop})
+		}
+		return <-remaining, err
+	}
+
+	left, err := create(context.Background(), sample.NewLaptop(), 0)
+	require.NoError(t, err)
+	require.InDelta(t, 200*time.Millisecond, left, float64(100*time.Millisecond), "the timeout of the method is applied")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	left, err = create(ctx, sample.NewLaptop(), 0)
+	require.NoError(t, err)
+	require.Greater(t, left, 5*time.Second, "the deadline of the caller is kept")
+
+	left, err = create(ctx, sample.NewLaptop(), 3*time.Second)
+	require.NoError(t, err)
+	require.InDelta(t, 3*time.Second, left, float64(time.Second), "the timeout of the call overrides the others")
+
+	slow := sample.NewLaptop()
+	slow.Brand = "slow"
+	_, err = create(context.Background(), slow, 0)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.Contains(t, err.Error(), createLaptop+": deadline exceeded after")
+	require.Contains(t, err.Error(), "of 200ms budget")
+
+	stream, err := laptopService.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Error(t, err)
+	require.Zero(t, <-remaining, "the streams without a timeout of their method have no deadline")
+}
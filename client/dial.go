@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Option configures a connection created by Dial.
+type Option func(*dialOptions)
+
+type dialOptions struct {
+	tls                *TLSConfig
+	serviceConfig      string
+	compressor         string
+	readyTimeout       time.Duration
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+	grpcOptions        []grpc.DialOption
+}
+
+// WithTLS makes Dial connect to the server over TLS.
+func WithTLS(config TLSConfig) Option {
+	return func(options *dialOptions) {
+		options.tls = &config
+	}
+}
+
+// WithGRPCOptions appends raw gRPC dial options, e.g. interceptors.
+func WithGRPCOptions(opts ...grpc.DialOption) Option {
+	return func(options *dialOptions) {
+		options.grpcOptions = append(options.grpcOptions, opts...)
+	}
+}
+
+// WithMaxMessageSize sets the largest messages the calls of the connection may receive and send,
+// in bytes, in place of the gRPC defaults of 4 MiB received and unlimited sent. A size of 0 keeps the default.
+func WithMaxMessageSize(maxRecvSize int, maxSendSize int) Option {
+	return func(options *dialOptions) {
+		var callOptions []grpc.CallOption
+		if maxRecvSize > 0 {
+			callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(maxRecvSize))
+		}
+		if maxSendSize > 0 {
+			callOptions = append(callOptions, grpc.MaxCallSendMsgSize(maxSendSize))
+		}
+		options.grpcOptions = append(options.grpcOptions, grpc.WithDefaultCallOptions(callOptions...))
+	}
+}
+
+// Dial creates a client connection to the laptop server.
+// The connection is insecure unless WithTLS is given.
+// The address may list several servers separated by commas, see StaticTarget, to balance the calls across them.
+func Dial(address string, opts ...Option) (*grpc.ClientConn, error) {
+	options := &dialOptions{serviceConfig: DefaultServiceConfig}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	transportOption := grpc.WithTransportCredentials(insecure.NewCredentials())
+	if options.tls != nil {
+		tlsCredentials, err := LoadTLSCredentials(*options.tls)
+		if err != nil {
+			return nil, err
+		}
+		transportOption = grpc.WithTransportCredentials(tlsCredentials)
+	}
+
+	grpcOptions := []grpc.DialOption{
+		transportOption,
+		grpc.WithDefaultServiceConfig(options.serviceConfig),
+		grpc.WithChainUnaryInterceptor(options.unaryInterceptors...),
+		grpc.WithChainStreamInterceptor(options.streamInterceptors...),
+	}
+	if options.compressor != "" {
+		err := checkCompressor(options.compressor)
+		if err != nil {
+			return nil, err
+		}
+		grpcOptions = append(grpcOptions, grpc.WithDefaultCallOptions(grpc.UseCompressor(options.compressor)))
+	}
+	if addresses := balancedAddresses(address); addresses != nil {
+		address = StaticTarget(addresses)
+		grpcOptions = append(grpcOptions, staticDialOptions(addresses)...)
+	}
+	grpcOptions = append(grpcOptions, options.grpcOptions...)
+
+	conn, err := grpc.Dial(address, grpcOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.readyTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), options.readyTimeout)
+		defer cancel()
+
+		err = WaitForReady(ctx, conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,44 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWithMaxMessageSize(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeLaptopServer{create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+		// The response is as large as the name of the laptop.
+		return laptop.GetName(), nil
+	}}
+	target := dialFakeLaptopServer(t, server).Target()
+
+	createLaptop := func(nameSize int, opts ...client.Option) error {
+		conn, err := client.Dial(target, opts...)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		laptop := sample.NewLaptop()
+		laptop.Name = strings.Repeat("x", nameSize)
+		_, err = pb.NewLaptopServiceClient(conn).CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+		return err
+	}
+
+	require.NoError(t, createLaptop(8<<10))
+	require.NoError(t, createLaptop(8<<10, client.WithMaxMessageSize(0, 0)), "the gRPC defaults are kept")
+
+	err := createLaptop(8<<10, client.WithMaxMessageSize(0, 4<<10))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), "the request is too large to send")
+	err = createLaptop(8<<10, client.WithMaxMessageSize(4<<10, 0))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), "the response is too large to receive")
+	require.NoError(t, createLaptop(8<<10, client.WithMaxMessageSize(16<<10, 16<<10)))
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"grpc_app/tracing"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WithInstrumentation makes the connection trace every call with the tracer and record
+// its latency and status in the metrics. Either of them can be nil.
+func WithInstrumentation(tracer *tracing.Tracer, metrics *ClientMetrics) Option {
+	interceptor := &InstrumentationInterceptor{tracer: tracer, metrics: metrics}
+	return func(options *dialOptions) {
+		options.unaryInterceptors = append(options.unaryInterceptors, interceptor.Unary())
+		options.streamInterceptors = append(options.streamInterceptors, interceptor.Stream())
+	}
+}
+
+// InstrumentationInterceptor is a client interceptor that creates spans, propagates
+// the trace context in the metadata and records per-method metrics.
+type InstrumentationInterceptor struct {
+	tracer  *tracing.Tracer
+	metrics *ClientMetrics
+}
+
+// Unary returns a client interceptor to instrument unary RPC.
+func (interceptor *InstrumentationInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx, finish := interceptor.start(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finish(err)
+		return err
+	}
+}
+
+// Stream returns a client interceptor to instrument stream RPC.
+func (interceptor *InstrumentationInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx, finish := interceptor.start(ctx, method)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		return &instrumentedClientStream{ClientStream: stream, serverStreams: desc.ServerStreams, finish: finish}, nil
+	}
+}
+
+// start starts the span of the call, and returns the function to call when it's done.
+func (interceptor *InstrumentationInterceptor) start(ctx context.Context, method string) (context.Context, func(error)) {
+	var span *tracing.Span
+	if interceptor.tracer != nil {
+		ctx, span = interceptor.tracer.Start(ctx, method, tracing.KindClient)
+		span.SetAttribute("rpc.system", "grpc")
+		span.SetAttribute("rpc.method", method)
+		ctx = tracing.Inject(ctx)
+	}
+
+	start := time.Now()
+	once := sync.Once{}
+
+	return ctx, func(err error) {
+		once.Do(func() {
+			code := status.Code(err)
+			if interceptor.metrics != nil {
+				interceptor.metrics.record(method, code, time.Since(start))
+			}
+			if span != nil {
+				span.SetStatus(code, err)
+				span.Finish()
+			}
+		})
+	}
+}
+
+// instrumentedClientStream finishes the call once the stream ends.
+type instrumentedClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	finish        func(error)
+}
+
+func (stream *instrumentedClientStream) RecvMsg(m interface{}) error {
+	err := stream.ClientStream.RecvMsg(m)
+	if err == io.EOF {
+		stream.finish(nil)
+	} else if err != nil {
+		stream.finish(err)
+	} else if !stream.serverStreams {
+		// Client-streaming calls are done once the single response is received.
+		stream.finish(nil)
+	}
+	return err
+}
+
+// MethodMetrics contains the metrics of the calls to one method.
+type MethodMetrics struct {
+	Calls        int64
+	Errors       map[codes.Code]int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// AverageLatency returns the average latency of the calls.
+func (m MethodMetrics) AverageLatency() time.Duration {
+	if m.Calls == 0 {
+		return 0
+	}
+	return m.TotalLatency / time.Duration(m.Calls)
+}
+
+// ClientMetrics records the latency and status of the calls per method.
+type ClientMetrics struct {
+	mutex   sync.Mutex
+	methods map[string]*MethodMetrics
+}
+
+// NewClientMetrics returns a new client metrics.
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{methods: make(map[string]*MethodMetrics)}
+}
+
+func (metrics *ClientMetrics) record(method string, code codes.Code, latency time.Duration) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	m := metrics.methods[method]
+	if m == nil {
+		m = &MethodMetrics{Errors: make(map[codes.Code]int64)}
+		metrics.methods[method] = m
+	}
+
+	m.Calls++
+	if code != codes.OK {
+		m.Errors[code]++
+	}
+	m.TotalLatency += latency
+	if latency > m.MaxLatency {
+		m.MaxLatency = latency
+	}
+}
+
+// Methods returns the names of the methods that have been called, sorted.
+func (metrics *ClientMetrics) Methods() []string {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	methods := make([]string, 0, len(metrics.methods))
+	for method := range metrics.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Method returns a copy of the metrics of the method.
+func (metrics *ClientMetrics) Method(method string) MethodMetrics {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	m := metrics.methods[method]
+	if m == nil {
+		return MethodMetrics{Errors: make(map[codes.Code]int64)}
+	}
+
+	other := *m
+	other.Errors = make(map[codes.Code]int64, len(m.Errors))
+	for code, count := range m.Errors {
+		other.Errors[code] = count
+	}
+	return other
+}
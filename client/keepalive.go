@@ -0,0 +1,23 @@
+package client
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// WithKeepalive makes the connection ping the server after it's idle for pingTime, at least 10 seconds,
+// and close if the ping isn't acked within the timeout, 20 seconds if 0.
+// The connection is only pinged while streams are active unless permitWithoutStream is true.
+// The server closes the connections of the clients that ping more often than its keepalive policy allows,
+// see service.KeepaliveConfig.
+func WithKeepalive(pingTime time.Duration, timeout time.Duration, permitWithoutStream bool) Option {
+	return func(options *dialOptions) {
+		options.grpcOptions = append(options.grpcOptions, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                pingTime,
+			Timeout:             timeout,
+			PermitWithoutStream: permitWithoutStream,
+		}))
+	}
+}
@@ -0,0 +1,10 @@
+package client
+
+// acceptLanguageHeader is the metadata key that selects the language of the error messages.
+const acceptLanguageHeader = "accept-language"
+
+// WithLanguage asks the server to localize the error messages of every call,
+// e.g. "fr-CH, fr;q=0.9". The status codes and details are not localized.
+func WithLanguage(acceptLanguage string) Option {
+	return withHeader(acceptLanguageHeader, acceptLanguage)
+}
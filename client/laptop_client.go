@@ -11,14 +11,17 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 // LaptopClient is a client to call laptop service RPC.
 type LaptopClient struct {
 	service pb.LaptopServiceClient
+	cache   *LaptopCache
 }
 
 // NewLaptopClient returns a new laptop client.
@@ -27,28 +30,209 @@ func NewLaptopClient(cc *grpc.ClientConn) *LaptopClient {
 	return &LaptopClient{service: service}
 }
 
-// CreateLaptop calls  create laptop RPC.
-func (laptopClient *LaptopClient) CreateLaptop(laptop *pb.Laptop) {
-	req := &pb.CreateLaptopRequest{
-		Laptop: laptop,
+// NewCachedLaptopClient returns a new laptop client that keeps the laptops it sees in the cache.
+func NewCachedLaptopClient(cc *grpc.ClientConn, cache *LaptopCache) *LaptopClient {
+	service := pb.NewLaptopServiceClient(cc)
+	return &LaptopClient{service: service, cache: cache}
+}
+
+// Cache returns the client's laptop cache, or nil if caching is disabled.
+func (laptopClient *LaptopClient) Cache() *LaptopCache {
+	return laptopClient.cache
+}
+
+// CreateLaptop calls create laptop RPC, and returns the ID of the new laptop.
+// A laptop without ID is sent with a new one, so that the call is safe to retry:
+// if an attempt is stored but its response lost, the retry of WithRetry fails with
+// codes.AlreadyExists, which it returns as a success with an empty response. A laptop given
+// with an ID still fails with codes.AlreadyExists, as another laptop may have had that ID.
+func (laptopClient *LaptopClient) CreateLaptop(ctx context.Context, laptop *pb.Laptop) (string, error) {
+	if laptop.GetId() == "" {
+		laptop = proto.Clone(laptop).(*pb.Laptop)
+		laptop.Id = uuid.NewString()
+		ctx = contextWithGeneratedID(ctx)
 	}
 
-	// set timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	res, err := laptopClient.service.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: laptop})
+	if err != nil {
+		return "", err
+	}
+
+	id := res.GetId()
+	if id == "" {
+		id = laptop.GetId()
+	}
+	if laptopClient.cache != nil {
+		created := proto.Clone(laptop).(*pb.Laptop)
+		created.Id = id
+		laptopClient.cache.Set(created)
+		laptopClient.cache.InvalidateSearches()
+	}
+	return id, nil
+}
+
+// GetLaptop calls get laptop RPC. If fields are given, e.g. "id" or "cpu.brand",
+// the server only returns these fields. Full laptops are served from the cache if enabled.
+func (laptopClient *LaptopClient) GetLaptop(ctx context.Context, id string, fields ...string) (*pb.Laptop, error) {
+	if laptopClient.cache != nil && len(fields) == 0 {
+		if laptop := laptopClient.cache.Get(id); laptop != nil {
+			return laptop, nil
+		}
+	}
+
+	req := &pb.GetLaptopRequest{Id: id, ReadMask: fieldMask(fields)}
+	res, err := laptopClient.service.GetLaptop(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if laptopClient.cache != nil && len(fields) == 0 {
+		laptopClient.cache.Set(res.GetLaptop())
+	}
+	return res.GetLaptop(), nil
+}
 
-	res, err := laptopClient.service.CreateLaptop(ctx, req)
+// UpdateLaptop calls update laptop RPC to replace the laptop with the same ID, and returns the updated laptop
+// with its next version. It fails with codes.Aborted if the laptop doesn't have the stored version.
+// If fields are given, e.g. "price_usd" or "ram", only these fields are replaced, and the version
+// is only checked if it is one of them.
+func (laptopClient *LaptopClient) UpdateLaptop(ctx context.Context, laptop *pb.Laptop, fields ...string) (*pb.Laptop, error) {
+	req := &pb.UpdateLaptopRequest{Laptop: laptop, UpdateMask: fieldMask(fields)}
+	res, err := laptopClient.service.UpdateLaptop(ctx, req)
 	if err != nil {
-		st, ok := status.FromError(err)
-		if ok && st.Code() == codes.AlreadyExists {
-			log.Print("laptop already exist")
-		} else {
-			log.Fatal("cannot create laptop: ", err)
+		if laptopClient.cache != nil {
+			laptopClient.cache.Invalidate(laptop.GetId())
 		}
-		return
+		return nil, err
+	}
+
+	if laptopClient.cache != nil {
+		laptopClient.cache.Set(res.GetLaptop())
+		laptopClient.cache.InvalidateSearches()
+	}
+	return res.GetLaptop(), nil
+}
+
+// DeleteLaptop calls delete laptop RPC.
+func (laptopClient *LaptopClient) DeleteLaptop(ctx context.Context, id string) error {
+	if laptopClient.cache != nil {
+		defer laptopClient.cache.Invalidate(id)
+	}
+
+	_, err := laptopClient.service.DeleteLaptop(ctx, &pb.DeleteLaptopRequest{Id: id})
+	return err
+}
+
+// RestoreLaptop calls restore laptop RPC, and returns the restored laptop.
+func (laptopClient *LaptopClient) RestoreLaptop(ctx context.Context, id string) (*pb.Laptop, error) {
+	res, err := laptopClient.service.RestoreLaptop(ctx, &pb.RestoreLaptopRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	if laptopClient.cache != nil {
+		laptopClient.cache.Set(res.GetLaptop())
+		laptopClient.cache.InvalidateSearches()
 	}
+	return res.GetLaptop(), nil
+}
 
-	log.Printf("created laptop with id: %s", res.Id)
+// CountLaptops calls count laptops RPC, and returns the number of laptops matching the filter.
+func (laptopClient *LaptopClient) CountLaptops(ctx context.Context, filter *pb.Filter) (int64, error) {
+	res, err := laptopClient.service.CountLaptops(ctx, &pb.CountLaptopsRequest{Filter: filter})
+	if err != nil {
+		return 0, err
+	}
+	return res.GetCount(), nil
+}
+
+// GetCatalogStats calls get catalog stats RPC, and returns the statistics of the laptops matching the filter.
+func (laptopClient *LaptopClient) GetCatalogStats(ctx context.Context, filter *pb.Filter) (*pb.CatalogStats, error) {
+	res, err := laptopClient.service.GetCatalogStats(ctx, &pb.GetCatalogStatsRequest{Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	return res.GetStats(), nil
+}
+
+// ListLaptops calls list laptops RPC, and returns a page of at most pageSize laptops, the server's default
+// if it is 0, starting at the page token, and the token of the next page, empty after the last page.
+func (laptopClient *LaptopClient) ListLaptops(
+	ctx context.Context,
+	pageSize int,
+	pageToken string,
+	fields ...string,
+) ([]*pb.Laptop, string, error) {
+	req := &pb.ListLaptopsRequest{PageSize: int32(pageSize), PageToken: pageToken, ReadMask: fieldMask(fields)}
+	res, err := laptopClient.service.ListLaptops(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	return res.GetLaptops(), res.GetNextPageToken(), nil
+}
+
+// AcquireHold calls acquire hold RPC to hold the laptop for ttl, and returns the hold ID
+// and its expiry time. It fails with codes.Aborted if someone else holds the laptop.
+func (laptopClient *LaptopClient) AcquireHold(ctx context.Context, laptopID string, ttl time.Duration) (string, time.Time, error) {
+	req := &pb.AcquireHoldRequest{LaptopId: laptopID, Ttl: durationpb.New(ttl)}
+	res, err := laptopClient.service.AcquireHold(ctx, req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return res.GetHoldId(), res.GetExpiresAt().AsTime(), nil
+}
+
+// ReleaseHold calls release hold RPC.
+func (laptopClient *LaptopClient) ReleaseHold(ctx context.Context, laptopID string, holdID string) error {
+	req := &pb.ReleaseHoldRequest{LaptopId: laptopID, HoldId: holdID}
+	_, err := laptopClient.service.ReleaseHold(ctx, req)
+	return err
+}
+
+// RecommendLaptops calls recommend laptops RPC to get at most limit laptops with the best score
+// for the workload, like the laptop with the ID if it isn't empty, or within the budget otherwise.
+func (laptopClient *LaptopClient) RecommendLaptops(
+	ctx context.Context,
+	laptopID string,
+	budgetUSD float64,
+	workload pb.RecommendLaptopsRequest_Workload,
+	limit int,
+) ([]*pb.RecommendedLaptop, error) {
+	req := &pb.RecommendLaptopsRequest{Workload: workload, Limit: uint32(limit)}
+	if laptopID != "" {
+		req.Target = &pb.RecommendLaptopsRequest_LaptopId{LaptopId: laptopID}
+	} else {
+		req.Target = &pb.RecommendLaptopsRequest_BudgetUsd{BudgetUsd: budgetUSD}
+	}
+
+	res, err := laptopClient.service.RecommendLaptops(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res.GetLaptops(), nil
+}
+
+// GetTrendingLaptops calls get trending laptops RPC to get at most limit laptops
+// with the most views over the window.
+func (laptopClient *LaptopClient) GetTrendingLaptops(
+	ctx context.Context,
+	window time.Duration,
+	limit int,
+) ([]*pb.TrendingLaptop, error) {
+	req := &pb.GetTrendingLaptopsRequest{Window: durationpb.New(window), Limit: uint32(limit)}
+	res, err := laptopClient.service.GetTrendingLaptops(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res.GetLaptops(), nil
+}
+
+// readMask returns the field mask selecting the fields, or nil to select all of them.
+func fieldMask(fields []string) *fieldmaskpb.FieldMask {
+	if len(fields) == 0 {
+		return nil
+	}
+	return &fieldmaskpb.FieldMask{Paths: fields}
 }
 
 // SearchLaptop calls search laptop RPC.
@@ -58,28 +242,115 @@ func (laptopClient *LaptopClient) SearchLaptop(filter *pb.Filter) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	req := &pb.SearchLaptopRequest{Filter: filter}
-	stream, err := laptopClient.service.SearchLaptop(ctx, req)
+	it, err := laptopClient.Search(ctx, filter, 0)
 	if err != nil {
 		log.Fatal("cannot search laptop ", err)
 	}
+	defer it.Close()
+
+	for it.Next() {
+		laptop := it.Laptop()
+		log.Print("- found: ", laptop.GetId())
+		log.Print("  + brand: ", laptop.GetBrand())
+		log.Print("  + name: ", laptop.GetName())
+		log.Print("  + cpu cores: ", laptop.GetCpu().GetNumberCores())
+		log.Print("  + ram: ", laptop.GetRam().GetValue(), laptop.GetRam().GetUnit())
+		log.Print("  + price: ", laptop.GetPriceUsd(), "used")
+	}
+
+	if err := it.Err(); err != nil {
+		log.Fatal("cannot receive response: ", err)
+	}
+}
+
+// ExportLaptops calls export laptops RPC, and calls found with every laptop matching the filter,
+// or with every laptop if the filter is nil, until they are all received or found returns an error.
+func (laptopClient *LaptopClient) ExportLaptops(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := laptopClient.service.ExportLaptops(ctx, &pb.ExportLaptopsRequest{Filter: filter})
+	if err != nil {
+		return err
+	}
 
 	for {
 		res, err := stream.Recv()
 		if err == io.EOF {
-			return
+			return nil
 		}
 		if err != nil {
-			log.Fatal("cannot receive response: ", err)
+			return err
 		}
 
-		laptop := res.GetLaptop()
-		log.Print("- found: ", laptop.GetId())
-		log.Print("  + brand: ", laptop.GetBrand())
-		log.Print("  + name: ", laptop.GetName())
-		log.Print("  + cpu cores: ", laptop.GetCpu().GetNumberCores())
-		log.Print("  + ram: ", laptop.GetRam().GetValue(), laptop.GetRam().GetUnit())
-		log.Print("  + price: ", laptop.GetPriceUsd(), "used")
+		err = found(res.GetLaptop())
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ImportLaptops calls import laptops RPC to stream the laptops to the server,
+// and returns how many were created, skipped or failed.
+func (laptopClient *LaptopClient) ImportLaptops(ctx context.Context, laptops []*pb.Laptop) (*pb.ImportLaptopsResponse, error) {
+	if laptopClient.cache != nil {
+		defer laptopClient.cache.InvalidateSearches()
+	}
+
+	stream, err := laptopClient.service.ImportLaptops(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, laptop := range laptops {
+		err := stream.Send(&pb.ImportLaptopsRequest{Laptop: laptop})
+		if err == io.EOF {
+			// The server ended the stream, and its error is returned by CloseAndRecv.
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stream.CloseAndRecv()
+}
+
+// WatchLaptops calls watch laptops RPC, and calls changed with every change of the laptops
+// matching the filter, until the context is done or changed returns an error. The changes made
+// since the change of the resume token are sent first, unless it's empty.
+func (laptopClient *LaptopClient) WatchLaptops(
+	ctx context.Context,
+	filter *pb.Filter,
+	resumeToken string,
+	changed func(res *pb.WatchLaptopsResponse) error,
+) error {
+	req := &pb.WatchLaptopsRequest{Filter: filter, ResumeToken: resumeToken}
+	stream, err := laptopClient.service.WatchLaptops(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if laptopClient.cache != nil {
+			laptopClient.cache.Invalidate(res.GetLaptop().GetId())
+		}
+		err = changed(res)
+		if err != nil {
+			return err
+		}
 	}
 }
 
@@ -146,6 +417,74 @@ func (laptopClient *LaptopClient) UploadImage(laptopID string, imagePath string)
 	log.Printf("image uploaded with id: %s, size: %d", res.GetId(), res.GetSize())
 }
 
+// UploadImageFile calls upload image RPC to stream the image file of the laptop in chunks of chunkSize bytes,
+// and returns the ID and size of the stored image. If progress isn't nil, it's called with the number of bytes
+// sent after each chunk, and the size of the file. The chunk size must be positive.
+func (laptopClient *LaptopClient) UploadImageFile(
+	ctx context.Context,
+	laptopID string,
+	imagePath string,
+	chunkSize int,
+	progress func(sent int64, total int64),
+) (*pb.UploadImageResponse, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive: %d", chunkSize)
+	}
+
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open image file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat image file: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := laptopClient.service.UploadImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{
+			Info: &pb.ImageInfo{
+				LaptopId:  laptopID,
+				ImageType: filepath.Ext(imagePath),
+			},
+		},
+	}
+	err = stream.Send(req)
+
+	buffer := make([]byte, chunkSize)
+	sent := int64(0)
+	for err == nil {
+		var n int
+		n, err = file.Read(buffer)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read image file: %w", err)
+		}
+
+		err = stream.Send(&pb.UploadImageRequest{Data: &pb.UploadImageRequest_ChunkData{ChunkData: buffer[:n]}})
+		sent += int64(n)
+		if err == nil && progress != nil {
+			progress(sent, info.Size())
+		}
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// If the server ended the stream, its error is returned by CloseAndRecv.
+	return stream.CloseAndRecv()
+}
+
 func (laptopClient *LaptopClient) RateLaptop(laptopIDs []string, scores []float64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -182,7 +521,7 @@ func (laptopClient *LaptopClient) RateLaptop(laptopIDs []string, scores []float6
 		}
 		err := stream.Send(req)
 		if err != nil {
-			return fmt.Errorf("cannot send stream request: %v", err, stream.RecvMsg(nil))
+			return fmt.Errorf("cannot send stream request: %v - %v", err, stream.RecvMsg(nil))
 		}
 
 		log.Print("send request: ", req)
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// withHeader adds the metadata header to every call of the connection.
+func withHeader(key, value string) Option {
+	return func(options *dialOptions) {
+		options.unaryInterceptors = append(options.unaryInterceptors, func(
+			ctx context.Context,
+			method string,
+			req, reply interface{},
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			return invoker(metadata.AppendToOutgoingContext(ctx, key, value), method, req, reply, cc, opts...)
+		})
+		options.streamInterceptors = append(options.streamInterceptors, func(
+			ctx context.Context,
+			desc *grpc.StreamDesc,
+			cc *grpc.ClientConn,
+			method string,
+			streamer grpc.Streamer,
+			opts ...grpc.CallOption,
+		) (grpc.ClientStream, error) {
+			return streamer(metadata.AppendToOutgoingContext(ctx, key, value), desc, cc, method, opts...)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimiter is a token bucket that allows rate calls per second on average,
+// with bursts of up to burst calls.
+type RateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a new rate limiter with a full bucket.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token if one is available, and returns whether it did.
+func (limiter *RateLimiter) Allow() bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.refill(time.Now())
+	if limiter.tokens < 1 {
+		return false
+	}
+
+	limiter.tokens--
+	return true
+}
+
+// Wait blocks until a token is available or the context is done.
+func (limiter *RateLimiter) Wait(ctx context.Context) error {
+	limiter.mutex.Lock()
+	limiter.refill(time.Now())
+	limiter.tokens--
+	missing := -limiter.tokens
+	limiter.mutex.Unlock()
+
+	if missing <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(missing / limiter.rate * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the reserved token back.
+		limiter.mutex.Lock()
+		limiter.tokens++
+		limiter.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (limiter *RateLimiter) refill(now time.Time) {
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.last = now
+}
+
+// WithRateLimit throttles the outgoing calls of the connection with the limiter.
+// If block is true, calls wait for their turn, otherwise they fail with ResourceExhausted.
+func WithRateLimit(limiter *RateLimiter, block bool) Option {
+	interceptor := &RateLimitInterceptor{limiter: limiter, block: block}
+	return func(options *dialOptions) {
+		options.unaryInterceptors = append(options.unaryInterceptors, interceptor.Unary())
+		options.streamInterceptors = append(options.streamInterceptors, interceptor.Stream())
+	}
+}
+
+// RateLimitInterceptor is a client interceptor that throttles outgoing calls.
+type RateLimitInterceptor struct {
+	limiter *RateLimiter
+	block   bool
+}
+
+// Unary returns a client interceptor to throttle unary RPC.
+func (interceptor *RateLimitInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := interceptor.take(ctx, method)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Stream returns a client interceptor to throttle stream RPC.
+func (interceptor *RateLimitInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		err := interceptor.take(ctx, method)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+func (interceptor *RateLimitInterceptor) take(ctx context.Context, method string) error {
+	if !interceptor.block {
+		if !interceptor.limiter.Allow() {
+			return status.Errorf(codes.ResourceExhausted, "client rate limit exceeded for %s", method)
+		}
+		return nil
+	}
+
+	err := interceptor.limiter.Wait(ctx)
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
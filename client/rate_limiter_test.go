@@ -0,0 +1,27 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	limiter := client.NewRateLimiter(20, 2)
+	require.True(t, limiter.Allow())
+	require.True(t, limiter.Allow())
+	require.False(t, limiter.Allow())
+
+	start := time.Now()
+	require.NoError(t, limiter.Wait(context.Background()))
+	require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, limiter.Wait(ctx), context.Canceled)
+}
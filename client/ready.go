@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// WithWaitForReady makes Dial block until the connection is ready, for at most timeout.
+func WithWaitForReady(timeout time.Duration) Option {
+	return func(options *dialOptions) {
+		options.readyTimeout = timeout
+	}
+}
+
+// WaitForReady blocks until the connection is ready or the context is done.
+func WaitForReady(ctx context.Context, conn *grpc.ClientConn) error {
+	conn.Connect()
+
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if state == connectivity.Shutdown {
+			return fmt.Errorf("connection is shut down")
+		}
+
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection is not ready, last state %s: %w", state, ctx.Err())
+		}
+	}
+}
+
+// Ping checks that the server is up by calling the standard health check service.
+// A server that doesn't implement the health service but answers is considered up.
+func Ping(ctx context.Context, conn *grpc.ClientConn) error {
+	healthClient := grpc_health_v1.NewHealthClient(conn)
+
+	res, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.WaitForReady(true))
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot ping server: %w", err)
+	}
+
+	if res.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("server is %s", res.GetStatus())
+	}
+	return nil
+}
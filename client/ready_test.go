@@ -0,0 +1,59 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestWaitForReady(t *testing.T) {
+	t.Parallel()
+
+	target := dialFakeLaptopServer(t, &fakeLaptopServer{}).Target()
+	conn, err := client.Dial(target, client.WithWaitForReady(5*time.Second))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// The port is closed once the listener is, so the connection never gets ready.
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+	start := time.Now()
+	_, err = client.Dial(listener.Addr().String(), client.WithWaitForReady(200*time.Millisecond))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "connection is not ready")
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn := dialFakeLaptopServer(t, &fakeLaptopServer{})
+	require.NoError(t, client.Ping(ctx, conn), "a server without health service is up")
+
+	healthServer := health.NewServer()
+	grpcServer := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err = client.Dial(listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, client.Ping(ctx, conn))
+
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	require.EqualError(t, client.Ping(ctx, conn), "server is NOT_SERVING")
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy configures the retries of the unary calls of a connection created with WithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a call, the first one included.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled after each retry up to MaxBackoff.
+	// Each wait is jittered by up to 20% so that the clients don't retry in lockstep.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// PerAttemptTimeout bounds each attempt, so that an attempt stuck on a slow server
+	// is retried within the deadline of the call. The attempts are not bounded if 0.
+	PerAttemptTimeout time.Duration
+	// Methods are the full names of the retried methods, which must be safe to call more than once.
+	Methods []string
+}
+
+// createLaptopMethod is the full name of the create laptop RPC, whose retries may find the laptop created.
+const createLaptopMethod = laptopServicePath + "CreateLaptop"
+
+type generatedIDKey struct{}
+
+// contextWithGeneratedID marks the call of the context as creating a laptop whose ID was generated
+// for it, so that no other laptop can have that ID.
+func contextWithGeneratedID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, generatedIDKey{}, true)
+}
+
+// hasGeneratedID reports whether the call of the context creates a laptop whose ID was generated for it.
+func hasGeneratedID(ctx context.Context) bool {
+	generated, _ := ctx.Value(generatedIDKey{}).(bool)
+	return generated
+}
+
+// DefaultRetryPolicy returns the retry policy of the idempotent RPCs of the laptop and auth services.
+// CreateLaptop is retried as well, since LaptopClient.CreateLaptop sends the ID of the new laptop,
+// but UpdateLaptop is not: a retry after a lost response would fail with a version conflict.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:       4,
+		InitialBackoff:    100 * time.Millisecond,
+		MaxBackoff:        2 * time.Second,
+		PerAttemptTimeout: 2 * time.Second,
+		Methods: []string{
+			loginMethod,
+			createLaptopMethod,
+			laptopServicePath + "GetLaptop",
+			laptopServicePath + "CountLaptops",
+			laptopServicePath + "GetCatalogStats",
+			laptopServicePath + "ListLaptops",
+			laptopServicePath + "RecommendLaptops",
+			laptopServicePath + "GetTrendingLaptops",
+		},
+	}
+}
+
+// WithRetry makes the connection retry the unary calls of the methods of the policy that fail
+// with codes.Unavailable, or with codes.DeadlineExceeded when only the attempt timed out.
+// A retry of LaptopClient.CreateLaptop failing with codes.AlreadyExists succeeds if the client
+// generated the ID of the laptop, as the laptop was created by a previous attempt whose response was lost.
+// The error is kept for an ID given by the caller, which may have belonged to another laptop already.
+// The retries of the service config are disabled, so that the attempts of a call are not multiplied.
+// It must be given after WithDefaultTimeouts, so that the retries share the deadline of the call.
+func WithRetry(policy RetryPolicy) Option {
+	interceptor := NewRetryInterceptor(policy)
+	return func(options *dialOptions) {
+		options.unaryInterceptors = append(options.unaryInterceptors, interceptor.Unary())
+		options.grpcOptions = append(options.grpcOptions, grpc.WithDisableRetry())
+	}
+}
+
+// RetryInterceptor is a client interceptor that retries the failed unary calls.
+type RetryInterceptor struct {
+	policy  RetryPolicy
+	methods map[string]bool
+}
+
+// NewRetryInterceptor returns a new retry interceptor with the policy.
+func NewRetryInterceptor(policy RetryPolicy) *RetryInterceptor {
+	methods := make(map[string]bool, len(policy.Methods))
+	for _, method := range policy.Methods {
+		methods[method] = true
+	}
+	return &RetryInterceptor{policy: policy, methods: methods}
+}
+
+// Unary returns a client interceptor to retry unary RPC.
+func (interceptor *RetryInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if !interceptor.methods[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		backoff := interceptor.policy.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			err := interceptor.invoke(ctx, method, req, reply, cc, invoker, opts)
+			if attempt > 1 && method == createLaptopMethod && status.Code(err) == codes.AlreadyExists && hasGeneratedID(ctx) {
+				return nil
+			}
+			if err == nil || attempt >= interceptor.policy.MaxAttempts || !interceptor.retryable(ctx, err) {
+				return err
+			}
+
+			wait := jitter(backoff)
+			log.Printf("retry %s in %v after attempt %d failed: %v", method, wait, attempt, err)
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+
+			backoff *= 2
+			if backoff > interceptor.policy.MaxBackoff {
+				backoff = interceptor.policy.MaxBackoff
+			}
+		}
+	}
+}
+
+// invoke makes an attempt of the call, bounded by the per-attempt timeout of the policy.
+func (interceptor *RetryInterceptor) invoke(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts []grpc.CallOption,
+) error {
+	if interceptor.policy.PerAttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, interceptor.policy.PerAttemptTimeout)
+		defer cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// retryable reports whether the call may be retried after the error of an attempt.
+// A deadline exceeded is only retried if the call itself has time left.
+func (interceptor *RetryInterceptor) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return true
+	case codes.DeadlineExceeded:
+		return interceptor.policy.PerAttemptTimeout > 0
+	default:
+		return false
+	}
+}
+
+// jitter returns the backoff shifted randomly by up to 20% either way.
+func jitter(backoff time.Duration) time.Duration {
+	return time.Duration(float64(backoff) * (0.8 + 0.4*rand.Float64()))
+}
@@ -0,0 +1,113 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryInterceptor(t *testing.T) {
+	t.Parallel()
+
+	const method = "/grpc_app.proto.LaptopService/GetLaptop"
+	interceptor := client.NewRetryInterceptor(client.RetryPolicy{
+		MaxAttempts:       3,
+		InitialBackoff:    time.Millisecond,
+		MaxBackoff:        time.Millisecond,
+		PerAttemptTimeout: 20 * time.Millisecond,
+		Methods:           []string{method},
+	}).Unary()
+
+	call := func(method string, errs ...error) (int, error) {
+		attempts := 0
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			err := errs[attempts]
+			attempts++
+			if status.Code(err) == codes.DeadlineExceeded {
+				<-ctx.Done()
+			}
+			return err
+		}
+		err := interceptor(context.Background(), method, nil, nil, nil, invoker)
+		return attempts, err
+	}
+
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	timedOut := status.Error(codes.DeadlineExceeded, "deadline exceeded")
+
+	attempts, err := call(method, unavailable, timedOut, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	attempts, err = call(method, unavailable, unavailable, unavailable)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 3, attempts, "the attempts are bounded")
+
+	attempts, err = call(method, status.Error(codes.InvalidArgument, "invalid"))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, 1, attempts, "the other errors are not retried")
+
+	attempts, err = call("/grpc_app.proto.LaptopService/DeleteLaptop", unavailable)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 1, attempts, "the methods of the policy only are retried")
+}
+
+func TestRetryInterceptorCreateLaptop(t *testing.T) {
+	t.Parallel()
+
+	policy := client.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = time.Millisecond
+	require.NotContains(t, policy.Methods, "/grpc_app.proto.LaptopService/UpdateLaptop",
+		"a retried update would fail with a version conflict")
+
+	// The server stores the laptops, but loses the response of the first attempt of each of them.
+	var mutex sync.Mutex
+	stored := map[string]bool{"existing": true}
+	server := &fakeLaptopServer{create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if stored[laptop.GetId()] {
+			return "", status.Error(codes.AlreadyExists, "already exists")
+		}
+		stored[laptop.GetId()] = true
+		return "", status.Error(codes.Unavailable, "response lost")
+	}}
+	conn, err := client.Dial(dialFakeLaptopServer(t, server).Target(), client.WithRetry(policy))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	laptopClient := client.NewLaptopClient(conn)
+
+	laptop := sample.NewLaptop()
+	laptop.Id = ""
+	id, err := laptopClient.CreateLaptop(context.Background(), laptop)
+	require.NoError(t, err, "the laptop was created by the first attempt")
+	require.NotEmpty(t, id)
+	mutex.Lock()
+	require.True(t, stored[id], "the retry sent the generated ID again")
+	mutex.Unlock()
+	require.Equal(t, int32(2), atomic.LoadInt32(&server.calls))
+
+	laptop = sample.NewLaptop()
+	laptop.Id = "existing"
+	_, err = laptopClient.CreateLaptop(context.Background(), laptop)
+	require.Equal(t, codes.AlreadyExists, status.Code(err), "the laptop existed before the call")
+	require.Equal(t, int32(3), atomic.LoadInt32(&server.calls))
+
+	laptop = sample.NewLaptop()
+	laptop.Id = "given"
+	_, err = laptopClient.CreateLaptop(context.Background(), laptop)
+	require.Equal(t, codes.AlreadyExists, status.Code(err),
+		"the retry cannot tell whether a given ID belonged to another laptop")
+}
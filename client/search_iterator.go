@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"grpc_app/pb"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// SearchIterator iterates over the laptops streamed back by the search laptop RPC.
+//
+//	it, err := laptopClient.Search(ctx, filter, time.Second)
+//	...
+//	defer it.Close()
+//	for it.Next() {
+//		laptop := it.Laptop()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type SearchIterator struct {
+	stream      pb.LaptopService_SearchLaptopClient
+	cancel      context.CancelFunc
+	itemTimeout time.Duration
+	timedOut    int32
+
+	// cache stores the results of req once they are all received, and cached
+	// holds the remaining results of a search served from the cache.
+	cache   *LaptopCache
+	req     *pb.SearchLaptopRequest
+	results []*pb.Laptop
+	cached  []*pb.Laptop
+
+	laptop *pb.Laptop
+	err    error
+	done   bool
+}
+
+// Search starts a search laptop RPC and returns an iterator over the found laptops.
+// If itemTimeout is positive, the search fails when the next laptop doesn't arrive in time.
+// If fields are given, e.g. "id" or "cpu.brand", the server only returns these fields.
+// The results are served from the cache if enabled, until a laptop is changed by the client.
+func (laptopClient *LaptopClient) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	itemTimeout time.Duration,
+	fields ...string,
+) (*SearchIterator, error) {
+	req := &pb.SearchLaptopRequest{Filter: filter, ReadMask: fieldMask(fields)}
+	if laptopClient.cache != nil {
+		if laptops, ok := laptopClient.cache.GetSearch(req); ok {
+			return &SearchIterator{cancel: func() {}, cached: laptops}, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := laptopClient.service.SearchLaptop(ctx, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &SearchIterator{stream: stream, cancel: cancel, itemTimeout: itemTimeout, cache: laptopClient.cache, req: req}, nil
+}
+
+// Next advances to the next laptop, and returns false when there is no more laptop or an error occurs.
+func (it *SearchIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	if it.stream == nil {
+		if len(it.cached) == 0 {
+			it.finish(io.EOF)
+			return false
+		}
+		it.laptop, it.cached = it.cached[0], it.cached[1:]
+		return true
+	}
+
+	var timer *time.Timer
+	if it.itemTimeout > 0 {
+		timer = time.AfterFunc(it.itemTimeout, func() {
+			atomic.StoreInt32(&it.timedOut, 1)
+			it.cancel()
+		})
+	}
+
+	res, err := it.stream.Recv()
+	if timer != nil {
+		timer.Stop()
+	}
+
+	if err != nil {
+		it.finish(err)
+		return false
+	}
+
+	it.laptop = res.GetLaptop()
+	if it.cache != nil {
+		it.results = append(it.results, proto.Clone(it.laptop).(*pb.Laptop))
+	}
+	return true
+}
+
+// Laptop returns the current laptop.
+func (it *SearchIterator) Laptop() *pb.Laptop {
+	return it.laptop
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+// Close stops the search and releases its resources.
+func (it *SearchIterator) Close() {
+	it.done = true
+	it.cancel()
+}
+
+func (it *SearchIterator) finish(err error) {
+	it.done = true
+	it.laptop = nil
+	it.cancel()
+
+	switch {
+	case err == io.EOF:
+		it.err = nil
+		if it.cache != nil {
+			it.cache.SetSearch(it.req, it.results)
+		}
+	case atomic.LoadInt32(&it.timedOut) == 1:
+		it.err = status.Errorf(codes.DeadlineExceeded, "no laptop received within %v", it.itemTimeout)
+	default:
+		it.err = err
+	}
+}
@@ -0,0 +1,84 @@
+package client_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// searchAll returns the IDs of the laptops of the iterator, and the error that stopped it.
+func searchAll(it *client.SearchIterator) ([]string, error) {
+	defer it.Close()
+
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Laptop().GetId())
+	}
+	return ids, it.Err()
+}
+
+func TestSearchIterator(t *testing.T) {
+	t.Parallel()
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop()}
+	canceled := make(chan struct{})
+	server := &fakeLaptopServer{search: func(req *pb.SearchLaptopRequest, stream pb.LaptopService_SearchLaptopServer) error {
+		for _, laptop := range laptops {
+			if err := stream.Send(&pb.SearchLaptopResponse{Laptop: laptop}); err != nil {
+				return err
+			}
+		}
+		switch req.GetFilter().GetMaxPriceUsd() {
+		case 1:
+			return status.Error(codes.Internal, "cannot search laptops")
+		case 2:
+			<-stream.Context().Done()
+			close(canceled)
+			return stream.Context().Err()
+		default:
+			return nil
+		}
+	}}
+	laptopClient := client.NewLaptopClient(dialFakeLaptopServer(t, server))
+	laptopIDs := []string{laptops[0].GetId(), laptops[1].GetId()}
+
+	it, err := laptopClient.Search(context.Background(), &pb.Filter{}, time.Second)
+	require.NoError(t, err)
+	ids, err := searchAll(it)
+	require.NoError(t, err, "the end of the stream is not an error")
+	require.Equal(t, laptopIDs, ids)
+	require.False(t, it.Next(), "the iteration stays done")
+	require.Nil(t, it.Laptop())
+
+	it, err = laptopClient.Search(context.Background(), &pb.Filter{MaxPriceUsd: 1}, time.Second)
+	require.NoError(t, err)
+	ids, err = searchAll(it)
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Equal(t, laptopIDs, ids, "the laptops before the error are returned")
+
+	it, err = laptopClient.Search(context.Background(), &pb.Filter{MaxPriceUsd: 2}, 50*time.Millisecond)
+	require.NoError(t, err)
+	ids, err = searchAll(it)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.Contains(t, err.Error(), "no laptop received within 50ms")
+	require.Equal(t, laptopIDs, ids)
+	<-canceled
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it, err = laptopClient.Search(ctx, &pb.Filter{MaxPriceUsd: 3}, 0)
+	require.NoError(t, err)
+	require.True(t, it.Next())
+	cancel()
+	ids, err = searchAll(it)
+	if err != nil {
+		require.Equal(t, codes.Canceled, status.Code(err))
+	}
+	require.LessOrEqual(t, len(ids), 1, "the canceled search stops")
+}
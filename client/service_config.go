@@ -0,0 +1,23 @@
+package client
+
+import (
+	_ "embed"
+)
+
+// DefaultServiceConfig is the gRPC service config applied by Dial unless the
+// resolver provides one or it's overridden with WithServiceConfig.
+// It retries the idempotent reads (Get, List, Search and Count) on UNAVAILABLE, waits for the
+// connection to be ready, sets timeouts on the unary methods and balances calls with round robin.
+// WithRetry replaces these retries, so that the attempts of a call are not multiplied.
+// The streams but SearchLaptop have no timeout, so that the exports, imports
+// and watches last as long as they need.
+//
+//go:embed service_config.json
+var DefaultServiceConfig string
+
+// WithServiceConfig overrides the default service config with the given JSON.
+func WithServiceConfig(serviceConfig string) Option {
+	return func(options *dialOptions) {
+		options.serviceConfig = serviceConfig
+	}
+}
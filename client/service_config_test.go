@@ -0,0 +1,124 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestDefaultServiceConfigTimeouts(t *testing.T) {
+	t.Parallel()
+
+	var config struct {
+		MethodConfig []struct {
+			Name []struct {
+				Service string `json:"service"`
+				Method  string `json:"method"`
+			} `json:"name"`
+			Timeout string `json:"timeout"`
+		} `json:"methodConfig"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(client.DefaultServiceConfig), &config))
+
+	// Dial rejects an invalid default service config.
+	conn, err := grpc.Dial(
+		"passthrough:///localhost:0",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(client.DefaultServiceConfig),
+	)
+	require.NoError(t, err)
+	conn.Close()
+
+	// timeout returns the timeout of the method, from its own entry or else from the entry of its service.
+	timeout := func(method string) string {
+		serviceTimeout := ""
+		for _, methodConfig := range config.MethodConfig {
+			for _, name := range methodConfig.Name {
+				if name.Service != "grpc_app.proto.LaptopService" {
+					continue
+				}
+				if name.Method == method {
+					return methodConfig.Timeout
+				}
+				if name.Method == "" {
+					serviceTimeout = methodConfig.Timeout
+				}
+			}
+		}
+		return serviceTimeout
+	}
+
+	require.Equal(t, "5s", timeout("GetLaptop"))
+	require.Equal(t, "30s", timeout("CreateLaptop"))
+	require.Equal(t, "30s", timeout("ListLaptops"))
+	require.Empty(t, timeout("ExportLaptops"))
+	require.Empty(t, timeout("ImportLaptops"))
+	require.Empty(t, timeout("WatchLaptops"))
+
+	watch := 0
+	for _, methodConfig := range config.MethodConfig {
+		for _, name := range methodConfig.Name {
+			if name.Method == "WatchLaptops" {
+				watch++
+			}
+		}
+	}
+	require.Equal(t, 1, watch, "WatchLaptops has its own entry")
+}
+
+func TestDefaultServiceConfigRetries(t *testing.T) {
+	t.Parallel()
+
+	// The server fails the first call of each method as unavailable.
+	var searches int32
+	server := &fakeLaptopServer{
+		create: func(ctx context.Context, laptop *pb.Laptop) (string, error) {
+			return "", status.Error(codes.Unavailable, "unavailable")
+		},
+		search: func(req *pb.SearchLaptopRequest, stream pb.LaptopService_SearchLaptopServer) error {
+			if atomic.AddInt32(&searches, 1) == 1 {
+				return status.Error(codes.Unavailable, "unavailable")
+			}
+			return stream.Send(&pb.SearchLaptopResponse{Laptop: sample.NewLaptop()})
+		},
+	}
+	target := dialFakeLaptopServer(t, server).Target()
+
+	search := func(opts ...client.Option) error {
+		conn, err := client.Dial(target, opts...)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		stream, err := pb.NewLaptopServiceClient(conn).SearchLaptop(context.Background(), &pb.SearchLaptopRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		return err
+	}
+
+	require.NoError(t, search(), "the search is retried")
+	require.Equal(t, int32(2), atomic.LoadInt32(&searches))
+
+	conn, err := client.Dial(target)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = pb.NewLaptopServiceClient(conn).CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, int32(1), atomic.LoadInt32(&server.calls), "the creation is not retried by the service config")
+
+	atomic.StoreInt32(&searches, 0)
+	policy := client.DefaultRetryPolicy()
+	policy.Methods = nil
+	err = search(client.WithRetry(policy))
+	require.Equal(t, codes.Unavailable, status.Code(err), "WithRetry replaces the retries of the service config")
+	require.Equal(t, int32(1), atomic.LoadInt32(&searches))
+}
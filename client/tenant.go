@@ -0,0 +1,10 @@
+package client
+
+// tenantHeader is the metadata key that selects the tenant of a call.
+const tenantHeader = "x-tenant-id"
+
+// WithTenant makes every call of the connection target the given tenant.
+// Authenticated users can only access their own tenant.
+func WithTenant(tenant string) Option {
+	return withHeader(tenantHeader, tenant)
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSConfig contains the client-side TLS settings.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of trusted CA certificates.
+	// The system cert pool is used if it's empty.
+	CAFile string
+	// ServerName overrides the name used to verify the server certificate,
+	// useful in test environments where the address doesn't match the certificate.
+	ServerName string
+	// SPKIPins is a list of base64-encoded SHA-256 hashes of the subject public key info.
+	// If set, at least one certificate of the verified chain must match one of the pins.
+	SPKIPins []string
+	// CertFile and KeyFile are the PEM certificate and private key of the client,
+	// presented to the servers that authenticate their clients with mutual TLS.
+	CertFile string
+	KeyFile  string
+}
+
+// LoadTLSCredentials returns the transport credentials built from the TLS config.
+func LoadTLSCredentials(config TLSConfig) (credentials.TransportCredentials, error) {
+	tlsConfig := &tls.Config{
+		ServerName: config.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config.CAFile != "" {
+		pemServerCA, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file: %w", err)
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(pemServerCA) {
+			return nil, fmt.Errorf("failed to add server CA's certificate")
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	if config.CertFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	if len(config.SPKIPins) > 0 {
+		pins := make(map[string]bool, len(config.SPKIPins))
+		for _, pin := range config.SPKIPins {
+			hash, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("invalid SPKI pin %q, must be a base64-encoded SHA-256 hash", pin)
+			}
+			pins[pin] = true
+		}
+		tlsConfig.VerifyPeerCertificate = verifySPKIPins(pins)
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// verifySPKIPins returns a function that checks the verified chains against the SPKI pins.
+func verifySPKIPins(pins map[string]bool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, chain := range verifiedChains {
+			for _, cert := range chain {
+				if pins[SPKIPin(cert)] {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("no certificate matches the SPKI pins")
+	}
+}
+
+// SPKIPin returns the base64-encoded SHA-256 hash of the certificate's subject public key info.
+func SPKIPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+const browseHelp = `commands:
+  n, p                        next or previous page
+  filter [filter flags]       search again, e.g. filter -max-price 2000 -text dell (defaults for the flags not given)
+  show <#>                    show all the fields of a laptop of the page
+  edit <#> <field>=<value>... update fields of a laptop, e.g. edit 3 price_usd=1999 ram=32GB cpu.number_cores=8
+  delete <#>                  delete a laptop
+  refresh                     search again with the same filter
+  help                        show the commands
+  q                           quit`
+
+// browser is an interactive terminal browser of the laptops found by a search.
+type browser struct {
+	laptopClient *client.LaptopClient
+	in           *bufio.Scanner
+	out          io.Writer
+	columns      []string
+	pageSize     int
+
+	filter  *pb.Filter
+	laptops []*pb.Laptop
+	page    int
+	message string
+}
+
+// runBrowse starts the interactive browser of the laptops, which lists the laptops matching a filter page by page,
+// and shows, edits or deletes them with commands typed at its prompt.
+func runBrowse(laptopClient *client.LaptopClient, args []string) {
+	flags := flag.NewFlagSet("browse", flag.ExitOnError)
+	filter := filterFlags(flags)
+	pageSize := flags.Int("page-size", 20, "number of laptops of each page")
+	columns := flags.String("columns", "id,brand,name,cores,ram,price", "comma-separated columns of the list")
+	flags.Parse(args)
+
+	b := &browser{
+		laptopClient: laptopClient,
+		in:           bufio.NewScanner(os.Stdin),
+		out:          os.Stdout,
+		pageSize:     *pageSize,
+	}
+	for _, column := range strings.Split(*columns, ",") {
+		column = strings.TrimSpace(column)
+		if tableColumns[column] == nil {
+			log.Fatalf("unknown column %q", column)
+		}
+		b.columns = append(b.columns, column)
+	}
+	if b.pageSize < 1 {
+		log.Fatalf("page size must be positive: %d", b.pageSize)
+	}
+
+	b.search(filter())
+	for {
+		b.render()
+		fmt.Fprint(b.out, "> ")
+		if !b.in.Scan() {
+			return
+		}
+		if quit := b.run(strings.Fields(b.in.Text())); quit {
+			return
+		}
+	}
+}
+
+// search loads all the laptops matching the filter from the search stream.
+func (b *browser) search(filter *pb.Filter) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	it, err := b.laptopClient.Search(ctx, filter, 5*time.Second)
+	if err != nil {
+		b.message = fmt.Sprintf("cannot search laptops: %v", err)
+		return
+	}
+	defer it.Close()
+
+	var laptops []*pb.Laptop
+	for it.Next() {
+		laptops = append(laptops, it.Laptop())
+	}
+	if err := it.Err(); err != nil {
+		b.message = fmt.Sprintf("cannot search laptops: %v", err)
+		return
+	}
+	b.filter, b.laptops, b.page = filter, laptops, 0
+}
+
+// render draws the current page of laptops, with the message of the last command.
+func (b *browser) render() {
+	fmt.Fprint(b.out, clearScreen)
+	fmt.Fprintf(b.out, "%d laptops, page %d/%d, filter: max price %.0f, min cores %d, min %.1f GHz, min RAM %d GB",
+		len(b.laptops), b.page+1, b.pages(),
+		b.filter.GetMaxPriceUsd(), b.filter.GetMinCpuCores(), b.filter.GetMinCpuGhz(), b.filter.GetMinRam().GetValue())
+	if b.filter.GetText() != "" {
+		fmt.Fprintf(b.out, ", text %q", b.filter.GetText())
+	}
+	fmt.Fprint(b.out, "\n\n")
+
+	table := tabwriter.NewWriter(b.out, 0, 4, 2, ' ', 0)
+	header := []string{"#"}
+	for _, column := range b.columns {
+		header = append(header, strings.ToUpper(column))
+	}
+	fmt.Fprintln(table, strings.Join(header, "\t"))
+	start, end := b.pageBounds()
+	for i := start; i < end; i++ {
+		values := []string{strconv.Itoa(i + 1)}
+		for _, column := range b.columns {
+			values = append(values, tableColumns[column](b.laptops[i]))
+		}
+		fmt.Fprintln(table, strings.Join(values, "\t"))
+	}
+	table.Flush()
+
+	fmt.Fprintln(b.out)
+	if b.message != "" {
+		fmt.Fprintln(b.out, b.message)
+		b.message = ""
+	}
+	fmt.Fprintln(b.out, "n/p: page, filter, show <#>, edit <#> <field>=<value>, delete <#>, refresh, help, q: quit")
+}
+
+func (b *browser) pages() int {
+	if len(b.laptops) == 0 {
+		return 1
+	}
+	return (len(b.laptops) + b.pageSize - 1) / b.pageSize
+}
+
+// pageBounds returns the range of the indexes of the laptops of the current page.
+func (b *browser) pageBounds() (int, int) {
+	start := b.page * b.pageSize
+	end := start + b.pageSize
+	if end > len(b.laptops) {
+		end = len(b.laptops)
+	}
+	return start, end
+}
+
+// run runs the command typed at the prompt, and reports whether the browser must quit.
+func (b *browser) run(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "q", "quit", "exit":
+		return true
+	case "n", "next":
+		if b.page+1 < b.pages() {
+			b.page++
+		}
+	case "p", "prev":
+		if b.page > 0 {
+			b.page--
+		}
+	case "filter":
+		flags := flag.NewFlagSet("filter", flag.ContinueOnError)
+		var usage bytes.Buffer
+		flags.SetOutput(&usage)
+		filter := filterFlags(flags)
+		if err := flags.Parse(args[1:]); err != nil {
+			b.message = usage.String()
+			return false
+		}
+		b.search(filter())
+	case "refresh":
+		b.search(b.filter)
+	case "show":
+		laptop, ok := b.laptop(args)
+		if !ok {
+			return false
+		}
+		printer, err := newLaptopPrinter(b.out, outputYAML, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprint(b.out, clearScreen)
+		if err := printer.Print(laptop); err != nil {
+			b.message = fmt.Sprintf("cannot print laptop: %v", err)
+			return false
+		}
+		fmt.Fprint(b.out, "\npress enter to go back")
+		b.in.Scan()
+	case "edit":
+		laptop, ok := b.laptop(args)
+		if !ok {
+			return false
+		}
+		b.edit(laptop, args[2:])
+	case "delete":
+		laptop, ok := b.laptop(args)
+		if !ok {
+			return false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := b.laptopClient.DeleteLaptop(ctx, laptop.GetId()); err != nil {
+			b.message = fmt.Sprintf("cannot delete laptop: %v", err)
+			return false
+		}
+		b.search(b.filter)
+		b.message = fmt.Sprintf("deleted laptop %s", laptop.GetId())
+	case "help":
+		b.message = browseHelp
+	default:
+		b.message = fmt.Sprintf("unknown command %q, type help for the commands", args[0])
+	}
+	return false
+}
+
+// laptop returns the laptop of the number given as the argument of the command.
+func (b *browser) laptop(args []string) (*pb.Laptop, bool) {
+	if len(args) < 2 {
+		b.message = fmt.Sprintf("%s needs the # of a laptop", args[0])
+		return nil, false
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 || n > len(b.laptops) {
+		b.message = fmt.Sprintf("no laptop #%s", args[1])
+		return nil, false
+	}
+	return b.laptops[n-1], true
+}
+
+// edit updates the fields of the laptop to the values of the field=value arguments,
+// parsed like the cells of the CSV imports, e.g. ram=16GB.
+func (b *browser) edit(laptop *pb.Laptop, args []string) {
+	if len(args) == 0 {
+		b.message = "nothing to edit, e.g. edit 3 price_usd=1999"
+		return
+	}
+
+	var fields, values []string
+	for _, arg := range args {
+		field, value, ok := strings.Cut(arg, "=")
+		if !ok || strings.Contains(field, "[") {
+			b.message = fmt.Sprintf("invalid edit %q, must be <field>=<value> of a field that isn't repeated", arg)
+			return
+		}
+		fields = append(fields, field)
+		values = append(values, value)
+	}
+
+	// The fields and values are read as the header and row of a CSV file.
+	var data bytes.Buffer
+	writer := csv.NewWriter(&data)
+	writer.Write(fields)
+	writer.Write(values)
+	writer.Flush()
+	reader, err := client.NewLaptopCSVReader(&data)
+	if err != nil {
+		b.message = fmt.Sprintf("cannot edit laptop: %v", err)
+		return
+	}
+	update, err := reader.Read()
+	if err != nil {
+		b.message = fmt.Sprintf("cannot edit laptop: %v", err)
+		return
+	}
+	update.Id = laptop.GetId()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	updated, err := b.laptopClient.UpdateLaptop(ctx, update, fields...)
+	if err != nil {
+		b.message = fmt.Sprintf("cannot update laptop: %v", err)
+		return
+	}
+	for i := range b.laptops {
+		if b.laptops[i].GetId() == updated.GetId() {
+			b.laptops[i] = updated
+		}
+	}
+	b.message = fmt.Sprintf("updated %s of laptop %s", strings.Join(fields, ", "), updated.GetId())
+}
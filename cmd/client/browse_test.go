@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrowser(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	for i := 0; i < 5; i++ {
+		laptop := sample.NewLaptop()
+		laptop.PriceUsd = 1000
+		require.NoError(t, laptopStore.Save(context.Background(), laptop))
+	}
+	var output bytes.Buffer
+	b := &browser{
+		laptopClient: startTestServer(t, laptopStore),
+		in:           bufio.NewScanner(strings.NewReader("\n")),
+		out:          &output,
+		columns:      []string{"id", "price"},
+		pageSize:     2,
+	}
+
+	b.search(&pb.Filter{MaxPriceUsd: 2000})
+	require.Len(t, b.laptops, 5)
+	require.Equal(t, 3, b.pages())
+	b.render()
+	require.Contains(t, output.String(), "5 laptops, page 1/3")
+
+	for _, command := range []string{"n", "n", "n"} {
+		require.False(t, b.run([]string{command}))
+	}
+	require.Equal(t, 2, b.page, "the last page is kept")
+	start, end := b.pageBounds()
+	require.Equal(t, []int{4, 5}, []int{start, end})
+	b.run([]string{"p"})
+	require.Equal(t, 1, b.page)
+
+	b.run([]string{"edit", "1", "price_usd=1999"})
+	require.Contains(t, b.message, "updated price_usd of laptop")
+	stored, err := laptopStore.Find(context.Background(), b.laptops[0].GetId())
+	require.NoError(t, err)
+	require.Equal(t, 1999.0, stored.GetPriceUsd())
+	require.Equal(t, 1999.0, b.laptops[0].GetPriceUsd(), "the edited laptop is replaced in the list")
+
+	b.run([]string{"edit", "1", "gpus[0].brand=NVIDIA"})
+	require.Contains(t, b.message, "invalid edit")
+
+	deleted := b.laptops[1].GetId()
+	b.run([]string{"delete", "2"})
+	require.Equal(t, "deleted laptop "+deleted, b.message)
+	require.Len(t, b.laptops, 4, "the laptops are searched again")
+
+	// The other criteria are relaxed, as the sample laptops may not meet their defaults.
+	b.run([]string{"filter", "-max-price", "1500", "-min-cores", "0", "-min-ghz", "0", "-min-ram", "0"})
+	require.Len(t, b.laptops, 3)
+	require.Equal(t, 0, b.page)
+
+	output.Reset()
+	b.run([]string{"show", "1"})
+	require.Contains(t, output.String(), "id: "+b.laptops[0].GetId())
+
+	b.run([]string{"show", "9"})
+	require.Equal(t, "no laptop #9", b.message)
+	b.run([]string{"frobnicate"})
+	require.Contains(t, b.message, `unknown command "frobnicate"`)
+	require.True(t, b.run([]string{"q"}))
+}
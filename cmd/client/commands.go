@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// runCreate creates sample laptops and prints them.
+func runCreate(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	count := flags.Int("n", 1, "number of sample laptops to create")
+	concurrency := flags.Int("concurrency", 4, "maximum number of requests in flight")
+	batch := flags.Bool("batch", false, "create the laptops with a single batch request")
+	flags.Parse(args)
+
+	laptops := make([]*pb.Laptop, *count)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+	}
+
+	var ids []string
+	if *batch {
+		results, err := laptopClient.BatchCreateLaptops(context.Background(), laptops)
+		if err != nil {
+			log.Fatal("cannot create laptops: ", err)
+		}
+		ids = make([]string, len(results))
+		for i, result := range results {
+			if result.GetError() != "" {
+				log.Printf("cannot create laptop %d: %s", i, result.GetError())
+			}
+			ids[i] = result.GetId()
+		}
+	} else {
+		var err error
+		ids, err = laptopClient.CreateLaptops(context.Background(), laptops, *concurrency)
+		if err != nil {
+			log.Print("cannot create some laptops: ", err)
+		}
+	}
+
+	for i, laptop := range laptops {
+		if ids[i] == "" {
+			continue
+		}
+		laptop.Id = ids[i]
+		if err := printer.Print(laptop); err != nil {
+			log.Fatal("cannot print laptop: ", err)
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		log.Fatal("cannot print laptops: ", err)
+	}
+}
+
+// runSearch searches for laptops matching the filter flags and prints them.
+func runSearch(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	filter := filterFlags(flags)
+	itemTimeout := flags.Duration("item-timeout", 5*time.Second, "maximum time to wait for the next result")
+	fields := flags.String("fields", "", "comma-separated fields to return, e.g. id,brand,price_usd (all if empty)")
+	sortBy := flags.String("sort", "", "comma-separated fields to sort by, price_usd, cpu_number_cores or ram, "+
+		"each descending if prefixed with -, e.g. -ram,price_usd")
+	flags.Parse(args)
+
+	searchFilter := filter()
+	sorts, err := parseSort(*sortBy)
+	if err != nil {
+		log.Fatal("invalid sort: ", err)
+	}
+	searchFilter.SortBy = sorts
+
+	it, err := laptopClient.Search(context.Background(), searchFilter, *itemTimeout, splitFields(*fields)...)
+	if err != nil {
+		log.Fatal("cannot search laptop: ", err)
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := printer.Print(it.Laptop()); err != nil {
+			log.Fatal("cannot print laptop: ", err)
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		log.Fatal("cannot print laptops: ", err)
+	}
+
+	if err := it.Err(); err != nil {
+		log.Fatal("cannot receive response: ", err)
+	}
+}
+
+// runCount prints the number of laptops matching the filter flags.
+func runCount(laptopClient *client.LaptopClient, args []string) {
+	flags := flag.NewFlagSet("count", flag.ExitOnError)
+	filter := filterFlags(flags)
+	flags.Parse(args)
+
+	count, err := laptopClient.CountLaptops(context.Background(), filter())
+	if err != nil {
+		log.Fatal("cannot count laptops: ", err)
+	}
+	fmt.Println(count)
+}
+
+// runStats prints the statistics of the laptops matching the filter flags as JSON.
+func runStats(laptopClient *client.LaptopClient, args []string) {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	filter := filterFlags(flags)
+	flags.Parse(args)
+
+	stats, err := laptopClient.GetCatalogStats(context.Background(), filter())
+	if err != nil {
+		log.Fatal("cannot get catalog stats: ", err)
+	}
+
+	data, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(stats)
+	if err != nil {
+		log.Fatal("cannot print catalog stats: ", err)
+	}
+	fmt.Println(string(data))
+}
+
+// runList prints a page of laptops, or all of them page by page, and the token of the next page if any.
+func runList(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	pageSize := flags.Int("page-size", 0, "maximum number of laptops of a page (the server's default if 0)")
+	pageToken := flags.String("page-token", "", "the token of the page to list, printed after the previous page")
+	all := flags.Bool("all", false, "list every page from the page token")
+	fields := flags.String("fields", "", "comma-separated fields to return, e.g. id,brand,price_usd (all if empty)")
+	flags.Parse(args)
+
+	token := *pageToken
+	for {
+		laptops, nextPageToken, err := laptopClient.ListLaptops(context.Background(), *pageSize, token, splitFields(*fields)...)
+		if err != nil {
+			log.Fatal("cannot list laptops: ", err)
+		}
+		for _, laptop := range laptops {
+			if err := printer.Print(laptop); err != nil {
+				log.Fatal("cannot print laptop: ", err)
+			}
+		}
+
+		token = nextPageToken
+		if token == "" || !*all {
+			break
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		log.Fatal("cannot print laptops: ", err)
+	}
+	if token != "" {
+		log.Print("next page token: ", token)
+	}
+}
+
+// filterFlags defines the filter flags of search and count,
+// and returns a function building the filter once the flags are parsed.
+func filterFlags(flags *flag.FlagSet) func() *pb.Filter {
+	maxPrice := flags.Float64("max-price", 3000, "maximum price in USD")
+	minCores := flags.Uint("min-cores", 4, "minimum number of CPU cores")
+	minGhz := flags.Float64("min-ghz", 2.5, "minimum CPU frequency in GHz")
+	minRAM := flags.Uint64("min-ram", 8, "minimum RAM in gigabytes")
+	text := flags.String("text", "", "words that must appear in the brand, name or CPU of the laptops")
+
+	return func() *pb.Filter {
+		return &pb.Filter{
+			MaxPriceUsd: *maxPrice,
+			MinCpuCores: uint32(*minCores),
+			MinCpuGhz:   *minGhz,
+			MinRam:      &pb.Memory{Value: *minRAM, Unit: pb.Memory_GIGABYTE},
+			Text:        *text,
+		}
+	}
+}
+
+// runGet gets the laptops with the given IDs and prints them.
+func runGet(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	fields := flags.String("fields", "", "comma-separated fields to return, e.g. id,brand,price_usd (all if empty)")
+	flags.Parse(args)
+
+	for _, id := range flags.Args() {
+		laptop, err := laptopClient.GetLaptop(context.Background(), id, splitFields(*fields)...)
+		if err != nil {
+			log.Fatal("cannot get laptop: ", err)
+		}
+		if err := printer.Print(laptop); err != nil {
+			log.Fatal("cannot print laptop: ", err)
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		log.Fatal("cannot print laptops: ", err)
+	}
+}
+
+// runUpdate updates only the price or the RAM of the laptops with the given IDs, and prints them.
+func runUpdate(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	price := flags.Float64("price", 0, "the new price in USD")
+	ramGB := flags.Uint64("ram-gb", 0, "the new RAM in gigabytes")
+	flags.Parse(args)
+
+	update := &pb.Laptop{
+		PriceUsd: *price,
+		Ram:      &pb.Memory{Value: *ramGB, Unit: pb.Memory_GIGABYTE},
+	}
+	var fields []string
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "price":
+			fields = append(fields, "price_usd")
+		case "ram-gb":
+			fields = append(fields, "ram")
+		}
+	})
+	if len(fields) == 0 {
+		log.Fatal("nothing to update, set -price or -ram-gb")
+	}
+
+	for _, id := range flags.Args() {
+		update.Id = id
+		laptop, err := laptopClient.UpdateLaptop(context.Background(), update, fields...)
+		if err != nil {
+			log.Fatal("cannot update laptop: ", err)
+		}
+		if err := printer.Print(laptop); err != nil {
+			log.Fatal("cannot print laptop: ", err)
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		log.Fatal("cannot print laptops: ", err)
+	}
+}
+
+// runDelete deletes the laptops with the given IDs.
+func runDelete(laptopClient *client.LaptopClient, args []string) {
+	for _, id := range args {
+		err := laptopClient.DeleteLaptop(context.Background(), id)
+		if err != nil {
+			log.Fatal("cannot delete laptop: ", err)
+		}
+		log.Printf("deleted laptop with id: %s", id)
+	}
+}
+
+// runRestore restores the deleted laptops with the given IDs and prints them.
+func runRestore(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	for _, id := range args {
+		laptop, err := laptopClient.RestoreLaptop(context.Background(), id)
+		if err != nil {
+			log.Fatal("cannot restore laptop: ", err)
+		}
+		if err := printer.Print(laptop); err != nil {
+			log.Fatal("cannot print laptop: ", err)
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		log.Fatal("cannot print laptops: ", err)
+	}
+}
+
+// runRecommend prints the laptops recommended for a workload, within a budget or like a laptop.
+func runRecommend(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	flags := flag.NewFlagSet("recommend", flag.ExitOnError)
+	laptopID := flags.String("like", "", "recommend laptops like the laptop with this ID")
+	budget := flags.Float64("budget", 0, "recommend laptops of at most this price in USD")
+	workload := flags.String("workload", "", "the workload: office, development or gaming (balanced if empty)")
+	limit := flags.Int("limit", 10, "maximum number of laptops")
+	flags.Parse(args)
+
+	workloadValue := int32(pb.RecommendLaptopsRequest_WORKLOAD_UNSPECIFIED)
+	if *workload != "" {
+		var ok bool
+		workloadValue, ok = pb.RecommendLaptopsRequest_Workload_value[strings.ToUpper(*workload)]
+		if !ok {
+			log.Fatalf("unknown workload %q", *workload)
+		}
+	}
+
+	recommended, err := laptopClient.RecommendLaptops(
+		context.Background(),
+		*laptopID,
+		*budget,
+		pb.RecommendLaptopsRequest_Workload(workloadValue),
+		*limit,
+	)
+	if err != nil {
+		log.Fatal("cannot recommend laptops: ", err)
+	}
+
+	for _, r := range recommended {
+		log.Printf("laptop %s: score %.2f", r.GetLaptop().GetId(), r.GetScore())
+		if err := printer.Print(r.GetLaptop()); err != nil {
+			log.Fatal("cannot print laptop: ", err)
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		log.Fatal("cannot print laptops: ", err)
+	}
+}
+
+// runTrending prints the most viewed laptops.
+func runTrending(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	flags := flag.NewFlagSet("trending", flag.ExitOnError)
+	window := flags.Duration("window", time.Hour, "how far back to count the views")
+	limit := flags.Int("limit", 10, "maximum number of laptops")
+	flags.Parse(args)
+
+	trending, err := laptopClient.GetTrendingLaptops(context.Background(), *window, *limit)
+	if err != nil {
+		log.Fatal("cannot get trending laptops: ", err)
+	}
+
+	for _, t := range trending {
+		log.Printf("laptop %s: %d views, %d impressions", t.GetLaptop().GetId(), t.GetViews(), t.GetImpressions())
+		if err := printer.Print(t.GetLaptop()); err != nil {
+			log.Fatal("cannot print laptop: ", err)
+		}
+	}
+
+	if err := printer.Flush(); err != nil {
+		log.Fatal("cannot print laptops: ", err)
+	}
+}
+
+func splitFields(fields string) []string {
+	if fields == "" {
+		return nil
+	}
+	return strings.Split(fields, ",")
+}
+
+// parseSort parses the sort fields of the sort flag.
+func parseSort(sortBy string) ([]*pb.Sort, error) {
+	var sorts []*pb.Sort
+	for _, name := range splitFields(sortBy) {
+		sort := &pb.Sort{}
+		if strings.HasPrefix(name, "-") {
+			sort.Descending = true
+			name = name[1:]
+		}
+		field, ok := pb.Sort_Field_value[strings.ToUpper(name)]
+		if !ok || field == int32(pb.Sort_FIELD_UNSPECIFIED) {
+			return nil, fmt.Errorf("unknown sort field %q", name)
+		}
+		sort.Field = pb.Sort_Field(field)
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the CLI config file in the home directory.
+const configFileName = ".laptopctl.yaml"
+
+// cliConfig is the content of the CLI config file:
+//
+//	default_profile: staging
+//	profiles:
+//	  staging:
+//	    address: staging.example.com:443
+//	    tls: true
+//	    username: admin1
+//	    password: secret
+//	    output: table
+type cliConfig struct {
+	DefaultProfile string              `yaml:"default_profile"`
+	Profiles       map[string]*profile `yaml:"profiles"`
+}
+
+// profile holds the settings of one environment.
+type profile struct {
+	Address    string   `yaml:"address"`
+	TLS        *bool    `yaml:"tls"`
+	CAFile     string   `yaml:"ca_file"`
+	ServerName string   `yaml:"server_name"`
+	SPKIPins   []string `yaml:"spki_pins"`
+	Tenant     string   `yaml:"tenant"`
+	Username   string   `yaml:"username"`
+	Password   string   `yaml:"password"`
+	Output     string   `yaml:"output"`
+	Columns    string   `yaml:"columns"`
+}
+
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return configFileName
+	}
+	return filepath.Join(home, configFileName)
+}
+
+// loadProfile reads the named profile from the config file, or its default profile if name is empty.
+// It returns nil if no profile is requested and the config file doesn't exist.
+func loadProfile(configPath string, name string) (*profile, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) && name == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	config := &cliConfig{}
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %w", configPath, err)
+	}
+
+	if name == "" {
+		name = config.DefaultProfile
+		if name == "" {
+			return nil, nil
+		}
+	}
+
+	p := config.Profiles[name]
+	if p == nil {
+		return nil, fmt.Errorf("profile %q not found in %s", name, configPath)
+	}
+	return p, nil
+}
+
+// applyProfile sets the flags that are not given on the command line from the profile.
+func applyProfile(flags *flag.FlagSet, p *profile) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	values := map[string]string{
+		"address":     p.Address,
+		"ca-file":     p.CAFile,
+		"server-name": p.ServerName,
+		"spki-pins":   strings.Join(p.SPKIPins, ","),
+		"tenant":      p.Tenant,
+		"username":    p.Username,
+		"password":    p.Password,
+		"output":      p.Output,
+		"columns":     p.Columns,
+	}
+	if p.TLS != nil {
+		values["tls"] = strconv.FormatBool(*p.TLS)
+	}
+
+	for name, value := range values {
+		if value == "" || explicit[name] {
+			continue
+		}
+		err := flags.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("invalid profile value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	exportNDJSON = "ndjson"
+	exportJSON   = "json"
+
+	// exportProgressInterval is the number of exported laptops between two progress reports.
+	exportProgressInterval = 1000
+)
+
+// runExport streams the laptops matching the filter flags, all of them by default, to a file
+// of newline-delimited JSON or a single JSON array. The file is only replaced once the export is complete,
+// so a failed export doesn't leave a partial backup behind.
+func runExport(laptopClient *client.LaptopClient, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	filter := filterFlags(flags)
+	path := flags.String("file", "", "the file to export the laptops to (stdout if empty)")
+	format := flags.String("format", exportNDJSON, "the format of the file: ndjson, one laptop per line, or json, a single array")
+	flags.Parse(args)
+
+	if *format != exportNDJSON && *format != exportJSON {
+		log.Fatalf("unknown export format %q, must be ndjson or json", *format)
+	}
+
+	var file *os.File
+	out := io.Writer(os.Stdout)
+	if *path != "" {
+		var err error
+		file, err = os.CreateTemp(filepath.Dir(*path), filepath.Base(*path)+".*.tmp")
+		if err != nil {
+			log.Fatal("cannot create export file: ", err)
+		}
+		defer os.Remove(file.Name())
+		out = file
+	}
+
+	count, err := exportLaptops(laptopClient, filter(), out, *format)
+	if err != nil {
+		log.Fatal("cannot export laptops: ", err)
+	}
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			log.Fatal("cannot write export file: ", err)
+		}
+		if err := os.Rename(file.Name(), *path); err != nil {
+			log.Fatal("cannot write export file: ", err)
+		}
+		log.Printf("exported %d laptops to %s", count, *path)
+	}
+}
+
+// exportLaptops writes the laptops matching the filter to out in the format, and returns how many were written.
+func exportLaptops(laptopClient *client.LaptopClient, filter *pb.Filter, out io.Writer, format string) (int, error) {
+	writer := bufio.NewWriter(out)
+	if format == exportJSON {
+		writer.WriteString("[")
+	}
+
+	count := 0
+	err := laptopClient.ExportLaptops(context.Background(), filter, func(laptop *pb.Laptop) error {
+		data, err := stableJSON(laptop)
+		if err != nil {
+			return err
+		}
+		if format == exportJSON {
+			separator := ",\n"
+			if count == 0 {
+				separator = "\n"
+			}
+			writer.WriteString(separator)
+		}
+		writer.Write(data)
+		if format == exportNDJSON {
+			writer.WriteString("\n")
+		}
+
+		count++
+		if count%exportProgressInterval == 0 {
+			log.Printf("exported %d laptops", count)
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	if format == exportJSON {
+		fmt.Fprint(writer, "\n]\n")
+	}
+	return count, writer.Flush()
+}
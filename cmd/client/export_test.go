@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// startTestServer serves the laptops of the store, and returns a client of them.
+func startTestServer(t *testing.T, laptopStore service.LaptopStore) *client.LaptopClient {
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(laptopStore, nil, nil))
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := client.Dial(listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return client.NewLaptopClient(conn)
+}
+
+func TestExportLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	var ids []string
+	for i := 0; i < 3; i++ {
+		laptop := sample.NewLaptop()
+		laptop.PriceUsd = 1000
+		require.NoError(t, laptopStore.Save(context.Background(), laptop))
+		ids = append(ids, laptop.GetId())
+	}
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, laptopStore.Save(context.Background(), expensive))
+	laptopClient := startTestServer(t, laptopStore)
+
+	var output bytes.Buffer
+	count, err := exportLaptops(laptopClient, &pb.Filter{MaxPriceUsd: 2000}, &output, exportNDJSON)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	var exported []string
+	for _, line := range strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n") {
+		var laptop struct{ ID string }
+		require.NoError(t, json.Unmarshal([]byte(line), &laptop))
+		exported = append(exported, laptop.ID)
+	}
+	require.ElementsMatch(t, ids, exported)
+
+	output.Reset()
+	count, err = exportLaptops(laptopClient, &pb.Filter{MaxPriceUsd: 2000}, &output, exportJSON)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	var laptops []struct{ ID string }
+	require.NoError(t, json.Unmarshal(output.Bytes(), &laptops))
+	require.Len(t, laptops, 3)
+
+	output.Reset()
+	count, err = exportLaptops(laptopClient, &pb.Filter{MaxPriceUsd: 1}, &output, exportJSON)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	require.NoError(t, json.Unmarshal(output.Bytes(), &laptops))
+	require.Empty(t, laptops, "an empty export is an empty array")
+}
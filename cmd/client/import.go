@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"io"
+	"log"
+	"os"
+)
+
+// maxBatchSize is the largest batch of the batch create RPC.
+const maxBatchSize = 100
+
+// importedRow is a row of the CSV file sent in a batch.
+type importedRow struct {
+	line   int
+	laptop *pb.Laptop
+}
+
+// csvImport counts the rows of an import, and writes the rejected ones to its error report.
+type csvImport struct {
+	created  int
+	rejected int
+	report   *csv.Writer
+}
+
+func (imp *csvImport) reject(line int, err error) {
+	imp.rejected++
+	imp.report.Write([]string{fmt.Sprint(line), err.Error()})
+}
+
+// runImportCSV creates the laptops of the rows of a CSV file, see client.LaptopCSVReader for its columns,
+// with batch create requests. The rejected rows are written with their errors to the error report.
+func runImportCSV(laptopClient *client.LaptopClient, args []string) {
+	flags := flag.NewFlagSet("import-csv", flag.ExitOnError)
+	path := flags.String("file", "", "the CSV file of the laptops, whose header names the fields of the columns, e.g. brand,name,cpu.number_cores,ram,gpus[0].brand")
+	batchSize := flags.Int("batch-size", maxBatchSize, fmt.Sprintf("number of laptops of each batch request, at most %d", maxBatchSize))
+	reportPath := flags.String("errors", "", "write the lines and errors of the rejected rows to this CSV file (stderr if empty)")
+	flags.Parse(args)
+
+	if *path == "" {
+		log.Fatal("the CSV file is required, e.g. import-csv -file laptops.csv")
+	}
+	if *batchSize < 1 || *batchSize > maxBatchSize {
+		log.Fatalf("batch size must be between 1 and %d: %d", maxBatchSize, *batchSize)
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		log.Fatal("cannot open CSV file: ", err)
+	}
+	defer file.Close()
+	reader, err := client.NewLaptopCSVReader(file)
+	if err != nil {
+		log.Fatal("cannot read CSV file: ", err)
+	}
+
+	reportFile := os.Stderr
+	if *reportPath != "" {
+		reportFile, err = os.Create(*reportPath)
+		if err != nil {
+			log.Fatal("cannot create error report: ", err)
+		}
+		defer reportFile.Close()
+	}
+	imp := &csvImport{report: csv.NewWriter(reportFile)}
+	imp.report.Write([]string{"line", "error"})
+
+	var batch []importedRow
+	for {
+		laptop, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *client.CSVRowError
+		if errors.As(err, &rowErr) {
+			imp.reject(rowErr.Line, rowErr.Err)
+			continue
+		}
+		if err != nil {
+			log.Fatal("cannot read CSV file: ", err)
+		}
+
+		batch = append(batch, importedRow{line: reader.Line(), laptop: laptop})
+		if len(batch) == *batchSize {
+			imp.send(laptopClient, batch)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		imp.send(laptopClient, batch)
+	}
+
+	imp.report.Flush()
+	if err := imp.report.Error(); err != nil {
+		log.Fatal("cannot write error report: ", err)
+	}
+	log.Printf("imported %s: %d laptops created, %d rows rejected", *path, imp.created, imp.rejected)
+	if imp.rejected > 0 {
+		os.Exit(1)
+	}
+}
+
+// send creates the laptops of the batch, and reports the progress of the import.
+func (imp *csvImport) send(laptopClient *client.LaptopClient, batch []importedRow) {
+	laptops := make([]*pb.Laptop, len(batch))
+	for i, row := range batch {
+		laptops[i] = row.laptop
+	}
+
+	results, err := laptopClient.BatchCreateLaptops(context.Background(), laptops)
+	if err != nil {
+		log.Fatalf("cannot create laptops of lines %d to %d: %v", batch[0].line, batch[len(batch)-1].line, err)
+	}
+	for i, result := range results {
+		if result.GetError() != "" {
+			imp.reject(batch[i].line, errors.New(result.GetError()))
+			continue
+		}
+		imp.created++
+	}
+	log.Printf("processed %d rows: %d created, %d rejected", imp.created+imp.rejected, imp.created, imp.rejected)
+}
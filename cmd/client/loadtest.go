@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	loadCreate = "create"
+	loadSearch = "search"
+)
+
+// loadResult is the outcome of a call of the load test.
+type loadResult struct {
+	operation string
+	latency   time.Duration
+	code      codes.Code
+}
+
+// loadReport aggregates the results of the calls of an operation.
+type loadReport struct {
+	latencies []time.Duration
+	codes     map[codes.Code]int
+}
+
+// runLoadTest fires concurrent create and search calls at the server for the duration, or until the
+// number of calls is reached, and prints their throughput, latency percentiles and errors by status code.
+func runLoadTest(laptopClient *client.LaptopClient, args []string) {
+	flags := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	duration := flags.Duration("duration", 10*time.Second, "how long to send the calls")
+	requests := flags.Int("requests", 0, "stop after this number of calls (only the duration bounds the test if 0)")
+	concurrency := flags.Int("concurrency", 10, "number of calls in flight")
+	searchRatio := flags.Float64("search-ratio", 0.5, "ratio of the search calls, the others create sample laptops")
+	timeout := flags.Duration("timeout", 5*time.Second, "the timeout of each call")
+	flags.Parse(args)
+
+	if *concurrency < 1 {
+		log.Fatalf("concurrency must be positive: %d", *concurrency)
+	}
+	if *searchRatio < 0 || *searchRatio > 1 {
+		log.Fatalf("search ratio must be between 0 and 1: %v", *searchRatio)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	// The calls are taken from the channel, so that the number of calls is shared by the workers.
+	calls := make(chan string)
+	go func() {
+		defer close(calls)
+		for i := 0; *requests == 0 || i < *requests; i++ {
+			operation := loadCreate
+			if rand.Float64() < *searchRatio {
+				operation = loadSearch
+			}
+			select {
+			case calls <- operation:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan loadResult)
+	var workers sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for operation := range calls {
+				results <- loadCall(laptopClient, operation, *timeout)
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	log.Printf("load test of %v with %d concurrent calls", *duration, *concurrency)
+	start := time.Now()
+	reports := map[string]*loadReport{}
+	for result := range results {
+		report := reports[result.operation]
+		if report == nil {
+			report = &loadReport{codes: map[codes.Code]int{}}
+			reports[result.operation] = report
+		}
+		report.latencies = append(report.latencies, result.latency)
+		report.codes[result.code]++
+	}
+	printLoadReports(os.Stdout, reports, time.Since(start))
+}
+
+// loadCall makes a call of the operation, and returns its latency and status code.
+// A search is timed until all its laptops are received.
+func loadCall(laptopClient *client.LaptopClient, operation string, timeout time.Duration) loadResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	var err error
+	switch operation {
+	case loadCreate:
+		_, err = laptopClient.CreateLaptop(ctx, sample.NewLaptop())
+	case loadSearch:
+		var it *client.SearchIterator
+		it, err = laptopClient.Search(ctx, &pb.Filter{MaxPriceUsd: 3000, MinCpuCores: 4}, 0)
+		if err == nil {
+			for it.Next() {
+			}
+			it.Close()
+			err = it.Err()
+		}
+	}
+	return loadResult{operation: operation, latency: time.Since(start), code: status.Code(err)}
+}
+
+// printLoadReports prints the throughput, latency percentiles and status codes of each operation to out.
+func printLoadReports(out io.Writer, reports map[string]*loadReport, elapsed time.Duration) {
+	total := 0
+	for _, operation := range []string{loadCreate, loadSearch} {
+		report := reports[operation]
+		if report == nil {
+			continue
+		}
+		total += len(report.latencies)
+
+		sort.Slice(report.latencies, func(i, j int) bool { return report.latencies[i] < report.latencies[j] })
+		fmt.Fprintf(out, "%s: %d calls, %.1f calls/s\n", operation, len(report.latencies), float64(len(report.latencies))/elapsed.Seconds())
+		fmt.Fprintf(out, "  latency p50 %v, p90 %v, p99 %v, max %v\n",
+			percentile(report.latencies, 50),
+			percentile(report.latencies, 90),
+			percentile(report.latencies, 99),
+			report.latencies[len(report.latencies)-1].Round(time.Microsecond),
+		)
+
+		statusCodes := make([]codes.Code, 0, len(report.codes))
+		for code := range report.codes {
+			statusCodes = append(statusCodes, code)
+		}
+		sort.Slice(statusCodes, func(i, j int) bool { return statusCodes[i] < statusCodes[j] })
+		for _, code := range statusCodes {
+			fmt.Fprintf(out, "  %s: %d\n", code, report.codes[code])
+		}
+	}
+	fmt.Fprintf(out, "total: %d calls in %v, %.1f calls/s\n", total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+}
+
+// percentile returns the p-th percentile of the sorted latencies, by the nearest-rank method.
+func percentile(latencies []time.Duration, p int) time.Duration {
+	rank := (p*len(latencies) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return latencies[rank-1].Round(time.Microsecond)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/pb"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	require.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	require.Equal(t, 99*time.Millisecond, percentile(latencies, 99))
+	require.Equal(t, 100*time.Millisecond, percentile(latencies, 100))
+	require.Equal(t, time.Millisecond, percentile(latencies, 0))
+	require.Equal(t, 3*time.Millisecond, percentile([]time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, 90))
+}
+
+func TestLoadCall(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	laptopClient := startTestServer(t, laptopStore)
+
+	result := loadCall(laptopClient, loadCreate, time.Second)
+	require.Equal(t, loadCreate, result.operation)
+	require.Equal(t, codes.OK, result.code)
+	require.Positive(t, result.latency)
+	count, err := laptopStore.Count(context.Background(), &pb.Filter{MaxPriceUsd: 1e6})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+
+	result = loadCall(laptopClient, loadSearch, time.Second)
+	require.Equal(t, loadSearch, result.operation)
+	require.Equal(t, codes.OK, result.code)
+}
+
+func TestPrintLoadReports(t *testing.T) {
+	t.Parallel()
+
+	reports := map[string]*loadReport{
+		loadCreate: {
+			latencies: []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond},
+			codes:     map[codes.Code]int{codes.OK: 2, codes.Unavailable: 1},
+		},
+	}
+	var output bytes.Buffer
+	printLoadReports(&output, reports, time.Second)
+	require.Equal(t, `create: 3 calls, 3.0 calls/s
+  latency p50 2ms, p90 3ms, p99 3ms, max 3ms
+  OK: 2
+  Unavailable: 1
+total: 3 calls in 1s, 3.0 calls/s
+`, output.String())
+}
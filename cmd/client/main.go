@@ -1,25 +1,43 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"grpc_app/client"
 	"grpc_app/pb"
 	"grpc_app/sample"
+	"grpc_app/tracing"
 	"log"
+	"os"
 	"strings"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// createLaptop creates the laptop, and exits if it cannot be created.
+func createLaptop(laptopClient *client.LaptopClient, laptop *pb.Laptop) {
+	id, err := laptopClient.CreateLaptop(context.Background(), laptop)
+	if status.Code(err) == codes.AlreadyExists {
+		log.Print("laptop already exist")
+		return
+	}
+	if err != nil {
+		log.Fatal("cannot create laptop: ", err)
+	}
+	log.Printf("created laptop with id: %s", id)
+}
+
 func testCreateLaptop(laptopClient *client.LaptopClient) {
-	laptopClient.CreateLaptop(sample.NewLaptop())
+	createLaptop(laptopClient, sample.NewLaptop())
 }
 
 func testSearchLaptop(laptopClient *client.LaptopClient) {
 	for i := 0; i < 10; i++ {
-		laptopClient.CreateLaptop(sample.NewLaptop())
+		createLaptop(laptopClient, sample.NewLaptop())
 	}
 
 	filter := &pb.Filter{
@@ -34,7 +52,7 @@ func testSearchLaptop(laptopClient *client.LaptopClient) {
 
 func testUploadImage(laptopClient *client.LaptopClient) {
 	laptop := sample.NewLaptop()
-	laptopClient.CreateLaptop(laptop)
+	createLaptop(laptopClient, laptop)
 	laptopClient.UploadImage(laptop.GetId(), "tmp/laptop.jpg")
 }
 
@@ -45,7 +63,7 @@ func testRateLaptop(laptopClient *client.LaptopClient) {
 	for i := 0; i < n; i++ {
 		laptop := sample.NewLaptop()
 		laptopIDs[i] = laptop.GetId()
-		laptopClient.CreateLaptop(laptop)
+		createLaptop(laptopClient, laptop)
 	}
 
 	scores := make([]float64, n)
@@ -68,46 +86,277 @@ func testRateLaptop(laptopClient *client.LaptopClient) {
 	}
 }
 
-const (
-	username        = "user1"
-	password        = "secret"
-	refreshDuration = 30 * time.Second
-)
+const refreshBefore = 30 * time.Second
 
 func AuthMethods() map[string]bool {
 	const laptopServicePath = "/grpc_app.proto.LaptopService/"
 	return map[string]bool{
-		laptopServicePath + "CreateLaptop": true,
-		laptopServicePath + "UploadImage":  true,
-		laptopServicePath + "RateLaptop":   true,
+		laptopServicePath + "CreateLaptop":       true,
+		laptopServicePath + "BatchCreateLaptops": true,
+		laptopServicePath + "ImportLaptops":      true,
+		laptopServicePath + "UpdateLaptop":       true,
+		laptopServicePath + "DeleteLaptop":       true,
+		laptopServicePath + "RestoreLaptop":      true,
+		laptopServicePath + "UploadImage":        true,
+		laptopServicePath + "RateLaptop":         true,
+		laptopServicePath + "AcquireHold":        true,
+		laptopServicePath + "ReleaseHold":        true,
 	}
 }
 
-func main() {
-	serverAddress := flag.String("address", "", "the server address")
-	flag.Parse()
-	log.Printf("dial server %s", *serverAddress)
+// dialAuthenticated dials the server with a connection whose calls are authenticated with the API key,
+// or with the access token of the user if there is no API key.
+func dialAuthenticated(
+	serverAddress string,
+	dialOptions []client.Option,
+	apiKey string,
+	username string,
+	password string,
+) (*grpc.ClientConn, error) {
+	if apiKey != "" {
+		cc, err := client.Dial(serverAddress, append(dialOptions, client.WithAPIKey(apiKey))...)
+		if err != nil {
+			return nil, fmt.Errorf("cannot dial server: %w", err)
+		}
+		return cc, nil
+	}
 
-	cc1, err := grpc.Dial(*serverAddress, grpc.WithInsecure())
+	cc1, err := client.Dial(serverAddress, dialOptions...)
 	if err != nil {
-		log.Fatal("cannot dial server: ", err)
+		return nil, fmt.Errorf("cannot dial server: %w", err)
 	}
 	authClient := client.NewAuthClient(cc1, username, password)
-	interceptor, err := client.NewAuthInterceptor(authClient, AuthMethods(), refreshDuration)
+	interceptor, err := client.NewAuthInterceptor(authClient, AuthMethods(), refreshBefore)
 	if err != nil {
-		log.Fatal("cannot create auth interceptor: ", err)
+		return nil, fmt.Errorf("cannot create auth interceptor: %w", err)
 	}
 
-	cc2, err := grpc.Dial(
-		*serverAddress,
-		grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(interceptor.Unary()),
-		grpc.WithStreamInterceptor(interceptor.Stream()),
+	cc2, err := client.Dial(
+		serverAddress,
+		append(dialOptions, client.WithGRPCOptions(
+			grpc.WithUnaryInterceptor(interceptor.Unary()),
+			grpc.WithStreamInterceptor(interceptor.Stream()),
+		))...,
 	)
 	if err != nil {
-		log.Fatal("cannot dial server: ", err)
+		return nil, fmt.Errorf("cannot dial server: %w", err)
 	}
+	return cc2, nil
+}
 
-	laptopClient := client.NewLaptopClient(cc2)
-	testRateLaptop(laptopClient)
+// commands are the subcommands of the client, with their descriptions shown in the usage.
+var commands = []struct {
+	name        string
+	description string
+}{
+	{"create", "create sample laptops"},
+	{"get", "print a laptop by its ID"},
+	{"update", "update the fields of a laptop"},
+	{"delete", "delete a laptop"},
+	{"restore", "restore a deleted laptop"},
+	{"search", "search the laptops matching a filter"},
+	{"browse", "browse, inspect and edit the laptops interactively"},
+	{"watch", "print the changes of the laptops matching a filter as they happen"},
+	{"import-csv", "create the laptops of a CSV file"},
+	{"export", "export the laptops to a JSON or NDJSON file"},
+	{"count", "count the laptops matching a filter"},
+	{"stats", "print the statistics of the laptops matching a filter"},
+	{"list", "list a page of laptops, or all of them"},
+	{"recommend", "recommend laptops for a workload, a budget or like a laptop"},
+	{"trending", "print the most viewed laptops"},
+	{"loadtest", "send concurrent create and search calls and report their latencies"},
+	{"ping", "check that the server is up"},
+	{"upload-image", "upload an image file of a laptop in chunks"},
+	{"upload", "upload the image of a new laptop"},
+	{"rate", "rate new laptops (the default command)"},
+}
+
+// usage prints the flags and the subcommands of the client.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] <command> [command flags]\n\nCommands:\n", os.Args[0])
+	for _, command := range commands {
+		fmt.Fprintf(out, "  %-12s %s\n", command.name, command.description)
+	}
+	fmt.Fprint(out, "\nRun a command with -h for its flags.\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// commandNames returns the comma-separated names of the subcommands.
+func commandNames() string {
+	names := make([]string, len(commands))
+	for i, command := range commands {
+		names[i] = command.name
+	}
+	return strings.Join(names, ", ")
+}
+
+func main() {
+	serverAddress := flag.String("address", "", "the server address, comma-separated addresses to balance the calls across several servers, or a dns:/// target")
+	enableTLS := flag.Bool("tls", false, "enable TLS")
+	caFile := flag.String("ca-file", "", "PEM bundle of trusted CA certificates (system pool if empty)")
+	serverName := flag.String("server-name", "", "override the server name used to verify its certificate")
+	tlsCert := flag.String("tls-cert", "", "the PEM certificate of the client, for servers that require mutual TLS")
+	tlsKey := flag.String("tls-key", "", "the PEM private key of the client certificate")
+	spkiPins := flag.String("spki-pins", "", "comma-separated base64 SHA-256 SPKI pins of the server certificate chain")
+	output := flag.String("output", outputTable, "output format: json, yaml or table")
+	columns := flag.String("columns", defaultColumns, "comma-separated columns of the table output")
+	compressor := flag.String("compress", "", "compress calls with the named compressor: gzip or zstd")
+	maxRecvMsgSize := flag.Int("max-recv-msg-size", 0, "the largest message in bytes the client may receive (4 MiB if 0)")
+	maxSendMsgSize := flag.Int("max-send-msg-size", 0, "the largest message in bytes the client may send (unlimited if 0)")
+	trace := flag.Bool("trace", false, "log a span for every call")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "send the spans of the calls to the OpenTelemetry collector at this OTLP/HTTP endpoint")
+	rateLimit := flag.Float64("rate-limit", 0, "maximum number of calls per second (unlimited if 0)")
+	rateBurst := flag.Int("rate-burst", 1, "maximum burst of calls above the rate limit")
+	keepaliveTime := flag.Duration("keepalive-time", 0, "ping the server when the connection is idle for this long, at least 10s (no pings if 0)")
+	keepaliveTimeout := flag.Duration("keepalive-timeout", 0, "close the connection if a ping is not acked within this timeout (20s if 0)")
+	keepalivePermitWithoutStream := flag.Bool("keepalive-permit-without-stream", false, "ping the server even without active stream, if its keepalive policy allows it")
+	maxAttempts := flag.Int("max-attempts", client.DefaultRetryPolicy().MaxAttempts, "maximum number of attempts of the idempotent calls failing with unavailable or deadline exceeded (no retry if 1)")
+	waitForReady := flag.Duration("wait-for-ready", 0, "wait up to this long for the server to be ready")
+	tenant := flag.String("tenant", "", "the tenant to target (the tenant of the user if empty)")
+	language := flag.String("language", "", "the preferred languages of the error messages, e.g. fr-CH, fr;q=0.9")
+	username := flag.String("username", "user1", "the username to login with")
+	password := flag.String("password", "secret", "the password to login with")
+	apiKey := flag.String("api-key", os.Getenv("LAPTOP_API_KEY"), "authenticate with this API key instead of logging in")
+	configPath := flag.String("config", defaultConfigPath(), "the CLI config file")
+	profileName := flag.String("profile", "", "the config profile to use (default profile of the config file if empty)")
+	flag.Usage = usage
+	flag.Parse()
+
+	profile, err := loadProfile(*configPath, *profileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if profile != nil {
+		err = applyProfile(flag.CommandLine, profile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	printer, err := newLaptopPrinter(os.Stdout, *output, *columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("dial server %s, TLS = %t", *serverAddress, *enableTLS)
+
+	// The retries are always made by WithRetry, which also disables those of the service config.
+	retryPolicy := client.DefaultRetryPolicy()
+	retryPolicy.MaxAttempts = *maxAttempts
+	dialOptions := []client.Option{
+		client.WithDefaultTimeouts(client.DefaultTimeout, client.DefaultMethodTimeouts()),
+		client.WithRetry(retryPolicy),
+	}
+	if *tenant != "" {
+		dialOptions = append(dialOptions, client.WithTenant(*tenant))
+	}
+	if *language != "" {
+		dialOptions = append(dialOptions, client.WithLanguage(*language))
+	}
+	if *maxRecvMsgSize > 0 || *maxSendMsgSize > 0 {
+		dialOptions = append(dialOptions, client.WithMaxMessageSize(*maxRecvMsgSize, *maxSendMsgSize))
+	}
+	if *keepaliveTime > 0 {
+		dialOptions = append(dialOptions, client.WithKeepalive(*keepaliveTime, *keepaliveTimeout, *keepalivePermitWithoutStream))
+	}
+	if *waitForReady > 0 {
+		dialOptions = append(dialOptions, client.WithWaitForReady(*waitForReady))
+	}
+	if *rateLimit > 0 {
+		limiter := client.NewRateLimiter(*rateLimit, *rateBurst)
+		dialOptions = append(dialOptions, client.WithRateLimit(limiter, true))
+	}
+	var spanExporter *tracing.OTLPExporter
+	switch {
+	case *otlpEndpoint != "":
+		spanExporter = tracing.NewOTLPExporter(*otlpEndpoint, "grpc_app-client")
+		dialOptions = append(dialOptions, client.WithInstrumentation(tracing.NewTracer(spanExporter), nil))
+	case *trace:
+		dialOptions = append(dialOptions, client.WithInstrumentation(tracing.NewTracer(tracing.LogExporter), nil))
+	}
+	var compressionStats *client.CompressionStats
+	if *compressor != "" {
+		compressionStats = client.NewCompressionStats()
+		dialOptions = append(dialOptions, client.WithCompression(*compressor), client.WithCompressionStats(compressionStats))
+	}
+	if *enableTLS {
+		tlsConfig := client.TLSConfig{
+			CAFile:     *caFile,
+			ServerName: *serverName,
+			CertFile:   *tlsCert,
+			KeyFile:    *tlsKey,
+		}
+		if *spkiPins != "" {
+			tlsConfig.SPKIPins = strings.Split(*spkiPins, ",")
+		}
+		dialOptions = append(dialOptions, client.WithTLS(tlsConfig))
+	}
+
+	cc, err := dialAuthenticated(*serverAddress, dialOptions, *apiKey, *username, *password)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	laptopClient := client.NewLaptopClient(cc)
+
+	switch command := flag.Arg(0); command {
+	case "create":
+		runCreate(laptopClient, printer, flag.Args()[1:])
+	case "get":
+		runGet(laptopClient, printer, flag.Args()[1:])
+	case "update":
+		runUpdate(laptopClient, printer, flag.Args()[1:])
+	case "delete":
+		runDelete(laptopClient, flag.Args()[1:])
+	case "restore":
+		runRestore(laptopClient, printer, flag.Args()[1:])
+	case "search":
+		runSearch(laptopClient, printer, flag.Args()[1:])
+	case "browse":
+		runBrowse(laptopClient, flag.Args()[1:])
+	case "watch":
+		runWatch(laptopClient, printer, flag.Args()[1:])
+	case "import-csv":
+		runImportCSV(laptopClient, flag.Args()[1:])
+	case "export":
+		runExport(laptopClient, flag.Args()[1:])
+	case "count":
+		runCount(laptopClient, flag.Args()[1:])
+	case "stats":
+		runStats(laptopClient, flag.Args()[1:])
+	case "list":
+		runList(laptopClient, printer, flag.Args()[1:])
+	case "recommend":
+		runRecommend(laptopClient, printer, flag.Args()[1:])
+	case "trending":
+		runTrending(laptopClient, printer, flag.Args()[1:])
+	case "loadtest":
+		runLoadTest(laptopClient, flag.Args()[1:])
+	case "ping":
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx, cc); err != nil {
+			log.Fatal(err)
+		}
+		log.Print("server is up")
+	case "upload-image":
+		runUploadImage(laptopClient, flag.Args()[1:])
+	case "upload":
+		testUploadImage(laptopClient)
+	case "", "rate":
+		testRateLaptop(laptopClient)
+	default:
+		log.Fatalf("unknown command %q, must be one of %s", command, commandNames())
+	}
+
+	if compressionStats != nil {
+		log.Printf("message sizes:\n%s", compressionStats.Report())
+	}
+	if spanExporter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := spanExporter.Flush(ctx); err != nil {
+			log.Printf("cannot export spans: %v", err)
+		}
+	}
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the laptop printer.
+const (
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputTable = "table"
+)
+
+const defaultColumns = "id,brand,name,cpu,cores,ram,price"
+
+// tableColumns maps a column name to the function extracting its value.
+var tableColumns = map[string]func(laptop *pb.Laptop) string{
+	"id":    func(laptop *pb.Laptop) string { return laptop.GetId() },
+	"brand": func(laptop *pb.Laptop) string { return laptop.GetBrand() },
+	"name":  func(laptop *pb.Laptop) string { return laptop.GetName() },
+	"cpu":   func(laptop *pb.Laptop) string { return laptop.GetCpu().GetName() },
+	"cores": func(laptop *pb.Laptop) string { return fmt.Sprint(laptop.GetCpu().GetNumberCores()) },
+	"ghz": func(laptop *pb.Laptop) string {
+		return fmt.Sprintf("%.2f-%.2f", laptop.GetCpu().GetMinGhz(), laptop.GetCpu().GetMaxGhz())
+	},
+	"ram": func(laptop *pb.Laptop) string {
+		return fmt.Sprintf("%d %s", laptop.GetRam().GetValue(), laptop.GetRam().GetUnit())
+	},
+	"gpus":  func(laptop *pb.Laptop) string { return fmt.Sprint(len(laptop.GetGpus())) },
+	"price": func(laptop *pb.Laptop) string { return fmt.Sprintf("%.2f", laptop.GetPriceUsd()) },
+	"year":  func(laptop *pb.Laptop) string { return fmt.Sprint(laptop.GetReleaseYear()) },
+	"weight": func(laptop *pb.Laptop) string {
+		if laptop.GetWeightLb() > 0 {
+			return fmt.Sprintf("%.2f lb", laptop.GetWeightLb())
+		}
+		return fmt.Sprintf("%.2f kg", laptop.GetWeightKg())
+	},
+}
+
+// laptopPrinter prints laptops in the selected output format.
+type laptopPrinter struct {
+	writer  io.Writer
+	format  string
+	columns []string
+	table   *tabwriter.Writer
+	count   int
+}
+
+func newLaptopPrinter(writer io.Writer, format string, columns string) (*laptopPrinter, error) {
+	printer := &laptopPrinter{writer: writer, format: format}
+
+	switch format {
+	case outputJSON, outputYAML:
+	case outputTable:
+		for _, column := range strings.Split(columns, ",") {
+			column = strings.TrimSpace(column)
+			if tableColumns[column] == nil {
+				return nil, fmt.Errorf("unknown column %q", column)
+			}
+			printer.columns = append(printer.columns, column)
+		}
+		printer.table = tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	default:
+		return nil, fmt.Errorf("unknown output format %q, must be one of json, yaml, table", format)
+	}
+
+	return printer, nil
+}
+
+// Print prints one laptop.
+func (printer *laptopPrinter) Print(laptop *pb.Laptop) error {
+	defer func() { printer.count++ }()
+
+	switch printer.format {
+	case outputJSON:
+		data, err := stableJSON(laptop)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(printer.writer, "%s\n", data)
+		return err
+
+	case outputYAML:
+		data, err := stableJSON(laptop)
+		if err != nil {
+			return err
+		}
+
+		var value interface{}
+		err = json.Unmarshal(data, &value)
+		if err != nil {
+			return err
+		}
+
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop to YAML: %w", err)
+		}
+		_, err = fmt.Fprintf(printer.writer, "---\n%s", out)
+		return err
+
+	default:
+		if printer.count == 0 {
+			header := make([]string, len(printer.columns))
+			for i, column := range printer.columns {
+				header[i] = strings.ToUpper(column)
+			}
+			fmt.Fprintln(printer.table, strings.Join(header, "\t"))
+		}
+
+		values := make([]string, len(printer.columns))
+		for i, column := range printer.columns {
+			values[i] = tableColumns[column](laptop)
+		}
+		_, err := fmt.Fprintln(printer.table, strings.Join(values, "\t"))
+		return err
+	}
+}
+
+// PrintEvent prints the change of a laptop at the time, and flushes it right away so that the changes
+// can be tailed. The JSON and YAML outputs wrap the laptop with the time and type of the change,
+// the table output prefixes its columns with them.
+func (printer *laptopPrinter) PrintEvent(eventType string, at time.Time, laptop *pb.Laptop) error {
+	defer func() { printer.count++ }()
+
+	switch printer.format {
+	case outputJSON:
+		data, err := stableJSON(laptop)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(printer.writer, "{\"time\":%q,\"type\":%q,\"laptop\":%s}\n", at.Format(time.RFC3339), eventType, data)
+		return err
+
+	case outputYAML:
+		data, err := stableJSON(laptop)
+		if err != nil {
+			return err
+		}
+
+		var value interface{}
+		err = json.Unmarshal(data, &value)
+		if err != nil {
+			return err
+		}
+
+		out, err := yaml.Marshal(map[string]interface{}{"time": at.Format(time.RFC3339), "type": eventType, "laptop": value})
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop to YAML: %w", err)
+		}
+		_, err = fmt.Fprintf(printer.writer, "---\n%s", out)
+		return err
+
+	default:
+		if printer.count == 0 {
+			header := []string{"TIME", "EVENT"}
+			for _, column := range printer.columns {
+				header = append(header, strings.ToUpper(column))
+			}
+			fmt.Fprintln(printer.table, strings.Join(header, "\t"))
+		}
+
+		values := []string{at.Format("15:04:05"), eventType}
+		for _, column := range printer.columns {
+			values = append(values, tableColumns[column](laptop))
+		}
+		fmt.Fprintln(printer.table, strings.Join(values, "\t"))
+		return printer.table.Flush()
+	}
+}
+
+// Flush writes out any buffered output.
+func (printer *laptopPrinter) Flush() error {
+	if printer.table != nil {
+		return printer.table.Flush()
+	}
+	return nil
+}
+
+// stableJSON marshals the laptop to a single line of JSON.
+// protojson output is deliberately unstable, so it's compacted with encoding/json.
+func stableJSON(laptop *pb.Laptop) ([]byte, error) {
+	marshaler := protojson.MarshalOptions{UseProtoNames: true}
+	data, err := marshaler.Marshal(laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal laptop to JSON: %w", err)
+	}
+
+	out := bytes.Buffer{}
+	err = json.Compact(&out, data)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
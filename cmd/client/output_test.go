@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLaptopPrinter(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.Brand = "Apple"
+	laptop.PriceUsd = 1999.5
+
+	var output bytes.Buffer
+	printer, err := newLaptopPrinter(&output, outputJSON, defaultColumns)
+	require.NoError(t, err)
+	require.NoError(t, printer.Print(laptop))
+	require.NoError(t, printer.Print(laptop))
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	require.Len(t, lines, 2, "one laptop per line")
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &decoded))
+	require.Equal(t, laptop.GetId(), decoded["id"])
+	require.Equal(t, "Apple", decoded["brand"])
+
+	output.Reset()
+	printer, err = newLaptopPrinter(&output, outputYAML, defaultColumns)
+	require.NoError(t, err)
+	require.NoError(t, printer.Print(laptop))
+	require.True(t, strings.HasPrefix(output.String(), "---\n"))
+	require.NoError(t, yaml.Unmarshal(output.Bytes(), &decoded))
+	require.Equal(t, laptop.GetId(), decoded["id"])
+
+	output.Reset()
+	printer, err = newLaptopPrinter(&output, outputTable, "id, brand,price")
+	require.NoError(t, err)
+	require.NoError(t, printer.Print(laptop))
+	require.NoError(t, printer.Flush())
+	lines = strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, []string{"ID", "BRAND", "PRICE"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{laptop.GetId(), "Apple", "1999.50"}, strings.Fields(lines[1]))
+
+	_, err = newLaptopPrinter(&output, outputTable, "id,colour")
+	require.EqualError(t, err, `unknown column "colour"`)
+	_, err = newLaptopPrinter(&output, "xml", defaultColumns)
+	require.Error(t, err)
+}
+
+func TestLaptopPrinterEvents(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	at := time.Date(2022, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	var output bytes.Buffer
+	printer, err := newLaptopPrinter(&output, outputJSON, defaultColumns)
+	require.NoError(t, err)
+	require.NoError(t, printer.PrintEvent("CREATED", at, laptop))
+	var event struct {
+		Time   string
+		Type   string
+		Laptop *json.RawMessage
+	}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &event))
+	require.Equal(t, "2022-05-01T10:30:00Z", event.Time)
+	require.Equal(t, "CREATED", event.Type)
+	require.NotNil(t, event.Laptop)
+
+	output.Reset()
+	printer, err = newLaptopPrinter(&output, outputTable, "id")
+	require.NoError(t, err)
+	require.NoError(t, printer.PrintEvent("DELETED", at, &pb.Laptop{Id: "laptop-1"}))
+	require.Equal(t, "TIME      EVENT    ID\n10:30:00  DELETED  laptop-1\n", output.String(), "the events are flushed at once")
+}
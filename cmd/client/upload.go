@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"grpc_app/client"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// progressBarWidth is the number of characters of the progress bar of the uploads.
+const progressBarWidth = 30
+
+// runUploadImage streams an image file of a laptop in chunks, with a progress bar on stderr.
+// The upload is started again after transient failures, as the server doesn't resume partial uploads.
+func runUploadImage(laptopClient *client.LaptopClient, args []string) {
+	flags := flag.NewFlagSet("upload-image", flag.ExitOnError)
+	laptopID := flags.String("laptop-id", "", "the ID of the laptop of the image")
+	path := flags.String("file", "", "the image file to upload")
+	chunkSize := flags.Int("chunk-size", 64*1024, "the size in bytes of the chunks of the upload")
+	maxAttempts := flags.Int("max-attempts", 3, "maximum number of attempts of the upload failing with unavailable or deadline exceeded")
+	timeout := flags.Duration("timeout", 30*time.Second, "the timeout of each attempt")
+	flags.Parse(args)
+
+	if *laptopID == "" || *path == "" {
+		log.Fatal("the laptop ID and the image file are required, e.g. upload-image -laptop-id <id> -file laptop.jpg")
+	}
+	if *chunkSize < 1 {
+		log.Fatalf("chunk size must be positive: %d", *chunkSize)
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		res, err := laptopClient.UploadImageFile(ctx, *laptopID, *path, *chunkSize, printProgress)
+		cancel()
+		fmt.Fprintln(os.Stderr)
+		if err == nil {
+			log.Printf("image uploaded with id: %s, size: %d", res.GetId(), res.GetSize())
+			return
+		}
+
+		code := status.Code(err)
+		if attempt >= *maxAttempts || (code != codes.Unavailable && code != codes.DeadlineExceeded) {
+			log.Fatal("cannot upload image: ", err)
+		}
+		log.Printf("upload again in %v after attempt %d failed: %v", backoff, attempt, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// printProgress draws the progress bar of an upload on stderr, e.g. [#######-------]  50% 512/1024 KiB.
+func printProgress(sent int64, total int64) {
+	ratio := 1.0
+	if total > 0 {
+		ratio = float64(sent) / float64(total)
+	}
+	done := int(ratio * progressBarWidth)
+	if done > progressBarWidth {
+		done = progressBarWidth
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %3.0f%% %d/%d KiB",
+		strings.Repeat("#", done),
+		strings.Repeat("-", progressBarWidth-done),
+		ratio*100,
+		(sent+1023)/1024,
+		(total+1023)/1024,
+	)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxWatchBackoff is the longest wait before watching again after the stream is lost.
+const maxWatchBackoff = 30 * time.Second
+
+// runWatch prints the changes of the laptops matching the filter flags as they happen, until interrupted.
+// The stream is watched again if the server becomes unavailable or the stream times out, e.g. on a proxy
+// with a stream timeout, resuming after the last change printed so that the changes in between are printed
+// too. With -resume-file, the resume token is kept in the file, so that the next watch resumes as well.
+func runWatch(laptopClient *client.LaptopClient, printer *laptopPrinter, args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	filter := filterFlags(flags)
+	reconnect := flags.Bool("reconnect", true, "watch again when the server becomes unavailable or the stream times out")
+	resumeFile := flags.String("resume-file", "", "resume after the change of the token in this file, and keep the token of the last change in it")
+	flags.Parse(args)
+
+	resumeToken := ""
+	if *resumeFile != "" {
+		data, err := os.ReadFile(*resumeFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Fatal("cannot read resume token: ", err)
+		}
+		resumeToken = strings.TrimSpace(string(data))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	backoff := time.Second
+	for {
+		log.Print("watching the laptop changes")
+		err := laptopClient.WatchLaptops(ctx, filter(), resumeToken, func(res *pb.WatchLaptopsResponse) error {
+			backoff = time.Second
+			eventType := strings.ToLower(res.GetType().String())
+			err := printer.PrintEvent(eventType, time.Now(), res.GetLaptop())
+			if err != nil {
+				return err
+			}
+
+			resumeToken = res.GetResumeToken()
+			if *resumeFile != "" {
+				return os.WriteFile(*resumeFile, []byte(resumeToken+"\n"), 0o644)
+			}
+			return nil
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			log.Print("the server ended the watch")
+			return
+		}
+		if resumeToken != "" && status.Code(err) == codes.OutOfRange {
+			log.Print("the changes since the resume token are no longer kept, watch the changes from now on: ", err)
+			resumeToken = ""
+			continue
+		}
+		if !*reconnect || !reconnectable(err) {
+			log.Fatal("cannot watch laptops: ", err)
+		}
+
+		log.Printf("watch again in %v: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxWatchBackoff {
+			backoff = maxWatchBackoff
+		}
+	}
+}
+
+// reconnectable reports whether the watch may be started again after the error ending it.
+func reconnectable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,32 +1,141 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"flag"
 	"fmt"
+	// compression registers zstd, for the same reason as gzip.
+	_ "grpc_app/compression"
+	"grpc_app/config"
+	"grpc_app/gateway"
+	"grpc_app/grpcweb"
+	"grpc_app/logging"
+	"grpc_app/metrics"
+	"grpc_app/migration"
 	"grpc_app/pb"
+	pbv2 "grpc_app/pb/v2"
+	"grpc_app/profiling"
 	"grpc_app/service"
+	"grpc_app/tracing"
+	"io"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/credentials/insecure"
+	// gzip is registered so the clients may compress their calls, and get their responses compressed the same way.
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
+// envPrefix is the prefix of the environment variables of the settings, e.g. LAPTOP_PORT for -port.
+const envPrefix = "LAPTOP_"
+
 const (
-	secretKey     = "secret"
-	tokenDuration = 15 * time.Minute
+	viewFlushInterval = 10 * time.Second
+	purgeInterval     = time.Hour
+	retentionInterval = time.Hour
+	sweepInterval     = time.Minute
+	healthInterval    = 10 * time.Second
+	healthTimeout     = 2 * time.Second
+	spanFlushInterval = 5 * time.Second
+
+	// inProcessBufferSize is the size of the in-memory connection buffers of the HTTP gateway.
+	inProcessBufferSize = 1 << 20
 )
 
+// reloadableFlags are the settings applied to the running server when its config is reloaded,
+// on SIGHUP or when the config file changes. The other settings take effect on restart only.
+var reloadableFlags = []string{
+	"log-level",
+	"request-log",
+	"default-timeout",
+	"default-stream-timeout",
+	"rate-limit",
+	"rate-burst",
+	"caller-rate-limit",
+	"caller-rate-burst",
+	"method-rate-limits",
+}
+
 func accessibleRoles() map[string][]string {
 	const laptopServicePath = "/grpc_app.proto.LaptopService/"
+	const laptopServiceV2Path = "/grpc_app.proto.v2.LaptopService/"
+	const adminServicePath = "/grpc_app.proto.AdminService/"
+	// The channelz service reveals the addresses of the peers, so it's reserved to the admins.
+	const channelzServicePath = "/grpc.channelz.v1.Channelz/"
 	return map[string][]string{
-		laptopServicePath + "CreateLaptop": {"admin"},
-		laptopServicePath + "UploadImage":  {"admin"},
-		laptopServicePath + "RateLaptop":   {"admin", "user"},
+		laptopServicePath + "CreateLaptop":         {"admin"},
+		laptopServicePath + "BatchCreateLaptops":   {"admin"},
+		laptopServicePath + "ImportLaptops":        {"admin"},
+		laptopServicePath + "UpdateLaptop":         {"admin"},
+		laptopServicePath + "DeleteLaptop":         {"admin"},
+		laptopServicePath + "RestoreLaptop":        {"admin"},
+		laptopServicePath + "UploadImage":          {"admin"},
+		laptopServicePath + "RateLaptop":           {"admin", "user"},
+		laptopServicePath + "AcquireHold":          {"admin", "user"},
+		laptopServicePath + "ReleaseHold":          {"admin", "user"},
+		laptopServiceV2Path + "CreateLaptop":       {"admin"},
+		laptopServiceV2Path + "BatchCreateLaptops": {"admin"},
+		laptopServiceV2Path + "ImportLaptops":      {"admin"},
+		laptopServiceV2Path + "UpdateLaptop":       {"admin"},
+		laptopServiceV2Path + "DeleteLaptop":       {"admin"},
+		laptopServiceV2Path + "RestoreLaptop":      {"admin"},
+		laptopServiceV2Path + "UploadImage":        {"admin"},
+		laptopServiceV2Path + "RateLaptop":         {"admin", "user"},
+		laptopServiceV2Path + "AcquireHold":        {"admin", "user"},
+		laptopServiceV2Path + "ReleaseHold":        {"admin", "user"},
+		adminServicePath + "EraseUserData":         {"admin"},
+		adminServicePath + "GetSchemaVersion":      {"admin"},
+		adminServicePath + "PurgeDeletedLaptops":   {"admin"},
+		adminServicePath + "ReindexLaptops":        {"admin"},
+		adminServicePath + "GetServerStats":        {"admin"},
+		adminServicePath + "ListAuditEntries":      {"admin"},
+		adminServicePath + "CreateWebhook":         {"admin"},
+		adminServicePath + "ListWebhooks":          {"admin"},
+		adminServicePath + "DeleteWebhook":         {"admin"},
+		adminServicePath + "SetLogLevel":           {"admin"},
+		channelzServicePath + "GetTopChannels":     {"admin"},
+		channelzServicePath + "GetServers":         {"admin"},
+		channelzServicePath + "GetServer":          {"admin"},
+		channelzServicePath + "GetServerSockets":   {"admin"},
+		channelzServicePath + "GetChannel":         {"admin"},
+		channelzServicePath + "GetSubchannel":      {"admin"},
+		channelzServicePath + "GetSocket":          {"admin"},
 	}
 }
 
+// overrideRoles replaces the roles of the methods of the roles file, and opens the methods
+// the file gives no roles to everyone.
+func overrideRoles(roles map[string][]string, rolesPath string) error {
+	fileRoles, err := service.ReadAccessibleRoles(rolesPath)
+	if err != nil {
+		return err
+	}
+	for method, methodRoles := range fileRoles {
+		if len(methodRoles) == 0 {
+			delete(roles, method)
+			continue
+		}
+		roles[method] = methodRoles
+	}
+	logging.Default().Info("read roles", "methods", len(fileRoles), "path", rolesPath)
+	return nil
+}
+
 // In order to test the new login API
 func seedUsers(userStore service.UserStore) error {
 	err := createUser(userStore, "admin1", "secret", "admin")
@@ -44,35 +153,856 @@ func createUser(userStore service.UserStore, username, password, role string) er
 	return userStore.Save(user)
 }
 
+// loadAPIKeys returns a store of the API keys of the file.
+func loadAPIKeys(apiKeysPath string) (service.APIKeyStore, error) {
+	keys, err := service.ReadAPIKeys(apiKeysPath)
+	if err != nil {
+		return nil, err
+	}
+
+	store := service.NewInMemoryAPIKeyStore()
+	for _, key := range keys {
+		err := store.Save(key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot save API key %s: %w", key.Name, err)
+		}
+	}
+	logging.Default().Info("read API keys", "keys", len(keys), "path", apiKeysPath)
+	return store, nil
+}
+
+// loadWebhooks returns a webhook store of the webhooks of the file, or an empty one if the path is empty.
+func loadWebhooks(webhooksPath string) (service.WebhookStore, error) {
+	store := service.NewInMemoryWebhookStore()
+	if webhooksPath == "" {
+		return store, nil
+	}
+
+	webhooks, err := service.ReadWebhooks(webhooksPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		err := store.Add(webhook)
+		if err != nil {
+			return nil, fmt.Errorf("cannot save webhook %s: %w", webhook.GetUrl(), err)
+		}
+	}
+	logging.Default().Info("read webhooks", "webhooks", len(webhooks), "path", webhooksPath)
+	return store, nil
+}
+
+// serveMetrics serves the metrics of the registry on /metrics of the HTTP port in the background.
+func serveMetrics(port int, registry *metrics.Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+
+	logging.Default().Info("serve metrics", "port", port)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("cannot serve metrics: ", err)
+		}
+	}()
+	return server
+}
+
+// serveDebug serves the pprof profiles, the expvar variables and the GC statistics on /debug/ of the HTTP port
+// in the background.
+func serveDebug(port int) *http.Server {
+	logging.Default().Info("serve debug endpoints", "port", port)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: profiling.NewHandler()}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("cannot serve debug endpoints: ", err)
+		}
+	}()
+	return server
+}
+
+// dialInProcess serves the server on an in-memory listener, and returns a connection to it.
+func dialInProcess(server *grpc.Server) (*grpc.ClientConn, error) {
+	listener := bufconn.Listen(inProcessBufferSize)
+	go server.Serve(listener)
+
+	return grpc.Dial(
+		"in-process",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+}
+
+// writeOpenAPI writes the OpenAPI document of the HTTP API of the gateway to path.
+func writeOpenAPI(path string) error {
+	document, err := gateway.OpenAPI()
+	if err != nil {
+		return fmt.Errorf("cannot generate OpenAPI document: %w", err)
+	}
+	err = os.WriteFile(path, document, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot write OpenAPI document: %w", err)
+	}
+	return nil
+}
+
+// serveHTTP serves the handler of the HTTP API on the port in the background.
+func serveHTTP(port int, handler http.Handler) *http.Server {
+	logging.Default().Info("serve HTTP", "port", port)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: handler}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("cannot serve HTTP: ", err)
+		}
+	}()
+	return server
+}
+
+// shutdown releases the resources of the server when it stops, in the reverse order of their registration.
+type shutdown struct {
+	mutex   sync.Mutex
+	names   []string
+	closers []func(ctx context.Context) error
+}
+
+// add registers the closer of the named resource.
+func (s *shutdown) add(name string, closer func(ctx context.Context) error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.names = append(s.names, name)
+	s.closers = append(s.closers, closer)
+}
+
+// run calls the closers, and logs their errors.
+func (s *shutdown) run(ctx context.Context) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if err := s.closers[i](ctx); err != nil {
+			logging.Default().Error("cannot close resource", "resource", s.names[i], "error", err)
+		}
+	}
+}
+
+// newLogger returns a new logger writing the messages of the level and above to the standard error in the format.
+func newLogger(levelName string, formatName string) (*logging.Logger, error) {
+	level, err := logging.ParseLevel(levelName)
+	if err != nil {
+		return nil, err
+	}
+	format, err := logging.ParseFormat(formatName)
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(os.Stderr, level, format), nil
+}
+
+// reloadConfig sets the reloadable flags again from the config file and the environment,
+// but the ones given on the command line, and applies them if any changed.
+func reloadConfig(path string, commandLine map[string]bool, apply func() error) {
+	changed, err := config.Reload(flag.CommandLine, path, envPrefix, commandLine, reloadableFlags...)
+	if err != nil {
+		logging.Default().Error("cannot reload config", "error", err)
+		return
+	}
+	if len(changed) == 0 {
+		logging.Default().Info("config reloaded, no setting changed")
+		return
+	}
+
+	err = apply()
+	if err != nil {
+		logging.Default().Error("cannot apply reloaded config", "error", err)
+		return
+	}
+	logging.Default().Info("config reloaded", "changed", strings.Join(changed, ","))
+}
+
+// modTime returns the modification time of the file at path, or the zero time if it can't be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// gracefulStop stops the server once its calls end, or cancels them when the context is done.
+func gracefulStop(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logging.Default().Warn("the calls didn't end within the shutdown timeout, cancel them")
+		server.Stop()
+		<-stopped
+	}
+}
+
+// openDatabase opens the database and checks its schema, applying the pending migrations if migrate is set.
+func openDatabase(driver string, dsn string, dialect migration.Dialect, migrate bool) (*sql.DB, *migration.Migrator, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dialect == migration.SQLite {
+		// SQLite only supports one writer at a time.
+		db.SetMaxOpenConns(1)
+	}
+
+	migrator, err := migration.NewMigrator(db, dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if migrate {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		logging.Default().Info("applied schema migrations", "applied", applied)
+	}
+
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version != migrator.Latest() || dirty {
+		logging.Default().Warn("schema is not up to date", "version", version, "dirty", dirty, "latest", migrator.Latest())
+	}
+
+	return db, migrator, nil
+}
+
+// retentionRules returns the retention rules of the laptops and of the audit log whose retention is set.
+func retentionRules(
+	laptopStore service.LaptopStore,
+	tenants func() []string,
+	laptopRetention time.Duration,
+	laptopRetentionFilter string,
+	auditSink service.AuditSink,
+	auditRetention time.Duration,
+) ([]service.RetentionRule, error) {
+	var rules []service.RetentionRule
+	if laptopRetention > 0 {
+		var expression *pb.Expression
+		if laptopRetentionFilter != "" {
+			expression = &pb.Expression{}
+			err := protojson.Unmarshal([]byte(laptopRetentionFilter), expression)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse -laptop-retention-filter: %w", err)
+			}
+			err = service.ValidateExpression(expression)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -laptop-retention-filter: %w", err)
+			}
+		}
+		rules = append(rules, service.NewLaptopRetentionRule(laptopStore, tenants, expression, laptopRetention))
+	}
+	if auditRetention > 0 {
+		pruner, ok := auditSink.(service.AuditPruner)
+		if !ok {
+			return nil, fmt.Errorf("-audit-retention requires an audit log that can delete its entries: file or sql")
+		}
+		rules = append(rules, service.NewAuditRetentionRule(pruner, auditRetention))
+	}
+	return rules, nil
+}
+
+// openAuditSink returns the audit sink of the kind, the file at path or the audit_log table of the database.
+func openAuditSink(kind string, path string, db *sql.DB, dialect migration.Dialect) (service.AuditSink, error) {
+	switch kind {
+	case "file":
+		return service.OpenFileAuditSink(path)
+	case "sql":
+		if db == nil {
+			return nil, fmt.Errorf("the sql audit log requires -db-dsn")
+		}
+		return service.NewSQLAuditSink(db, dialect), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q, must be file or sql", kind)
+	}
+}
+
+// newEventPublisher returns the event publisher of the kind: nats or log.
+func newEventPublisher(kind string, natsConfig service.NATSConfig) (service.EventPublisher, error) {
+	switch kind {
+	case "nats":
+		return service.NewNATSPublisher(natsConfig)
+	case "log":
+		return service.NewLogEventPublisher(nil), nil
+	default:
+		return nil, fmt.Errorf("unknown event publisher %q, must be nats or log", kind)
+	}
+}
+
+// runSnapshot writes a snapshot of the store to snapshotPath, or restores the snapshot at restorePath.
+func runSnapshot(store service.LaptopStore, snapshotPath string, restorePath string) error {
+	if snapshotPath != "" {
+		file, err := os.Create(snapshotPath)
+		if err != nil {
+			return fmt.Errorf("cannot create snapshot: %w", err)
+		}
+		written, err := service.SnapshotLaptops(context.Background(), store, file)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("cannot write snapshot: %w", err)
+		}
+		logging.Default().Info("wrote snapshot", "laptops", written, "path", snapshotPath)
+		return file.Close()
+	}
+
+	file, err := os.Open(restorePath)
+	if err != nil {
+		return fmt.Errorf("cannot open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	saved, err := service.RestoreLaptops(context.Background(), store, file)
+	logging.Default().Info("restored snapshot", "laptops", saved, "path", restorePath)
+	if err != nil {
+		return fmt.Errorf("cannot restore snapshot: %w", err)
+	}
+	return nil
+}
+
 func main() {
 	port := flag.Int("port", 0, "the server port")
+	trace := flag.Bool("trace", false, "log a span for every call and store operation")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "send the spans to the OpenTelemetry collector at this OTLP/HTTP endpoint, e.g. http://localhost:4318")
+	httpPort := flag.Int("http-port", 0, "serve the laptop service over HTTP/JSON and gRPC-Web on this port (no HTTP if 0)")
+	grpcWebOrigins := flag.String("grpc-web-origins", "", "comma-separated origins of the web apps allowed to call gRPC-Web on the HTTP port, * for all (same origin only if empty)")
+	enableGraphQL := flag.Bool("graphql", false, "serve the GraphQL endpoint on /graphql of the HTTP port")
+	openAPIPath := flag.String("openapi", "", "write the OpenAPI document of the HTTP API to this file and exit")
+	metricsPort := flag.Int("metrics-port", 0, "serve the Prometheus metrics on /metrics of this HTTP port (no metrics if 0)")
+	debugPort := flag.Int("debug-port", 0, "serve pprof, expvar and the GC stats on /debug/ of this HTTP port, for the operators only (not served if 0)")
+	storeKind := flag.String("store", "memory", "the laptop store: memory, sqlite, sql, bolt, badger, redis, mongo, dynamo or elastic")
+	slowStoreThreshold := flag.Duration("slow-store-threshold", 0, "log the store operations taking longer than this, with the ID or filter involved (not logged if 0)")
+	cacheTTL := flag.Duration("cache-ttl", 0, "cache the laptops found in the store for this long (not cached if 0)")
+	cacheSize := flag.Int("cache-size", 10000, "maximum number of laptops in the cache")
+	softDeleteRetention := flag.Duration("soft-delete-retention", 0, "keep the deleted laptops this long to be restored (deleted at once if 0)")
+	laptopRetention := flag.Duration("laptop-retention", 0, "delete the laptops not created or updated for this long (kept if 0)")
+	laptopRetentionFilter := flag.String("laptop-retention-filter", "", `the JSON filter expression of the laptops deleted by -laptop-retention, e.g. {"condition": {"field": "release_year", "operator": "LT", "numberValue": 2018}} (all of them if empty)`)
+	auditRetention := flag.Duration("audit-retention", 0, "delete the audit entries older than this (kept if 0)")
+	retentionDryRun := flag.Bool("retention-dry-run", false, "log and count the records the retention rules would delete without deleting them")
+	memoryTTL := flag.Duration("memory-ttl", 0, "delete the laptops of the memory store this long after they are saved (kept if 0)")
+	maxTenants := flag.Int("max-tenants", 1000, "the maximum number of tenants of the memory store (unlimited if 0)")
+	journalDir := flag.String("journal-dir", "", "keep the laptops of the memory store in journals in this directory (not kept if empty)")
+	sqlitePath := flag.String("sqlite-path", "laptops.db", "the database file of the sqlite store")
+	boltPath := flag.String("bolt-path", "laptops.bolt", "the database file of the bolt store")
+	badgerDir := flag.String("badger-dir", "laptops.badger", "the database directory of the badger store")
+	redisAddr := flag.String("redis-addr", "localhost:6379", "the address of the Redis server of the redis store")
+	redisTTL := flag.Duration("redis-ttl", 0, "expire the laptops of the redis store this long after they are saved or updated (kept if 0)")
+	mongoURI := flag.String("mongo-uri", "mongodb://localhost:27017", "the URI of the MongoDB deployment of the mongo store, with its credentials if any")
+	mongoDatabase := flag.String("mongo-database", "catalog", "the MongoDB database of the mongo store")
+	mongoCollection := flag.String("mongo-collection", "laptops", "the MongoDB collection of the mongo store")
+	elasticURL := flag.String("elastic-url", "http://localhost:9200", "the URL of the Elasticsearch cluster of the elastic store, with its credentials if any")
+	elasticIndex := flag.String("elastic-index", "laptops", "the Elasticsearch index of the elastic store")
+	dynamoTable := flag.String("dynamo-table", "laptops", "the DynamoDB table of the dynamo store")
+	dynamoRegion := flag.String("dynamo-region", os.Getenv("AWS_REGION"), "the AWS region of the dynamo store")
+	dynamoEndpoint := flag.String("dynamo-endpoint", "", "override the DynamoDB endpoint, e.g. for DynamoDB Local")
+	dbDriver := flag.String("db-driver", "", "the database/sql driver of the database, which must be linked into the binary")
+	dbDSN := flag.String("db-dsn", "", "the data source name of the database (no database if empty)")
+	dbDialect := flag.String("db-dialect", string(migration.Postgres), "the SQL dialect of the database: postgres, mysql or sqlite")
+	migrate := flag.Bool("migrate", false, "apply the pending schema migrations on startup")
+	migrateDown := flag.Int("migrate-down", 0, "revert this many schema migrations and exit")
+	snapshotPath := flag.String("snapshot", "", "write a snapshot of the laptops of the tenant to this file and exit")
+	restorePath := flag.String("restore", "", "save the laptops of a snapshot file to the store of the tenant and exit")
+	tlsCert := flag.String("tls-cert", "", "the PEM certificate chain of the server (plaintext if empty)")
+	tlsKey := flag.String("tls-key", "", "the PEM private key of the server certificate")
+	tlsClientCA := flag.String("tls-client-ca", "", "require the client certificates issued by the CAs of this PEM bundle (mutual TLS)")
+	defaultTimeout := flag.Duration("default-timeout", 30*time.Second, "the timeout of the unary calls sent without deadline (none if 0)")
+	defaultStreamTimeout := flag.Duration("default-stream-timeout", 0, "the timeout of the streaming calls sent without deadline (none if 0)")
+	maxRecvMsgSize := flag.Int("max-recv-msg-size", 0, "the largest message in bytes the server may receive (4 MiB if 0)")
+	maxSendMsgSize := flag.Int("max-send-msg-size", 0, "the largest message in bytes the server may send (unlimited if 0)")
+	keepaliveTime := flag.Duration("keepalive-time", 0, "ping the clients of the connections idle for this long (2h if 0)")
+	keepaliveTimeout := flag.Duration("keepalive-timeout", 0, "close the connections whose pings are not acked within this timeout (20s if 0)")
+	keepaliveMinClientTime := flag.Duration("keepalive-min-client-time", 0, "close the connections of the clients pinging more often than this (5m if 0)")
+	keepalivePermitWithoutStream := flag.Bool("keepalive-permit-without-stream", false, "allow the clients to ping the connections without active stream")
+	rateLimit := flag.Float64("rate-limit", 0, "maximum number of calls per second of all the callers (unlimited if 0)")
+	rateBurst := flag.Int("rate-burst", 1, "maximum burst of calls above the rate limit")
+	callerRateLimit := flag.Float64("caller-rate-limit", 0, "maximum number of calls per second of each user, API key or anonymous IP (unlimited if 0)")
+	callerRateBurst := flag.Int("caller-rate-burst", 1, "maximum burst of calls of each caller above its rate limit")
+	methodRateLimits := flag.String("method-rate-limits", "", "comma-separated method=rate[:burst] limits of each caller, in place of -caller-rate-limit")
+	requestLog := flag.String("request-log", "all", "the calls logged by the request logger: all, errors or none")
+	apiKeysPath := flag.String("api-keys", "", "a JSON file of the API keys the machine callers may authenticate with (no API keys if empty)")
+	rolesPath := flag.String("roles", "", "a JSON file of the roles that may access each method, overriding the built-in ones")
+	eventPublisherKind := flag.String("event-publisher", "", "publish the changes of the laptops to a message broker: nats or log (not published if empty)")
+	natsURL := flag.String("nats-url", "nats://localhost:4222", "the URL of the NATS server of the nats event publisher, with its credentials if any")
+	natsSubject := flag.String("nats-subject", "laptops", "the prefix of the subjects of the events of the nats event publisher")
+	webhooksPath := flag.String("webhooks", "", "a JSON file of the webhooks the changes of the laptops are posted to, more can be created with the admin service")
+	auditSinkKind := flag.String("audit-sink", "", "record the changes of the laptops in an audit log: file or sql (no audit log if empty)")
+	auditPath := flag.String("audit-path", "audit.jsonl", "the JSON-lines file of the file audit log")
+	tenant := flag.String("tenant", "", "the tenant of -snapshot and -restore")
+	enableReflection := flag.Bool("reflection", false, "register the gRPC reflection service, e.g. for grpcurl during development")
+	enableChannelz := flag.Bool("channelz", false, "register the gRPC channelz service, to inspect the live channels and sockets of the server (admins only)")
+	configPath := flag.String("config", os.Getenv(config.EnvName(envPrefix, "config")), "a YAML file of the settings, keyed by flag name, e.g. store: sqlite (overridden by the environment and the flags)")
+	logLevel := flag.String("log-level", "info", "the lowest level of the messages logged: debug, info, warn or error")
+	logFormat := flag.String("log-format", "console", "the format of the messages logged: console or json")
+	printConfig := flag.Bool("print-config", false, "print the settings in effect as a config file, and exit")
+	configReloadInterval := flag.Duration("config-reload-interval", 0, "check the -config file for changes this often, to reload it as on SIGHUP (on SIGHUP only if 0)")
+	secretKey := flag.String("jwt-secret", "secret", "the secret key signing the access tokens")
+	signingKey := flag.String("erasure-signing-key", "erasure-secret", "the key signing the receipts of the user data erasures")
+	tokenDuration := flag.Duration("token-duration", 15*time.Minute, "the lifetime of the access tokens")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "on SIGINT or SIGTERM, the time given to the calls in flight to end and to the events to be published")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage of %s:\n\nEvery flag may also be set in the -config file, by its name, "+
+			"or by an environment variable, e.g. %s for -tls-cert.\n\n", os.Args[0], config.EnvName(envPrefix, "tls-cert"))
+		flag.PrintDefaults()
+	}
 	flag.Parse()
-	log.Printf("start server on port %d", *port)
+
+	// The flags given on the command line keep their value when the config is reloaded.
+	commandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		commandLine[f.Name] = true
+	})
+	err := config.Load(flag.CommandLine, *configPath, envPrefix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The standard logger only logs the fatal errors of the server and of the libraries.
+	logger, err := newLogger(*logLevel, *logFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logging.SetDefault(logger)
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logging.Error))
+
+	if *printConfig {
+		// The printed settings are a config file to load, without the flags that only make sense on the command line.
+		flag.Set("config", "")
+		flag.Set("print-config", "false")
+		err := config.Write(os.Stdout, flag.CommandLine, "jwt-secret", "erasure-signing-key")
+		if err != nil {
+			log.Fatal("cannot print config: ", err)
+		}
+		return
+	}
+
+	if *openAPIPath != "" {
+		err := writeOpenAPI(*openAPIPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	logger.Info("start server", "port", *port, "tls", *tlsCert != "")
+
+	if *storeKind == "sqlite" {
+		// The sqlite database is owned by the server, so its schema is always migrated.
+		*dbDriver, *dbDSN, *dbDialect, *migrate = service.SQLiteDriver, *sqlitePath, string(migration.SQLite), true
+	}
+
+	// The background tasks run until the server stops, and the resources are released after them.
+	tasksCtx, stopTasks := context.WithCancel(context.Background())
+	defer stopTasks()
+	var tasks sync.WaitGroup
+	resources := &shutdown{}
+
+	var db *sql.DB
+	var adminOptions []service.AdminServerOption
+	if *dbDSN != "" {
+		var migrator *migration.Migrator
+		var err error
+		db, migrator, err = openDatabase(*dbDriver, *dbDSN, migration.Dialect(*dbDialect), *migrate)
+		if err != nil {
+			log.Fatal("cannot open database: ", err)
+		}
+		resources.add("database", func(context.Context) error { return db.Close() })
+
+		if *migrateDown > 0 {
+			reverted, err := migrator.Down(context.Background(), *migrateDown)
+			if err != nil {
+				log.Fatal("cannot revert schema migrations: ", err)
+			}
+			logger.Info("reverted schema migrations", "reverted", reverted)
+			return
+		}
+		adminOptions = append(adminOptions, service.WithSchemaMigrator(migrator))
+	}
 
 	userStore := service.NewInMemoryUserStore()
 
-	err := seedUsers(userStore)
+	err = seedUsers(userStore)
 	if err != nil {
 		log.Fatal("cannot seed users")
 	}
 
-	jwtManager := service.NewJWTManager(secretKey, tokenDuration)
+	jwtManager := service.NewJWTManager(*secretKey, *tokenDuration)
 	authServer := service.NewAuthServer(userStore, jwtManager)
 
-	laptopStore := service.NewInMemoryLaptopStore()
+	var laptopStore service.LaptopStore
+	// tenants returns the tenants of the store, or is nil if only the default tenant is known.
+	var tenants func() []string
+	switch *storeKind {
+	case "memory":
+		tenantStore := service.NewTenantLaptopStore(func(tenant string) (service.LaptopStore, error) {
+			store := service.NewInMemoryLaptopStore()
+			if *journalDir != "" {
+				var err error
+				store, err = service.OpenInMemoryLaptopStore(service.JournalPath(*journalDir, tenant))
+				if err != nil {
+					return nil, err
+				}
+				resources.add("journal of tenant "+tenant, func(context.Context) error { return store.Close() })
+			}
+			if *memoryTTL > 0 {
+				store.SetDefaultTTL(*memoryTTL)
+				go store.Run(tasksCtx, sweepInterval)
+			}
+			return store, nil
+		})
+		tenantStore.SetMaxTenants(*maxTenants)
+		laptopStore = tenantStore
+		tenants = tenantStore.Tenants
+	case "sqlite", "sql":
+		if db == nil {
+			log.Fatal("the sql store requires -db-dsn")
+		}
+		laptopStore = service.NewSQLLaptopStore(db, migration.Dialect(*dbDialect))
+	case "bolt":
+		boltStore, err := service.OpenBoltLaptopStore(*boltPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resources.add("bolt database", func(context.Context) error { return boltStore.Close() })
+		laptopStore = boltStore
+	case "badger":
+		badgerStore, err := service.OpenBadgerLaptopStore(*badgerDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resources.add("badger database", func(context.Context) error { return badgerStore.Close() })
+		laptopStore = badgerStore
+	case "redis":
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		redisStore, err := service.DialRedisLaptopStore(ctx, *redisAddr, *redisTTL)
+		cancel()
+		if err != nil {
+			log.Fatal(err)
+		}
+		resources.add("redis client", func(context.Context) error { return redisStore.Close() })
+		laptopStore = redisStore
+	case "mongo":
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		mongoStore, err := service.DialMongoLaptopStore(ctx, *mongoURI, *mongoDatabase, *mongoCollection)
+		cancel()
+		if err != nil {
+			log.Fatal(err)
+		}
+		resources.add("mongo client", func(context.Context) error { return mongoStore.Close() })
+		laptopStore = mongoStore
+	case "dynamo":
+		// The credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+		dynamoClient := service.NewDynamoHTTPClient(service.DynamoConfig{
+			Region:      *dynamoRegion,
+			Credentials: service.DynamoCredentialsFromEnv(),
+			Endpoint:    *dynamoEndpoint,
+		})
+		laptopStore = service.NewDynamoLaptopStore(dynamoClient, *dynamoTable)
+	case "elastic":
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		laptopStore, err = service.NewElasticLaptopStore(ctx, nil, *elasticURL, *elasticIndex)
+		cancel()
+		if err != nil {
+			log.Fatal("cannot open elastic store: ", err)
+		}
+	default:
+		log.Fatalf("unknown store %q, must be one of memory, sqlite, sql, bolt, badger, redis, mongo, dynamo, elastic", *storeKind)
+	}
+	var metricsRegistry *metrics.Registry
+	var storeMetrics *service.StoreMetrics
+	if *metricsPort > 0 {
+		metricsRegistry = metrics.NewRegistry()
+		storeMetrics = service.NewStoreMetrics(metricsRegistry)
+	}
+	var tracer *tracing.Tracer
+	switch {
+	case *otlpEndpoint != "":
+		exporter := tracing.NewOTLPExporter(*otlpEndpoint, "grpc_app-server")
+		go exporter.Run(tasksCtx, spanFlushInterval)
+		resources.add("span exporter", exporter.Flush)
+		tracer = tracing.NewTracer(exporter)
+	case *trace:
+		tracer = tracing.NewTracer(tracing.LogExporter)
+	}
+	if storeMetrics != nil || tracer != nil {
+		laptopStore = service.NewInstrumentedLaptopStore(laptopStore, storeMetrics, tracer)
+	}
+	if *slowStoreThreshold > 0 {
+		laptopStore = service.NewSlowLaptopStore(laptopStore, *slowStoreThreshold, logger.With("component", "laptop_store"))
+	}
+	if *cacheTTL > 0 {
+		laptopStore = service.NewCachedLaptopStore(laptopStore, *cacheTTL, *cacheSize)
+	}
+	if *softDeleteRetention > 0 {
+		softDeleteStore := service.NewSoftDeleteLaptopStore(laptopStore, *softDeleteRetention)
+		go softDeleteStore.Run(tasksCtx, purgeInterval)
+		laptopStore = softDeleteStore
+		adminOptions = append(adminOptions, service.WithSoftDeleteStore(softDeleteStore))
+	}
+	if *snapshotPath != "" || *restorePath != "" {
+		store := laptopStore
+		if scoped, ok := store.(service.TenantScopedStore); ok {
+			store = scoped.ForTenant(*tenant)
+		}
+		err := runSnapshot(store, *snapshotPath, *restorePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	var auditSink service.AuditSink
+	if *auditSinkKind != "" {
+		auditSink, err = openAuditSink(*auditSinkKind, *auditPath, db, migration.Dialect(*dbDialect))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if closer, ok := auditSink.(io.Closer); ok {
+			resources.add("audit log", func(context.Context) error { return closer.Close() })
+		}
+		laptopStore = service.NewAuditLaptopStore(laptopStore, auditSink)
+		adminOptions = append(adminOptions, service.WithAuditSink(auditSink))
+	}
+	watchStore := service.NewWatchLaptopStore(laptopStore)
+	laptopStore = watchStore
+	if *eventPublisherKind != "" {
+		publisher, err := newEventPublisher(*eventPublisherKind, service.NATSConfig{URL: *natsURL, Subject: *natsSubject})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if closer, ok := publisher.(io.Closer); ok {
+			resources.add("event publisher", func(context.Context) error { return closer.Close() })
+		}
+		tasks.Add(1)
+		go func() {
+			defer tasks.Done()
+			service.PublishLaptopEvents(tasksCtx, watchStore, publisher, nil)
+		}()
+	}
+	webhookStore, err := loadWebhooks(*webhooksPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhookPublisher := service.NewWebhookPublisher(webhookStore, service.WebhookConfig{})
+	resources.add("webhook deliveries", webhookPublisher.Flush)
+	tasks.Add(1)
+	go func() {
+		defer tasks.Done()
+		service.PublishLaptopEvents(tasksCtx, watchStore, webhookPublisher, nil)
+	}()
+	adminOptions = append(adminOptions, service.WithWebhookStore(webhookStore))
+	retentionRules, err := retentionRules(
+		laptopStore, tenants, *laptopRetention, *laptopRetentionFilter, auditSink, *auditRetention,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(retentionRules) > 0 {
+		retentionPolicy := service.NewRetentionPolicy(retentionRules, *retentionDryRun, metricsRegistry)
+		retentionPolicy.SetLogger(logger.With("component", "retention"))
+		tasks.Add(1)
+		go func() {
+			defer tasks.Done()
+			retentionPolicy.Run(tasksCtx, retentionInterval)
+		}()
+	}
 	imageStore := service.NewDiskImageStore("img")
 	ratingStore := service.NewInMemoryRatingStore()
-	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore)
+	viewCounter := service.NewViewCounter(service.NewInMemoryViewStore(service.MaxTrendingWindow), 16)
+	tasks.Add(1)
+	go func() {
+		defer tasks.Done()
+		viewCounter.Run(tasksCtx, viewFlushInterval)
+	}()
+	holdStore := service.NewInMemoryHoldStore()
+	laptopServer := service.NewLaptopServer(
+		laptopStore,
+		imageStore,
+		ratingStore,
+		service.WithHoldStore(holdStore),
+		service.WithViewCounter(viewCounter),
+		service.WithLogger(logger.With("component", "laptop_server")),
+	)
+	adminOptions = append(adminOptions, service.WithLaptopStore(laptopStore), service.WithAdminLogger(logger.With("component", "admin_server")))
+	erasers := map[string]service.UserDataEraser{
+		"users": userStore,
+		"holds": holdStore,
+	}
+	if eraser, ok := auditSink.(service.UserDataEraser); ok {
+		erasers["audit_log"] = eraser
+	}
+	adminServer := service.NewAdminServer(*signingKey, erasers, adminOptions...)
+
+	roles := accessibleRoles()
+	if *rolesPath != "" {
+		err := overrideRoles(roles, *rolesPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var authOptions []service.AuthInterceptorOption
+	if *apiKeysPath != "" {
+		apiKeyStore, err := loadAPIKeys(*apiKeysPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authOptions = append(authOptions, service.WithAPIKeyStore(apiKeyStore))
+	}
+	interceptor := service.NewAuthInterceptor(jwtManager, roles, authOptions...)
+	localizer := service.NewLocalizer(service.DefaultTranslations())
+	requestLogger := service.NewRequestLogger(nil)
+	deadlineInterceptor := service.NewDeadlineInterceptor(*defaultTimeout, *defaultStreamTimeout, nil)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		localizer.Unary(), requestLogger.Unary(), deadlineInterceptor.Unary(), interceptor.Unary(),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		localizer.Stream(), requestLogger.Stream(), deadlineInterceptor.Stream(), interceptor.Stream(),
+	}
+	if metricsRegistry != nil {
+		serverMetrics := service.NewServerMetrics(metricsRegistry)
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{serverMetrics.Unary()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{serverMetrics.Stream()}, streamInterceptors...)
+		metricsServer := serveMetrics(*metricsPort, metricsRegistry)
+		resources.add("metrics server", metricsServer.Shutdown)
+	}
+	if *debugPort > 0 {
+		debugServer := serveDebug(*debugPort)
+		resources.add("debug server", debugServer.Shutdown)
+	}
+	if tracer != nil {
+		tracingInterceptor := service.NewTracingInterceptor(tracer)
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{tracingInterceptor.Unary()}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{tracingInterceptor.Stream()}, streamInterceptors...)
+	}
+	// The rate limiter is always installed, unlimited by default, so that the limits may be set by a reload.
+	rateLimiter := service.NewRateLimiter(service.RateLimiterConfig{})
+	unaryInterceptors = append(unaryInterceptors, rateLimiter.Unary())
+	streamInterceptors = append(streamInterceptors, rateLimiter.Stream())
+	// applySettings applies the reloadable flags to the running interceptors.
+	applySettings := func() error {
+		logLevel, err := logging.ParseLevel(*logLevel)
+		if err != nil {
+			return err
+		}
+		level, err := service.ParseRequestLogLevel(*requestLog)
+		if err != nil {
+			return err
+		}
+		methodLimits, err := service.ParseRateLimits(*methodRateLimits)
+		if err != nil {
+			return fmt.Errorf("cannot parse method rate limits: %w", err)
+		}
 
-	interceptor := service.NewAuthInterceptor(jwtManager, accessibleRoles())
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(interceptor.Unary()),
-		grpc.StreamInterceptor(interceptor.Stream()),
+		logger.SetLevel(logLevel)
+		requestLogger.SetLevel(level)
+		deadlineInterceptor.SetTimeouts(*defaultTimeout, *defaultStreamTimeout)
+		rateLimiter.SetConfig(service.RateLimiterConfig{
+			Global:    service.RateLimit{Rate: *rateLimit, Burst: *rateBurst},
+			PerCaller: service.RateLimit{Rate: *callerRateLimit, Burst: *callerRateBurst},
+			Methods:   methodLimits,
+		})
+		return nil
+	}
+	if err := applySettings(); err != nil {
+		log.Fatal(err)
+	}
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	if *maxRecvMsgSize > 0 {
+		serverOptions = append(serverOptions, grpc.MaxRecvMsgSize(*maxRecvMsgSize))
+	}
+	if *maxSendMsgSize > 0 {
+		serverOptions = append(serverOptions, grpc.MaxSendMsgSize(*maxSendMsgSize))
+	}
+	serverOptions = append(serverOptions, service.KeepaliveServerOptions(service.KeepaliveConfig{
+		Time:                *keepaliveTime,
+		Timeout:             *keepaliveTimeout,
+		MinClientTime:       *keepaliveMinClientTime,
+		PermitWithoutStream: *keepalivePermitWithoutStream,
+	})...)
+	// The in-process server of the HTTP gateway has the same options but the credentials,
+	// since it is only reached through memory.
+	inProcessOptions := append([]grpc.ServerOption(nil), serverOptions...)
+	if *tlsCert != "" {
+		tlsCredentials, err := service.LoadTLSCredentials(service.TLSConfig{
+			CertFile:     *tlsCert,
+			KeyFile:      *tlsKey,
+			ClientCAFile: *tlsClientCA,
+		})
+		if err != nil {
+			log.Fatal("cannot load TLS credentials: ", err)
+		}
+		serverOptions = append(serverOptions, grpc.Creds(tlsCredentials))
+	}
+	registerServices := func(server *grpc.Server) {
+		pb.RegisterAuthServiceServer(server, authServer)
+		pb.RegisterLaptopServiceServer(server, laptopServer)
+		pbv2.RegisterLaptopServiceServer(server, service.NewLaptopServerV2(laptopServer))
+		pb.RegisterAdminServiceServer(server, adminServer)
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
+	registerServices(grpcServer)
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	storeHealth := service.NewStoreHealth(
+		laptopStore,
+		healthServer,
+		healthTimeout,
+		pb.LaptopService_ServiceDesc.ServiceName,
+		pbv2.LaptopService_ServiceDesc.ServiceName,
 	)
+	if err := storeHealth.Check(context.Background()); err != nil {
+		logger.Warn("cannot reach laptop store", "error", err)
+	}
+	go storeHealth.Run(tasksCtx, healthInterval)
+	if *enableReflection {
+		reflection.Register(grpcServer)
+	}
+	if *enableChannelz {
+		channelz.RegisterChannelzServiceToServer(grpcServer)
+	}
 
-	pb.RegisterAuthServiceServer(grpcServer, authServer)
-	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
-	reflection.Register(grpcServer)
+	var httpServer *http.Server
+	var inProcessServer *grpc.Server
+	if *httpPort > 0 {
+		inProcessServer = grpc.NewServer(inProcessOptions...)
+		registerServices(inProcessServer)
+		conn, err := dialInProcess(inProcessServer)
+		if err != nil {
+			log.Fatal("cannot dial in-process server: ", err)
+		}
+		// The gRPC-Web calls of the browsers are served by the in-process server too, the others by the gateway.
+		var origins []string
+		if *grpcWebOrigins != "" {
+			origins = strings.Split(*grpcWebOrigins, ",")
+		}
+		var gatewayOptions []gateway.Option
+		if *enableGraphQL {
+			gatewayOptions = append(gatewayOptions, gateway.WithGraphQL())
+		}
+		httpServer = serveHTTP(*httpPort, grpcweb.New(inProcessServer, gateway.New(conn, gatewayOptions...), origins))
+	}
 
 	address := fmt.Sprintf("0.0.0.0:%d", *port)
 	listener, err := net.Listen("tcp", address)
@@ -80,8 +1010,70 @@ func main() {
 		log.Fatal("cannot start server: ", err)
 	}
 
-	err = grpcServer.Serve(listener)
-	if err != nil {
-		log.Fatal("cannot start server: ", err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(listener)
+	}()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	var configChecks <-chan time.Time
+	configModTime := modTime(*configPath)
+	if *configPath != "" && *configReloadInterval > 0 {
+		ticker := time.NewTicker(*configReloadInterval)
+		defer ticker.Stop()
+		configChecks = ticker.C
+	}
+serve:
+	for {
+		select {
+		case err := <-serveErr:
+			log.Fatal("cannot start server: ", err)
+		case <-reloads:
+			configModTime = modTime(*configPath)
+			reloadConfig(*configPath, commandLine, applySettings)
+		case <-configChecks:
+			if t := modTime(*configPath); !t.Equal(configModTime) {
+				configModTime = t
+				reloadConfig(*configPath, commandLine, applySettings)
+			}
+		case <-signals.Done():
+			break serve
+		}
+	}
+	signal.Stop(reloads)
+
+	// The health checks report the server as not serving, so that the load balancers stop sending it calls,
+	// and the calls in flight end before the background tasks publish their last events.
+	logger.Info("shut down the server", "timeout", *shutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	healthServer.Shutdown()
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			logger.Error("cannot shut down HTTP server", "error", err)
+		}
+		gracefulStop(ctx, inProcessServer)
+	}
+	gracefulStop(ctx, grpcServer)
+
+	// The streams that never end, e.g. the watches, use up the timeout of the calls,
+	// so the tasks and the resources have a timeout of their own to flush their events.
+	ctx, cancel = context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	stopTasks()
+	tasksDone := make(chan struct{})
+	go func() {
+		tasks.Wait()
+		close(tasksDone)
+	}()
+	select {
+	case <-tasksDone:
+	case <-ctx.Done():
+		logger.Warn("the background tasks didn't end within the shutdown timeout")
 	}
+	resources.run(ctx)
+	logger.Info("server stopped")
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/service"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	channelz "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestChannelzAccessibleRoles(t *testing.T) {
+	t.Parallel()
+
+	roles := accessibleRoles()
+	for _, method := range channelzpb.Channelz_ServiceDesc.Methods {
+		path := "/" + channelzpb.Channelz_ServiceDesc.ServiceName + "/" + method.MethodName
+		require.Equal(t, []string{"admin"}, roles[path], "%s is reserved to the admins", path)
+	}
+
+	jwtManager := service.NewJWTManager("secret", time.Minute)
+	authInterceptor := service.NewAuthInterceptor(jwtManager, roles)
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(authInterceptor.Unary()))
+	channelz.RegisterChannelzServiceToServer(grpcServer)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	channelzClient := channelzpb.NewChannelzClient(conn)
+
+	getServers := func(role string) error {
+		user, err := service.NewUser(role+"1", "secret", role)
+		require.NoError(t, err)
+		token, err := jwtManager.Generate(user)
+		require.NoError(t, err)
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+		_, err = channelzClient.GetServers(ctx, &channelzpb.GetServersRequest{})
+		return err
+	}
+	require.NoError(t, getServers("admin"))
+	require.Equal(t, codes.PermissionDenied, status.Code(getServers("user")))
+}
+
+func TestAdminAccessibleRoles(t *testing.T) {
+	t.Parallel()
+
+	roles := accessibleRoles()
+	for _, method := range pb.AdminService_ServiceDesc.Methods {
+		path := "/" + pb.AdminService_ServiceDesc.ServiceName + "/" + method.MethodName
+		require.Equal(t, []string{"admin"}, roles[path], "%s is reserved to the admins", path)
+	}
+}
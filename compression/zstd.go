@@ -0,0 +1,78 @@
+// Package compression registers the zstd compressor of the gRPC calls, so that the clients and the server
+// can compress their messages with zstd like with the gzip compressor of gRPC. It's registered when the package
+// is imported, and both sides must import it.
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+// Zstd is the name of the zstd compressor.
+const Zstd = "zstd"
+
+// maxDecodedSize bounds the size of a decompressed message, so that a small message can't expand
+// to exhaust the memory before gRPC checks its size against the maximum received.
+const maxDecodedSize = 64 << 20
+
+func init() {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		panic(fmt.Sprintf("cannot create zstd encoder: %v", err))
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecodedSize))
+	if err != nil {
+		panic(fmt.Sprintf("cannot create zstd decoder: %v", err))
+	}
+	encoding.RegisterCompressor(&zstdCompressor{encoder: encoder, decoder: decoder})
+}
+
+// zstdCompressor compresses each message as a whole with a shared encoder and decoder,
+// whose EncodeAll and DecodeAll may be called concurrently.
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// Name returns the name of the compressor
+func (compressor *zstdCompressor) Name() string {
+	return Zstd
+}
+
+// Compress returns a writer buffering the message, compressed to w when it's closed
+func (compressor *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &zstdWriter{encoder: compressor.encoder, writer: w}, nil
+}
+
+// Decompress returns a reader of the decompressed message read from r
+func (compressor *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := compressor.decoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress message: %w", err)
+	}
+	return bytes.NewReader(decoded), nil
+}
+
+// zstdWriter buffers a message to compress it at once when it's closed.
+type zstdWriter struct {
+	encoder *zstd.Encoder
+	writer  io.Writer
+	buffer  bytes.Buffer
+}
+
+func (writer *zstdWriter) Write(p []byte) (int, error) {
+	return writer.buffer.Write(p)
+}
+
+func (writer *zstdWriter) Close() error {
+	_, err := writer.writer.Write(writer.encoder.EncodeAll(writer.buffer.Bytes(), nil))
+	return err
+}
@@ -0,0 +1,39 @@
+package compression_test
+
+import (
+	"bytes"
+	"grpc_app/compression"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestZstd(t *testing.T) {
+	t.Parallel()
+
+	compressor := encoding.GetCompressor(compression.Zstd)
+	require.NotNil(t, compressor, "the compressor is registered")
+
+	message := []byte(strings.Repeat("compressible ", 1000))
+	var compressed bytes.Buffer
+	writer, err := compressor.Compress(&compressed)
+	require.NoError(t, err)
+	_, err = writer.Write(message[:100])
+	require.NoError(t, err)
+	_, err = writer.Write(message[100:])
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.Less(t, compressed.Len(), len(message)/10)
+
+	reader, err := compressor.Decompress(&compressed)
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, message, decompressed)
+
+	_, err = compressor.Decompress(strings.NewReader("not zstd"))
+	require.Error(t, err)
+}
@@ -0,0 +1,203 @@
+// Package config loads the settings of a command into its flags from a YAML file and environment variables,
+// so that every setting of the command can be set in any of them, with the flag as the single source of truth
+// of its name, type and default.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load sets the flags of the set that are not given on the command line from the environment variables
+// named after them with the prefix, e.g. LAPTOP_TLS_CERT for the tls-cert flag and the prefix LAPTOP_,
+// and else from the YAML file at path, if not empty, whose keys are the names of the flags:
+//
+//	port: 8080
+//	store: sqlite
+//	tls-cert: cert/server-cert.pem
+//	grpc-web-origins: [https://app.example.com, https://admin.example.com]
+//
+// The lists are joined with commas, for the flags taking comma-separated values.
+// The command line overrides the environment, which overrides the file.
+func Load(flags *flag.FlagSet, path string, envPrefix string) error {
+	values, err := readFile(flags, path)
+	if err != nil {
+		return err
+	}
+
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var setErr error
+	flags.VisitAll(func(f *flag.Flag) {
+		if setErr != nil || explicit[f.Name] {
+			return
+		}
+
+		value, source, ok := lookup(f.Name, values, path, envPrefix)
+		if !ok {
+			return
+		}
+
+		err := flags.Set(f.Name, value)
+		if err != nil {
+			setErr = fmt.Errorf("invalid %s of %s: %w", f.Name, source, err)
+		}
+	})
+	return setErr
+}
+
+// Reload sets the named flags of the set again from the environment and the YAML file at path as Load does,
+// e.g. on SIGHUP to apply the changes of the file to the running command, and returns the names of the flags
+// whose value changed. The flags in fixed, usually the ones given on the command line, keep their value,
+// and the others missing from both the environment and the file are reset to their default.
+// The values are checked on new values of the types of the flags before any flag is set,
+// so that an invalid file or environment changes none of them.
+func Reload(flags *flag.FlagSet, path string, envPrefix string, fixed map[string]bool, names ...string) ([]string, error) {
+	values, err := readFile(flags, path)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]string, len(names))
+	for _, name := range names {
+		f := flags.Lookup(name)
+		if f == nil {
+			return nil, fmt.Errorf("unknown flag %s", name)
+		}
+		if fixed[name] {
+			continue
+		}
+
+		value, source, ok := lookup(name, values, path, envPrefix)
+		if !ok {
+			value, source = f.DefValue, "default"
+		}
+		if t := reflect.TypeOf(f.Value); t.Kind() == reflect.Ptr {
+			check := reflect.New(t.Elem()).Interface().(flag.Value)
+			if err := check.Set(value); err != nil {
+				return nil, fmt.Errorf("invalid %s of %s: %w", name, source, err)
+			}
+		}
+		updates[name] = value
+	}
+
+	var changed []string
+	for _, name := range names {
+		value, ok := updates[name]
+		if !ok {
+			continue
+		}
+		previous := flags.Lookup(name).Value.String()
+		if err := flags.Set(name, value); err != nil {
+			return changed, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if flags.Lookup(name).Value.String() != previous {
+			changed = append(changed, name)
+		}
+	}
+	return changed, nil
+}
+
+// lookup returns the value of the flag from the environment, or else from the values of the file at path,
+// with its source for the errors, and whether it's set in either.
+func lookup(name string, values map[string]string, path string, envPrefix string) (string, string, bool) {
+	if value, ok := os.LookupEnv(EnvName(envPrefix, name)); ok {
+		return value, "environment variable " + EnvName(envPrefix, name), true
+	}
+	value, ok := values[name]
+	return value, "config file " + path, ok
+}
+
+// EnvName returns the name of the environment variable of the flag, e.g. LAPTOP_TLS_CERT for tls-cert.
+func EnvName(envPrefix string, flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readFile returns the values of the flags in the YAML file, or none if path is empty.
+// The keys may use underscores in place of dashes, e.g. tls_cert.
+func readFile(flags *flag.FlagSet, path string) (map[string]string, error) {
+	values := make(map[string]string)
+	if path == "" {
+		return values, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	var file map[string]interface{}
+	err = yaml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+
+	for key, value := range file {
+		name := strings.ReplaceAll(key, "_", "-")
+		if flags.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown setting %q in config file %s", key, path)
+		}
+
+		switch value := value.(type) {
+		case nil:
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		case map[string]interface{}:
+			return nil, fmt.Errorf("setting %q in config file %s must be a value or a list", key, path)
+		default:
+			values[name] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+// redacted replaces the values of the secret flags written by Write.
+const redacted = "REDACTED"
+
+// Write writes the current values of the flags of the set as a YAML config file, sorted by name,
+// e.g. to print the settings in effect after Load. The values of the secret flags are redacted.
+func Write(w io.Writer, flags *flag.FlagSet, secrets ...string) error {
+	isSecret := make(map[string]bool, len(secrets))
+	for _, name := range secrets {
+		isSecret[name] = true
+	}
+
+	values := make(map[string]string)
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+		if isSecret[f.Name] {
+			values[f.Name] = redacted
+		}
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+
+	for _, name := range names {
+		// The values are written as YAML strings, which Load sets back as they are.
+		value, err := yaml.Marshal(values[name])
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s: %s", name, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package config_test
+
+import (
+	"bytes"
+	"flag"
+	"grpc_app/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+port: 8080
+store: sqlite
+tls_cert: cert/server-cert.pem
+origins: [https://a.example.com, https://b.example.com]
+timeout: 10s
+`), 0o600))
+	t.Setenv("TEST_STORE", "memory")
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	port := flags.Int("port", 0, "")
+	store := flags.String("store", "", "")
+	tlsCert := flags.String("tls-cert", "", "")
+	origins := flags.String("origins", "", "")
+	timeout := flags.Duration("timeout", time.Second, "")
+	secret := flags.String("secret", "default", "")
+	require.NoError(t, flags.Parse([]string{"-port", "9090"}))
+
+	require.NoError(t, config.Load(flags, path, "TEST_"))
+	require.Equal(t, 9090, *port, "the command line overrides the file")
+	require.Equal(t, "memory", *store, "the environment overrides the file")
+	require.Equal(t, "cert/server-cert.pem", *tlsCert)
+	require.Equal(t, "https://a.example.com,https://b.example.com", *origins)
+	require.Equal(t, 10*time.Second, *timeout)
+	require.Equal(t, "default", *secret, "the missing settings keep their default")
+
+	var out bytes.Buffer
+	require.NoError(t, config.Write(&out, flags, "secret"))
+	require.Contains(t, out.String(), "secret: REDACTED\n")
+	require.Contains(t, out.String(), "timeout: 10s\n")
+
+	// The written settings load back as they are.
+	written := filepath.Join(t.TempDir(), "written.yaml")
+	require.NoError(t, os.WriteFile(written, out.Bytes(), 0o600))
+	loaded := flag.NewFlagSet("server", flag.ContinueOnError)
+	loadedPort := loaded.Int("port", 0, "")
+	loaded.String("store", "", "")
+	loaded.String("tls-cert", "", "")
+	loadedOrigins := loaded.String("origins", "", "")
+	loaded.Duration("timeout", time.Second, "")
+	loaded.String("secret", "", "")
+	require.NoError(t, config.Load(loaded, written, "OTHER_"))
+	require.Equal(t, 9090, *loadedPort)
+	require.Equal(t, *origins, *loadedOrigins)
+
+	require.NoError(t, os.WriteFile(path, []byte("unknown: 1\n"), 0o600))
+	require.ErrorContains(t, config.Load(flags, path, "TEST_"), `unknown setting "unknown"`)
+
+	t.Setenv("TEST_TIMEOUT", "soon")
+	invalid := flag.NewFlagSet("server", flag.ContinueOnError)
+	invalid.Duration("timeout", time.Second, "")
+	require.ErrorContains(t, config.Load(invalid, "", "TEST_"), "TEST_TIMEOUT")
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rate: 5\nburst: 10\n"), 0o600))
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	rate := flags.Float64("rate", 0, "")
+	burst := flags.Int("burst", 1, "")
+	timeout := flags.Duration("timeout", time.Second, "")
+	require.NoError(t, flags.Parse([]string{"-timeout", "5s"}))
+	fixed := map[string]bool{"timeout": true}
+	require.NoError(t, config.Load(flags, path, "TEST_"))
+
+	require.NoError(t, os.WriteFile(path, []byte("rate: 2\ntimeout: 1m\n"), 0o600))
+	changed, err := config.Reload(flags, path, "TEST_", fixed, "rate", "burst", "timeout")
+	require.NoError(t, err)
+	require.Equal(t, []string{"rate", "burst"}, changed)
+	require.Equal(t, 2.0, *rate)
+	require.Equal(t, 1, *burst, "the settings removed from the file are reset to their default")
+	require.Equal(t, 5*time.Second, *timeout, "the command line overrides the file")
+
+	changed, err = config.Reload(flags, path, "TEST_", fixed, "rate", "burst")
+	require.NoError(t, err)
+	require.Empty(t, changed)
+
+	require.NoError(t, os.WriteFile(path, []byte("rate: 3\nburst: many\n"), 0o600))
+	_, err = config.Reload(flags, path, "TEST_", fixed, "rate", "burst")
+	require.ErrorContains(t, err, "invalid burst")
+	require.Equal(t, 2.0, *rate, "an invalid file changes none of the settings")
+}
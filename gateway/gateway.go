@@ -0,0 +1,304 @@
+// Package gateway serves the laptop service over HTTP with JSON bodies, as a reverse proxy
+// forwarding every request to the gRPC server, in the manner of grpc-gateway:
+//
+//	POST /v1/laptops          CreateLaptop, with the laptop as body
+//	GET  /v1/laptops/{id}     GetLaptop, with the read_mask query parameter, e.g. read_mask=name,price_usd
+//	GET  /v1/laptops:search   SearchLaptop, with the fields of the request as query parameters,
+//	                          e.g. filter.max_price_usd=2000&filter.min_ram.value=8&filter.min_ram.unit=GIGABYTE
+//
+// The messages are in the JSON mapping of protobuf. The errors are google.rpc.Status messages
+// with the HTTP status of their code, and the found laptops are streamed as {"result": ...} lines.
+//
+// The OpenAPI document of the API is served on /openapi.json, and its Swagger UI on /swagger/.
+// With the WithGraphQL option, the GraphQL queries are served on /graphql too.
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// maxBodySize is the largest request body the gateway reads.
+const maxBodySize = 4 << 20
+
+// metadataHeaderPrefix is the prefix of the HTTP headers forwarded to the gRPC server
+// as metadata without the prefix, and of the response headers holding the metadata of the server.
+const metadataHeaderPrefix = "Grpc-Metadata-"
+
+// forwardedHeaders are the HTTP headers forwarded as is to the gRPC server as metadata.
+var forwardedHeaders = []string{"Authorization", "X-Api-Key", "X-Tenant-Id", "X-Request-Id", "Accept-Language"}
+
+var marshalOptions = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// Gateway is an HTTP handler serving the laptop service over HTTP/JSON.
+type Gateway struct {
+	client pb.LaptopServiceClient
+	mux    *http.ServeMux
+}
+
+// Option configures a gateway.
+type Option func(gateway *Gateway)
+
+// WithGraphQL serves the GraphQL endpoint of the laptop service on /graphql.
+func WithGraphQL() Option {
+	return func(gateway *Gateway) {
+		gateway.mux.HandleFunc("/graphql", gateway.serveGraphQL)
+	}
+}
+
+// New returns a new gateway forwarding the requests to the laptop service of the connection.
+func New(conn grpc.ClientConnInterface, opts ...Option) *Gateway {
+	gateway := &Gateway{client: pb.NewLaptopServiceClient(conn), mux: http.NewServeMux()}
+	gateway.mux.HandleFunc("/v1/laptops", gateway.createLaptop)
+	gateway.mux.HandleFunc("/v1/laptops/", gateway.getLaptop)
+	gateway.mux.HandleFunc("/v1/laptops:search", gateway.searchLaptop)
+	gateway.mux.HandleFunc("/openapi.json", serveOpenAPI)
+	gateway.mux.HandleFunc("/swagger/", serveSwaggerUI)
+	for _, opt := range opts {
+		opt(gateway)
+	}
+	return gateway
+}
+
+// ServeHTTP serves the HTTP/JSON requests of the laptop service.
+func (gateway *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gateway.mux.ServeHTTP(w, r)
+}
+
+func (gateway *Gateway) createLaptop(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "cannot read body: %v", err))
+		return
+	}
+	laptop := &pb.Laptop{}
+	err = protojson.Unmarshal(body, laptop)
+	if err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "cannot parse laptop: %v", err))
+		return
+	}
+
+	var header metadata.MD
+	res, err := gateway.client.CreateLaptop(outgoingContext(r), &pb.CreateLaptopRequest{Laptop: laptop}, grpc.Header(&header))
+	writeHeader(w, header)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeMessage(w, res)
+}
+
+func (gateway *Gateway) getLaptop(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/v1/laptops/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	req := &pb.GetLaptopRequest{Id: id}
+	if readMask := r.URL.Query().Get("read_mask"); readMask != "" {
+		req.ReadMask = &fieldmaskpb.FieldMask{Paths: strings.Split(readMask, ",")}
+	}
+
+	var header metadata.MD
+	res, err := gateway.client.GetLaptop(outgoingContext(r), req, grpc.Header(&header))
+	writeHeader(w, header)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeMessage(w, res)
+}
+
+func (gateway *Gateway) searchLaptop(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	req := &pb.SearchLaptopRequest{}
+	err := populateQuery(req, r.URL.Query())
+	if err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "%v", err))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(outgoingContext(r))
+	defer cancel()
+	stream, err := gateway.client.SearchLaptop(ctx, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	header, err := stream.Header()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeHeader(w, header)
+
+	// The status is only known at the end of the stream, so the first result commits to 200 OK
+	// and a later error is sent as the last line.
+	flusher, _ := w.(http.Flusher)
+	started := false
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			if !started {
+				w.Header().Set("Content-Type", "application/json")
+			}
+			return
+		}
+		if err != nil {
+			if !started {
+				writeError(w, err)
+				return
+			}
+			writeLine(w, "error", status.Convert(err).Proto())
+			return
+		}
+
+		if !started {
+			w.Header().Set("Content-Type", "application/json")
+			started = true
+		}
+		if !writeLine(w, "result", res) {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// allowMethod reports whether the request has the method, or replies with 405 Method Not Allowed.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeErrorWithStatus(w, http.StatusMethodNotAllowed,
+		status.Newf(codes.Unimplemented, "method %s is not allowed on %s", r.Method, r.URL.Path))
+	return false
+}
+
+// outgoingContext returns the context of the request with the forwarded headers as metadata.
+func outgoingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for _, name := range forwardedHeaders {
+		if values := r.Header.Values(name); len(values) > 0 {
+			md.Set(name, values...)
+		}
+	}
+	for name, values := range r.Header {
+		if strings.HasPrefix(name, metadataHeaderPrefix) {
+			md.Append(strings.TrimPrefix(name, metadataHeaderPrefix), values...)
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		md.Append("x-forwarded-for", host)
+	}
+	return metadata.NewOutgoingContext(r.Context(), md)
+}
+
+// writeHeader writes the header metadata of the server as Grpc-Metadata- response headers,
+// but the content type of the gRPC response.
+func writeHeader(w http.ResponseWriter, header metadata.MD) {
+	for key, values := range header {
+		if key == "content-type" {
+			continue
+		}
+		name := metadataHeaderPrefix + textproto.CanonicalMIMEHeaderKey(key)
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+}
+
+func writeMessage(w http.ResponseWriter, message proto.Message) {
+	data, err := marshalOptions.Marshal(message)
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "cannot marshal response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// writeLine writes a line of a streamed response with the message under the key,
+// and reports whether it could be written.
+func writeLine(w http.ResponseWriter, key string, message proto.Message) bool {
+	data, err := marshalOptions.Marshal(message)
+	if err != nil {
+		return false
+	}
+	_, err = fmt.Fprintf(w, "{%q:%s}\n", key, data)
+	return err == nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeErrorWithStatus(w, HTTPStatusFromCode(st.Code()), st)
+}
+
+func writeErrorWithStatus(w http.ResponseWriter, httpStatus int, st *status.Status) {
+	data, err := marshalOptions.Marshal(st.Proto())
+	if err != nil {
+		http.Error(w, st.Message(), httpStatus)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(data)
+}
+
+// HTTPStatusFromCode returns the HTTP status of a gRPC status code.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		// 499 Client Closed Request, the nginx convention.
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
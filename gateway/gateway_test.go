@@ -0,0 +1,109 @@
+package gateway_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"grpc_app/gateway"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGateway(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(store, nil, nil))
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	httpServer := httptest.NewServer(gateway.New(conn))
+	t.Cleanup(httpServer.Close)
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = 1000
+	body, err := protojson.Marshal(laptop)
+	require.NoError(t, err)
+	res, err := http.Post(httpServer.URL+"/v1/laptops", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	created := &pb.CreateLaptopResponse{}
+	readMessage(t, res, created)
+	require.Equal(t, laptop.GetId(), created.GetId())
+
+	res, err = http.Post(httpServer.URL+"/v1/laptops", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, res.StatusCode)
+	res.Body.Close()
+
+	res, err = http.Get(httpServer.URL + "/v1/laptops/" + laptop.GetId() + "?read_mask=name,price_usd")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	found := &pb.GetLaptopResponse{}
+	readMessage(t, res, found)
+	require.Equal(t, laptop.GetName(), found.GetLaptop().GetName())
+	require.Empty(t, found.GetLaptop().GetBrand(), "the fields out of the read mask are cleared")
+
+	res, err = http.Get(httpServer.URL + "/v1/laptops/unknown")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	res.Body.Close()
+
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, store.Save(context.Background(), expensive))
+
+	res, err = http.Get(httpServer.URL + "/v1/laptops:search?filter.max_price_usd=2000")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var ids []string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var line struct {
+			Result json.RawMessage `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		result := &pb.SearchLaptopResponse{}
+		require.NoError(t, protojson.Unmarshal(line.Result, result))
+		ids = append(ids, result.GetLaptop().GetId())
+	}
+	res.Body.Close()
+	require.Equal(t, []string{laptop.GetId()}, ids)
+
+	res, err = http.Get(httpServer.URL + "/v1/laptops:search?filter.unknown=1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	res.Body.Close()
+
+	res, err = http.Post(httpServer.URL+"/v1/laptops:search", "application/json", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	res.Body.Close()
+}
+
+func readMessage(t *testing.T, res *http.Response, message proto.Message) {
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, protojson.Unmarshal(data, message))
+}
@@ -0,0 +1,438 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The GraphQL endpoint maps the root fields to the calls of the laptop service:
+//
+//	query    { laptop(id: "...") { name priceUsd } }              GetLaptop
+//	query    { laptops(filter: {maxPriceUsd: 2000}) { id name } }  SearchLaptop
+//	mutation { createLaptop(laptop: {...}) { id } }                 CreateLaptop
+//
+// The arguments are the fields of the requests in the JSON mapping of protobuf, and the fields of the
+// results those of the messages by JSON name, so the types are those of the messages.
+
+type gqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type gqlError struct {
+	Message    string            `json:"message"`
+	Path       []interface{}     `json:"path,omitempty"`
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+type gqlResponse struct {
+	Data   *gqlObject `json:"data,omitempty"`
+	Errors []gqlError `json:"errors,omitempty"`
+}
+
+// gqlObject is an object of the response, whose keys keep the order of the selections.
+type gqlObject []gqlEntry
+
+type gqlEntry struct {
+	key   string
+	value interface{}
+}
+
+func (object gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range object {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// gqlResolver resolves a root field with its arguments into a message or a list of messages.
+type gqlResolver func(gateway *Gateway, ctx context.Context, arguments map[string]interface{}) (interface{}, error)
+
+var gqlRootFields = map[string]map[string]gqlResolver{
+	"query": {
+		"laptop":  (*Gateway).resolveLaptop,
+		"laptops": (*Gateway).resolveLaptops,
+	},
+	"mutation": {
+		"createLaptop": (*Gateway).resolveCreateLaptop,
+	},
+}
+
+func (gateway *Gateway) resolveLaptop(ctx context.Context, arguments map[string]interface{}) (interface{}, error) {
+	req := &pb.GetLaptopRequest{}
+	if err := argumentsToMessage(arguments, req); err != nil {
+		return nil, err
+	}
+	res, err := gateway.client.GetLaptop(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res.GetLaptop(), nil
+}
+
+func (gateway *Gateway) resolveLaptops(ctx context.Context, arguments map[string]interface{}) (interface{}, error) {
+	req := &pb.SearchLaptopRequest{}
+	if err := argumentsToMessage(arguments, req); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := gateway.client.SearchLaptop(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	laptops := []proto.Message{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return laptops, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		laptops = append(laptops, res.GetLaptop())
+	}
+}
+
+func (gateway *Gateway) resolveCreateLaptop(ctx context.Context, arguments map[string]interface{}) (interface{}, error) {
+	req := &pb.CreateLaptopRequest{}
+	if err := argumentsToMessage(arguments, req); err != nil {
+		return nil, err
+	}
+	return gateway.client.CreateLaptop(ctx, req)
+}
+
+// argumentsToMessage sets the fields of the request from the arguments, in the JSON mapping of protobuf.
+func argumentsToMessage(arguments map[string]interface{}, message proto.Message) error {
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "cannot marshal arguments: %v", err)
+	}
+	err = protojson.Unmarshal(data, message)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// serveGraphQL serves the GraphQL requests, with a JSON body or as query parameters of a GET request.
+// The mutations must be posted.
+func (gateway *Gateway) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	req := gqlRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeGraphQL(w, http.StatusBadRequest, gqlResponse{Errors: []gqlError{{Message: "invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	case http.MethodPost:
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			writeGraphQL(w, http.StatusBadRequest, gqlResponse{Errors: []gqlError{{Message: "invalid request: " + err.Error()}}})
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeGraphQL(w, http.StatusMethodNotAllowed, gqlResponse{Errors: []gqlError{{Message: "method " + r.Method + " is not allowed"}}})
+		return
+	}
+
+	operation, err := selectOperation(req)
+	if err != nil {
+		writeGraphQL(w, http.StatusBadRequest, gqlResponse{Errors: []gqlError{{Message: err.Error()}}})
+		return
+	}
+	if operation.kind == "mutation" && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGraphQL(w, http.StatusMethodNotAllowed, gqlResponse{Errors: []gqlError{{Message: "mutations must be posted"}}})
+		return
+	}
+	writeGraphQL(w, http.StatusOK, gateway.execute(outgoingContext(r), operation, req.Variables))
+}
+
+// selectOperation parses the query of the request and returns its operation of the operation name.
+func selectOperation(req gqlRequest) (*gqlOperation, error) {
+	operations, err := parseGraphQL(req.Query)
+	if err != nil {
+		return nil, err
+	}
+	if req.OperationName == "" {
+		if len(operations) > 1 {
+			return nil, fmt.Errorf("operation name is required for a document of %d operations", len(operations))
+		}
+		return operations[0], nil
+	}
+	for _, operation := range operations {
+		if operation.name == req.OperationName {
+			return operation, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", req.OperationName)
+}
+
+// execute resolves the root fields of the operation in order. The error of a field is reported
+// with its path, and its value is null.
+func (gateway *Gateway) execute(ctx context.Context, operation *gqlOperation, variables map[string]interface{}) gqlResponse {
+	resolvers := gqlRootFields[operation.kind]
+	data := gqlObject{}
+	var errors []gqlError
+	for _, field := range operation.selections {
+		key := field.responseKey()
+		value, err := gateway.executeField(ctx, resolvers, operation, field, variables)
+		if err != nil {
+			errors = append(errors, newGQLError(err, key))
+			value = nil
+		}
+		data = append(data, gqlEntry{key: key, value: value})
+	}
+	return gqlResponse{Data: &data, Errors: errors}
+}
+
+func (gateway *Gateway) executeField(
+	ctx context.Context,
+	resolvers map[string]gqlResolver,
+	operation *gqlOperation,
+	field *gqlField,
+	variables map[string]interface{},
+) (interface{}, error) {
+	if field.name == "__typename" {
+		if operation.kind == "mutation" {
+			return "Mutation", nil
+		}
+		return "Query", nil
+	}
+	resolver, ok := resolvers[field.name]
+	if !ok {
+		return nil, fmt.Errorf("field %q is not defined on the %s type", field.name, operation.kind)
+	}
+
+	resolved, err := resolveVariables(field.arguments, operation, variables)
+	if err != nil {
+		return nil, err
+	}
+	arguments, _ := resolved.(map[string]interface{})
+	if arguments == nil {
+		arguments = map[string]interface{}{}
+	}
+	result, err := resolver(gateway, ctx, arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	if messages, ok := result.([]proto.Message); ok {
+		list := make([]interface{}, 0, len(messages))
+		for _, message := range messages {
+			object, err := projectMessage(message.ProtoReflect(), field)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, object)
+		}
+		return list, nil
+	}
+	message := result.(proto.Message)
+	if !message.ProtoReflect().IsValid() {
+		return nil, nil
+	}
+	return projectMessage(message.ProtoReflect(), field)
+}
+
+// resolveVariables returns the value with its variables replaced by their values, or their defaults.
+// The arguments of an undefined variable are left out.
+func resolveVariables(value interface{}, operation *gqlOperation, variables map[string]interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case gqlVariable:
+		for _, definition := range operation.variables {
+			if definition.name == string(value) {
+				if v, ok := variables[definition.name]; ok {
+					return v, nil
+				}
+				return definition.defaultValue, nil
+			}
+		}
+		return nil, fmt.Errorf("variable $%s is not defined", value)
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(value))
+		for key, v := range value {
+			r, err := resolveVariables(v, operation, variables)
+			if err != nil {
+				return nil, err
+			}
+			if r != nil {
+				resolved[key] = r
+			}
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			r, err := resolveVariables(v, operation, variables)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, r)
+		}
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
+
+// projectMessage returns the object of the fields of the message selected by the field.
+func projectMessage(message protoreflect.Message, selection *gqlField) (gqlObject, error) {
+	descriptor := message.Descriptor()
+	if len(selection.selections) == 0 {
+		return nil, fmt.Errorf("field %q of type %s must have a selection of subfields", selection.name, descriptor.Name())
+	}
+
+	object := make(gqlObject, 0, len(selection.selections))
+	for _, subselection := range selection.selections {
+		key := subselection.responseKey()
+		if subselection.name == "__typename" {
+			object = append(object, gqlEntry{key: key, value: string(descriptor.Name())})
+			continue
+		}
+
+		fields := descriptor.Fields()
+		field := fields.ByJSONName(subselection.name)
+		if field == nil {
+			field = fields.ByName(protoreflect.Name(subselection.name))
+		}
+		if field == nil {
+			return nil, fmt.Errorf("field %q is not defined on type %s", subselection.name, descriptor.Name())
+		}
+		value, err := projectField(message, field, subselection)
+		if err != nil {
+			return nil, err
+		}
+		object = append(object, gqlEntry{key: key, value: value})
+	}
+	return object, nil
+}
+
+func projectField(message protoreflect.Message, field protoreflect.FieldDescriptor, selection *gqlField) (interface{}, error) {
+	isObject := field.Message() != nil && !field.IsMap() && wellKnownSchemas[field.Message().FullName()].Type == ""
+	if !isObject && len(selection.selections) > 0 {
+		return nil, fmt.Errorf("field %q is not an object and must not have a selection of subfields", selection.name)
+	}
+
+	switch {
+	case field.IsMap():
+		values := map[string]interface{}{}
+		message.Get(field).Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			values[key.String()] = projectScalar(field.MapValue(), value)
+			return true
+		})
+		return values, nil
+	case field.IsList():
+		list := message.Get(field).List()
+		values := make([]interface{}, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			if !isObject {
+				values = append(values, projectScalar(field, list.Get(i)))
+				continue
+			}
+			object, err := projectMessage(list.Get(i).Message(), selection)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, object)
+		}
+		return values, nil
+	case field.Message() != nil && !message.Has(field):
+		return nil, nil
+	case isObject:
+		return projectMessage(message.Get(field).Message(), selection)
+	default:
+		return projectScalar(field, message.Get(field)), nil
+	}
+}
+
+// projectScalar returns the value of a scalar, an enum or a well-known type in the JSON mapping of protobuf.
+func projectScalar(field protoreflect.FieldDescriptor, value protoreflect.Value) interface{} {
+	switch field.Kind() {
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByNumber(value.Enum()); enumValue != nil {
+			return string(enumValue.Name())
+		}
+		return int32(value.Enum())
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(value.Int(), 10)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(value.Uint(), 10)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f := value.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			// They have no JSON number, like in the JSON mapping of protobuf.
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return f
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(value.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		data, err := protojson.Marshal(value.Message().Interface())
+		if err != nil {
+			return nil
+		}
+		return json.RawMessage(data)
+	default:
+		return value.Interface()
+	}
+}
+
+func newGQLError(err error, path ...interface{}) gqlError {
+	st, ok := status.FromError(err)
+	if !ok {
+		return gqlError{Message: err.Error(), Path: path}
+	}
+	return gqlError{Message: st.Message(), Path: path, Extensions: map[string]string{"code": st.Code().String()}}
+}
+
+func writeGraphQL(w http.ResponseWriter, httpStatus int, res gqlResponse) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		http.Error(w, "cannot marshal response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(data)
+}
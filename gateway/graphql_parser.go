@@ -0,0 +1,459 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The parser reads the executable GraphQL documents the GraphQL endpoint supports:
+// the query and mutation operations, with their variables, and the fields with arguments and aliases.
+// The fragments, directives and subscriptions are rejected.
+
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  []gqlVariableDefinition
+	selections []*gqlField
+}
+
+type gqlVariableDefinition struct {
+	name         string
+	defaultValue interface{}
+}
+
+type gqlField struct {
+	alias      string
+	name       string
+	arguments  map[string]interface{}
+	selections []*gqlField
+}
+
+// responseKey returns the key of the field in the response, its alias if any.
+func (field *gqlField) responseKey() string {
+	if field.alias != "" {
+		return field.alias
+	}
+	return field.name
+}
+
+// gqlVariable is a reference to a variable in the value of an argument.
+type gqlVariable string
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunctuator
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+	pos   int
+}
+
+type gqlParser struct {
+	source string
+	pos    int
+	token  gqlToken
+}
+
+// parseGraphQL parses the operations of a GraphQL document.
+func parseGraphQL(source string) ([]*gqlOperation, error) {
+	parser := &gqlParser{source: source}
+	if err := parser.next(); err != nil {
+		return nil, err
+	}
+
+	var operations []*gqlOperation
+	for parser.token.kind != gqlEOF {
+		operation, err := parser.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("document has no operation")
+	}
+	return operations, nil
+}
+
+func (parser *gqlParser) parseOperation() (*gqlOperation, error) {
+	operation := &gqlOperation{kind: "query"}
+	if parser.token.kind == gqlName {
+		switch parser.token.value {
+		case "query", "mutation":
+			operation.kind = parser.token.value
+		case "fragment", "subscription":
+			return nil, parser.errorf("%ss are not supported", parser.token.value)
+		default:
+			return nil, parser.errorf("unexpected %q", parser.token.value)
+		}
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+		if parser.token.kind == gqlName {
+			operation.name = parser.token.value
+			if err := parser.next(); err != nil {
+				return nil, err
+			}
+		}
+		if parser.is("(") {
+			variables, err := parser.parseVariableDefinitions()
+			if err != nil {
+				return nil, err
+			}
+			operation.variables = variables
+		}
+	}
+
+	selections, err := parser.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	operation.selections = selections
+	return operation, nil
+}
+
+func (parser *gqlParser) parseVariableDefinitions() ([]gqlVariableDefinition, error) {
+	if err := parser.expect("("); err != nil {
+		return nil, err
+	}
+	var definitions []gqlVariableDefinition
+	for !parser.is(")") {
+		if err := parser.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := parser.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if err := parser.expect(":"); err != nil {
+			return nil, err
+		}
+		// The types are only checked by the messages the values are converted to.
+		if err := parser.skipType(); err != nil {
+			return nil, err
+		}
+
+		definition := gqlVariableDefinition{name: name}
+		if parser.is("=") {
+			if err := parser.next(); err != nil {
+				return nil, err
+			}
+			definition.defaultValue, err = parser.parseValue(true)
+			if err != nil {
+				return nil, err
+			}
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions, parser.next()
+}
+
+func (parser *gqlParser) skipType() error {
+	if parser.is("[") {
+		if err := parser.next(); err != nil {
+			return err
+		}
+		if err := parser.skipType(); err != nil {
+			return err
+		}
+		if err := parser.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := parser.parseName(); err != nil {
+		return err
+	}
+	if parser.is("!") {
+		return parser.next()
+	}
+	return nil
+}
+
+func (parser *gqlParser) parseSelectionSet() ([]*gqlField, error) {
+	if err := parser.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []*gqlField
+	for !parser.is("}") {
+		if parser.is("...") {
+			return nil, parser.errorf("fragments are not supported")
+		}
+		field, err := parser.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, parser.errorf("selection set is empty")
+	}
+	return fields, parser.next()
+}
+
+func (parser *gqlParser) parseField() (*gqlField, error) {
+	name, err := parser.parseName()
+	if err != nil {
+		return nil, err
+	}
+	field := &gqlField{name: name}
+	if parser.is(":") {
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+		field.alias = name
+		field.name, err = parser.parseName()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if parser.is("(") {
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+		field.arguments = make(map[string]interface{})
+		for !parser.is(")") {
+			name, err := parser.parseName()
+			if err != nil {
+				return nil, err
+			}
+			if err := parser.expect(":"); err != nil {
+				return nil, err
+			}
+			field.arguments[name], err = parser.parseValue(false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+	}
+	if parser.is("@") {
+		return nil, parser.errorf("directives are not supported")
+	}
+
+	if parser.is("{") {
+		field.selections, err = parser.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+// parseValue parses a value into its JSON form, with the enum values as strings
+// and the variables as gqlVariable, unless constant is set.
+func (parser *gqlParser) parseValue(constant bool) (interface{}, error) {
+	token := parser.token
+	switch {
+	case token.kind == gqlPunctuator && token.value == "$" && !constant:
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+		name, err := parser.parseName()
+		return gqlVariable(name), err
+	case token.kind == gqlPunctuator && token.value == "[":
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !parser.is("]") {
+			value, err := parser.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, parser.next()
+	case token.kind == gqlPunctuator && token.value == "{":
+		if err := parser.next(); err != nil {
+			return nil, err
+		}
+		object := map[string]interface{}{}
+		for !parser.is("}") {
+			name, err := parser.parseName()
+			if err != nil {
+				return nil, err
+			}
+			if err := parser.expect(":"); err != nil {
+				return nil, err
+			}
+			object[name], err = parser.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return object, parser.next()
+	case token.kind == gqlInt || token.kind == gqlFloat:
+		return json.Number(token.value), parser.next()
+	case token.kind == gqlString:
+		return token.value, parser.next()
+	case token.kind == gqlName:
+		var value interface{}
+		switch token.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = token.value
+		}
+		return value, parser.next()
+	default:
+		return nil, parser.errorf("unexpected %s", parser.describe())
+	}
+}
+
+func (parser *gqlParser) parseName() (string, error) {
+	if parser.token.kind != gqlName {
+		return "", parser.errorf("expected name, got %s", parser.describe())
+	}
+	name := parser.token.value
+	return name, parser.next()
+}
+
+// is reports whether the current token is the punctuator.
+func (parser *gqlParser) is(punctuator string) bool {
+	return parser.token.kind == gqlPunctuator && parser.token.value == punctuator
+}
+
+func (parser *gqlParser) expect(punctuator string) error {
+	if !parser.is(punctuator) {
+		return parser.errorf("expected %q, got %s", punctuator, parser.describe())
+	}
+	return parser.next()
+}
+
+func (parser *gqlParser) describe() string {
+	if parser.token.kind == gqlEOF {
+		return "end of document"
+	}
+	return strconv.Quote(parser.token.value)
+}
+
+func (parser *gqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at offset %d: %s", parser.token.pos, fmt.Sprintf(format, args...))
+}
+
+// next reads the next token, skipping the white space, the commas and the comments.
+func (parser *gqlParser) next() error {
+	source := parser.source
+	for parser.pos < len(source) {
+		c := source[parser.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			parser.pos++
+		} else if c == '#' {
+			for parser.pos < len(source) && source[parser.pos] != '\n' {
+				parser.pos++
+			}
+		} else {
+			break
+		}
+	}
+
+	start := parser.pos
+	parser.token = gqlToken{pos: start}
+	if start == len(source) {
+		parser.token.kind = gqlEOF
+		return nil
+	}
+
+	c := source[start]
+	switch {
+	case strings.HasPrefix(source[start:], "..."):
+		parser.pos += 3
+		parser.token.kind, parser.token.value = gqlPunctuator, "..."
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		parser.pos++
+		parser.token.kind, parser.token.value = gqlPunctuator, string(c)
+	case c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z'):
+		for parser.pos < len(source) && isNameByte(source[parser.pos]) {
+			parser.pos++
+		}
+		parser.token.kind, parser.token.value = gqlName, source[start:parser.pos]
+	case c == '-' || ('0' <= c && c <= '9'):
+		return parser.readNumber()
+	case c == '"':
+		return parser.readString()
+	default:
+		r, _ := utf8.DecodeRuneInString(source[start:])
+		return fmt.Errorf("syntax error at offset %d: unexpected character %q", start, r)
+	}
+	return nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func (parser *gqlParser) readNumber() error {
+	source := parser.source
+	start := parser.pos
+	kind := gqlInt
+	if source[parser.pos] == '-' {
+		parser.pos++
+	}
+	for parser.pos < len(source) {
+		c := source[parser.pos]
+		if c == '.' || c == 'e' || c == 'E' || ((c == '+' || c == '-') && (source[parser.pos-1] == 'e' || source[parser.pos-1] == 'E')) {
+			kind = gqlFloat
+		} else if c < '0' || c > '9' {
+			break
+		}
+		parser.pos++
+	}
+
+	value := source[start:parser.pos]
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return fmt.Errorf("syntax error at offset %d: invalid number %q", start, value)
+	}
+	parser.token.kind, parser.token.value = kind, value
+	return nil
+}
+
+// readString reads a string value, whose escapes are those of JSON.
+func (parser *gqlParser) readString() error {
+	source := parser.source
+	start := parser.pos
+	if strings.HasPrefix(source[start:], `"""`) {
+		end := strings.Index(source[start+3:], `"""`)
+		if end < 0 {
+			return fmt.Errorf("syntax error at offset %d: unterminated string", start)
+		}
+		parser.pos = start + 3 + end + 3
+		parser.token.kind, parser.token.value = gqlString, strings.TrimSpace(source[start+3:start+3+end])
+		return nil
+	}
+
+	parser.pos++
+	for parser.pos < len(source) && source[parser.pos] != '"' && source[parser.pos] != '\n' {
+		if source[parser.pos] == '\\' {
+			parser.pos++
+		}
+		parser.pos++
+	}
+	if parser.pos >= len(source) || source[parser.pos] != '"' {
+		return fmt.Errorf("syntax error at offset %d: unterminated string", start)
+	}
+	parser.pos++
+
+	var value string
+	if err := json.Unmarshal([]byte(source[start:parser.pos]), &value); err != nil {
+		return fmt.Errorf("syntax error at offset %d: invalid string: %v", start, err)
+	}
+	parser.token.kind, parser.token.value = gqlString, value
+	return nil
+}
@@ -0,0 +1,120 @@
+package gateway_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"grpc_app/gateway"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestGraphQL(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(store, nil, nil))
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	httpServer := httptest.NewServer(gateway.New(conn, gateway.WithGraphQL()))
+	t.Cleanup(httpServer.Close)
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = 1000
+	laptopJSON, err := protojson.Marshal(laptop)
+	require.NoError(t, err)
+	var laptopInput map[string]interface{}
+	require.NoError(t, json.Unmarshal(laptopJSON, &laptopInput))
+
+	status, res := postGraphQL(t, httpServer.URL, `
+		mutation Create($laptop: LaptopInput!) {
+			created: createLaptop(laptop: $laptop) { id __typename }
+		}`, map[string]interface{}{"laptop": laptopInput})
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"data": {"created": {"id": "`+laptop.GetId()+`", "__typename": "CreateLaptopResponse"}}}`, res)
+
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, store.Save(context.Background(), expensive))
+
+	status, res = postGraphQL(t, httpServer.URL, `{
+		laptop(id: "`+laptop.GetId()+`") { name priceUsd cpu { numberCores } }
+		laptops(filter: {maxPriceUsd: 2000}) { id }
+		missing: laptop(id: "unknown") { id }
+	}`, nil)
+	require.Equal(t, http.StatusOK, status)
+	var body struct {
+		Data struct {
+			Laptop struct {
+				Name     string  `json:"name"`
+				PriceUsd float64 `json:"priceUsd"`
+				CPU      struct {
+					NumberCores int `json:"numberCores"`
+				} `json:"cpu"`
+			} `json:"laptop"`
+			Laptops []struct {
+				ID string `json:"id"`
+			} `json:"laptops"`
+			Missing *struct{} `json:"missing"`
+		} `json:"data"`
+		Errors []struct {
+			Message    string            `json:"message"`
+			Path       []string          `json:"path"`
+			Extensions map[string]string `json:"extensions"`
+		} `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(res), &body))
+	require.Equal(t, laptop.GetName(), body.Data.Laptop.Name)
+	require.Equal(t, 1000.0, body.Data.Laptop.PriceUsd)
+	require.Equal(t, int(laptop.GetCpu().GetNumberCores()), body.Data.Laptop.CPU.NumberCores)
+	require.Len(t, body.Data.Laptops, 1)
+	require.Equal(t, laptop.GetId(), body.Data.Laptops[0].ID)
+	require.Nil(t, body.Data.Missing)
+	require.Len(t, body.Errors, 1)
+	require.Equal(t, []string{"missing"}, body.Errors[0].Path)
+	require.Equal(t, "NotFound", body.Errors[0].Extensions["code"])
+
+	status, res = postGraphQL(t, httpServer.URL, `{ laptop(id: "`+laptop.GetId()+`") { unknown } }`, nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, res, `field \"unknown\" is not defined on type Laptop`)
+
+	status, _ = postGraphQL(t, httpServer.URL, `{ laptop(id: "1") { id `, nil)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	query := url.Values{"query": {`mutation { createLaptop(laptop: {}) { id } }`}}
+	httpRes, err := http.Get(httpServer.URL + "/graphql?" + query.Encode())
+	require.NoError(t, err)
+	httpRes.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, httpRes.StatusCode)
+}
+
+func postGraphQL(t *testing.T, serverURL string, query string, variables map[string]interface{}) (int, string) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+	res, err := http.Post(serverURL+"/graphql", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var data bytes.Buffer
+	_, err = data.ReadFrom(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, data.String()
+}
@@ -0,0 +1,287 @@
+package gateway
+
+import (
+	"encoding/json"
+	"grpc_app/pb"
+	"net/http"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// statusDefinition is the name of the definition of the errors, the google.rpc.Status messages.
+const statusDefinition = "google.rpc.Status"
+
+type openAPIDocument struct {
+	Swagger     string                                  `json:"swagger"`
+	Info        openAPIInfo                             `json:"info"`
+	Consumes    []string                                `json:"consumes"`
+	Produces    []string                                `json:"produces"`
+	Paths       map[string]map[string]*openAPIOperation `json:"paths"`
+	Definitions map[string]*openAPISchema               `json:"definitions"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name             string         `json:"name"`
+	In               string         `json:"in"`
+	Required         bool           `json:"required,omitempty"`
+	Type             string         `json:"type,omitempty"`
+	Format           string         `json:"format,omitempty"`
+	Items            *openAPISchema `json:"items,omitempty"`
+	Enum             []string       `json:"enum,omitempty"`
+	CollectionFormat string         `json:"collectionFormat,omitempty"`
+	Schema           *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPIResponse struct {
+	Description string         `json:"description"`
+	Schema      *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Title                string                    `json:"title,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// wellKnownSchemas are the schemas of the well-known types, which have a special JSON mapping.
+var wellKnownSchemas = map[protoreflect.FullName]openAPISchema{
+	"google.protobuf.Timestamp": {Type: "string", Format: "date-time"},
+	"google.protobuf.Duration":  {Type: "string"},
+	"google.protobuf.FieldMask": {Type: "string"},
+	"google.protobuf.Any":       {Type: "object"},
+	"google.protobuf.Struct":    {Type: "object"},
+}
+
+// OpenAPI returns the OpenAPI 2.0 document of the HTTP API of the gateway, generated from the
+// descriptors of its messages, so the clients of the API can be generated from it.
+func OpenAPI() ([]byte, error) {
+	generator := &openAPIGenerator{definitions: map[string]*openAPISchema{
+		statusDefinition: {
+			Type: "object",
+			Properties: map[string]*openAPISchema{
+				"code":    {Type: "integer", Format: "int32"},
+				"message": {Type: "string"},
+				"details": {Type: "array", Items: &openAPISchema{Type: "object"}},
+			},
+		},
+	}}
+
+	createLaptop := &openAPIOperation{
+		OperationID: "LaptopService_CreateLaptop",
+		Summary:     "Create a new laptop, with a new ID if it has none.",
+		Parameters: []*openAPIParameter{
+			{Name: "body", In: "body", Required: true, Schema: generator.ref((&pb.Laptop{}).ProtoReflect().Descriptor())},
+		},
+		Responses: generator.responses(generator.ref((&pb.CreateLaptopResponse{}).ProtoReflect().Descriptor())),
+	}
+	getLaptop := &openAPIOperation{
+		OperationID: "LaptopService_GetLaptop",
+		Summary:     "Get a laptop by ID, with the fields selected by the read mask.",
+		Parameters: []*openAPIParameter{
+			{Name: "id", In: "path", Required: true, Type: "string"},
+			{Name: "read_mask", In: "query", Type: "string"},
+		},
+		Responses: generator.responses(generator.ref((&pb.GetLaptopResponse{}).ProtoReflect().Descriptor())),
+	}
+	searchLaptop := &openAPIOperation{
+		OperationID: "LaptopService_SearchLaptop",
+		Summary:     "Search for laptops, streamed as one line per result.",
+		Parameters:  generator.queryParameters((&pb.SearchLaptopRequest{}).ProtoReflect().Descriptor(), "", nil),
+		Responses: generator.responses(&openAPISchema{
+			Title: "Stream result of grpc_app.proto.SearchLaptopResponse",
+			Type:  "object",
+			Properties: map[string]*openAPISchema{
+				"result": generator.ref((&pb.SearchLaptopResponse{}).ProtoReflect().Descriptor()),
+				"error":  {Ref: "#/definitions/" + statusDefinition},
+			},
+		}),
+	}
+	for _, operation := range []*openAPIOperation{createLaptop, getLaptop, searchLaptop} {
+		operation.Tags = []string{"LaptopService"}
+	}
+
+	document := openAPIDocument{
+		Swagger:  "2.0",
+		Info:     openAPIInfo{Title: "Laptop service", Version: "v1"},
+		Consumes: []string{"application/json"},
+		Produces: []string{"application/json"},
+		Paths: map[string]map[string]*openAPIOperation{
+			"/v1/laptops":        {"post": createLaptop},
+			"/v1/laptops/{id}":   {"get": getLaptop},
+			"/v1/laptops:search": {"get": searchLaptop},
+		},
+		Definitions: generator.definitions,
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// openAPIGenerator generates the definitions of the messages of an OpenAPI document.
+type openAPIGenerator struct {
+	definitions map[string]*openAPISchema
+}
+
+// responses returns the responses of an operation with the schema of its success, and the errors by default.
+func (generator *openAPIGenerator) responses(schema *openAPISchema) map[string]*openAPIResponse {
+	return map[string]*openAPIResponse{
+		"200":     {Description: "A successful response.", Schema: schema},
+		"default": {Description: "An error response.", Schema: &openAPISchema{Ref: "#/definitions/" + statusDefinition}},
+	}
+}
+
+// ref returns the schema of the message, a reference to its definition unless it's a well-known type.
+func (generator *openAPIGenerator) ref(message protoreflect.MessageDescriptor) *openAPISchema {
+	if schema, ok := wellKnownSchemas[message.FullName()]; ok {
+		return &schema
+	}
+
+	name := string(message.FullName())
+	if _, ok := generator.definitions[name]; !ok {
+		definition := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+		// The definition is added before its fields, so the recursive messages refer to it.
+		generator.definitions[name] = definition
+		fields := message.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			field := fields.Get(i)
+			definition.Properties[field.JSONName()] = generator.fieldSchema(field)
+		}
+	}
+	return &openAPISchema{Ref: "#/definitions/" + name}
+}
+
+func (generator *openAPIGenerator) fieldSchema(field protoreflect.FieldDescriptor) *openAPISchema {
+	if field.IsMap() {
+		return &openAPISchema{Type: "object", AdditionalProperties: generator.valueSchema(field.MapValue())}
+	}
+	schema := generator.valueSchema(field)
+	if field.IsList() {
+		return &openAPISchema{Type: "array", Items: schema}
+	}
+	return schema
+}
+
+// valueSchema returns the schema of a single value of the field, in the JSON mapping of protobuf.
+func (generator *openAPIGenerator) valueSchema(field protoreflect.FieldDescriptor) *openAPISchema {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return generator.ref(field.Message())
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return &openAPISchema{Type: "string", Enum: names}
+	case protoreflect.BoolKind:
+		return &openAPISchema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// The 64-bit integers are JSON strings, since they don't fit in a JavaScript number.
+		return &openAPISchema{Type: "string", Format: "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &openAPISchema{Type: "string", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case protoreflect.BytesKind:
+		return &openAPISchema{Type: "string", Format: "byte"}
+	default:
+		return &openAPISchema{Type: "string"}
+	}
+}
+
+// queryParameters returns the query parameters of the fields of the message that populateQuery can set,
+// under the prefix. The messages already in parents are skipped, so the recursive ones end.
+func (generator *openAPIGenerator) queryParameters(
+	message protoreflect.MessageDescriptor,
+	prefix string,
+	parents []protoreflect.FullName,
+) []*openAPIParameter {
+	parents = append(parents, message.FullName())
+
+	var parameters []*openAPIParameter
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		name := prefix + string(field.Name())
+
+		if field.Message() != nil && field.Message().FullName() != fieldMaskName {
+			if field.IsList() || field.IsMap() || contains(parents, field.Message().FullName()) {
+				continue
+			}
+			parameters = append(parameters, generator.queryParameters(field.Message(), name+".", parents)...)
+			continue
+		}
+
+		schema := generator.valueSchema(field)
+		parameter := &openAPIParameter{Name: name, In: "query", Type: schema.Type, Format: schema.Format, Enum: schema.Enum}
+		if field.IsList() {
+			parameter = &openAPIParameter{Name: name, In: "query", Type: "array", Items: schema, CollectionFormat: "multi"}
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
+
+func contains(names []protoreflect.FullName, name protoreflect.FullName) bool {
+	for _, other := range names {
+		if other == name {
+			return true
+		}
+	}
+	return false
+}
+
+// swaggerUI is the page of the Swagger UI of the OpenAPI document, loaded from a CDN.
+const swaggerUI = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Laptop service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	document, err := OpenAPI()
+	if err != nil {
+		http.Error(w, "cannot generate OpenAPI document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(document)
+}
+
+func serveSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUI))
+}
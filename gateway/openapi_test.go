@@ -0,0 +1,33 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"grpc_app/gateway"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	data, err := gateway.OpenAPI()
+	require.NoError(t, err)
+
+	var document struct {
+		Swagger     string                                `json:"swagger"`
+		Paths       map[string]map[string]json.RawMessage `json:"paths"`
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}
+	require.NoError(t, json.Unmarshal(data, &document))
+	require.Equal(t, "2.0", document.Swagger)
+	require.Contains(t, document.Paths["/v1/laptops"], "post")
+	require.Contains(t, document.Paths["/v1/laptops/{id}"], "get")
+	require.Contains(t, document.Paths["/v1/laptops:search"], "get")
+
+	laptop := document.Definitions["grpc_app.proto.Laptop"]
+	require.Contains(t, laptop.Properties, "priceUsd", "the properties have the JSON names of the fields")
+	require.Contains(t, document.Definitions, "grpc_app.proto.CPU")
+}
@@ -0,0 +1,127 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+var fieldMaskName = (&fieldmaskpb.FieldMask{}).ProtoReflect().Descriptor().FullName()
+
+// populateQuery sets the fields of the message from the query parameters, whose keys are
+// the dot-separated paths of the fields by proto or JSON name, like filter.min_ram.value=8.
+// A repeated field takes every value of its key, and a field mask the comma-separated paths of its value.
+func populateQuery(message proto.Message, query url.Values) error {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		err := populateField(message.ProtoReflect(), strings.Split(key, "."), query[key])
+		if err != nil {
+			return fmt.Errorf("invalid query parameter %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func populateField(message protoreflect.Message, path []string, values []string) error {
+	fields := message.Descriptor().Fields()
+	field := fields.ByName(protoreflect.Name(path[0]))
+	if field == nil {
+		field = fields.ByJSONName(path[0])
+	}
+	if field == nil {
+		return fmt.Errorf("unknown field %q", path[0])
+	}
+
+	if len(path) > 1 {
+		if field.Message() == nil || field.IsList() || field.IsMap() {
+			return fmt.Errorf("field %q has no fields", path[0])
+		}
+		return populateField(message.Mutable(field).Message(), path[1:], values)
+	}
+
+	if field.IsMap() || (field.Message() != nil && field.Message().FullName() != fieldMaskName) {
+		return fmt.Errorf("field %q can't be set from a query parameter, only its fields", path[0])
+	}
+
+	if field.IsList() {
+		list := message.Mutable(field).List()
+		for _, value := range values {
+			v, err := parseValue(field, value)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	}
+
+	if len(values) != 1 {
+		return fmt.Errorf("field %q is not repeated, got %d values", path[0], len(values))
+	}
+	if field.Message() != nil {
+		mask := &fieldmaskpb.FieldMask{Paths: strings.Split(values[0], ",")}
+		message.Set(field, protoreflect.ValueOfMessage(mask.ProtoReflect()))
+		return nil
+	}
+	v, err := parseValue(field, values[0])
+	if err != nil {
+		return err
+	}
+	message.Set(field, v)
+	return nil
+}
+
+// parseValue parses the value of a scalar or enum field, an enum by name or number.
+func parseValue(field protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(value)
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByName(protoreflect.Name(value)); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown value %q of enum %s", value, field.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	default:
+		return protoreflect.Value{}, fmt.Errorf("field %s of kind %s is not supported", field.Name(), field.Kind())
+	}
+}
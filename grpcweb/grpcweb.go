@@ -0,0 +1,222 @@
+// Package grpcweb serves the gRPC-Web calls of the browsers by translating them into gRPC calls
+// of a gRPC server, so the web apps can call it without a proxy like Envoy in front of it.
+// The binary format, application/grpc-web(+proto), and the text format, application/grpc-web-text
+// encoded in base64, are supported, along with the CORS requests of the allowed origins.
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	contentTypeGRPCWeb = "application/grpc-web"
+	contentTypeText    = "application/grpc-web-text"
+
+	// trailerFlag is the flag of the frame of the trailers, at the end of a gRPC-Web response.
+	trailerFlag = 0x80
+)
+
+// exposedHeaders are the response headers the browsers let the web apps of other origins read.
+var exposedHeaders = "grpc-status, grpc-message, grpc-status-details-bin, x-request-id"
+
+// Handler is an HTTP handler serving the gRPC-Web calls with a gRPC server,
+// and the other requests with another handler.
+type Handler struct {
+	server         http.Handler
+	next           http.Handler
+	allowedOrigins map[string]bool
+}
+
+// New returns a new handler serving the gRPC-Web calls with the server, a *grpc.Server,
+// and the other requests with next. The web apps of the allowed origins, or of all the
+// origins if they include "*", may call the server across origins.
+func New(server http.Handler, next http.Handler, allowedOrigins []string) *Handler {
+	handler := &Handler{server: server, next: next, allowedOrigins: make(map[string]bool)}
+	for _, origin := range allowedOrigins {
+		handler.allowedOrigins[origin] = true
+	}
+	return handler
+}
+
+// IsGRPCWebRequest reports whether the request is a gRPC-Web call.
+func IsGRPCWebRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeGRPCWeb)
+}
+
+// isPreflightRequest reports whether the request is the CORS preflight request of a gRPC-Web call.
+func isPreflightRequest(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Access-Control-Request-Method") != "" &&
+		strings.Contains(strings.ToLower(r.Header.Get("Access-Control-Request-Headers")), "x-grpc-web")
+}
+
+// ServeHTTP serves the gRPC-Web calls and their preflight requests with the server, and the others with next.
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case isPreflightRequest(r):
+		if !handler.allowOrigin(w, r) {
+			http.Error(w, "origin is not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+		w.Header().Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.WriteHeader(http.StatusNoContent)
+	case IsGRPCWebRequest(r):
+		if r.Header.Get("Origin") != "" && !handler.allowOrigin(w, r) {
+			http.Error(w, "origin is not allowed", http.StatusForbidden)
+			return
+		}
+		handler.serveGRPCWeb(w, r)
+	default:
+		handler.next.ServeHTTP(w, r)
+	}
+}
+
+// allowOrigin sets the CORS headers of the origin of the request, and reports whether it's allowed.
+func (handler *Handler) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if !handler.allowedOrigins["*"] && !handler.allowedOrigins[origin] {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Add("Vary", "Origin")
+	return true
+}
+
+// serveGRPCWeb serves the gRPC-Web call as a gRPC call over HTTP/2, whose trailers are sent
+// at the end of the body, since the browsers can't read the HTTP trailers.
+func (handler *Handler) serveGRPCWeb(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, contentTypeText)
+
+	req := r.Clone(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2.0"
+	req.Header.Set("Content-Type", "application/grpc"+contentSubtype(contentType))
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	if text {
+		req.Body = io.NopCloser(base64.NewDecoder(base64.StdEncoding, r.Body))
+	}
+
+	responseContentType := contentTypeGRPCWeb + "+proto"
+	if text {
+		responseContentType = contentTypeText + "+proto"
+	}
+	writer := &responseWriter{w: w, header: make(http.Header), contentType: responseContentType, text: text}
+	handler.server.ServeHTTP(writer, req)
+	writer.finish()
+}
+
+// contentSubtype returns the codec suffix of the gRPC-Web content type, e.g. +proto, if any.
+func contentSubtype(contentType string) string {
+	contentType = strings.TrimPrefix(strings.TrimPrefix(contentType, contentTypeText), contentTypeGRPCWeb)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if strings.HasPrefix(contentType, "+") {
+		return contentType
+	}
+	return ""
+}
+
+// responseWriter is the response writer of the gRPC server, which writes its response in the gRPC-Web format.
+type responseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	contentType string
+	text        bool
+
+	wroteHeader bool
+	// buffered are the bytes written since the last flush, encoded at once in the text format.
+	buffered bytes.Buffer
+}
+
+func (writer *responseWriter) Header() http.Header {
+	return writer.header
+}
+
+func (writer *responseWriter) WriteHeader(code int) {
+	if writer.wroteHeader {
+		return
+	}
+	writer.wroteHeader = true
+
+	header := writer.w.Header()
+	for key, values := range writer.header {
+		if key == "Trailer" || strings.HasPrefix(key, http.TrailerPrefix) || isTrailer(writer.header, key) {
+			continue
+		}
+		header[key] = values
+	}
+	header.Set("Content-Type", writer.contentType)
+	if header.Get("Access-Control-Allow-Origin") != "" {
+		header.Set("Access-Control-Expose-Headers", exposedHeaders)
+	}
+	writer.w.WriteHeader(code)
+}
+
+func (writer *responseWriter) Write(p []byte) (int, error) {
+	writer.WriteHeader(http.StatusOK)
+	if writer.text {
+		return writer.buffered.Write(p)
+	}
+	return writer.w.Write(p)
+}
+
+// Flush sends the bytes written so far, in a single base64 chunk in the text format.
+func (writer *responseWriter) Flush() {
+	writer.WriteHeader(http.StatusOK)
+	if writer.text && writer.buffered.Len() > 0 {
+		writer.w.Write([]byte(base64.StdEncoding.EncodeToString(writer.buffered.Bytes())))
+		writer.buffered.Reset()
+	}
+	if flusher, ok := writer.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish writes the trailers of the gRPC response in the last frame of the body.
+func (writer *responseWriter) finish() {
+	var trailers bytes.Buffer
+	keys := make([]string, 0, len(writer.header))
+	for key := range writer.header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, http.TrailerPrefix)
+		if name == key && !isTrailer(writer.header, key) {
+			continue
+		}
+		for _, value := range writer.header[key] {
+			fmt.Fprintf(&trailers, "%s: %s\r\n", strings.ToLower(name), value)
+		}
+	}
+
+	frame := make([]byte, 5, 5+trailers.Len())
+	frame[0] = trailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(trailers.Len()))
+	writer.Write(append(frame, trailers.Bytes()...))
+	writer.Flush()
+}
+
+// isTrailer reports whether the key is declared as a trailer by the Trailer values of the header.
+func isTrailer(header http.Header, key string) bool {
+	for _, values := range header["Trailer"] {
+		for _, trailer := range strings.Split(values, ",") {
+			if http.CanonicalHeaderKey(strings.TrimSpace(trailer)) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package grpcweb_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"grpc_app/grpcweb"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGRPCWeb(t *testing.T) {
+	t.Parallel()
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(service.NewInMemoryLaptopStore(), nil, nil))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	httpServer := httptest.NewServer(grpcweb.New(grpcServer, next, []string{"https://app.example.com"}))
+	t.Cleanup(httpServer.Close)
+
+	laptop := sample.NewLaptop()
+	res := call(t, httpServer.URL+"/grpc_app.proto.LaptopService/CreateLaptop", "application/grpc-web+proto",
+		&pb.CreateLaptopRequest{Laptop: laptop}, false)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "application/grpc-web+proto", res.Header.Get("Content-Type"))
+	messages, trailers := readFrames(t, res.Body, false)
+	require.Len(t, messages, 1)
+	created := &pb.CreateLaptopResponse{}
+	require.NoError(t, proto.Unmarshal(messages[0], created))
+	require.Equal(t, laptop.GetId(), created.GetId())
+	require.Contains(t, trailers, "grpc-status: 0\r\n")
+
+	res = call(t, httpServer.URL+"/grpc_app.proto.LaptopService/GetLaptop", "application/grpc-web-text",
+		&pb.GetLaptopRequest{Id: "unknown"}, true)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "application/grpc-web-text+proto", res.Header.Get("Content-Type"))
+	messages, trailers = readFrames(t, res.Body, true)
+	require.Empty(t, messages)
+	require.Contains(t, trailers, "grpc-status: 5\r\n")
+
+	req, err := http.NewRequest(http.MethodOptions, httpServer.URL+"/grpc_app.proto.LaptopService/CreateLaptop", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web,x-user-agent")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	require.Equal(t, "https://app.example.com", res.Header.Get("Access-Control-Allow-Origin"))
+
+	req.Header.Set("Origin", "https://evil.example.com")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res, err = http.Get(httpServer.URL + "/v1/laptops/" + laptop.GetId())
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusTeapot, res.StatusCode, "the other requests are served by the next handler")
+}
+
+// call sends the request of a gRPC-Web call in a single frame, encoded in base64 if text is set.
+func call(t *testing.T, url string, contentType string, req proto.Message, text bool) *http.Response {
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+	body := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(data)))
+	body = append(body, data...)
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("X-Grpc-Web", "1")
+	res, err := http.DefaultClient.Do(httpReq)
+	require.NoError(t, err)
+	return res
+}
+
+// readFrames returns the messages of the response body, and its trailers,
+// decoding each base64 chunk of the response if text is set.
+func readFrames(t *testing.T, body io.ReadCloser, text bool) ([][]byte, string) {
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	if text {
+		// The chunks are padded apart, but every quantum of four characters decodes on its own.
+		require.Zero(t, len(data)%4)
+		var decoded []byte
+		for i := 0; i < len(data); i += 4 {
+			quantum, err := base64.StdEncoding.DecodeString(string(data[i : i+4]))
+			require.NoError(t, err)
+			decoded = append(decoded, quantum...)
+		}
+		data = decoded
+	}
+
+	var messages [][]byte
+	for len(data) > 0 {
+		require.GreaterOrEqual(t, len(data), 5)
+		length := binary.BigEndian.Uint32(data[1:5])
+		frame := data[5 : 5+length]
+		if data[0]&0x80 != 0 {
+			return messages, string(frame)
+		}
+		messages = append(messages, frame)
+		data = data[5+length:]
+	}
+	t.Fatal("no trailers frame")
+	return nil, ""
+}
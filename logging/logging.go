@@ -0,0 +1,258 @@
+// Package logging provides a leveled logger writing messages with key-value fields,
+// encoded as console lines for the humans or as JSON lines for the log collectors.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level is the severity of a message. A logger writes the messages of its level and above.
+type Level int32
+
+const (
+	// Debug is the level of the details of the calls, e.g. the requests received.
+	Debug Level = iota
+	// Info is the level of the changes of state, e.g. the laptops saved.
+	Info
+	// Warn is the level of the problems the server recovers from, e.g. a call close to its deadline.
+	Warn
+	// Error is the level of the failures, e.g. an event that can't be published.
+	Error
+)
+
+// levelNames are the names of the levels, in the order of the levels.
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// String returns the name of the level.
+func (level Level) String() string {
+	if level < Debug || level > Error {
+		return "level(" + strconv.Itoa(int(level)) + ")"
+	}
+	return levelNames[level]
+}
+
+// ParseLevel returns the level of the name: debug, info, warn or error.
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(level), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, must be debug, info, warn or error", name)
+}
+
+// Format is the encoding of the messages of a logger.
+type Format string
+
+const (
+	// Console writes the messages as lines of the time, the level, the message and the key=value fields.
+	Console Format = "console"
+	// JSON writes the messages as JSON objects of the time, the level, the message and the fields, one per line.
+	JSON Format = "json"
+)
+
+// ParseFormat returns the format of the name: console or json.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case Console, JSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown log format %q, must be console or json", name)
+}
+
+// Logger writes the messages of its level and above with their key-value fields,
+// after the fields of the logger itself. It's safe for concurrent use.
+type Logger struct {
+	output *output
+	fields []interface{}
+}
+
+// output is the destination of a logger, shared with the loggers derived from it by With.
+type output struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	format Format
+	level  int32
+	now    func() time.Time
+}
+
+// New returns a new logger writing the messages of the level and above to the writer in the format.
+func New(writer io.Writer, level Level, format Format) *Logger {
+	return &Logger{output: &output{writer: writer, format: format, level: int32(level), now: time.Now}}
+}
+
+var (
+	defaultMutex  sync.RWMutex
+	defaultLogger = New(os.Stderr, Info, Console)
+)
+
+// Default returns the default logger, which writes the info messages and above to the standard error
+// as console lines unless it's replaced by SetDefault.
+func Default() *Logger {
+	defaultMutex.RLock()
+	defer defaultMutex.RUnlock()
+
+	return defaultLogger
+}
+
+// SetDefault replaces the default logger, e.g. with the logger configured by the flags of a command.
+// The components already given the previous default keep it.
+func SetDefault(logger *Logger) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	defaultLogger = logger
+}
+
+// With returns a logger writing to the same output, at the same level, with the key-value fields
+// added to the fields of the logger.
+func (logger *Logger) With(keyValues ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(logger.fields)+len(keyValues))
+	fields = append(fields, logger.fields...)
+	fields = append(fields, keyValues...)
+	return &Logger{output: logger.output, fields: fields}
+}
+
+// SetLevel sets the level of the logger, and of all the loggers sharing its output,
+// e.g. when the config of the server is reloaded.
+func (logger *Logger) SetLevel(level Level) {
+	atomic.StoreInt32(&logger.output.level, int32(level))
+}
+
+// Level returns the level of the logger.
+func (logger *Logger) Level() Level {
+	return Level(atomic.LoadInt32(&logger.output.level))
+}
+
+// Enabled returns whether the messages of the level are written,
+// e.g. to skip computing the fields of a message that isn't.
+func (logger *Logger) Enabled(level Level) bool {
+	return level >= Level(atomic.LoadInt32(&logger.output.level))
+}
+
+// Debug writes the message at the debug level.
+func (logger *Logger) Debug(message string, keyValues ...interface{}) {
+	logger.Log(Debug, message, keyValues...)
+}
+
+// Info writes the message at the info level.
+func (logger *Logger) Info(message string, keyValues ...interface{}) {
+	logger.Log(Info, message, keyValues...)
+}
+
+// Warn writes the message at the warn level.
+func (logger *Logger) Warn(message string, keyValues ...interface{}) {
+	logger.Log(Warn, message, keyValues...)
+}
+
+// Error writes the message at the error level.
+func (logger *Logger) Error(message string, keyValues ...interface{}) {
+	logger.Log(Error, message, keyValues...)
+}
+
+// Log writes the message at the level with the key-value pairs, if the level is enabled.
+// A key without value is written with the value "MISSING".
+func (logger *Logger) Log(level Level, message string, keyValues ...interface{}) {
+	if !logger.Enabled(level) {
+		return
+	}
+
+	fields := make([]interface{}, 0, len(logger.fields)+len(keyValues)+1)
+	fields = append(fields, logger.fields...)
+	fields = append(fields, keyValues...)
+	if len(fields)%2 == 1 {
+		fields = append(fields, "MISSING")
+	}
+
+	output := logger.output
+	var line []byte
+	if output.format == JSON {
+		line = encodeJSON(output.now(), level, message, fields)
+	} else {
+		line = encodeConsole(output.now(), level, message, fields)
+	}
+
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	_, _ = output.writer.Write(line)
+}
+
+// Writer returns a writer logging every write as a message at the level, without its trailing newline,
+// e.g. to redirect the standard logger of the libraries to the logger.
+func (logger *Logger) Writer(level Level) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		logger.Log(level, strings.TrimSuffix(string(p), "\n"))
+		return len(p), nil
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// encodeConsole encodes the message as a line of the time, the level, the message and
+// the space-separated key=value fields, quoting the values with spaces or quotes.
+func encodeConsole(t time.Time, level Level, message string, fields []interface{}) []byte {
+	var builder strings.Builder
+	builder.WriteString(t.Format("2006-01-02T15:04:05.000Z07:00"))
+	builder.WriteByte(' ')
+	builder.WriteString(strings.ToUpper(level.String()))
+	builder.WriteByte(' ')
+	builder.WriteString(message)
+	for i := 0; i+1 < len(fields); i += 2 {
+		value := fmt.Sprint(fields[i+1])
+		if value == "" || strings.ContainsAny(value, " \"=\n") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&builder, " %v=%s", fields[i], value)
+	}
+	builder.WriteByte('\n')
+	return []byte(builder.String())
+}
+
+// encodeJSON encodes the message as a JSON object of the time, the level, the message and the fields
+// in their order, on one line. The errors and the fmt.Stringer values are written as strings.
+func encodeJSON(t time.Time, level Level, message string, fields []interface{}) []byte {
+	var builder strings.Builder
+	builder.WriteString(`{"time":`)
+	builder.Write(jsonValue(t.Format(time.RFC3339Nano)))
+	builder.WriteString(`,"level":`)
+	builder.Write(jsonValue(level.String()))
+	builder.WriteString(`,"msg":`)
+	builder.Write(jsonValue(message))
+	for i := 0; i+1 < len(fields); i += 2 {
+		builder.WriteByte(',')
+		builder.Write(jsonValue(fmt.Sprint(fields[i])))
+		builder.WriteByte(':')
+		builder.Write(jsonValue(fields[i+1]))
+	}
+	builder.WriteString("}\n")
+	return []byte(builder.String())
+}
+
+// jsonValue returns the JSON encoding of the value, or of its string form if it's an error,
+// a fmt.Stringer or can't be encoded.
+func jsonValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case fmt.Stringer:
+		value = v.String()
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return data
+}
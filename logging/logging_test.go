@@ -0,0 +1,89 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"grpc_app/logging"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerConsole(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	logger := logging.New(&output, logging.Info, logging.Console).With("component", "store")
+
+	logger.Debug("not written")
+	logger.Info("saved laptop", "id", "laptop-1", "brand", "Apple Inc", "duration", 1500*time.Millisecond)
+	logger.Error("cannot save laptop", "error", errors.New(`already "exists"`), "odd")
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	_, line, _ := strings.Cut(lines[0], " ")
+	require.Equal(t, `INFO saved laptop component=store id=laptop-1 brand="Apple Inc" duration=1.5s`, line)
+	_, line, _ = strings.Cut(lines[1], " ")
+	require.Equal(t, `ERROR cannot save laptop component=store error="already \"exists\"" odd=MISSING`, line)
+
+	output.Reset()
+	logger.SetLevel(logging.Debug)
+	logger.Debug("written")
+	require.Contains(t, output.String(), " DEBUG written component=store\n")
+	require.True(t, logger.Enabled(logging.Debug))
+}
+
+func TestLoggerJSON(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	logger := logging.New(&output, logging.Debug, logging.JSON)
+	logger.Warn("slow call", "method", "/a.S/Search", "duration", time.Second, "count", 3)
+
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &message))
+	require.NotEmpty(t, message["time"])
+	delete(message, "time")
+	require.Equal(t, map[string]interface{}{
+		"level":    "warn",
+		"msg":      "slow call",
+		"method":   "/a.S/Search",
+		"duration": "1s",
+		"count":    float64(3),
+	}, message)
+	require.True(t, strings.HasPrefix(output.String(), `{"time":`), "the fields are written in order")
+}
+
+func TestLoggerWriter(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	logger := logging.New(&output, logging.Info, logging.Console)
+	standard := log.New(logger.Writer(logging.Error), "", 0)
+	standard.Printf("cannot start server: %s", "port in use")
+
+	require.Contains(t, output.String(), ` ERROR cannot start server: port in use`+"\n")
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	level, err := logging.ParseLevel("WARN")
+	require.NoError(t, err)
+	require.Equal(t, logging.Warn, level)
+	require.Equal(t, "warn", level.String())
+
+	_, err = logging.ParseLevel("verbose")
+	require.Error(t, err)
+
+	format, err := logging.ParseFormat("json")
+	require.NoError(t, err)
+	require.Equal(t, logging.JSON, format)
+
+	_, err = logging.ParseFormat("xml")
+	require.Error(t, err)
+}
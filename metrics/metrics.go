@@ -0,0 +1,260 @@
+// Package metrics provides minimal counters, gauges and histograms with labels,
+// exposed in the Prometheus text format so they can be scraped by Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the buckets of the latency histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds the metrics exposed together.
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []*metric
+	names   map[string]bool
+}
+
+// NewRegistry returns a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// metric is a metric with all the series of its label values.
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a metric for some label values.
+type series struct {
+	labelValues []string
+	value       float64
+	// counts are the cumulative counts of the buckets of a histogram, and sum the sum of its values.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (registry *Registry) register(name string, help string, kind string, buckets []float64, labels []string) *metric {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if registry.names[name] {
+		panic(fmt.Sprintf("metric %s is already registered", name))
+	}
+	registry.names[name] = true
+
+	m := &metric{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	registry.metrics = append(registry.metrics, m)
+	return m
+}
+
+// with calls update with the series of the label values, created the first time they're used.
+func (m *metric) with(labelValues []string, update func(s *series)) {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", m.name, len(m.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s := m.series[key]
+	if s == nil {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if m.kind == "histogram" {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	update(s)
+}
+
+// Counter is a metric that only goes up, like the number of calls.
+type Counter struct {
+	metric *metric
+}
+
+// NewCounter registers a new counter with the label names.
+func (registry *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	return &Counter{metric: registry.register(name, help, "counter", nil, labels)}
+}
+
+// Inc adds 1 to the counter of the label values.
+func (counter *Counter) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+// Add adds the value, which must not be negative, to the counter of the label values.
+func (counter *Counter) Add(value float64, labelValues ...string) {
+	counter.metric.with(labelValues, func(s *series) {
+		s.value += value
+	})
+}
+
+// Gauge is a metric that goes up and down, like the number of open streams.
+type Gauge struct {
+	metric *metric
+}
+
+// NewGauge registers a new gauge with the label names.
+func (registry *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{metric: registry.register(name, help, "gauge", nil, labels)}
+}
+
+// Add adds the value, which may be negative, to the gauge of the label values.
+func (gauge *Gauge) Add(value float64, labelValues ...string) {
+	gauge.metric.with(labelValues, func(s *series) {
+		s.value += value
+	})
+}
+
+// Set sets the gauge of the label values to the value.
+func (gauge *Gauge) Set(value float64, labelValues ...string) {
+	gauge.metric.with(labelValues, func(s *series) {
+		s.value = value
+	})
+}
+
+// Histogram is a metric that counts the values in buckets, like the latency of the calls.
+type Histogram struct {
+	metric *metric
+}
+
+// NewHistogram registers a new histogram with the sorted upper bounds of its buckets
+// and the label names.
+func (registry *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{metric: registry.register(name, help, "histogram", buckets, labels)}
+}
+
+// Observe adds the value to the histogram of the label values.
+func (histogram *Histogram) Observe(value float64, labelValues ...string) {
+	histogram.metric.with(labelValues, func(s *series) {
+		for i, bound := range histogram.metric.buckets {
+			if value <= bound {
+				s.counts[i]++
+			}
+		}
+		s.count++
+		s.sum += value
+	})
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format, with the series sorted by label values.
+func (registry *Registry) WriteTo(w io.Writer) (int64, error) {
+	registry.mutex.Lock()
+	metrics := append([]*metric(nil), registry.metrics...)
+	registry.mutex.Unlock()
+
+	counter := &countingWriter{w: w}
+	writer := bufio.NewWriter(counter)
+	for _, m := range metrics {
+		m.write(writer)
+	}
+	err := writer.Flush()
+	return counter.n, err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format, so the registry is the handler of /metrics.
+func (registry *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	registry.WriteTo(w)
+}
+
+func (m *metric) write(w *bufio.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escape(m.help, false))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, m.labelPairs(s.labelValues, ""), formatValue(s.value))
+			continue
+		}
+		for i, bound := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.labelPairs(s.labelValues, formatValue(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.labelPairs(s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, m.labelPairs(s.labelValues, ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, m.labelPairs(s.labelValues, ""), s.count)
+	}
+}
+
+// labelPairs formats the labels with their values, and the le label of a histogram bucket if any.
+func (m *metric) labelPairs(labelValues []string, le string) string {
+	pairs := make([]string, 0, len(labelValues)+1)
+	for i, label := range m.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, escape(labelValues[i], true)))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%s"`, le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// escape escapes the backslashes and newlines of a help text or label value, and the quotes of a label value.
+func escape(s string, quotes bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quotes {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	n, err := writer.w.Write(p)
+	writer.n += int64(n)
+	return n, err
+}
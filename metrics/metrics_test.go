@@ -0,0 +1,48 @@
+package metrics_test
+
+import (
+	"bytes"
+	"grpc_app/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	calls := registry.NewCounter("calls_total", "Number of calls.", "method", "code")
+	streams := registry.NewGauge("open_streams", "Number of open streams.")
+	latency := registry.NewHistogram("latency_seconds", "Latency of the calls.", []float64{0.1, 1}, "method")
+
+	calls.Inc("Search", "OK")
+	calls.Add(2, "Create", `Already "Exists"`)
+	streams.Add(3)
+	streams.Add(-1)
+	latency.Observe(0.05, "Search")
+	latency.Observe(0.5, "Search")
+	latency.Observe(5, "Search")
+
+	var output bytes.Buffer
+	_, err := registry.WriteTo(&output)
+	require.NoError(t, err)
+	require.Equal(t, `# HELP calls_total Number of calls.
+# TYPE calls_total counter
+calls_total{method="Create",code="Already \"Exists\""} 2
+calls_total{method="Search",code="OK"} 1
+# HELP open_streams Number of open streams.
+# TYPE open_streams gauge
+open_streams 2
+# HELP latency_seconds Latency of the calls.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="Search",le="0.1"} 1
+latency_seconds_bucket{method="Search",le="1"} 2
+latency_seconds_bucket{method="Search",le="+Inf"} 3
+latency_seconds_sum{method="Search"} 5.55
+latency_seconds_count{method="Search"} 3
+`, output.String())
+
+	require.Panics(t, func() { registry.NewCounter("calls_total", "Again.") })
+	require.Panics(t, func() { calls.Inc("Search") })
+}
@@ -0,0 +1,340 @@
+// Package migration manages the schema of the SQL laptop stores with versioned migrations.
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql
+var files embed.FS
+
+// Dialect is the SQL dialect of a database.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// ErrDirty is returned when a previous migration failed halfway, and the schema
+// must be repaired by hand before forcing its version.
+var ErrDirty = errors.New("schema is dirty")
+
+// Migration is one version of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migrations returns the embedded migrations of the dialect by increasing version.
+func Migrations(dialect Dialect) ([]Migration, error) {
+	dir, err := fs.Sub(files, path.Join("sql", string(dialect)))
+	if err != nil {
+		return nil, fmt.Errorf("unknown dialect %q: %w", dialect, err)
+	}
+	return Load(dir)
+}
+
+// Load reads the migrations from files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql in the root of fsys, by increasing version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version %q", entry.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("cannot read migration %s: %w", entry.Name(), err)
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database, and records the applied versions
+// in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewMigrator returns a new migrator of the database with the embedded migrations of the dialect.
+func NewMigrator(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Latest returns the latest version of the migrations.
+func (migrator *Migrator) Latest() int {
+	if len(migrator.migrations) == 0 {
+		return 0
+	}
+	return migrator.migrations[len(migrator.migrations)-1].Version
+}
+
+// Version returns the current version of the schema, 0 if no migration is applied,
+// and whether the last migration failed halfway.
+func (migrator *Migrator) Version(ctx context.Context) (int, bool, error) {
+	err := migrator.createTable(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return migrator.version(ctx)
+}
+
+// Up applies the migrations that are not applied yet, and returns how many were applied.
+func (migrator *Migrator) Up(ctx context.Context) (int, error) {
+	version, err := migrator.checkVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, migration := range migrator.migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		err := migrator.apply(ctx, migration.Version, migration.Up, true)
+		if err != nil {
+			return applied, fmt.Errorf("cannot apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// Down reverts the last steps migrations, and returns how many were reverted.
+func (migrator *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	version, err := migrator.checkVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(migrator.migrations) - 1; i >= 0 && reverted < steps; i-- {
+		migration := migrator.migrations[i]
+		if migration.Version > version {
+			continue
+		}
+		if migration.Down == "" {
+			return reverted, fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+		}
+
+		err := migrator.apply(ctx, migration.Version, migration.Down, false)
+		if err != nil {
+			return reverted, fmt.Errorf("cannot revert migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		reverted++
+	}
+
+	return reverted, nil
+}
+
+// Force sets the version of the schema and clears the dirty flag,
+// once a failed migration has been repaired by hand.
+func (migrator *Migrator) Force(ctx context.Context, version int) error {
+	err := migrator.createTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := migrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p := migrator.placeholder
+	_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version >= "+p(1), version)
+	if err != nil {
+		return fmt.Errorf("cannot delete versions: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE schema_migrations SET dirty = "+p(1), false)
+	if err != nil {
+		return fmt.Errorf("cannot clear dirty flag: %w", err)
+	}
+	if version > 0 {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO schema_migrations (version, dirty, applied_at) VALUES ("+p(1)+", "+p(2)+", "+p(3)+")",
+			version, false, time.Now().UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("cannot record version: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (migrator *Migrator) checkVersion(ctx context.Context) (int, error) {
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("version %d: %w", version, ErrDirty)
+	}
+	return version, nil
+}
+
+// apply runs the statements of a migration in a transaction. The version is marked
+// dirty first, so that a failure is detected on databases without transactional DDL.
+func (migrator *Migrator) apply(ctx context.Context, version int, statements string, up bool) error {
+	p := migrator.placeholder
+	var err error
+	if up {
+		_, err = migrator.db.ExecContext(
+			ctx,
+			"INSERT INTO schema_migrations (version, dirty, applied_at) VALUES ("+p(1)+", "+p(2)+", "+p(3)+")",
+			version, true, time.Now().UTC(),
+		)
+	} else {
+		_, err = migrator.db.ExecContext(ctx, "UPDATE schema_migrations SET dirty = "+p(1)+" WHERE version = "+p(2), true, version)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot mark version as dirty: %w", err)
+	}
+
+	tx, err := migrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, statements)
+	if err != nil {
+		return err
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "UPDATE schema_migrations SET dirty = "+p(1)+" WHERE version = "+p(2), false, version)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = "+p(1), version)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot record version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (migrator *Migrator) version(ctx context.Context) (int, bool, error) {
+	var version int
+	var dirty bool
+	err := migrator.db.QueryRowContext(
+		ctx,
+		"SELECT version, dirty FROM schema_migrations ORDER BY version DESC LIMIT 1",
+	).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+func (migrator *Migrator) createTable(ctx context.Context) error {
+	_, err := migrator.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL PRIMARY KEY,
+    dirty BOOLEAN NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("cannot create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func (migrator *Migrator) placeholder(n int) string {
+	return migrator.dialect.Placeholder(n)
+}
+
+// Placeholder returns the placeholder of the nth argument of a query, starting at 1.
+func (dialect Dialect) Placeholder(n int) string {
+	if dialect == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// Rebind replaces the ? placeholders of the query with the placeholders of the dialect.
+func (dialect Dialect) Rebind(query string) string {
+	if dialect != Postgres {
+		return query
+	}
+
+	var rebound strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			rebound.WriteString(dialect.Placeholder(n))
+			continue
+		}
+		rebound.WriteRune(r)
+	}
+	return rebound.String()
+}
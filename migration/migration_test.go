@@ -0,0 +1,57 @@
+package migration_test
+
+import (
+	"grpc_app/migration"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"0002_add_index.up.sql":    {Data: []byte("CREATE INDEX i ON t (c);")},
+		"0002_add_index.down.sql":  {Data: []byte("DROP INDEX i;")},
+		"0001_create_table.up.sql": {Data: []byte("CREATE TABLE t (c INT);")},
+		"README.md":                {Data: []byte("ignored")},
+	}
+
+	migrations, err := migration.Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	require.Equal(t, 1, migrations[0].Version)
+	require.Equal(t, "create_table", migrations[0].Name)
+	require.Empty(t, migrations[0].Down)
+	require.Equal(t, 2, migrations[1].Version)
+	require.Equal(t, "DROP INDEX i;", migrations[1].Down)
+
+	_, err = migration.Load(fstest.MapFS{"0001_only_down.down.sql": {Data: []byte("DROP TABLE t;")}})
+	require.Error(t, err)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	t.Parallel()
+
+	for _, dialect := range []migration.Dialect{migration.Postgres, migration.MySQL, migration.SQLite} {
+		migrations, err := migration.Migrations(dialect)
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+		for i, m := range migrations {
+			require.Equal(t, i+1, m.Version)
+			require.NotEmpty(t, m.Down, "migration %d of %s must be reversible", m.Version, dialect)
+		}
+	}
+
+	_, err := migration.Migrations("oracle")
+	require.Error(t, err)
+}
+
+func TestRebind(t *testing.T) {
+	t.Parallel()
+
+	query := "SELECT data FROM laptops WHERE tenant = ? AND price_usd <= ?"
+	require.Equal(t, query, migration.MySQL.Rebind(query))
+	require.Equal(t, "SELECT data FROM laptops WHERE tenant = $1 AND price_usd <= $2", migration.Postgres.Rebind(query))
+}
@@ -0,0 +1,22 @@
+CREATE TABLE laptops (
+    tenant VARCHAR(64) NOT NULL DEFAULT '',
+    id VARCHAR(36) NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    price_usd DOUBLE NOT NULL,
+    release_year INT UNSIGNED NOT NULL,
+    weight_kg DOUBLE NOT NULL,
+    cpu_brand VARCHAR(255) NOT NULL,
+    cpu_name VARCHAR(255) NOT NULL,
+    cpu_number_cores INT UNSIGNED NOT NULL,
+    cpu_number_threads INT UNSIGNED NOT NULL,
+    cpu_min_ghz DOUBLE NOT NULL,
+    cpu_max_ghz DOUBLE NOT NULL,
+    ram_bits BIGINT UNSIGNED NOT NULL,
+    screen_size_inch FLOAT NOT NULL,
+    screen_multitouch BOOLEAN NOT NULL,
+    keyboard_backlit BOOLEAN NOT NULL,
+    data MEDIUMBLOB NOT NULL,
+    updated_at TIMESTAMP(6) NOT NULL,
+    PRIMARY KEY (tenant, id)
+);
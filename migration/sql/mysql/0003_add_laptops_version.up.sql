@@ -0,0 +1 @@
+ALTER TABLE laptops ADD COLUMN version BIGINT UNSIGNED NOT NULL DEFAULT 0;
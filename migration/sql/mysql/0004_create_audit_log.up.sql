@@ -0,0 +1,9 @@
+CREATE TABLE audit_log (
+    seq BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    tenant VARCHAR(64) NOT NULL DEFAULT '',
+    laptop_id VARCHAR(36) NOT NULL,
+    data MEDIUMBLOB NOT NULL,
+    created_at TIMESTAMP(6) NOT NULL,
+    PRIMARY KEY (seq),
+    INDEX audit_log_tenant_laptop_id (tenant, laptop_id, seq)
+);
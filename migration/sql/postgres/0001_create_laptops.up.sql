@@ -0,0 +1,22 @@
+CREATE TABLE laptops (
+    tenant TEXT NOT NULL DEFAULT '',
+    id TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    name TEXT NOT NULL,
+    price_usd DOUBLE PRECISION NOT NULL,
+    release_year INTEGER NOT NULL,
+    weight_kg DOUBLE PRECISION NOT NULL,
+    cpu_brand TEXT NOT NULL,
+    cpu_name TEXT NOT NULL,
+    cpu_number_cores INTEGER NOT NULL,
+    cpu_number_threads INTEGER NOT NULL,
+    cpu_min_ghz DOUBLE PRECISION NOT NULL,
+    cpu_max_ghz DOUBLE PRECISION NOT NULL,
+    ram_bits BIGINT NOT NULL,
+    screen_size_inch REAL NOT NULL,
+    screen_multitouch BOOLEAN NOT NULL,
+    keyboard_backlit BOOLEAN NOT NULL,
+    data BYTEA NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant, id)
+);
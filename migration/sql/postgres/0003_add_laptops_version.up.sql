@@ -0,0 +1 @@
+ALTER TABLE laptops ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+CREATE TABLE audit_log (
+    seq BIGSERIAL PRIMARY KEY,
+    tenant TEXT NOT NULL DEFAULT '',
+    laptop_id TEXT NOT NULL,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX audit_log_tenant_laptop_id ON audit_log (tenant, laptop_id, seq);
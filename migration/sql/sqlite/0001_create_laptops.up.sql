@@ -0,0 +1,22 @@
+CREATE TABLE laptops (
+    tenant TEXT NOT NULL DEFAULT '',
+    id TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    name TEXT NOT NULL,
+    price_usd REAL NOT NULL,
+    release_year INTEGER NOT NULL,
+    weight_kg REAL NOT NULL,
+    cpu_brand TEXT NOT NULL,
+    cpu_name TEXT NOT NULL,
+    cpu_number_cores INTEGER NOT NULL,
+    cpu_number_threads INTEGER NOT NULL,
+    cpu_min_ghz REAL NOT NULL,
+    cpu_max_ghz REAL NOT NULL,
+    ram_bits INTEGER NOT NULL,
+    screen_size_inch REAL NOT NULL,
+    screen_multitouch BOOLEAN NOT NULL,
+    keyboard_backlit BOOLEAN NOT NULL,
+    data BLOB NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (tenant, id)
+);
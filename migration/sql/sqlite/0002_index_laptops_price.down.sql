@@ -0,0 +1 @@
+DROP INDEX laptops_tenant_price_usd;
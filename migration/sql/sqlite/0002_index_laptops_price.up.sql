@@ -0,0 +1 @@
+CREATE INDEX laptops_tenant_price_usd ON laptops (tenant, price_usd);
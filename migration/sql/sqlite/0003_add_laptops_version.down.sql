@@ -0,0 +1 @@
+ALTER TABLE laptops DROP COLUMN version;
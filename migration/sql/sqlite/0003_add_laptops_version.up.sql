@@ -0,0 +1 @@
+ALTER TABLE laptops ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
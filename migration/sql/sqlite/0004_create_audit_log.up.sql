@@ -0,0 +1,8 @@
+CREATE TABLE audit_log (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant TEXT NOT NULL DEFAULT '',
+    laptop_id TEXT NOT NULL,
+    data BLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX audit_log_tenant_laptop_id ON audit_log (tenant, laptop_id, seq);
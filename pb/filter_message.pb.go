@@ -20,21 +20,425 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type Condition_Operator int32
+
+const (
+	Condition_OPERATOR_UNSPECIFIED Condition_Operator = 0
+	Condition_EQ                   Condition_Operator = 1
+	Condition_NE                   Condition_Operator = 2
+	Condition_LT                   Condition_Operator = 3
+	Condition_LE                   Condition_Operator = 4
+	Condition_GT                   Condition_Operator = 5
+	Condition_GE                   Condition_Operator = 6
+)
+
+// Enum value maps for Condition_Operator.
+var (
+	Condition_Operator_name = map[int32]string{
+		0: "OPERATOR_UNSPECIFIED",
+		1: "EQ",
+		2: "NE",
+		3: "LT",
+		4: "LE",
+		5: "GT",
+		6: "GE",
+	}
+	Condition_Operator_value = map[string]int32{
+		"OPERATOR_UNSPECIFIED": 0,
+		"EQ":                   1,
+		"NE":                   2,
+		"LT":                   3,
+		"LE":                   4,
+		"GT":                   5,
+		"GE":                   6,
+	}
+)
+
+func (x Condition_Operator) Enum() *Condition_Operator {
+	p := new(Condition_Operator)
+	*p = x
+	return p
+}
+
+func (x Condition_Operator) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Condition_Operator) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_filter_message_proto_enumTypes[0].Descriptor()
+}
+
+func (Condition_Operator) Type() protoreflect.EnumType {
+	return &file_proto_filter_message_proto_enumTypes[0]
+}
+
+func (x Condition_Operator) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Condition_Operator.Descriptor instead.
+func (Condition_Operator) EnumDescriptor() ([]byte, []int) {
+	return file_proto_filter_message_proto_rawDescGZIP(), []int{0, 0}
+}
+
+type Sort_Field int32
+
+const (
+	Sort_FIELD_UNSPECIFIED Sort_Field = 0
+	Sort_PRICE_USD         Sort_Field = 1
+	Sort_CPU_NUMBER_CORES  Sort_Field = 2
+	Sort_RAM               Sort_Field = 3
+)
+
+// Enum value maps for Sort_Field.
+var (
+	Sort_Field_name = map[int32]string{
+		0: "FIELD_UNSPECIFIED",
+		1: "PRICE_USD",
+		2: "CPU_NUMBER_CORES",
+		3: "RAM",
+	}
+	Sort_Field_value = map[string]int32{
+		"FIELD_UNSPECIFIED": 0,
+		"PRICE_USD":         1,
+		"CPU_NUMBER_CORES":  2,
+		"RAM":               3,
+	}
+)
+
+func (x Sort_Field) Enum() *Sort_Field {
+	p := new(Sort_Field)
+	*p = x
+	return p
+}
+
+func (x Sort_Field) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Sort_Field) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_filter_message_proto_enumTypes[1].Descriptor()
+}
+
+func (Sort_Field) Type() protoreflect.EnumType {
+	return &file_proto_filter_message_proto_enumTypes[1]
+}
+
+func (x Sort_Field) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Sort_Field.Descriptor instead.
+func (Sort_Field) EnumDescriptor() ([]byte, []int) {
+	return file_proto_filter_message_proto_rawDescGZIP(), []int{2, 0}
+}
+
+type Condition struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Field    string             `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Operator Condition_Operator `protobuf:"varint,2,opt,name=operator,proto3,enum=grpc_app.proto.Condition_Operator" json:"operator,omitempty"`
+	// Types that are assignable to Value:
+	//	*Condition_StringValue
+	//	*Condition_NumberValue
+	//	*Condition_BoolValue
+	//	*Condition_MemoryValue
+	Value isCondition_Value `protobuf_oneof:"value"`
+}
+
+func (x *Condition) Reset() {
+	*x = Condition{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_message_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Condition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Condition) ProtoMessage() {}
+
+func (x *Condition) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_message_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Condition.ProtoReflect.Descriptor instead.
+func (*Condition) Descriptor() ([]byte, []int) {
+	return file_proto_filter_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Condition) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *Condition) GetOperator() Condition_Operator {
+	if x != nil {
+		return x.Operator
+	}
+	return Condition_OPERATOR_UNSPECIFIED
+}
+
+func (m *Condition) GetValue() isCondition_Value {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (x *Condition) GetStringValue() string {
+	if x, ok := x.GetValue().(*Condition_StringValue); ok {
+		return x.StringValue
+	}
+	return ""
+}
+
+func (x *Condition) GetNumberValue() float64 {
+	if x, ok := x.GetValue().(*Condition_NumberValue); ok {
+		return x.NumberValue
+	}
+	return 0
+}
+
+func (x *Condition) GetBoolValue() bool {
+	if x, ok := x.GetValue().(*Condition_BoolValue); ok {
+		return x.BoolValue
+	}
+	return false
+}
+
+func (x *Condition) GetMemoryValue() *Memory {
+	if x, ok := x.GetValue().(*Condition_MemoryValue); ok {
+		return x.MemoryValue
+	}
+	return nil
+}
+
+type isCondition_Value interface {
+	isCondition_Value()
+}
+
+type Condition_StringValue struct {
+	StringValue string `protobuf:"bytes,3,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+type Condition_NumberValue struct {
+	NumberValue float64 `protobuf:"fixed64,4,opt,name=number_value,json=numberValue,proto3,oneof"`
+}
+
+type Condition_BoolValue struct {
+	BoolValue bool `protobuf:"varint,5,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type Condition_MemoryValue struct {
+	MemoryValue *Memory `protobuf:"bytes,6,opt,name=memory_value,json=memoryValue,proto3,oneof"`
+}
+
+func (*Condition_StringValue) isCondition_Value() {}
+
+func (*Condition_NumberValue) isCondition_Value() {}
+
+func (*Condition_BoolValue) isCondition_Value() {}
+
+func (*Condition_MemoryValue) isCondition_Value() {}
+
+type Expression struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Node:
+	//	*Expression_Condition
+	//	*Expression_And
+	//	*Expression_Or
+	//	*Expression_Not
+	Node isExpression_Node `protobuf_oneof:"node"`
+}
+
+func (x *Expression) Reset() {
+	*x = Expression{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_message_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Expression) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Expression) ProtoMessage() {}
+
+func (x *Expression) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_message_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Expression.ProtoReflect.Descriptor instead.
+func (*Expression) Descriptor() ([]byte, []int) {
+	return file_proto_filter_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (m *Expression) GetNode() isExpression_Node {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+func (x *Expression) GetCondition() *Condition {
+	if x, ok := x.GetNode().(*Expression_Condition); ok {
+		return x.Condition
+	}
+	return nil
+}
+
+func (x *Expression) GetAnd() *Expression_List {
+	if x, ok := x.GetNode().(*Expression_And); ok {
+		return x.And
+	}
+	return nil
+}
+
+func (x *Expression) GetOr() *Expression_List {
+	if x, ok := x.GetNode().(*Expression_Or); ok {
+		return x.Or
+	}
+	return nil
+}
+
+func (x *Expression) GetNot() *Expression {
+	if x, ok := x.GetNode().(*Expression_Not); ok {
+		return x.Not
+	}
+	return nil
+}
+
+type isExpression_Node interface {
+	isExpression_Node()
+}
+
+type Expression_Condition struct {
+	Condition *Condition `protobuf:"bytes,1,opt,name=condition,proto3,oneof"`
+}
+
+type Expression_And struct {
+	And *Expression_List `protobuf:"bytes,2,opt,name=and,proto3,oneof"`
+}
+
+type Expression_Or struct {
+	Or *Expression_List `protobuf:"bytes,3,opt,name=or,proto3,oneof"`
+}
+
+type Expression_Not struct {
+	Not *Expression `protobuf:"bytes,4,opt,name=not,proto3,oneof"`
+}
+
+func (*Expression_Condition) isExpression_Node() {}
+
+func (*Expression_And) isExpression_Node() {}
+
+func (*Expression_Or) isExpression_Node() {}
+
+func (*Expression_Not) isExpression_Node() {}
+
+type Sort struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Field      Sort_Field `protobuf:"varint,1,opt,name=field,proto3,enum=grpc_app.proto.Sort_Field" json:"field,omitempty"`
+	Descending bool       `protobuf:"varint,2,opt,name=descending,proto3" json:"descending,omitempty"`
+}
+
+func (x *Sort) Reset() {
+	*x = Sort{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_message_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Sort) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sort) ProtoMessage() {}
+
+func (x *Sort) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_message_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sort.ProtoReflect.Descriptor instead.
+func (*Sort) Descriptor() ([]byte, []int) {
+	return file_proto_filter_message_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Sort) GetField() Sort_Field {
+	if x != nil {
+		return x.Field
+	}
+	return Sort_FIELD_UNSPECIFIED
+}
+
+func (x *Sort) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
 type Filter struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	MaxPriceUsd float64 `protobuf:"fixed64,1,opt,name=max_price_usd,json=maxPriceUsd,proto3" json:"max_price_usd,omitempty"`
-	MinCpuCores uint32  `protobuf:"varint,2,opt,name=min_cpu_cores,json=minCpuCores,proto3" json:"min_cpu_cores,omitempty"`
-	MinCpuGhz   float64 `protobuf:"fixed64,3,opt,name=min_cpu_ghz,json=minCpuGhz,proto3" json:"min_cpu_ghz,omitempty"`
-	MinRam      *Memory `protobuf:"bytes,4,opt,name=min_ram,json=minRam,proto3" json:"min_ram,omitempty"`
+	MaxPriceUsd    float64     `protobuf:"fixed64,1,opt,name=max_price_usd,json=maxPriceUsd,proto3" json:"max_price_usd,omitempty"`
+	MinCpuCores    uint32      `protobuf:"varint,2,opt,name=min_cpu_cores,json=minCpuCores,proto3" json:"min_cpu_cores,omitempty"`
+	MinCpuGhz      float64     `protobuf:"fixed64,3,opt,name=min_cpu_ghz,json=minCpuGhz,proto3" json:"min_cpu_ghz,omitempty"`
+	MinRam         *Memory     `protobuf:"bytes,4,opt,name=min_ram,json=minRam,proto3" json:"min_ram,omitempty"`
+	Expression     *Expression `protobuf:"bytes,5,opt,name=expression,proto3" json:"expression,omitempty"`
+	Text           string      `protobuf:"bytes,6,opt,name=text,proto3" json:"text,omitempty"`
+	IncludeDeleted bool        `protobuf:"varint,7,opt,name=include_deleted,json=includeDeleted,proto3" json:"include_deleted,omitempty"`
+	SortBy         []*Sort     `protobuf:"bytes,8,rep,name=sort_by,json=sortBy,proto3" json:"sort_by,omitempty"`
 }
 
 func (x *Filter) Reset() {
 	*x = Filter{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_filter_message_proto_msgTypes[0]
+		mi := &file_proto_filter_message_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -47,7 +451,7 @@ func (x *Filter) String() string {
 func (*Filter) ProtoMessage() {}
 
 func (x *Filter) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filter_message_proto_msgTypes[0]
+	mi := &file_proto_filter_message_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -60,7 +464,7 @@ func (x *Filter) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Filter.ProtoReflect.Descriptor instead.
 func (*Filter) Descriptor() ([]byte, []int) {
-	return file_proto_filter_message_proto_rawDescGZIP(), []int{0}
+	return file_proto_filter_message_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Filter) GetMaxPriceUsd() float64 {
@@ -91,6 +495,81 @@ func (x *Filter) GetMinRam() *Memory {
 	return nil
 }
 
+func (x *Filter) GetExpression() *Expression {
+	if x != nil {
+		return x.Expression
+	}
+	return nil
+}
+
+func (x *Filter) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Filter) GetIncludeDeleted() bool {
+	if x != nil {
+		return x.IncludeDeleted
+	}
+	return false
+}
+
+func (x *Filter) GetSortBy() []*Sort {
+	if x != nil {
+		return x.SortBy
+	}
+	return nil
+}
+
+type Expression_List struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Expressions []*Expression `protobuf:"bytes,1,rep,name=expressions,proto3" json:"expressions,omitempty"`
+}
+
+func (x *Expression_List) Reset() {
+	*x = Expression_List{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_filter_message_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Expression_List) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Expression_List) ProtoMessage() {}
+
+func (x *Expression_List) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filter_message_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Expression_List.ProtoReflect.Descriptor instead.
+func (*Expression_List) Descriptor() ([]byte, []int) {
+	return file_proto_filter_message_proto_rawDescGZIP(), []int{1, 0}
+}
+
+func (x *Expression_List) GetExpressions() []*Expression {
+	if x != nil {
+		return x.Expressions
+	}
+	return nil
+}
+
 var File_proto_filter_message_proto protoreflect.FileDescriptor
 
 var file_proto_filter_message_proto_rawDesc = []byte{
@@ -98,18 +577,82 @@ var file_proto_filter_message_proto_rawDesc = []byte{
 	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0e, 0x67, 0x72,
 	0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1a, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x2f, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa1, 0x01, 0x0a, 0x06, 0x46, 0x69, 0x6c,
-	0x74, 0x65, 0x72, 0x12, 0x22, 0x0a, 0x0d, 0x6d, 0x61, 0x78, 0x5f, 0x70, 0x72, 0x69, 0x63, 0x65,
-	0x5f, 0x75, 0x73, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x6d, 0x61, 0x78, 0x50,
-	0x72, 0x69, 0x63, 0x65, 0x55, 0x73, 0x64, 0x12, 0x22, 0x0a, 0x0d, 0x6d, 0x69, 0x6e, 0x5f, 0x63,
-	0x70, 0x75, 0x5f, 0x63, 0x6f, 0x72, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b,
-	0x6d, 0x69, 0x6e, 0x43, 0x70, 0x75, 0x43, 0x6f, 0x72, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0b, 0x6d,
-	0x69, 0x6e, 0x5f, 0x63, 0x70, 0x75, 0x5f, 0x67, 0x68, 0x7a, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01,
-	0x52, 0x09, 0x6d, 0x69, 0x6e, 0x43, 0x70, 0x75, 0x47, 0x68, 0x7a, 0x12, 0x2f, 0x0a, 0x07, 0x6d,
-	0x69, 0x6e, 0x5f, 0x72, 0x61, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x67,
-	0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4d, 0x65,
-	0x6d, 0x6f, 0x72, 0x79, 0x52, 0x06, 0x6d, 0x69, 0x6e, 0x52, 0x61, 0x6d, 0x42, 0x07, 0x5a, 0x05,
-	0x2e, 0x2f, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xe8, 0x02, 0x0a, 0x09, 0x43, 0x6f, 0x6e,
+	0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x3e, 0x0a, 0x08,
+	0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x22,
+	0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
+	0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74,
+	0x6f, 0x72, 0x52, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x23, 0x0a, 0x0c,
+	0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x48, 0x00, 0x52, 0x0b, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x12, 0x23, 0x0a, 0x0c, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x5f, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x0b, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1f, 0x0a, 0x0a, 0x62, 0x6f, 0x6f, 0x6c, 0x5f, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x09, 0x62, 0x6f,
+	0x6f, 0x6c, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x3b, 0x0a, 0x0c, 0x6d, 0x65, 0x6d, 0x6f, 0x72,
+	0x79, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e,
+	0x67, 0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4d,
+	0x65, 0x6d, 0x6f, 0x72, 0x79, 0x48, 0x00, 0x52, 0x0b, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x22, 0x54, 0x0a, 0x08, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x6f, 0x72,
+	0x12, 0x18, 0x0a, 0x14, 0x4f, 0x50, 0x45, 0x52, 0x41, 0x54, 0x4f, 0x52, 0x5f, 0x55, 0x4e, 0x53,
+	0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x06, 0x0a, 0x02, 0x45, 0x51,
+	0x10, 0x01, 0x12, 0x06, 0x0a, 0x02, 0x4e, 0x45, 0x10, 0x02, 0x12, 0x06, 0x0a, 0x02, 0x4c, 0x54,
+	0x10, 0x03, 0x12, 0x06, 0x0a, 0x02, 0x4c, 0x45, 0x10, 0x04, 0x12, 0x06, 0x0a, 0x02, 0x47, 0x54,
+	0x10, 0x05, 0x12, 0x06, 0x0a, 0x02, 0x47, 0x45, 0x10, 0x06, 0x42, 0x07, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x22, 0xad, 0x02, 0x0a, 0x0a, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x39, 0x0a, 0x09, 0x63, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e,
+	0x48, 0x00, 0x52, 0x09, 0x63, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x33, 0x0a,
+	0x03, 0x61, 0x6e, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x67, 0x72, 0x70,
+	0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x45, 0x78, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x48, 0x00, 0x52, 0x03, 0x61,
+	0x6e, 0x64, 0x12, 0x31, 0x0a, 0x02, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f,
+	0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
+	0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x48,
+	0x00, 0x52, 0x02, 0x6f, 0x72, 0x12, 0x2e, 0x0a, 0x03, 0x6e, 0x6f, 0x74, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x48, 0x00,
+	0x52, 0x03, 0x6e, 0x6f, 0x74, 0x1a, 0x44, 0x0a, 0x04, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x3c, 0x0a,
+	0x0b, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x0b,
+	0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x42, 0x06, 0x0a, 0x04, 0x6e,
+	0x6f, 0x64, 0x65, 0x22, 0xa6, 0x01, 0x0a, 0x04, 0x53, 0x6f, 0x72, 0x74, 0x12, 0x30, 0x0a, 0x05,
+	0x66, 0x69, 0x65, 0x6c, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x67, 0x72,
+	0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x53, 0x6f, 0x72,
+	0x74, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x1e,
+	0x0a, 0x0a, 0x64, 0x65, 0x73, 0x63, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x0a, 0x64, 0x65, 0x73, 0x63, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x22, 0x4c,
+	0x0a, 0x05, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x15, 0x0a, 0x11, 0x46, 0x49, 0x45, 0x4c, 0x44,
+	0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0d,
+	0x0a, 0x09, 0x50, 0x52, 0x49, 0x43, 0x45, 0x5f, 0x55, 0x53, 0x44, 0x10, 0x01, 0x12, 0x14, 0x0a,
+	0x10, 0x43, 0x50, 0x55, 0x5f, 0x4e, 0x55, 0x4d, 0x42, 0x45, 0x52, 0x5f, 0x43, 0x4f, 0x52, 0x45,
+	0x53, 0x10, 0x02, 0x12, 0x07, 0x0a, 0x03, 0x52, 0x41, 0x4d, 0x10, 0x03, 0x22, 0xc9, 0x02, 0x0a,
+	0x06, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12, 0x22, 0x0a, 0x0d, 0x6d, 0x61, 0x78, 0x5f, 0x70,
+	0x72, 0x69, 0x63, 0x65, 0x5f, 0x75, 0x73, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b,
+	0x6d, 0x61, 0x78, 0x50, 0x72, 0x69, 0x63, 0x65, 0x55, 0x73, 0x64, 0x12, 0x22, 0x0a, 0x0d, 0x6d,
+	0x69, 0x6e, 0x5f, 0x63, 0x70, 0x75, 0x5f, 0x63, 0x6f, 0x72, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x0b, 0x6d, 0x69, 0x6e, 0x43, 0x70, 0x75, 0x43, 0x6f, 0x72, 0x65, 0x73, 0x12,
+	0x1e, 0x0a, 0x0b, 0x6d, 0x69, 0x6e, 0x5f, 0x63, 0x70, 0x75, 0x5f, 0x67, 0x68, 0x7a, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x6d, 0x69, 0x6e, 0x43, 0x70, 0x75, 0x47, 0x68, 0x7a, 0x12,
+	0x2f, 0x0a, 0x07, 0x6d, 0x69, 0x6e, 0x5f, 0x72, 0x61, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x16, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2e, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x52, 0x06, 0x6d, 0x69, 0x6e, 0x52, 0x61, 0x6d,
+	0x12, 0x3a, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x52, 0x0a, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04,
+	0x74, 0x65, 0x78, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x65, 0x78, 0x74,
+	0x12, 0x27, 0x0a, 0x0f, 0x69, 0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x5f, 0x64, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x69, 0x6e, 0x63, 0x6c, 0x75,
+	0x64, 0x65, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x12, 0x2d, 0x0a, 0x07, 0x73, 0x6f, 0x72,
+	0x74, 0x5f, 0x62, 0x79, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x72, 0x70,
+	0x63, 0x5f, 0x61, 0x70, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x53, 0x6f, 0x72, 0x74,
+	0x52, 0x06, 0x73, 0x6f, 0x72, 0x74, 0x42, 0x79, 0x42, 0x10, 0x5a, 0x0e, 0x67, 0x72, 0x70, 0x63,
+	0x5f, 0x61, 0x70, 0x70, 0x2f, 0x70, 0x62, 0x3b, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
 }
 
 var (
@@ -124,18 +667,35 @@ func file_proto_filter_message_proto_rawDescGZIP() []byte {
 	return file_proto_filter_message_proto_rawDescData
 }
 
-var file_proto_filter_message_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_proto_filter_message_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_filter_message_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_proto_filter_message_proto_goTypes = []interface{}{
-	(*Filter)(nil), // 0: grpc_app.proto.Filter
-	(*Memory)(nil), // 1: grpc_app.proto.Memory
+	(Condition_Operator)(0), // 0: grpc_app.proto.Condition.Operator
+	(Sort_Field)(0),         // 1: grpc_app.proto.Sort.Field
+	(*Condition)(nil),       // 2: grpc_app.proto.Condition
+	(*Expression)(nil),      // 3: grpc_app.proto.Expression
+	(*Sort)(nil),            // 4: grpc_app.proto.Sort
+	(*Filter)(nil),          // 5: grpc_app.proto.Filter
+	(*Expression_List)(nil), // 6: grpc_app.proto.Expression.List
+	(*Memory)(nil),          // 7: grpc_app.proto.Memory
 }
 var file_proto_filter_message_proto_depIdxs = []int32{
-	1, // 0: grpc_app.proto.Filter.min_ram:type_name -> grpc_app.proto.Memory
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: grpc_app.proto.Condition.operator:type_name -> grpc_app.proto.Condition.Operator
+	7,  // 1: grpc_app.proto.Condition.memory_value:type_name -> grpc_app.proto.Memory
+	2,  // 2: grpc_app.proto.Expression.condition:type_name -> grpc_app.proto.Condition
+	6,  // 3: grpc_app.proto.Expression.and:type_name -> grpc_app.proto.Expression.List
+	6,  // 4: grpc_app.proto.Expression.or:type_name -> grpc_app.proto.Expression.List
+	3,  // 5: grpc_app.proto.Expression.not:type_name -> grpc_app.proto.Expression
+	1,  // 6: grpc_app.proto.Sort.field:type_name -> grpc_app.proto.Sort.Field
+	7,  // 7: grpc_app.proto.Filter.min_ram:type_name -> grpc_app.proto.Memory
+	3,  // 8: grpc_app.proto.Filter.expression:type_name -> grpc_app.proto.Expression
+	4,  // 9: grpc_app.proto.Filter.sort_by:type_name -> grpc_app.proto.Sort
+	3,  // 10: grpc_app.proto.Expression.List.expressions:type_name -> grpc_app.proto.Expression
+	11, // [11:11] is the sub-list for method output_type
+	11, // [11:11] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_proto_filter_message_proto_init() }
@@ -146,6 +706,42 @@ func file_proto_filter_message_proto_init() {
 	file_proto_memory_message_proto_init()
 	if !protoimpl.UnsafeEnabled {
 		file_proto_filter_message_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Condition); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_message_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Expression); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_message_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Sort); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_filter_message_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Filter); i {
 			case 0:
 				return &v.state
@@ -157,19 +753,44 @@ func file_proto_filter_message_proto_init() {
 				return nil
 			}
 		}
+		file_proto_filter_message_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Expression_List); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_proto_filter_message_proto_msgTypes[0].OneofWrappers = []interface{}{
+		(*Condition_StringValue)(nil),
+		(*Condition_NumberValue)(nil),
+		(*Condition_BoolValue)(nil),
+		(*Condition_MemoryValue)(nil),
+	}
+	file_proto_filter_message_proto_msgTypes[1].OneofWrappers = []interface{}{
+		(*Expression_Condition)(nil),
+		(*Expression_And)(nil),
+		(*Expression_Or)(nil),
+		(*Expression_Not)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_proto_filter_message_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   1,
+			NumEnums:      2,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_proto_filter_message_proto_goTypes,
 		DependencyIndexes: file_proto_filter_message_proto_depIdxs,
+		EnumInfos:         file_proto_filter_message_proto_enumTypes,
 		MessageInfos:      file_proto_filter_message_proto_msgTypes,
 	}.Build()
 	File_proto_filter_message_proto = out.File
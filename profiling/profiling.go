@@ -0,0 +1,83 @@
+// Package profiling serves the runtime debug endpoints of a server: the pprof profiles, the expvar variables
+// and the garbage collector statistics. They reveal the internals of the process, so they should be served
+// on a port of their own, only reachable by the operators.
+package profiling
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// recentPauses is the number of the last garbage collection pauses in the GC statistics.
+const recentPauses = 16
+
+// GCStats are the statistics of the garbage collector and the heap served on /debug/gc.
+type GCStats struct {
+	NumGC        int64     `json:"num_gc"`
+	LastGC       time.Time `json:"last_gc"`
+	PauseTotalMS float64   `json:"pause_total_ms"`
+	// RecentPausesMS are the last pauses, most recent first.
+	RecentPausesMS []float64 `json:"recent_pauses_ms"`
+	HeapAllocBytes uint64    `json:"heap_alloc_bytes"`
+	HeapObjects    uint64    `json:"heap_objects"`
+	NextGCBytes    uint64    `json:"next_gc_bytes"`
+	Goroutines     int       `json:"goroutines"`
+}
+
+// ReadGCStats returns the current statistics of the garbage collector and the heap.
+// It stops the world briefly to read the memory statistics.
+func ReadGCStats() GCStats {
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	stats := GCStats{
+		NumGC:          gc.NumGC,
+		LastGC:         gc.LastGC,
+		PauseTotalMS:   milliseconds(gc.PauseTotal),
+		RecentPausesMS: make([]float64, 0, recentPauses),
+		HeapAllocBytes: memory.HeapAlloc,
+		HeapObjects:    memory.HeapObjects,
+		NextGCBytes:    memory.NextGC,
+		Goroutines:     runtime.NumGoroutine(),
+	}
+	for i, pause := range gc.Pause {
+		if i == recentPauses {
+			break
+		}
+		stats.RecentPausesMS = append(stats.RecentPausesMS, milliseconds(pause))
+	}
+	return stats
+}
+
+// NewHandler returns a handler serving the pprof profiles on /debug/pprof/, e.g. /debug/pprof/profile
+// for a CPU profile and /debug/pprof/heap for the memory, the expvar variables on /debug/vars,
+// and the GCStats as JSON on /debug/gc.
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/gc", serveGCStats)
+	return mux
+}
+
+func serveGCStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(ReadGCStats())
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,49 @@
+package profiling_test
+
+import (
+	"encoding/json"
+	"grpc_app/profiling"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	httpServer := httptest.NewServer(profiling.NewHandler())
+	t.Cleanup(httpServer.Close)
+	get := func(path string) (*http.Response, []byte) {
+		res, err := http.Get(httpServer.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+
+	res, body := get("/debug/pprof/")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Contains(t, string(body), "heap")
+
+	res, body = get("/debug/pprof/goroutine?debug=1")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Contains(t, string(body), "goroutine profile")
+
+	res, body = get("/debug/vars")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Contains(t, string(body), `"memstats"`)
+
+	runtime.GC()
+	res, body = get("/debug/gc")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var stats profiling.GCStats
+	require.NoError(t, json.Unmarshal(body, &stats))
+	require.Positive(t, stats.NumGC)
+	require.Positive(t, stats.Goroutines)
+	require.NotEmpty(t, stats.RecentPausesMS)
+}
@@ -4,6 +4,7 @@ import (
 	"grpc_app/pb"
 	"grpc_app/sample"
 	"grpc_app/serializer"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -13,8 +14,8 @@ import (
 func TestFileSerializer(t *testing.T) {
 	t.Parallel()
 
-	binaryFile := "../tmp/laptop.bin"
-	jsonFile := "../tmp/laptop.json"
+	binaryFile := filepath.Join(t.TempDir(), "laptop.bin")
+	jsonFile := filepath.Join(t.TempDir(), "laptop.json")
 
 	// Test proto message to binary.
 	laptop1 := sample.NewLaptop()
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"grpc_app/logging"
+	"grpc_app/pb"
+	"net/url"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserDataEraser removes or anonymizes the data of a user in one store.
+type UserDataEraser interface {
+	// EraseUserData erases the data of the user and returns the number of erased records.
+	EraseUserData(username string) (int, error)
+}
+
+// SchemaMigrator reports the version of the schema of a database store.
+type SchemaMigrator interface {
+	// Version returns the current version of the schema, and whether a migration failed halfway.
+	Version(ctx context.Context) (int, bool, error)
+	// Latest returns the version of the last known migration.
+	Latest() int
+}
+
+// AdminServer is the server that provides administrative RPCs.
+type AdminServer struct {
+	pb.UnimplementedAdminServiceServer
+	signingKey string
+	erasers    map[string]UserDataEraser
+	migrator   SchemaMigrator
+	// laptopStore is the store of the laptops reindexed by ReindexLaptops, if any.
+	laptopStore LaptopStore
+	// softDeleteStore is the store of the laptops purged by PurgeDeletedLaptops, if any.
+	softDeleteStore *SoftDeleteLaptopStore
+	// auditSink is the audit log queried by ListAuditEntries, if any.
+	auditSink AuditSink
+	// webhookStore is the store of the webhooks managed by the webhook RPCs, if any.
+	webhookStore WebhookStore
+	startedAt    time.Time
+	logger       *logging.Logger
+}
+
+// AdminServerOption configures the optional features of an AdminServer.
+type AdminServerOption func(server *AdminServer)
+
+// WithSchemaMigrator enables the schema version RPC for the database of the migrator.
+func WithSchemaMigrator(migrator SchemaMigrator) AdminServerOption {
+	return func(server *AdminServer) {
+		server.migrator = migrator
+	}
+}
+
+// WithLaptopStore enables the reindex RPC for the laptop store.
+func WithLaptopStore(store LaptopStore) AdminServerOption {
+	return func(server *AdminServer) {
+		server.laptopStore = store
+	}
+}
+
+// WithSoftDeleteStore enables the purge RPC for the deleted laptops of the store.
+func WithSoftDeleteStore(store *SoftDeleteLaptopStore) AdminServerOption {
+	return func(server *AdminServer) {
+		server.softDeleteStore = store
+	}
+}
+
+// WithAuditSink enables the audit entries RPC for the audit log of the sink.
+func WithAuditSink(sink AuditSink) AdminServerOption {
+	return func(server *AdminServer) {
+		server.auditSink = sink
+	}
+}
+
+// WithWebhookStore enables the webhook RPCs for the webhooks of the store.
+func WithWebhookStore(store WebhookStore) AdminServerOption {
+	return func(server *AdminServer) {
+		server.webhookStore = store
+	}
+}
+
+// WithAdminLogger replaces the logger of the admin server, the default logger by default.
+func WithAdminLogger(logger *logging.Logger) AdminServerOption {
+	return func(server *AdminServer) {
+		server.logger = logger
+	}
+}
+
+// NewAdminServer returns a new admin server. The erasers are keyed by store name,
+// and the erasure reports are signed with the signing key.
+func NewAdminServer(signingKey string, erasers map[string]UserDataEraser, options ...AdminServerOption) *AdminServer {
+	server := &AdminServer{signingKey: signingKey, erasers: erasers, startedAt: time.Now(), logger: logging.Default()}
+	for _, option := range options {
+		option(server)
+	}
+	return server
+}
+
+// EraseUserData is a unary RPC to erase all data of a user across the stores,
+// and returns a signed erasure report.
+func (server *AdminServer) EraseUserData(
+	ctx context.Context,
+	req *pb.EraseUserDataRequest,
+) (*pb.EraseUserDataResponse, error) {
+	username := req.GetUsername()
+	if username == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "username is required")
+	}
+	server.logger.Debug("receive an erase-user-data request", "username", username)
+
+	names := make([]string, 0, len(server.erasers))
+	for name := range server.erasers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := &pb.ErasureReport{Username: username}
+	for _, name := range names {
+		if err := contextError(ctx); err != nil {
+			return nil, err
+		}
+
+		count, err := server.erasers[name].EraseUserData(username)
+		if err != nil {
+			return nil, logError(status.Errorf(codes.Internal, "cannot erase user data from %s: %v", name, err))
+		}
+
+		report.Records = append(report.Records, &pb.ErasedRecords{Store: name, Count: uint32(count)})
+	}
+	report.ErasedAt = timestamppb.Now()
+
+	signature, err := SignErasureReport(server.signingKey, report)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot sign erasure report: %v", err)
+	}
+
+	server.logger.Info("erased user data", "username", username, "records", report.GetRecords())
+	return &pb.EraseUserDataResponse{Report: report, Signature: signature}, nil
+}
+
+// GetSchemaVersion is a unary RPC to get the version of the database schema.
+func (server *AdminServer) GetSchemaVersion(
+	ctx context.Context,
+	req *pb.GetSchemaVersionRequest,
+) (*pb.GetSchemaVersionResponse, error) {
+	if server.migrator == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "no database store is configured")
+	}
+
+	version, dirty, err := server.migrator.Version(ctx)
+	if err != nil {
+		return nil, logError(status.Errorf(codes.Internal, "cannot get schema version: %v", err))
+	}
+
+	return &pb.GetSchemaVersionResponse{
+		Version:       int64(version),
+		LatestVersion: int64(server.migrator.Latest()),
+		Dirty:         dirty,
+	}, nil
+}
+
+// PurgeDeletedLaptops is a unary RPC to delete the soft-deleted laptops whose retention window
+// has passed at once, or all of them, instead of waiting for the next purge.
+func (server *AdminServer) PurgeDeletedLaptops(
+	ctx context.Context,
+	req *pb.PurgeDeletedLaptopsRequest,
+) (*pb.PurgeDeletedLaptopsResponse, error) {
+	if server.softDeleteStore == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "the deleted laptops are not kept")
+	}
+	server.logger.Debug("receive a purge-deleted-laptops request", "all", req.GetAll())
+
+	purge := server.softDeleteStore.Purge
+	if req.GetAll() {
+		purge = server.softDeleteStore.PurgeAll
+	}
+	purged, err := purge(ctx)
+	if err != nil {
+		return nil, logError(status.Errorf(storeErrorCode(err), "cannot purge deleted laptops: %v", err))
+	}
+
+	server.logger.Info("purged deleted laptops", "purged", purged)
+	return &pb.PurgeDeletedLaptopsResponse{Purged: uint32(purged)}, nil
+}
+
+// ReindexLaptops is a unary RPC to rebuild the search indexes of the laptop store.
+func (server *AdminServer) ReindexLaptops(
+	ctx context.Context,
+	req *pb.ReindexLaptopsRequest,
+) (*pb.ReindexLaptopsResponse, error) {
+	if server.laptopStore == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "no laptop store is configured")
+	}
+	server.logger.Debug("receive a reindex-laptops request")
+
+	indexed, err := reindexLaptops(ctx, server.laptopStore)
+	if errors.Is(err, ErrNotReindexable) {
+		return nil, status.Errorf(codes.Unimplemented, "cannot reindex laptops: %v", err)
+	}
+	if err != nil {
+		return nil, logError(status.Errorf(storeErrorCode(err), "cannot reindex laptops: %v", err))
+	}
+
+	server.logger.Info("reindexed laptops", "indexed", indexed)
+	return &pb.ReindexLaptopsResponse{Indexed: uint32(indexed)}, nil
+}
+
+// GetServerStats is a unary RPC to get the uptime and the runtime statistics of the server.
+func (server *AdminServer) GetServerStats(
+	ctx context.Context,
+	req *pb.GetServerStatsRequest,
+) (*pb.GetServerStatsResponse, error) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	return &pb.GetServerStatsResponse{
+		StartedAt:      timestamppb.New(server.startedAt),
+		Uptime:         durationpb.New(time.Since(server.startedAt)),
+		Goroutines:     uint32(runtime.NumGoroutine()),
+		HeapAllocBytes: memStats.HeapAlloc,
+		SysBytes:       memStats.Sys,
+		NumGc:          memStats.NumGC,
+	}, nil
+}
+
+// ListAuditEntries is a unary RPC to list the audit entries of a laptop of the tenant of the caller.
+func (server *AdminServer) ListAuditEntries(
+	ctx context.Context,
+	req *pb.ListAuditEntriesRequest,
+) (*pb.ListAuditEntriesResponse, error) {
+	if server.auditSink == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "the audit log is not enabled")
+	}
+	if req.GetLaptopId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is required")
+	}
+	server.logger.Debug("receive a list-audit-entries request", "laptop_id", req.GetLaptopId())
+
+	entries, err := server.auditSink.Query(ctx, TenantFromContext(ctx), req.GetLaptopId())
+	if err != nil {
+		return nil, logError(status.Errorf(codes.Internal, "cannot query audit log: %v", err))
+	}
+	return &pb.ListAuditEntriesResponse{Entries: entries}, nil
+}
+
+// CreateWebhook is a unary RPC to register an HTTP callback the events of the laptops are posted to.
+// The secret of the webhook is generated if it's not given, and only returned by this RPC.
+func (server *AdminServer) CreateWebhook(
+	ctx context.Context,
+	req *pb.CreateWebhookRequest,
+) (*pb.CreateWebhookResponse, error) {
+	if server.webhookStore == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "the webhooks are not enabled")
+	}
+	server.logger.Debug("receive a create-webhook request", "url", req.GetUrl())
+
+	webhook, err := NewWebhook(req.GetUrl(), req.GetSecret(), req.GetTenant())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid webhook: %v", err)
+	}
+	err = server.webhookStore.Add(webhook)
+	if err != nil {
+		return nil, logError(status.Errorf(storeErrorCode(err), "cannot save webhook: %v", err))
+	}
+
+	server.logger.Info("created webhook", "id", webhook.GetId(), "url", webhook.GetUrl())
+	return &pb.CreateWebhookResponse{Webhook: webhook}, nil
+}
+
+// ListWebhooks is a unary RPC to list the registered webhooks, without their secrets.
+func (server *AdminServer) ListWebhooks(
+	ctx context.Context,
+	req *pb.ListWebhooksRequest,
+) (*pb.ListWebhooksResponse, error) {
+	if server.webhookStore == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "the webhooks are not enabled")
+	}
+
+	webhooks, err := server.webhookStore.List()
+	if err != nil {
+		return nil, logError(status.Errorf(codes.Internal, "cannot list webhooks: %v", err))
+	}
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+	return &pb.ListWebhooksResponse{Webhooks: webhooks}, nil
+}
+
+// DeleteWebhook is a unary RPC to unregister a webhook.
+func (server *AdminServer) DeleteWebhook(
+	ctx context.Context,
+	req *pb.DeleteWebhookRequest,
+) (*pb.DeleteWebhookResponse, error) {
+	if server.webhookStore == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "the webhooks are not enabled")
+	}
+	server.logger.Debug("receive a delete-webhook request", "id", req.GetId())
+
+	err := server.webhookStore.Delete(req.GetId())
+	if errors.Is(err, ErrWebhookNotFound) {
+		return nil, status.Errorf(codes.NotFound, "webhook %s is not found", req.GetId())
+	}
+	if err != nil {
+		return nil, logError(status.Errorf(codes.Internal, "cannot delete webhook: %v", err))
+	}
+	return &pb.DeleteWebhookResponse{}, nil
+}
+
+// NewWebhook returns a new webhook of the HTTP or HTTPS URL, with a new ID,
+// and a random secret if it's empty.
+func NewWebhook(rawURL string, secret string, tenant string) (*pb.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url %q must be an absolute HTTP or HTTPS URL", rawURL)
+	}
+
+	if secret == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("cannot generate secret: %w", err)
+		}
+		secret = hex.EncodeToString(key)
+	}
+	return &pb.Webhook{
+		Id:        uuid.New().String(),
+		Url:       rawURL,
+		Secret:    secret,
+		Tenant:    tenant,
+		CreatedAt: timestamppb.Now(),
+	}, nil
+}
+
+// SignErasureReport returns the base64 HMAC-SHA256 signature of the report.
+func SignErasureReport(signingKey string, report *pb.ErasureReport) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal report: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write(data)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyErasureReport checks that the signature of the report is valid.
+func VerifyErasureReport(signingKey string, report *pb.ErasureReport, signature string) bool {
+	expected, err := SignErasureReport(signingKey, report)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// SetLogLevel is a unary RPC to change the level of the logs of the server at runtime,
+// e.g. to debug a problem without restarting it, until the config of the server is reloaded.
+func (server *AdminServer) SetLogLevel(
+	ctx context.Context,
+	req *pb.SetLogLevelRequest,
+) (*pb.SetLogLevelResponse, error) {
+	level, err := logging.ParseLevel(req.GetLevel())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid log level: %v", err)
+	}
+
+	previous := server.logger.Level()
+	server.logger.SetLevel(level)
+	server.logger.Info("set log level", "level", level, "previous_level", previous)
+	return &pb.SetLogLevelResponse{PreviousLevel: previous.String()}, nil
+}
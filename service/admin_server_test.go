@@ -0,0 +1,130 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/logging"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEraseUserData(t *testing.T) {
+	t.Parallel()
+
+	userStore := service.NewInMemoryUserStore()
+	user, err := service.NewUser("alice", "secret", "user")
+	require.NoError(t, err)
+	require.NoError(t, userStore.Save(user))
+
+	holdStore := service.NewInMemoryHoldStore()
+	_, err = holdStore.Acquire("laptop-1", "alice", time.Hour)
+	require.NoError(t, err)
+	_, err = holdStore.Acquire("laptop-2", "bob", time.Hour)
+	require.NoError(t, err)
+
+	const signingKey = "signing-key"
+	server := service.NewAdminServer(signingKey, map[string]service.UserDataEraser{
+		"users": userStore,
+		"holds": holdStore,
+	})
+
+	res, err := server.EraseUserData(context.Background(), &pb.EraseUserDataRequest{Username: "alice"})
+	require.NoError(t, err)
+
+	report := res.GetReport()
+	require.Equal(t, "alice", report.GetUsername())
+	require.NotNil(t, report.GetErasedAt())
+	require.Len(t, report.GetRecords(), 2)
+	require.Equal(t, "holds", report.GetRecords()[0].GetStore())
+	require.Equal(t, uint32(1), report.GetRecords()[0].GetCount())
+	require.Equal(t, "users", report.GetRecords()[1].GetStore())
+	require.Equal(t, uint32(1), report.GetRecords()[1].GetCount())
+	require.True(t, service.VerifyErasureReport(signingKey, report, res.GetSignature()))
+	require.False(t, service.VerifyErasureReport("other-key", report, res.GetSignature()))
+
+	found, err := userStore.Find("alice")
+	require.NoError(t, err)
+	require.Nil(t, found)
+	require.NoError(t, holdStore.Check("laptop-1", "bob"), "the hold of the user is released")
+	require.Error(t, holdStore.Check("laptop-2", "alice"), "the holds of the other users are kept")
+
+	_, err = server.EraseUserData(context.Background(), &pb.EraseUserDataRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAdminServerLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	memoryStore := service.NewInMemoryLaptopStore()
+	softDeleteStore := service.NewSoftDeleteLaptopStore(memoryStore, time.Hour)
+	server := service.NewAdminServer("signing-key", nil,
+		service.WithLaptopStore(softDeleteStore),
+		service.WithSoftDeleteStore(softDeleteStore),
+	)
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.NoError(t, softDeleteStore.SaveBatch(ctx, []*pb.Laptop{laptop1, laptop2}))
+	require.NoError(t, softDeleteStore.Delete(ctx, laptop1.GetId()))
+
+	reindexed, err := server.ReindexLaptops(ctx, &pb.ReindexLaptopsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), reindexed.GetIndexed())
+
+	purged, err := server.PurgeDeletedLaptops(ctx, &pb.PurgeDeletedLaptopsRequest{})
+	require.NoError(t, err)
+	require.Zero(t, purged.GetPurged())
+
+	purged, err = server.PurgeDeletedLaptops(ctx, &pb.PurgeDeletedLaptopsRequest{All: true})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), purged.GetPurged())
+
+	found, err := memoryStore.Find(ctx, laptop1.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	stats, err := server.GetServerStats(ctx, &pb.GetServerStatsRequest{})
+	require.NoError(t, err)
+	require.NotZero(t, stats.GetGoroutines())
+	require.NotZero(t, stats.GetHeapAllocBytes())
+
+	server = service.NewAdminServer("signing-key", nil, service.WithLaptopStore(service.NewCachedLaptopStore(
+		service.NewSQLLaptopStore(nil, ""), time.Minute, 10,
+	)))
+	_, err = server.ReindexLaptops(ctx, &pb.ReindexLaptopsRequest{})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = server.PurgeDeletedLaptops(ctx, &pb.PurgeDeletedLaptopsRequest{})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestAdminServerSetLogLevel(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	logger := logging.New(&output, logging.Info, logging.Console)
+	server := service.NewAdminServer("signing-key", nil, service.WithAdminLogger(logger.With("component", "admin_server")))
+
+	res, err := server.SetLogLevel(context.Background(), &pb.SetLogLevelRequest{Level: "DEBUG"})
+	require.NoError(t, err)
+	require.Equal(t, "info", res.GetPreviousLevel())
+	require.True(t, logger.Enabled(logging.Debug), "the level of the loggers sharing the output is set")
+
+	_, err = server.SetLogLevel(context.Background(), &pb.SetLogLevelRequest{Level: "verbose"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = server.SetLogLevel(context.Background(), &pb.SetLogLevelRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, logging.Debug, logger.Level(), "an invalid level is not applied")
+
+	res, err = server.SetLogLevel(context.Background(), &pb.SetLogLevelRequest{Level: "warn"})
+	require.NoError(t, err)
+	require.Equal(t, "debug", res.GetPreviousLevel())
+	require.False(t, logger.Enabled(logging.Info))
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// apiKeyHeader is the metadata key of the API key of machine callers.
+const apiKeyHeader = "x-api-key"
+
+// APIKey is the API key of a machine caller, which is authorized like a user with the role of the key.
+type APIKey struct {
+	// Name identifies the caller, as the username of its claims.
+	Name string `json:"name"`
+	// HashedKey is the hex SHA-256 hash of the key.
+	HashedKey string `json:"key_sha256"`
+	Role      string `json:"role"`
+	Tenant    string `json:"tenant,omitempty"`
+	// RateLimit is the maximum number of calls per second with the key (unlimited if 0),
+	// with bursts of up to RateBurst calls.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	RateBurst int     `json:"rate_burst,omitempty"`
+}
+
+// NewAPIKey returns a new API key of the caller with the role.
+func NewAPIKey(name string, key string, role string) *APIKey {
+	return &APIKey{Name: name, HashedKey: HashAPIKey(key), Role: role}
+}
+
+// HashAPIKey returns the hex SHA-256 hash of the key. Unlike passwords, the keys are long random
+// strings, so a fast hash is enough and lets the keys be looked up by hash.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// Clone returns a clone of this API key
+func (key *APIKey) Clone() *APIKey {
+	clone := *key
+	return &clone
+}
+
+// ReadAPIKeys reads the API keys of a JSON file, an array of keys with their hashes.
+func ReadAPIKeys(path string) ([]*APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read API keys file: %w", err)
+	}
+
+	var keys []*APIKey
+	err = json.Unmarshal(data, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse API keys file %s: %w", path, err)
+	}
+	for i, key := range keys {
+		if key.Name == "" || len(key.HashedKey) != 2*sha256.Size {
+			return nil, fmt.Errorf("invalid API key %d in file %s, must have a name and a key_sha256", i+1, path)
+		}
+	}
+	return keys, nil
+}
+
+// APIKeyStore is an interface to store API keys.
+type APIKeyStore interface {
+	// Save saves an API key to the store
+	Save(key *APIKey) error
+	// Find finds an API key by the hash of the key
+	Find(hashedKey string) (*APIKey, error)
+}
+
+// InMemoryAPIKeyStore stores API keys in memory.
+type InMemoryAPIKeyStore struct {
+	mutex sync.RWMutex
+	keys  map[string]*APIKey
+}
+
+// NewInMemoryAPIKeyStore returns a new in-memory API key store.
+func NewInMemoryAPIKeyStore() *InMemoryAPIKeyStore {
+	return &InMemoryAPIKeyStore{
+		keys: make(map[string]*APIKey),
+	}
+}
+
+// Save saves an API key to the store.
+func (store *InMemoryAPIKeyStore) Save(key *APIKey) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.keys[key.HashedKey] != nil {
+		return ErrAlreadyExist
+	}
+
+	store.keys[key.HashedKey] = key.Clone()
+	return nil
+}
+
+// Find finds an API key by the hash of the key.
+func (store *InMemoryAPIKeyStore) Find(hashedKey string) (*APIKey, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	key := store.keys[hashedKey]
+	if key == nil {
+		return nil, nil
+	}
+
+	return key.Clone(), nil
+}
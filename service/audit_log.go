@@ -0,0 +1,580 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"grpc_app/migration"
+	"grpc_app/pb"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AuditSink is an append-only log of audit entries.
+type AuditSink interface {
+	// Append appends the entry to the log.
+	Append(ctx context.Context, entry *pb.AuditEntry) error
+	// Query returns the entries of the laptop of the tenant, the oldest first.
+	Query(ctx context.Context, tenant string, laptopID string) ([]*pb.AuditEntry, error)
+}
+
+// InMemoryAuditSink keeps the audit entries in memory.
+type InMemoryAuditSink struct {
+	mutex   sync.RWMutex
+	entries []*pb.AuditEntry
+}
+
+// NewInMemoryAuditSink returns a new empty InMemoryAuditSink.
+func NewInMemoryAuditSink() *InMemoryAuditSink {
+	return &InMemoryAuditSink{}
+}
+
+// Append appends the entry to the sink
+func (sink *InMemoryAuditSink) Append(ctx context.Context, entry *pb.AuditEntry) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	sink.entries = append(sink.entries, proto.Clone(entry).(*pb.AuditEntry))
+	return nil
+}
+
+// Query returns the entries of the laptop of the tenant
+func (sink *InMemoryAuditSink) Query(ctx context.Context, tenant string, laptopID string) ([]*pb.AuditEntry, error) {
+	sink.mutex.RLock()
+	defer sink.mutex.RUnlock()
+
+	var entries []*pb.AuditEntry
+	for _, entry := range sink.entries {
+		if entry.GetTenant() == tenant && entry.GetLaptopId() == laptopID {
+			entries = append(entries, proto.Clone(entry).(*pb.AuditEntry))
+		}
+	}
+	return entries, nil
+}
+
+// Prune deletes the entries recorded before the time
+func (sink *InMemoryAuditSink) Prune(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	kept := make([]*pb.AuditEntry, 0, len(sink.entries))
+	for _, entry := range sink.entries {
+		if !entry.GetTime().AsTime().Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	pruned := len(sink.entries) - len(kept)
+	if !dryRun {
+		sink.entries = kept
+	}
+	return pruned, nil
+}
+
+// EraseUserData clears the actor of the entries recorded for the user, and returns their number.
+// The entries are kept, so the audit log still tells what happened to the laptops.
+func (sink *InMemoryAuditSink) EraseUserData(username string) (int, error) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	erased := 0
+	for _, entry := range sink.entries {
+		if entry.GetActor() == username {
+			entry.Actor = ""
+			erased++
+		}
+	}
+	return erased, nil
+}
+
+// FileAuditSink appends the audit entries to a JSON-lines file, one entry per line.
+type FileAuditSink struct {
+	path  string
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// OpenFileAuditSink opens the audit log file at path, creating it if needed.
+func OpenFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open audit log: %w", err)
+	}
+	return &FileAuditSink{path: path, file: file}, nil
+}
+
+// Close closes the audit log file.
+func (sink *FileAuditSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	return sink.file.Close()
+}
+
+// Append appends the entry to the file
+func (sink *FileAuditSink) Append(ctx context.Context, entry *pb.AuditEntry) error {
+	line, err := protojson.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("cannot marshal audit entry: %w", err)
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	_, err = sink.file.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("cannot write audit log: %w", err)
+	}
+	return nil
+}
+
+// Query reads the whole file for the entries of the laptop of the tenant
+func (sink *FileAuditSink) Query(ctx context.Context, tenant string, laptopID string) ([]*pb.AuditEntry, error) {
+	file, err := os.Open(sink.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*pb.AuditEntry
+	reader := bufio.NewReader(file)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A last line without newline is still being appended.
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read audit log: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		entry := &pb.AuditEntry{}
+		err = protojson.Unmarshal(line, entry)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unmarshal audit entry: %w", err)
+		}
+		if entry.GetTenant() == tenant && entry.GetLaptopId() == laptopID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Prune rewrites the file without the entries recorded before the time. The entries are appended
+// to the new file once it replaces the old one, so none is lost while it's rewritten.
+func (sink *FileAuditSink) Prune(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	data, err := os.ReadFile(sink.path)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read audit log: %w", err)
+	}
+
+	var kept bytes.Buffer
+	pruned := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		entry := &pb.AuditEntry{}
+		err = protojson.Unmarshal(line, entry)
+		if err != nil {
+			return 0, fmt.Errorf("cannot unmarshal audit entry: %w", err)
+		}
+		if entry.GetTime().AsTime().Before(before) {
+			pruned++
+			continue
+		}
+		kept.Write(line)
+	}
+	if dryRun || pruned == 0 {
+		return pruned, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return pruned, sink.replace(kept.Bytes())
+}
+
+// EraseUserData rewrites the file with the actor of the entries recorded for the user cleared,
+// and returns the number of anonymized entries.
+func (sink *FileAuditSink) EraseUserData(username string) (int, error) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	data, err := os.ReadFile(sink.path)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read audit log: %w", err)
+	}
+
+	var rewritten bytes.Buffer
+	erased := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		entry := &pb.AuditEntry{}
+		err = protojson.Unmarshal(line, entry)
+		if err != nil {
+			return 0, fmt.Errorf("cannot unmarshal audit entry: %w", err)
+		}
+		if entry.GetActor() != username {
+			rewritten.Write(line)
+			continue
+		}
+
+		entry.Actor = ""
+		line, err = protojson.Marshal(entry)
+		if err != nil {
+			return 0, fmt.Errorf("cannot marshal audit entry: %w", err)
+		}
+		rewritten.Write(append(line, '\n'))
+		erased++
+	}
+	if erased == 0 {
+		return 0, nil
+	}
+	return erased, sink.replace(rewritten.Bytes())
+}
+
+// replace replaces the content of the file with data, and appends the next entries to the new file.
+// The mutex must be held.
+func (sink *FileAuditSink) replace(data []byte) error {
+	err := os.WriteFile(sink.path+".tmp", data, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot write audit log: %w", err)
+	}
+	err = os.Rename(sink.path+".tmp", sink.path)
+	if err != nil {
+		return fmt.Errorf("cannot replace audit log: %w", err)
+	}
+
+	file, err := os.OpenFile(sink.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot open audit log: %w", err)
+	}
+	sink.file.Close()
+	sink.file = file
+	return nil
+}
+
+// SQLAuditSink appends the audit entries to the audit_log table of a SQL database
+// created by the migrations of the migration package.
+type SQLAuditSink struct {
+	db      *sql.DB
+	dialect migration.Dialect
+}
+
+// NewSQLAuditSink returns a new SQLAuditSink of the database, whose schema must be up to date.
+func NewSQLAuditSink(db *sql.DB, dialect migration.Dialect) *SQLAuditSink {
+	return &SQLAuditSink{db: db, dialect: dialect}
+}
+
+// Append inserts the entry into the table
+func (sink *SQLAuditSink) Append(ctx context.Context, entry *pb.AuditEntry) error {
+	data, err := proto.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("cannot marshal audit entry: %w", err)
+	}
+
+	_, err = sink.db.ExecContext(
+		ctx,
+		sink.dialect.Rebind("INSERT INTO audit_log (tenant, laptop_id, data, created_at) VALUES (?, ?, ?, ?)"),
+		entry.GetTenant(), entry.GetLaptopId(), data, entry.GetTime().AsTime(),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query selects the entries of the laptop of the tenant in insertion order
+func (sink *SQLAuditSink) Query(ctx context.Context, tenant string, laptopID string) ([]*pb.AuditEntry, error) {
+	rows, err := sink.db.QueryContext(
+		ctx,
+		sink.dialect.Rebind("SELECT data FROM audit_log WHERE tenant = ? AND laptop_id = ? ORDER BY seq"),
+		tenant, laptopID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot select audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*pb.AuditEntry
+	for rows.Next() {
+		var data []byte
+		err := rows.Scan(&data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot scan audit entry: %w", err)
+		}
+
+		entry := &pb.AuditEntry{}
+		err = proto.Unmarshal(data, entry)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unmarshal audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Prune deletes the rows created before the time
+func (sink *SQLAuditSink) Prune(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	if dryRun {
+		var count int
+		err := sink.db.QueryRowContext(
+			ctx,
+			sink.dialect.Rebind("SELECT COUNT(*) FROM audit_log WHERE created_at < ?"),
+			before,
+		).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf("cannot count audit entries: %w", err)
+		}
+		return count, nil
+	}
+
+	result, err := sink.db.ExecContext(ctx, sink.dialect.Rebind("DELETE FROM audit_log WHERE created_at < ?"), before)
+	if err != nil {
+		return 0, fmt.Errorf("cannot delete audit entries: %w", err)
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("cannot count deleted audit entries: %w", err)
+	}
+	return int(pruned), nil
+}
+
+// EraseUserData clears the actor of the rows recorded for the user in a transaction,
+// and returns their number. The actor is only stored in the encoded entries, so every row is read.
+func (sink *SQLAuditSink) EraseUserData(username string) (int, error) {
+	ctx := context.Background()
+	tx, err := sink.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT seq, data FROM audit_log")
+	if err != nil {
+		return 0, fmt.Errorf("cannot select audit entries: %w", err)
+	}
+	erased := make(map[int64][]byte)
+	for rows.Next() {
+		var seq int64
+		var data []byte
+		err := rows.Scan(&seq, &data)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("cannot scan audit entry: %w", err)
+		}
+
+		entry := &pb.AuditEntry{}
+		err = proto.Unmarshal(data, entry)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("cannot unmarshal audit entry: %w", err)
+		}
+		if entry.GetActor() != username {
+			continue
+		}
+
+		entry.Actor = ""
+		erased[seq], err = proto.Marshal(entry)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("cannot marshal audit entry: %w", err)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("cannot select audit entries: %w", err)
+	}
+
+	for seq, data := range erased {
+		_, err := tx.ExecContext(ctx, sink.dialect.Rebind("UPDATE audit_log SET data = ? WHERE seq = ?"), data, seq)
+		if err != nil {
+			return 0, fmt.Errorf("cannot update audit entry: %w", err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("cannot commit transaction: %w", err)
+	}
+	return len(erased), nil
+}
+
+// AuditLaptopStore is a LaptopStore that records who created, updated, deleted or restored
+// each laptop through it, and when, in an audit sink with the laptop before and after the change.
+type AuditLaptopStore struct {
+	backend LaptopStore
+	tenant  string
+	sink    AuditSink
+}
+
+// NewAuditLaptopStore returns a new AuditLaptopStore over the backend, recording to the sink.
+func NewAuditLaptopStore(backend LaptopStore, sink AuditSink) *AuditLaptopStore {
+	return &AuditLaptopStore{backend: backend, sink: sink}
+}
+
+// ForTenant returns the store of the tenant, whose entries are recorded with the tenant.
+func (store *AuditLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &AuditLaptopStore{backend: tenantStore(store.backend, tenant), tenant: tenant, sink: store.sink}
+}
+
+// record appends the entry of the operation on the laptop, by the caller of the context, to the sink.
+func (store *AuditLaptopStore) record(
+	ctx context.Context,
+	operation pb.AuditEntry_Operation,
+	id string,
+	before *pb.Laptop,
+	after *pb.Laptop,
+) error {
+	entry := &pb.AuditEntry{
+		Time:      timestamppb.Now(),
+		Actor:     auditActor(ctx),
+		Tenant:    store.tenant,
+		Operation: operation,
+		LaptopId:  id,
+		RequestId: RequestIDFromContext(ctx),
+	}
+	if before != nil {
+		entry.Before = proto.Clone(before).(*pb.Laptop)
+	}
+	if after != nil {
+		entry.After = proto.Clone(after).(*pb.Laptop)
+	}
+
+	err := store.sink.Append(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("cannot record audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditActor returns the username of the caller, or the identity of its client certificate if it's anonymous.
+func auditActor(ctx context.Context) string {
+	if claims := ClaimsFromContext(ctx); claims != nil {
+		return claims.Username
+	}
+	return ClientIdentityFromContext(ctx)
+}
+
+// Save saves the laptop to the backend
+func (store *AuditLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	err := store.backend.Save(ctx, laptop)
+	if err != nil {
+		return err
+	}
+	return store.record(ctx, pb.AuditEntry_CREATE, laptop.GetId(), nil, laptop)
+}
+
+// SaveBatch saves the laptops to the backend
+func (store *AuditLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	err := store.backend.SaveBatch(ctx, laptops)
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		err := store.record(ctx, pb.AuditEntry_CREATE, laptop.GetId(), nil, laptop)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update updates the laptop in the backend
+func (store *AuditLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	before, err := store.backend.Find(ctx, laptop.GetId())
+	if err != nil {
+		return err
+	}
+
+	err = store.backend.Update(ctx, laptop)
+	if err != nil {
+		return err
+	}
+	return store.record(ctx, pb.AuditEntry_UPDATE, laptop.GetId(), before, laptop)
+}
+
+// Delete deletes the laptop from the backend
+func (store *AuditLaptopStore) Delete(ctx context.Context, id string) error {
+	before, err := store.backend.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = store.backend.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	return store.record(ctx, pb.AuditEntry_DELETE, id, before, nil)
+}
+
+// Restore restores the deleted laptop in the backend, or returns ErrNotRestorable
+// if the backend doesn't keep the deleted laptops
+func (store *AuditLaptopStore) Restore(ctx context.Context, id string) (*pb.Laptop, error) {
+	restorer, ok := store.backend.(LaptopRestorer)
+	if !ok {
+		return nil, ErrNotRestorable
+	}
+
+	laptop, err := restorer.Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return laptop, store.record(ctx, pb.AuditEntry_RESTORE, id, nil, laptop)
+}
+
+// Find finds a laptop by ID in the backend
+func (store *AuditLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	return store.backend.Find(ctx, id)
+}
+
+// List lists the laptops of the backend
+func (store *AuditLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	return store.backend.List(ctx, pageSize, pageToken)
+}
+
+// Count counts the laptops of the backend
+func (store *AuditLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	return store.backend.Count(ctx, filter)
+}
+
+// Reindex rebuilds the indexes of the backend
+func (store *AuditLaptopStore) Reindex(ctx context.Context) (int, error) {
+	return reindexLaptops(ctx, store.backend)
+}
+
+// Stats returns the statistics of the laptops of the backend
+func (store *AuditLaptopStore) Stats(ctx context.Context, filter *pb.Filter) (*pb.CatalogStats, error) {
+	return aggregateLaptops(ctx, store.backend, filter)
+}
+
+// Search searches for laptops in the backend
+func (store *AuditLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.backend.Search(ctx, filter, found)
+}
@@ -0,0 +1,142 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/migration"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuditLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	fileSink, err := service.OpenFileAuditSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { fileSink.Close() })
+
+	sinks := map[string]service.AuditSink{
+		"memory": service.NewInMemoryAuditSink(),
+		"file":   fileSink,
+	}
+	for name, sink := range sinks {
+		sink := sink
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			backend := service.NewTenantLaptopStore(func(tenant string) (service.LaptopStore, error) {
+				return service.NewInMemoryLaptopStore(), nil
+			})
+			store := service.NewAuditLaptopStore(backend, sink).ForTenant("acme")
+			ctx := service.ContextWithClaims(context.Background(), &service.UserClaims{Username: "alice", Role: "admin"})
+
+			laptop := sample.NewLaptop()
+			require.NoError(t, store.Save(ctx, laptop))
+			updated := sample.NewLaptop()
+			updated.Id = laptop.GetId()
+			require.NoError(t, store.Update(ctx, updated))
+			require.NoError(t, store.Delete(ctx, laptop.GetId()))
+			require.NoError(t, store.Save(ctx, sample.NewLaptop()))
+
+			entries, err := sink.Query(ctx, "acme", laptop.GetId())
+			require.NoError(t, err)
+			require.Len(t, entries, 3)
+
+			require.Equal(t, pb.AuditEntry_CREATE, entries[0].GetOperation())
+			require.Nil(t, entries[0].GetBefore())
+			require.Equal(t, laptop.GetName(), entries[0].GetAfter().GetName())
+
+			require.Equal(t, pb.AuditEntry_UPDATE, entries[1].GetOperation())
+			require.Equal(t, laptop.GetName(), entries[1].GetBefore().GetName())
+			require.Equal(t, updated.GetName(), entries[1].GetAfter().GetName())
+
+			require.Equal(t, pb.AuditEntry_DELETE, entries[2].GetOperation())
+			require.Nil(t, entries[2].GetAfter())
+			for _, entry := range entries {
+				require.Equal(t, "alice", entry.GetActor())
+				require.Equal(t, "acme", entry.GetTenant())
+			}
+
+			entries, err = sink.Query(ctx, "other", laptop.GetId())
+			require.NoError(t, err)
+			require.Empty(t, entries, "the entries of the other tenants are not returned")
+		})
+	}
+}
+
+func TestAdminServerListAuditEntries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	_, err := service.NewAdminServer("secret", nil).ListAuditEntries(ctx, &pb.ListAuditEntriesRequest{LaptopId: "id"})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	sink := service.NewInMemoryAuditSink()
+	store := service.NewAuditLaptopStore(service.NewInMemoryLaptopStore(), sink)
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(ctx, laptop))
+
+	server := service.NewAdminServer("secret", nil, service.WithAuditSink(sink))
+	res, err := server.ListAuditEntries(ctx, &pb.ListAuditEntriesRequest{LaptopId: laptop.GetId()})
+	require.NoError(t, err)
+	require.Len(t, res.GetEntries(), 1)
+	require.Equal(t, laptop.GetId(), res.GetEntries()[0].GetLaptopId())
+
+	_, err = server.ListAuditEntries(ctx, &pb.ListAuditEntriesRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAuditSinkEraseUserData(t *testing.T) {
+	t.Parallel()
+
+	fileSink, err := service.OpenFileAuditSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { fileSink.Close() })
+
+	sinks := map[string]interface {
+		service.AuditSink
+		service.UserDataEraser
+	}{
+		"memory": service.NewInMemoryAuditSink(),
+		"file":   fileSink,
+		"sql":    service.NewSQLAuditSink(openSQLiteDatabase(t), migration.SQLite),
+	}
+	for name, sink := range sinks {
+		sink := sink
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			store := service.NewAuditLaptopStore(service.NewInMemoryLaptopStore(), sink)
+			alice := service.ContextWithClaims(context.Background(), &service.UserClaims{Username: "alice", Role: "admin"})
+			bob := service.ContextWithClaims(context.Background(), &service.UserClaims{Username: "bob", Role: "admin"})
+			laptop := sample.NewLaptop()
+			require.NoError(t, store.Save(alice, laptop))
+			require.NoError(t, store.Update(bob, laptop))
+			require.NoError(t, store.Delete(alice, laptop.GetId()))
+
+			server := service.NewAdminServer("secret", map[string]service.UserDataEraser{"audit_log": sink},
+				service.WithAuditSink(sink))
+			res, err := server.EraseUserData(context.Background(), &pb.EraseUserDataRequest{Username: "alice"})
+			require.NoError(t, err)
+			require.Equal(t, uint32(2), res.GetReport().GetRecords()[0].GetCount())
+
+			entries, err := server.ListAuditEntries(context.Background(), &pb.ListAuditEntriesRequest{LaptopId: laptop.GetId()})
+			require.NoError(t, err)
+			require.Len(t, entries.GetEntries(), 3, "the entries are anonymized, not deleted")
+			actors := make([]string, 0, len(entries.GetEntries()))
+			for _, entry := range entries.GetEntries() {
+				actors = append(actors, entry.GetActor())
+			}
+			require.Equal(t, []string{"", "bob", ""}, actors)
+
+			// The entries are still appended after the erasure.
+			require.NoError(t, store.Save(bob, sample.NewLaptop()))
+		})
+	}
+}
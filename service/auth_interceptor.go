@@ -1,7 +1,11 @@
 package service
 
 import (
-	"log"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -14,11 +18,62 @@ import (
 type AuthInterceptor struct {
 	jwtManager      *JWTManager
 	accessibleRoles map[string][]string
+	// apiKeys are the API keys the callers may authenticate with instead of an access token, if any.
+	apiKeys APIKeyStore
+
+	mutex sync.Mutex
+	// limiters are the rate limiters of the API keys with a rate limit, by hashed key.
+	limiters map[string]*tokenBucket
+}
+
+// AuthInterceptorOption configures the optional features of an AuthInterceptor.
+type AuthInterceptorOption func(interceptor *AuthInterceptor)
+
+// WithAPIKeyStore lets the callers authenticate with an API key of the store in the x-api-key
+// metadata, instead of an access token.
+func WithAPIKeyStore(store APIKeyStore) AuthInterceptorOption {
+	return func(interceptor *AuthInterceptor) {
+		interceptor.apiKeys = store
+	}
 }
 
 // NewAuthInterceptor returns a new auth interceptor
-func NewAuthInterceptor(jwtManager *JWTManager, accessibleRoles map[string][]string) *AuthInterceptor {
-	return &AuthInterceptor{jwtManager: jwtManager, accessibleRoles: accessibleRoles}
+func NewAuthInterceptor(
+	jwtManager *JWTManager,
+	accessibleRoles map[string][]string,
+	options ...AuthInterceptorOption,
+) *AuthInterceptor {
+	interceptor := &AuthInterceptor{
+		jwtManager:      jwtManager,
+		accessibleRoles: accessibleRoles,
+		limiters:        make(map[string]*tokenBucket),
+	}
+	for _, option := range options {
+		option(interceptor)
+	}
+	return interceptor
+}
+
+// ReadAccessibleRoles reads the roles that may access each method from a JSON file,
+// an object from the full method names like "/grpc_app.proto.LaptopService/CreateLaptop"
+// to their roles. A method with no roles may be accessed by everyone.
+func ReadAccessibleRoles(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read roles file: %w", err)
+	}
+
+	var roles map[string][]string
+	err = json.Unmarshal(data, &roles)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse roles file %s: %w", path, err)
+	}
+	for method := range roles {
+		if !strings.HasPrefix(method, "/") || strings.Count(method, "/") != 2 {
+			return nil, fmt.Errorf("invalid method %q in roles file %s, must be /package.Service/Method", method, path)
+		}
+	}
+	return roles, nil
 }
 
 // Unary returns a server interceptor function to authenticate and authorize a unary RPC
@@ -29,8 +84,7 @@ func (interceptor *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		log.Println("--> unary interceptor: ", info.FullMethod)
-		err := interceptor.authorize(ctx, info.FullMethod)
+		ctx, err := interceptor.authorize(ctx, info.FullMethod)
 		if err != nil {
 			return nil, err
 		}
@@ -46,44 +100,142 @@ func (interceptor *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
 		info *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		log.Println("--> stream interceptor: ", info.FullMethod)
-		err := interceptor.authorize(stream.Context(), info.FullMethod)
+		ctx, err := interceptor.authorize(stream.Context(), info.FullMethod)
 		if err != nil {
 			return err
 		}
-		return handler(srv, stream)
+		return handler(srv, &serverStreamWithContext{ServerStream: stream, ctx: ctx})
 	}
 }
 
-// authorize function
-func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) error {
+// authorize checks that the caller may access the method, and returns
+// a context holding the tenant of the caller.
+func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	claims, err := interceptor.authenticate(md)
 	accessibleRoles, ok := interceptor.accessibleRoles[method]
 	if !ok {
-		// everyone can access
-		return nil
+		// everyone can access, the credentials are only used to find the tenant
+		if status.Code(err) == codes.Unauthenticated {
+			return withTenant(ctx, md, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return withTenant(ctx, md, claims)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return status.Errorf(codes.Unauthenticated, "metadata is not provided")
+	for _, role := range accessibleRoles {
+		if role == claims.Role {
+			return withTenant(ctx, md, claims)
+		}
+	}
+
+	return nil, status.Error(codes.PermissionDenied, "no permission to accces the RPC")
+}
+
+// authenticate returns the claims of the caller, from its API key if it has one and the API keys
+// are enabled, or from its access token otherwise.
+func (interceptor *AuthInterceptor) authenticate(md metadata.MD) (*UserClaims, error) {
+	if values := md[apiKeyHeader]; len(values) > 0 && interceptor.apiKeys != nil {
+		return interceptor.authenticateAPIKey(values[0])
+	}
+
+	if md == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "metadata is not provided")
 	}
 
 	values := md["authorization"]
 	if len(values) == 0 {
-		return status.Errorf(codes.Unauthenticated, "authorization token is not provided")
+		return nil, status.Errorf(codes.Unauthenticated, "authorization token is not provided")
 	}
 
 	accessToken := values[0]
 	claims, err := interceptor.jwtManager.Verify(accessToken)
 	if err != nil {
-		return status.Errorf(codes.Unauthenticated, "access token is invalid: v", err)
+		return nil, status.Errorf(codes.Unauthenticated, "access token is invalid: %v", err)
 	}
+	return claims, nil
+}
 
-	for _, role := range accessibleRoles {
-		if role == claims.Role {
-			return nil
-		}
+// authenticateAPIKey returns the claims of the API key, once its rate limit allows the call.
+func (interceptor *AuthInterceptor) authenticateAPIKey(key string) (*UserClaims, error) {
+	apiKey, err := interceptor.apiKeys.Find(HashAPIKey(key))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot find API key: %v", err)
+	}
+	if apiKey == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "API key is invalid")
+	}
+
+	if apiKey.RateLimit > 0 && !interceptor.limiter(apiKey).allow() {
+		return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded for API key %s", apiKey.Name)
+	}
+	return &UserClaims{Username: apiKey.Name, Role: apiKey.Role, Tenant: apiKey.Tenant}, nil
+}
+
+// limiter returns the rate limiter of the API key, created the first time the key is used.
+func (interceptor *AuthInterceptor) limiter(apiKey *APIKey) *tokenBucket {
+	interceptor.mutex.Lock()
+	defer interceptor.mutex.Unlock()
+
+	limiter := interceptor.limiters[apiKey.HashedKey]
+	if limiter == nil {
+		limiter = newTokenBucket(apiKey.RateLimit, apiKey.RateBurst)
+		interceptor.limiters[apiKey.HashedKey] = limiter
+	}
+	return limiter
+}
+
+// withTenant returns the context with the tenant of the verified claims, or the one
+// requested in the metadata for anonymous calls. Authenticated callers cannot
+// request another tenant than their own.
+func withTenant(ctx context.Context, md metadata.MD, claims *UserClaims) (context.Context, error) {
+	requested := ""
+	if values := md[tenantHeader]; len(values) > 0 {
+		requested = values[0]
+	}
+	if !validTenant(requested) {
+		return nil, status.Errorf(
+			codes.InvalidArgument,
+			"tenant ID must be at most %d letters, digits, dots, dashes or underscores",
+			maxTenantLength,
+		)
+	}
+
+	if claims == nil {
+		return ContextWithTenant(ctx, requested), nil
+	}
+
+	if requested != "" && requested != claims.Tenant {
+		return nil, status.Errorf(codes.PermissionDenied, "no permission to access tenant %q", requested)
 	}
+	return ContextWithTenant(ContextWithClaims(ctx, claims), claims.Tenant), nil
+}
+
+type claimsKey struct{}
+
+// ContextWithClaims returns a context holding the verified claims of the caller.
+func ContextWithClaims(ctx context.Context, claims *UserClaims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the verified claims of the caller, or nil if the call is anonymous.
+func ClaimsFromContext(ctx context.Context) *UserClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*UserClaims)
+	return claims
+}
+
+// serverStreamWithContext is a server stream with a different context.
+type serverStreamWithContext struct {
+	grpc.ServerStream
+	ctx context.Context
+}
 
-	return status.Error(codes.PermissionDenied, "no permission to accces the RPC")
+func (stream *serverStreamWithContext) Context() context.Context {
+	return stream.ctx
 }
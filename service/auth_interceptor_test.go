@@ -0,0 +1,135 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/service"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptorUnary(t *testing.T) {
+	t.Parallel()
+
+	const method = "/grpc_app.proto.LaptopService/CreateLaptop"
+	jwtManager := service.NewJWTManager("secret-key", time.Minute)
+	interceptor := service.NewAuthInterceptor(jwtManager, map[string][]string{method: {"admin"}})
+	unary := interceptor.Unary()
+
+	call := func(ctx context.Context, method string) (*service.UserClaims, error) {
+		var claims *service.UserClaims
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			claims = service.ClaimsFromContext(ctx)
+			return nil, nil
+		})
+		return claims, err
+	}
+	withToken := func(role string) context.Context {
+		token, err := jwtManager.Generate(&service.User{Username: "alice", Role: role})
+		require.NoError(t, err)
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", token))
+	}
+
+	claims, err := call(withToken("admin"), method)
+	require.NoError(t, err)
+	require.Equal(t, "alice", claims.Username)
+
+	_, err = call(context.Background(), method)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	invalid := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "invalid"))
+	_, err = call(invalid, method)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = call(withToken("user"), method)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	claims, err = call(context.Background(), "/grpc_app.proto.LaptopService/SearchLaptop")
+	require.NoError(t, err)
+	require.Nil(t, claims)
+
+	withTenant := func(tenant string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", tenant))
+	}
+	_, err = call(withTenant("acme-1.eu_west"), "/grpc_app.proto.LaptopService/SearchLaptop")
+	require.NoError(t, err)
+	_, err = call(withTenant("../acme"), "/grpc_app.proto.LaptopService/SearchLaptop")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = call(withTenant(strings.Repeat("a", 65)), "/grpc_app.proto.LaptopService/SearchLaptop")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestReadAccessibleRoles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "roles.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"/grpc_app.proto.LaptopService/CreateLaptop": ["admin", "user"],
+		"/grpc_app.proto.LaptopService/RateLaptop": []
+	}`), 0o600))
+
+	roles, err := service.ReadAccessibleRoles(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"/grpc_app.proto.LaptopService/CreateLaptop": {"admin", "user"},
+		"/grpc_app.proto.LaptopService/RateLaptop":   {},
+	}, roles)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"CreateLaptop": ["admin"]}`), 0o600))
+	_, err = service.ReadAccessibleRoles(invalid)
+	require.Error(t, err)
+
+	_, err = service.ReadAccessibleRoles(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
+
+func TestAuthInterceptorAPIKey(t *testing.T) {
+	t.Parallel()
+
+	const method = "/grpc_app.proto.LaptopService/CreateLaptop"
+	apiKeyStore := service.NewInMemoryAPIKeyStore()
+	apiKey := service.NewAPIKey("importer", "key-1", "admin")
+	apiKey.Tenant = "acme"
+	apiKey.RateLimit = 0.001
+	apiKey.RateBurst = 2
+	require.NoError(t, apiKeyStore.Save(apiKey))
+
+	interceptor := service.NewAuthInterceptor(
+		service.NewJWTManager("secret-key", time.Minute),
+		map[string][]string{method: {"admin"}},
+		service.WithAPIKeyStore(apiKeyStore),
+	)
+	call := func(key string) (*service.UserClaims, error) {
+		var claims *service.UserClaims
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", key))
+		_, err := interceptor.Unary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			claims = service.ClaimsFromContext(ctx)
+			require.Equal(t, "acme", service.TenantFromContext(ctx))
+			return nil, nil
+		})
+		return claims, err
+	}
+
+	claims, err := call("key-1")
+	require.NoError(t, err)
+	require.Equal(t, "importer", claims.Username)
+	require.Equal(t, "admin", claims.Role)
+
+	_, err = call("key-2")
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = call("key-1")
+	require.NoError(t, err)
+	_, err = call("key-1")
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -21,7 +21,7 @@ func NewAuthServer(userStore UserStore, jwtManager *JWTManager) *AuthServer {
 }
 
 // Login is a unary RPC to login user.
-func (server *AuthServer) Login(ctc context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+func (server *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
 	user, err := server.UserStore.Find(req.GetUsername())
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "cannot find user: %v", err)
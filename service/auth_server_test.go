@@ -0,0 +1,42 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthServerLogin(t *testing.T) {
+	t.Parallel()
+
+	userStore := service.NewInMemoryUserStore()
+	user, err := service.NewUser("alice", "secret", "admin")
+	require.NoError(t, err)
+	require.NoError(t, userStore.Save(user))
+
+	jwtManager := service.NewJWTManager("secret-key", time.Minute)
+	server := service.NewAuthServer(userStore, jwtManager)
+
+	res, err := server.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "secret"})
+	require.NoError(t, err)
+
+	claims, err := jwtManager.Verify(res.GetAccessToken())
+	require.NoError(t, err)
+	require.Equal(t, "alice", claims.Username)
+	require.Equal(t, "admin", claims.Role)
+
+	_, err = server.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "wrong"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = server.Login(context.Background(), &pb.LoginRequest{Username: "bob", Password: "secret"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = service.NewJWTManager("other-key", time.Minute).Verify(res.GetAccessToken())
+	require.Error(t, err)
+}
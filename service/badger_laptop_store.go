@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+
+	"github.com/dgraph-io/badger/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrTxnConflict is returned by BadgerDB.Update when the transaction conflicts with another one
+// and can be retried, as badger.ErrConflict.
+var ErrTxnConflict = errors.New("transaction conflict")
+
+// BadgerTxn is the subset of a BadgerDB transaction used by BadgerLaptopStore.
+type BadgerTxn interface {
+	// Get returns a copy of the value of the key, or nil if it doesn't exist.
+	Get(key []byte) ([]byte, error)
+	// Set sets the value of the key.
+	Set(key []byte, value []byte) error
+	// Delete deletes the key.
+	Delete(key []byte) error
+	// Iterate iterates over the keys with the prefix in key order, calling fn with each key and its value.
+	// The key and value are only valid during the call.
+	Iterate(prefix []byte, fn func(key []byte, value []byte) error) error
+}
+
+// BadgerDB is the subset of a BadgerDB database used by BadgerLaptopStore. It can be implemented with
+// a thin adapter over *badger.DB, whose iterator seeks to the prefix and stops when ValidForPrefix is false.
+type BadgerDB interface {
+	// Update calls fn in a read-write transaction and commits it.
+	Update(fn func(txn BadgerTxn) error) error
+	// View calls fn in a read-only transaction.
+	View(fn func(txn BadgerTxn) error) error
+}
+
+// badgerMaxAttempts is the maximum number of attempts of a conflicting write.
+const badgerMaxAttempts = 3
+
+// BadgerLaptopStore stores serialized laptops in an embedded BadgerDB database.
+// The keys are prefixed with the tenant, so Search iterates over the laptops of a single tenant.
+type BadgerLaptopStore struct {
+	db     BadgerDB
+	prefix []byte
+}
+
+// NewBadgerLaptopStore returns a new BadgerLaptopStore of the database
+func NewBadgerLaptopStore(db BadgerDB) *BadgerLaptopStore {
+	return &BadgerLaptopStore{db: db, prefix: badgerPrefix("")}
+}
+
+// badgerPrefix returns the key prefix of the laptops of the tenant. Tenants come from metadata
+// and can't contain a NUL byte, so no prefix is a prefix of another one.
+func badgerPrefix(tenant string) []byte {
+	return []byte("laptops\x00" + tenant + "\x00")
+}
+
+// OpenBadgerLaptopStore opens the BadgerDB database in the directory, creating it if needed,
+// and returns a new BadgerLaptopStore of it. The store must be closed once unused.
+func OpenBadgerLaptopStore(dir string) (*BadgerLaptopStore, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLoggingLevel(badger.WARNING))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open badger database: %w", err)
+	}
+	return NewBadgerLaptopStore(&badgerDB{db: db}), nil
+}
+
+// Close closes the database of the store if it can be closed, e.g. if it was opened by OpenBadgerLaptopStore.
+func (store *BadgerLaptopStore) Close() error {
+	if closer, ok := store.db.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// badgerDB is the BadgerDB of a badger database.
+type badgerDB struct {
+	db *badger.DB
+}
+
+func (db *badgerDB) Update(fn func(txn BadgerTxn) error) error {
+	err := db.db.Update(func(txn *badger.Txn) error { return fn(badgerTxn{txn: txn}) })
+	if errors.Is(err, badger.ErrConflict) {
+		return ErrTxnConflict
+	}
+	return err
+}
+
+func (db *badgerDB) View(fn func(txn BadgerTxn) error) error {
+	return db.db.View(func(txn *badger.Txn) error { return fn(badgerTxn{txn: txn}) })
+}
+
+func (db *badgerDB) Close() error {
+	return db.db.Close()
+}
+
+// badgerTxn is the BadgerTxn of a badger transaction.
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (txn badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := txn.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (txn badgerTxn) Set(key []byte, value []byte) error {
+	return txn.txn.Set(key, value)
+}
+
+func (txn badgerTxn) Delete(key []byte) error {
+	return txn.txn.Delete(key)
+}
+
+func (txn badgerTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	options := badger.DefaultIteratorOptions
+	options.Prefix = prefix
+	it := txn.txn.NewIterator(options)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		err := item.Value(func(value []byte) error { return fn(item.Key(), value) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForTenant returns the laptop store of the tenant, sharing the same database.
+func (store *BadgerLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &BadgerLaptopStore{db: store.db, prefix: badgerPrefix(tenant)}
+}
+
+func (store *BadgerLaptopStore) key(id string) []byte {
+	return append(append([]byte(nil), store.prefix...), id...)
+}
+
+// Save saves the laptop to the store
+func (store *BadgerLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	return store.SaveBatch(ctx, []*pb.Laptop{laptop})
+}
+
+// SaveBatch saves the laptops to the store in a single transaction,
+// which fails with badger.ErrTxnTooBig if the batch doesn't fit in memory
+func (store *BadgerLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	err := checkBatchIDs(laptops)
+	if err != nil {
+		return err
+	}
+
+	values := make([][]byte, len(laptops))
+	for i, laptop := range laptops {
+		values[i], err = proto.Marshal(laptop)
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop: %w", err)
+		}
+	}
+
+	return store.update(ctx, func(txn BadgerTxn) error {
+		for i, laptop := range laptops {
+			key := store.key(laptop.GetId())
+			existing, err := txn.Get(key)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				return ErrAlreadyExist
+			}
+			if err := txn.Set(key, values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update replaces the laptop with the same ID and version in the store
+func (store *BadgerLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	next := nextVersion(laptop)
+	data, err := proto.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	key := store.key(laptop.GetId())
+	err = store.update(ctx, func(txn BadgerTxn) error {
+		existing, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		stored, err := unmarshalLaptopIfAny(existing)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(stored, laptop); err != nil {
+			return err
+		}
+		return txn.Set(key, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	laptop.Version = next.Version
+	return nil
+}
+
+// Delete deletes the laptop with the ID from the store
+func (store *BadgerLaptopStore) Delete(ctx context.Context, id string) error {
+	key := store.key(id)
+	return store.update(ctx, func(txn BadgerTxn) error {
+		existing, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return ErrNotFound
+		}
+		return txn.Delete(key)
+	})
+}
+
+// update calls fn in a read-write transaction, retrying it while it conflicts and the context isn't done.
+func (store *BadgerLaptopStore) update(ctx context.Context, fn func(txn BadgerTxn) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := store.db.Update(fn)
+		if !errors.Is(err, ErrTxnConflict) || attempt == badgerMaxAttempts {
+			return err
+		}
+	}
+}
+
+// Find finds a laptop by ID
+func (store *BadgerLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var laptop *pb.Laptop
+	err := store.db.View(func(txn BadgerTxn) error {
+		data, err := txn.Get(store.key(id))
+		if err != nil || data == nil {
+			return err
+		}
+
+		laptop, err = unmarshalLaptop(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return laptop, nil
+}
+
+// List returns a page of laptops in ID order, which is the key order of the database
+func (store *BadgerLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+	after, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var laptops []*pb.Laptop
+	err = store.db.View(func(txn BadgerTxn) error {
+		return txn.Iterate(store.prefix, func(key []byte, data []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if string(key[len(store.prefix):]) <= after {
+				return nil
+			}
+
+			laptop, err := unmarshalLaptop(data)
+			if err != nil {
+				return err
+			}
+			laptops = append(laptops, laptop)
+			if len(laptops) > pageSize {
+				return errPageFull
+			}
+			return nil
+		})
+	})
+	if err != nil && !errors.Is(err, errPageFull) {
+		return nil, "", err
+	}
+
+	laptops, nextPageToken := nextPage(laptops, pageSize)
+	return laptops, nextPageToken, nil
+}
+
+// Count returns the number of laptops matching the filter, reading every laptop of the tenant
+func (store *BadgerLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	return countBySearch(ctx, store, filter)
+}
+
+// Search searches for laptops with filter, returns one by one via the found function.
+// The laptops are sorted after they are all found, if the filter has a sort.
+func (store *BadgerLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return searchSorted(ctx, store.search, filter, found)
+}
+
+// search searches for laptops with filter in the order of the store.
+func (store *BadgerLaptopStore) search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.db.View(func(txn BadgerTxn) error {
+		return txn.Iterate(store.prefix, func(key []byte, data []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			laptop, err := unmarshalLaptop(data)
+			if err != nil {
+				return err
+			}
+			if !isQualified(filter, laptop) {
+				return nil
+			}
+			return found(laptop)
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBadgerDB keeps the keys in memory. The next conflicts updates fail with ErrTxnConflict.
+type fakeBadgerDB struct {
+	mutex     sync.Mutex
+	values    map[string][]byte
+	conflicts int
+}
+
+type fakeBadgerTxn struct {
+	db *fakeBadgerDB
+}
+
+func (txn fakeBadgerTxn) Get(key []byte) ([]byte, error) {
+	return append([]byte(nil), txn.db.values[string(key)]...), nil
+}
+
+func (txn fakeBadgerTxn) Set(key []byte, value []byte) error {
+	txn.db.values[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+func (txn fakeBadgerTxn) Delete(key []byte) error {
+	delete(txn.db.values, string(key))
+	return nil
+}
+
+func (txn fakeBadgerTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	var keys []string
+	for key := range txn.db.values {
+		if strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := fn([]byte(key), txn.db.values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *fakeBadgerDB) Update(fn func(txn service.BadgerTxn) error) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.conflicts > 0 {
+		db.conflicts--
+		return service.ErrTxnConflict
+	}
+	return fn(fakeBadgerTxn{db})
+}
+
+func (db *fakeBadgerDB) View(fn func(txn service.BadgerTxn) error) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return fn(fakeBadgerTxn{db})
+}
+
+func TestBadgerLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	db := &fakeBadgerDB{values: make(map[string][]byte), conflicts: 2}
+	store := service.NewBadgerLaptopStore(db)
+
+	cheap := sample.NewLaptop()
+	cheap.PriceUsd = 1000
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, store.Save(context.Background(), cheap), "conflicts are retried")
+	require.NoError(t, store.Save(context.Background(), expensive))
+	require.ErrorIs(t, store.Save(context.Background(), cheap), service.ErrAlreadyExist)
+	require.NoError(t, store.Update(context.Background(), expensive))
+	require.Equal(t, uint64(1), expensive.GetVersion())
+	stale := sample.NewLaptop()
+	stale.Id = expensive.GetId()
+	require.ErrorIs(t, store.Update(context.Background(), stale), service.ErrVersionConflict)
+	require.ErrorIs(t, store.Update(context.Background(), sample.NewLaptop()), service.ErrNotFound)
+	require.ErrorIs(t, store.Delete(context.Background(), "unknown"), service.ErrNotFound)
+	require.ElementsMatch(t, []string{cheap.GetId(), expensive.GetId()}, listAll(t, store, 1))
+
+	db.conflicts = 3
+	require.ErrorIs(t, store.Save(context.Background(), sample.NewLaptop()), service.ErrTxnConflict)
+	db.conflicts = 0
+
+	other := store.ForTenant("other")
+	require.NoError(t, other.Save(context.Background(), sample.NewLaptop()))
+
+	laptop, err := store.Find(context.Background(), cheap.GetId())
+	require.NoError(t, err)
+	require.Equal(t, cheap.GetId(), laptop.GetId())
+
+	var found []string
+	err = store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 2000}, func(laptop *pb.Laptop) error {
+		found = append(found, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{cheap.GetId()}, found)
+
+	laptop, err = other.Find(context.Background(), cheap.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop)
+}
+
+func TestOpenBadgerLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "laptops")
+	store, err := service.OpenBadgerLaptopStore(dir)
+	require.NoError(t, err)
+
+	cheap := sample.NewLaptop()
+	cheap.PriceUsd = 1000
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, store.SaveBatch(context.Background(), []*pb.Laptop{cheap, expensive}))
+	require.ErrorIs(t, store.Save(context.Background(), cheap), service.ErrAlreadyExist)
+	require.NoError(t, store.ForTenant("acme").Save(context.Background(), sample.NewLaptop()))
+	require.NoError(t, store.Update(context.Background(), cheap))
+	require.NoError(t, store.Close())
+
+	// The laptops are kept in the directory after the store is closed, as after a restart.
+	store, err = service.OpenBadgerLaptopStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	found, err := store.Find(context.Background(), cheap.GetId())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), found.GetVersion())
+	require.ElementsMatch(t, []string{cheap.GetId(), expensive.GetId()}, listAll(t, store, 1))
+
+	var ids []string
+	err = store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 2000}, func(laptop *pb.Laptop) error {
+		ids = append(ids, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{cheap.GetId()}, ids)
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+)
+
+// BoltBucket is the subset of a bbolt bucket used by BoltLaptopStore, which *bbolt.Bucket implements.
+// The values it returns are only valid during the transaction.
+type BoltBucket interface {
+	Get(key []byte) []byte
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	// ForEach calls fn with each key and value of the bucket in key order.
+	ForEach(fn func(key []byte, value []byte) error) error
+}
+
+// BoltDB is the subset of a bbolt database used by BoltLaptopStore. It can be implemented
+// with a thin adapter over *bbolt.DB that opens the named bucket in a transaction.
+type BoltDB interface {
+	// Update calls fn with the bucket in a read-write transaction, creating the bucket if needed.
+	Update(bucket string, fn func(bucket BoltBucket) error) error
+	// View calls fn with the bucket in a read-only transaction, or with nil if the bucket doesn't exist.
+	View(bucket string, fn func(bucket BoltBucket) error) error
+}
+
+// boltBucketPrefix is the prefix of the buckets of the laptops, one per tenant.
+const boltBucketPrefix = "laptops"
+
+// BoltLaptopStore stores serialized laptops in an embedded bbolt database,
+// so the laptops are kept across restarts without an external database.
+type BoltLaptopStore struct {
+	db     BoltDB
+	bucket string
+}
+
+// NewBoltLaptopStore returns a new BoltLaptopStore of the database
+func NewBoltLaptopStore(db BoltDB) *BoltLaptopStore {
+	return &BoltLaptopStore{db: db, bucket: boltBucketPrefix}
+}
+
+// boltOpenTimeout is how long OpenBoltLaptopStore waits for the lock of a database used by another process.
+const boltOpenTimeout = 5 * time.Second
+
+// OpenBoltLaptopStore opens the bbolt database file at path, creating it if needed,
+// and returns a new BoltLaptopStore of it. The store must be closed once unused.
+func OpenBoltLaptopStore(path string) (*BoltLaptopStore, error) {
+	db, err := bbolt.Open(path, 0o644, &bbolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open bolt database: %w", err)
+	}
+	return NewBoltLaptopStore(&bboltDB{db: db}), nil
+}
+
+// Close closes the database of the store if it can be closed, e.g. if it was opened by OpenBoltLaptopStore.
+func (store *BoltLaptopStore) Close() error {
+	if closer, ok := store.db.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// bboltDB is the BoltDB of a bbolt database.
+type bboltDB struct {
+	db *bbolt.DB
+}
+
+func (db *bboltDB) Update(bucket string, fn func(bucket BoltBucket) error) error {
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+func (db *bboltDB) View(bucket string, fn func(bucket BoltBucket) error) error {
+	return db.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fn(nil)
+		}
+		return fn(b)
+	})
+}
+
+func (db *bboltDB) Close() error {
+	return db.db.Close()
+}
+
+// ForTenant returns the laptop store of the tenant, which uses its own bucket of the same database.
+func (store *BoltLaptopStore) ForTenant(tenant string) LaptopStore {
+	bucket := boltBucketPrefix
+	if tenant != "" {
+		bucket += "/" + tenant
+	}
+	return &BoltLaptopStore{db: store.db, bucket: bucket}
+}
+
+// Save saves the laptop to the store
+func (store *BoltLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	return store.update(ctx, func(bucket BoltBucket) error {
+		key := []byte(laptop.GetId())
+		if bucket.Get(key) != nil {
+			return ErrAlreadyExist
+		}
+		return bucket.Put(key, data)
+	})
+}
+
+// SaveBatch saves the laptops to the store in a single transaction
+func (store *BoltLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	err := checkBatchIDs(laptops)
+	if err != nil {
+		return err
+	}
+
+	values := make([][]byte, len(laptops))
+	for i, laptop := range laptops {
+		values[i], err = proto.Marshal(laptop)
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop: %w", err)
+		}
+	}
+
+	return store.update(ctx, func(bucket BoltBucket) error {
+		for i, laptop := range laptops {
+			key := []byte(laptop.GetId())
+			if bucket.Get(key) != nil {
+				return ErrAlreadyExist
+			}
+			if err := bucket.Put(key, values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update replaces the laptop with the same ID and version in the store
+func (store *BoltLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	next := nextVersion(laptop)
+	data, err := proto.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	err = store.update(ctx, func(bucket BoltBucket) error {
+		key := []byte(laptop.GetId())
+		stored, err := unmarshalLaptopIfAny(bucket.Get(key))
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(stored, laptop); err != nil {
+			return err
+		}
+		return bucket.Put(key, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	laptop.Version = next.Version
+	return nil
+}
+
+// Delete deletes the laptop with the ID from the store
+func (store *BoltLaptopStore) Delete(ctx context.Context, id string) error {
+	return store.update(ctx, func(bucket BoltBucket) error {
+		key := []byte(id)
+		if bucket.Get(key) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete(key)
+	})
+}
+
+// update calls fn with the bucket in a read-write transaction, unless the context is done.
+// The transaction can't be canceled once it has started.
+func (store *BoltLaptopStore) update(ctx context.Context, fn func(bucket BoltBucket) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return store.db.Update(store.bucket, fn)
+}
+
+// Find finds a laptop by ID
+func (store *BoltLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var laptop *pb.Laptop
+	err := store.db.View(store.bucket, func(bucket BoltBucket) error {
+		if bucket == nil {
+			return nil
+		}
+
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+
+		var err error
+		laptop, err = unmarshalLaptop(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return laptop, nil
+}
+
+// errPageFull stops the iterations of List once the page is full.
+var errPageFull = errors.New("page is full")
+
+// List returns a page of laptops in ID order, which is the key order of the bucket
+func (store *BoltLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+	after, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var laptops []*pb.Laptop
+	err = store.db.View(store.bucket, func(bucket BoltBucket) error {
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key []byte, data []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if string(key) <= after {
+				return nil
+			}
+
+			laptop, err := unmarshalLaptop(data)
+			if err != nil {
+				return err
+			}
+			laptops = append(laptops, laptop)
+			if len(laptops) > pageSize {
+				return errPageFull
+			}
+			return nil
+		})
+	})
+	if err != nil && !errors.Is(err, errPageFull) {
+		return nil, "", err
+	}
+
+	laptops, nextPageToken := nextPage(laptops, pageSize)
+	return laptops, nextPageToken, nil
+}
+
+// Count returns the number of laptops matching the filter, reading every laptop of the bucket
+func (store *BoltLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	return countBySearch(ctx, store, filter)
+}
+
+// Search searches for laptops with filter, returns one by one via the found function.
+// The laptops are sorted after they are all found, if the filter has a sort.
+func (store *BoltLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return searchSorted(ctx, store.search, filter, found)
+}
+
+// search searches for laptops with filter in the order of the store.
+func (store *BoltLaptopStore) search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.db.View(store.bucket, func(bucket BoltBucket) error {
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key []byte, data []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// Unmarshal copies the data, so the laptop outlives the transaction.
+			laptop, err := unmarshalLaptop(data)
+			if err != nil {
+				return err
+			}
+			if !isQualified(filter, laptop) {
+				return nil
+			}
+			return found(laptop)
+		})
+	})
+}
@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBoltBucket map[string][]byte
+
+func (bucket fakeBoltBucket) Get(key []byte) []byte {
+	return bucket[string(key)]
+}
+
+func (bucket fakeBoltBucket) Put(key []byte, value []byte) error {
+	bucket[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+func (bucket fakeBoltBucket) Delete(key []byte) error {
+	delete(bucket, string(key))
+	return nil
+}
+
+func (bucket fakeBoltBucket) ForEach(fn func(key []byte, value []byte) error) error {
+	keys := make([]string, 0, len(bucket))
+	for key := range bucket {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := fn([]byte(key), bucket[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeBoltDB keeps the buckets in memory and serializes the transactions.
+type fakeBoltDB struct {
+	mutex   sync.Mutex
+	buckets map[string]fakeBoltBucket
+}
+
+func (db *fakeBoltDB) Update(name string, fn func(bucket service.BoltBucket) error) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	bucket, ok := db.buckets[name]
+	if !ok {
+		bucket = make(fakeBoltBucket)
+		db.buckets[name] = bucket
+	}
+	return fn(bucket)
+}
+
+func (db *fakeBoltDB) View(name string, fn func(bucket service.BoltBucket) error) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	bucket, ok := db.buckets[name]
+	if !ok {
+		return fn(nil)
+	}
+	return fn(bucket)
+}
+
+func TestBoltLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	db := &fakeBoltDB{buckets: make(map[string]fakeBoltBucket)}
+	store := service.NewBoltLaptopStore(db)
+
+	cheap := sample.NewLaptop()
+	cheap.PriceUsd = 1000
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, store.Save(context.Background(), cheap))
+	require.NoError(t, store.Save(context.Background(), expensive))
+	require.ErrorIs(t, store.Save(context.Background(), cheap), service.ErrAlreadyExist)
+	require.NoError(t, store.Update(context.Background(), expensive))
+	require.Equal(t, uint64(1), expensive.GetVersion())
+	stale := sample.NewLaptop()
+	stale.Id = expensive.GetId()
+	require.ErrorIs(t, store.Update(context.Background(), stale), service.ErrVersionConflict)
+	require.ErrorIs(t, store.Update(context.Background(), sample.NewLaptop()), service.ErrNotFound)
+	require.ErrorIs(t, store.Delete(context.Background(), "unknown"), service.ErrNotFound)
+	deleted := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), deleted))
+	require.NoError(t, store.Delete(context.Background(), deleted.GetId()))
+	require.ElementsMatch(t, []string{cheap.GetId(), expensive.GetId()}, listAll(t, store, 1))
+
+	laptop, err := store.Find(context.Background(), cheap.GetId())
+	require.NoError(t, err)
+	require.Equal(t, cheap.GetName(), laptop.GetName())
+
+	var found []string
+	err = store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 2000}, func(laptop *pb.Laptop) error {
+		found = append(found, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{cheap.GetId()}, found)
+
+	// The laptops of the tenants are kept apart, and reading doesn't create a bucket.
+	other := store.ForTenant("other")
+	laptop, err = other.Find(context.Background(), cheap.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop)
+	require.NotContains(t, db.buckets, "laptops/other")
+
+	// A new store of the same database finds the laptops again, as after a restart.
+	laptop, err = service.NewBoltLaptopStore(db).Find(context.Background(), expensive.GetId())
+	require.NoError(t, err)
+	require.Equal(t, expensive.GetId(), laptop.GetId())
+}
+
+func TestOpenBoltLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "laptops.bolt")
+	store, err := service.OpenBoltLaptopStore(path)
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	require.NoError(t, store.ForTenant("acme").Save(context.Background(), sample.NewLaptop()))
+	require.NoError(t, store.Update(context.Background(), laptop))
+	found, err := store.ForTenant("other").Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+	require.NoError(t, store.Close())
+
+	// The laptops are kept in the file after the store is closed, as after a restart.
+	store, err = service.OpenBoltLaptopStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	found, err = store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), found.GetVersion())
+	require.ElementsMatch(t, []string{laptop.GetId()}, listAll(t, store, 10))
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"grpc_app/pb"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// CachedLaptopStore is a LaptopStore that caches the laptops found in another store in memory,
+// so finding a hot laptop doesn't hit the backend every time. Writes through the cache
+// invalidate the entries of the laptops written.
+type CachedLaptopStore struct {
+	backend LaptopStore
+	tenant  string
+	cache   *laptopCache
+}
+
+// NewCachedLaptopStore returns a new CachedLaptopStore over the backend.
+// Entries expire after ttl, and at most maxEntries laptops are kept, for all the tenants.
+func NewCachedLaptopStore(backend LaptopStore, ttl time.Duration, maxEntries int) *CachedLaptopStore {
+	return &CachedLaptopStore{
+		backend: backend,
+		cache: &laptopCache{
+			ttl:        ttl,
+			maxEntries: maxEntries,
+			entries:    make(map[cacheKey]*cacheEntry),
+		},
+	}
+}
+
+// ForTenant returns the cached store of the tenant, sharing the same cache.
+func (store *CachedLaptopStore) ForTenant(tenant string) LaptopStore {
+	backend := store.backend
+	if scoped, ok := backend.(TenantScopedStore); ok {
+		backend = scoped.ForTenant(tenant)
+	}
+	return &CachedLaptopStore{backend: backend, tenant: tenant, cache: store.cache}
+}
+
+func (store *CachedLaptopStore) key(id string) cacheKey {
+	return cacheKey{tenant: store.tenant, id: id}
+}
+
+// Save saves the laptop to the backend
+func (store *CachedLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	defer store.cache.invalidate(store.key(laptop.GetId()))
+	return store.backend.Save(ctx, laptop)
+}
+
+// SaveBatch saves the laptops to the backend
+func (store *CachedLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	for _, laptop := range laptops {
+		defer store.cache.invalidate(store.key(laptop.GetId()))
+	}
+	return store.backend.SaveBatch(ctx, laptops)
+}
+
+// Update updates the laptop in the backend
+func (store *CachedLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	defer store.cache.invalidate(store.key(laptop.GetId()))
+	return store.backend.Update(ctx, laptop)
+}
+
+// Delete deletes the laptop from the backend
+func (store *CachedLaptopStore) Delete(ctx context.Context, id string) error {
+	defer store.cache.invalidate(store.key(id))
+	return store.backend.Delete(ctx, id)
+}
+
+// Find finds a laptop by ID in the cache, or in the backend if it's not cached
+func (store *CachedLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	key := store.key(id)
+	if laptop := store.cache.get(key); laptop != nil {
+		return laptop, nil
+	}
+
+	laptop, err := store.backend.Find(ctx, id)
+	if err != nil || laptop == nil {
+		return laptop, err
+	}
+
+	store.cache.set(key, laptop)
+	return laptop, nil
+}
+
+// List lists the laptops of the backend
+func (store *CachedLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	return store.backend.List(ctx, pageSize, pageToken)
+}
+
+// Count counts the laptops of the backend
+func (store *CachedLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	return store.backend.Count(ctx, filter)
+}
+
+// Reindex rebuilds the indexes of the backend
+func (store *CachedLaptopStore) Reindex(ctx context.Context) (int, error) {
+	return reindexLaptops(ctx, store.backend)
+}
+
+// Stats returns the statistics of the laptops of the backend
+func (store *CachedLaptopStore) Stats(ctx context.Context, filter *pb.Filter) (*pb.CatalogStats, error) {
+	return aggregateLaptops(ctx, store.backend, filter)
+}
+
+// Search searches for laptops in the backend, as the results of a filter can't be cached by ID.
+func (store *CachedLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.backend.Search(ctx, filter, found)
+}
+
+type cacheKey struct {
+	tenant string
+	id     string
+}
+
+type cacheEntry struct {
+	laptop    *pb.Laptop
+	expiresAt time.Time
+}
+
+// laptopCache is an in-memory TTL cache of laptops.
+type laptopCache struct {
+	mutex      sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[cacheKey]*cacheEntry
+}
+
+// get returns a copy of the cached laptop, or nil if it's missing or expired.
+func (cache *laptopCache) get(key cacheKey) *pb.Laptop {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry := cache.entries[key]
+	if entry == nil {
+		return nil
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(cache.entries, key)
+		return nil
+	}
+
+	return proto.Clone(entry.laptop).(*pb.Laptop)
+}
+
+// set stores a copy of the laptop in the cache.
+func (cache *laptopCache) set(key cacheKey, laptop *pb.Laptop) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	now := time.Now()
+	if cache.entries[key] == nil && len(cache.entries) >= cache.maxEntries {
+		cache.evict(now)
+	}
+
+	cache.entries[key] = &cacheEntry{
+		laptop:    proto.Clone(laptop).(*pb.Laptop),
+		expiresAt: now.Add(cache.ttl),
+	}
+}
+
+// invalidate removes the laptop from the cache.
+func (cache *laptopCache) invalidate(key cacheKey) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	delete(cache.entries, key)
+}
+
+// evict drops expired entries, or the entry closest to expiry if none has expired.
+func (cache *laptopCache) evict(now time.Time) {
+	var oldestKey *cacheKey
+	var oldest time.Time
+
+	for key, entry := range cache.entries {
+		if now.After(entry.expiresAt) {
+			delete(cache.entries, key)
+			continue
+		}
+		if oldestKey == nil || entry.expiresAt.Before(oldest) {
+			key := key
+			oldestKey, oldest = &key, entry.expiresAt
+		}
+	}
+
+	if len(cache.entries) >= cache.maxEntries && oldestKey != nil {
+		delete(cache.entries, *oldestKey)
+	}
+}
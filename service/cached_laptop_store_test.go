@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingLaptopStore counts the calls to Find of the laptop store.
+type countingLaptopStore struct {
+	service.LaptopStore
+	finds int32
+}
+
+func (store *countingLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	atomic.AddInt32(&store.finds, 1)
+	return store.LaptopStore.Find(ctx, id)
+}
+
+func TestCachedLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	backend := &countingLaptopStore{LaptopStore: service.NewInMemoryLaptopStore()}
+	store := service.NewCachedLaptopStore(backend, 100*time.Millisecond, 1)
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop1))
+	require.NoError(t, store.Save(context.Background(), laptop2))
+
+	for i := 0; i < 3; i++ {
+		laptop, err := store.Find(context.Background(), laptop1.GetId())
+		require.NoError(t, err)
+		require.Equal(t, laptop1.GetId(), laptop.GetId())
+	}
+	require.EqualValues(t, 1, backend.finds, "hits are served from the cache")
+
+	laptop, err := store.Find(context.Background(), "unknown")
+	require.NoError(t, err)
+	require.Nil(t, laptop)
+
+	// laptop2 evicts laptop1, as the cache holds a single laptop.
+	_, err = store.Find(context.Background(), laptop2.GetId())
+	require.NoError(t, err)
+	_, err = store.Find(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.EqualValues(t, 4, backend.finds)
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = store.Find(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.EqualValues(t, 5, backend.finds, "expired entries are found again")
+}
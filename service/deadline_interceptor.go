@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"grpc_app/logging"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// nearDeadlineFraction is the fraction of its budget left under which a call is logged
+// as coming close to its deadline.
+const nearDeadlineFraction = 0.1
+
+// DeadlineInterceptor is a server interceptor that applies a default timeout to the calls
+// whose client sent no deadline, so no handler runs forever, and logs the calls that end
+// with less than a tenth of their budget left, or past their deadline.
+type DeadlineInterceptor struct {
+	mutex         sync.RWMutex
+	unaryTimeout  time.Duration
+	streamTimeout time.Duration
+	logger        *logging.Logger
+}
+
+// NewDeadlineInterceptor returns a new deadline interceptor applying the timeouts to the unary
+// and the stream RPC, none if a timeout is 0, and logging to the logger, or to the default logger if it's nil.
+// The stream timeout should be long, since the streams like WatchLaptops are meant to stay open.
+func NewDeadlineInterceptor(unaryTimeout time.Duration, streamTimeout time.Duration, logger *logging.Logger) *DeadlineInterceptor {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &DeadlineInterceptor{unaryTimeout: unaryTimeout, streamTimeout: streamTimeout, logger: logger}
+}
+
+// SetTimeouts replaces the timeouts of the unary and the stream RPC, e.g. when the config of the server
+// is reloaded. The calls in progress keep the timeout they started with.
+func (interceptor *DeadlineInterceptor) SetTimeouts(unaryTimeout time.Duration, streamTimeout time.Duration) {
+	interceptor.mutex.Lock()
+	defer interceptor.mutex.Unlock()
+
+	interceptor.unaryTimeout = unaryTimeout
+	interceptor.streamTimeout = streamTimeout
+}
+
+// timeouts returns the timeouts of the unary and the stream RPC.
+func (interceptor *DeadlineInterceptor) timeouts() (time.Duration, time.Duration) {
+	interceptor.mutex.RLock()
+	defer interceptor.mutex.RUnlock()
+
+	return interceptor.unaryTimeout, interceptor.streamTimeout
+}
+
+// Unary returns a server interceptor to apply the default deadline to unary RPC
+func (interceptor *DeadlineInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		unaryTimeout, _ := interceptor.timeouts()
+		ctx, cancel := withDefaultTimeout(ctx, unaryTimeout)
+		defer cancel()
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+		interceptor.check(ctx, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// Stream returns a server interceptor to apply the default deadline to stream RPC
+func (interceptor *DeadlineInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		_, streamTimeout := interceptor.timeouts()
+		ctx, cancel := withDefaultTimeout(stream.Context(), streamTimeout)
+		defer cancel()
+
+		start := time.Now()
+		err := handler(srv, &serverStreamWithContext{ServerStream: stream, ctx: ctx})
+		interceptor.check(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// withDefaultTimeout returns the context with the timeout, unless it already has a deadline or the timeout is 0.
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// check logs the call if it ended close to or past the deadline of its context.
+func (interceptor *DeadlineInterceptor) check(ctx context.Context, method string, start time.Time, err error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+
+	end := time.Now()
+	budget := deadline.Sub(start)
+	remaining := deadline.Sub(end)
+	if budget <= 0 || float64(remaining) >= float64(budget)*nearDeadlineFraction {
+		return
+	}
+	interceptor.logger.Warn(
+		"call close to its deadline",
+		"method", method,
+		"took", end.Sub(start).Round(time.Millisecond),
+		"budget", budget.Round(time.Millisecond),
+		"request_id", RequestIDFromContext(ctx),
+		"code", status.Code(err),
+	)
+}
@@ -0,0 +1,43 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/logging"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestDeadlineInterceptorUnary(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	unary := service.NewDeadlineInterceptor(time.Minute, 0, logging.New(&output, logging.Debug, logging.Console)).Unary()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc_app.proto.LaptopService/GetLaptop"}
+
+	_, err := unary(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok, "the default timeout is applied")
+		require.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.Empty(t, output.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = unary(ctx, nil, info, func(handlerCtx context.Context, req interface{}) (interface{}, error) {
+		deadline, _ := handlerCtx.Deadline()
+		expected, _ := ctx.Deadline()
+		require.Equal(t, expected, deadline, "the deadline of the client is kept")
+
+		<-handlerCtx.Done()
+		return nil, handlerCtx.Err()
+	})
+	require.Error(t, err)
+	require.Contains(t, output.String(), "WARN call close to its deadline method=/grpc_app.proto.LaptopService/GetLaptop took=")
+}
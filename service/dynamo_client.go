@@ -0,0 +1,186 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DynamoCredentials are the AWS credentials used to sign the DynamoDB requests.
+type DynamoCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only set for temporary credentials.
+	SessionToken string
+}
+
+// DynamoCredentialsFromEnv returns the credentials of the standard AWS environment variables.
+func DynamoCredentialsFromEnv() DynamoCredentials {
+	return DynamoCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// DynamoConfig configures a DynamoHTTPClient.
+type DynamoConfig struct {
+	Region      string
+	Credentials DynamoCredentials
+	// Endpoint overrides the endpoint of the region, e.g. to use DynamoDB Local.
+	Endpoint string
+	// HTTPClient is http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// DynamoError is an error returned by DynamoDB.
+type DynamoError struct {
+	StatusCode int
+	Type       string `json:"__type"`
+	Message    string `json:"message"`
+}
+
+func (err *DynamoError) Error() string {
+	return fmt.Sprintf("dynamodb: %s: %s (status %d)", err.Code(), err.Message, err.StatusCode)
+}
+
+// Code returns the type of the error without its namespace, e.g. ConditionalCheckFailedException.
+func (err *DynamoError) Code() string {
+	return err.Type[strings.LastIndex(err.Type, "#")+1:]
+}
+
+// DynamoHTTPClient calls the DynamoDB JSON API over HTTP, signing the requests with AWS Signature Version 4.
+type DynamoHTTPClient struct {
+	config DynamoConfig
+	now    func() time.Time
+}
+
+// NewDynamoHTTPClient returns a new DynamoHTTPClient
+func NewDynamoHTTPClient(config DynamoConfig) *DynamoHTTPClient {
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://dynamodb.%s.amazonaws.com", config.Region)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &DynamoHTTPClient{config: config, now: time.Now}
+}
+
+// Do calls the operation, e.g. PutItem, with the input and decodes the response into output.
+func (client *DynamoHTTPClient) Do(ctx context.Context, operation string, input interface{}, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("cannot marshal %s input: %w", operation, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.config.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+operation)
+	signV4(req, body, client.config.Credentials, client.config.Region, "dynamodb", client.now())
+
+	res, err := client.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot call %s: %w", operation, err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("cannot read %s response: %w", operation, err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		dynamoErr := &DynamoError{StatusCode: res.StatusCode}
+		if json.Unmarshal(data, dynamoErr) != nil || dynamoErr.Type == "" {
+			dynamoErr.Type, dynamoErr.Message = http.StatusText(res.StatusCode), string(data)
+		}
+		return dynamoErr
+	}
+
+	if output == nil {
+		return nil
+	}
+	err = json.Unmarshal(data, output)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// signV4 sets the X-Amz-Date and Authorization headers of the request, signing the host and every header of it.
+func signV4(req *http.Request, body []byte, credentials DynamoCredentials, region string, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSHA256(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,523 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DynamoClient calls the operations of the DynamoDB JSON API, and is implemented by DynamoHTTPClient.
+type DynamoClient interface {
+	// Do calls the operation, e.g. PutItem, with the input and decodes the response into output.
+	Do(ctx context.Context, operation string, input interface{}, output interface{}) error
+}
+
+// DynamoValue is a DynamoDB attribute value of type S, N, B or BOOL.
+type DynamoValue struct {
+	S    *string `json:"S,omitempty"`
+	N    *string `json:"N,omitempty"`
+	B    []byte  `json:"B,omitempty"`
+	BOOL *bool   `json:"BOOL,omitempty"`
+}
+
+// DynamoItem is a DynamoDB item.
+type DynamoItem map[string]DynamoValue
+
+func dynamoString(s string) DynamoValue {
+	return DynamoValue{S: &s}
+}
+
+func dynamoValue(value interface{}) DynamoValue {
+	switch value := value.(type) {
+	case string:
+		return dynamoString(value)
+	case float64:
+		n := strconv.FormatFloat(value, 'f', -1, 64)
+		return DynamoValue{N: &n}
+	case uint64:
+		n := strconv.FormatUint(value, 10)
+		return DynamoValue{N: &n}
+	case bool:
+		return DynamoValue{BOOL: &value}
+	default:
+		return DynamoValue{}
+	}
+}
+
+// dynamoAttributes are the attributes of the filter fields whose column is taken by the item keys.
+var dynamoAttributes = map[string]string{
+	"id": "laptop_id",
+}
+
+func dynamoAttribute(field string) string {
+	if attribute, ok := dynamoAttributes[field]; ok {
+		return attribute
+	}
+	return filterFields[field].column
+}
+
+// DynamoLaptopStore stores laptops in a DynamoDB table whose partition key is the string attribute id.
+// Each item holds the serialized laptop, its tenant and one attribute per filter field, used by Search.
+type DynamoLaptopStore struct {
+	client DynamoClient
+	table  string
+	tenant string
+}
+
+// NewDynamoLaptopStore returns a new DynamoLaptopStore of the table
+func NewDynamoLaptopStore(client DynamoClient, table string) *DynamoLaptopStore {
+	return &DynamoLaptopStore{client: client, table: table}
+}
+
+// ForTenant returns the laptop store of the tenant, sharing the same table.
+func (store *DynamoLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &DynamoLaptopStore{client: store.client, table: store.table, tenant: tenant}
+}
+
+func (store *DynamoLaptopStore) key(id string) DynamoItem {
+	if store.tenant != "" {
+		id = store.tenant + "/" + id
+	}
+	return DynamoItem{"id": dynamoString(id)}
+}
+
+// Save saves the laptop to the store
+func (store *DynamoLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	return store.put(ctx, laptop, "attribute_not_exists(#id)", nil, ErrAlreadyExist)
+}
+
+// dynamoMaxTransactItems is the maximum number of items of a DynamoDB transaction.
+const dynamoMaxTransactItems = 100
+
+// SaveBatch saves the laptops to the store in a single transaction,
+// so a batch has at most 100 laptops
+func (store *DynamoLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	if len(laptops) > dynamoMaxTransactItems {
+		return fmt.Errorf("cannot save more than %d laptops in a batch", dynamoMaxTransactItems)
+	}
+	err := checkBatchIDs(laptops)
+	if err != nil {
+		return err
+	}
+	if len(laptops) == 0 {
+		return nil
+	}
+
+	items := make([]interface{}, len(laptops))
+	for i, laptop := range laptops {
+		item, err := store.item(laptop)
+		if err != nil {
+			return err
+		}
+		items[i] = map[string]interface{}{"Put": map[string]interface{}{
+			"TableName":                store.table,
+			"Item":                     item,
+			"ConditionExpression":      "attribute_not_exists(#id)",
+			"ExpressionAttributeNames": map[string]string{"#id": "id"},
+		}}
+	}
+
+	err = store.client.Do(ctx, "TransactWriteItems", map[string]interface{}{"TransactItems": items}, nil)
+	var dynamoErr *DynamoError
+	// The message lists the cancellation reason of every item, e.g. [None, ConditionalCheckFailed].
+	if errors.As(err, &dynamoErr) && dynamoErr.Code() == "TransactionCanceledException" &&
+		strings.Contains(dynamoErr.Message, "ConditionalCheckFailed") {
+		return ErrAlreadyExist
+	}
+	if err != nil {
+		return fmt.Errorf("cannot put laptops: %w", err)
+	}
+	return nil
+}
+
+// Update replaces the laptop with the same ID and version in the store
+func (store *DynamoLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	next := nextVersion(laptop)
+
+	// The items saved before the laptops had versions have none.
+	condition := "attribute_exists(#id) AND (#version = :version)"
+	if laptop.GetVersion() == 0 {
+		condition = "attribute_exists(#id) AND (#version = :version OR attribute_not_exists(#version))"
+	}
+	values := map[string]DynamoValue{":version": dynamoValue(laptop.GetVersion())}
+
+	err := store.put(ctx, next, condition, values, ErrVersionConflict)
+	if errors.Is(err, ErrVersionConflict) {
+		stored, err := store.Find(ctx, laptop.GetId())
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	laptop.Version = next.Version
+	return nil
+}
+
+// Delete deletes the laptop with the ID from the store
+func (store *DynamoLaptopStore) Delete(ctx context.Context, id string) error {
+	input := map[string]interface{}{
+		"TableName":                store.table,
+		"Key":                      store.key(id),
+		"ConditionExpression":      "attribute_exists(#id)",
+		"ExpressionAttributeNames": map[string]string{"#id": "id"},
+	}
+	err := store.client.Do(ctx, "DeleteItem", input, nil)
+	var dynamoErr *DynamoError
+	if errors.As(err, &dynamoErr) && dynamoErr.Code() == "ConditionalCheckFailedException" {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("cannot delete laptop: %w", err)
+	}
+	return nil
+}
+
+// put puts the item of the laptop if the condition holds, and returns conditionErr otherwise.
+// The condition can refer to the #id and #version attributes, and to the values.
+func (store *DynamoLaptopStore) put(
+	ctx context.Context,
+	laptop *pb.Laptop,
+	condition string,
+	values map[string]DynamoValue,
+	conditionErr error,
+) error {
+	item, err := store.item(laptop)
+	if err != nil {
+		return err
+	}
+
+	// DynamoDB rejects the names and values that the condition doesn't use.
+	names := map[string]string{"#id": "id"}
+	if strings.Contains(condition, "#version") {
+		names["#version"] = "version"
+	}
+	input := map[string]interface{}{
+		"TableName":                store.table,
+		"Item":                     item,
+		"ConditionExpression":      condition,
+		"ExpressionAttributeNames": names,
+	}
+	if len(values) > 0 {
+		input["ExpressionAttributeValues"] = values
+	}
+	err = store.client.Do(ctx, "PutItem", input, nil)
+	var dynamoErr *DynamoError
+	if errors.As(err, &dynamoErr) && dynamoErr.Code() == "ConditionalCheckFailedException" {
+		return conditionErr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot put laptop: %w", err)
+	}
+	return nil
+}
+
+// item returns the item of the laptop, with its key, tenant, data, version and filter fields.
+func (store *DynamoLaptopStore) item(laptop *pb.Laptop) (DynamoItem, error) {
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	item := store.key(laptop.GetId())
+	item["tenant"] = dynamoString(store.tenant)
+	item["data"] = DynamoValue{B: data}
+	item["version"] = dynamoValue(laptop.GetVersion())
+	for name, field := range filterFields {
+		item[dynamoAttribute(name)] = dynamoValue(field.value(laptop))
+	}
+	return item, nil
+}
+
+// Find finds a laptop by ID
+func (store *DynamoLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	input := map[string]interface{}{
+		"TableName":      store.table,
+		"Key":            store.key(id),
+		"ConsistentRead": true,
+	}
+	var output struct {
+		Item DynamoItem
+	}
+	err := store.client.Do(ctx, "GetItem", input, &output)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get laptop: %w", err)
+	}
+
+	if output.Item == nil {
+		return nil, nil
+	}
+	return unmarshalLaptop(output.Item["data"].B)
+}
+
+// List returns a page of laptops in the order of a scan of the table, which is stable but not by ID.
+// The page token is the key where the scan stopped.
+func (store *DynamoLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+
+	var startKey DynamoItem
+	if pageToken != "" {
+		data, err := base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil || json.Unmarshal(data, &startKey) != nil || startKey == nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+
+	input := map[string]interface{}{
+		"TableName":                 store.table,
+		"FilterExpression":          "#tenant = :tenant",
+		"ProjectionExpression":      "#data",
+		"ExpressionAttributeNames":  map[string]string{"#tenant": "tenant", "#data": "data"},
+		"ExpressionAttributeValues": map[string]DynamoValue{":tenant": dynamoString(store.tenant)},
+	}
+	var laptops []*pb.Laptop
+	for len(laptops) < pageSize {
+		// The limit applies before the filter, so the scan stops right after the last laptop of the page.
+		input["Limit"] = pageSize - len(laptops)
+		if startKey != nil {
+			input["ExclusiveStartKey"] = startKey
+		}
+
+		var output struct {
+			Items            []DynamoItem
+			LastEvaluatedKey DynamoItem
+		}
+		err := store.client.Do(ctx, "Scan", input, &output)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot scan laptops: %w", err)
+		}
+
+		for _, item := range output.Items {
+			laptop, err := unmarshalLaptop(item["data"].B)
+			if err != nil {
+				return nil, "", err
+			}
+			laptops = append(laptops, laptop)
+		}
+
+		startKey = output.LastEvaluatedKey
+		if startKey == nil {
+			return laptops, "", nil
+		}
+	}
+
+	data, err := json.Marshal(startKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return laptops, base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Count returns the number of laptops matching the filter. Like Search, it scans the table,
+// but DynamoDB only returns the number of matching items.
+func (store *DynamoLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	if filter.GetText() != "" {
+		return countBySearch(ctx, store, filter)
+	}
+
+	input, err := store.scanInput(filter)
+	if err != nil {
+		return 0, err
+	}
+	input["Select"] = "COUNT"
+
+	var count int64
+	for {
+		var output struct {
+			Count            int64
+			LastEvaluatedKey DynamoItem
+		}
+		err := store.client.Do(ctx, "Scan", input, &output)
+		if err != nil {
+			return 0, fmt.Errorf("cannot count laptops: %w", err)
+		}
+
+		count += output.Count
+		if output.LastEvaluatedKey == nil {
+			return count, nil
+		}
+		input["ExclusiveStartKey"] = output.LastEvaluatedKey
+	}
+}
+
+// scanInput returns the input of a scan for the laptops of the tenant matching the filter.
+func (store *DynamoLaptopStore) scanInput(filter *pb.Filter) (map[string]interface{}, error) {
+	expression, names, values, err := FilterToDynamo(filter)
+	if err != nil {
+		return nil, err
+	}
+	names["#tenant"] = "tenant"
+	values[":tenant"] = dynamoString(store.tenant)
+
+	return map[string]interface{}{
+		"TableName":                 store.table,
+		"FilterExpression":          "#tenant = :tenant AND " + expression,
+		"ExpressionAttributeNames":  names,
+		"ExpressionAttributeValues": values,
+	}, nil
+}
+
+// Search searches for laptops with filter, returns one by one via the found function.
+// The laptops are sorted after they are all found, if the filter has a sort.
+func (store *DynamoLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return searchSorted(ctx, store.search, filter, found)
+}
+
+// search searches for laptops with filter in the order of the store.
+// It scans the table, so DynamoDB reads every item but only returns the matching ones.
+func (store *DynamoLaptopStore) search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	input, err := store.scanInput(filter)
+	if err != nil {
+		return err
+	}
+	input["ProjectionExpression"] = "#data"
+	input["ExpressionAttributeNames"].(map[string]string)["#data"] = "data"
+
+	for {
+		var output struct {
+			Items            []DynamoItem
+			LastEvaluatedKey DynamoItem
+		}
+		err := store.client.Do(ctx, "Scan", input, &output)
+		if err != nil {
+			return fmt.Errorf("cannot scan laptops: %w", err)
+		}
+
+		for _, item := range output.Items {
+			laptop, err := unmarshalLaptop(item["data"].B)
+			if err != nil {
+				return err
+			}
+			// The text is matched here, as DynamoDB has no full-text search.
+			if !matchesText(filter.GetText(), laptop) {
+				continue
+			}
+			err = found(laptop)
+			if err != nil {
+				return err
+			}
+		}
+
+		if output.LastEvaluatedKey == nil {
+			return nil
+		}
+		input["ExclusiveStartKey"] = output.LastEvaluatedKey
+	}
+}
+
+// FilterToDynamo translates the filter to a DynamoDB filter expression, with its attribute names and values.
+func FilterToDynamo(filter *pb.Filter) (string, map[string]string, map[string]DynamoValue, error) {
+	expression := filter.GetExpression()
+	err := ValidateExpression(expression)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	builder := &dynamoExpression{
+		names:  make(map[string]string),
+		values: make(map[string]DynamoValue),
+	}
+
+	var conditions []string
+	if expression == nil || filter.GetMaxPriceUsd() != 0 {
+		conditions = append(conditions, builder.condition("price_usd", "<=", filter.GetMaxPriceUsd()))
+	}
+	if filter.GetMinCpuCores() != 0 {
+		conditions = append(conditions, builder.condition("cpu.number_cores", ">=", float64(filter.GetMinCpuCores())))
+	}
+	if filter.GetMinCpuGhz() != 0 {
+		conditions = append(conditions, builder.condition("cpu.min_ghz", ">=", filter.GetMinCpuGhz()))
+	}
+	if filter.GetMinRam() != nil {
+		conditions = append(conditions, builder.condition("ram", ">=", toBit(filter.GetMinRam())))
+	}
+	if expression != nil {
+		conditions = append(conditions, builder.expression(expression))
+	}
+
+	if len(conditions) == 0 {
+		return builder.always(true), builder.names, builder.values, nil
+	}
+	return strings.Join(conditions, " AND "), builder.names, builder.values, nil
+}
+
+// dynamoExpression collects the attribute names and values of a DynamoDB expression.
+type dynamoExpression struct {
+	names  map[string]string
+	values map[string]DynamoValue
+}
+
+func (builder *dynamoExpression) name(attribute string) string {
+	placeholder := "#" + attribute
+	builder.names[placeholder] = attribute
+	return placeholder
+}
+
+func (builder *dynamoExpression) value(value interface{}) string {
+	placeholder := fmt.Sprintf(":v%d", len(builder.values))
+	builder.values[placeholder] = dynamoValue(value)
+	return placeholder
+}
+
+func (builder *dynamoExpression) condition(field string, operator string, value interface{}) string {
+	return fmt.Sprintf("%s %s %s", builder.name(dynamoAttribute(field)), operator, builder.value(value))
+}
+
+// always returns an expression that is always true or always false, as DynamoDB has no boolean literals.
+func (builder *dynamoExpression) always(match bool) string {
+	if match {
+		return "attribute_exists(" + builder.name("id") + ")"
+	}
+	return "attribute_not_exists(" + builder.name("id") + ")"
+}
+
+func (builder *dynamoExpression) expression(expression *pb.Expression) string {
+	switch node := expression.GetNode().(type) {
+	case *pb.Expression_Condition:
+		condition := node.Condition
+		return builder.condition(condition.GetField(), sqlOperators[condition.GetOperator()], conditionValue(condition))
+	case *pb.Expression_And:
+		return builder.join(node.And.GetExpressions(), " AND ", true)
+	case *pb.Expression_Or:
+		return builder.join(node.Or.GetExpressions(), " OR ", false)
+	case *pb.Expression_Not:
+		return "NOT (" + builder.expression(node.Not) + ")"
+	default:
+		return builder.always(true)
+	}
+}
+
+func (builder *dynamoExpression) join(expressions []*pb.Expression, separator string, empty bool) string {
+	if len(expressions) == 0 {
+		return builder.always(empty)
+	}
+
+	conditions := make([]string, len(expressions))
+	for i, expression := range expressions {
+		conditions[i] = builder.expression(expression)
+	}
+	return "(" + strings.Join(conditions, separator) + ")"
+}
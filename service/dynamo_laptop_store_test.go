@@ -0,0 +1,203 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDynamoDB serves PutItem, DeleteItem, GetItem, Scan and the puts of TransactWriteItems of a single table. Scan ignores the filter
+// expression and returns one item per page.
+type fakeDynamoDB struct {
+	mutex sync.Mutex
+	items map[string]service.DynamoItem
+	scans []string
+}
+
+func (db *fakeDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		http.Error(w, `{"__type":"UnrecognizedClientException"}`, http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		TableName         string
+		Item              service.DynamoItem
+		Key               service.DynamoItem
+		ExclusiveStartKey service.DynamoItem
+		FilterExpression  string
+		Select            string
+		TransactItems     []struct {
+			Put struct {
+				TableName string
+				Item      service.DynamoItem
+			}
+		}
+		// ConditionExpression is either attribute_not_exists(#id), or attribute_exists(#id)
+		// followed by a condition on #version.
+		ConditionExpression       string
+		ExpressionAttributeValues service.DynamoItem
+	}
+	if json.NewDecoder(r.Body).Decode(&input) != nil || (input.TableName != "laptops" && input.TransactItems == nil) {
+		http.Error(w, `{"__type":"ValidationException"}`, http.StatusBadRequest)
+		return
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var output interface{} = struct{}{}
+	switch r.Header.Get("X-Amz-Target") {
+	case "DynamoDB_20120810.PutItem":
+		id := *input.Item["id"].S
+		item, ok := db.items[id]
+		if ok && strings.HasPrefix(input.ConditionExpression, "attribute_exists") {
+			ok = *item["version"].N == *input.ExpressionAttributeValues[":version"].N
+		}
+		if ok != strings.HasPrefix(input.ConditionExpression, "attribute_exists") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`))
+			return
+		}
+		db.items[id] = input.Item
+	case "DynamoDB_20120810.TransactWriteItems":
+		// Every put of SaveBatch requires the item not to exist.
+		for _, item := range input.TransactItems {
+			if _, ok := db.items[*item.Put.Item["id"].S]; ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException","message":"Transaction cancelled, please refer cancellation reasons for specific reasons [ConditionalCheckFailed]"}`))
+				return
+			}
+		}
+		for _, item := range input.TransactItems {
+			db.items[*item.Put.Item["id"].S] = item.Put.Item
+		}
+	case "DynamoDB_20120810.DeleteItem":
+		id := *input.Key["id"].S
+		if _, ok := db.items[id]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`))
+			return
+		}
+		delete(db.items, id)
+	case "DynamoDB_20120810.GetItem":
+		if item, ok := db.items[*input.Key["id"].S]; ok {
+			output = map[string]interface{}{"Item": item}
+		}
+	case "DynamoDB_20120810.Scan":
+		db.scans = append(db.scans, input.FilterExpression)
+		ids := make([]string, 0, len(db.items))
+		for id := range db.items {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		page := map[string]interface{}{"Items": []service.DynamoItem{}}
+		for _, id := range ids {
+			if input.ExclusiveStartKey == nil || id > *input.ExclusiveStartKey["id"].S {
+				page["Items"] = []service.DynamoItem{db.items[id]}
+				if input.Select == "COUNT" {
+					page = map[string]interface{}{"Count": 1}
+				}
+				page["LastEvaluatedKey"] = service.DynamoItem{"id": db.items[id]["id"]}
+				break
+			}
+		}
+		output = page
+	default:
+		http.Error(w, `{"__type":"UnknownOperationException"}`, http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(output)
+}
+
+func TestDynamoLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	db := &fakeDynamoDB{items: make(map[string]service.DynamoItem)}
+	server := httptest.NewServer(db)
+	defer server.Close()
+
+	client := service.NewDynamoHTTPClient(service.DynamoConfig{
+		Region:      "eu-west-1",
+		Endpoint:    server.URL,
+		Credentials: service.DynamoCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+	store := service.NewDynamoLaptopStore(client, "laptops")
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop1))
+	require.NoError(t, store.Save(context.Background(), laptop2))
+	require.ErrorIs(t, store.Save(context.Background(), laptop1), service.ErrAlreadyExist)
+	require.NoError(t, store.Update(context.Background(), laptop2))
+	require.Equal(t, uint64(1), laptop2.GetVersion())
+	stale := sample.NewLaptop()
+	stale.Id = laptop2.GetId()
+	require.ErrorIs(t, store.Update(context.Background(), stale), service.ErrVersionConflict)
+	require.ErrorIs(t, store.Update(context.Background(), sample.NewLaptop()), service.ErrNotFound)
+	require.ErrorIs(t, store.Delete(context.Background(), "unknown"), service.ErrNotFound)
+	require.NoError(t, store.ForTenant("other").Save(context.Background(), laptop1), "tenants have their own keys")
+
+	laptop3 := sample.NewLaptop()
+	require.ErrorIs(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop3, laptop1}), service.ErrAlreadyExist)
+	require.ErrorIs(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop3, laptop3}), service.ErrAlreadyExist)
+	require.NoError(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop3}))
+	require.NoError(t, store.Delete(context.Background(), laptop3.GetId()))
+
+	laptop, err := store.Find(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.Equal(t, laptop1.GetName(), laptop.GetName())
+
+	laptop, err = store.Find(context.Background(), "unknown")
+	require.NoError(t, err)
+	require.Nil(t, laptop)
+
+	var found []string
+	err = store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 3000}, func(laptop *pb.Laptop) error {
+		found = append(found, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 3, "all pages are read")
+	require.Len(t, db.scans, 4)
+	require.Equal(t, "#tenant = :tenant AND #price_usd <= :v0", db.scans[0])
+
+	count, err := store.Count(context.Background(), &pb.Filter{MaxPriceUsd: 3000})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count, "the counts of all pages are summed")
+}
+
+func TestFilterToDynamo(t *testing.T) {
+	t.Parallel()
+
+	filter := &pb.Filter{
+		MinCpuCores: 4,
+		Expression: or(
+			condition("brand", pb.Condition_EQ, "Apple"),
+			not(condition("id", pb.Condition_EQ, "laptop")),
+			and(),
+		),
+	}
+	expression, names, values, err := service.FilterToDynamo(filter)
+	require.NoError(t, err)
+	require.Equal(t, "#cpu_number_cores >= :v0 AND (#brand = :v1 OR NOT (#laptop_id = :v2) OR attribute_exists(#id))", expression)
+	require.Equal(t, map[string]string{
+		"#cpu_number_cores": "cpu_number_cores",
+		"#brand":            "brand",
+		"#laptop_id":        "laptop_id",
+		"#id":               "id",
+	}, names)
+	require.Equal(t, "4", *values[":v0"].N)
+	require.Equal(t, "Apple", *values[":v1"].S)
+}
@@ -0,0 +1,555 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+	"net/http"
+	"net/url"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// elasticPageSize is the number of laptops fetched by each search request of Search.
+const elasticPageSize = 100
+
+// elasticTextFields are the filter fields that are also indexed for full-text search.
+var elasticTextFields = []string{"brand", "name", "cpu.brand", "cpu.name"}
+
+// ElasticError is an error returned by Elasticsearch.
+type ElasticError struct {
+	StatusCode int
+	Type       string
+	Reason     string
+}
+
+func (err *ElasticError) Error() string {
+	return fmt.Sprintf("elasticsearch: %s: %s (status %d)", err.Type, err.Reason, err.StatusCode)
+}
+
+// ElasticLaptopStore indexes laptops into an Elasticsearch index through its REST API.
+// Each document holds the serialized laptop, its tenant and one field per filter field, used by Search.
+// The credentials of the cluster, if any, are given as the user info of the URL.
+type ElasticLaptopStore struct {
+	client *http.Client
+	url    string
+	index  string
+	tenant string
+}
+
+// NewElasticLaptopStore returns a new ElasticLaptopStore of the index of the cluster at url,
+// and creates the index with its mapping if it doesn't exist. The client is http.DefaultClient if nil.
+func NewElasticLaptopStore(ctx context.Context, client *http.Client, url string, index string) (*ElasticLaptopStore, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	store := &ElasticLaptopStore{client: client, url: url, index: index}
+
+	err := store.do(ctx, http.MethodPut, "", elasticMapping(), nil)
+	var elasticErr *ElasticError
+	if errors.As(err, &elasticErr) && elasticErr.Type == "resource_already_exists_exception" {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create index: %w", err)
+	}
+	return store, nil
+}
+
+// elasticMapping returns the mapping of the index: the keys are keywords, the filter fields are
+// stored by column and the laptop is stored as binary, which is not indexed.
+func elasticMapping() map[string]interface{} {
+	properties := map[string]interface{}{
+		"key":    map[string]interface{}{"type": "keyword"},
+		"tenant": map[string]interface{}{"type": "keyword"},
+		"data":   map[string]interface{}{"type": "binary"},
+	}
+	for _, field := range filterFields {
+		switch field.kind {
+		case stringField:
+			properties[field.column] = map[string]interface{}{"type": "keyword"}
+		case numberField:
+			properties[field.column] = map[string]interface{}{"type": "double"}
+		case boolField:
+			properties[field.column] = map[string]interface{}{"type": "boolean"}
+		case memoryField:
+			properties[field.column] = map[string]interface{}{"type": "unsigned_long"}
+		}
+	}
+	for _, name := range elasticTextFields {
+		column := filterFields[name].column
+		properties[column] = map[string]interface{}{
+			"type":   "keyword",
+			"fields": map[string]interface{}{"text": map[string]interface{}{"type": "text"}},
+		}
+	}
+
+	return map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"dynamic":    "strict",
+			"properties": properties,
+		},
+	}
+}
+
+// ForTenant returns the laptop store of the tenant, sharing the same index.
+func (store *ElasticLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &ElasticLaptopStore{client: store.client, url: store.url, index: store.index, tenant: tenant}
+}
+
+func (store *ElasticLaptopStore) key(id string) string {
+	if store.tenant != "" {
+		return store.tenant + "/" + id
+	}
+	return id
+}
+
+// Save saves the laptop to the store. The laptop is visible to Search when Save returns.
+func (store *ElasticLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	key := store.key(laptop.GetId())
+	document, err := store.document(key, laptop)
+	if err != nil {
+		return err
+	}
+
+	err = store.do(ctx, http.MethodPut, "/_create/"+url.PathEscape(key)+"?refresh=wait_for", document, nil)
+	var elasticErr *ElasticError
+	if errors.As(err, &elasticErr) && elasticErr.StatusCode == http.StatusConflict {
+		return ErrAlreadyExist
+	}
+	if err != nil {
+		return fmt.Errorf("cannot index laptop: %w", err)
+	}
+	return nil
+}
+
+// SaveBatch saves the laptops to the store, deleting the saved ones if one of them can't be saved.
+// As Elasticsearch has no transactions, the first laptops are visible before the batch fails.
+func (store *ElasticLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	return saveEach(ctx, store, laptops)
+}
+
+// Update replaces the laptop with the same ID and version in the store. The laptop is visible to Search when Update returns.
+func (store *ElasticLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	key := store.key(laptop.GetId())
+	stored, err := store.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	var storedLaptop *pb.Laptop
+	if stored != nil {
+		storedLaptop = stored.laptop
+	}
+	if err := checkVersion(storedLaptop, laptop); err != nil {
+		return err
+	}
+
+	next := nextVersion(laptop)
+	document, err := store.document(key, next)
+	if err != nil {
+		return err
+	}
+
+	// Every field of the document is set, so merging the document replaces the stored one.
+	// The update fails with a conflict if the document was written since it was read.
+	input := map[string]interface{}{"doc": document}
+	path := fmt.Sprintf("/_update/%s?refresh=wait_for&if_seq_no=%d&if_primary_term=%d", url.PathEscape(key), stored.seqNo, stored.primaryTerm)
+	err = store.do(ctx, http.MethodPost, path, input, nil)
+	var elasticErr *ElasticError
+	if errors.As(err, &elasticErr) && elasticErr.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if errors.As(err, &elasticErr) && elasticErr.StatusCode == http.StatusConflict {
+		return ErrVersionConflict
+	}
+	if err != nil {
+		return fmt.Errorf("cannot update laptop: %w", err)
+	}
+
+	laptop.Version = next.Version
+	return nil
+}
+
+// Delete deletes the laptop with the ID from the store. The laptop is gone from Search when Delete returns.
+func (store *ElasticLaptopStore) Delete(ctx context.Context, id string) error {
+	err := store.do(ctx, http.MethodDelete, "/_doc/"+url.PathEscape(store.key(id))+"?refresh=wait_for", nil, nil)
+	var elasticErr *ElasticError
+	if errors.As(err, &elasticErr) && elasticErr.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("cannot delete laptop: %w", err)
+	}
+	return nil
+}
+
+// document returns the document of the laptop.
+func (store *ElasticLaptopStore) document(key string, laptop *pb.Laptop) (map[string]interface{}, error) {
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	document := map[string]interface{}{
+		"key":    key,
+		"tenant": store.tenant,
+		"data":   data,
+	}
+	for _, field := range filterFields {
+		document[field.column] = field.value(laptop)
+	}
+	return document, nil
+}
+
+// elasticSource is the part of the documents read back from the index.
+type elasticSource struct {
+	Data []byte `json:"data"`
+}
+
+// Find finds a laptop by ID
+func (store *ElasticLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	stored, err := store.get(ctx, store.key(id))
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	return stored.laptop, nil
+}
+
+// elasticStored is a laptop read from the index, with the sequence number and primary term
+// of its document, which change with every write of the document.
+type elasticStored struct {
+	laptop      *pb.Laptop
+	seqNo       int64
+	primaryTerm int64
+}
+
+// get gets the laptop of the document with the key, or nil if there is none.
+func (store *ElasticLaptopStore) get(ctx context.Context, key string) (*elasticStored, error) {
+	var output struct {
+		SeqNo       int64         `json:"_seq_no"`
+		PrimaryTerm int64         `json:"_primary_term"`
+		Source      elasticSource `json:"_source"`
+	}
+	err := store.do(ctx, http.MethodGet, "/_doc/"+url.PathEscape(key), nil, &output)
+	var elasticErr *ElasticError
+	if errors.As(err, &elasticErr) && elasticErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get laptop: %w", err)
+	}
+
+	laptop, err := unmarshalLaptop(output.Source.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &elasticStored{laptop: laptop, seqNo: output.SeqNo, primaryTerm: output.PrimaryTerm}, nil
+}
+
+// Search searches for laptops with filter, returns one by one via the found function.
+func (store *ElasticLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	query, err := store.query(filter)
+	if err != nil {
+		return err
+	}
+	sort, err := elasticSort(filter.GetSortBy())
+	if err != nil {
+		return err
+	}
+
+	input := map[string]interface{}{
+		"size":    elasticPageSize,
+		"_source": []string{"data"},
+		"sort":    sort,
+		"query":   query,
+	}
+	for {
+		var output struct {
+			Hits struct {
+				Hits []struct {
+					Source elasticSource `json:"_source"`
+					Sort   []interface{} `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		err := store.do(ctx, http.MethodPost, "/_search", input, &output)
+		if err != nil {
+			return fmt.Errorf("cannot search laptops: %w", err)
+		}
+
+		hits := output.Hits.Hits
+		for _, hit := range hits {
+			laptop, err := unmarshalLaptop(hit.Source.Data)
+			if err != nil {
+				return err
+			}
+			err = found(laptop)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(hits) < elasticPageSize {
+			return nil
+		}
+		input["search_after"] = hits[len(hits)-1].Sort
+	}
+}
+
+// elasticSort returns the sort of a search for the laptops sorted by the fields, then by key,
+// which is unique so search_after never skips a laptop.
+func elasticSort(sortBy []*pb.Sort) ([]interface{}, error) {
+	err := ValidateSort(sortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]interface{}, 0, len(sortBy)+1)
+	for _, sort := range sortBy {
+		order := "asc"
+		if sort.GetDescending() {
+			order = "desc"
+		}
+		fields = append(fields, map[string]interface{}{filterFields[sortFields[sort.GetField()]].column: order})
+	}
+	return append(fields, map[string]interface{}{"key": "asc"}), nil
+}
+
+// Count returns the number of laptops matching the filter
+func (store *ElasticLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	query, err := store.query(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var output struct {
+		Count int64 `json:"count"`
+	}
+	err = store.do(ctx, http.MethodPost, "/_count", map[string]interface{}{"query": query}, &output)
+	if err != nil {
+		return 0, fmt.Errorf("cannot count laptops: %w", err)
+	}
+	return output.Count, nil
+}
+
+// query returns the query of the laptops of the tenant matching the filter.
+func (store *ElasticLaptopStore) query(filter *pb.Filter) (map[string]interface{}, error) {
+	query, err := FilterToElastic(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{elasticTerm("tenant", store.tenant), query},
+		},
+	}, nil
+}
+
+// List returns a page of laptops in ID order
+func (store *ElasticLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+	after, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := map[string]interface{}{
+		"size":    pageSize + 1,
+		"_source": []string{"data"},
+		"sort":    []interface{}{map[string]interface{}{"key": "asc"}},
+		"query":   elasticTerm("tenant", store.tenant),
+	}
+	if after != "" {
+		input["search_after"] = []string{store.key(after)}
+	}
+
+	var output struct {
+		Hits struct {
+			Hits []struct {
+				Source elasticSource `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	err = store.do(ctx, http.MethodPost, "/_search", input, &output)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot search laptops: %w", err)
+	}
+
+	laptops := make([]*pb.Laptop, len(output.Hits.Hits))
+	for i, hit := range output.Hits.Hits {
+		laptops[i], err = unmarshalLaptop(hit.Source.Data)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	laptops, nextPageToken := nextPage(laptops, pageSize)
+	return laptops, nextPageToken, nil
+}
+
+// do calls the REST API on the path of the index.
+func (store *ElasticLaptopStore) do(ctx context.Context, method string, path string, input interface{}, output interface{}) error {
+	var body io.Reader
+	if input != nil {
+		data, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, store.url+"/"+url.PathEscape(store.index)+path, body)
+	if err != nil {
+		return err
+	}
+	if input != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := store.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		elasticErr := &ElasticError{StatusCode: res.StatusCode, Type: http.StatusText(res.StatusCode)}
+		if json.Unmarshal(data, &failure) == nil && failure.Error.Type != "" {
+			elasticErr.Type, elasticErr.Reason = failure.Error.Type, failure.Error.Reason
+		}
+		return elasticErr
+	}
+
+	if output == nil {
+		return nil
+	}
+	return json.Unmarshal(data, output)
+}
+
+// FilterToElastic translates the filter into an Elasticsearch query
+// on the documents of ElasticLaptopStore.
+func FilterToElastic(filter *pb.Filter) (map[string]interface{}, error) {
+	expression := filter.GetExpression()
+	err := ValidateExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []interface{}
+	if expression == nil || filter.GetMaxPriceUsd() != 0 {
+		conditions = append(conditions, elasticRange("price_usd", "lte", filter.GetMaxPriceUsd()))
+	}
+	if filter.GetMinCpuCores() != 0 {
+		conditions = append(conditions, elasticRange("cpu.number_cores", "gte", filter.GetMinCpuCores()))
+	}
+	if filter.GetMinCpuGhz() != 0 {
+		conditions = append(conditions, elasticRange("cpu.min_ghz", "gte", filter.GetMinCpuGhz()))
+	}
+	if filter.GetMinRam() != nil {
+		conditions = append(conditions, elasticRange("ram", "gte", toBit(filter.GetMinRam())))
+	}
+	if expression != nil {
+		conditions = append(conditions, expressionToElastic(expression))
+	}
+	if filter.GetText() != "" {
+		fields := make([]string, len(elasticTextFields))
+		for i, name := range elasticTextFields {
+			fields[i] = filterFields[name].column + ".text"
+		}
+		conditions = append(conditions, map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":    filter.GetText(),
+				"fields":   fields,
+				"type":     "cross_fields",
+				"operator": "and",
+			},
+		})
+	}
+
+	return elasticBool("filter", conditions), nil
+}
+
+var elasticRangeOperators = map[pb.Condition_Operator]string{
+	pb.Condition_LT: "lt",
+	pb.Condition_LE: "lte",
+	pb.Condition_GT: "gt",
+	pb.Condition_GE: "gte",
+}
+
+func elasticTerm(column string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"term": map[string]interface{}{column: value}}
+}
+
+func elasticRange(field string, operator string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			filterFields[field].column: map[string]interface{}{operator: value},
+		},
+	}
+}
+
+func elasticBool(occur string, queries []interface{}) map[string]interface{} {
+	if queries == nil {
+		queries = []interface{}{}
+	}
+	return map[string]interface{}{"bool": map[string]interface{}{occur: queries}}
+}
+
+func expressionToElastic(expression *pb.Expression) map[string]interface{} {
+	switch node := expression.GetNode().(type) {
+	case *pb.Expression_Condition:
+		condition := node.Condition
+		value := conditionValue(condition)
+		switch operator := condition.GetOperator(); operator {
+		case pb.Condition_EQ:
+			return elasticTerm(filterFields[condition.GetField()].column, value)
+		case pb.Condition_NE:
+			return elasticBool("must_not", []interface{}{elasticTerm(filterFields[condition.GetField()].column, value)})
+		default:
+			return elasticRange(condition.GetField(), elasticRangeOperators[operator], value)
+		}
+	case *pb.Expression_And:
+		return elasticBool("filter", elasticQueries(node.And.GetExpressions()))
+	case *pb.Expression_Or:
+		if len(node.Or.GetExpressions()) == 0 {
+			return map[string]interface{}{"match_none": map[string]interface{}{}}
+		}
+		query := elasticBool("should", elasticQueries(node.Or.GetExpressions()))
+		query["bool"].(map[string]interface{})["minimum_should_match"] = 1
+		return query
+	case *pb.Expression_Not:
+		return elasticBool("must_not", []interface{}{expressionToElastic(node.Not)})
+	default:
+		return map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+}
+
+func elasticQueries(expressions []*pb.Expression) []interface{} {
+	queries := make([]interface{}, len(expressions))
+	for i, expression := range expressions {
+		queries[i] = expressionToElastic(expression)
+	}
+	return queries
+}
@@ -0,0 +1,199 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeElastic serves the document and search APIs of a single index.
+// The search API ignores the query and returns the documents sorted by key.
+// Every write of a document increments its sequence number, and the primary term is always 1.
+type fakeElastic struct {
+	mutex     sync.Mutex
+	created   bool
+	documents map[string]map[string]interface{}
+	seqNos    map[string]int
+	queries   []interface{}
+}
+
+func (elastic *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	elastic.mutex.Lock()
+	defer elastic.mutex.Unlock()
+
+	var input map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&input)
+	}
+
+	if elastic.seqNos == nil {
+		elastic.seqNos = make(map[string]int)
+	}
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/laptops")
+	switch {
+	case r.Method == http.MethodPut && path == "":
+		if elastic.created {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"type":"resource_already_exists_exception","reason":"index [laptops] already exists"}}`))
+			return
+		}
+		elastic.created = true
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/_create/"):
+		key, _ := url.PathUnescape(strings.TrimPrefix(path, "/_create/"))
+		if _, ok := elastic.documents[key]; ok {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":{"type":"version_conflict_engine_exception","reason":"document already exists"}}`))
+			return
+		}
+		elastic.documents[key] = input
+		elastic.seqNos[key]++
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/_update/"):
+		key, _ := url.PathUnescape(strings.TrimPrefix(path, "/_update/"))
+		if _, ok := elastic.documents[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"document_missing_exception","reason":"document missing"}}`))
+			return
+		}
+		if r.URL.Query().Get("if_seq_no") != strconv.Itoa(elastic.seqNos[key]) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":{"type":"version_conflict_engine_exception","reason":"version conflict"}}`))
+			return
+		}
+		elastic.documents[key] = input["doc"].(map[string]interface{})
+		elastic.seqNos[key]++
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/_doc/"):
+		key, _ := url.PathUnescape(strings.TrimPrefix(path, "/_doc/"))
+		if _, ok := elastic.documents[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"result":"not_found"}`))
+			return
+		}
+		delete(elastic.documents, key)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/_doc/"):
+		key, _ := url.PathUnescape(strings.TrimPrefix(path, "/_doc/"))
+		document, ok := elastic.documents[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"found":false}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"_source":       document,
+			"_seq_no":       elastic.seqNos[key],
+			"_primary_term": 1,
+		})
+	case r.Method == http.MethodPost && path == "/_count":
+		elastic.queries = append(elastic.queries, input["query"])
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": len(elastic.documents)})
+	case r.Method == http.MethodPost && path == "/_search":
+		elastic.queries = append(elastic.queries, input["query"])
+		keys := make([]string, 0, len(elastic.documents))
+		for key := range elastic.documents {
+			after, _ := input["search_after"].([]interface{})
+			if len(after) == 0 || key > after[0].(string) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		if size := int(input["size"].(float64)); len(keys) > size {
+			keys = keys[:size]
+		}
+
+		hits := make([]interface{}, len(keys))
+		for i, key := range keys {
+			hits[i] = map[string]interface{}{"_source": elastic.documents[key], "sort": []string{key}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestElasticLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	elastic := &fakeElastic{documents: make(map[string]map[string]interface{})}
+	server := httptest.NewServer(elastic)
+	defer server.Close()
+
+	store, err := service.NewElasticLaptopStore(context.Background(), server.Client(), server.URL, "laptops")
+	require.NoError(t, err)
+	_, err = service.NewElasticLaptopStore(context.Background(), server.Client(), server.URL, "laptops")
+	require.NoError(t, err, "the index already exists")
+
+	n := 150
+	for i := 0; i < n; i++ {
+		require.NoError(t, store.Save(context.Background(), sample.NewLaptop()))
+	}
+	laptop1 := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop1))
+	require.ErrorIs(t, store.Save(context.Background(), laptop1), service.ErrAlreadyExist)
+	require.NoError(t, store.Update(context.Background(), laptop1))
+	require.Equal(t, uint64(1), laptop1.GetVersion())
+	stale := sample.NewLaptop()
+	stale.Id = laptop1.GetId()
+	require.ErrorIs(t, store.Update(context.Background(), stale), service.ErrVersionConflict)
+	require.ErrorIs(t, store.Update(context.Background(), sample.NewLaptop()), service.ErrNotFound)
+	require.ErrorIs(t, store.Delete(context.Background(), "unknown"), service.ErrNotFound)
+
+	laptop, err := store.Find(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.Equal(t, laptop1.GetName(), laptop.GetName())
+
+	laptop, err = store.ForTenant("other").Find(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop)
+
+	count := 0
+	err = store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 3000}, func(laptop *pb.Laptop) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, n+1, count, "all pages are read")
+	require.Len(t, elastic.queries, 2)
+	require.Len(t, listAll(t, store, 40), n+1)
+
+	total, err := store.Count(context.Background(), &pb.Filter{MaxPriceUsd: 3000})
+	require.NoError(t, err)
+	require.Equal(t, int64(n+1), total)
+	require.Equal(t, elastic.queries[1], elastic.queries[len(elastic.queries)-1], "count and search share the query")
+}
+
+func TestFilterToElastic(t *testing.T) {
+	t.Parallel()
+
+	filter := &pb.Filter{
+		MinCpuCores: 4,
+		Expression:  not(condition("brand", pb.Condition_NE, "Apple")),
+		Text:        "macbook pro",
+	}
+	query, err := service.FilterToElastic(filter)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(query)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"bool": {"filter": [
+		{"range": {"cpu_number_cores": {"gte": 4}}},
+		{"bool": {"must_not": [{"bool": {"must_not": [{"term": {"brand": "Apple"}}]}}]}},
+		{"multi_match": {
+			"query": "macbook pro",
+			"fields": ["brand.text", "name.text", "cpu_brand.text", "cpu_name.text"],
+			"type": "cross_fields",
+			"operator": "and"
+		}}
+	]}}`, string(data))
+}
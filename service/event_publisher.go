@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"grpc_app/logging"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// publishBufferSize is the number of events buffered for the publisher,
+// beyond which the events are dropped until it catches up.
+const publishBufferSize = 1000
+
+// publishTimeout is the time given to the publisher to publish an event.
+const publishTimeout = 5 * time.Second
+
+// EventPublisher publishes the events of the laptops to a message broker,
+// so the other systems can react to the changes of the catalog.
+type EventPublisher interface {
+	// Publish publishes the event, or returns an error if it can't be published.
+	Publish(ctx context.Context, event LaptopEvent) error
+}
+
+// eventTypeNames are the names of the types of events in the published messages.
+var eventTypeNames = map[LaptopEventType]string{
+	LaptopCreated: "created",
+	LaptopUpdated: "updated",
+	LaptopDeleted: "deleted",
+}
+
+// PublishLaptopEvents publishes the events of the laptops of all the tenants of the store
+// with the publisher, until the context is done. The events buffered when the context is done are still
+// published before it returns, so that the server may stop without losing them. The events that can't be
+// published are logged to the logger, or to the default logger if it's nil, and dropped.
+func PublishLaptopEvents(ctx context.Context, store *WatchLaptopStore, publisher EventPublisher, logger *logging.Logger) {
+	if logger == nil {
+		logger = logging.Default()
+	}
+
+	events := make(chan LaptopEvent, publishBufferSize)
+	stop := store.WatchAll(events)
+
+	publish := func(ctx context.Context, event LaptopEvent) {
+		publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		err := publisher.Publish(publishCtx, event)
+		cancel()
+		if err != nil {
+			logger.Error("cannot publish event", "type", eventTypeNames[event.Type], "laptop_id", event.Laptop.GetId(), "error", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			for {
+				select {
+				case event := <-events:
+					publish(context.Background(), event)
+				default:
+					return
+				}
+			}
+		case event := <-events:
+			publish(ctx, event)
+		}
+	}
+}
+
+// MarshalLaptopEvent returns the JSON message of the event, with its type, its tenant
+// and the laptop in the JSON mapping of protobuf.
+func MarshalLaptopEvent(event LaptopEvent) ([]byte, error) {
+	laptop, err := protojson.Marshal(event.Laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+	return json.Marshal(struct {
+		Type   string          `json:"type"`
+		Tenant string          `json:"tenant,omitempty"`
+		Laptop json.RawMessage `json:"laptop"`
+	}{eventTypeNames[event.Type], event.Tenant, laptop})
+}
+
+// LogEventPublisher is an EventPublisher writing the events to a logger, e.g. in development.
+type LogEventPublisher struct {
+	logger *logging.Logger
+}
+
+// NewLogEventPublisher returns a new LogEventPublisher writing to the logger at the info level,
+// or to the default logger if it's nil.
+func NewLogEventPublisher(logger *logging.Logger) *LogEventPublisher {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &LogEventPublisher{logger: logger}
+}
+
+// Publish writes the JSON message of the event to the logger
+func (publisher *LogEventPublisher) Publish(ctx context.Context, event LaptopEvent) error {
+	message, err := MarshalLaptopEvent(event)
+	if err != nil {
+		return err
+	}
+	publisher.logger.Info("event", "message", string(message))
+	return nil
+}
@@ -0,0 +1,4 @@
+package service
+
+// SignV4 exposes signV4 to the known-answer tests of the AWS Signature Version 4 test suite.
+var SignV4 = signV4
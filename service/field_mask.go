@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// fieldTree is the set of field paths of a field mask, by field name.
+type fieldTree map[protoreflect.Name]fieldTree
+
+// validateFieldMask checks that the paths of the mask are fields of the message.
+func validateFieldMask(mask *fieldmaskpb.FieldMask, message proto.Message) error {
+	if mask == nil {
+		return nil
+	}
+
+	for _, path := range mask.GetPaths() {
+		if !(&fieldmaskpb.FieldMask{Paths: []string{path}}).IsValid(message) {
+			return fmt.Errorf("unknown field %q", path)
+		}
+	}
+	return nil
+}
+
+// applyFieldMask clears the fields of the message that are not in a valid mask.
+// An empty mask keeps all the fields.
+func applyFieldMask(mask *fieldmaskpb.FieldMask, message proto.Message) {
+	if len(mask.GetPaths()) == 0 {
+		return
+	}
+
+	tree := fieldTree{}
+	for _, path := range mask.GetPaths() {
+		node := tree
+		for _, name := range strings.Split(path, ".") {
+			child, ok := node[protoreflect.Name(name)]
+			if !ok {
+				child = fieldTree{}
+				node[protoreflect.Name(name)] = child
+			}
+			node = child
+		}
+	}
+
+	prune(message.ProtoReflect(), tree)
+}
+
+// prune clears the fields of the message that are not in the tree.
+// The selected sub-messages are cloned before being pruned, since they may be
+// shared with the store.
+func prune(message protoreflect.Message, tree fieldTree) {
+	var cleared []protoreflect.FieldDescriptor
+	pruned := make(map[protoreflect.FieldDescriptor]protoreflect.Message)
+
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		children, ok := tree[field.Name()]
+		switch {
+		case !ok:
+			cleared = append(cleared, field)
+		case len(children) > 0:
+			// Only some fields of the sub-message are selected, e.g. cpu.brand.
+			other := proto.Clone(value.Message().Interface()).ProtoReflect()
+			prune(other, children)
+			pruned[field] = other
+		}
+		return true
+	})
+
+	for _, field := range cleared {
+		message.Clear(field)
+	}
+	for field, other := range pruned {
+		message.Set(field, protoreflect.ValueOfMessage(other))
+	}
+}
+
+// mergeFieldMask sets the fields of dst that are in a valid mask to their value in src,
+// clearing the ones src doesn't have. The merged sub-messages are cloned from src.
+func mergeFieldMask(mask *fieldmaskpb.FieldMask, dst proto.Message, src proto.Message) {
+	for _, path := range mask.GetPaths() {
+		names := strings.Split(path, ".")
+		to := dst.ProtoReflect()
+		from := src.ProtoReflect()
+		for _, name := range names[:len(names)-1] {
+			field := to.Descriptor().Fields().ByName(protoreflect.Name(name))
+			to = to.Mutable(field).Message()
+			from = from.Get(field).Message()
+		}
+
+		field := to.Descriptor().Fields().ByName(protoreflect.Name(names[len(names)-1]))
+		if !from.Has(field) {
+			to.Clear(field)
+			continue
+		}
+		to.Set(field, cloneValue(to, field, from.Get(field)))
+	}
+}
+
+// cloneValue returns a copy of the value of the field of message, which doesn't share the sub-messages.
+func cloneValue(message protoreflect.Message, field protoreflect.FieldDescriptor, value protoreflect.Value) protoreflect.Value {
+	switch {
+	case field.Message() == nil || field.IsMap():
+		return value
+	case field.IsList():
+		list := message.NewField(field).List()
+		for i := 0; i < value.List().Len(); i++ {
+			element := value.List().Get(i).Message().Interface()
+			list.Append(protoreflect.ValueOfMessage(proto.Clone(element).ProtoReflect()))
+		}
+		return protoreflect.ValueOfList(list)
+	default:
+		return protoreflect.ValueOfMessage(proto.Clone(value.Message().Interface()).ProtoReflect())
+	}
+}
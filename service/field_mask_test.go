@@ -0,0 +1,53 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestServerGetLaptopWithReadMask(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	store := service.NewInMemoryLaptopStore()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	server := service.NewLaptopServer(store, nil, nil)
+
+	req := &pb.GetLaptopRequest{
+		Id:       laptop.GetId(),
+		ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"id", "brand", "price_usd", "cpu.name"}},
+	}
+	res, err := server.GetLaptop(context.Background(), req)
+	require.NoError(t, err)
+
+	got := res.GetLaptop()
+	require.Equal(t, laptop.GetId(), got.GetId())
+	require.Equal(t, laptop.GetBrand(), got.GetBrand())
+	require.Equal(t, laptop.GetPriceUsd(), got.GetPriceUsd())
+	require.Equal(t, laptop.GetCpu().GetName(), got.GetCpu().GetName())
+	require.Empty(t, got.GetCpu().GetBrand())
+	require.Empty(t, got.GetName())
+	require.Nil(t, got.GetRam())
+	require.Nil(t, got.GetKeyboard())
+	require.Empty(t, got.GetGpus())
+
+	// The stored laptop is not pruned.
+	stored, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetCpu().GetBrand(), stored.GetCpu().GetBrand())
+
+	req.ReadMask = &fieldmaskpb.FieldMask{Paths: []string{"unknown"}}
+	_, err = server.GetLaptop(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
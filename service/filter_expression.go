@@ -0,0 +1,295 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"strings"
+)
+
+// maxExpressionDepth limits the nesting of filter expressions.
+const maxExpressionDepth = 32
+
+type fieldKind int
+
+const (
+	stringField fieldKind = iota
+	numberField
+	boolField
+	memoryField
+)
+
+// filterField is a laptop field that can be used in a filter expression.
+type filterField struct {
+	kind fieldKind
+	// column is the column of the field in SQL stores.
+	column string
+	// value returns the field of the laptop as a string, float64, bool or number of bits.
+	value func(laptop *pb.Laptop) interface{}
+}
+
+var filterFields = map[string]filterField{
+	"id":                 {stringField, "id", func(l *pb.Laptop) interface{} { return l.GetId() }},
+	"brand":              {stringField, "brand", func(l *pb.Laptop) interface{} { return l.GetBrand() }},
+	"name":               {stringField, "name", func(l *pb.Laptop) interface{} { return l.GetName() }},
+	"price_usd":          {numberField, "price_usd", func(l *pb.Laptop) interface{} { return l.GetPriceUsd() }},
+	"release_year":       {numberField, "release_year", func(l *pb.Laptop) interface{} { return float64(l.GetReleaseYear()) }},
+	"weight_kg":          {numberField, "weight_kg", func(l *pb.Laptop) interface{} { return weightKg(l) }},
+	"cpu.brand":          {stringField, "cpu_brand", func(l *pb.Laptop) interface{} { return l.GetCpu().GetBrand() }},
+	"cpu.name":           {stringField, "cpu_name", func(l *pb.Laptop) interface{} { return l.GetCpu().GetName() }},
+	"cpu.number_cores":   {numberField, "cpu_number_cores", func(l *pb.Laptop) interface{} { return float64(l.GetCpu().GetNumberCores()) }},
+	"cpu.number_threads": {numberField, "cpu_number_threads", func(l *pb.Laptop) interface{} { return float64(l.GetCpu().GetNumberThreads()) }},
+	"cpu.min_ghz":        {numberField, "cpu_min_ghz", func(l *pb.Laptop) interface{} { return l.GetCpu().GetMinGhz() }},
+	"cpu.max_ghz":        {numberField, "cpu_max_ghz", func(l *pb.Laptop) interface{} { return l.GetCpu().GetMaxGhz() }},
+	"ram":                {memoryField, "ram_bits", func(l *pb.Laptop) interface{} { return toBit(l.GetRam()) }},
+	"screen.size_inch":   {numberField, "screen_size_inch", func(l *pb.Laptop) interface{} { return float64(l.GetScreen().GetSizeInch()) }},
+	"screen.multitouch":  {boolField, "screen_multitouch", func(l *pb.Laptop) interface{} { return l.GetScreen().GetMultitouch() }},
+	"keyboard.backlit":   {boolField, "keyboard_backlit", func(l *pb.Laptop) interface{} { return l.GetKeyboard().GetBacklit() }},
+}
+
+func weightKg(laptop *pb.Laptop) float64 {
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		return weight.WeightKg
+	case *pb.Laptop_WeightLb:
+		return weight.WeightLb * 0.45359237
+	default:
+		return 0
+	}
+}
+
+// ValidateExpression checks that the fields, operators and values of the expression are valid.
+// A nil expression is valid and matches every laptop.
+func ValidateExpression(expression *pb.Expression) error {
+	if expression == nil {
+		return nil
+	}
+	return validateExpression(expression, 1)
+}
+
+func validateExpression(expression *pb.Expression, depth int) error {
+	if depth > maxExpressionDepth {
+		return fmt.Errorf("expression is nested deeper than %d", maxExpressionDepth)
+	}
+
+	switch node := expression.GetNode().(type) {
+	case *pb.Expression_Condition:
+		return validateCondition(node.Condition)
+	case *pb.Expression_And:
+		return validateExpressions(node.And.GetExpressions(), depth)
+	case *pb.Expression_Or:
+		return validateExpressions(node.Or.GetExpressions(), depth)
+	case *pb.Expression_Not:
+		if node.Not == nil {
+			return errors.New("NOT expression is empty")
+		}
+		return validateExpression(node.Not, depth+1)
+	default:
+		return errors.New("expression is empty")
+	}
+}
+
+func validateExpressions(expressions []*pb.Expression, depth int) error {
+	for _, expression := range expressions {
+		err := validateExpression(expression, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateCondition(condition *pb.Condition) error {
+	field, ok := filterFields[condition.GetField()]
+	if !ok {
+		return fmt.Errorf("unknown field %q", condition.GetField())
+	}
+
+	operator := condition.GetOperator()
+	if operator == pb.Condition_OPERATOR_UNSPECIFIED {
+		return fmt.Errorf("operator of field %q is not specified", condition.GetField())
+	}
+
+	var valid bool
+	switch condition.GetValue().(type) {
+	case *pb.Condition_StringValue:
+		valid = field.kind == stringField
+	case *pb.Condition_NumberValue:
+		valid = field.kind == numberField
+	case *pb.Condition_BoolValue:
+		valid = field.kind == boolField
+	case *pb.Condition_MemoryValue:
+		valid = field.kind == memoryField
+	}
+	if !valid {
+		return fmt.Errorf("invalid value type for field %q", condition.GetField())
+	}
+
+	if field.kind == boolField && operator != pb.Condition_EQ && operator != pb.Condition_NE {
+		return fmt.Errorf("operator %s is not supported by field %q", operator, condition.GetField())
+	}
+
+	return nil
+}
+
+// evaluate returns whether the laptop matches a valid expression.
+func evaluate(expression *pb.Expression, laptop *pb.Laptop) bool {
+	switch node := expression.GetNode().(type) {
+	case *pb.Expression_Condition:
+		return matches(node.Condition, laptop)
+	case *pb.Expression_And:
+		for _, expression := range node.And.GetExpressions() {
+			if !evaluate(expression, laptop) {
+				return false
+			}
+		}
+		return true
+	case *pb.Expression_Or:
+		for _, expression := range node.Or.GetExpressions() {
+			if evaluate(expression, laptop) {
+				return true
+			}
+		}
+		return false
+	case *pb.Expression_Not:
+		return !evaluate(node.Not, laptop)
+	default:
+		return true
+	}
+}
+
+func matches(condition *pb.Condition, laptop *pb.Laptop) bool {
+	value := filterFields[condition.GetField()].value(laptop)
+
+	var cmp int
+	switch v := value.(type) {
+	case string:
+		cmp = strings.Compare(v, condition.GetStringValue())
+	case float64:
+		cmp = compare(v, condition.GetNumberValue())
+	case uint64:
+		cmp = compare(v, toBit(condition.GetMemoryValue()))
+	case bool:
+		if v != condition.GetBoolValue() {
+			cmp = 1
+		}
+	}
+
+	switch condition.GetOperator() {
+	case pb.Condition_EQ:
+		return cmp == 0
+	case pb.Condition_NE:
+		return cmp != 0
+	case pb.Condition_LT:
+		return cmp < 0
+	case pb.Condition_LE:
+		return cmp <= 0
+	case pb.Condition_GT:
+		return cmp > 0
+	case pb.Condition_GE:
+		return cmp >= 0
+	default:
+		return false
+	}
+}
+
+func compare[T float64 | uint64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+var sqlOperators = map[pb.Condition_Operator]string{
+	pb.Condition_EQ: "=",
+	pb.Condition_NE: "<>",
+	pb.Condition_LT: "<",
+	pb.Condition_LE: "<=",
+	pb.Condition_GT: ">",
+	pb.Condition_GE: ">=",
+}
+
+// FilterToSQL translates the filter into a SQL condition with ? placeholders
+// and its arguments, for the stores that keep laptops in a SQL database.
+// Memory values are compared in bits.
+func FilterToSQL(filter *pb.Filter) (string, []interface{}, error) {
+	expression := filter.GetExpression()
+	err := ValidateExpression(expression)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var conditions []string
+	var args []interface{}
+
+	if expression == nil || filter.GetMaxPriceUsd() != 0 {
+		conditions = append(conditions, "price_usd <= ?")
+		args = append(args, filter.GetMaxPriceUsd())
+	}
+	if filter.GetMinCpuCores() != 0 {
+		conditions = append(conditions, "cpu_number_cores >= ?")
+		args = append(args, filter.GetMinCpuCores())
+	}
+	if filter.GetMinCpuGhz() != 0 {
+		conditions = append(conditions, "cpu_min_ghz >= ?")
+		args = append(args, filter.GetMinCpuGhz())
+	}
+	if filter.GetMinRam() != nil {
+		conditions = append(conditions, "ram_bits >= ?")
+		args = append(args, toBit(filter.GetMinRam()))
+	}
+
+	if expression != nil {
+		conditions = append(conditions, expressionToSQL(expression, &args))
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+func expressionToSQL(expression *pb.Expression, args *[]interface{}) string {
+	switch node := expression.GetNode().(type) {
+	case *pb.Expression_Condition:
+		condition := node.Condition
+		*args = append(*args, conditionValue(condition))
+		return fmt.Sprintf("%s %s ?", filterFields[condition.GetField()].column, sqlOperators[condition.GetOperator()])
+	case *pb.Expression_And:
+		return joinSQL(node.And.GetExpressions(), " AND ", "1 = 1", args)
+	case *pb.Expression_Or:
+		return joinSQL(node.Or.GetExpressions(), " OR ", "1 = 0", args)
+	case *pb.Expression_Not:
+		return "NOT (" + expressionToSQL(node.Not, args) + ")"
+	default:
+		return "1 = 1"
+	}
+}
+
+func joinSQL(expressions []*pb.Expression, separator string, empty string, args *[]interface{}) string {
+	if len(expressions) == 0 {
+		return empty
+	}
+
+	conditions := make([]string, len(expressions))
+	for i, expression := range expressions {
+		conditions[i] = expressionToSQL(expression, args)
+	}
+	return "(" + strings.Join(conditions, separator) + ")"
+}
+
+func conditionValue(condition *pb.Condition) interface{} {
+	switch value := condition.GetValue().(type) {
+	case *pb.Condition_StringValue:
+		return value.StringValue
+	case *pb.Condition_NumberValue:
+		return value.NumberValue
+	case *pb.Condition_BoolValue:
+		return value.BoolValue
+	case *pb.Condition_MemoryValue:
+		return toBit(value.MemoryValue)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func condition(field string, operator pb.Condition_Operator, value interface{}) *pb.Expression {
+	c := &pb.Condition{Field: field, Operator: operator}
+	switch v := value.(type) {
+	case string:
+		c.Value = &pb.Condition_StringValue{StringValue: v}
+	case float64:
+		c.Value = &pb.Condition_NumberValue{NumberValue: v}
+	case bool:
+		c.Value = &pb.Condition_BoolValue{BoolValue: v}
+	case *pb.Memory:
+		c.Value = &pb.Condition_MemoryValue{MemoryValue: v}
+	}
+	return &pb.Expression{Node: &pb.Expression_Condition{Condition: c}}
+}
+
+func and(expressions ...*pb.Expression) *pb.Expression {
+	return &pb.Expression{Node: &pb.Expression_And{And: &pb.Expression_List{Expressions: expressions}}}
+}
+
+func or(expressions ...*pb.Expression) *pb.Expression {
+	return &pb.Expression{Node: &pb.Expression_Or{Or: &pb.Expression_List{Expressions: expressions}}}
+}
+
+func not(expression *pb.Expression) *pb.Expression {
+	return &pb.Expression{Node: &pb.Expression_Not{Not: expression}}
+}
+
+func TestSearchWithExpression(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	brands := []string{"Apple", "Dell", "Lenovo"}
+	for i, brand := range brands {
+		laptop := sample.NewLaptop()
+		laptop.Brand = brand
+		laptop.PriceUsd = float64(1000 * (i + 1))
+		laptop.Ram = &pb.Memory{Value: uint64(8 << i), Unit: pb.Memory_GIGABYTE}
+		require.NoError(t, store.Save(context.Background(), laptop))
+	}
+
+	testCases := []struct {
+		name       string
+		expression *pb.Expression
+		brands     []string
+	}{
+		{
+			name:       "or",
+			expression: or(condition("brand", pb.Condition_EQ, "Apple"), condition("brand", pb.Condition_EQ, "Lenovo")),
+			brands:     []string{"Apple", "Lenovo"},
+		},
+		{
+			name: "and not",
+			expression: and(
+				condition("price_usd", pb.Condition_GE, 2000.0),
+				not(condition("ram", pb.Condition_GT, &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE})),
+			),
+			brands: []string{"Dell"},
+		},
+		{
+			name:       "empty and",
+			expression: and(),
+			brands:     brands,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter := &pb.Filter{Expression: tc.expression}
+			require.NoError(t, service.ValidateExpression(filter.GetExpression()))
+
+			var found []string
+			err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+				found = append(found, laptop.GetBrand())
+				return nil
+			})
+			require.NoError(t, err)
+			require.ElementsMatch(t, tc.brands, found)
+		})
+	}
+}
+
+func TestValidateExpression(t *testing.T) {
+	t.Parallel()
+
+	require.Error(t, service.ValidateExpression(condition("unknown", pb.Condition_EQ, "x")))
+	require.Error(t, service.ValidateExpression(condition("brand", pb.Condition_EQ, 1.0)))
+	require.Error(t, service.ValidateExpression(condition("brand", pb.Condition_OPERATOR_UNSPECIFIED, "x")))
+	require.Error(t, service.ValidateExpression(condition("keyboard.backlit", pb.Condition_LT, true)))
+	require.Error(t, service.ValidateExpression(and(&pb.Expression{})))
+}
+
+func TestFilterToSQL(t *testing.T) {
+	t.Parallel()
+
+	filter := &pb.Filter{
+		MinCpuCores: 4,
+		Expression: or(
+			condition("brand", pb.Condition_EQ, "Apple"),
+			not(condition("keyboard.backlit", pb.Condition_EQ, true)),
+		),
+	}
+
+	where, args, err := service.FilterToSQL(filter)
+	require.NoError(t, err)
+	require.Equal(t, "cpu_number_cores >= ? AND (brand = ? OR NOT (keyboard_backlit = ?))", where)
+	require.Equal(t, []interface{}{uint32(4), "Apple", true}, args)
+
+	where, args, err = service.FilterToSQL(&pb.Filter{MaxPriceUsd: 3000})
+	require.NoError(t, err)
+	require.Equal(t, "price_usd <= ?", where)
+	require.Equal(t, []interface{}{3000.0}, args)
+}
+
+func TestFilterText(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	laptop.Brand, laptop.Name = "Apple", "Macbook Pro"
+	require.NoError(t, store.Save(context.Background(), laptop))
+
+	for text, want := range map[string]int{"": 1, "macbook APPLE": 1, "pro": 1, "macbook air": 0} {
+		count := 0
+		err := store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 5000, Text: text}, func(laptop *pb.Laptop) error {
+			count++
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, want, count, text)
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrHoldNotFound is returned when releasing a hold that doesn't exist or has expired.
+var ErrHoldNotFound = errors.New("hold not found")
+
+// HoldStore is an interface to store exclusive holds on laptops.
+type HoldStore interface {
+	// Acquire places a hold of the holder on the laptop for ttl. Acquiring a hold
+	// again extends it. It returns a HoldConflictError if someone else holds the laptop.
+	Acquire(laptopID string, holder string, ttl time.Duration) (*Hold, error)
+	// Release releases the hold on the laptop.
+	Release(laptopID string, holdID string) error
+	// Check returns a HoldConflictError if someone else than the holder holds the laptop.
+	Check(laptopID string, holder string) error
+}
+
+// Hold is an exclusive hold on a laptop.
+type Hold struct {
+	ID        string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+// HoldConflictError is returned when a laptop is held by someone else.
+type HoldConflictError struct {
+	LaptopID string
+	Hold     Hold
+}
+
+func (err *HoldConflictError) Error() string {
+	return fmt.Sprintf("laptop %s is held by %s until %s", err.LaptopID, err.Hold.Holder, err.Hold.ExpiresAt.Format(time.RFC3339))
+}
+
+// InMemoryHoldStore stores laptop holds in memory.
+type InMemoryHoldStore struct {
+	mutex sync.Mutex
+	holds map[string]*Hold
+	now   func() time.Time
+}
+
+// NewInMemoryHoldStore returns a new InMemoryHoldStore.
+func NewInMemoryHoldStore() *InMemoryHoldStore {
+	return &InMemoryHoldStore{
+		holds: make(map[string]*Hold),
+		now:   time.Now,
+	}
+}
+
+// Acquire places a hold of the holder on the laptop for ttl.
+func (store *InMemoryHoldStore) Acquire(laptopID string, holder string, ttl time.Duration) (*Hold, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	hold := store.active(laptopID, now)
+	if hold != nil && hold.Holder != holder {
+		return nil, &HoldConflictError{LaptopID: laptopID, Hold: *hold}
+	}
+
+	if hold == nil {
+		hold = &Hold{ID: uuid.New().String(), Holder: holder}
+		store.holds[laptopID] = hold
+	}
+	hold.ExpiresAt = now.Add(ttl)
+
+	other := *hold
+	return &other, nil
+}
+
+// Release releases the hold on the laptop.
+func (store *InMemoryHoldStore) Release(laptopID string, holdID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	hold := store.active(laptopID, store.now())
+	if hold == nil || hold.ID != holdID {
+		return ErrHoldNotFound
+	}
+
+	delete(store.holds, laptopID)
+	return nil
+}
+
+// Check returns a HoldConflictError if someone else than the holder holds the laptop.
+func (store *InMemoryHoldStore) Check(laptopID string, holder string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	hold := store.active(laptopID, store.now())
+	if hold != nil && hold.Holder != holder {
+		return &HoldConflictError{LaptopID: laptopID, Hold: *hold}
+	}
+	return nil
+}
+
+// EraseUserData releases the holds of the user, and returns their number.
+func (store *InMemoryHoldStore) EraseUserData(username string) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	erased := 0
+	for laptopID, hold := range store.holds {
+		if hold.Holder == username {
+			delete(store.holds, laptopID)
+			erased++
+		}
+	}
+	return erased, nil
+}
+
+// active returns the hold on the laptop, deleting it if it has expired.
+func (store *InMemoryHoldStore) active(laptopID string, now time.Time) *Hold {
+	hold := store.holds[laptopID]
+	if hold != nil && !now.Before(hold.ExpiresAt) {
+		delete(store.holds, laptopID)
+		return nil
+	}
+	return hold
+}
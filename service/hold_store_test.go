@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestServerHold(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptopStore := service.NewInMemoryLaptopStore()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop))
+	server := service.NewLaptopServer(laptopStore, nil, nil, service.WithHoldStore(service.NewInMemoryHoldStore()))
+
+	alice := service.ContextWithClaims(context.Background(), &service.UserClaims{Username: "alice"})
+	bob := service.ContextWithClaims(context.Background(), &service.UserClaims{Username: "bob"})
+
+	req := &pb.AcquireHoldRequest{LaptopId: laptop.GetId(), Ttl: durationpb.New(100 * time.Millisecond)}
+	res, err := server.AcquireHold(alice, req)
+	require.NoError(t, err)
+	require.NotEmpty(t, res.GetHoldId())
+
+	_, err = server.AcquireHold(bob, req)
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	// Acquiring again extends the same hold.
+	again, err := server.AcquireHold(alice, req)
+	require.NoError(t, err)
+	require.Equal(t, res.GetHoldId(), again.GetHoldId())
+
+	_, err = server.ReleaseHold(bob, &pb.ReleaseHoldRequest{LaptopId: laptop.GetId(), HoldId: "other"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = server.ReleaseHold(alice, &pb.ReleaseHoldRequest{LaptopId: laptop.GetId(), HoldId: res.GetHoldId()})
+	require.NoError(t, err)
+
+	_, err = server.AcquireHold(bob, req)
+	require.NoError(t, err)
+
+	// The hold of bob expires.
+	time.Sleep(150 * time.Millisecond)
+	_, err = server.AcquireHold(alice, req)
+	require.NoError(t, err)
+
+	_, err = server.AcquireHold(context.Background(), req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	req.Ttl = durationpb.New(time.Hour)
+	_, err = server.AcquireHold(alice, req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const acceptLanguageHeader = "accept-language"
+
+// Translations maps the English format of a status message to its translation.
+// The translation must use the same number of verbs as the English format.
+type Translations map[string]string
+
+// DefaultTranslations returns the translations of the status messages of the servers
+// by language. English is the language of the servers, so it needs no translations.
+func DefaultTranslations() map[string]Translations {
+	return map[string]Translations{
+		"fr": {
+			"request is canceled":                 "la requête est annulée",
+			"deadline is exceeded":                "le délai est dépassé",
+			"metadata is not provided":            "les métadonnées ne sont pas fournies",
+			"authorization token is not provided": "le jeton d'autorisation n'est pas fourni",
+			"access token is invalid: %v":         "le jeton d'accès n'est pas valide : %v",
+			"no permission to accces the RPC":     "aucune permission pour accéder au RPC",
+			"no permission to access tenant %q":   "aucune permission pour accéder au locataire %q",
+			"incorrect username/password":         "nom d'utilisateur ou mot de passe incorrect",
+			"username is required":                "le nom d'utilisateur est requis",
+			"laptop ID is not a valid UUID: %v":   "l'ID de l'ordinateur n'est pas un UUID valide : %v",
+			"laptop %s doesn't exist":             "l'ordinateur %s n'existe pas",
+			"laptopID %s is not found":            "l'ordinateur %s est introuvable",
+			"image is too large: %d > %d":         "l'image est trop grande : %d > %d",
+			"cannot save laptop to the store: %v": "impossible d'enregistrer l'ordinateur : %v",
+			"cannot save image to the store: %v":  "impossible d'enregistrer l'image : %v",
+			"cannot add rating to the store: %v":  "impossible d'enregistrer la note : %v",
+		},
+		"es": {
+			"request is canceled":                 "la solicitud fue cancelada",
+			"deadline is exceeded":                "se superó el plazo",
+			"metadata is not provided":            "no se proporcionaron metadatos",
+			"authorization token is not provided": "no se proporcionó el token de autorización",
+			"access token is invalid: %v":         "el token de acceso no es válido: %v",
+			"no permission to accces the RPC":     "sin permiso para acceder al RPC",
+			"no permission to access tenant %q":   "sin permiso para acceder al inquilino %q",
+			"incorrect username/password":         "usuario o contraseña incorrectos",
+			"username is required":                "el nombre de usuario es obligatorio",
+			"laptop ID is not a valid UUID: %v":   "el ID del portátil no es un UUID válido: %v",
+			"laptop %s doesn't exist":             "el portátil %s no existe",
+			"laptopID %s is not found":            "no se encontró el portátil %s",
+			"image is too large: %d > %d":         "la imagen es demasiado grande: %d > %d",
+			"cannot save laptop to the store: %v": "no se puede guardar el portátil: %v",
+			"cannot save image to the store: %v":  "no se puede guardar la imagen: %v",
+			"cannot add rating to the store: %v":  "no se puede guardar la calificación: %v",
+		},
+	}
+}
+
+var formatVerb = regexp.MustCompile(`%[a-z]`)
+
+type translation struct {
+	pattern *regexp.Regexp
+	format  string
+}
+
+// Localizer translates the messages of the status errors returned by the servers
+// into the language requested with the accept-language header.
+// The status codes and details are kept unchanged.
+type Localizer struct {
+	translations map[string][]translation
+}
+
+// NewLocalizer returns a new localizer for the translations by language.
+func NewLocalizer(translations map[string]Translations) *Localizer {
+	localizer := &Localizer{translations: make(map[string][]translation)}
+
+	for language, messages := range translations {
+		for english, format := range messages {
+			parts := formatVerb.Split(english, -1)
+			for i, part := range parts {
+				parts[i] = regexp.QuoteMeta(part)
+			}
+			pattern := regexp.MustCompile("^" + strings.Join(parts, "(.*)") + "$")
+
+			language = strings.ToLower(language)
+			localizer.translations[language] = append(
+				localizer.translations[language],
+				translation{pattern: pattern, format: formatVerb.ReplaceAllString(format, "%s")},
+			)
+		}
+	}
+
+	return localizer
+}
+
+// Unary returns a server interceptor to localize the errors of unary RPC
+func (localizer *Localizer) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		res, err := handler(ctx, req)
+		return res, localizer.localize(ctx, err)
+	}
+}
+
+// Stream returns a server interceptor to localize the errors of stream RPC
+func (localizer *Localizer) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		err := handler(srv, stream)
+		return localizer.localize(stream.Context(), err)
+	}
+}
+
+func (localizer *Localizer) localize(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, language := range acceptedLanguages(md.Get(acceptLanguageHeader)) {
+		if language == "en" || language == "*" {
+			return err
+		}
+
+		translations, ok := localizer.translations[language]
+		if !ok {
+			continue
+		}
+
+		message, ok := translate(translations, st.Message())
+		if !ok {
+			return err
+		}
+
+		proto := st.Proto()
+		proto.Message = message
+		return status.FromProto(proto).Err()
+	}
+
+	return err
+}
+
+func translate(translations []translation, message string) (string, bool) {
+	for _, t := range translations {
+		match := t.pattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+
+		args := make([]interface{}, len(match)-1)
+		for i, arg := range match[1:] {
+			args[i] = arg
+		}
+		return fmt.Sprintf(t.format, args...), true
+	}
+
+	return "", false
+}
+
+// acceptedLanguages returns the languages of accept-language header values
+// by decreasing preference. Each language tag is followed by its base language,
+// so that "fr-CH" falls back to "fr".
+func acceptedLanguages(values []string) []string {
+	type accepted struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []accepted
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			fields := strings.Split(item, ";")
+			tag := strings.ToLower(strings.TrimSpace(fields[0]))
+			if tag == "" {
+				continue
+			}
+
+			quality := 1.0
+			for _, param := range fields[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					q, err := strconv.ParseFloat(param[2:], 64)
+					if err == nil {
+						quality = q
+					}
+				}
+			}
+			if quality <= 0 {
+				continue
+			}
+
+			tags = append(tags, accepted{tag: tag, quality: quality})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+
+	languages := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		languages = append(languages, tag.tag)
+		if base, _, found := strings.Cut(tag.tag, "-"); found {
+			languages = append(languages, base)
+		}
+	}
+	return languages
+}
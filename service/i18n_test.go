@@ -0,0 +1,60 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLocalizer(t *testing.T) {
+	t.Parallel()
+
+	localizer := service.NewLocalizer(service.DefaultTranslations())
+	interceptor := localizer.Unary()
+
+	st, err := status.New(codes.InvalidArgument, "image is too large: 2048 > 1024").
+		WithDetails(&errdetails.ErrorInfo{Reason: "IMAGE_TOO_LARGE"})
+	require.NoError(t, err)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, st.Err()
+	}
+
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		message        string
+	}{
+		{"no header", "", "image is too large: 2048 > 1024"},
+		{"french", "fr", "l'image est trop grande : 2048 > 1024"},
+		{"regional tag", "fr-CH", "l'image est trop grande : 2048 > 1024"},
+		{"by quality", "fr;q=0.5, es", "la imagen es demasiado grande: 2048 > 1024"},
+		{"english preferred", "en, fr;q=0.8", "image is too large: 2048 > 1024"},
+		{"unknown language", "de", "image is too large: 2048 > 1024"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tc.acceptLanguage != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("accept-language", tc.acceptLanguage))
+			}
+
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			localized, ok := status.FromError(err)
+			require.True(t, ok)
+			require.Equal(t, codes.InvalidArgument, localized.Code())
+			require.Equal(t, tc.message, localized.Message())
+			require.Len(t, localized.Details(), 1)
+		})
+	}
+}
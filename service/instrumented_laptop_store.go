@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"grpc_app/metrics"
+	"grpc_app/pb"
+	"grpc_app/tracing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// StoreMetrics records the latency and the result of the operations of laptop stores.
+type StoreMetrics struct {
+	operations *metrics.Histogram
+}
+
+// NewStoreMetrics returns new store metrics registered in the registry.
+func NewStoreMetrics(registry *metrics.Registry) *StoreMetrics {
+	return &StoreMetrics{
+		operations: registry.NewHistogram("laptop_store_operation_seconds",
+			"Latency of the operations of the laptop store, by result: ok, not_found, conflict or error.",
+			metrics.DefaultBuckets, "operation", "result"),
+	}
+}
+
+func (storeMetrics *StoreMetrics) record(operation string, start time.Time, err error) {
+	result := "ok"
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNotFound):
+		result = "not_found"
+	case errors.Is(err, ErrAlreadyExist), errors.Is(err, ErrVersionConflict):
+		result = "conflict"
+	default:
+		result = "error"
+	}
+	storeMetrics.operations.Observe(time.Since(start).Seconds(), operation, result)
+}
+
+// InstrumentedLaptopStore is a LaptopStore that records the metrics of the operations of another store,
+// and traces them as child spans of the span of the context.
+type InstrumentedLaptopStore struct {
+	backend LaptopStore
+	metrics *StoreMetrics
+	tracer  *tracing.Tracer
+}
+
+// NewInstrumentedLaptopStore returns a new InstrumentedLaptopStore over the backend, which records
+// the metrics if storeMetrics isn't nil and traces the operations if tracer isn't nil.
+func NewInstrumentedLaptopStore(backend LaptopStore, storeMetrics *StoreMetrics, tracer *tracing.Tracer) *InstrumentedLaptopStore {
+	return &InstrumentedLaptopStore{backend: backend, metrics: storeMetrics, tracer: tracer}
+}
+
+// ForTenant returns the instrumented store of the tenant, recording the same metrics.
+func (store *InstrumentedLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &InstrumentedLaptopStore{backend: tenantStore(store.backend, tenant), metrics: store.metrics, tracer: store.tracer}
+}
+
+// start starts the operation, and returns the context of the operation and the function
+// to call with its error when it's done.
+func (store *InstrumentedLaptopStore) start(ctx context.Context, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	var span *tracing.Span
+	if store.tracer != nil {
+		ctx, span = store.tracer.Start(ctx, "LaptopStore/"+operation, tracing.KindInternal)
+		span.SetAttribute("store.operation", operation)
+	}
+
+	return ctx, func(err error) {
+		if store.metrics != nil {
+			store.metrics.record(operation, start, err)
+		}
+		if span != nil {
+			code := codes.OK
+			if err != nil {
+				code = codes.Unknown
+			}
+			span.SetStatus(code, err)
+			span.Finish()
+		}
+	}
+}
+
+// Save saves the laptop to the backend
+func (store *InstrumentedLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) (err error) {
+	ctx, done := store.start(ctx, "save")
+	defer func() { done(err) }()
+	return store.backend.Save(ctx, laptop)
+}
+
+// SaveBatch saves the laptops to the backend
+func (store *InstrumentedLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) (err error) {
+	ctx, done := store.start(ctx, "save_batch")
+	defer func() { done(err) }()
+	return store.backend.SaveBatch(ctx, laptops)
+}
+
+// Update updates the laptop in the backend
+func (store *InstrumentedLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) (err error) {
+	ctx, done := store.start(ctx, "update")
+	defer func() { done(err) }()
+	return store.backend.Update(ctx, laptop)
+}
+
+// Delete deletes the laptop from the backend
+func (store *InstrumentedLaptopStore) Delete(ctx context.Context, id string) (err error) {
+	ctx, done := store.start(ctx, "delete")
+	defer func() { done(err) }()
+	return store.backend.Delete(ctx, id)
+}
+
+// Find finds a laptop by ID in the backend
+func (store *InstrumentedLaptopStore) Find(ctx context.Context, id string) (laptop *pb.Laptop, err error) {
+	ctx, done := store.start(ctx, "find")
+	defer func() { done(err) }()
+	return store.backend.Find(ctx, id)
+}
+
+// List lists the laptops of the backend
+func (store *InstrumentedLaptopStore) List(
+	ctx context.Context,
+	pageSize int,
+	pageToken string,
+) (laptops []*pb.Laptop, nextPageToken string, err error) {
+	ctx, done := store.start(ctx, "list")
+	defer func() { done(err) }()
+	return store.backend.List(ctx, pageSize, pageToken)
+}
+
+// Count counts the laptops of the backend
+func (store *InstrumentedLaptopStore) Count(ctx context.Context, filter *pb.Filter) (count int64, err error) {
+	ctx, done := store.start(ctx, "count")
+	defer func() { done(err) }()
+	return store.backend.Count(ctx, filter)
+}
+
+// Reindex rebuilds the indexes of the backend
+func (store *InstrumentedLaptopStore) Reindex(ctx context.Context) (indexed int, err error) {
+	ctx, done := store.start(ctx, "reindex")
+	defer func() { done(err) }()
+	return reindexLaptops(ctx, store.backend)
+}
+
+// Stats returns the statistics of the laptops of the backend
+func (store *InstrumentedLaptopStore) Stats(ctx context.Context, filter *pb.Filter) (stats *pb.CatalogStats, err error) {
+	ctx, done := store.start(ctx, "stats")
+	defer func() { done(err) }()
+	return aggregateLaptops(ctx, store.backend, filter)
+}
+
+// Search searches for laptops in the backend. The latency includes the time spent in found.
+func (store *InstrumentedLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) (err error) {
+	ctx, done := store.start(ctx, "search")
+	defer func() { done(err) }()
+	return store.backend.Search(ctx, filter, found)
+}
@@ -18,6 +18,7 @@ type UserClaims struct {
 	jwt.StandardClaims
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	Tenant   string `json:"tenant,omitempty"`
 }
 
 // NewJWTManager returns a new JWT manager.
@@ -33,6 +34,7 @@ func (manager *JWTManager) Generate(user *User) (string, error) {
 		},
 		Username: user.Username,
 		Role:     user.Role,
+		Tenant:   user.Tenant,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
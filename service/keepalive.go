@@ -0,0 +1,37 @@
+package service
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// KeepaliveConfig configures the keepalive pings of the server connections,
+// which keep the idle connections of long-lived streams open through NATs and load balancers.
+type KeepaliveConfig struct {
+	// Time is the idle time of a connection after which the server pings the client, 2 hours if 0.
+	Time time.Duration
+	// Timeout is the time the server waits for the ack of a ping before closing the connection, 20 seconds if 0.
+	Timeout time.Duration
+	// MinClientTime is the shortest interval between the pings of a client, 5 minutes if 0.
+	// The connections of the clients that ping more often are closed.
+	MinClientTime time.Duration
+	// PermitWithoutStream allows the clients to ping the connections without active stream.
+	// Their connections are closed otherwise.
+	PermitWithoutStream bool
+}
+
+// KeepaliveServerOptions returns the server options applying the keepalive config.
+func KeepaliveServerOptions(config KeepaliveConfig) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    config.Time,
+			Timeout: config.Timeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             config.MinClientTime,
+			PermitWithoutStream: config.PermitWithoutStream,
+		}),
+	}
+}
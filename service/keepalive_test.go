@@ -0,0 +1,66 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+)
+
+func TestKeepaliveServerOptions(t *testing.T) {
+	t.Parallel()
+
+	grpcServer := grpc.NewServer(service.KeepaliveServerOptions(service.KeepaliveConfig{
+		Time:                time.Second,
+		Timeout:             200 * time.Millisecond,
+		PermitWithoutStream: true,
+	})...)
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(service.NewInMemoryLaptopStore(), nil, nil))
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	// A client acking the pings keeps its idle connection.
+	conn, err := client.Dial(listener.Addr().String(), client.WithKeepalive(10*time.Second, time.Second, true))
+	require.NoError(t, err)
+	defer conn.Close()
+	laptopClient := pb.NewLaptopServiceClient(conn)
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.NoError(t, err)
+
+	// A peer that never acks the pings is disconnected once the ping times out.
+	rawConn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer rawConn.Close()
+	_, err = rawConn.Write([]byte(http2.ClientPreface))
+	require.NoError(t, err)
+	framer := http2.NewFramer(rawConn, rawConn)
+	require.NoError(t, framer.WriteSettings())
+
+	start := time.Now()
+	require.NoError(t, rawConn.SetReadDeadline(start.Add(10*time.Second)))
+	pinged := false
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			break
+		}
+		if ping, ok := frame.(*http2.PingFrame); ok && !ping.IsAck() {
+			pinged = true
+		}
+	}
+	require.True(t, pinged)
+	require.Less(t, time.Since(start), 5*time.Second, "the connection is closed by the server, not the read deadline")
+
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.NoError(t, err)
+}
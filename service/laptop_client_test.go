@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"grpc_app/client"
 	"grpc_app/pb"
 	"grpc_app/sample"
 	"grpc_app/serializer"
@@ -13,9 +14,12 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestClientCreateLaptop(t *testing.T) {
@@ -37,7 +41,7 @@ func TestClientCreateLaptop(t *testing.T) {
 	require.Equal(t, expctedID, res.Id)
 
 	// Check that laptop is saved to the store.
-	other, err := laptopStore.Find(res.Id)
+	other, err := laptopStore.Find(context.Background(), res.Id)
 	require.NoError(t, err)
 	require.NotNil(t, other)
 
@@ -86,7 +90,7 @@ func TestClientSearchLaptop(t *testing.T) {
 			expectedIDs[laptop.Id] = true
 		}
 
-		err := laptopStore.Save(laptop)
+		err := laptopStore.Save(context.Background(), laptop)
 		require.NoError(t, err)
 	}
 
@@ -123,7 +127,7 @@ func TestClientUploadImage(t *testing.T) {
 	imageStore := service.NewDiskImageStore(testImageFolder)
 
 	laptop := sample.NewLaptop()
-	err := laptopStore.Save(laptop)
+	err := laptopStore.Save(context.Background(), laptop)
 	require.NoError(t, err)
 
 	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
@@ -183,6 +187,46 @@ func TestClientUploadImage(t *testing.T) {
 	require.NoError(t, os.Remove(savedImagePath))
 }
 
+func TestClientUploadImageFile(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	imageStore := service.NewDiskImageStore(t.TempDir())
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop))
+
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
+	conn, err := client.Dial(serverAddress)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	laptopClient := client.NewLaptopClient(conn)
+
+	imagePath := "../tmp/laptop.jpg"
+	info, err := os.Stat(imagePath)
+	require.NoError(t, err)
+
+	var chunks int
+	var sent int64
+	res, err := laptopClient.UploadImageFile(context.Background(), laptop.GetId(), imagePath, 4096, func(n int64, total int64) {
+		chunks++
+		sent = n
+		require.Equal(t, info.Size(), total)
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.GetId())
+	require.EqualValues(t, info.Size(), res.GetSize())
+	require.Equal(t, info.Size(), sent)
+	require.Equal(t, int((info.Size()+4095)/4096), chunks)
+
+	_, err = laptopClient.UploadImageFile(context.Background(), "unknown", imagePath, 4096, nil)
+	require.Equal(t, codes.InvalidArgument, status.Code(err), "the error of the server is returned")
+
+	for _, chunkSize := range []int{0, -1} {
+		_, err = laptopClient.UploadImageFile(context.Background(), laptop.GetId(), imagePath, chunkSize, nil)
+		require.EqualError(t, err, fmt.Sprintf("chunk size must be positive: %d", chunkSize))
+	}
+}
+
 func TestClientRateLaptop(t *testing.T) {
 	t.Parallel()
 
@@ -190,7 +234,7 @@ func TestClientRateLaptop(t *testing.T) {
 	ratingStore := service.NewInMemoryRatingStore()
 
 	laptop := sample.NewLaptop()
-	err := laptopStore.Save(laptop)
+	err := laptopStore.Save(context.Background(), laptop)
 	require.NoError(t, err)
 
 	serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore)
@@ -230,6 +274,164 @@ func TestClientRateLaptop(t *testing.T) {
 	}
 }
 
+func TestClientExportLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	expectedIDs := make(map[string]bool)
+	cheap := 0
+	for i := 0; i < 150; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, laptopStore.Save(context.Background(), laptop))
+		expectedIDs[laptop.GetId()] = true
+		if laptop.GetPriceUsd() <= 2000 {
+			cheap++
+		}
+	}
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	export := func(filter *pb.Filter) map[string]bool {
+		stream, err := laptopClient.ExportLaptops(context.Background(), &pb.ExportLaptopsRequest{Filter: filter})
+		require.NoError(t, err)
+
+		ids := make(map[string]bool)
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				return ids
+			}
+			require.NoError(t, err)
+			require.False(t, ids[res.GetLaptop().GetId()], "the laptop is exported once")
+			ids[res.GetLaptop().GetId()] = true
+		}
+	}
+
+	require.Equal(t, expectedIDs, export(nil), "all the laptops are exported across the pages")
+	require.Len(t, export(&pb.Filter{MaxPriceUsd: 2000}), cheap)
+}
+
+func TestClientImportLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	existing := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), existing))
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	stream, err := laptopClient.ImportLaptops(context.Background())
+	require.NoError(t, err)
+
+	invalid := sample.NewLaptop()
+	invalid.Id = "invalid-uuid"
+	noID := sample.NewLaptop()
+	noID.Id = ""
+	for _, laptop := range []*pb.Laptop{sample.NewLaptop(), existing, invalid, noID} {
+		require.NoError(t, stream.Send(&pb.ImportLaptopsRequest{Laptop: laptop}))
+	}
+
+	res, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), res.GetCreated())
+	require.Equal(t, uint32(1), res.GetSkipped())
+	require.Equal(t, uint32(1), res.GetFailed())
+	require.Len(t, res.GetFailures(), 1)
+	require.Equal(t, uint32(2), res.GetFailures()[0].GetIndex())
+
+	count, err := laptopStore.Count(context.Background(), &pb.Filter{MaxPriceUsd: 1e9})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+}
+
+func TestClientWatchLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewWatchLaptopStore(service.NewInMemoryLaptopStore())
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := &pb.WatchLaptopsRequest{Filter: &pb.Filter{MaxPriceUsd: 2000}}
+	stream, err := laptopClient.WatchLaptops(ctx, req)
+	require.NoError(t, err)
+
+	events := make(chan *pb.WatchLaptopsResponse, 100)
+	go func() {
+		for {
+			res, err := stream.Recv()
+			if err != nil {
+				close(events)
+				return
+			}
+			events <- res
+		}
+	}()
+
+	cheap := sample.NewLaptop()
+	cheap.PriceUsd = 1000
+	require.NoError(t, laptopStore.Save(context.Background(), cheap))
+
+	// The server starts watching after the stream is open, so update the laptop until it does.
+	for watching := false; !watching; {
+		require.NoError(t, laptopStore.Update(context.Background(), cheap))
+		select {
+		case res := <-events:
+			require.Equal(t, cheap.GetId(), res.GetLaptop().GetId())
+			watching = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 3000
+	require.NoError(t, laptopStore.Save(context.Background(), expensive))
+	require.NoError(t, laptopStore.Delete(context.Background(), cheap.GetId()))
+
+	for res := range events {
+		require.Equal(t, cheap.GetId(), res.GetLaptop().GetId(), "the laptops not matching the filter are not sent")
+		if res.GetType() == pb.WatchLaptopsResponse_DELETED {
+			require.Equal(t, cheap.GetName(), res.GetLaptop().GetName())
+			return
+		}
+	}
+	t.Fatal("the deletion is not sent")
+}
+
+func TestClientWatchLaptopsResume(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewWatchLaptopStore(service.NewInMemoryLaptopStore())
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	events := make(chan service.LaptopEvent, 10)
+	defer laptopStore.Watch(events)()
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(context.Background(), laptop1))
+	require.NoError(t, laptopStore.Save(context.Background(), laptop2))
+	resumeToken := (<-events).ResumeToken
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := laptopClient.WatchLaptops(ctx, &pb.WatchLaptopsRequest{ResumeToken: resumeToken})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, pb.WatchLaptopsResponse_CREATED, res.GetType())
+	require.Equal(t, laptop2.GetId(), res.GetLaptop().GetId(), "the change missed since the token is sent")
+	require.NotEmpty(t, res.GetResumeToken())
+
+	stream, err = laptopClient.WatchLaptops(ctx, &pb.WatchLaptopsRequest{ResumeToken: "invalid"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func startTestLaptopServer(t *testing.T,
 	laptopStore service.LaptopStore,
 	imageStore service.ImageStore,
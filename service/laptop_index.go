@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"grpc_app/pb"
+	"sort"
+)
+
+// LaptopReindexer is implemented by the laptop stores that keep secondary indexes of their laptops.
+type LaptopReindexer interface {
+	// Reindex rebuilds the indexes from the stored laptops and returns the number of laptops indexed.
+	Reindex(ctx context.Context) (int, error)
+}
+
+// ErrNotReindexable is returned when a laptop store and its backends don't keep any index.
+var ErrNotReindexable = errors.New("the laptop store doesn't keep indexes")
+
+// reindexLaptops rebuilds the indexes of the store, or returns ErrNotReindexable if it has none.
+func reindexLaptops(ctx context.Context, store LaptopStore) (int, error) {
+	reindexer, ok := store.(LaptopReindexer)
+	if !ok {
+		return 0, ErrNotReindexable
+	}
+	return reindexer.Reindex(ctx)
+}
+
+// laptopIndex is a secondary index of the in-memory laptops, sorted by a numeric field,
+// so a search with a bound on the field only reads the laptops within the bound.
+type laptopIndex struct {
+	key     func(laptop *pb.Laptop) float64
+	entries []laptopIndexEntry
+}
+
+type laptopIndexEntry struct {
+	key float64
+	id  string
+}
+
+func (entry laptopIndexEntry) less(other laptopIndexEntry) bool {
+	if entry.key != other.key {
+		return entry.key < other.key
+	}
+	return entry.id < other.id
+}
+
+// search returns the position of the entry, or where it would be inserted.
+func (index *laptopIndex) search(entry laptopIndexEntry) int {
+	return sort.Search(len(index.entries), func(i int) bool {
+		return !index.entries[i].less(entry)
+	})
+}
+
+func (index *laptopIndex) insert(laptop *pb.Laptop) {
+	entry := laptopIndexEntry{key: index.key(laptop), id: laptop.GetId()}
+	i := index.search(entry)
+	index.entries = append(index.entries, laptopIndexEntry{})
+	copy(index.entries[i+1:], index.entries[i:])
+	index.entries[i] = entry
+}
+
+func (index *laptopIndex) remove(laptop *pb.Laptop) {
+	entry := laptopIndexEntry{key: index.key(laptop), id: laptop.GetId()}
+	i := index.search(entry)
+	if i < len(index.entries) && index.entries[i] == entry {
+		index.entries = append(index.entries[:i], index.entries[i+1:]...)
+	}
+}
+
+// rebuild indexes the laptops, replacing the indexed ones.
+func (index *laptopIndex) rebuild(data map[string]*pb.Laptop) {
+	index.entries = make([]laptopIndexEntry, 0, len(data))
+	for _, laptop := range data {
+		index.entries = append(index.entries, laptopIndexEntry{key: index.key(laptop), id: laptop.GetId()})
+	}
+	sort.Slice(index.entries, func(i, j int) bool {
+		return index.entries[i].less(index.entries[j])
+	})
+}
+
+// atMost returns the entries whose key is at most max.
+func (index *laptopIndex) atMost(max float64) []laptopIndexEntry {
+	i := sort.Search(len(index.entries), func(i int) bool {
+		return index.entries[i].key > max
+	})
+	return index.entries[:i]
+}
+
+// atLeast returns the entries whose key is at least min.
+func (index *laptopIndex) atLeast(min float64) []laptopIndexEntry {
+	i := sort.Search(len(index.entries), func(i int) bool {
+		return index.entries[i].key >= min
+	})
+	return index.entries[i:]
+}
+
+// laptopIndexes are the indexes of the in-memory laptops by the fields bounded by a filter.
+// The RAM is indexed as a float64 number of bits, which can round it, but never reverses
+// the order of two sizes, so the laptops within a bound are always in its range.
+type laptopIndexes struct {
+	price *laptopIndex
+	cores *laptopIndex
+	ram   *laptopIndex
+}
+
+func newLaptopIndexes() *laptopIndexes {
+	return &laptopIndexes{
+		price: &laptopIndex{key: func(laptop *pb.Laptop) float64 {
+			return laptop.GetPriceUsd()
+		}},
+		cores: &laptopIndex{key: func(laptop *pb.Laptop) float64 {
+			return float64(laptop.GetCpu().GetNumberCores())
+		}},
+		ram: &laptopIndex{key: func(laptop *pb.Laptop) float64 {
+			return float64(toBit(laptop.GetRam()))
+		}},
+	}
+}
+
+func (indexes *laptopIndexes) all() []*laptopIndex {
+	return []*laptopIndex{indexes.price, indexes.cores, indexes.ram}
+}
+
+func (indexes *laptopIndexes) insert(laptop *pb.Laptop) {
+	for _, index := range indexes.all() {
+		index.insert(laptop)
+	}
+}
+
+func (indexes *laptopIndexes) remove(laptop *pb.Laptop) {
+	for _, index := range indexes.all() {
+		index.remove(laptop)
+	}
+}
+
+func (indexes *laptopIndexes) rebuild(data map[string]*pb.Laptop) {
+	for _, index := range indexes.all() {
+		index.rebuild(data)
+	}
+}
+
+// candidates returns the entries of the laptops that can match the filter, from the index whose
+// range of the filter has the fewest laptops, or false if the filter doesn't bound an indexed field.
+// The laptops still have to be qualified by the rest of the filter.
+func (indexes *laptopIndexes) candidates(filter *pb.Filter) ([]laptopIndexEntry, bool) {
+	var ranges [][]laptopIndexEntry
+	// As in isQualified, with an expression, the max price only applies if it is set.
+	if filter.GetExpression() == nil || filter.GetMaxPriceUsd() != 0 {
+		ranges = append(ranges, indexes.price.atMost(filter.GetMaxPriceUsd()))
+	}
+	if filter.GetMinCpuCores() > 0 {
+		ranges = append(ranges, indexes.cores.atLeast(float64(filter.GetMinCpuCores())))
+	}
+	if minRAM := toBit(filter.GetMinRam()); minRAM > 0 {
+		ranges = append(ranges, indexes.ram.atLeast(float64(minRAM)))
+	}
+	if len(ranges) == 0 {
+		return nil, false
+	}
+
+	candidates := ranges[0]
+	for _, entries := range ranges[1:] {
+		if len(entries) < len(candidates) {
+			candidates = entries
+		}
+	}
+	return candidates, true
+}
+
+// Reindex rebuilds the indexes of the laptops of the store.
+func (store *InMemoryLaptopStore) Reindex(ctx context.Context) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	store.indexes.rebuild(store.data)
+	return len(store.data), nil
+}
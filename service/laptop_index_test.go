@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryLaptopStoreIndexes(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	laptops := make(map[string]*pb.Laptop)
+	for i := 0; i < 50; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(context.Background(), laptop))
+		laptops[laptop.GetId()] = laptop
+	}
+
+	i := 0
+	for id, laptop := range laptops {
+		switch {
+		case i%5 == 0:
+			require.NoError(t, store.Delete(context.Background(), id))
+			delete(laptops, id)
+		case i%5 == 1:
+			laptop.PriceUsd /= 2
+			laptop.Ram = &pb.Memory{Value: laptop.GetRam().GetValue() * 1024, Unit: pb.Memory_MEGABYTE}
+			require.NoError(t, store.Update(context.Background(), laptop))
+		}
+		i++
+	}
+
+	filters := []*pb.Filter{
+		{MaxPriceUsd: 2000},
+		{MaxPriceUsd: 3000, MinCpuCores: 4},
+		{MaxPriceUsd: 1e6, MinRam: &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}},
+		{MaxPriceUsd: 1e6, MinCpuCores: 6, MinRam: &pb.Memory{Value: 8 << 10, Unit: pb.Memory_MEGABYTE}},
+	}
+	for _, filter := range filters {
+		var expected []string
+		for id, laptop := range laptops {
+			ramGB := laptop.GetRam().GetValue()
+			if laptop.GetRam().GetUnit() == pb.Memory_MEGABYTE {
+				ramGB >>= 10
+			}
+			minRAMGB := filter.GetMinRam().GetValue()
+			if filter.GetMinRam().GetUnit() == pb.Memory_MEGABYTE {
+				minRAMGB >>= 10
+			}
+			if laptop.GetPriceUsd() <= filter.GetMaxPriceUsd() &&
+				laptop.GetCpu().GetNumberCores() >= filter.GetMinCpuCores() && ramGB >= minRAMGB {
+				expected = append(expected, id)
+			}
+		}
+
+		var found []string
+		err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+			found = append(found, laptop.GetId())
+			return nil
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, expected, found, "filter: %v", filter)
+
+		count, err := store.Count(context.Background(), filter)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(expected)), count)
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// JournalPath returns the path of the laptop journal of the tenant in the directory.
+func JournalPath(dir string, tenant string) string {
+	if tenant == "" {
+		return filepath.Join(dir, "laptops.jsonl")
+	}
+	return filepath.Join(dir, "laptops-"+url.PathEscape(tenant)+".jsonl")
+}
+
+// journalTombstone is the journal line of a deleted laptop.
+type journalTombstone struct {
+	DeletedID string `json:"deleted_id"`
+}
+
+// OpenInMemoryLaptopStore returns a new InMemoryLaptopStore that writes the saved, updated and
+// deleted laptops through to a JSON-lines journal at path, the last line of a laptop winning on
+// replay. The laptops already in the journal are loaded first, so the store keeps its data across
+// restarts.
+func OpenInMemoryLaptopStore(path string) (*InMemoryLaptopStore, error) {
+	journal, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open laptop journal: %w", err)
+	}
+
+	store := NewInMemoryLaptopStore()
+	err = replayJournal(journal, store.data)
+	if err != nil {
+		journal.Close()
+		return nil, fmt.Errorf("cannot replay laptop journal %s: %w", path, err)
+	}
+	store.indexes.rebuild(store.data)
+
+	store.journal = journal
+	return store, nil
+}
+
+// Close closes the journal of the store, if any.
+func (store *InMemoryLaptopStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.journal == nil {
+		return nil
+	}
+	err := store.journal.Close()
+	store.journal = nil
+	return err
+}
+
+// replayJournal loads the laptops of the journal into data, and leaves the journal positioned at its end.
+// A last line without newline is left by a crash while appending it, so it is truncated.
+func replayJournal(journal *os.File, data map[string]*pb.Laptop) error {
+	reader := bufio.NewReader(journal)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				err = journal.Truncate(offset)
+				if err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			err = replayLine(line, data)
+			if err != nil {
+				return fmt.Errorf("invalid line at offset %d: %w", offset, err)
+			}
+		}
+		offset += int64(len(line))
+	}
+
+	_, err := journal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// replayLine applies a line of the journal, either a laptop or a tombstone, to data.
+func replayLine(line []byte, data map[string]*pb.Laptop) error {
+	var tombstone journalTombstone
+	if json.Unmarshal(line, &tombstone) == nil && tombstone.DeletedID != "" {
+		delete(data, tombstone.DeletedID)
+		return nil
+	}
+
+	laptop := &pb.Laptop{}
+	err := protojson.Unmarshal(line, laptop)
+	if err != nil {
+		return err
+	}
+	data[laptop.GetId()] = laptop
+	return nil
+}
+
+// appendTombstone appends the tombstone of the deleted laptop to the journal.
+func appendTombstone(journal *os.File, id string) error {
+	line, err := json.Marshal(journalTombstone{DeletedID: id})
+	if err != nil {
+		return err
+	}
+
+	_, err = journal.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("cannot write laptop journal: %w", err)
+	}
+	return nil
+}
+
+// appendJournal appends the laptops to the journal, one line each.
+func appendJournal(journal *os.File, laptops ...*pb.Laptop) error {
+	var lines []byte
+	for _, laptop := range laptops {
+		line, err := protojson.Marshal(laptop)
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop: %w", err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	// The lines are written at once, so a batch is only torn by a crash during the write.
+	_, err := journal.Write(lines)
+	if err != nil {
+		return fmt.Errorf("cannot write laptop journal: %w", err)
+	}
+	return nil
+}
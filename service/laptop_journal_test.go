@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryLaptopStoreJournal(t *testing.T) {
+	t.Parallel()
+
+	path := service.JournalPath(t.TempDir(), "acme")
+
+	store, err := service.OpenInMemoryLaptopStore(path)
+	require.NoError(t, err)
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.NoError(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop1, laptop2}))
+	require.ErrorIs(t, store.Save(context.Background(), laptop1), service.ErrAlreadyExist)
+	require.NoError(t, store.Close())
+
+	// Simulate a crash while appending a laptop.
+	journal, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = journal.WriteString(`{"id":"trunc`)
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	store, err = service.OpenInMemoryLaptopStore(path)
+	require.NoError(t, err)
+
+	laptop, err := store.Find(context.Background(), laptop2.GetId())
+	require.NoError(t, err)
+	require.Equal(t, laptop2.GetName(), laptop.GetName())
+	require.ErrorIs(t, store.Save(context.Background(), laptop1), service.ErrAlreadyExist)
+
+	laptop3 := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop3))
+	require.NoError(t, store.Delete(context.Background(), laptop2.GetId()))
+	require.NoError(t, store.Close())
+
+	store, err = service.OpenInMemoryLaptopStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	laptop, err = store.Find(context.Background(), laptop3.GetId())
+	require.NoError(t, err)
+	require.NotNil(t, laptop)
+
+	laptop, err = store.Find(context.Background(), laptop2.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop, "deleted laptops stay deleted")
+}
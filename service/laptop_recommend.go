@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"grpc_app/pb"
+	"math"
+	"sort"
+)
+
+// LaptopScorer scores a laptop for a workload, the higher the better.
+type LaptopScorer func(laptop *pb.Laptop) float64
+
+// RecommendWeights are the weights of the specs of a laptop in its score.
+type RecommendWeights struct {
+	// CPUCores is the weight of each CPU core.
+	CPUCores float64
+	// CPUGhz is the weight of each GHz of the max CPU frequency.
+	CPUGhz float64
+	// RAM is the weight of each gigabyte of RAM.
+	RAM float64
+	// Storage is the weight of each terabyte of storage, SSD or HDD alike.
+	Storage float64
+	// GPUMemory is the weight of each gigabyte of GPU memory, of all the GPUs.
+	GPUMemory float64
+	// PerPrice divides the weighted specs by the price in thousands of USD,
+	// so the laptops are ranked by price/performance rather than performance.
+	PerPrice bool
+}
+
+// Scorer returns the scorer of the laptops by the weighted sum of their specs.
+func (weights RecommendWeights) Scorer() LaptopScorer {
+	return func(laptop *pb.Laptop) float64 {
+		var storage, gpuMemory uint64
+		for _, s := range laptop.GetStorage() {
+			storage += toBit(s.GetMemory())
+		}
+		for _, gpu := range laptop.GetGpus() {
+			gpuMemory += toBit(gpu.GetMemory())
+		}
+
+		score := weights.CPUCores*float64(laptop.GetCpu().GetNumberCores()) +
+			weights.CPUGhz*laptop.GetCpu().GetMaxGhz() +
+			weights.RAM*float64(toBit(laptop.GetRam()))/(1<<33) +
+			weights.Storage*float64(storage)/(1<<43) +
+			weights.GPUMemory*float64(gpuMemory)/(1<<33)
+		if weights.PerPrice {
+			// The free laptops are scored as if they cost 1 USD.
+			score /= math.Max(laptop.GetPriceUsd(), 1) / 1000
+		}
+		return score
+	}
+}
+
+// DefaultLaptopScorers returns the price/performance scorers of the recommended laptops by workload.
+func DefaultLaptopScorers() map[pb.RecommendLaptopsRequest_Workload]LaptopScorer {
+	return map[pb.RecommendLaptopsRequest_Workload]LaptopScorer{
+		pb.RecommendLaptopsRequest_WORKLOAD_UNSPECIFIED: RecommendWeights{
+			CPUCores: 1, CPUGhz: 1, RAM: 1, Storage: 1, GPUMemory: 1, PerPrice: true,
+		}.Scorer(),
+		pb.RecommendLaptopsRequest_OFFICE: RecommendWeights{
+			CPUCores: 0.5, CPUGhz: 1, RAM: 1, Storage: 2, PerPrice: true,
+		}.Scorer(),
+		pb.RecommendLaptopsRequest_DEVELOPMENT: RecommendWeights{
+			CPUCores: 2, CPUGhz: 1, RAM: 2, Storage: 2, GPUMemory: 0.5, PerPrice: true,
+		}.Scorer(),
+		pb.RecommendLaptopsRequest_GAMING: RecommendWeights{
+			CPUCores: 1, CPUGhz: 2, RAM: 1, Storage: 1, GPUMemory: 3, PerPrice: true,
+		}.Scorer(),
+	}
+}
+
+// recommendLaptops searches for the laptops matching the filter, except the excluded one,
+// and returns the limit ones with the best score, from the best to the worst.
+func recommendLaptops(
+	ctx context.Context,
+	store LaptopStore,
+	filter *pb.Filter,
+	excludedID string,
+	scorer LaptopScorer,
+	limit int,
+) ([]*pb.RecommendedLaptop, error) {
+	var recommended []*pb.RecommendedLaptop
+	err := store.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		if laptop.GetId() == excludedID {
+			return nil
+		}
+		recommended = append(recommended, &pb.RecommendedLaptop{Laptop: laptop, Score: scorer(laptop)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(recommended, func(i, j int) bool {
+		if recommended[i].GetScore() != recommended[j].GetScore() {
+			return recommended[i].GetScore() > recommended[j].GetScore()
+		}
+		return recommended[i].GetLaptop().GetId() < recommended[j].GetLaptop().GetId()
+	})
+	if len(recommended) > limit {
+		recommended = recommended[:limit]
+	}
+	return recommended, nil
+}
@@ -4,50 +4,126 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"grpc_app/logging"
 	"grpc_app/pb"
 	"io"
-	"log"
+	"time"
 
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // Maximum 1 megabyte.
 const maxImageSize = 1 << 20
 
+const (
+	defaultHoldTTL = time.Minute
+	maxHoldTTL     = 15 * time.Minute
+
+	defaultTrendingWindow = time.Hour
+	defaultTrendingLimit  = 10
+	maxTrendingLimit      = 100
+
+	defaultRecommendLimit = 10
+	maxRecommendLimit     = 100
+)
+
+// MaxTrendingWindow is the longest window of the trending laptops RPC,
+// so the view stores must keep the counts at least that long.
+const MaxTrendingWindow = 24 * time.Hour
+
 // LaptopServer is the server that provides laptop service.
 type LaptopServer struct {
 	pb.UnimplementedLaptopServiceServer
 	laptopStore LaptopStore
 	imageStore  ImageStore
 	ratingStore RatingStore
+	holdStore   HoldStore
+	viewCounter *ViewCounter
+	scorers     map[pb.RecommendLaptopsRequest_Workload]LaptopScorer
+	logger      *logging.Logger
+}
+
+// LaptopServerOption configures the optional features of a LaptopServer.
+type LaptopServerOption func(server *LaptopServer)
+
+// WithHoldStore enables the hold RPCs, and makes the laptop edits fail
+// while someone else holds the laptop.
+func WithHoldStore(holdStore HoldStore) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.holdStore = holdStore
+	}
+}
+
+// WithViewCounter counts the views and search impressions of the laptops,
+// and enables the trending laptops RPC.
+func WithViewCounter(viewCounter *ViewCounter) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.viewCounter = viewCounter
+	}
+}
+
+// WithLaptopScorers replaces the scorers of the recommended laptops of the given workloads.
+func WithLaptopScorers(scorers map[pb.RecommendLaptopsRequest_Workload]LaptopScorer) LaptopServerOption {
+	return func(server *LaptopServer) {
+		for workload, scorer := range scorers {
+			server.scorers[workload] = scorer
+		}
+	}
+}
+
+// WithLogger replaces the logger of the server, the default logger by default.
+func WithLogger(logger *logging.Logger) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.logger = logger
+	}
 }
 
 // NewLaptopServer returns a new LaptopServer.
-func NewLaptopServer(laptopStore LaptopStore, imageStore ImageStore, ratingStore RatingStore) *LaptopServer {
-	return &LaptopServer{laptopStore: laptopStore, imageStore: imageStore, ratingStore: ratingStore}
+func NewLaptopServer(
+	laptopStore LaptopStore,
+	imageStore ImageStore,
+	ratingStore RatingStore,
+	options ...LaptopServerOption,
+) *LaptopServer {
+	server := &LaptopServer{
+		laptopStore: laptopStore,
+		imageStore:  imageStore,
+		ratingStore: ratingStore,
+		scorers:     DefaultLaptopScorers(),
+		logger:      logging.Default(),
+	}
+	for _, option := range options {
+		option(server)
+	}
+	return server
+}
+
+// storeFor returns the laptop store scoped to the tenant of the context.
+func (server *LaptopServer) storeFor(ctx context.Context) LaptopStore {
+	if scoped, ok := server.laptopStore.(TenantScopedStore); ok {
+		return scoped.ForTenant(TenantFromContext(ctx))
+	}
+	return server.laptopStore
 }
 
+// CreateLaptop is a unary RPC to create a new laptop.
 func (server *LaptopServer) CreateLaptop(
 	ctx context.Context,
 	req *pb.CreateLaptopRequest,
 ) (*pb.CreateLaptopResponse, error) {
 	laptop := req.GetLaptop()
-	log.Printf("receive a create-laptop request with id: %s", laptop.Id)
 
-	if len(laptop.Id) > 0 {
-		// Check if it's a valid UUID.
-		_, err := uuid.Parse(laptop.Id)
-		if err != nil {
-			return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
-		}
-	} else {
-		id, err := uuid.NewRandom()
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "cannot generate a new laptop ID: %v", err)
-		}
-		laptop.Id = id.String()
+	err := assignLaptopID(laptop)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ValidateLaptop(laptop)
+	if err != nil {
+		return nil, err
 	}
 
 	// Some fake heavy processing.
@@ -57,15 +133,15 @@ func (server *LaptopServer) CreateLaptop(
 		return nil, err
 	}
 	// Save the laptop to storage(for now) or db.
-	err := server.laptopStore.Save(laptop)
+	stampCreated(laptop)
+	err = server.storeFor(ctx).Save(ctx, laptop)
 	if err != nil {
-		code := codes.Internal
+		code := storeErrorCode(err)
 		if errors.Is(err, ErrAlreadyExist) {
 			code = codes.AlreadyExists
 		}
 		return nil, status.Errorf(code, "cannot save laptop to the store: %v", err)
 	}
-	log.Printf("saved laptop with id: %s", laptop.Id)
 
 	res := &pb.CreateLaptopResponse{
 		Id: laptop.Id,
@@ -73,18 +149,343 @@ func (server *LaptopServer) CreateLaptop(
 	return res, nil
 }
 
-// SearchLaptop is a server-streaming RPC to search for laptops.
+// maxBatchCreateSize is the largest number of laptops of BatchCreateLaptops.
+const maxBatchCreateSize = 100
+
+// BatchCreateLaptops is a unary RPC to create several laptops with a single save, so all of them
+// are created or none if the store supports it. The invalid laptops are reported in their results
+// and the others are still created. It fails with codes.AlreadyExists if one of them is already stored.
+func (server *LaptopServer) BatchCreateLaptops(
+	ctx context.Context,
+	req *pb.BatchCreateLaptopsRequest,
+) (*pb.BatchCreateLaptopsResponse, error) {
+	laptops := req.GetLaptops()
+	server.logger.Debug("receive a batch-create-laptops request", "laptops", len(laptops))
+
+	if len(laptops) > maxBatchCreateSize {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot create more than %d laptops at once: %d", maxBatchCreateSize, len(laptops))
+	}
+
+	results := make([]*pb.BatchCreateLaptopResult, len(laptops))
+	valid := make([]*pb.Laptop, 0, len(laptops))
+	ids := make(map[string]bool, len(laptops))
+	for i, laptop := range laptops {
+		err := assignLaptopID(laptop)
+		if status.Code(err) == codes.Internal {
+			return nil, err
+		}
+		if err == nil {
+			err = ValidateLaptop(laptop)
+		}
+		if err == nil && ids[laptop.GetId()] {
+			err = status.Errorf(codes.InvalidArgument, "laptop ID %s is duplicated", laptop.GetId())
+		}
+		if err != nil {
+			results[i] = &pb.BatchCreateLaptopResult{Error: status.Convert(err).Message()}
+			continue
+		}
+
+		ids[laptop.GetId()] = true
+		results[i] = &pb.BatchCreateLaptopResult{Id: laptop.GetId()}
+		stampCreated(laptop)
+		valid = append(valid, laptop)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(valid) > 0 {
+		err := server.storeFor(ctx).SaveBatch(ctx, valid)
+		if err != nil {
+			code := storeErrorCode(err)
+			if errors.Is(err, ErrAlreadyExist) {
+				code = codes.AlreadyExists
+			}
+			return nil, status.Errorf(code, "cannot save laptops to the store: %v", err)
+		}
+	}
+	server.logger.Info("saved laptops", "saved", len(valid), "laptops", len(laptops))
+
+	return &pb.BatchCreateLaptopsResponse{Results: results}, nil
+}
+
+// GetLaptop is a unary RPC to get a laptop by ID, with the fields selected by the read mask.
+func (server *LaptopServer) GetLaptop(
+	ctx context.Context,
+	req *pb.GetLaptopRequest,
+) (*pb.GetLaptopResponse, error) {
+	server.logger.Debug("receive a get-laptop request", "id", req.GetId())
+
+	err := validateFieldMask(req.GetReadMask(), &pb.Laptop{})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid read mask: %v", err)
+	}
+
+	laptop, err := server.storeFor(ctx).Find(ctx, req.GetId())
+	if err != nil {
+		return nil, logError(status.Errorf(storeErrorCode(err), "cannot find laptop: %v", err))
+	}
+	if laptop == nil {
+		return nil, logError(status.Errorf(codes.NotFound, "laptopID %s is not found", req.GetId()))
+	}
+
+	if server.viewCounter != nil {
+		server.viewCounter.View(ViewKey{Tenant: TenantFromContext(ctx), LaptopID: laptop.GetId()})
+	}
+
+	applyFieldMask(req.GetReadMask(), laptop)
+	return &pb.GetLaptopResponse{Laptop: laptop}, nil
+}
+
+// UpdateLaptop is a unary RPC to replace an existing laptop, or only the fields of the update mask.
+// The laptop must have the stored version, unless the mask leaves it out, and is returned, whole,
+// with the next one. It fails with codes.Aborted if the laptop was updated since it was read.
+func (server *LaptopServer) UpdateLaptop(
+	ctx context.Context,
+	req *pb.UpdateLaptopRequest,
+) (*pb.UpdateLaptopResponse, error) {
+	laptop := req.GetLaptop()
+	server.logger.Debug("receive an update-laptop request", "id", laptop.GetId())
+
+	if laptop.GetId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is required")
+	}
+
+	err := validateFieldMask(req.GetUpdateMask(), &pb.Laptop{})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid update mask: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	err = server.checkHold(ctx, laptop.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.GetUpdateMask().GetPaths()) > 0 {
+		stored, err := server.storeFor(ctx).Find(ctx, laptop.GetId())
+		if err != nil {
+			return nil, status.Errorf(storeErrorCode(err), "cannot find laptop: %v", err)
+		}
+		if stored == nil {
+			return nil, status.Errorf(codes.NotFound, "laptopID %s is not found", laptop.GetId())
+		}
+		mergeFieldMask(req.GetUpdateMask(), stored, laptop)
+		laptop = stored
+	}
+
+	err = ValidateLaptop(laptop)
+	if err != nil {
+		return nil, err
+	}
+
+	laptop.UpdatedAt = timestamppb.Now()
+	err = server.storeFor(ctx).Update(ctx, laptop)
+	if err != nil {
+		code := storeErrorCode(err)
+		if errors.Is(err, ErrNotFound) {
+			code = codes.NotFound
+		}
+		if errors.Is(err, ErrVersionConflict) {
+			code = codes.Aborted
+		}
+		return nil, status.Errorf(code, "cannot update laptop in the store: %v", err)
+	}
+	server.logger.Info("updated laptop", "id", laptop.GetId())
+
+	return &pb.UpdateLaptopResponse{Laptop: laptop}, nil
+}
+
+// DeleteLaptop is a unary RPC to delete a laptop by ID.
+func (server *LaptopServer) DeleteLaptop(
+	ctx context.Context,
+	req *pb.DeleteLaptopRequest,
+) (*pb.DeleteLaptopResponse, error) {
+	server.logger.Debug("receive a delete-laptop request", "id", req.GetId())
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	err := server.checkHold(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = server.storeFor(ctx).Delete(ctx, req.GetId())
+	if err != nil {
+		code := storeErrorCode(err)
+		if errors.Is(err, ErrNotFound) {
+			code = codes.NotFound
+		}
+		return nil, status.Errorf(code, "cannot delete laptop from the store: %v", err)
+	}
+	server.logger.Info("deleted laptop", "id", req.GetId())
+
+	return &pb.DeleteLaptopResponse{}, nil
+}
+
+// RestoreLaptop is a unary RPC to restore a deleted laptop, if the store keeps the deleted laptops.
+func (server *LaptopServer) RestoreLaptop(
+	ctx context.Context,
+	req *pb.RestoreLaptopRequest,
+) (*pb.RestoreLaptopResponse, error) {
+	server.logger.Debug("receive a restore-laptop request", "id", req.GetId())
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	restorer, ok := server.storeFor(ctx).(LaptopRestorer)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "the laptop store doesn't keep the deleted laptops")
+	}
+
+	laptop, err := restorer.Restore(ctx, req.GetId())
+	if err != nil {
+		code := storeErrorCode(err)
+		if errors.Is(err, ErrNotFound) {
+			code = codes.NotFound
+		}
+		if errors.Is(err, ErrNotRestorable) {
+			code = codes.Unimplemented
+		}
+		return nil, status.Errorf(code, "cannot restore laptop in the store: %v", err)
+	}
+	server.logger.Info("restored laptop", "id", req.GetId())
+
+	return &pb.RestoreLaptopResponse{Laptop: laptop}, nil
+}
+
+// CountLaptops is a unary RPC to count the laptops matching a filter.
+func (server *LaptopServer) CountLaptops(
+	ctx context.Context,
+	req *pb.CountLaptopsRequest,
+) (*pb.CountLaptopsResponse, error) {
+	filter := req.GetFilter()
+	server.logger.Debug("receive a count-laptops request", "filter", filter)
+
+	err := ValidateExpression(filter.GetExpression())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid filter expression: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	count, err := server.storeFor(ctx).Count(ctx, filter)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "cannot count laptops: %v", err)
+	}
+
+	return &pb.CountLaptopsResponse{Count: count}, nil
+}
+
+// GetCatalogStats is a unary RPC to get the statistics of the laptops matching a filter,
+// computed by the store when it can.
+func (server *LaptopServer) GetCatalogStats(
+	ctx context.Context,
+	req *pb.GetCatalogStatsRequest,
+) (*pb.GetCatalogStatsResponse, error) {
+	filter := req.GetFilter()
+	server.logger.Debug("receive a get-catalog-stats request", "filter", filter)
+
+	err := ValidateExpression(filter.GetExpression())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid filter expression: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	stats, err := aggregateLaptops(ctx, server.storeFor(ctx), filter)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "cannot aggregate laptops: %v", err)
+	}
+
+	return &pb.GetCatalogStatsResponse{Stats: stats}, nil
+}
+
+const (
+	// defaultListPageSize is the page size of ListLaptops if the request has none.
+	defaultListPageSize = 50
+	// maxListPageSize is the largest page size of ListLaptops.
+	maxListPageSize = 1000
+)
+
+// ListLaptops is a unary RPC to list the laptops page by page, in the order of the store.
+func (server *LaptopServer) ListLaptops(
+	ctx context.Context,
+	req *pb.ListLaptopsRequest,
+) (*pb.ListLaptopsResponse, error) {
+	server.logger.Debug("receive a list-laptops request", "page_size", req.GetPageSize())
+
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize == 0:
+		pageSize = defaultListPageSize
+	case pageSize < 0 || pageSize > maxListPageSize:
+		return nil, status.Errorf(codes.InvalidArgument, "page size must be between 0 and %d: %d", maxListPageSize, pageSize)
+	}
+
+	err := validateFieldMask(req.GetReadMask(), &pb.Laptop{})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid read mask: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	laptops, nextPageToken, err := server.storeFor(ctx).List(ctx, pageSize, req.GetPageToken())
+	if err != nil {
+		code := storeErrorCode(err)
+		if errors.Is(err, ErrInvalidPageToken) {
+			code = codes.InvalidArgument
+		}
+		return nil, status.Errorf(code, "cannot list laptops: %v", err)
+	}
+
+	for _, laptop := range laptops {
+		applyFieldMask(req.GetReadMask(), laptop)
+	}
+	return &pb.ListLaptopsResponse{Laptops: laptops, NextPageToken: nextPageToken}, nil
+}
+
+// SearchLaptop is a server-streaming RPC to search for laptops, in the order of the sort of the filter.
 func (server *LaptopServer) SearchLaptop(
 	req *pb.SearchLaptopRequest,
 	stream pb.LaptopService_SearchLaptopServer,
 ) error {
 	filter := req.GetFilter()
-	log.Printf("receive a search-laptop request with a filter: %v", filter)
+	server.logger.Debug("receive a search-laptop request", "filter", filter)
+
+	err := ValidateExpression(filter.GetExpression())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid filter expression: %v", err)
+	}
 
-	err := server.laptopStore.Search(
+	err = ValidateSort(filter.GetSortBy())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid filter sort: %v", err)
+	}
+
+	err = validateFieldMask(req.GetReadMask(), &pb.Laptop{})
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid read mask: %v", err)
+	}
+
+	err = server.storeFor(stream.Context()).Search(
 		stream.Context(),
 		filter,
 		func(laptop *pb.Laptop) error {
+			applyFieldMask(req.GetReadMask(), laptop)
 			res := &pb.SearchLaptopResponse{
 				Laptop: laptop,
 			}
@@ -93,18 +494,292 @@ func (server *LaptopServer) SearchLaptop(
 				return err
 			}
 
-			log.Printf("send laptop with id: %s", laptop.GetId())
+			server.logger.Debug("send laptop", "id", laptop.GetId())
+			if server.viewCounter != nil {
+				server.viewCounter.Impression(ViewKey{Tenant: TenantFromContext(stream.Context()), LaptopID: laptop.GetId()})
+			}
 			return nil
 		},
 	)
 
 	if err != nil {
-		return status.Errorf(codes.Internal, "unexpected error: %v", err)
+		return status.Errorf(storeErrorCode(err), "unexpected error: %v", err)
 	}
 
 	return nil
 }
 
+// ExportLaptops is a server-streaming RPC to send all the laptops matching a filter, or all the laptops
+// of the store in the order of its pages if there is no filter, so they can be copied without paging.
+func (server *LaptopServer) ExportLaptops(
+	req *pb.ExportLaptopsRequest,
+	stream pb.LaptopService_ExportLaptopsServer,
+) error {
+	filter := req.GetFilter()
+	server.logger.Debug("receive an export-laptops request", "filter", filter)
+
+	err := ValidateExpression(filter.GetExpression())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid filter expression: %v", err)
+	}
+
+	err = ValidateSort(filter.GetSortBy())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid filter sort: %v", err)
+	}
+
+	exported := 0
+	send := func(laptop *pb.Laptop) error {
+		err := stream.Send(&pb.ExportLaptopsResponse{Laptop: laptop})
+		if err != nil {
+			return err
+		}
+		exported++
+		return nil
+	}
+
+	store := server.storeFor(stream.Context())
+	if filter == nil {
+		err = forEachLaptop(stream.Context(), store, send)
+	} else {
+		err = store.Search(stream.Context(), filter, send)
+	}
+	if err != nil {
+		return status.Errorf(storeErrorCode(err), "cannot export laptops: %v", err)
+	}
+	server.logger.Info("exported laptops", "exported", exported)
+
+	return nil
+}
+
+// maxImportFailures is the largest number of failures returned by ImportLaptops.
+const maxImportFailures = 100
+
+// ImportLaptops is a client-streaming RPC to create a stream of laptops, one at a time, and return
+// how many were created, skipped because their ID already exists, or failed because they are invalid.
+func (server *LaptopServer) ImportLaptops(stream pb.LaptopService_ImportLaptopsServer) error {
+	res, err := server.importLaptops(stream.Context(), func() (*pb.Laptop, error) {
+		req, err := stream.Recv()
+		return req.GetLaptop(), err
+	})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(res)
+}
+
+// invalidLaptopError is returned by the next function of importLaptops for a laptop that can't be imported.
+type invalidLaptopError struct {
+	err error
+}
+
+func (err invalidLaptopError) Error() string {
+	return err.err.Error()
+}
+
+// importLaptops saves the laptops returned by next until it returns io.EOF, and returns their summary.
+func (server *LaptopServer) importLaptops(
+	ctx context.Context,
+	next func() (*pb.Laptop, error),
+) (*pb.ImportLaptopsResponse, error) {
+	server.logger.Debug("receive an import-laptops request")
+
+	res := &pb.ImportLaptopsResponse{}
+	fail := func(index uint32, err error) {
+		res.Failed++
+		if len(res.Failures) < maxImportFailures {
+			res.Failures = append(res.Failures, &pb.ImportFailure{Index: index, Error: err.Error()})
+		}
+	}
+
+	store := server.storeFor(ctx)
+	for index := uint32(0); ; index++ {
+		if err := contextError(ctx); err != nil {
+			return nil, err
+		}
+
+		laptop, err := next()
+		if err == io.EOF {
+			break
+		}
+		var invalid invalidLaptopError
+		if errors.As(err, &invalid) {
+			fail(index, invalid.err)
+			continue
+		}
+		if err != nil {
+			return nil, logError(status.Errorf(codes.Unknown, "cannot receive laptop: %v", err))
+		}
+
+		err = assignLaptopID(laptop)
+		if status.Code(err) == codes.Internal {
+			return nil, err
+		}
+		if err == nil {
+			err = ValidateLaptop(laptop)
+		}
+		if err != nil {
+			fail(index, errors.New(status.Convert(err).Message()))
+			continue
+		}
+
+		stampCreated(laptop)
+		err = store.Save(ctx, laptop)
+		if errors.Is(err, ErrAlreadyExist) {
+			res.Skipped++
+			continue
+		}
+		if err != nil {
+			return nil, status.Errorf(storeErrorCode(err), "cannot save laptop to the store: %v", err)
+		}
+		res.Created++
+	}
+	server.logger.Info("imported laptops", "created", res.Created, "skipped", res.Skipped, "failed", res.Failed)
+
+	return res, nil
+}
+
+// watchBufferSize is the number of events buffered for each WatchLaptops stream,
+// beyond which the events are dropped until the client catches up.
+const watchBufferSize = 100
+
+// watchEventTypes are the types of the WatchLaptops responses by type of event.
+var watchEventTypes = map[LaptopEventType]pb.WatchLaptopsResponse_EventType{
+	LaptopCreated: pb.WatchLaptopsResponse_CREATED,
+	LaptopUpdated: pb.WatchLaptopsResponse_UPDATED,
+	LaptopDeleted: pb.WatchLaptopsResponse_DELETED,
+}
+
+// WatchLaptops is a server-streaming RPC to send the changes of the laptops matching a filter,
+// as they are made through this server, until the client cancels the stream.
+// A slow client misses the events made while its buffer is full. With a resume token,
+// the changes made since the change of the token are sent first.
+func (server *LaptopServer) WatchLaptops(
+	req *pb.WatchLaptopsRequest,
+	stream pb.LaptopService_WatchLaptopsServer,
+) error {
+	filter := req.GetFilter()
+	server.logger.Debug("receive a watch-laptops request", "filter", filter, "resume_token", req.GetResumeToken())
+
+	err := ValidateExpression(filter.GetExpression())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid filter expression: %v", err)
+	}
+
+	watcher, ok := server.storeFor(stream.Context()).(LaptopWatcher)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "the laptop store doesn't emit the changes of the laptops")
+	}
+
+	events := make(chan LaptopEvent, watchBufferSize)
+	var missed []LaptopEvent
+	var stop func()
+	if req.GetResumeToken() == "" {
+		stop = watcher.Watch(events)
+	} else {
+		missed, stop, err = watcher.WatchFrom(req.GetResumeToken(), events)
+		switch {
+		case errors.Is(err, ErrInvalidResumeToken):
+			return status.Errorf(codes.InvalidArgument, "%v: %s", err, req.GetResumeToken())
+		case errors.Is(err, ErrResumeTokenExpired):
+			return status.Errorf(codes.OutOfRange, "%v, the changes since are no longer kept", err)
+		case err != nil:
+			return status.Errorf(codes.Internal, "cannot watch laptops: %v", err)
+		}
+	}
+	defer stop()
+
+	send := func(event LaptopEvent) error {
+		if filter != nil && !isQualified(filter, event.Laptop) {
+			return nil
+		}
+
+		res := &pb.WatchLaptopsResponse{
+			Type:        watchEventTypes[event.Type],
+			Laptop:      event.Laptop,
+			ResumeToken: event.ResumeToken,
+		}
+		err := stream.Send(res)
+		if err != nil {
+			return err
+		}
+		server.logger.Debug("send laptop event", "type", res.GetType(), "id", event.Laptop.GetId())
+		return nil
+	}
+
+	for _, event := range missed {
+		err := send(event)
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return contextError(stream.Context())
+		case event := <-events:
+			err := send(event)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// RecommendLaptops is a unary RPC to get the laptops with the best score for a workload,
+// within a budget or like another laptop.
+func (server *LaptopServer) RecommendLaptops(
+	ctx context.Context,
+	req *pb.RecommendLaptopsRequest,
+) (*pb.RecommendLaptopsResponse, error) {
+	server.logger.Debug("receive a recommend-laptops request", "workload", req.GetWorkload())
+
+	limit := int(req.GetLimit())
+	switch {
+	case limit == 0:
+		limit = defaultRecommendLimit
+	case limit > maxRecommendLimit:
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be at most %d: %d", maxRecommendLimit, limit)
+	}
+
+	scorer, ok := server.scorers[req.GetWorkload()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown workload %s", req.GetWorkload())
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	store := server.storeFor(ctx)
+	filter := &pb.Filter{}
+	switch target := req.GetTarget().(type) {
+	case *pb.RecommendLaptopsRequest_LaptopId:
+		laptop, err := store.Find(ctx, target.LaptopId)
+		if err != nil {
+			return nil, status.Errorf(storeErrorCode(err), "cannot find laptop: %v", err)
+		}
+		if laptop == nil {
+			return nil, status.Errorf(codes.NotFound, "laptopID %s is not found", target.LaptopId)
+		}
+		filter.MaxPriceUsd = laptop.GetPriceUsd() * 1.25
+	case *pb.RecommendLaptopsRequest_BudgetUsd:
+		if target.BudgetUsd <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "budget must be positive: %v", target.BudgetUsd)
+		}
+		filter.MaxPriceUsd = target.BudgetUsd
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID or budget is required")
+	}
+
+	laptops, err := recommendLaptops(ctx, store, filter, req.GetLaptopId(), scorer, limit)
+	if err != nil {
+		return nil, status.Errorf(storeErrorCode(err), "cannot search laptops: %v", err)
+	}
+
+	return &pb.RecommendLaptopsResponse{Laptops: laptops}, nil
+}
+
 // UploadImage is a client-streaming RPC to upload a laptop image.
 func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) error {
 	req, err := stream.Recv()
@@ -114,16 +789,21 @@ func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServe
 
 	laptopID := req.GetInfo().GetLaptopId()
 	imageType := req.GetInfo().GetImageType()
-	log.Printf("received an upload-image request for laptop %s with image type %s", laptopID, imageType)
+	server.logger.Debug("receive an upload-image request", "laptop_id", laptopID, "image_type", imageType)
 
-	laptop, err := server.laptopStore.Find(laptopID)
+	laptop, err := server.storeFor(stream.Context()).Find(stream.Context(), laptopID)
 	if err != nil {
-		return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+		return logError(status.Errorf(storeErrorCode(err), "cannot find laptop: %v", err))
 	}
 	if laptop == nil {
 		return logError(status.Errorf(codes.InvalidArgument, "laptop %s doesn't exist", laptopID))
 	}
 
+	err = server.checkHold(stream.Context(), laptopID)
+	if err != nil {
+		return err
+	}
+
 	imageData := bytes.Buffer{}
 	imageSize := 0
 
@@ -133,11 +813,11 @@ func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServe
 			return err
 		}
 
-		log.Print("waiting to receive more data")
+		server.logger.Debug("waiting to receive more data")
 
 		req, err := stream.Recv()
 		if err == io.EOF {
-			log.Printf("no more data")
+			server.logger.Debug("no more data")
 			break
 		}
 		if err != nil {
@@ -147,7 +827,7 @@ func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServe
 		chunk := req.GetChunkData()
 		size := len(chunk)
 
-		log.Printf("received a chunk with size: %d", size)
+		server.logger.Debug("received a chunk", "size", size)
 
 		imageSize += size
 		if imageSize > maxImageSize {
@@ -179,7 +859,7 @@ func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServe
 		return logError(status.Errorf(codes.Unknown, "cannot send response: %v", err))
 	}
 
-	log.Printf("saved image with id: %s, size: %d", imageID, imageSize)
+	server.logger.Info("saved image", "id", imageID, "size", imageSize)
 	return nil
 }
 
@@ -194,7 +874,7 @@ func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer)
 
 		req, err := stream.Recv()
 		if err == io.EOF {
-			log.Print("no more data")
+			server.logger.Debug("no more data")
 			break
 		}
 		if err != nil {
@@ -204,18 +884,18 @@ func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer)
 		laptopID := req.GetLaptopId()
 		score := req.GetScore()
 
-		log.Printf("received a rate-laptop request: id = %s, score = %.2f", laptopID, score)
+		server.logger.Debug("receive a rate-laptop request", "id", laptopID, "score", score)
 
-		found, err := server.laptopStore.Find(laptopID)
+		found, err := server.storeFor(stream.Context()).Find(stream.Context(), laptopID)
 		if err != nil {
-			return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+			return logError(status.Errorf(storeErrorCode(err), "cannot find laptop: %v", err))
 		}
 
 		if found == nil {
 			return logError(status.Errorf(codes.NotFound, "laptopID %s is not found", laptopID))
 		}
 
-		rating, err := server.ratingStore.Add(laptopID, score)
+		rating, err := server.ratingStore.Add(tenantScopedID(stream.Context(), laptopID), score)
 		if err != nil {
 			return logError(status.Errorf(codes.Internal, "cannot add rating to the store: %v", err))
 		}
@@ -234,6 +914,186 @@ func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer)
 	return nil
 }
 
+// AcquireHold is a unary RPC to place an exclusive hold of the caller on a laptop,
+// so that nobody else can edit it until the hold is released or expires.
+func (server *LaptopServer) AcquireHold(
+	ctx context.Context,
+	req *pb.AcquireHoldRequest,
+) (*pb.AcquireHoldResponse, error) {
+	if server.holdStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "holds are not enabled")
+	}
+
+	claims := ClaimsFromContext(ctx)
+	if claims == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "holds require an authenticated user")
+	}
+
+	ttl := defaultHoldTTL
+	if req.GetTtl() != nil {
+		ttl = req.GetTtl().AsDuration()
+	}
+	if ttl <= 0 || ttl > maxHoldTTL {
+		return nil, status.Errorf(codes.InvalidArgument, "hold ttl must be between 0 and %v", maxHoldTTL)
+	}
+
+	laptopID := req.GetLaptopId()
+	laptop, err := server.storeFor(ctx).Find(ctx, laptopID)
+	if err != nil {
+		return nil, logError(status.Errorf(storeErrorCode(err), "cannot find laptop: %v", err))
+	}
+	if laptop == nil {
+		return nil, logError(status.Errorf(codes.NotFound, "laptopID %s is not found", laptopID))
+	}
+
+	hold, err := server.holdStore.Acquire(tenantScopedID(ctx, laptopID), claims.Username, ttl)
+	if err != nil {
+		return nil, holdError(err)
+	}
+
+	server.logger.Info("laptop is held", "laptop_id", laptopID, "holder", hold.Holder, "expires_at", hold.ExpiresAt)
+	return &pb.AcquireHoldResponse{HoldId: hold.ID, ExpiresAt: timestamppb.New(hold.ExpiresAt)}, nil
+}
+
+// ReleaseHold is a unary RPC to release a hold on a laptop.
+func (server *LaptopServer) ReleaseHold(
+	ctx context.Context,
+	req *pb.ReleaseHoldRequest,
+) (*pb.ReleaseHoldResponse, error) {
+	if server.holdStore == nil {
+		return nil, status.Errorf(codes.Unimplemented, "holds are not enabled")
+	}
+
+	err := server.holdStore.Release(tenantScopedID(ctx, req.GetLaptopId()), req.GetHoldId())
+	if err != nil {
+		return nil, holdError(err)
+	}
+
+	server.logger.Info("hold is released", "hold_id", req.GetHoldId(), "laptop_id", req.GetLaptopId())
+	return &pb.ReleaseHoldResponse{}, nil
+}
+
+// GetTrendingLaptops is a unary RPC to get the most viewed laptops over a window.
+func (server *LaptopServer) GetTrendingLaptops(
+	ctx context.Context,
+	req *pb.GetTrendingLaptopsRequest,
+) (*pb.GetTrendingLaptopsResponse, error) {
+	if server.viewCounter == nil {
+		return nil, status.Errorf(codes.Unimplemented, "view counters are not enabled")
+	}
+
+	window := defaultTrendingWindow
+	if req.GetWindow() != nil {
+		window = req.GetWindow().AsDuration()
+	}
+	if window <= 0 || window > MaxTrendingWindow {
+		return nil, status.Errorf(codes.InvalidArgument, "trending window must be between 0 and %v", MaxTrendingWindow)
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultTrendingLimit
+	}
+	if limit > maxTrendingLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "trending limit must be at most %d", maxTrendingLimit)
+	}
+
+	trending, err := server.viewCounter.Trending(TenantFromContext(ctx), time.Now().Add(-window), limit)
+	if err != nil {
+		return nil, logError(status.Errorf(codes.Internal, "cannot get trending laptops: %v", err))
+	}
+
+	res := &pb.GetTrendingLaptopsResponse{}
+	for _, t := range trending {
+		laptop, err := server.storeFor(ctx).Find(ctx, t.LaptopID)
+		if err != nil {
+			return nil, logError(status.Errorf(storeErrorCode(err), "cannot find laptop: %v", err))
+		}
+		if laptop == nil {
+			continue
+		}
+
+		res.Laptops = append(res.Laptops, &pb.TrendingLaptop{
+			Laptop:      laptop,
+			Views:       t.Views,
+			Impressions: t.Impressions,
+		})
+	}
+
+	return res, nil
+}
+
+// checkHold fails if someone else than the caller holds the laptop.
+func (server *LaptopServer) checkHold(ctx context.Context, laptopID string) error {
+	if server.holdStore == nil {
+		return nil
+	}
+
+	holder := ""
+	if claims := ClaimsFromContext(ctx); claims != nil {
+		holder = claims.Username
+	}
+
+	err := server.holdStore.Check(tenantScopedID(ctx, laptopID), holder)
+	if err != nil {
+		return holdError(err)
+	}
+	return nil
+}
+
+func holdError(err error) error {
+	var conflict *HoldConflictError
+	switch {
+	case errors.As(err, &conflict):
+		return logError(status.Errorf(codes.Aborted, "%v", conflict))
+	case errors.Is(err, ErrHoldNotFound):
+		return logError(status.Errorf(codes.NotFound, "hold is not found or has expired"))
+	default:
+		return logError(status.Errorf(codes.Internal, "cannot access hold store: %v", err))
+	}
+}
+
+// stampCreated sets the update time of a new laptop to now, unless it has one, e.g. when it's imported,
+// so that the retention rules can tell how old it is.
+func stampCreated(laptop *pb.Laptop) {
+	if laptop.GetUpdatedAt() == nil {
+		laptop.UpdatedAt = timestamppb.Now()
+	}
+}
+
+// assignLaptopID checks that the ID of the laptop is a valid UUID, or sets a new one if it has none.
+func assignLaptopID(laptop *pb.Laptop) error {
+	if laptop == nil {
+		return status.Errorf(codes.InvalidArgument, "laptop is required")
+	}
+
+	if len(laptop.Id) > 0 {
+		// Check if it's a valid UUID.
+		_, err := uuid.Parse(laptop.Id)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+		}
+		return nil
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot generate a new laptop ID: %v", err)
+	}
+	laptop.Id = id.String()
+	return nil
+}
+
+// tenantScopedID prefixes the ID with the tenant of the context, so that
+// records keyed by laptop ID don't collide across tenants.
+func tenantScopedID(ctx context.Context, id string) string {
+	tenant := TenantFromContext(ctx)
+	if tenant == "" {
+		return id
+	}
+	return tenant + "/" + id
+}
+
 func contextError(ctx context.Context) error {
 	switch ctx.Err() {
 	case context.Canceled:
@@ -245,9 +1105,27 @@ func contextError(ctx context.Context) error {
 	}
 }
 
+// storeErrorCode returns the code of an error of the laptop store, which is the code
+// of the context error if the store stopped because the context was done.
+// ErrTooManyTenants is ResourceExhausted.
+func storeErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, ErrTooManyTenants):
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
+
+// logError logs the error returned to the client at the debug level, since the request logger
+// already logs the failed calls, and returns it.
 func logError(err error) error {
 	if err != nil {
-		log.Print(err)
+		logging.Default().Debug("call failed", "error", err)
 	}
 	return err
 }
@@ -6,10 +6,12 @@ import (
 	"grpc_app/sample"
 	"grpc_app/service"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func TestServerCreateLaptop(t *testing.T) {
@@ -23,7 +25,7 @@ func TestServerCreateLaptop(t *testing.T) {
 
 	laptopDuplicateID := sample.NewLaptop()
 	storeDuplicateID := service.NewInMemoryLaptopStore()
-	err := storeDuplicateID.Save(laptopDuplicateID)
+	err := storeDuplicateID.Save(context.Background(), laptopDuplicateID)
 	require.Nil(t, err)
 
 	testCases := []struct {
@@ -90,3 +92,274 @@ func TestServerCreateLaptop(t *testing.T) {
 		)
 	}
 }
+
+func TestServerBatchCreateLaptops(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	server := service.NewLaptopServer(store, nil, nil)
+
+	laptopNoID := sample.NewLaptop()
+	laptopNoID.Id = ""
+	laptopInvalidID := sample.NewLaptop()
+	laptopInvalidID.Id = "invalid-uuid"
+	laptop := sample.NewLaptop()
+
+	req := &pb.BatchCreateLaptopsRequest{Laptops: []*pb.Laptop{laptop, laptopNoID, laptopInvalidID, laptop}}
+	res, err := server.BatchCreateLaptops(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, res.GetResults(), 4)
+
+	require.Equal(t, laptop.GetId(), res.GetResults()[0].GetId())
+	require.Empty(t, res.GetResults()[0].GetError())
+	require.NotEmpty(t, res.GetResults()[1].GetId())
+	require.Empty(t, res.GetResults()[1].GetError())
+	require.Empty(t, res.GetResults()[2].GetId())
+	require.NotEmpty(t, res.GetResults()[2].GetError())
+	require.NotEmpty(t, res.GetResults()[3].GetError(), "the duplicated ID is reported")
+
+	count, err := store.Count(context.Background(), &pb.Filter{MaxPriceUsd: 1e9})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	other := sample.NewLaptop()
+	req = &pb.BatchCreateLaptopsRequest{Laptops: []*pb.Laptop{other, laptop}}
+	_, err = server.BatchCreateLaptops(context.Background(), req)
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+	found, err := store.Find(context.Background(), other.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found, "none of the laptops is saved")
+}
+
+func TestServerUpdateLaptop(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	server := service.NewLaptopServer(store, nil, nil)
+
+	updated := sample.NewLaptop()
+	updated.Id = laptop.GetId()
+	res, err := server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: updated})
+	require.NoError(t, err)
+	require.NotNil(t, res.GetLaptop().GetUpdatedAt())
+
+	found, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Equal(t, updated.GetName(), found.GetName())
+	require.Equal(t, uint64(1), res.GetLaptop().GetVersion())
+
+	stale := sample.NewLaptop()
+	stale.Id = laptop.GetId()
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: stale})
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: &pb.Laptop{}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerUpdateLaptopMask(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	server := service.NewLaptopServer(store, nil, nil)
+
+	update := &pb.Laptop{Id: laptop.GetId(), PriceUsd: 1234, Cpu: &pb.CPU{Brand: "Acme"}}
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"price_usd", "cpu.brand"}}
+	req := &pb.UpdateLaptopRequest{Laptop: update, UpdateMask: mask}
+	res, err := server.UpdateLaptop(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetName(), res.GetLaptop().GetName())
+	require.Equal(t, uint64(1), res.GetLaptop().GetVersion())
+
+	found, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Equal(t, 1234.0, found.GetPriceUsd())
+	require.Equal(t, "Acme", found.GetCpu().GetBrand())
+	require.Equal(t, laptop.GetCpu().GetNumberCores(), found.GetCpu().GetNumberCores())
+	require.Equal(t, laptop.GetRam().GetValue(), found.GetRam().GetValue())
+
+	req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"price_usd", "version"}}
+	_, err = server.UpdateLaptop(context.Background(), req)
+	require.Equal(t, codes.Aborted, status.Code(err), "the version in the mask is checked")
+
+	req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"unknown"}}
+	_, err = server.UpdateLaptop(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.Laptop = &pb.Laptop{Id: sample.NewLaptop().GetId()}
+	req.UpdateMask = mask
+	_, err = server.UpdateLaptop(context.Background(), req)
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServerDeleteLaptop(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	server := service.NewLaptopServer(store, nil, nil)
+
+	_, err := server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.GetId()})
+	require.NoError(t, err)
+
+	found, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	_, err = server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServerCountLaptops(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	for _, price := range []float64{1000, 2000, 3000} {
+		laptop := sample.NewLaptop()
+		laptop.PriceUsd = price
+		require.NoError(t, store.Save(context.Background(), laptop))
+	}
+	server := service.NewLaptopServer(store, nil, nil)
+
+	filter := &pb.Filter{MaxPriceUsd: 2500}
+	res, err := server.CountLaptops(context.Background(), &pb.CountLaptopsRequest{Filter: filter})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), res.GetCount())
+
+	filter = &pb.Filter{Expression: &pb.Expression{Node: &pb.Expression_Condition{
+		Condition: &pb.Condition{Field: "unknown", Operator: pb.Condition_EQ},
+	}}}
+	_, err = server.CountLaptops(context.Background(), &pb.CountLaptopsRequest{Filter: filter})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerListLaptops(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Save(context.Background(), sample.NewLaptop()))
+	}
+	server := service.NewLaptopServer(store, nil, nil)
+
+	var ids []string
+	req := &pb.ListLaptopsRequest{PageSize: 2, ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"id"}}}
+	for {
+		res, err := server.ListLaptops(context.Background(), req)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(res.GetLaptops()), 2)
+		for _, laptop := range res.GetLaptops() {
+			require.Empty(t, laptop.GetBrand(), "only the ID is returned")
+			ids = append(ids, laptop.GetId())
+		}
+		if res.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = res.GetNextPageToken()
+	}
+	require.Equal(t, listAll(t, store, 10), ids)
+
+	res, err := server.ListLaptops(context.Background(), &pb.ListLaptopsRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 5, "the default page size is used")
+
+	_, err = server.ListLaptops(context.Background(), &pb.ListLaptopsRequest{PageSize: -1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = server.ListLaptops(context.Background(), &pb.ListLaptopsRequest{PageToken: "!"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerRecommendLaptops(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	laptops := make([]*pb.Laptop, 4)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+		laptops[i].PriceUsd = 1000
+		laptops[i].Cpu.NumberCores = uint32(2 + 2*i)
+	}
+	laptops[3].PriceUsd = 5000
+	require.NoError(t, store.SaveBatch(context.Background(), laptops))
+
+	cores := func(laptop *pb.Laptop) float64 {
+		return float64(laptop.GetCpu().GetNumberCores())
+	}
+	server := service.NewLaptopServer(store, nil, nil, service.WithLaptopScorers(
+		map[pb.RecommendLaptopsRequest_Workload]service.LaptopScorer{pb.RecommendLaptopsRequest_DEVELOPMENT: cores},
+	))
+
+	req := &pb.RecommendLaptopsRequest{
+		Target:   &pb.RecommendLaptopsRequest_BudgetUsd{BudgetUsd: 2000},
+		Workload: pb.RecommendLaptopsRequest_DEVELOPMENT,
+		Limit:    2,
+	}
+	res, err := server.RecommendLaptops(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 2)
+	require.Equal(t, laptops[2].GetId(), res.GetLaptops()[0].GetLaptop().GetId(), "the laptop over the budget is left out")
+	require.Equal(t, laptops[1].GetId(), res.GetLaptops()[1].GetLaptop().GetId())
+	require.Equal(t, 6.0, res.GetLaptops()[0].GetScore())
+
+	req.Target = &pb.RecommendLaptopsRequest_LaptopId{LaptopId: laptops[2].GetId()}
+	res, err = server.RecommendLaptops(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, laptops[1].GetId(), res.GetLaptops()[0].GetLaptop().GetId(), "the laptop itself is left out")
+
+	req.Workload = pb.RecommendLaptopsRequest_GAMING
+	res, err = server.RecommendLaptops(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 2, "the default scorers are kept")
+
+	req.Target = nil
+	_, err = server.RecommendLaptops(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.Target = &pb.RecommendLaptopsRequest_LaptopId{LaptopId: sample.NewLaptop().GetId()}
+	_, err = server.RecommendLaptops(context.Background(), req)
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServerGetCatalogStats(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	for i, price := range []float64{1000, 2000, 3000} {
+		laptop := sample.NewLaptop()
+		laptop.Brand = []string{"Apple", "Dell", "Dell"}[i]
+		laptop.PriceUsd = price
+		laptop.Cpu.NumberCores = 4
+		laptop.Ram = &pb.Memory{Value: 16384, Unit: pb.Memory_MEGABYTE}
+		require.NoError(t, store.Save(context.Background(), laptop))
+	}
+
+	for _, laptopStore := range []service.LaptopStore{store, service.NewSoftDeleteLaptopStore(store, time.Hour)} {
+		server := service.NewLaptopServer(laptopStore, nil, nil)
+		req := &pb.GetCatalogStatsRequest{Filter: &pb.Filter{MaxPriceUsd: 2500}}
+		res, err := server.GetCatalogStats(context.Background(), req)
+		require.NoError(t, err)
+
+		stats := res.GetStats()
+		require.Equal(t, int64(2), stats.GetCount())
+		require.Equal(t, map[string]int64{"Apple": 1, "Dell": 1}, stats.GetCountByBrand())
+		require.Equal(t, 1000.0, stats.GetMinPriceUsd())
+		require.Equal(t, 1500.0, stats.GetAvgPriceUsd())
+		require.Equal(t, 2000.0, stats.GetMaxPriceUsd())
+		require.Equal(t, map[uint32]int64{4: 2}, stats.GetCountByCpuCores())
+		require.Equal(t, map[uint64]int64{16: 2}, stats.GetCountByRamGb())
+	}
+
+	server := service.NewLaptopServer(store, nil, nil)
+	res, err := server.GetCatalogStats(context.Background(), &pb.GetCatalogStatsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), res.GetStats().GetCount())
+	require.Equal(t, 0.0, res.GetStats().GetMinPriceUsd())
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"grpc_app/pb"
+	pbv2 "grpc_app/pb/v2"
+	"math"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// usd is the only currency of the v1 laptops.
+const usd = "USD"
+
+// LaptopServerV2 serves the v2 laptop service by adapting its messages
+// to the v1 laptop server, so both versions share the same stores.
+type LaptopServerV2 struct {
+	pbv2.UnimplementedLaptopServiceServer
+	server *LaptopServer
+}
+
+// NewLaptopServerV2 returns a new v2 laptop server on top of the v1 server.
+func NewLaptopServerV2(server *LaptopServer) *LaptopServerV2 {
+	return &LaptopServerV2{server: server}
+}
+
+// CreateLaptop is a unary RPC to create a new laptop
+func (server *LaptopServerV2) CreateLaptop(
+	ctx context.Context,
+	req *pbv2.CreateLaptopRequest,
+) (*pbv2.CreateLaptopResponse, error) {
+	laptop, err := LaptopToV1(req.GetLaptop())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot convert laptop: %v", err)
+	}
+
+	res, err := server.server.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: laptop})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv2.CreateLaptopResponse{Id: res.GetId()}, nil
+}
+
+// BatchCreateLaptops is a unary RPC to create several laptops with a single save.
+// The laptops that can't be converted are reported in their results like the invalid ones.
+func (server *LaptopServerV2) BatchCreateLaptops(
+	ctx context.Context,
+	req *pbv2.BatchCreateLaptopsRequest,
+) (*pb.BatchCreateLaptopsResponse, error) {
+	if len(req.GetLaptops()) > maxBatchCreateSize {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot create more than %d laptops at once: %d", maxBatchCreateSize, len(req.GetLaptops()))
+	}
+
+	results := make([]*pb.BatchCreateLaptopResult, len(req.GetLaptops()))
+	laptops := make([]*pb.Laptop, 0, len(req.GetLaptops()))
+	indexes := make([]int, 0, len(req.GetLaptops()))
+	for i, laptop := range req.GetLaptops() {
+		other, err := LaptopToV1(laptop)
+		if err != nil {
+			results[i] = &pb.BatchCreateLaptopResult{Error: fmt.Sprintf("cannot convert laptop: %v", err)}
+			continue
+		}
+		laptops = append(laptops, other)
+		indexes = append(indexes, i)
+	}
+
+	res, err := server.server.BatchCreateLaptops(ctx, &pb.BatchCreateLaptopsRequest{Laptops: laptops})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range res.GetResults() {
+		results[indexes[i]] = result
+	}
+	return &pb.BatchCreateLaptopsResponse{Results: results}, nil
+}
+
+// GetLaptop is a unary RPC to get a laptop by ID, with the fields selected by the read mask.
+// The read mask applies to the v2 laptop.
+func (server *LaptopServerV2) GetLaptop(
+	ctx context.Context,
+	req *pbv2.GetLaptopRequest,
+) (*pbv2.GetLaptopResponse, error) {
+	err := validateFieldMask(req.GetReadMask(), &pbv2.Laptop{})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid read mask: %v", err)
+	}
+
+	res, err := server.server.GetLaptop(ctx, &pb.GetLaptopRequest{Id: req.GetId()})
+	if err != nil {
+		return nil, err
+	}
+
+	laptop := LaptopFromV1(res.GetLaptop())
+	applyFieldMask(req.GetReadMask(), laptop)
+	return &pbv2.GetLaptopResponse{Laptop: laptop}, nil
+}
+
+// UpdateLaptop is a unary RPC to replace an existing laptop, or only the fields of the update mask.
+// The update mask applies to the v2 laptop, so it is merged before the laptop is converted.
+func (server *LaptopServerV2) UpdateLaptop(
+	ctx context.Context,
+	req *pbv2.UpdateLaptopRequest,
+) (*pbv2.UpdateLaptopResponse, error) {
+	err := validateFieldMask(req.GetUpdateMask(), &pbv2.Laptop{})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid update mask: %v", err)
+	}
+
+	merged := req.GetLaptop()
+	if len(req.GetUpdateMask().GetPaths()) > 0 && merged.GetId() != "" {
+		stored, err := server.server.storeFor(ctx).Find(ctx, merged.GetId())
+		if err != nil {
+			return nil, status.Errorf(storeErrorCode(err), "cannot find laptop: %v", err)
+		}
+		if stored == nil {
+			return nil, status.Errorf(codes.NotFound, "laptopID %s is not found", merged.GetId())
+		}
+		merged = LaptopFromV1(stored)
+		mergeFieldMask(req.GetUpdateMask(), merged, req.GetLaptop())
+	}
+
+	laptop, err := LaptopToV1(merged)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot convert laptop: %v", err)
+	}
+
+	res, err := server.server.UpdateLaptop(ctx, &pb.UpdateLaptopRequest{Laptop: laptop})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv2.UpdateLaptopResponse{Laptop: LaptopFromV1(res.GetLaptop())}, nil
+}
+
+// DeleteLaptop is a unary RPC to delete a laptop by ID.
+func (server *LaptopServerV2) DeleteLaptop(ctx context.Context, req *pb.DeleteLaptopRequest) (*pb.DeleteLaptopResponse, error) {
+	return server.server.DeleteLaptop(ctx, req)
+}
+
+// CountLaptops is a unary RPC to count the laptops matching a filter.
+func (server *LaptopServerV2) CountLaptops(ctx context.Context, req *pb.CountLaptopsRequest) (*pb.CountLaptopsResponse, error) {
+	return server.server.CountLaptops(ctx, req)
+}
+
+// ListLaptops is a unary RPC to list the laptops page by page. The read mask applies to the v2 laptops.
+func (server *LaptopServerV2) ListLaptops(
+	ctx context.Context,
+	req *pbv2.ListLaptopsRequest,
+) (*pbv2.ListLaptopsResponse, error) {
+	err := validateFieldMask(req.GetReadMask(), &pbv2.Laptop{})
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid read mask: %v", err)
+	}
+
+	res, err := server.server.ListLaptops(ctx, &pb.ListLaptopsRequest{
+		PageSize:  req.GetPageSize(),
+		PageToken: req.GetPageToken(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	laptops := make([]*pbv2.Laptop, len(res.GetLaptops()))
+	for i, laptop := range res.GetLaptops() {
+		laptops[i] = LaptopFromV1(laptop)
+		applyFieldMask(req.GetReadMask(), laptops[i])
+	}
+	return &pbv2.ListLaptopsResponse{Laptops: laptops, NextPageToken: res.GetNextPageToken()}, nil
+}
+
+// GetCatalogStats is a unary RPC to get the statistics of the laptops matching a filter.
+func (server *LaptopServerV2) GetCatalogStats(ctx context.Context, req *pb.GetCatalogStatsRequest) (*pb.GetCatalogStatsResponse, error) {
+	return server.server.GetCatalogStats(ctx, req)
+}
+
+// RestoreLaptop is a unary RPC to restore a deleted laptop.
+func (server *LaptopServerV2) RestoreLaptop(ctx context.Context, req *pb.RestoreLaptopRequest) (*pbv2.RestoreLaptopResponse, error) {
+	res, err := server.server.RestoreLaptop(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv2.RestoreLaptopResponse{Laptop: LaptopFromV1(res.GetLaptop())}, nil
+}
+
+// SearchLaptop is a server-streaming RPC to search for laptops
+func (server *LaptopServerV2) SearchLaptop(
+	req *pbv2.SearchLaptopRequest,
+	stream pbv2.LaptopService_SearchLaptopServer,
+) error {
+	err := validateFieldMask(req.GetReadMask(), &pbv2.Laptop{})
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid read mask: %v", err)
+	}
+
+	return server.server.SearchLaptop(
+		&pb.SearchLaptopRequest{Filter: req.GetFilter()},
+		&searchLaptopServerV1{stream, req.GetReadMask()},
+	)
+}
+
+// ExportLaptops is a server-streaming RPC to send all the laptops matching a filter
+func (server *LaptopServerV2) ExportLaptops(
+	req *pb.ExportLaptopsRequest,
+	stream pbv2.LaptopService_ExportLaptopsServer,
+) error {
+	return server.server.ExportLaptops(req, &exportLaptopsServerV1{stream})
+}
+
+// ImportLaptops is a client-streaming RPC to create a stream of laptops.
+// The laptops that can't be converted are counted as failed like the invalid ones.
+func (server *LaptopServerV2) ImportLaptops(stream pbv2.LaptopService_ImportLaptopsServer) error {
+	res, err := server.server.importLaptops(stream.Context(), func() (*pb.Laptop, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+
+		laptop, err := LaptopToV1(req.GetLaptop())
+		if err != nil {
+			return nil, invalidLaptopError{fmt.Errorf("cannot convert laptop: %w", err)}
+		}
+		return laptop, nil
+	})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(res)
+}
+
+// WatchLaptops is a server-streaming RPC to send the changes of the laptops matching a filter
+func (server *LaptopServerV2) WatchLaptops(
+	req *pb.WatchLaptopsRequest,
+	stream pbv2.LaptopService_WatchLaptopsServer,
+) error {
+	return server.server.WatchLaptops(req, &watchLaptopsServerV1{stream})
+}
+
+// RecommendLaptops is a unary RPC to get the laptops with the best score for a workload.
+func (server *LaptopServerV2) RecommendLaptops(
+	ctx context.Context,
+	req *pb.RecommendLaptopsRequest,
+) (*pbv2.RecommendLaptopsResponse, error) {
+	res, err := server.server.RecommendLaptops(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	other := &pbv2.RecommendLaptopsResponse{}
+	for _, r := range res.GetLaptops() {
+		other.Laptops = append(other.Laptops, &pbv2.RecommendedLaptop{
+			Laptop: LaptopFromV1(r.GetLaptop()),
+			Score:  r.GetScore(),
+		})
+	}
+	return other, nil
+}
+
+// UploadImage is a client-streaming RPC to upload a laptop image.
+// Its messages are unchanged in v2, so the stream satisfies the v1 interface.
+func (server *LaptopServerV2) UploadImage(stream pbv2.LaptopService_UploadImageServer) error {
+	return server.server.UploadImage(stream)
+}
+
+// RateLaptop is a bidirectional-streaming RPC that allows client to rate a stream of laptops
+// with a score, and returns a stream of average score for each of them.
+func (server *LaptopServerV2) RateLaptop(stream pbv2.LaptopService_RateLaptopServer) error {
+	return server.server.RateLaptop(stream)
+}
+
+// AcquireHold is a unary RPC to place an exclusive hold of the caller on a laptop.
+func (server *LaptopServerV2) AcquireHold(ctx context.Context, req *pb.AcquireHoldRequest) (*pb.AcquireHoldResponse, error) {
+	return server.server.AcquireHold(ctx, req)
+}
+
+// ReleaseHold is a unary RPC to release a hold on a laptop.
+func (server *LaptopServerV2) ReleaseHold(ctx context.Context, req *pb.ReleaseHoldRequest) (*pb.ReleaseHoldResponse, error) {
+	return server.server.ReleaseHold(ctx, req)
+}
+
+// GetTrendingLaptops is a unary RPC to get the most viewed laptops over a window.
+func (server *LaptopServerV2) GetTrendingLaptops(
+	ctx context.Context,
+	req *pb.GetTrendingLaptopsRequest,
+) (*pbv2.GetTrendingLaptopsResponse, error) {
+	res, err := server.server.GetTrendingLaptops(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	other := &pbv2.GetTrendingLaptopsResponse{}
+	for _, t := range res.GetLaptops() {
+		other.Laptops = append(other.Laptops, &pbv2.TrendingLaptop{
+			Laptop:      LaptopFromV1(t.GetLaptop()),
+			Views:       t.GetViews(),
+			Impressions: t.GetImpressions(),
+		})
+	}
+	return other, nil
+}
+
+// searchLaptopServerV1 sends the laptops found by the v1 server to a v2 stream.
+type searchLaptopServerV1 struct {
+	pbv2.LaptopService_SearchLaptopServer
+	readMask *fieldmaskpb.FieldMask
+}
+
+func (stream *searchLaptopServerV1) Send(res *pb.SearchLaptopResponse) error {
+	laptop := LaptopFromV1(res.GetLaptop())
+	applyFieldMask(stream.readMask, laptop)
+	return stream.LaptopService_SearchLaptopServer.Send(&pbv2.SearchLaptopResponse{Laptop: laptop})
+}
+
+// exportLaptopsServerV1 sends the laptops exported by the v1 server to a v2 stream.
+type exportLaptopsServerV1 struct {
+	pbv2.LaptopService_ExportLaptopsServer
+}
+
+func (stream *exportLaptopsServerV1) Send(res *pb.ExportLaptopsResponse) error {
+	return stream.LaptopService_ExportLaptopsServer.Send(&pbv2.ExportLaptopsResponse{Laptop: LaptopFromV1(res.GetLaptop())})
+}
+
+// watchLaptopsServerV1 sends the events of the v1 server to a v2 stream.
+type watchLaptopsServerV1 struct {
+	pbv2.LaptopService_WatchLaptopsServer
+}
+
+func (stream *watchLaptopsServerV1) Send(res *pb.WatchLaptopsResponse) error {
+	return stream.LaptopService_WatchLaptopsServer.Send(&pbv2.WatchLaptopsResponse{
+		Type:        res.GetType(),
+		Laptop:      LaptopFromV1(res.GetLaptop()),
+		ResumeToken: res.GetResumeToken(),
+	})
+}
+
+// LaptopToV1 converts a v2 laptop to a v1 laptop.
+// It fails if the price is not in USD since v1 laptops can't carry a currency.
+func LaptopToV1(laptop *pbv2.Laptop) (*pb.Laptop, error) {
+	if laptop == nil {
+		return nil, nil
+	}
+
+	price := laptop.GetPrice()
+	if price != nil && price.GetCurrencyCode() != usd {
+		return nil, fmt.Errorf("unsupported currency %q, must be %s", price.GetCurrencyCode(), usd)
+	}
+
+	other := &pb.Laptop{
+		Id:          laptop.GetId(),
+		Brand:       laptop.GetBrand(),
+		Name:        laptop.GetName(),
+		Cpu:         laptop.GetCpu(),
+		Ram:         laptop.GetRam(),
+		Gpus:        laptop.GetGpus(),
+		Storage:     laptop.GetStorage(),
+		Screen:      laptop.GetScreen(),
+		Keyboard:    laptop.GetKeyboard(),
+		PriceUsd:    float64(price.GetUnits()) + float64(price.GetNanos())/1e9,
+		ReleaseYear: laptop.GetReleaseYear(),
+		UpdatedAt:   laptop.GetUpdatedAt(),
+		Version:     laptop.GetVersion(),
+		DeletedAt:   laptop.GetDeletedAt(),
+	}
+
+	weight := laptop.GetWeight()
+	switch weight.GetUnit() {
+	case pbv2.Weight_KILOGRAM:
+		other.Weight = &pb.Laptop_WeightKg{WeightKg: weight.GetValue()}
+	case pbv2.Weight_POUND:
+		other.Weight = &pb.Laptop_WeightLb{WeightLb: weight.GetValue()}
+	}
+
+	return other, nil
+}
+
+// LaptopFromV1 converts a v1 laptop to a v2 laptop.
+func LaptopFromV1(laptop *pb.Laptop) *pbv2.Laptop {
+	if laptop == nil {
+		return nil
+	}
+
+	units, fraction := math.Modf(laptop.GetPriceUsd())
+	nanos := math.Round(fraction * 1e9)
+	if math.Abs(nanos) >= 1e9 {
+		units += math.Copysign(1, nanos)
+		nanos = 0
+	}
+
+	other := &pbv2.Laptop{
+		Id:          laptop.GetId(),
+		Brand:       laptop.GetBrand(),
+		Name:        laptop.GetName(),
+		Cpu:         laptop.GetCpu(),
+		Ram:         laptop.GetRam(),
+		Gpus:        laptop.GetGpus(),
+		Storage:     laptop.GetStorage(),
+		Screen:      laptop.GetScreen(),
+		Keyboard:    laptop.GetKeyboard(),
+		Price:       &pbv2.Money{CurrencyCode: usd, Units: int64(units), Nanos: int32(nanos)},
+		ReleaseYear: laptop.GetReleaseYear(),
+		UpdatedAt:   laptop.GetUpdatedAt(),
+		Version:     laptop.GetVersion(),
+		DeletedAt:   laptop.GetDeletedAt(),
+	}
+
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		other.Weight = &pbv2.Weight{Value: weight.WeightKg, Unit: pbv2.Weight_KILOGRAM}
+	case *pb.Laptop_WeightLb:
+		other.Weight = &pbv2.Weight{Value: weight.WeightLb, Unit: pbv2.Weight_POUND}
+	}
+
+	return other
+}
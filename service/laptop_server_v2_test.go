@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"context"
+	pbv2 "grpc_app/pb/v2"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLaptopV1RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = 1999.99
+
+	v2 := service.LaptopFromV1(laptop)
+	require.Equal(t, "USD", v2.GetPrice().GetCurrencyCode())
+	require.Equal(t, int64(1999), v2.GetPrice().GetUnits())
+	require.NotNil(t, v2.GetWeight())
+
+	v1, err := service.LaptopToV1(v2)
+	require.NoError(t, err)
+	require.InDelta(t, laptop.GetPriceUsd(), v1.GetPriceUsd(), 1e-6)
+	v1.PriceUsd = laptop.GetPriceUsd()
+	require.True(t, proto.Equal(laptop, v1))
+}
+
+func TestServerV2CreateLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	server := service.NewLaptopServerV2(service.NewLaptopServer(laptopStore, nil, nil))
+
+	laptop := service.LaptopFromV1(sample.NewLaptop())
+	res, err := server.CreateLaptop(context.Background(), &pbv2.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetId(), res.GetId())
+
+	found, err := laptopStore.Find(context.Background(), res.GetId())
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetBrand(), found.GetBrand())
+	require.Equal(t, float64(laptop.GetPrice().GetUnits())+float64(laptop.GetPrice().GetNanos())/1e9, found.GetPriceUsd())
+
+	euro := service.LaptopFromV1(sample.NewLaptop())
+	euro.Price.CurrencyCode = "EUR"
+	_, err = server.CreateLaptop(context.Background(), &pbv2.CreateLaptopRequest{Laptop: euro})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// snapshotPageSize is the number of laptops listed or saved at once by the snapshots,
+// which is at most the largest batch of every store.
+const snapshotPageSize = 100
+
+// SnapshotLaptops writes every laptop of the store to w, one JSON line each like the journals,
+// so the snapshot can be restored into a store of any kind. It returns the number of laptops written.
+// The laptops saved while the snapshot is taken may or may not be in it.
+func SnapshotLaptops(ctx context.Context, store LaptopStore, w io.Writer) (int, error) {
+	writer := bufio.NewWriter(w)
+	written := 0
+	err := forEachLaptop(ctx, store, func(laptop *pb.Laptop) error {
+		line, err := protojson.Marshal(laptop)
+		if err != nil {
+			return fmt.Errorf("cannot marshal laptop: %w", err)
+		}
+		_, err = writer.Write(append(line, '\n'))
+		if err != nil {
+			return fmt.Errorf("cannot write snapshot: %w", err)
+		}
+		written++
+		return nil
+	})
+	if err != nil {
+		return written, err
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return written, fmt.Errorf("cannot write snapshot: %w", err)
+	}
+	return written, nil
+}
+
+// forEachLaptop calls found with every laptop of the store, in the order of its List,
+// listing them a page at a time.
+func forEachLaptop(ctx context.Context, store LaptopStore, found func(laptop *pb.Laptop) error) error {
+	pageToken := ""
+	for {
+		laptops, nextPageToken, err := store.List(ctx, snapshotPageSize, pageToken)
+		if err != nil {
+			return err
+		}
+
+		for _, laptop := range laptops {
+			err := found(laptop)
+			if err != nil {
+				return err
+			}
+		}
+
+		if nextPageToken == "" {
+			return nil
+		}
+		pageToken = nextPageToken
+	}
+}
+
+// RestoreLaptops saves the laptops of a snapshot written by SnapshotLaptops to the store, in batches,
+// and returns the number of laptops saved. A batch fails with ErrAlreadyExist if one of its laptops
+// is already in the store, and the batches saved before it are kept.
+func RestoreLaptops(ctx context.Context, store LaptopStore, r io.Reader) (int, error) {
+	reader := bufio.NewReader(r)
+	saved := 0
+	batch := make([]*pb.Laptop, 0, snapshotPageSize)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return saved, fmt.Errorf("cannot read snapshot: %w", err)
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			laptop := &pb.Laptop{}
+			err := protojson.Unmarshal(line, laptop)
+			if err != nil {
+				return saved, fmt.Errorf("invalid laptop %d of snapshot: %w", saved+len(batch)+1, err)
+			}
+			batch = append(batch, laptop)
+		}
+
+		if len(batch) == snapshotPageSize || (errors.Is(err, io.EOF) && len(batch) > 0) {
+			err := store.SaveBatch(ctx, batch)
+			if err != nil {
+				return saved, err
+			}
+			saved += len(batch)
+			batch = make([]*pb.Laptop, 0, snapshotPageSize)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return saved, nil
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSnapshotLaptops(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	for i := 0; i < 150; i++ {
+		require.NoError(t, store.Save(context.Background(), sample.NewLaptop()))
+	}
+
+	var snapshot bytes.Buffer
+	written, err := service.SnapshotLaptops(context.Background(), store, &snapshot)
+	require.NoError(t, err)
+	require.Equal(t, 150, written)
+
+	other := service.NewInMemoryLaptopStore()
+	saved, err := service.RestoreLaptops(context.Background(), other, bytes.NewReader(snapshot.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 150, saved)
+
+	ids := listAll(t, store, 50)
+	require.Equal(t, ids, listAll(t, other, 50))
+	laptop, err := store.Find(context.Background(), ids[0])
+	require.NoError(t, err)
+	restored, err := other.Find(context.Background(), ids[0])
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, restored))
+
+	_, err = service.RestoreLaptops(context.Background(), other, bytes.NewReader(snapshot.Bytes()))
+	require.ErrorIs(t, err, service.ErrAlreadyExist)
+
+	_, err = service.RestoreLaptops(context.Background(), service.NewInMemoryLaptopStore(), strings.NewReader("{}\nnot json\n"))
+	require.Error(t, err)
+}
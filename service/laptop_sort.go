@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"grpc_app/pb"
+	"sort"
+)
+
+// sortFields are the filter fields of the sort fields, which have the same value and column.
+var sortFields = map[pb.Sort_Field]string{
+	pb.Sort_PRICE_USD:        "price_usd",
+	pb.Sort_CPU_NUMBER_CORES: "cpu.number_cores",
+	pb.Sort_RAM:              "ram",
+}
+
+// ValidateSort checks that the fields of the sort are known.
+func ValidateSort(sortBy []*pb.Sort) error {
+	for _, sort := range sortBy {
+		if _, ok := sortFields[sort.GetField()]; !ok {
+			return fmt.Errorf("unknown sort field %s", sort.GetField())
+		}
+	}
+	return nil
+}
+
+// compareLaptops compares the laptops by each field of the sort in turn, then by ID,
+// and returns a negative number if laptop1 comes first, or a positive one if laptop2 does.
+func compareLaptops(sortBy []*pb.Sort, laptop1 *pb.Laptop, laptop2 *pb.Laptop) int {
+	for _, sort := range sortBy {
+		field := filterFields[sortFields[sort.GetField()]]
+		c := compareValues(field.value(laptop1), field.value(laptop2))
+		if sort.GetDescending() {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case laptop1.GetId() < laptop2.GetId():
+		return -1
+	case laptop1.GetId() > laptop2.GetId():
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareValues compares two values of a numeric filter field, which are both float64 or uint64.
+func compareValues(value1 interface{}, value2 interface{}) int {
+	switch value1 := value1.(type) {
+	case float64:
+		value2 := value2.(float64)
+		if value1 < value2 {
+			return -1
+		}
+		if value1 > value2 {
+			return 1
+		}
+	case uint64:
+		value2 := value2.(uint64)
+		if value1 < value2 {
+			return -1
+		}
+		if value1 > value2 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// searchSorted searches for laptops with search, and returns them via the found function in the order
+// of the sort of the filter, for the stores that can't sort them. With a sort, all the laptops
+// have to be found before the first one is returned.
+func searchSorted(
+	ctx context.Context,
+	search func(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	if len(filter.GetSortBy()) == 0 {
+		return search(ctx, filter, found)
+	}
+	err := ValidateSort(filter.GetSortBy())
+	if err != nil {
+		return err
+	}
+
+	var laptops []*pb.Laptop
+	err = search(ctx, filter, func(laptop *pb.Laptop) error {
+		laptops = append(laptops, laptop)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(laptops, func(i, j int) bool {
+		return compareLaptops(filter.GetSortBy(), laptops[i], laptops[j]) < 0
+	})
+	for _, laptop := range laptops {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestInMemoryLaptopStoreSort(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	for i := 0; i < 20; i++ {
+		laptop := sample.NewLaptop()
+		laptop.Cpu.NumberCores = uint32(i % 3)
+		require.NoError(t, store.Save(context.Background(), laptop))
+	}
+
+	filter := &pb.Filter{
+		MaxPriceUsd: 1e6,
+		SortBy: []*pb.Sort{
+			{Field: pb.Sort_CPU_NUMBER_CORES, Descending: true},
+			{Field: pb.Sort_PRICE_USD},
+		},
+	}
+	var laptops []*pb.Laptop
+	err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		laptops = append(laptops, laptop)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, laptops, 20)
+
+	for i := 1; i < len(laptops); i++ {
+		previous, laptop := laptops[i-1], laptops[i]
+		require.GreaterOrEqual(t, previous.GetCpu().GetNumberCores(), laptop.GetCpu().GetNumberCores())
+		if previous.GetCpu().GetNumberCores() == laptop.GetCpu().GetNumberCores() {
+			require.LessOrEqual(t, previous.GetPriceUsd(), laptop.GetPriceUsd())
+		}
+	}
+
+	filter.SortBy = []*pb.Sort{{}}
+	err = store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		return nil
+	})
+	require.Error(t, err)
+
+	server := service.NewLaptopServer(store, nil, nil)
+	err = server.SearchLaptop(&pb.SearchLaptopRequest{Filter: filter}, nil)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"grpc_app/pb"
+	"math"
+)
+
+// LaptopAggregator is implemented by the laptop stores that compute the catalog statistics
+// without returning the laptops.
+type LaptopAggregator interface {
+	// Stats returns the statistics of the laptops matching the filter.
+	Stats(ctx context.Context, filter *pb.Filter) (*pb.CatalogStats, error)
+}
+
+// aggregateLaptops returns the statistics of the laptops of the store matching the filter,
+// computed by the store if it is a LaptopAggregator, or from the laptops it finds otherwise.
+func aggregateLaptops(ctx context.Context, store LaptopStore, filter *pb.Filter) (*pb.CatalogStats, error) {
+	if aggregator, ok := store.(LaptopAggregator); ok {
+		return aggregator.Stats(ctx, filter)
+	}
+	return statsBySearch(ctx, store, filter)
+}
+
+// statsBySearch computes the statistics of the laptops found by the search of the store.
+func statsBySearch(ctx context.Context, store LaptopStore, filter *pb.Filter) (*pb.CatalogStats, error) {
+	stats := newCatalogStats()
+	err := store.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		stats.add(laptop)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats.result(), nil
+}
+
+// catalogStats accumulates the statistics of the laptops added to it.
+type catalogStats struct {
+	stats *pb.CatalogStats
+	total float64
+}
+
+func newCatalogStats() *catalogStats {
+	return &catalogStats{stats: &pb.CatalogStats{
+		CountByBrand:    make(map[string]int64),
+		CountByCpuCores: make(map[uint32]int64),
+		CountByRamGb:    make(map[uint64]int64),
+		MinPriceUsd:     math.Inf(1),
+		MaxPriceUsd:     math.Inf(-1),
+	}}
+}
+
+func (s *catalogStats) add(laptop *pb.Laptop) {
+	s.stats.Count++
+	s.stats.CountByBrand[laptop.GetBrand()]++
+	s.stats.CountByCpuCores[laptop.GetCpu().GetNumberCores()]++
+	s.stats.CountByRamGb[ramGB(toBit(laptop.GetRam()))]++
+	s.stats.MinPriceUsd = math.Min(s.stats.MinPriceUsd, laptop.GetPriceUsd())
+	s.stats.MaxPriceUsd = math.Max(s.stats.MaxPriceUsd, laptop.GetPriceUsd())
+	s.total += laptop.GetPriceUsd()
+}
+
+func (s *catalogStats) result() *pb.CatalogStats {
+	if s.stats.Count == 0 {
+		s.stats.MinPriceUsd = 0
+		s.stats.MaxPriceUsd = 0
+		return s.stats
+	}
+	s.stats.AvgPriceUsd = s.total / float64(s.stats.Count)
+	return s.stats
+}
+
+// ramGB returns the RAM in bits in gigabytes, rounded down.
+func ramGB(bits uint64) uint64 {
+	return bits >> 33
+}
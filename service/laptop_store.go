@@ -4,22 +4,47 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"grpc_app/logging"
 	"grpc_app/pb"
-	"log"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
-	"github.com/jinzhu/copier"
+	"google.golang.org/protobuf/proto"
 )
 
 // ErrAlreadyExist is returned when a record with the same ID already exists in the store.
 var ErrAlreadyExist = errors.New("record already exist")
 
-// LaptopStore is an interface to store laptop.
+// ErrNotFound is returned when no record has the ID in the store.
+var ErrNotFound = errors.New("record not found")
+
+// ErrVersionConflict is returned when the version of an updated record isn't the stored one,
+// as the record was updated since it was read.
+var ErrVersionConflict = errors.New("record version conflict")
+
+// LaptopStore is an interface to store laptop. The stores stop waiting for their
+// backends when the context is done, and return the error of the context.
 type LaptopStore interface {
 	// Save saves the laptop to the store.
-	Save(laptop *pb.Laptop) error
+	Save(ctx context.Context, laptop *pb.Laptop) error
+	// SaveBatch saves all the laptops or none of them, and returns ErrAlreadyExist
+	// if one of their IDs already exists or appears twice in the batch.
+	SaveBatch(ctx context.Context, laptops []*pb.Laptop) error
+	// Update replaces the laptop with the same ID and increments its version, or returns ErrNotFound,
+	// or ErrVersionConflict if the version of the laptop isn't the stored one.
+	Update(ctx context.Context, laptop *pb.Laptop) error
+	// Delete deletes the laptop with the ID, or returns ErrNotFound.
+	Delete(ctx context.Context, id string) error
 	// Find finds a laptop by ID.
-	Find(id string) (*pb.Laptop, error)
+	Find(ctx context.Context, id string) (*pb.Laptop, error)
+	// List returns a page of at most pageSize laptops in a stable order, starting at the page token
+	// or at the first laptop if it's empty, and the token of the next page, empty after the last page.
+	List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error)
+	// Count returns the number of laptops matching the filter.
+	Count(ctx context.Context, filter *pb.Filter) (int64, error)
 	// Search searches for laptops with filter, returns one by one via the found function.
 	Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error
 }
@@ -28,97 +53,372 @@ type LaptopStore interface {
 type InMemoryLaptopStore struct {
 	mutex sync.RWMutex
 	data  map[string]*pb.Laptop
+	// journal is the file the saved laptops are appended to, if any.
+	journal *os.File
+	// expiresAt is when the laptops with a TTL expire. The expired laptops
+	// are ignored until the sweeper deletes them.
+	expiresAt  map[string]time.Time
+	defaultTTL time.Duration
+	// indexes are the indexes of the laptops of data used by Search and Count.
+	indexes *laptopIndexes
+	logger  *logging.Logger
 }
 
-// DBLaptopStore stores laptop in DB.
-// THIS ONE FOR LATER!
-type DBLaptopStore struct{}
-
 // NewInMemoryLaptopStore returns a new InMemoryLaptopStore.
 func NewInMemoryLaptopStore() *InMemoryLaptopStore {
 	return &InMemoryLaptopStore{
-		data: make(map[string]*pb.Laptop),
+		data:      make(map[string]*pb.Laptop),
+		expiresAt: make(map[string]time.Time),
+		indexes:   newLaptopIndexes(),
+		logger:    logging.Default(),
 	}
 }
 
+// SetLogger replaces the logger of the store, the default logger by default. It must be called before Run.
+func (store *InMemoryLaptopStore) SetLogger(logger *logging.Logger) {
+	store.logger = logger
+}
+
 // Save saves the laptop to the store
-func (store *InMemoryLaptopStore) Save(laptop *pb.Laptop) error {
+func (store *InMemoryLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
 
-	if store.data[laptop.Id] != nil {
+	if store.lookup(laptop.Id) != nil {
 		return ErrAlreadyExist
 	}
 
 	// deep copy
-	other, err := deepCopy(laptop)
+	other := deepCopy(laptop)
+
+	if store.journal != nil {
+		err := appendJournal(store.journal, other)
+		if err != nil {
+			return err
+		}
+	}
+
+	store.put(other)
+	store.setTTL(other.Id, store.defaultTTL)
+	return nil
+}
+
+// SaveBatch saves the laptops to the store, all of them or none
+func (store *InMemoryLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	err := checkBatchIDs(laptops)
 	if err != nil {
 		return err
 	}
 
-	store.data[other.Id] = other
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	others := make([]*pb.Laptop, len(laptops))
+	for i, laptop := range laptops {
+		if store.lookup(laptop.Id) != nil {
+			return ErrAlreadyExist
+		}
+		others[i] = deepCopy(laptop)
+	}
+
+	if store.journal != nil {
+		err = appendJournal(store.journal, others...)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, other := range others {
+		store.put(other)
+		store.setTTL(other.Id, store.defaultTTL)
+	}
+	return nil
+}
+
+// checkBatchIDs returns ErrAlreadyExist if two laptops of the batch have the same ID.
+func checkBatchIDs(laptops []*pb.Laptop) error {
+	ids := make(map[string]bool, len(laptops))
+	for _, laptop := range laptops {
+		if ids[laptop.GetId()] {
+			return ErrAlreadyExist
+		}
+		ids[laptop.GetId()] = true
+	}
 	return nil
 }
 
+// saveEach saves the laptops one by one, and deletes the saved ones if one of them can't be saved.
+// It is the batch save of the stores without transactions, whose readers can see the first laptops
+// of a batch before it fails.
+func saveEach(ctx context.Context, store LaptopStore, laptops []*pb.Laptop) error {
+	err := checkBatchIDs(laptops)
+	if err != nil {
+		return err
+	}
+
+	for i, laptop := range laptops {
+		err := store.Save(ctx, laptop)
+		if err == nil {
+			continue
+		}
+
+		for _, saved := range laptops[:i] {
+			// The saved laptops are deleted even if the context is done, not to keep a part of the batch.
+			if deleteErr := store.Delete(context.Background(), saved.GetId()); deleteErr != nil {
+				return fmt.Errorf("cannot delete the saved laptops after %v: %w", err, deleteErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Update replaces the laptop with the same ID in the store
+func (store *InMemoryLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	err := checkVersion(store.lookup(laptop.Id), laptop)
+	if err != nil {
+		return err
+	}
+
+	other := nextVersion(laptop)
+
+	if store.journal != nil {
+		err = appendJournal(store.journal, other)
+		if err != nil {
+			return err
+		}
+	}
+
+	store.put(other)
+	laptop.Version = other.Version
+	return nil
+}
+
+// checkVersion returns ErrNotFound if there is no stored laptop,
+// or ErrVersionConflict if the version of the laptop isn't the stored one.
+func checkVersion(stored *pb.Laptop, laptop *pb.Laptop) error {
+	if stored == nil {
+		return ErrNotFound
+	}
+	if stored.GetVersion() != laptop.GetVersion() {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// nextVersion returns a copy of the laptop with the next version, as stored by Update.
+func nextVersion(laptop *pb.Laptop) *pb.Laptop {
+	next := deepCopy(laptop)
+	next.Version++
+	return next
+}
+
+// Delete deletes the laptop with the ID from the store
+func (store *InMemoryLaptopStore) Delete(ctx context.Context, id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.lookup(id) == nil {
+		return ErrNotFound
+	}
+	return store.delete(id)
+}
+
+// delete deletes the laptop with the ID, which must be locked.
+func (store *InMemoryLaptopStore) delete(id string) error {
+	if store.journal != nil {
+		err := appendTombstone(store.journal, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if laptop, ok := store.data[id]; ok {
+		store.indexes.remove(laptop)
+	}
+	delete(store.data, id)
+	delete(store.expiresAt, id)
+	return nil
+}
+
+// put stores the laptop in place of the one with the same ID, and indexes it, which must be locked.
+func (store *InMemoryLaptopStore) put(laptop *pb.Laptop) {
+	if old, ok := store.data[laptop.Id]; ok {
+		store.indexes.remove(old)
+	}
+	store.data[laptop.Id] = laptop
+	store.indexes.insert(laptop)
+}
+
 // Find finds a laptop by ID
-func (store *InMemoryLaptopStore) Find(id string) (*pb.Laptop, error) {
+func (store *InMemoryLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
 	store.mutex.RLock()
 	defer store.mutex.RLocker().Unlock()
 
-	laptop := store.data[id]
+	laptop := store.lookup(id)
 	if laptop == nil {
 		return nil, nil
 	}
 
-	// deep copy
-	other := &pb.Laptop{}
-	err := copier.Copy(other, laptop)
+	return deepCopy(laptop), nil
+}
+
+// List returns a page of laptops in ID order
+func (store *InMemoryLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+	after, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	ids := make([]string, 0, len(store.data))
+	for id := range store.data {
+		if id > after && !store.expired(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > pageSize+1 {
+		ids = ids[:pageSize+1]
+	}
+
+	laptops := make([]*pb.Laptop, len(ids))
+	for i, id := range ids {
+		laptops[i] = deepCopy(store.data[id])
+	}
+
+	laptops, nextPageToken := nextPage(laptops, pageSize)
+	return laptops, nextPageToken, nil
+}
+
+// Count returns the number of laptops matching the filter
+func (store *InMemoryLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	var count int64
+	err := store.scan(ctx, filter, func(laptop *pb.Laptop) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Stats returns the statistics of the laptops matching the filter
+func (store *InMemoryLaptopStore) Stats(ctx context.Context, filter *pb.Filter) (*pb.CatalogStats, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	stats := newCatalogStats()
+	err := store.scan(ctx, filter, func(laptop *pb.Laptop) error {
+		stats.add(laptop)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats.result(), nil
+}
+
+// countBySearch counts the laptops found by the search of the store,
+// for the stores that can't count the laptops without reading them.
+func countBySearch(ctx context.Context, store LaptopStore, filter *pb.Filter) (int64, error) {
+	var count int64
+	err := store.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		count++
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("cannot copy laptop data: %w", err)
+		return 0, err
 	}
-	return deepCopy(laptop)
+	return count, nil
 }
 
 // Search searches for laptops with filter, returns one by one via the found function.
+// The laptops are sorted after they are all found, if the filter has a sort.
 func (store *InMemoryLaptopStore) Search(
 	ctx context.Context,
 	filter *pb.Filter,
 	found func(laptop *pb.Laptop) error,
+) error {
+	return searchSorted(ctx, store.search, filter, found)
+}
+
+// search searches for laptops with filter in the order of the store.
+func (store *InMemoryLaptopStore) search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
 
 ) error {
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
-	for _, laptop := range store.data {
+	return store.scan(ctx, filter, func(laptop *pb.Laptop) error {
+		// deep copy
+		return found(deepCopy(laptop))
+	})
+}
+
+// scan calls fn with each laptop that hasn't expired and matches the filter, which must be locked.
+// If the filter bounds an indexed field, only the laptops within the bound are read.
+func (store *InMemoryLaptopStore) scan(
+	ctx context.Context,
+	filter *pb.Filter,
+	fn func(laptop *pb.Laptop) error,
+) error {
+	qualify := func(laptop *pb.Laptop) error {
 
 		// // heavy processing
 		// time.Sleep(time.Second)
 		// log.Print("checking laptop id: ", laptop.GetId(), laptop.GetBrand())
 
-		if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
-			log.Print("context is canceled")
-			return errors.New("context is canceled")
+		if err := ctx.Err(); err != nil {
+			store.logger.Debug("search is canceled", "error", err)
+			return err
 		}
 
-		if isQualified(filter, laptop) {
-			// deep copy
-			other, err := deepCopy(laptop)
-			if err != nil {
-				return err
-			}
+		if store.expired(laptop.Id) || !isQualified(filter, laptop) {
+			return nil
+		}
+		return fn(laptop)
+	}
 
-			err = found(other)
+	if candidates, ok := store.indexes.candidates(filter); ok {
+		for _, entry := range candidates {
+			err := qualify(store.data[entry.id])
 			if err != nil {
 				return err
 			}
 		}
+		return nil
 	}
 
+	for _, laptop := range store.data {
+		err := qualify(laptop)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
-	if laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
+	expression := filter.GetExpression()
+
+	// With an expression, the max price only applies if it is set.
+	if (expression == nil || filter.GetMaxPriceUsd() != 0) && laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
 		return false
 	}
 
@@ -134,6 +434,27 @@ func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
 		return false
 	}
 
+	return matchesText(filter.GetText(), laptop) && evaluate(expression, laptop)
+}
+
+// textFields returns the fields of the laptop searched by the text of a filter.
+func textFields(laptop *pb.Laptop) []string {
+	return []string{laptop.GetBrand(), laptop.GetName(), laptop.GetCpu().GetBrand(), laptop.GetCpu().GetName()}
+}
+
+// matchesText reports whether each word of the text appears in a text field of the laptop, ignoring case.
+func matchesText(text string, laptop *pb.Laptop) bool {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) == 0 {
+		return true
+	}
+
+	fields := strings.ToLower(strings.Join(textFields(laptop), " "))
+	for _, word := range words {
+		if !strings.Contains(fields, word) {
+			return false
+		}
+	}
 	return true
 }
 
@@ -158,13 +479,8 @@ func toBit(memory *pb.Memory) uint64 {
 	}
 }
 
-// Deep copy.
-func deepCopy(laptop *pb.Laptop) (*pb.Laptop, error) {
-	other := &pb.Laptop{}
-	err := copier.Copy(other, laptop)
-	if err != nil {
-		return nil, fmt.Errorf("cannot copy laptop data: %w", err)
-	}
-
-	return other, nil
+// deepCopy returns a deep copy of the laptop, which shares none of its messages and lists,
+// so the laptops of the stores can't be changed by the callers.
+func deepCopy(laptop *pb.Laptop) *pb.Laptop {
+	return proto.Clone(laptop).(*pb.Laptop)
 }
@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// listAll lists the IDs of all the laptops of the store, page by page.
+func listAll(t *testing.T, store service.LaptopStore, pageSize int) []string {
+	var ids []string
+	pageToken := ""
+	for {
+		laptops, nextPageToken, err := store.List(context.Background(), pageSize, pageToken)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(laptops), pageSize)
+
+		for _, laptop := range laptops {
+			ids = append(ids, laptop.GetId())
+		}
+		if nextPageToken == "" {
+			return ids
+		}
+		pageToken = nextPageToken
+	}
+}
+
+func TestInMemoryLaptopStoreList(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	var want []string
+	for i := 0; i < 10; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(context.Background(), laptop))
+		want = append(want, laptop.GetId())
+	}
+	sort.Strings(want)
+
+	for _, pageSize := range []int{1, 3, 10, 100} {
+		require.Equal(t, want, listAll(t, store, pageSize))
+	}
+
+	_, _, err := store.List(context.Background(), 0, "")
+	require.Error(t, err)
+	_, _, err = store.List(context.Background(), 5, "not a token!")
+	require.ErrorIs(t, err, service.ErrInvalidPageToken)
+}
+
+func TestInMemoryLaptopStoreSaveBatch(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	existing := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), existing))
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.ErrorIs(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop1, existing}), service.ErrAlreadyExist)
+	require.ErrorIs(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop1, laptop1}), service.ErrAlreadyExist)
+	require.Equal(t, []string{existing.GetId()}, listAll(t, store, 10), "failed batches save nothing")
+
+	require.NoError(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop1, laptop2}))
+	require.ElementsMatch(t, []string{existing.GetId(), laptop1.GetId(), laptop2.GetId()}, listAll(t, store, 10))
+}
+
+func TestInMemoryLaptopStoreCanceled(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	require.NoError(t, store.Save(context.Background(), sample.NewLaptop()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := store.Search(ctx, &pb.Filter{MaxPriceUsd: 1e6}, func(laptop *pb.Laptop) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = store.Count(ctx, &pb.Filter{MaxPriceUsd: 1e6})
+	require.ErrorIs(t, err, context.Canceled)
+
+	server := service.NewLaptopServer(store, nil, nil)
+	_, err = server.CountLaptops(ctx, &pb.CountLaptopsRequest{Filter: &pb.Filter{MaxPriceUsd: 1e6}})
+	require.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestInMemoryLaptopStoreCopies(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	laptop.Cpu.Brand = "changed after save"
+
+	found, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.NotEqual(t, "changed after save", found.GetCpu().GetBrand(), "the store keeps a copy of the saved laptop")
+	require.NotEmpty(t, found.GetStorage())
+
+	found.Cpu.Brand = "changed after find"
+	found.Storage[0].Driver = pb.Storage_UNKNOWN
+	again, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.NotEqual(t, "changed after find", again.GetCpu().GetBrand(), "the found laptop is a copy")
+	require.NotEqual(t, pb.Storage_UNKNOWN, again.GetStorage()[0].GetDriver())
+}
+
+func BenchmarkInMemoryLaptopStoreSave(b *testing.B) {
+	store := service.NewInMemoryLaptopStore()
+	laptops := make([]*pb.Laptop, b.N)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for _, laptop := range laptops {
+		if err := store.Save(context.Background(), laptop); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInMemoryLaptopStoreFind(b *testing.B) {
+	store := service.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(b, store.Save(context.Background(), laptop))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Find(context.Background(), laptop.GetId()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInMemoryLaptopStoreSearch(b *testing.B) {
+	store := service.NewInMemoryLaptopStore()
+	for i := 0; i < 1000; i++ {
+		require.NoError(b, store.Save(context.Background(), sample.NewLaptop()))
+	}
+
+	// The filter matches all the laptops, so each of them is copied.
+	filter := &pb.Filter{MaxPriceUsd: 1e9}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"grpc_app/pb"
+	"time"
+)
+
+// SetDefaultTTL makes the laptops saved from now on expire after ttl, or never if it is not positive.
+// The TTLs are not journaled, so the laptops replayed from a journal never expire.
+func (store *InMemoryLaptopStore) SetDefaultTTL(ttl time.Duration) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.defaultTTL = ttl
+}
+
+// SetTTL makes the laptop with the ID expire after ttl, or never if it is not positive.
+func (store *InMemoryLaptopStore) SetTTL(id string, ttl time.Duration) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.lookup(id) == nil {
+		return ErrNotFound
+	}
+	store.setTTL(id, ttl)
+	return nil
+}
+
+// setTTL sets the TTL of the laptop with the ID, which must be locked.
+func (store *InMemoryLaptopStore) setTTL(id string, ttl time.Duration) {
+	if ttl > 0 {
+		store.expiresAt[id] = time.Now().Add(ttl)
+	} else {
+		delete(store.expiresAt, id)
+	}
+}
+
+// expired reports whether the laptop with the ID has expired, which must be locked.
+func (store *InMemoryLaptopStore) expired(id string) bool {
+	expiresAt, ok := store.expiresAt[id]
+	return ok && !time.Now().Before(expiresAt)
+}
+
+// lookup returns the laptop with the ID unless it has expired, which must be locked.
+func (store *InMemoryLaptopStore) lookup(id string) *pb.Laptop {
+	if store.expired(id) {
+		return nil
+	}
+	return store.data[id]
+}
+
+// Sweep deletes the expired laptops, and returns the number of laptops deleted.
+func (store *InMemoryLaptopStore) Sweep() (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	swept := 0
+	for id := range store.expiresAt {
+		if !store.expired(id) {
+			continue
+		}
+		err := store.delete(id)
+		if err != nil {
+			return swept, err
+		}
+		swept++
+	}
+	return swept, nil
+}
+
+// Run sweeps the expired laptops every interval until the context is done.
+func (store *InMemoryLaptopStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		swept, err := store.Sweep()
+		if err != nil {
+			store.logger.Error("cannot sweep expired laptops", "error", err)
+		}
+		if swept > 0 {
+			store.logger.Info("swept expired laptops", "swept", swept)
+		}
+	}
+}
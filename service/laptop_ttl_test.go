@@ -0,0 +1,41 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryLaptopStoreTTL(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	kept := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), kept))
+
+	store.SetDefaultTTL(50 * time.Millisecond)
+	expiring := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), expiring))
+	renewed := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), renewed))
+	require.NoError(t, store.SetTTL(renewed.GetId(), time.Hour))
+	require.ErrorIs(t, store.SetTTL("unknown", time.Hour), service.ErrNotFound)
+
+	time.Sleep(100 * time.Millisecond)
+	laptop, err := store.Find(context.Background(), expiring.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop, "the laptop has expired")
+	require.ElementsMatch(t, []string{kept.GetId(), renewed.GetId()}, listAll(t, store, 10))
+	require.ErrorIs(t, store.Update(context.Background(), expiring), service.ErrNotFound)
+
+	swept, err := store.Sweep()
+	require.NoError(t, err)
+	require.Equal(t, 1, swept)
+
+	require.NoError(t, store.SaveBatch(context.Background(), []*pb.Laptop{expiring}), "the ID of an expired laptop can be saved again")
+}
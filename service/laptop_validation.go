@@ -0,0 +1,132 @@
+package service
+
+import (
+	"fmt"
+	"grpc_app/pb"
+	"math"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// minReleaseYear is the earliest release year of a laptop.
+const minReleaseYear = 1970
+
+// laptopValidator collects the violations of the fields of a laptop.
+type laptopValidator struct {
+	violations []*errdetails.BadRequest_FieldViolation
+}
+
+func (validator *laptopValidator) check(ok bool, field string, format string, args ...interface{}) {
+	if !ok {
+		validator.violations = append(validator.violations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+}
+
+// ValidateLaptop checks the specs of the laptop before it's saved, and returns a codes.InvalidArgument
+// error with a BadRequest detail listing every invalid field, or nil if the laptop is valid.
+func ValidateLaptop(laptop *pb.Laptop) error {
+	if laptop == nil {
+		return status.Errorf(codes.InvalidArgument, "laptop is required")
+	}
+
+	validator := &laptopValidator{}
+	validator.check(strings.TrimSpace(laptop.GetBrand()) != "", "brand", "must not be empty")
+	validator.check(strings.TrimSpace(laptop.GetName()) != "", "name", "must not be empty")
+	validator.validateCPU(laptop.GetCpu())
+	validator.validateMemory("ram", laptop.GetRam())
+	for i, gpu := range laptop.GetGpus() {
+		validator.validateGPU(fmt.Sprintf("gpus[%d]", i), gpu)
+	}
+	for i, storage := range laptop.GetStorage() {
+		field := fmt.Sprintf("storage[%d]", i)
+		_, known := pb.Storage_Driver_name[int32(storage.GetDriver())]
+		validator.check(known && storage.GetDriver() != pb.Storage_UNKNOWN, field+".driver", "must be HDD or SSD")
+		validator.validateMemory(field+".memory", storage.GetMemory())
+	}
+	if screen := laptop.GetScreen(); screen != nil {
+		validator.check(isPositive(float64(screen.GetSizeInch())), "screen.size_inch", "must be positive, got %v", screen.GetSizeInch())
+		validator.check(screen.GetResolution().GetWidth() > 0, "screen.resolution.width", "must be positive")
+		validator.check(screen.GetResolution().GetHeight() > 0, "screen.resolution.height", "must be positive")
+	}
+
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		validator.check(isPositive(weight.WeightKg), "weight_kg", "must be positive, got %v", weight.WeightKg)
+	case *pb.Laptop_WeightLb:
+		validator.check(isPositive(weight.WeightLb), "weight_lb", "must be positive, got %v", weight.WeightLb)
+	}
+
+	price := laptop.GetPriceUsd()
+	validator.check(price >= 0 && !math.IsInf(price, 1), "price_usd", "must not be negative, got %v", price)
+
+	if year := laptop.GetReleaseYear(); year != 0 {
+		maxYear := uint32(time.Now().Year() + 1)
+		validator.check(year >= minReleaseYear && year <= maxYear, "release_year",
+			"must be between %d and %d, got %d", minReleaseYear, maxYear, year)
+	}
+
+	return validator.err()
+}
+
+func (validator *laptopValidator) validateCPU(cpu *pb.CPU) {
+	if cpu == nil {
+		validator.check(false, "cpu", "is required")
+		return
+	}
+
+	validator.check(cpu.GetNumberCores() > 0, "cpu.number_cores", "must be at least 1")
+	validator.check(cpu.GetNumberThreads() >= cpu.GetNumberCores(), "cpu.number_threads",
+		"must be at least the number of cores %d, got %d", cpu.GetNumberCores(), cpu.GetNumberThreads())
+	validator.validateFrequencies("cpu", cpu.GetMinGhz(), cpu.GetMaxGhz())
+}
+
+func (validator *laptopValidator) validateGPU(field string, gpu *pb.GPU) {
+	validator.validateFrequencies(field, gpu.GetMinGhz(), gpu.GetMaxGhz())
+	validator.validateMemory(field+".memory", gpu.GetMemory())
+}
+
+func (validator *laptopValidator) validateFrequencies(field string, minGhz float64, maxGhz float64) {
+	validator.check(isPositive(minGhz), field+".min_ghz", "must be positive, got %v", minGhz)
+	validator.check(maxGhz >= minGhz, field+".max_ghz", "must be at least min_ghz %v, got %v", minGhz, maxGhz)
+}
+
+func (validator *laptopValidator) validateMemory(field string, memory *pb.Memory) {
+	if memory == nil {
+		validator.check(false, field, "is required")
+		return
+	}
+
+	validator.check(memory.GetValue() > 0, field+".value", "must be positive")
+	_, known := pb.Memory_Unit_name[int32(memory.GetUnit())]
+	validator.check(known && memory.GetUnit() != pb.Memory_UNKNOWN, field+".unit", "must be a known unit, got %v", memory.GetUnit())
+}
+
+// err returns the codes.InvalidArgument error of the violations, or nil if there are none.
+func (validator *laptopValidator) err() error {
+	if len(validator.violations) == 0 {
+		return nil
+	}
+
+	descriptions := make([]string, 0, len(validator.violations))
+	for _, violation := range validator.violations {
+		descriptions = append(descriptions, violation.GetField()+" "+violation.GetDescription())
+	}
+	st := status.Newf(codes.InvalidArgument, "invalid laptop: %s", strings.Join(descriptions, "; "))
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: validator.violations})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// isPositive reports whether the value is a finite number above zero.
+func isPositive(value float64) bool {
+	return value > 0 && !math.IsInf(value, 1)
+}
@@ -0,0 +1,65 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateLaptop(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, service.ValidateLaptop(sample.NewLaptop()))
+	require.Equal(t, codes.InvalidArgument, status.Code(service.ValidateLaptop(nil)))
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = -1
+	laptop.Cpu.NumberCores = 0
+	laptop.Ram.Unit = pb.Memory_UNKNOWN
+	laptop.Weight = &pb.Laptop_WeightKg{WeightKg: 0}
+
+	err := service.ValidateLaptop(laptop)
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Contains(t, st.Message(), "price_usd")
+
+	var fields []string
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		require.True(t, ok)
+		for _, violation := range badRequest.GetFieldViolations() {
+			fields = append(fields, violation.GetField())
+		}
+	}
+	require.ElementsMatch(t, []string{"price_usd", "cpu.number_cores", "ram.unit", "weight_kg"}, fields)
+}
+
+func TestServerCreateInvalidLaptop(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewInMemoryLaptopStore()
+	server := service.NewLaptopServer(store, nil, nil)
+
+	laptop := sample.NewLaptop()
+	laptop.Cpu.MaxGhz = laptop.Cpu.MinGhz - 1
+	_, err := server.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	found, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found, "the invalid laptop isn't saved")
+
+	valid := sample.NewLaptop()
+	req := &pb.BatchCreateLaptopsRequest{Laptops: []*pb.Laptop{valid, laptop}}
+	res, err := server.BatchCreateLaptops(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, valid.GetId(), res.GetResults()[0].GetId())
+	require.Contains(t, res.GetResults()[1].GetError(), "cpu.max_ghz")
+}
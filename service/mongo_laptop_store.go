@@ -0,0 +1,565 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// MongoCollection is the subset of a MongoDB collection used by MongoLaptopStore.
+// The documents and filters are maps that the BSON encoder of the driver accepts,
+// so it can be implemented with a thin adapter over *mongo.Collection.
+type MongoCollection interface {
+	// InsertOne inserts the document, and returns ErrAlreadyExist if its _id already exists.
+	InsertOne(ctx context.Context, document map[string]interface{}) error
+	// InsertMany inserts the documents, and returns ErrAlreadyExist if one of their _id
+	// already exists, keeping none of them.
+	InsertMany(ctx context.Context, documents []map[string]interface{}) error
+	// ReplaceOne replaces the document matching the filter, and returns ErrNotFound if there is none.
+	ReplaceOne(ctx context.Context, filter map[string]interface{}, document map[string]interface{}) error
+	// DeleteOne deletes the document with the _id, and returns ErrNotFound if there is none.
+	DeleteOne(ctx context.Context, id string) error
+	// FindOne returns the document matching the filter, or nil if there is none.
+	FindOne(ctx context.Context, filter map[string]interface{}) (map[string]interface{}, error)
+	// Find calls found with every document matching the filter.
+	// If sortKey is set, it calls found with at most limit documents in ascending order of the key.
+	Find(
+		ctx context.Context,
+		filter map[string]interface{},
+		sortKey string,
+		limit int,
+		found func(document map[string]interface{}) error,
+	) error
+	// CountDocuments returns the number of documents matching the filter.
+	CountDocuments(ctx context.Context, filter map[string]interface{}) (int64, error)
+	// CreateIndex creates an ascending compound index on the keys if it doesn't exist.
+	CreateIndex(ctx context.Context, keys []string) error
+}
+
+// mongoSearchKey is the sub-document holding the computed fields used by Search.
+const mongoSearchKey = "search"
+
+// mongoPaths are the document paths of the filter fields that are not stored as is.
+var mongoPaths = map[string]string{
+	"ram":       mongoSearchKey + ".ram_bits",
+	"weight_kg": mongoSearchKey + ".weight_kg",
+}
+
+func mongoPath(field string) string {
+	if path, ok := mongoPaths[field]; ok {
+		return path
+	}
+	return field
+}
+
+// MongoLaptopStore stores laptops in a MongoDB collection. Each laptop is mapped to
+// a document with the proto field names, plus its tenant and the computed search fields.
+type MongoLaptopStore struct {
+	collection MongoCollection
+	tenant     string
+}
+
+// NewMongoLaptopStore returns a new MongoLaptopStore of the collection,
+// and creates the index used by Search on price and CPU cores.
+func NewMongoLaptopStore(ctx context.Context, collection MongoCollection) (*MongoLaptopStore, error) {
+	err := collection.CreateIndex(ctx, []string{"tenant", "price_usd", "cpu.number_cores"})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create index: %w", err)
+	}
+	return &MongoLaptopStore{collection: collection}, nil
+}
+
+// DialMongoLaptopStore connects to the MongoDB deployment at uri, and returns a new MongoLaptopStore
+// of the collection of the database. The store must be closed once unused.
+func DialMongoLaptopStore(ctx context.Context, uri string, database string, collection string) (*MongoLaptopStore, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to mongodb: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, fmt.Errorf("cannot connect to mongodb: %w", err)
+	}
+
+	store, err := NewMongoLaptopStore(ctx, NewMongoDriverCollection(client.Database(database).Collection(collection)))
+	if err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, err
+	}
+	return store, nil
+}
+
+// Close disconnects the client of the collection of the store if it has one,
+// e.g. if the store was dialed by DialMongoLaptopStore.
+func (store *MongoLaptopStore) Close() error {
+	if collection, ok := store.collection.(*mongoDriverCollection); ok {
+		return collection.collection.Database().Client().Disconnect(context.Background())
+	}
+	return nil
+}
+
+// mongoDriverCollection is the MongoCollection of a collection of the MongoDB driver.
+type mongoDriverCollection struct {
+	collection *mongo.Collection
+}
+
+// NewMongoDriverCollection returns the MongoCollection of a collection of the MongoDB driver,
+// e.g. to use a client configured by the caller.
+func NewMongoDriverCollection(collection *mongo.Collection) MongoCollection {
+	return &mongoDriverCollection{collection: collection}
+}
+
+// InsertOne inserts the document
+func (collection *mongoDriverCollection) InsertOne(ctx context.Context, document map[string]interface{}) error {
+	_, err := collection.collection.InsertOne(ctx, document)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyExist
+	}
+	return err
+}
+
+// InsertMany inserts the documents in order. As a transaction requires a replica set, the documents
+// inserted before an _id that already exists are deleted again, so that none of them is kept.
+func (collection *mongoDriverCollection) InsertMany(ctx context.Context, documents []map[string]interface{}) error {
+	values := make([]interface{}, len(documents))
+	for i, document := range documents {
+		values[i] = document
+	}
+
+	_, err := collection.collection.InsertMany(ctx, values, options.InsertMany().SetOrdered(true))
+	if err == nil {
+		return nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	// The insertion stopped at the first write error, so the documents before it were inserted.
+	var inserted []interface{}
+	var exception mongo.BulkWriteException
+	if errors.As(err, &exception) && len(exception.WriteErrors) > 0 {
+		for _, document := range documents[:exception.WriteErrors[0].Index] {
+			inserted = append(inserted, document["_id"])
+		}
+	}
+	if len(inserted) > 0 {
+		_, err := collection.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": inserted}})
+		if err != nil {
+			return fmt.Errorf("cannot delete the inserted documents of a failed batch: %w", err)
+		}
+	}
+	return ErrAlreadyExist
+}
+
+// ReplaceOne replaces the document matching the filter
+func (collection *mongoDriverCollection) ReplaceOne(
+	ctx context.Context,
+	filter map[string]interface{},
+	document map[string]interface{},
+) error {
+	result, err := collection.collection.ReplaceOne(ctx, filter, document)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteOne deletes the document with the _id
+func (collection *mongoDriverCollection) DeleteOne(ctx context.Context, id string) error {
+	result, err := collection.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FindOne returns the document matching the filter
+func (collection *mongoDriverCollection) FindOne(
+	ctx context.Context,
+	filter map[string]interface{},
+) (map[string]interface{}, error) {
+	raw, err := collection.collection.FindOne(ctx, filter).DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mongoDocument(raw)
+}
+
+// Find calls found with every document matching the filter
+func (collection *mongoDriverCollection) Find(
+	ctx context.Context,
+	filter map[string]interface{},
+	sortKey string,
+	limit int,
+	found func(document map[string]interface{}) error,
+) error {
+	findOptions := options.Find()
+	if sortKey != "" {
+		findOptions.SetSort(bson.D{{Key: sortKey, Value: 1}}).SetLimit(int64(limit))
+	}
+
+	cursor, err := collection.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		document, err := mongoDocument(cursor.Current)
+		if err != nil {
+			return err
+		}
+		if err := found(document); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// CountDocuments returns the number of documents matching the filter
+func (collection *mongoDriverCollection) CountDocuments(ctx context.Context, filter map[string]interface{}) (int64, error) {
+	return collection.collection.CountDocuments(ctx, filter)
+}
+
+// CreateIndex creates an ascending compound index on the keys
+func (collection *mongoDriverCollection) CreateIndex(ctx context.Context, keys []string) error {
+	index := make(bson.D, len(keys))
+	for i, key := range keys {
+		index[i] = bson.E{Key: key, Value: 1}
+	}
+	_, err := collection.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: index})
+	return err
+}
+
+// mongoDocument decodes the BSON document into nested maps, through its relaxed extended JSON
+// so that the embedded documents are maps too, as the documents of MongoLaptopStore expect.
+func mongoDocument(raw bson.Raw) (map[string]interface{}, error) {
+	data, err := bson.MarshalExtJSON(raw, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode document: %w", err)
+	}
+
+	document := make(map[string]interface{})
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode document: %w", err)
+	}
+	return document, nil
+}
+
+// ForTenant returns the laptop store of the tenant, sharing the same collection.
+func (store *MongoLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &MongoLaptopStore{collection: store.collection, tenant: tenant}
+}
+
+// Save saves the laptop to the store
+func (store *MongoLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	document, err := store.document(laptop)
+	if err != nil {
+		return err
+	}
+	return store.collection.InsertOne(ctx, document)
+}
+
+// SaveBatch saves the laptops to the store in a single transaction
+func (store *MongoLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	err := checkBatchIDs(laptops)
+	if err != nil {
+		return err
+	}
+
+	documents := make([]map[string]interface{}, len(laptops))
+	for i, laptop := range laptops {
+		documents[i], err = store.document(laptop)
+		if err != nil {
+			return err
+		}
+	}
+	return store.collection.InsertMany(ctx, documents)
+}
+
+// Update replaces the laptop with the same ID and version in the store
+func (store *MongoLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	next := nextVersion(laptop)
+	document, err := store.document(next)
+	if err != nil {
+		return err
+	}
+
+	// The JSON mapping of protobuf encodes the 64-bit version as a string,
+	// and the documents saved before the laptops had versions have none.
+	versions := []interface{}{strconv.FormatUint(laptop.GetVersion(), 10)}
+	if laptop.GetVersion() == 0 {
+		versions = append(versions, nil)
+	}
+	filter := map[string]interface{}{
+		"_id":     document["_id"],
+		"version": map[string]interface{}{"$in": versions},
+	}
+
+	err = store.collection.ReplaceOne(ctx, filter, document)
+	if errors.Is(err, ErrNotFound) {
+		stored, err := store.Find(ctx, laptop.GetId())
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	laptop.Version = next.Version
+	return nil
+}
+
+// Delete deletes the laptop with the ID from the store
+func (store *MongoLaptopStore) Delete(ctx context.Context, id string) error {
+	return store.collection.DeleteOne(ctx, store.tenant+"/"+id)
+}
+
+// Find finds a laptop by ID
+func (store *MongoLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	document, err := store.collection.FindOne(ctx, map[string]interface{}{
+		"tenant": store.tenant,
+		"id":     id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot find laptop: %w", err)
+	}
+	if document == nil {
+		return nil, nil
+	}
+	return laptopFromDocument(document)
+}
+
+// List returns a page of laptops in ID order
+func (store *MongoLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+	after, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := map[string]interface{}{
+		"tenant": store.tenant,
+		"id":     map[string]interface{}{"$gt": after},
+	}
+	var laptops []*pb.Laptop
+	err = store.collection.Find(ctx, query, "id", pageSize+1, func(document map[string]interface{}) error {
+		laptop, err := laptopFromDocument(document)
+		if err != nil {
+			return err
+		}
+		laptops = append(laptops, laptop)
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot find laptops: %w", err)
+	}
+
+	laptops, nextPageToken := nextPage(laptops, pageSize)
+	return laptops, nextPageToken, nil
+}
+
+// Count returns the number of laptops matching the filter
+func (store *MongoLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	if filter.GetText() != "" {
+		return countBySearch(ctx, store, filter)
+	}
+
+	query, err := FilterToMongo(filter)
+	if err != nil {
+		return 0, err
+	}
+	query = map[string]interface{}{"$and": []interface{}{map[string]interface{}{"tenant": store.tenant}, query}}
+
+	count, err := store.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("cannot count laptops: %w", err)
+	}
+	return count, nil
+}
+
+// Search searches for laptops with filter, returns one by one via the found function.
+// The laptops are sorted after they are all found, if the filter has a sort.
+func (store *MongoLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return searchSorted(ctx, store.search, filter, found)
+}
+
+// search searches for laptops with filter in the order of the store.
+func (store *MongoLaptopStore) search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	query, err := FilterToMongo(filter)
+	if err != nil {
+		return err
+	}
+	query = map[string]interface{}{"$and": []interface{}{map[string]interface{}{"tenant": store.tenant}, query}}
+
+	return store.collection.Find(ctx, query, "", 0, func(document map[string]interface{}) error {
+		laptop, err := laptopFromDocument(document)
+		if err != nil {
+			return err
+		}
+		// The text is matched here, as a text index would have to be created by the operator.
+		if !matchesText(filter.GetText(), laptop) {
+			return nil
+		}
+		return found(laptop)
+	})
+}
+
+// document maps the laptop to a document with the proto field names.
+func (store *MongoLaptopStore) document(laptop *pb.Laptop) (map[string]interface{}, error) {
+	data, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	document := make(map[string]interface{})
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode laptop document: %w", err)
+	}
+
+	document["_id"] = store.tenant + "/" + laptop.GetId()
+	document["tenant"] = store.tenant
+	document[mongoSearchKey] = map[string]interface{}{
+		"ram_bits":  int64(toBit(laptop.GetRam())),
+		"weight_kg": weightKg(laptop),
+	}
+	return document, nil
+}
+
+func laptopFromDocument(document map[string]interface{}) (*pb.Laptop, error) {
+	fields := make(map[string]interface{}, len(document))
+	for key, value := range document {
+		switch key {
+		case "_id", "tenant", mongoSearchKey:
+		default:
+			fields[key] = value
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode laptop document: %w", err)
+	}
+
+	laptop := &pb.Laptop{}
+	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal laptop: %w", err)
+	}
+	return laptop, nil
+}
+
+var mongoOperators = map[pb.Condition_Operator]string{
+	pb.Condition_EQ: "$eq",
+	pb.Condition_NE: "$ne",
+	pb.Condition_LT: "$lt",
+	pb.Condition_LE: "$lte",
+	pb.Condition_GT: "$gt",
+	pb.Condition_GE: "$gte",
+}
+
+// FilterToMongo translates the filter into a MongoDB query document
+// on the documents of MongoLaptopStore.
+func FilterToMongo(filter *pb.Filter) (map[string]interface{}, error) {
+	expression := filter.GetExpression()
+	err := ValidateExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []interface{}
+	if expression == nil || filter.GetMaxPriceUsd() != 0 {
+		conditions = append(conditions, mongoCondition("price_usd", "$lte", filter.GetMaxPriceUsd()))
+	}
+	if filter.GetMinCpuCores() != 0 {
+		conditions = append(conditions, mongoCondition("cpu.number_cores", "$gte", filter.GetMinCpuCores()))
+	}
+	if filter.GetMinCpuGhz() != 0 {
+		conditions = append(conditions, mongoCondition("cpu.min_ghz", "$gte", filter.GetMinCpuGhz()))
+	}
+	if filter.GetMinRam() != nil {
+		conditions = append(conditions, mongoCondition(mongoPath("ram"), "$gte", int64(toBit(filter.GetMinRam()))))
+	}
+	if expression != nil {
+		conditions = append(conditions, expressionToMongo(expression))
+	}
+
+	if len(conditions) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	return map[string]interface{}{"$and": conditions}, nil
+}
+
+func mongoCondition(path string, operator string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{path: map[string]interface{}{operator: value}}
+}
+
+func expressionToMongo(expression *pb.Expression) map[string]interface{} {
+	switch node := expression.GetNode().(type) {
+	case *pb.Expression_Condition:
+		condition := node.Condition
+		value := conditionValue(condition)
+		if bits, ok := value.(uint64); ok {
+			value = int64(bits)
+		}
+		return mongoCondition(mongoPath(condition.GetField()), mongoOperators[condition.GetOperator()], value)
+	case *pb.Expression_And:
+		return mongoList("$and", node.And.GetExpressions())
+	case *pb.Expression_Or:
+		if len(node.Or.GetExpressions()) == 0 {
+			// $or requires at least one expression, and an empty OR matches nothing.
+			return map[string]interface{}{"_id": map[string]interface{}{"$exists": false}}
+		}
+		return mongoList("$or", node.Or.GetExpressions())
+	case *pb.Expression_Not:
+		return map[string]interface{}{"$nor": []interface{}{expressionToMongo(node.Not)}}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func mongoList(operator string, expressions []*pb.Expression) map[string]interface{} {
+	if len(expressions) == 0 {
+		return map[string]interface{}{}
+	}
+
+	list := make([]interface{}, len(expressions))
+	for i, expression := range expressions {
+		list[i] = expressionToMongo(expression)
+	}
+	return map[string]interface{}{operator: list}
+}
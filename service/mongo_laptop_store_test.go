@@ -0,0 +1,255 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeMongoCollection keeps the documents in memory and only supports
+// the equality filters of FindOne, the version filters of ReplaceOne and the filters of List.
+type fakeMongoCollection struct {
+	documents map[interface{}]map[string]interface{}
+	indexes   [][]string
+}
+
+func (collection *fakeMongoCollection) InsertOne(ctx context.Context, document map[string]interface{}) error {
+	if collection.documents[document["_id"]] != nil {
+		return service.ErrAlreadyExist
+	}
+	collection.documents[document["_id"]] = document
+	return nil
+}
+
+func (collection *fakeMongoCollection) InsertMany(ctx context.Context, documents []map[string]interface{}) error {
+	for _, document := range documents {
+		if collection.documents[document["_id"]] != nil {
+			return service.ErrAlreadyExist
+		}
+	}
+	for _, document := range documents {
+		collection.documents[document["_id"]] = document
+	}
+	return nil
+}
+
+func (collection *fakeMongoCollection) ReplaceOne(
+	ctx context.Context,
+	filter map[string]interface{},
+	document map[string]interface{},
+) error {
+	stored := collection.documents[filter["_id"]]
+	if stored == nil {
+		return service.ErrNotFound
+	}
+	for _, version := range filter["version"].(map[string]interface{})["$in"].([]interface{}) {
+		if stored["version"] == version {
+			collection.documents[filter["_id"]] = document
+			return nil
+		}
+	}
+	return service.ErrNotFound
+}
+
+func (collection *fakeMongoCollection) DeleteOne(ctx context.Context, id string) error {
+	if collection.documents[id] == nil {
+		return service.ErrNotFound
+	}
+	delete(collection.documents, id)
+	return nil
+}
+
+func (collection *fakeMongoCollection) FindOne(
+	ctx context.Context,
+	filter map[string]interface{},
+) (map[string]interface{}, error) {
+	for _, document := range collection.documents {
+		matches := true
+		for key, value := range filter {
+			matches = matches && document[key] == value
+		}
+		if matches {
+			return document, nil
+		}
+	}
+	return nil, nil
+}
+
+func (collection *fakeMongoCollection) Find(
+	ctx context.Context,
+	filter map[string]interface{},
+	sortKey string,
+	limit int,
+	found func(document map[string]interface{}) error,
+) error {
+	if sortKey == "" {
+		for _, document := range collection.documents {
+			if err := found(document); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Sorted finds come from List, whose filter is a tenant and a minimum ID.
+	var documents []map[string]interface{}
+	for _, document := range collection.documents {
+		after := filter["id"].(map[string]interface{})["$gt"].(string)
+		if document["tenant"] == filter["tenant"] && document["id"].(string) > after {
+			documents = append(documents, document)
+		}
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i][sortKey].(string) < documents[j][sortKey].(string)
+	})
+	if len(documents) > limit {
+		documents = documents[:limit]
+	}
+
+	for _, document := range documents {
+		if err := found(document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (collection *fakeMongoCollection) CountDocuments(ctx context.Context, filter map[string]interface{}) (int64, error) {
+	return int64(len(collection.documents)), nil
+}
+
+func (collection *fakeMongoCollection) CreateIndex(ctx context.Context, keys []string) error {
+	collection.indexes = append(collection.indexes, keys)
+	return nil
+}
+
+func TestMongoLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	collection := &fakeMongoCollection{documents: make(map[interface{}]map[string]interface{})}
+	store, err := service.NewMongoLaptopStore(context.Background(), collection)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"tenant", "price_usd", "cpu.number_cores"}}, collection.indexes)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	require.ErrorIs(t, store.Save(context.Background(), laptop), service.ErrAlreadyExist)
+	require.NoError(t, store.Update(context.Background(), laptop))
+	require.Equal(t, uint64(1), laptop.GetVersion())
+	stale := sample.NewLaptop()
+	stale.Id = laptop.GetId()
+	require.ErrorIs(t, store.Update(context.Background(), stale), service.ErrVersionConflict)
+	require.ErrorIs(t, store.Update(context.Background(), sample.NewLaptop()), service.ErrNotFound)
+	require.ErrorIs(t, store.Delete(context.Background(), "unknown"), service.ErrNotFound)
+	require.Equal(t, []string{laptop.GetId()}, listAll(t, store, 1))
+
+	found, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, found))
+
+	found, err = store.ForTenant("other").Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+}
+
+func TestMongoDriverCollection(t *testing.T) {
+	t.Parallel()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("store", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		store, err := service.NewMongoLaptopStore(context.Background(), service.NewMongoDriverCollection(mt.Coll))
+		require.NoError(t, err)
+
+		laptop := sample.NewLaptop()
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		require.NoError(t, store.Save(context.Background(), laptop))
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}))
+		require.ErrorIs(t, store.Save(context.Background(), laptop), service.ErrAlreadyExist)
+
+		// The stored document has embedded documents, which are decoded as maps.
+		data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(laptop)
+		require.NoError(t, err)
+		var document bson.D
+		require.NoError(t, bson.UnmarshalExtJSON(data, false, &document))
+		document = append(document, bson.E{Key: "_id", Value: "/" + laptop.GetId()}, bson.E{Key: "tenant", Value: ""})
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.Coll.Database().Name()+"."+mt.Coll.Name(), mtest.FirstBatch, document))
+		found, err := store.Find(context.Background(), laptop.GetId())
+		require.NoError(t, err)
+		require.True(t, proto.Equal(laptop, found))
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.Coll.Database().Name()+"."+mt.Coll.Name(), mtest.FirstBatch))
+		found, err = store.Find(context.Background(), "unknown")
+		require.NoError(t, err)
+		require.Nil(t, found)
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}})
+		require.ErrorIs(t, store.Delete(context.Background(), "unknown"), service.ErrNotFound)
+	})
+
+	mt.Run("failed batch", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		store, err := service.NewMongoLaptopStore(context.Background(), service.NewMongoDriverCollection(mt.Coll))
+		require.NoError(t, err)
+
+		laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()}
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 2, Code: 11000, Message: "duplicate key"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}},
+		)
+		mt.ClearEvents()
+		require.ErrorIs(t, store.SaveBatch(context.Background(), laptops), service.ErrAlreadyExist)
+
+		// The laptops inserted before the duplicate are deleted.
+		started := mt.GetStartedEvent()
+		require.Equal(t, "insert", started.CommandName)
+		started = mt.GetStartedEvent()
+		require.Equal(t, "delete", started.CommandName)
+		ids, err := started.Command.Lookup("deletes").Array().Index(0).Value().Document().
+			Lookup("q", "_id", "$in").Array().Values()
+		require.NoError(t, err)
+		var deleted []interface{}
+		for _, id := range ids {
+			deleted = append(deleted, id.StringValue())
+		}
+		require.Equal(t, []interface{}{"/" + laptops[0].GetId(), "/" + laptops[1].GetId()}, deleted)
+	})
+
+	_, err := service.DialMongoLaptopStore(context.Background(), "mongodb://localhost:1/?serverSelectionTimeoutMS=100", "catalog", "laptops")
+	require.Error(t, err)
+}
+
+func TestFilterToMongo(t *testing.T) {
+	t.Parallel()
+
+	filter := &pb.Filter{
+		MinRam: &pb.Memory{Value: 1, Unit: pb.Memory_KILOBYTE},
+		Expression: or(
+			condition("brand", pb.Condition_EQ, "Apple"),
+			not(condition("cpu.number_cores", pb.Condition_LT, 8.0)),
+		),
+	}
+
+	query, err := service.FilterToMongo(filter)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"$and": []interface{}{
+		map[string]interface{}{"search.ram_bits": map[string]interface{}{"$gte": int64(8192)}},
+		map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"brand": map[string]interface{}{"$eq": "Apple"}},
+			map[string]interface{}{"$nor": []interface{}{
+				map[string]interface{}{"cpu.number_cores": map[string]interface{}{"$lt": 8.0}},
+			}},
+		}},
+	}}, query)
+}
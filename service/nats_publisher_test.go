@@ -0,0 +1,154 @@
+package service_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// natsMessage is a message received by the fake NATS server.
+type natsMessage struct {
+	subject string
+	payload []byte
+}
+
+// startNATSServer starts a fake NATS server sending the messages it receives to the channel,
+// whose first connection is closed after its first message if dropFirst is set.
+func startNATSServer(t *testing.T, messages chan<- natsMessage, dropFirst bool) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for first := true; ; first = false {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveNATS(conn, messages, first && dropFirst)
+		}
+	}()
+	return "nats://user:password@" + listener.Addr().String()
+}
+
+func serveNATS(conn net.Conn, messages chan<- natsMessage, drop bool) {
+	defer conn.Close()
+
+	fmt.Fprint(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+	reader := bufio.NewReader(conn)
+	published := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "CONNECT":
+			if !strings.Contains(line, `"user":"user"`) || !strings.Contains(line, `"pass":"password"`) {
+				fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+			if drop && published {
+				return
+			}
+		case "PUB":
+			var size int
+			fmt.Sscan(fields[2], &size)
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			messages <- natsMessage{subject: fields[1], payload: payload[:size]}
+			published = true
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	t.Parallel()
+
+	messages := make(chan natsMessage, 10)
+	publisher, err := service.NewNATSPublisher(service.NATSConfig{URL: startNATSServer(t, messages, true)})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	laptop := sample.NewLaptop()
+	err = publisher.Publish(ctx, service.LaptopEvent{Type: service.LaptopCreated, Tenant: "acme", Laptop: laptop})
+	require.NoError(t, err)
+
+	message := <-messages
+	require.Equal(t, "laptops.acme.created", message.subject)
+	var event struct {
+		Type   string `json:"type"`
+		Tenant string `json:"tenant"`
+		Laptop struct {
+			ID string `json:"id"`
+		} `json:"laptop"`
+	}
+	require.NoError(t, json.Unmarshal(message.payload, &event))
+	require.Equal(t, "created", event.Type)
+	require.Equal(t, "acme", event.Tenant)
+	require.Equal(t, laptop.GetId(), event.Laptop.ID)
+
+	err = publisher.Publish(ctx, service.LaptopEvent{Type: service.LaptopDeleted, Laptop: laptop})
+	require.NoError(t, err, "the message is sent again on a new connection")
+	require.Equal(t, "laptops.default.deleted", (<-messages).subject)
+
+	// The tenants are escaped so that no two of them share a subject.
+	subjects := map[string]string{"laptops.default.created": ""}
+	for _, tenant := range []string{"a.b", "a_b", "a%2Eb", "a*", "a>", "default"} {
+		err = publisher.Publish(ctx, service.LaptopEvent{Type: service.LaptopCreated, Tenant: tenant, Laptop: laptop})
+		require.NoError(t, err)
+		subject := (<-messages).subject
+		require.Len(t, strings.Split(subject, "."), 3, "the tenant of %s is a single token", subject)
+		require.NotContains(t, subjects, subject, "the tenants %q and %q share a subject", tenant, subjects[subject])
+		subjects[subject] = tenant
+	}
+	require.Equal(t, "a.b", subjects["laptops.a%2Eb.created"])
+	require.Equal(t, "a_b", subjects["laptops.a_b.created"])
+
+	_, err = service.NewNATSPublisher(service.NATSConfig{URL: "http://localhost:4222"})
+	require.Error(t, err)
+}
+
+func TestPublishLaptopEvents(t *testing.T) {
+	t.Parallel()
+
+	messages := make(chan natsMessage, 10)
+	publisher, err := service.NewNATSPublisher(service.NATSConfig{URL: startNATSServer(t, messages, false), Subject: "catalog"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	store := service.NewWatchLaptopStore(service.NewInMemoryLaptopStore())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.PublishLaptopEvents(ctx, store, publisher, nil)
+
+	// The publisher watches the store in the background, so the laptops are saved until it does.
+	tenantStore := store.ForTenant("acme")
+	require.Eventually(t, func() bool {
+		require.NoError(t, tenantStore.Save(context.Background(), sample.NewLaptop()))
+		select {
+		case message := <-messages:
+			require.Equal(t, "catalog.acme.created", message.subject)
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 20*time.Millisecond)
+}
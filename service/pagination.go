@@ -0,0 +1,42 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+)
+
+// ErrInvalidPageToken is returned by List when the page token wasn't returned by a List of the same store.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+func checkPageSize(pageSize int) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("page size must be positive: %d", pageSize)
+	}
+	return nil
+}
+
+// encodePageToken returns the page token of the page that starts after the key in the order of the store.
+func encodePageToken(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodePageToken returns the key after which the page of the token starts, empty for the first page.
+func decodePageToken(token string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || (token != "" && len(key) == 0) {
+		return "", ErrInvalidPageToken
+	}
+	return string(key), nil
+}
+
+// nextPage returns the first pageSize laptops, and the token of the next page if there are more
+// of them. The laptops must be in ID order, and at most pageSize+1 are needed.
+func nextPage(laptops []*pb.Laptop, pageSize int) ([]*pb.Laptop, string) {
+	if len(laptops) <= pageSize {
+		return laptops, ""
+	}
+	laptops = laptops[:pageSize]
+	return laptops, encodePageToken(laptops[pageSize-1].GetId())
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// maxRateLimitBuckets is the number of token buckets of the callers above which
+// the full buckets, which are the same as new ones, are removed.
+const maxRateLimitBuckets = 10000
+
+// RateLimit allows Rate calls per second on average, with bursts of up to Burst calls.
+// A zero rate is unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimits parses the rate limits of the methods from a comma-separated list of
+// method=rate or method=rate:burst, like "/grpc_app.proto.LaptopService/CreateLaptop=5:10".
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		method, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q, must be method=rate[:burst]", item)
+		}
+		rateValue, burstValue, hasBurst := strings.Cut(value, ":")
+
+		rate, err := strconv.ParseFloat(rateValue, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate of %s: %q", method, rateValue)
+		}
+		limit := RateLimit{Rate: rate, Burst: 1}
+		if hasBurst {
+			limit.Burst, err = strconv.Atoi(burstValue)
+			if err != nil || limit.Burst < 1 {
+				return nil, fmt.Errorf("invalid burst of %s: %q", method, burstValue)
+			}
+		}
+		limits[method] = limit
+	}
+	return limits, nil
+}
+
+// RateLimiterConfig contains the rate limits of a RateLimiter.
+type RateLimiterConfig struct {
+	// Global limits the calls of all the callers together.
+	Global RateLimit
+	// PerCaller limits the calls of each caller, which is the authenticated user or API key,
+	// or the IP address of the anonymous callers.
+	PerCaller RateLimit
+	// Methods are the limits of each caller for the methods, by full method name,
+	// in place of PerCaller.
+	Methods map[string]RateLimit
+}
+
+// RateLimiter is a server interceptor that rejects the calls above the rate limits with
+// ResourceExhausted. It must run after the auth interceptor to tell the authenticated callers apart.
+type RateLimiter struct {
+	mutex   sync.Mutex
+	config  RateLimiterConfig
+	global  *tokenBucket
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter returns a new rate limiter with the limits of the config.
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	limiter := &RateLimiter{}
+	limiter.SetConfig(config)
+	return limiter
+}
+
+// SetConfig replaces the limits of the rate limiter, e.g. when the config of the server is reloaded.
+// The token buckets start full again with the new limits.
+func (limiter *RateLimiter) SetConfig(config RateLimiterConfig) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.config = config
+	limiter.global = nil
+	if config.Global.Rate > 0 {
+		limiter.global = newTokenBucket(config.Global.Rate, config.Global.Burst)
+	}
+	limiter.buckets = make(map[string]*tokenBucket)
+}
+
+// Unary returns a server interceptor to rate limit unary RPC
+func (limiter *RateLimiter) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		err := limiter.allow(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns a server interceptor to rate limit stream RPC, whose messages are not limited.
+func (limiter *RateLimiter) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		err := limiter.allow(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+// allow takes a token of the caller for the method, then a global one.
+func (limiter *RateLimiter) allow(ctx context.Context, method string) error {
+	limiter.mutex.Lock()
+	limit, ok := limiter.config.Methods[method]
+	if !ok {
+		limit = limiter.config.PerCaller
+	}
+	global := limiter.global
+	limiter.mutex.Unlock()
+
+	if limit.Rate > 0 {
+		caller := rateLimitCaller(ctx)
+		if !limiter.bucket(caller+" "+method, limit).allow() {
+			return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for %s", method)
+		}
+	}
+
+	if global != nil && !global.allow() {
+		return status.Errorf(codes.ResourceExhausted, "server rate limit exceeded")
+	}
+	return nil
+}
+
+// bucket returns the token bucket of the key, created the first time it's used.
+func (limiter *RateLimiter) bucket(key string, limit RateLimit) *tokenBucket {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	bucket := limiter.buckets[key]
+	if bucket != nil {
+		return bucket
+	}
+
+	if len(limiter.buckets) >= maxRateLimitBuckets {
+		for key, bucket := range limiter.buckets {
+			if bucket.full() {
+				delete(limiter.buckets, key)
+			}
+		}
+	}
+	bucket = newTokenBucket(limit.Rate, limit.Burst)
+	limiter.buckets[key] = bucket
+	return bucket
+}
+
+// rateLimitCaller returns the key of the caller of the call, its username if it's authenticated,
+// or its IP address otherwise.
+func rateLimitCaller(ctx context.Context) string {
+	if claims := ClaimsFromContext(ctx); claims != nil {
+		return "user:" + claims.Username
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "peer:"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "peer:" + host
+}
+
+// tokenBucket allows rate calls per second on average, with bursts of up to burst calls.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a new token bucket, full.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// allow takes a token if one is available, and returns whether it did.
+func (bucket *tokenBucket) allow() bool {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+
+	bucket.refill(time.Now())
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// full returns whether the bucket has all its tokens.
+func (bucket *tokenBucket) full() bool {
+	bucket.mutex.Lock()
+	defer bucket.mutex.Unlock()
+
+	bucket.refill(time.Now())
+	return bucket.tokens >= bucket.burst
+}
+
+func (bucket *tokenBucket) refill(now time.Time) {
+	bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+	if bucket.tokens > bucket.burst {
+		bucket.tokens = bucket.burst
+	}
+	bucket.last = now
+}
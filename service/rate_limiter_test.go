@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/service"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimiterUnary(t *testing.T) {
+	t.Parallel()
+
+	const createMethod = "/grpc_app.proto.LaptopService/CreateLaptop"
+	const searchMethod = "/grpc_app.proto.LaptopService/SearchLaptop"
+	unary := service.NewRateLimiter(service.RateLimiterConfig{
+		Global:    service.RateLimit{Rate: 0.001, Burst: 5},
+		PerCaller: service.RateLimit{Rate: 0.001, Burst: 2},
+		Methods:   map[string]service.RateLimit{createMethod: {Rate: 0.001, Burst: 1}},
+	}).Unary()
+
+	call := func(ctx context.Context, method string) error {
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	peerContext := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}})
+	}
+	alice := service.ContextWithClaims(peerContext("10.0.0.1"), &service.UserClaims{Username: "alice"})
+
+	require.NoError(t, call(alice, createMethod))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call(alice, createMethod)))
+
+	require.NoError(t, call(alice, searchMethod))
+	require.NoError(t, call(alice, searchMethod))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call(alice, searchMethod)))
+
+	require.NoError(t, call(peerContext("10.0.0.1"), searchMethod))
+	require.NoError(t, call(peerContext("10.0.0.2"), searchMethod))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call(peerContext("10.0.0.3"), searchMethod)))
+}
+
+func TestRateLimiterSetConfig(t *testing.T) {
+	t.Parallel()
+
+	const method = "/grpc_app.proto.LaptopService/SearchLaptop"
+	limiter := service.NewRateLimiter(service.RateLimiterConfig{})
+	unary := limiter.Unary()
+	call := func() error {
+		_, err := unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, call(), "the calls are unlimited")
+	}
+
+	limiter.SetConfig(service.RateLimiterConfig{Global: service.RateLimit{Rate: 0.001, Burst: 1}})
+	require.NoError(t, call())
+	require.Equal(t, codes.ResourceExhausted, status.Code(call()))
+
+	limiter.SetConfig(service.RateLimiterConfig{})
+	require.NoError(t, call(), "the limits are removed")
+}
+
+func TestParseRateLimits(t *testing.T) {
+	t.Parallel()
+
+	limits, err := service.ParseRateLimits("/a.S/Create=5:10, /a.S/Search=0.5")
+	require.NoError(t, err)
+	require.Equal(t, map[string]service.RateLimit{
+		"/a.S/Create": {Rate: 5, Burst: 10},
+		"/a.S/Search": {Rate: 0.5, Burst: 1},
+	}, limits)
+
+	_, err = service.ParseRateLimits("/a.S/Create")
+	require.Error(t, err)
+	_, err = service.ParseRateLimits("/a.S/Create=fast")
+	require.Error(t, err)
+	_, err = service.ParseRateLimits("/a.S/Create=5:0")
+	require.Error(t, err)
+}
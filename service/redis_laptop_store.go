@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/protobuf/proto"
+)
+
+// RedisClient is the subset of a Redis client used by RedisLaptopStore.
+// It can be implemented with a thin adapter over the client of a Redis library.
+type RedisClient interface {
+	// SetNX sets the key to the value if it doesn't exist yet, and returns whether it was set.
+	// The key expires after ttl if it is positive.
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// CompareAndSwap sets the key to the value if its current value is old, and returns whether it was set.
+	// The key expires after ttl if it is positive. It can be implemented with RedisCompareAndSwapScript.
+	CompareAndSwap(ctx context.Context, key string, old []byte, value []byte, ttl time.Duration) (bool, error)
+	// Del deletes the key, and returns whether it existed.
+	Del(ctx context.Context, key string) (bool, error)
+	// Get returns the value of the key, or nil if it doesn't exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Scan calls found with every key matching the glob pattern.
+	Scan(ctx context.Context, pattern string, found func(key string) error) error
+}
+
+// RedisCompareAndSwapScript is a Lua script implementing RedisClient.CompareAndSwap with EVAL,
+// given the key, then the old value, the value and the ttl in milliseconds (0 for none) as arguments.
+const RedisCompareAndSwapScript = `if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1`
+
+// redisKeyPrefix is the prefix of the keys of the laptops.
+const redisKeyPrefix = "laptop:"
+
+// RedisLaptopStore stores serialized laptops in Redis, optionally expiring them,
+// e.g. to use the service as an ephemeral catalog cache.
+type RedisLaptopStore struct {
+	client RedisClient
+	ttl    time.Duration
+	tenant string
+}
+
+// NewRedisLaptopStore returns a new RedisLaptopStore. The laptops expire after ttl
+// if it is positive, and are kept forever otherwise.
+func NewRedisLaptopStore(client RedisClient, ttl time.Duration) *RedisLaptopStore {
+	return &RedisLaptopStore{client: client, ttl: ttl}
+}
+
+// DialRedisLaptopStore returns a new RedisLaptopStore of the Redis server at addr, checking that it can be reached.
+// The laptops expire after ttl if it is positive. The store must be closed once unused.
+func DialRedisLaptopStore(ctx context.Context, addr string, ttl time.Duration) (*RedisLaptopStore, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("cannot connect to redis: %w", err)
+	}
+	return NewRedisLaptopStore(&goRedisClient{client: client}, ttl), nil
+}
+
+// Close closes the client of the store if it can be closed, e.g. if it was dialed by DialRedisLaptopStore.
+func (store *RedisLaptopStore) Close() error {
+	if closer, ok := store.client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// goRedisClient is the RedisClient of a go-redis client.
+type goRedisClient struct {
+	client *redis.Client
+}
+
+// goRedisCompareAndSwap runs RedisCompareAndSwapScript with EVALSHA, loading it first if needed.
+var goRedisCompareAndSwap = redis.NewScript(RedisCompareAndSwapScript)
+
+func (client *goRedisClient) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return client.client.SetNX(ctx, key, value, positiveTTL(ttl)).Result()
+}
+
+func (client *goRedisClient) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	old []byte,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	swapped, err := goRedisCompareAndSwap.Run(ctx, client.client, []string{key}, old, value, positiveTTL(ttl).Milliseconds()).Int()
+	return swapped == 1, err
+}
+
+func (client *goRedisClient) Del(ctx context.Context, key string) (bool, error) {
+	deleted, err := client.client.Del(ctx, key).Result()
+	return deleted > 0, err
+}
+
+func (client *goRedisClient) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := client.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (client *goRedisClient) Scan(ctx context.Context, pattern string, found func(key string) error) error {
+	iterator := client.client.Scan(ctx, 0, pattern, 0).Iterator()
+	for iterator.Next(ctx) {
+		if err := found(iterator.Val()); err != nil {
+			return err
+		}
+	}
+	return iterator.Err()
+}
+
+func (client *goRedisClient) Close() error {
+	return client.client.Close()
+}
+
+// positiveTTL returns the ttl if it is positive, and 0, meaning no expiration, otherwise.
+func positiveTTL(ttl time.Duration) time.Duration {
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// ForTenant returns the laptop store of the tenant, sharing the same Redis database.
+func (store *RedisLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &RedisLaptopStore{client: store.client, ttl: store.ttl, tenant: tenant}
+}
+
+func (store *RedisLaptopStore) key(id string) string {
+	return redisKeyPrefix + store.tenant + ":" + id
+}
+
+// Save saves the laptop to the store
+func (store *RedisLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	ok, err := store.client.SetNX(ctx, store.key(laptop.GetId()), data, store.ttl)
+	if err != nil {
+		return fmt.Errorf("cannot set laptop: %w", err)
+	}
+	if !ok {
+		return ErrAlreadyExist
+	}
+
+	return nil
+}
+
+// SaveBatch saves the laptops to the store, deleting the saved ones if one of them can't be saved.
+// As Redis can't make a set of SETNX atomic, the first laptops are visible before the batch fails.
+func (store *RedisLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	return saveEach(ctx, store, laptops)
+}
+
+// Update replaces the laptop with the same ID and version in the store, and restarts its expiration
+func (store *RedisLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	key := store.key(laptop.GetId())
+
+	old, err := store.client.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("cannot get laptop: %w", err)
+	}
+	stored, err := unmarshalLaptopIfAny(old)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(stored, laptop); err != nil {
+		return err
+	}
+
+	next := nextVersion(laptop)
+	data, err := proto.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	// The swap fails if the laptop was updated or deleted since it was read.
+	ok, err := store.client.CompareAndSwap(ctx, key, old, data, store.ttl)
+	if err != nil {
+		return fmt.Errorf("cannot set laptop: %w", err)
+	}
+	if !ok {
+		return ErrVersionConflict
+	}
+
+	laptop.Version = next.Version
+	return nil
+}
+
+// Delete deletes the laptop with the ID from the store
+func (store *RedisLaptopStore) Delete(ctx context.Context, id string) error {
+	ok, err := store.client.Del(ctx, store.key(id))
+	if err != nil {
+		return fmt.Errorf("cannot delete laptop: %w", err)
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Find finds a laptop by ID
+func (store *RedisLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	return store.get(ctx, store.key(id))
+}
+
+func (store *RedisLaptopStore) get(ctx context.Context, key string) (*pb.Laptop, error) {
+	data, err := store.client.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get laptop: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return unmarshalLaptop(data)
+}
+
+// List returns a page of laptops in ID order. Redis can't sort the keys,
+// so every key of the tenant is scanned for each page.
+func (store *RedisLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+	after, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefix := store.key("")
+	var ids []string
+	err = store.client.Scan(ctx, redisKeyPrefix+escapeGlob(store.tenant)+":*", func(key string) error {
+		if id := strings.TrimPrefix(key, prefix); id > after {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot scan laptops: %w", err)
+	}
+	sort.Strings(ids)
+
+	var laptops []*pb.Laptop
+	for _, id := range ids {
+		laptop, err := store.get(ctx, store.key(id))
+		if err != nil {
+			return nil, "", err
+		}
+		// The laptop may have expired since it was scanned.
+		if laptop != nil {
+			laptops = append(laptops, laptop)
+		}
+		if len(laptops) > pageSize {
+			break
+		}
+	}
+
+	laptops, nextPageToken := nextPage(laptops, pageSize)
+	return laptops, nextPageToken, nil
+}
+
+// Count returns the number of laptops matching the filter, reading every laptop of the tenant
+func (store *RedisLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	return countBySearch(ctx, store, filter)
+}
+
+// Search searches for laptops with filter, returns one by one via the found function.
+// The laptops are sorted after they are all found, if the filter has a sort.
+func (store *RedisLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return searchSorted(ctx, store.search, filter, found)
+}
+
+// search searches for laptops with filter in the order of the store.
+// Redis can't filter the values, so every laptop of the tenant is read and checked.
+func (store *RedisLaptopStore) search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	pattern := redisKeyPrefix + escapeGlob(store.tenant) + ":*"
+	return store.client.Scan(ctx, pattern, func(key string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		laptop, err := store.get(ctx, key)
+		if err != nil {
+			return err
+		}
+		// The laptop may have expired since it was scanned.
+		if laptop == nil || !isQualified(filter, laptop) {
+			return nil
+		}
+		return found(laptop)
+	})
+}
+
+// escapeGlob escapes the special characters of Redis glob patterns.
+func escapeGlob(s string) string {
+	var escaped strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
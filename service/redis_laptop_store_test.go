@@ -0,0 +1,193 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRedisEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// fakeRedisClient keeps the keys in memory. Its glob patterns follow path.Match.
+type fakeRedisClient struct {
+	mutex   sync.Mutex
+	entries map[string]fakeRedisEntry
+}
+
+func (client *fakeRedisClient) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if _, ok := client.lookup(key); ok {
+		return false, nil
+	}
+	client.set(key, value, ttl)
+	return true, nil
+}
+
+func (client *fakeRedisClient) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	old []byte,
+	value []byte,
+	ttl time.Duration,
+) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if entry, ok := client.lookup(key); !ok || !bytes.Equal(entry.value, old) {
+		return false, nil
+	}
+	client.set(key, value, ttl)
+	return true, nil
+}
+
+func (client *fakeRedisClient) set(key string, value []byte, ttl time.Duration) {
+
+	entry := fakeRedisEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	client.entries[key] = entry
+}
+
+func (client *fakeRedisClient) Del(ctx context.Context, key string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	_, ok := client.lookup(key)
+	delete(client.entries, key)
+	return ok, nil
+}
+
+func (client *fakeRedisClient) Get(ctx context.Context, key string) ([]byte, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	entry, _ := client.lookup(key)
+	return entry.value, nil
+}
+
+func (client *fakeRedisClient) Scan(ctx context.Context, pattern string, found func(key string) error) error {
+	client.mutex.Lock()
+	var keys []string
+	for key := range client.entries {
+		if ok, _ := path.Match(pattern, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	client.mutex.Unlock()
+
+	for _, key := range keys {
+		if err := found(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (client *fakeRedisClient) lookup(key string) (fakeRedisEntry, bool) {
+	entry, ok := client.entries[key]
+	if ok && !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(client.entries, key)
+		return fakeRedisEntry{}, false
+	}
+	return entry, ok
+}
+
+func TestRedisLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeRedisClient{entries: make(map[string]fakeRedisEntry)}
+	store := service.NewRedisLaptopStore(client, 100*time.Millisecond)
+
+	cheap := sample.NewLaptop()
+	cheap.PriceUsd = 1000
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, store.Save(context.Background(), cheap))
+	require.NoError(t, store.Save(context.Background(), expensive))
+	require.ErrorIs(t, store.Save(context.Background(), cheap), service.ErrAlreadyExist)
+	require.NoError(t, store.Update(context.Background(), expensive))
+	require.Equal(t, uint64(1), expensive.GetVersion())
+	stale := sample.NewLaptop()
+	stale.Id = expensive.GetId()
+	require.ErrorIs(t, store.Update(context.Background(), stale), service.ErrVersionConflict)
+	require.ErrorIs(t, store.Update(context.Background(), sample.NewLaptop()), service.ErrNotFound)
+	require.ErrorIs(t, store.Delete(context.Background(), "unknown"), service.ErrNotFound)
+	batch := sample.NewLaptop()
+	require.ErrorIs(t, store.SaveBatch(context.Background(), []*pb.Laptop{batch, cheap}), service.ErrAlreadyExist)
+	laptop, err := store.Find(context.Background(), batch.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop, "the saved laptops of a failed batch are deleted")
+	require.ElementsMatch(t, []string{cheap.GetId(), expensive.GetId()}, listAll(t, store, 1))
+	require.NoError(t, store.ForTenant("other").Save(context.Background(), sample.NewLaptop()))
+
+	var found []string
+	err = store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 2000}, func(laptop *pb.Laptop) error {
+		found = append(found, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{cheap.GetId()}, found)
+
+	time.Sleep(150 * time.Millisecond)
+	laptop, err = store.Find(context.Background(), cheap.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop, "laptop has expired")
+}
+
+func TestDialRedisLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	store, err := service.DialRedisLaptopStore(context.Background(), server.Addr(), time.Minute)
+	require.NoError(t, err)
+	defer store.Close()
+
+	cheap := sample.NewLaptop()
+	cheap.PriceUsd = 1000
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, store.SaveBatch(context.Background(), []*pb.Laptop{cheap, expensive}))
+	require.ErrorIs(t, store.Save(context.Background(), cheap), service.ErrAlreadyExist)
+	require.NoError(t, store.Update(context.Background(), expensive))
+	stale := sample.NewLaptop()
+	stale.Id = expensive.GetId()
+	require.ErrorIs(t, store.Update(context.Background(), stale), service.ErrVersionConflict)
+	require.NoError(t, store.ForTenant("other").Save(context.Background(), sample.NewLaptop()))
+	require.ElementsMatch(t, []string{cheap.GetId(), expensive.GetId()}, listAll(t, store, 1))
+
+	var found []string
+	err = store.Search(context.Background(), &pb.Filter{MaxPriceUsd: 2000}, func(laptop *pb.Laptop) error {
+		found = append(found, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{cheap.GetId()}, found)
+
+	require.NoError(t, store.Delete(context.Background(), cheap.GetId()))
+	require.ErrorIs(t, store.Delete(context.Background(), cheap.GetId()), service.ErrNotFound)
+
+	server.FastForward(2 * time.Minute)
+	laptop, err := store.Find(context.Background(), expensive.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop, "the updated laptop has expired")
+
+	addr := server.Addr()
+	server.Close()
+	_, err = service.DialRedisLaptopStore(context.Background(), addr, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"grpc_app/logging"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// requestIDHeader is the metadata key of the ID of a call, which is generated by the server
+// if the client doesn't send one, and sent back in the response header.
+const requestIDHeader = "x-request-id"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the call, or "" if the call isn't logged.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// RequestLogLevel is the level of the calls logged by a RequestLogger.
+type RequestLogLevel int32
+
+const (
+	// RequestLogAll logs all the calls.
+	RequestLogAll RequestLogLevel = iota
+	// RequestLogErrors logs the calls that fail only.
+	RequestLogErrors
+	// RequestLogNone logs no call.
+	RequestLogNone
+)
+
+// requestLogLevelNames are the names of the request log levels.
+var requestLogLevelNames = map[string]RequestLogLevel{
+	"all":    RequestLogAll,
+	"errors": RequestLogErrors,
+	"none":   RequestLogNone,
+}
+
+// ParseRequestLogLevel returns the request log level of the name: all, errors or none.
+func ParseRequestLogLevel(name string) (RequestLogLevel, error) {
+	level, ok := requestLogLevelNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown request log level %q, must be all, errors or none", name)
+	}
+	return level, nil
+}
+
+// RequestLogger is a server interceptor that logs a message for every call, with the method,
+// the peer, the request ID, the duration, the status code and the size of the messages
+// as fields, at the info level if the call succeeds and at the warn level otherwise.
+type RequestLogger struct {
+	logger *logging.Logger
+	level  int32
+}
+
+// NewRequestLogger returns a new request logger writing to the logger,
+// or to the default logger if it's nil.
+func NewRequestLogger(logger *logging.Logger) *RequestLogger {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &RequestLogger{logger: logger}
+}
+
+// SetLevel sets the level of the calls logged, RequestLogAll by default,
+// e.g. when the config of the server is reloaded. The request IDs are still generated at any level.
+func (requestLogger *RequestLogger) SetLevel(level RequestLogLevel) {
+	atomic.StoreInt32(&requestLogger.level, int32(level))
+}
+
+// Unary returns a server interceptor to log unary RPC
+func (requestLogger *RequestLogger) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+		ctx, requestID := withRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID))
+
+		res, err := handler(ctx, req)
+
+		requestLogger.log(ctx, info.FullMethod, start, err,
+			"req_bytes", messageSize(req),
+			"resp_bytes", messageSize(res),
+		)
+		return res, err
+	}
+}
+
+// Stream returns a server interceptor to log stream RPC
+func (requestLogger *RequestLogger) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		ctx, requestID := withRequestID(stream.Context())
+		_ = stream.SetHeader(metadata.Pairs(requestIDHeader, requestID))
+
+		loggedStream := &loggedServerStream{ServerStream: stream, ctx: ctx}
+		err := handler(srv, loggedStream)
+
+		requestLogger.log(ctx, info.FullMethod, start, err,
+			"recv_msgs", loggedStream.recvMsgs,
+			"recv_bytes", loggedStream.recvBytes,
+			"sent_msgs", loggedStream.sentMsgs,
+			"sent_bytes", loggedStream.sentBytes,
+		)
+		return err
+	}
+}
+
+// log logs the call with the fields common to all the calls, then the given key-value pairs.
+func (requestLogger *RequestLogger) log(
+	ctx context.Context,
+	method string,
+	start time.Time,
+	err error,
+	keyValues ...interface{},
+) {
+	switch RequestLogLevel(atomic.LoadInt32(&requestLogger.level)) {
+	case RequestLogNone:
+		return
+	case RequestLogErrors:
+		if err == nil {
+			return
+		}
+	}
+
+	peerAddress := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		peerAddress = p.Addr.String()
+	}
+
+	fields := []interface{}{
+		"method", method,
+		"peer", peerAddress,
+		"request_id", RequestIDFromContext(ctx),
+		"duration", time.Since(start),
+		"code", status.Code(err),
+	}
+	fields = append(fields, keyValues...)
+	if err != nil {
+		fields = append(fields, "error", status.Convert(err).Message())
+		requestLogger.logger.Warn("call", fields...)
+		return
+	}
+	requestLogger.logger.Info("call", fields...)
+}
+
+// withRequestID returns the context with the request ID of the incoming metadata,
+// or a new one if there is none.
+func withRequestID(ctx context.Context) (context.Context, string) {
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md[requestIDHeader]; len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID), requestID
+}
+
+// messageSize returns the size of the encoded message, or 0 if it's not a proto message.
+func messageSize(message interface{}) int {
+	if message, ok := message.(proto.Message); ok {
+		return proto.Size(message)
+	}
+	return 0
+}
+
+// loggedServerStream is a server stream that counts the messages it receives and sends.
+type loggedServerStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	recvMsgs  int
+	recvBytes int
+	sentMsgs  int
+	sentBytes int
+}
+
+func (stream *loggedServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *loggedServerStream) RecvMsg(m interface{}) error {
+	err := stream.ServerStream.RecvMsg(m)
+	if err == nil {
+		stream.recvMsgs++
+		stream.recvBytes += messageSize(m)
+	}
+	return err
+}
+
+func (stream *loggedServerStream) SendMsg(m interface{}) error {
+	err := stream.ServerStream.SendMsg(m)
+	if err == nil {
+		stream.sentMsgs++
+		stream.sentBytes += messageSize(m)
+	}
+	return err
+}
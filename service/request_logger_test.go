@@ -0,0 +1,77 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/logging"
+	"grpc_app/pb"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequestLoggerUnary(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	unary := service.NewRequestLogger(logging.New(&output, logging.Debug, logging.Console)).Unary()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc_app.proto.LaptopService/CreateLaptop"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "request-1"))
+	req := &pb.CreateLaptopRequest{Laptop: &pb.Laptop{Id: "laptop-1"}}
+	_, err := unary(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		require.Equal(t, "request-1", service.RequestIDFromContext(ctx))
+		return &pb.CreateLaptopResponse{Id: "laptop-1"}, nil
+	})
+	require.NoError(t, err)
+
+	line := output.String()
+	require.Contains(t, line, "method=/grpc_app.proto.LaptopService/CreateLaptop ")
+	require.Contains(t, line, " request_id=request-1 ")
+	require.Contains(t, line, " code=OK ")
+	require.Contains(t, line, " req_bytes=12 resp_bytes=10\n")
+
+	output.Reset()
+	_, err = unary(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		require.NotEmpty(t, service.RequestIDFromContext(ctx))
+		return nil, status.Errorf(codes.AlreadyExists, "cannot save laptop to the store")
+	})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+	require.Contains(t, output.String(), ` code=AlreadyExists `)
+	require.Contains(t, output.String(), ` error="cannot save laptop to the store"`)
+}
+
+func TestRequestLoggerLevel(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	requestLogger := service.NewRequestLogger(logging.New(&output, logging.Debug, logging.Console))
+	unary := requestLogger.Unary()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc_app.proto.LaptopService/CreateLaptop"}
+	call := func(err error) {
+		_, _ = unary(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, err
+		})
+	}
+
+	level, err := service.ParseRequestLogLevel("errors")
+	require.NoError(t, err)
+	requestLogger.SetLevel(level)
+	call(nil)
+	require.Empty(t, output.String())
+	call(status.Error(codes.Internal, "boom"))
+	require.Contains(t, output.String(), " code=Internal ")
+
+	output.Reset()
+	requestLogger.SetLevel(service.RequestLogNone)
+	call(status.Error(codes.Internal, "boom"))
+	require.Empty(t, output.String())
+
+	_, err = service.ParseRequestLogLevel("debug")
+	require.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"grpc_app/logging"
+	"grpc_app/metrics"
+	"grpc_app/pb"
+	"time"
+)
+
+// RetentionRule deletes the records kept longer than its maximum age.
+type RetentionRule struct {
+	// Name names the rule in the logs and the metrics.
+	Name string
+	// MaxAge is how long the records are kept.
+	MaxAge time.Duration
+	// Purge deletes the records older than before, or only counts them if dryRun,
+	// and returns their number.
+	Purge func(ctx context.Context, before time.Time, dryRun bool) (int, error)
+}
+
+// NewLaptopRetentionRule returns the rule deleting the laptops matching the expression, all of them if it's nil,
+// that were not created or updated for maxAge. The laptops are deleted through the store, so they are audited
+// and kept to be restored like the others. The laptops of every tenant returned by tenants are deleted,
+// or only the laptops of the default tenant if tenants is nil.
+func NewLaptopRetentionRule(
+	store LaptopStore,
+	tenants func() []string,
+	expression *pb.Expression,
+	maxAge time.Duration,
+) RetentionRule {
+	if expression == nil {
+		expression = &pb.Expression{}
+	}
+	filter := &pb.Filter{Expression: expression}
+	expired := func(before time.Time) func(laptop *pb.Laptop) bool {
+		return func(laptop *pb.Laptop) bool {
+			return laptop.GetDeletedAt() == nil && laptop.GetUpdatedAt().AsTime().Before(before) && isQualified(filter, laptop)
+		}
+	}
+	return RetentionRule{
+		Name:   "laptops",
+		MaxAge: maxAge,
+		Purge: func(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+			names := []string{""}
+			if tenants != nil {
+				names = tenants()
+			}
+
+			purged := 0
+			for _, tenant := range names {
+				n, err := deleteLaptops(ctx, tenantStore(store, tenant), expired(before), dryRun)
+				purged += n
+				if err != nil {
+					return purged, fmt.Errorf("cannot purge laptops of tenant %q: %w", tenant, err)
+				}
+			}
+			return purged, nil
+		},
+	}
+}
+
+// AuditPruner is implemented by the audit sinks that can delete their old entries.
+type AuditPruner interface {
+	// Prune deletes the entries recorded before the time, or only counts them if dryRun,
+	// and returns their number.
+	Prune(ctx context.Context, before time.Time, dryRun bool) (int, error)
+}
+
+// NewAuditRetentionRule returns the rule deleting the audit entries older than maxAge.
+func NewAuditRetentionRule(pruner AuditPruner, maxAge time.Duration) RetentionRule {
+	return RetentionRule{Name: "audit_log", MaxAge: maxAge, Purge: pruner.Prune}
+}
+
+// RetentionPolicy applies retention rules, recording the number of records deleted by each rule.
+// In a dry run, the records are only counted, to check the rules before they delete anything.
+type RetentionPolicy struct {
+	rules   []RetentionRule
+	dryRun  bool
+	deleted *metrics.Counter
+	logger  *logging.Logger
+}
+
+// NewRetentionPolicy returns a new RetentionPolicy applying the rules, recording its metrics
+// in the registry unless it's nil.
+func NewRetentionPolicy(rules []RetentionRule, dryRun bool, registry *metrics.Registry) *RetentionPolicy {
+	policy := &RetentionPolicy{rules: rules, dryRun: dryRun, logger: logging.Default()}
+	if registry != nil {
+		policy.deleted = registry.NewCounter("retention_deleted_records_total",
+			"Total number of records deleted by the retention rules, or that would be in a dry run.",
+			"rule", "dry_run")
+	}
+	return policy
+}
+
+// SetLogger replaces the logger of the policy, the default logger by default. It must be called before Run.
+func (policy *RetentionPolicy) SetLogger(logger *logging.Logger) {
+	policy.logger = logger
+}
+
+// Apply applies every rule once, and returns the number of records deleted, or that would be
+// in a dry run, by rule name. A failed rule doesn't stop the others, the first error is returned.
+func (policy *RetentionPolicy) Apply(ctx context.Context) (map[string]int, error) {
+	deleted := make(map[string]int, len(policy.rules))
+	var firstErr error
+	for _, rule := range policy.rules {
+		n, err := rule.Purge(ctx, time.Now().Add(-rule.MaxAge), policy.dryRun)
+		deleted[rule.Name] += n
+		if policy.deleted != nil && n > 0 {
+			policy.deleted.Add(float64(n), rule.Name, fmt.Sprint(policy.dryRun))
+		}
+		if err != nil {
+			policy.logger.Error("cannot apply retention rule", "rule", rule.Name, "deleted", n, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		switch {
+		case policy.dryRun:
+			policy.logger.Info("retention rule dry run", "rule", rule.Name, "max_age", rule.MaxAge, "would_delete", n)
+		case n > 0:
+			policy.logger.Info("applied retention rule", "rule", rule.Name, "max_age", rule.MaxAge, "deleted", n)
+		}
+	}
+	return deleted, firstErr
+}
+
+// Run applies the rules every interval until the context is done.
+func (policy *RetentionPolicy) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		_, _ = policy.Apply(ctx)
+	}
+}
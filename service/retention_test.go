@@ -0,0 +1,133 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/metrics"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestLaptopRetentionRule(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewTenantLaptopStore(func(string) (service.LaptopStore, error) {
+		return service.NewInMemoryLaptopStore(), nil
+	})
+	ctx := context.Background()
+	old := timestamppb.New(time.Now().Add(-48 * time.Hour))
+
+	oldLaptop := sample.NewLaptop()
+	oldLaptop.ReleaseYear = 2015
+	oldLaptop.UpdatedAt = old
+	recentLaptop := sample.NewLaptop()
+	recentLaptop.ReleaseYear = 2015
+	recentLaptop.UpdatedAt = timestamppb.Now()
+	newModel := sample.NewLaptop()
+	newModel.ReleaseYear = 2022
+	newModel.UpdatedAt = old
+	otherTenant := sample.NewLaptop()
+	otherTenant.ReleaseYear = 2015
+	otherTenant.UpdatedAt = old
+	require.NoError(t, store.SaveBatch(ctx, []*pb.Laptop{oldLaptop, recentLaptop, newModel}))
+	require.NoError(t, store.ForTenant("acme").Save(ctx, otherTenant))
+
+	expression := &pb.Expression{Node: &pb.Expression_Condition{Condition: &pb.Condition{
+		Field:    "release_year",
+		Operator: pb.Condition_LT,
+		Value:    &pb.Condition_NumberValue{NumberValue: 2018},
+	}}}
+	rule := service.NewLaptopRetentionRule(store, store.Tenants, expression, 24*time.Hour)
+	registry := metrics.NewRegistry()
+
+	deleted, err := service.NewRetentionPolicy([]service.RetentionRule{rule}, true, registry).Apply(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"laptops": 2}, deleted)
+	count, err := store.Count(ctx, &pb.Filter{MaxPriceUsd: 1e6})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, count, "nothing is deleted in a dry run")
+
+	dryRunRegistry := registry
+	registry = metrics.NewRegistry()
+	deleted, err = service.NewRetentionPolicy([]service.RetentionRule{rule}, false, registry).Apply(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"laptops": 2}, deleted)
+
+	found, err := store.Find(ctx, oldLaptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+	found, err = store.ForTenant("acme").Find(ctx, otherTenant.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+	for _, kept := range []*pb.Laptop{recentLaptop, newModel} {
+		found, err = store.Find(ctx, kept.GetId())
+		require.NoError(t, err)
+		require.NotNil(t, found)
+	}
+
+	var output bytes.Buffer
+	_, err = dryRunRegistry.WriteTo(&output)
+	require.NoError(t, err)
+	require.Contains(t, output.String(), `retention_deleted_records_total{rule="laptops",dry_run="true"} 2`)
+	output.Reset()
+	_, err = registry.WriteTo(&output)
+	require.NoError(t, err)
+	require.Contains(t, output.String(), `retention_deleted_records_total{rule="laptops",dry_run="false"} 2`)
+}
+
+func TestAuditRetentionRule(t *testing.T) {
+	t.Parallel()
+
+	fileSink, err := service.OpenFileAuditSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { fileSink.Close() })
+
+	sinks := map[string]interface {
+		service.AuditSink
+		service.AuditPruner
+	}{
+		"memory": service.NewInMemoryAuditSink(),
+		"file":   fileSink,
+	}
+	for name, sink := range sinks {
+		sink := sink
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			oldEntry := &pb.AuditEntry{Time: timestamppb.New(time.Now().Add(-100 * 24 * time.Hour)), LaptopId: "laptop-1"}
+			newEntry := &pb.AuditEntry{Time: timestamppb.Now(), LaptopId: "laptop-1"}
+			require.NoError(t, sink.Append(ctx, oldEntry))
+			require.NoError(t, sink.Append(ctx, newEntry))
+
+			rules := []service.RetentionRule{service.NewAuditRetentionRule(sink, 90*24*time.Hour)}
+			deleted, err := service.NewRetentionPolicy(rules, true, nil).Apply(ctx)
+			require.NoError(t, err)
+			require.Equal(t, map[string]int{"audit_log": 1}, deleted)
+			entries, err := sink.Query(ctx, "", "laptop-1")
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+
+			deleted, err = service.NewRetentionPolicy(rules, false, nil).Apply(ctx)
+			require.NoError(t, err)
+			require.Equal(t, map[string]int{"audit_log": 1}, deleted)
+			entries, err = sink.Query(ctx, "", "laptop-1")
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			require.Equal(t, newEntry.GetTime().AsTime(), entries[0].GetTime().AsTime())
+
+			// The entries are still appended after the pruning.
+			require.NoError(t, sink.Append(ctx, newEntry))
+			entries, err = sink.Query(ctx, "", "laptop-1")
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"grpc_app/metrics"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// ServerMetrics is a server interceptor that records the number, the status codes and the latency
+// of the calls, and the number of open streams, for every method.
+type ServerMetrics struct {
+	started  *metrics.Counter
+	handled  *metrics.Counter
+	handling *metrics.Histogram
+	inFlight *metrics.Gauge
+}
+
+// NewServerMetrics returns new server metrics registered in the registry.
+func NewServerMetrics(registry *metrics.Registry) *ServerMetrics {
+	return &ServerMetrics{
+		started: registry.NewCounter("grpc_server_started_total",
+			"Total number of RPCs started on the server.", "grpc_service", "grpc_method"),
+		handled: registry.NewCounter("grpc_server_handled_total",
+			"Total number of RPCs completed on the server, regardless of success or failure.",
+			"grpc_service", "grpc_method", "grpc_code"),
+		handling: registry.NewHistogram("grpc_server_handling_seconds",
+			"Latency of the RPCs handled by the server.", metrics.DefaultBuckets, "grpc_service", "grpc_method"),
+		inFlight: registry.NewGauge("grpc_server_in_flight_streams",
+			"Number of streaming RPCs open on the server.", "grpc_service", "grpc_method"),
+	}
+}
+
+// Unary returns a server interceptor to record the metrics of unary RPC
+func (serverMetrics *ServerMetrics) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		service, method := splitMethod(info.FullMethod)
+		serverMetrics.started.Inc(service, method)
+		start := time.Now()
+
+		res, err := handler(ctx, req)
+
+		serverMetrics.record(service, method, start, err)
+		return res, err
+	}
+}
+
+// Stream returns a server interceptor to record the metrics of stream RPC
+func (serverMetrics *ServerMetrics) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		service, method := splitMethod(info.FullMethod)
+		serverMetrics.started.Inc(service, method)
+		serverMetrics.inFlight.Add(1, service, method)
+		start := time.Now()
+
+		err := handler(srv, stream)
+
+		serverMetrics.inFlight.Add(-1, service, method)
+		serverMetrics.record(service, method, start, err)
+		return err
+	}
+}
+
+func (serverMetrics *ServerMetrics) record(service string, method string, start time.Time, err error) {
+	serverMetrics.handled.Inc(service, method, status.Code(err).String())
+	serverMetrics.handling.Observe(time.Since(start).Seconds(), service, method)
+}
+
+// splitMethod splits a full method name like /grpc_app.proto.LaptopService/CreateLaptop
+// into its service and method names.
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", fullMethod
+	}
+	return service, method
+}
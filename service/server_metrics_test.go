@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/metrics"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerMetrics(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	unary := service.NewServerMetrics(registry).Unary()
+	laptopStore := service.NewInstrumentedLaptopStore(service.NewInMemoryLaptopStore(), service.NewStoreMetrics(registry), nil)
+	server := service.NewLaptopServer(laptopStore, nil, nil)
+
+	laptop := sample.NewLaptop()
+	create := func() error {
+		_, err := unary(
+			context.Background(),
+			&pb.CreateLaptopRequest{Laptop: laptop},
+			&grpc.UnaryServerInfo{FullMethod: "/grpc_app.proto.LaptopService/CreateLaptop"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return server.CreateLaptop(ctx, req.(*pb.CreateLaptopRequest))
+			},
+		)
+		return err
+	}
+	require.NoError(t, create())
+	require.Equal(t, codes.AlreadyExists, status.Code(create()))
+
+	var output bytes.Buffer
+	_, err := registry.WriteTo(&output)
+	require.NoError(t, err)
+	require.Contains(t, output.String(),
+		`grpc_server_started_total{grpc_service="grpc_app.proto.LaptopService",grpc_method="CreateLaptop"} 2`)
+	require.Contains(t, output.String(),
+		`grpc_server_handled_total{grpc_service="grpc_app.proto.LaptopService",grpc_method="CreateLaptop",grpc_code="OK"} 1`)
+	require.Contains(t, output.String(),
+		`grpc_server_handled_total{grpc_service="grpc_app.proto.LaptopService",grpc_method="CreateLaptop",grpc_code="AlreadyExists"} 1`)
+	require.Contains(t, output.String(),
+		`grpc_server_handling_seconds_count{grpc_service="grpc_app.proto.LaptopService",grpc_method="CreateLaptop"} 2`)
+	require.Contains(t, output.String(), `laptop_store_operation_seconds_count{operation="save",result="ok"} 1`)
+	require.Contains(t, output.String(), `laptop_store_operation_seconds_count{operation="save",result="conflict"} 1`)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"grpc_app/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TracingInterceptor is a server interceptor that traces every call with a server span,
+// child of the span propagated by the client if any. The span is in the context of the handler,
+// so the spans of the stores are its children.
+type TracingInterceptor struct {
+	tracer *tracing.Tracer
+}
+
+// NewTracingInterceptor returns a new tracing interceptor creating its spans with the tracer.
+func NewTracingInterceptor(tracer *tracing.Tracer) *TracingInterceptor {
+	return &TracingInterceptor{tracer: tracer}
+}
+
+// Unary returns a server interceptor to trace unary RPC
+func (interceptor *TracingInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, span := interceptor.start(ctx, info.FullMethod)
+		res, err := handler(ctx, req)
+		finishSpan(span, err)
+		return res, err
+	}
+}
+
+// Stream returns a server interceptor to trace stream RPC
+func (interceptor *TracingInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, span := interceptor.start(stream.Context(), info.FullMethod)
+		err := handler(srv, &serverStreamWithContext{ServerStream: stream, ctx: ctx})
+		finishSpan(span, err)
+		return err
+	}
+}
+
+func (interceptor *TracingInterceptor) start(ctx context.Context, fullMethod string) (context.Context, *tracing.Span) {
+	ctx, span := interceptor.tracer.StartFromIncoming(ctx, fullMethod)
+	service, method := splitMethod(fullMethod)
+	span.SetAttribute("rpc.system", "grpc")
+	span.SetAttribute("rpc.service", service)
+	span.SetAttribute("rpc.method", method)
+	if p, ok := peer.FromContext(ctx); ok {
+		span.SetAttribute("net.peer.address", p.Addr.String())
+	}
+	return ctx, span
+}
+
+func finishSpan(span *tracing.Span, err error) {
+	span.SetStatus(status.Code(err), err)
+	span.Finish()
+}
@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"grpc_app/tracing"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTracingInterceptorUnary(t *testing.T) {
+	t.Parallel()
+
+	var spans []*tracing.Span
+	tracer := tracing.NewTracer(tracing.ExporterFunc(func(span *tracing.Span) {
+		spans = append(spans, span)
+	}))
+	laptopStore := service.NewInstrumentedLaptopStore(service.NewInMemoryLaptopStore(), nil, tracer)
+	server := service.NewLaptopServer(laptopStore, nil, nil)
+	unary := service.NewTracingInterceptor(tracer).Unary()
+
+	clientContext, clientSpan := tracer.Start(context.Background(), "client", tracing.KindClient)
+	outgoing, _ := metadata.FromOutgoingContext(tracing.Inject(clientContext))
+	ctx := metadata.NewIncomingContext(context.Background(), outgoing)
+
+	_, err := unary(
+		ctx,
+		&pb.CreateLaptopRequest{Laptop: sample.NewLaptop()},
+		&grpc.UnaryServerInfo{FullMethod: "/grpc_app.proto.LaptopService/CreateLaptop"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return server.CreateLaptop(ctx, req.(*pb.CreateLaptopRequest))
+		},
+	)
+	require.NoError(t, err)
+
+	require.Len(t, spans, 2)
+	storeSpan, serverSpan := spans[0], spans[1]
+	require.Equal(t, "LaptopStore/save", storeSpan.Name)
+	require.Equal(t, "/grpc_app.proto.LaptopService/CreateLaptop", serverSpan.Name)
+	require.Equal(t, tracing.KindServer, serverSpan.Kind)
+	require.Equal(t, "CreateLaptop", serverSpan.Attributes["rpc.method"])
+	require.Equal(t, codes.OK, serverSpan.Code)
+
+	require.Equal(t, clientSpan.Context.TraceID, serverSpan.Context.TraceID)
+	require.Equal(t, clientSpan.Context.SpanID, serverSpan.ParentID)
+	require.Equal(t, serverSpan.Context.TraceID, storeSpan.Context.TraceID)
+	require.Equal(t, serverSpan.Context.SpanID, storeSpan.ParentID)
+}
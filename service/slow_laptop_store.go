@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"grpc_app/logging"
+	"grpc_app/pb"
+	"time"
+)
+
+// SlowLaptopStore is a LaptopStore that logs the operations of another store taking longer than a threshold,
+// with the ID or the filter involved, to surface the pathological queries.
+type SlowLaptopStore struct {
+	backend   LaptopStore
+	threshold time.Duration
+	logger    *logging.Logger
+}
+
+// NewSlowLaptopStore returns a new SlowLaptopStore over the backend, logging the operations slower
+// than the threshold at the warn level to the logger, or to the default logger if it's nil.
+func NewSlowLaptopStore(backend LaptopStore, threshold time.Duration, logger *logging.Logger) *SlowLaptopStore {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &SlowLaptopStore{backend: backend, threshold: threshold, logger: logger}
+}
+
+// ForTenant returns the store of the tenant, whose slow operations are logged with the tenant.
+func (store *SlowLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &SlowLaptopStore{
+		backend:   tenantStore(store.backend, tenant),
+		threshold: store.threshold,
+		logger:    store.logger.With("tenant", tenant),
+	}
+}
+
+// check logs the operation if it took longer than the threshold since start, with the key-value pairs
+// of what it was applied to.
+func (store *SlowLaptopStore) check(ctx context.Context, operation string, start time.Time, err error, keyValues ...interface{}) {
+	duration := time.Since(start)
+	if duration <= store.threshold {
+		return
+	}
+
+	fields := []interface{}{
+		"operation", operation,
+		"duration", duration.Round(time.Millisecond),
+		"threshold", store.threshold,
+		"request_id", RequestIDFromContext(ctx),
+	}
+	fields = append(fields, keyValues...)
+	if err != nil {
+		fields = append(fields, "error", err)
+	}
+	store.logger.Warn("slow store operation", fields...)
+}
+
+// Save saves the laptop to the backend
+func (store *SlowLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) (err error) {
+	defer func(start time.Time) { store.check(ctx, "save", start, err, "id", laptop.GetId()) }(time.Now())
+	return store.backend.Save(ctx, laptop)
+}
+
+// SaveBatch saves the laptops to the backend
+func (store *SlowLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) (err error) {
+	defer func(start time.Time) { store.check(ctx, "save_batch", start, err, "laptops", len(laptops)) }(time.Now())
+	return store.backend.SaveBatch(ctx, laptops)
+}
+
+// Update updates the laptop in the backend
+func (store *SlowLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) (err error) {
+	defer func(start time.Time) { store.check(ctx, "update", start, err, "id", laptop.GetId()) }(time.Now())
+	return store.backend.Update(ctx, laptop)
+}
+
+// Delete deletes the laptop from the backend
+func (store *SlowLaptopStore) Delete(ctx context.Context, id string) (err error) {
+	defer func(start time.Time) { store.check(ctx, "delete", start, err, "id", id) }(time.Now())
+	return store.backend.Delete(ctx, id)
+}
+
+// Find finds a laptop by ID in the backend
+func (store *SlowLaptopStore) Find(ctx context.Context, id string) (laptop *pb.Laptop, err error) {
+	defer func(start time.Time) { store.check(ctx, "find", start, err, "id", id) }(time.Now())
+	return store.backend.Find(ctx, id)
+}
+
+// List lists the laptops of the backend
+func (store *SlowLaptopStore) List(
+	ctx context.Context,
+	pageSize int,
+	pageToken string,
+) (laptops []*pb.Laptop, nextPageToken string, err error) {
+	defer func(start time.Time) {
+		store.check(ctx, "list", start, err, "page_size", pageSize, "page_token", pageToken)
+	}(time.Now())
+	return store.backend.List(ctx, pageSize, pageToken)
+}
+
+// Count counts the laptops of the backend
+func (store *SlowLaptopStore) Count(ctx context.Context, filter *pb.Filter) (count int64, err error) {
+	defer func(start time.Time) { store.check(ctx, "count", start, err, "filter", filter) }(time.Now())
+	return store.backend.Count(ctx, filter)
+}
+
+// Reindex rebuilds the indexes of the backend
+func (store *SlowLaptopStore) Reindex(ctx context.Context) (indexed int, err error) {
+	defer func(start time.Time) { store.check(ctx, "reindex", start, err, "indexed", indexed) }(time.Now())
+	return reindexLaptops(ctx, store.backend)
+}
+
+// Stats returns the statistics of the laptops of the backend
+func (store *SlowLaptopStore) Stats(ctx context.Context, filter *pb.Filter) (stats *pb.CatalogStats, err error) {
+	defer func(start time.Time) { store.check(ctx, "stats", start, err, "filter", filter) }(time.Now())
+	return aggregateLaptops(ctx, store.backend, filter)
+}
+
+// Search searches for laptops in the backend. Unlike the metrics of InstrumentedLaptopStore, the duration
+// excludes the time spent in found, e.g. sending the laptops to a slow client, which the store isn't to blame for.
+func (store *SlowLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) (err error) {
+	var foundDuration time.Duration
+	matched := 0
+	defer func(start time.Time) {
+		store.check(ctx, "search", start.Add(foundDuration), err, "filter", filter, "matched", matched)
+	}(time.Now())
+
+	return store.backend.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		matched++
+		foundStart := time.Now()
+		defer func() { foundDuration += time.Since(foundStart) }()
+		return found(laptop)
+	})
+}
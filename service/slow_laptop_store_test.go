@@ -0,0 +1,56 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"grpc_app/logging"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sleepyLaptopStore is a laptop store whose Find takes at least the delay.
+type sleepyLaptopStore struct {
+	service.LaptopStore
+	delay time.Duration
+}
+
+func (store *sleepyLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	time.Sleep(store.delay)
+	return store.LaptopStore.Find(ctx, id)
+}
+
+func TestSlowLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	logger := logging.New(&output, logging.Debug, logging.Console)
+	backend := &sleepyLaptopStore{LaptopStore: service.NewInMemoryLaptopStore(), delay: 50 * time.Millisecond}
+	store := service.NewSlowLaptopStore(backend, 20*time.Millisecond, logger)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	require.Empty(t, output.String(), "the fast operations are not logged")
+
+	_, err := store.Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Contains(t, output.String(), "WARN slow store operation operation=find ")
+	require.Contains(t, output.String(), " threshold=20ms ")
+	require.Contains(t, output.String(), " id="+laptop.GetId()+"\n")
+
+	output.Reset()
+	err = store.Search(context.Background(), &pb.Filter{}, func(laptop *pb.Laptop) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Empty(t, output.String(), "the time spent in found is not the store's")
+
+	_, err = store.ForTenant("acme").Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Contains(t, output.String(), " slow store operation tenant=acme operation=find ")
+}
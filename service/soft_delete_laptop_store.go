@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"grpc_app/logging"
+	"grpc_app/pb"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// LaptopRestorer is implemented by the laptop stores that keep the deleted laptops for a while.
+type LaptopRestorer interface {
+	// Restore restores the deleted laptop with the ID and returns it, or returns ErrNotFound
+	// if no laptop with the ID was deleted within the retention window of the store.
+	Restore(ctx context.Context, id string) (*pb.Laptop, error)
+}
+
+// ErrNotRestorable is returned by the wrappers of the laptop stores that implement LaptopRestorer
+// when their backend doesn't keep the deleted laptops.
+var ErrNotRestorable = errors.New("the laptop store doesn't keep the deleted laptops")
+
+// SoftDeleteLaptopStore is a LaptopStore that keeps the laptops deleted through it in another store,
+// with the time they were deleted, so they can be restored within a retention window.
+// The deleted laptops are hidden from Find, List and Search unless the filter includes them.
+type SoftDeleteLaptopStore struct {
+	backend   LaptopStore
+	tenant    string
+	retention time.Duration
+	// tenants are the tenants the store was used for, whose deleted laptops are purged by Purge.
+	tenants *tenantSet
+	logger  *logging.Logger
+}
+
+type tenantSet struct {
+	mutex   sync.Mutex
+	tenants map[string]bool
+}
+
+// NewSoftDeleteLaptopStore returns a new SoftDeleteLaptopStore over the backend,
+// which keeps the deleted laptops for the retention window.
+func NewSoftDeleteLaptopStore(backend LaptopStore, retention time.Duration) *SoftDeleteLaptopStore {
+	return &SoftDeleteLaptopStore{
+		backend:   backend,
+		retention: retention,
+		tenants:   &tenantSet{tenants: map[string]bool{"": true}},
+		logger:    logging.Default(),
+	}
+}
+
+// SetLogger replaces the logger of the store, the default logger by default. It must be called before Run.
+func (store *SoftDeleteLaptopStore) SetLogger(logger *logging.Logger) {
+	store.logger = logger
+}
+
+// ForTenant returns the store of the tenant, which keeps the deleted laptops in the store of the tenant.
+func (store *SoftDeleteLaptopStore) ForTenant(tenant string) LaptopStore {
+	store.tenants.mutex.Lock()
+	store.tenants.tenants[tenant] = true
+	store.tenants.mutex.Unlock()
+
+	return &SoftDeleteLaptopStore{
+		backend:   tenantStore(store.backend, tenant),
+		tenant:    tenant,
+		retention: store.retention,
+		tenants:   store.tenants,
+		logger:    store.logger,
+	}
+}
+
+// Save saves the laptop to the backend
+func (store *SoftDeleteLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	return store.backend.Save(ctx, laptop)
+}
+
+// SaveBatch saves the laptops to the backend
+func (store *SoftDeleteLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	return store.backend.SaveBatch(ctx, laptops)
+}
+
+// Update updates the laptop in the backend, unless it is deleted
+func (store *SoftDeleteLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	stored, err := store.backend.Find(ctx, laptop.GetId())
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.GetDeletedAt() != nil {
+		return ErrNotFound
+	}
+
+	// If the laptop is deleted since it was found, its version has changed.
+	return store.backend.Update(ctx, laptop)
+}
+
+// Delete marks the laptop as deleted in the backend
+func (store *SoftDeleteLaptopStore) Delete(ctx context.Context, id string) error {
+	return store.update(ctx, id, func(laptop *pb.Laptop) error {
+		if laptop.GetDeletedAt() != nil {
+			return ErrNotFound
+		}
+		laptop.DeletedAt = timestamppb.Now()
+		return nil
+	})
+}
+
+// Restore restores the deleted laptop in the backend
+func (store *SoftDeleteLaptopStore) Restore(ctx context.Context, id string) (*pb.Laptop, error) {
+	var restored *pb.Laptop
+	err := store.update(ctx, id, func(laptop *pb.Laptop) error {
+		if laptop.GetDeletedAt() == nil || store.expired(laptop) {
+			return ErrNotFound
+		}
+		laptop.DeletedAt = nil
+		restored = laptop
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// update applies change to the laptop of the backend, and retries if it is updated concurrently.
+func (store *SoftDeleteLaptopStore) update(ctx context.Context, id string, change func(laptop *pb.Laptop) error) error {
+	for {
+		laptop, err := store.backend.Find(ctx, id)
+		if err != nil {
+			return err
+		}
+		if laptop == nil {
+			return ErrNotFound
+		}
+
+		err = change(laptop)
+		if err != nil {
+			return err
+		}
+
+		err = store.backend.Update(ctx, laptop)
+		if !errors.Is(err, ErrVersionConflict) {
+			return err
+		}
+	}
+}
+
+// Find finds a laptop by ID in the backend, unless it is deleted
+func (store *SoftDeleteLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	laptop, err := store.backend.Find(ctx, id)
+	if err != nil || laptop.GetDeletedAt() != nil {
+		return nil, err
+	}
+	return laptop, nil
+}
+
+// List returns a page of the laptops of the backend that are not deleted. A page is only empty
+// if it is the last one, but it can have less than pageSize laptops otherwise.
+func (store *SoftDeleteLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	for {
+		laptops, nextPageToken, err := store.backend.List(ctx, pageSize, pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+
+		kept := laptops[:0]
+		for _, laptop := range laptops {
+			if laptop.GetDeletedAt() == nil {
+				kept = append(kept, laptop)
+			}
+		}
+		if len(kept) > 0 || nextPageToken == "" {
+			return kept, nextPageToken, nil
+		}
+		pageToken = nextPageToken
+	}
+}
+
+// Count counts the laptops of the backend matching the filter. Unless the filter includes
+// the deleted laptops, they have to be read to exclude the deleted ones.
+func (store *SoftDeleteLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	if filter.GetIncludeDeleted() {
+		return store.backend.Count(ctx, filter)
+	}
+	return countBySearch(ctx, store, filter)
+}
+
+// Search searches for laptops in the backend, excluding the deleted ones unless the filter includes them.
+func (store *SoftDeleteLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.backend.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		if laptop.GetDeletedAt() != nil && !filter.GetIncludeDeleted() {
+			return nil
+		}
+		return found(laptop)
+	})
+}
+
+func (store *SoftDeleteLaptopStore) expired(laptop *pb.Laptop) bool {
+	return time.Since(laptop.GetDeletedAt().AsTime()) > store.retention
+}
+
+// Reindex rebuilds the indexes of the backend, the deleted laptops included.
+func (store *SoftDeleteLaptopStore) Reindex(ctx context.Context) (int, error) {
+	return reindexLaptops(ctx, store.backend)
+}
+
+// Purge deletes the laptops whose retention window has passed from the backend, for the tenants
+// the store was used for since it was created, and returns the number of laptops deleted.
+func (store *SoftDeleteLaptopStore) Purge(ctx context.Context) (int, error) {
+	return store.purgeTenants(ctx, store.expired)
+}
+
+// PurgeAll deletes all the deleted laptops from the backend, even the ones still within their
+// retention window, for the tenants the store was used for, and returns the number of laptops deleted.
+func (store *SoftDeleteLaptopStore) PurgeAll(ctx context.Context) (int, error) {
+	return store.purgeTenants(ctx, func(laptop *pb.Laptop) bool { return true })
+}
+
+// purgeTenants deletes the deleted laptops to purge from the backends of the tenants.
+func (store *SoftDeleteLaptopStore) purgeTenants(ctx context.Context, purgeable func(laptop *pb.Laptop) bool) (int, error) {
+	store.tenants.mutex.Lock()
+	tenants := make([]string, 0, len(store.tenants.tenants))
+	for tenant := range store.tenants.tenants {
+		tenants = append(tenants, tenant)
+	}
+	store.tenants.mutex.Unlock()
+
+	purged := 0
+	for _, tenant := range tenants {
+		n, err := store.purge(ctx, tenantStore(store.backend, tenant), purgeable)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// purgePageSize is the page size of the laptops listed by Purge.
+const purgePageSize = 100
+
+// purge deletes the deleted laptops to purge of the backend of a tenant.
+func (store *SoftDeleteLaptopStore) purge(ctx context.Context, backend LaptopStore, purgeable func(laptop *pb.Laptop) bool) (int, error) {
+	return deleteLaptops(ctx, backend, func(laptop *pb.Laptop) bool {
+		return laptop.GetDeletedAt() != nil && purgeable(laptop)
+	}, false)
+}
+
+// deleteLaptops deletes the laptops of the store matching match, or only counts them if dryRun,
+// and returns their number.
+func deleteLaptops(ctx context.Context, store LaptopStore, match func(laptop *pb.Laptop) bool, dryRun bool) (int, error) {
+	// The laptops are deleted once they are all listed, so the deletes don't change the pages.
+	var matched []string
+	pageToken := ""
+	for {
+		laptops, nextPageToken, err := store.List(ctx, purgePageSize, pageToken)
+		if err != nil {
+			return 0, err
+		}
+		for _, laptop := range laptops {
+			if match(laptop) {
+				matched = append(matched, laptop.GetId())
+			}
+		}
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+	if dryRun {
+		return len(matched), nil
+	}
+
+	deleted := 0
+	for _, id := range matched {
+		err := store.Delete(ctx, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Run purges the expired laptops every interval until the context is done.
+func (store *SoftDeleteLaptopStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		purged, err := store.Purge(ctx)
+		if err != nil {
+			store.logger.Error("cannot purge deleted laptops", "error", err)
+		}
+		if purged > 0 {
+			store.logger.Info("purged deleted laptops", "purged", purged)
+		}
+	}
+}
+
+// tenantStore returns the store of the tenant if the store is tenant-scoped, or the store itself.
+func tenantStore(store LaptopStore, tenant string) LaptopStore {
+	if scoped, ok := store.(TenantScopedStore); ok {
+		return scoped.ForTenant(tenant)
+	}
+	return store
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSoftDeleteLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	backend := service.NewInMemoryLaptopStore()
+	store := service.NewSoftDeleteLaptopStore(backend, time.Hour)
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.NoError(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop1, laptop2}))
+	require.NoError(t, store.Delete(context.Background(), laptop1.GetId()))
+	require.ErrorIs(t, store.Delete(context.Background(), laptop1.GetId()), service.ErrNotFound)
+	require.ErrorIs(t, store.Update(context.Background(), laptop1), service.ErrNotFound)
+
+	laptop, err := store.Find(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop)
+	laptop, err = backend.Find(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.NotNil(t, laptop.GetDeletedAt(), "the deleted laptop is kept")
+	require.Equal(t, []string{laptop2.GetId()}, listAll(t, store, 1))
+
+	filter := &pb.Filter{Expression: &pb.Expression{Node: &pb.Expression_And{And: &pb.Expression_List{}}}}
+	count, err := store.Count(context.Background(), filter)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+	filter.IncludeDeleted = true
+	count, err = store.Count(context.Background(), filter)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	restored, err := store.Restore(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.Nil(t, restored.GetDeletedAt())
+	_, err = store.Restore(context.Background(), laptop1.GetId())
+	require.ErrorIs(t, err, service.ErrNotFound, "the laptop is not deleted anymore")
+	laptop, err = store.Find(context.Background(), laptop1.GetId())
+	require.NoError(t, err)
+	require.NotNil(t, laptop)
+}
+
+func TestSoftDeleteLaptopStorePurge(t *testing.T) {
+	t.Parallel()
+
+	backend := service.NewTenantLaptopStore(func(string) (service.LaptopStore, error) {
+		return service.NewInMemoryLaptopStore(), nil
+	})
+	store := service.NewSoftDeleteLaptopStore(backend, 0).ForTenant("acme")
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop))
+	require.NoError(t, store.Delete(context.Background(), laptop.GetId()))
+
+	_, err := store.(service.LaptopRestorer).Restore(context.Background(), laptop.GetId())
+	require.ErrorIs(t, err, service.ErrNotFound, "the retention window has passed")
+
+	purged, err := store.(*service.SoftDeleteLaptopStore).Purge(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+
+	laptop, err = backend.ForTenant("acme").Find(context.Background(), laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, laptop)
+}
+
+func TestServerRestoreLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	store := service.NewSoftDeleteLaptopStore(service.NewInMemoryLaptopStore(), time.Hour)
+	require.NoError(t, store.Save(context.Background(), laptop))
+	server := service.NewLaptopServer(store, nil, nil)
+
+	_, err := server.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.GetId()})
+	require.NoError(t, err)
+
+	res, err := server.RestoreLaptop(context.Background(), &pb.RestoreLaptopRequest{Id: laptop.GetId()})
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetId(), res.GetLaptop().GetId())
+
+	_, err = server.RestoreLaptop(context.Background(), &pb.RestoreLaptopRequest{Id: laptop.GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	server = service.NewLaptopServer(service.NewInMemoryLaptopStore(), nil, nil)
+	_, err = server.RestoreLaptop(context.Background(), &pb.RestoreLaptopRequest{Id: laptop.GetId()})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
@@ -0,0 +1,473 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"grpc_app/migration"
+	"grpc_app/pb"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	// sqlite registers the driver of SQLiteDriver.
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDriver is the database/sql driver name of SQLite, linked into the binary
+// with the pure Go driver of modernc.org/sqlite.
+const SQLiteDriver = "sqlite"
+
+// SQLLaptopStore stores laptops in the laptops table of a SQL database
+// created by the migrations of the migration package. The laptop is stored
+// as a protobuf blob, next to the columns of the filter fields used by Search.
+type SQLLaptopStore struct {
+	db      *sql.DB
+	dialect migration.Dialect
+	tenant  string
+}
+
+// NewSQLLaptopStore returns a new SQLLaptopStore of the database, whose schema must be up to date.
+func NewSQLLaptopStore(db *sql.DB, dialect migration.Dialect) *SQLLaptopStore {
+	return &SQLLaptopStore{db: db, dialect: dialect}
+}
+
+// ForTenant returns the laptop store of the tenant, sharing the same table.
+func (store *SQLLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &SQLLaptopStore{db: store.db, dialect: store.dialect, tenant: tenant}
+}
+
+// sqlColumns returns the filter fields stored in columns, in a stable order.
+func sqlColumns() []string {
+	names := make([]string, 0, len(filterFields))
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sqlQueryer runs the queries of SQLLaptopStore, either on the database or in a transaction.
+type sqlQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Save saves the laptop to the store
+func (store *SQLLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	return store.insert(ctx, store.db, laptop)
+}
+
+// SaveBatch saves the laptops to the store in a single transaction
+func (store *SQLLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	err := checkBatchIDs(laptops)
+	if err != nil {
+		return err
+	}
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, laptop := range laptops {
+		err := store.insert(ctx, tx, laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("cannot commit transaction: %w", err)
+	}
+	return nil
+}
+
+// insert inserts the laptop with the queryer, and returns ErrAlreadyExist if its ID exists.
+func (store *SQLLaptopStore) insert(ctx context.Context, queryer sqlQueryer, laptop *pb.Laptop) error {
+	existing, err := store.find(ctx, queryer, laptop.GetId())
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrAlreadyExist
+	}
+
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	columns, args := sqlValues(laptop, data)
+	columns = append([]string{"tenant"}, columns...)
+	args = append([]interface{}{store.tenant}, args...)
+
+	query := fmt.Sprintf(
+		"INSERT INTO laptops (%s) VALUES (%s)",
+		strings.Join(columns, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "),
+	)
+	_, err = queryer.ExecContext(ctx, store.dialect.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("cannot insert laptop: %w", err)
+	}
+
+	return nil
+}
+
+// Update replaces the laptop with the same ID and version in the store
+func (store *SQLLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	next := nextVersion(laptop)
+	data, err := proto.Marshal(next)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	columns, args := sqlValues(next, data)
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = column + " = ?"
+	}
+	query := fmt.Sprintf("UPDATE laptops SET %s WHERE tenant = ? AND id = ? AND version = ?", strings.Join(assignments, ", "))
+	args = append(args, store.tenant, laptop.GetId(), int64(laptop.GetVersion()))
+
+	result, err := store.db.ExecContext(ctx, store.dialect.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("cannot update laptop: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot update laptop: %w", err)
+	}
+	if updated == 0 {
+		// The version always changes, so no row is updated if the laptop doesn't exist or has another version.
+		existing, err := store.Find(ctx, laptop.GetId())
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+
+	laptop.Version = next.Version
+	return nil
+}
+
+// Delete deletes the laptop with the ID from the store
+func (store *SQLLaptopStore) Delete(ctx context.Context, id string) error {
+	result, err := store.db.ExecContext(
+		ctx,
+		store.dialect.Rebind("DELETE FROM laptops WHERE tenant = ? AND id = ?"),
+		store.tenant, id,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot delete laptop: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot delete laptop: %w", err)
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// sqlValues returns the columns of the laptop, except its tenant, and their values.
+func sqlValues(laptop *pb.Laptop, data []byte) ([]string, []interface{}) {
+	updatedAt := time.Now().UTC()
+	if laptop.GetUpdatedAt() != nil {
+		updatedAt = laptop.GetUpdatedAt().AsTime()
+	}
+
+	columns := []string{"data", "updated_at", "version"}
+	args := []interface{}{data, updatedAt, int64(laptop.GetVersion())}
+	for _, name := range sqlColumns() {
+		field := filterFields[name]
+		value := field.value(laptop)
+		if bits, ok := value.(uint64); ok {
+			value = int64(bits)
+		}
+		columns = append(columns, field.column)
+		args = append(args, value)
+	}
+	return columns, args
+}
+
+// Find finds a laptop by ID
+func (store *SQLLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	return store.find(ctx, store.db, id)
+}
+
+// find finds a laptop by ID with the queryer.
+func (store *SQLLaptopStore) find(ctx context.Context, queryer sqlQueryer, id string) (*pb.Laptop, error) {
+	var data []byte
+	err := queryer.QueryRowContext(
+		ctx,
+		store.dialect.Rebind("SELECT data FROM laptops WHERE tenant = ? AND id = ?"),
+		store.tenant, id,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot select laptop: %w", err)
+	}
+
+	return unmarshalLaptop(data)
+}
+
+// List returns a page of laptops in ID order
+func (store *SQLLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", err
+	}
+	after, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := store.db.QueryContext(
+		ctx,
+		store.dialect.Rebind("SELECT data FROM laptops WHERE tenant = ? AND id > ? ORDER BY id LIMIT ?"),
+		store.tenant, after, pageSize+1,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot select laptops: %w", err)
+	}
+	defer rows.Close()
+
+	var laptops []*pb.Laptop
+	for rows.Next() {
+		var data []byte
+		err := rows.Scan(&data)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot scan laptop: %w", err)
+		}
+
+		laptop, err := unmarshalLaptop(data)
+		if err != nil {
+			return nil, "", err
+		}
+		laptops = append(laptops, laptop)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	laptops, nextPageToken := nextPage(laptops, pageSize)
+	return laptops, nextPageToken, nil
+}
+
+// Count returns the number of laptops matching the filter
+func (store *SQLLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	if filter.GetText() != "" {
+		return countBySearch(ctx, store, filter)
+	}
+
+	where, args, err := FilterToSQL(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "SELECT COUNT(*) FROM laptops WHERE tenant = ?"
+	if where != "" {
+		query += " AND " + where
+	}
+
+	var count int64
+	err = store.db.QueryRowContext(
+		ctx,
+		store.dialect.Rebind(query),
+		append([]interface{}{store.tenant}, args...)...,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("cannot count laptops: %w", err)
+	}
+	return count, nil
+}
+
+// Stats returns the statistics of the laptops matching the filter, grouped by the database
+func (store *SQLLaptopStore) Stats(ctx context.Context, filter *pb.Filter) (*pb.CatalogStats, error) {
+	if filter.GetText() != "" {
+		return statsBySearch(ctx, store, filter)
+	}
+
+	where, args, err := FilterToSQL(filter)
+	if err != nil {
+		return nil, err
+	}
+	if where != "" {
+		where = "tenant = ? AND " + where
+	} else {
+		where = "tenant = ?"
+	}
+	args = append([]interface{}{store.tenant}, args...)
+
+	stats := &pb.CatalogStats{
+		CountByBrand:    make(map[string]int64),
+		CountByCpuCores: make(map[uint32]int64),
+		CountByRamGb:    make(map[uint64]int64),
+	}
+	var minPrice, avgPrice, maxPrice sql.NullFloat64
+	err = store.db.QueryRowContext(
+		ctx,
+		store.dialect.Rebind("SELECT COUNT(*), MIN(price_usd), AVG(price_usd), MAX(price_usd) FROM laptops WHERE "+where),
+		args...,
+	).Scan(&stats.Count, &minPrice, &avgPrice, &maxPrice)
+	if err != nil {
+		return nil, fmt.Errorf("cannot aggregate laptops: %w", err)
+	}
+	stats.MinPriceUsd = minPrice.Float64
+	stats.AvgPriceUsd = avgPrice.Float64
+	stats.MaxPriceUsd = maxPrice.Float64
+
+	var brand string
+	err = store.groupCount(ctx, "brand", where, args, &brand, func(count int64) {
+		stats.CountByBrand[brand] += count
+	})
+	if err != nil {
+		return nil, err
+	}
+	var cores uint32
+	err = store.groupCount(ctx, "cpu_number_cores", where, args, &cores, func(count int64) {
+		stats.CountByCpuCores[cores] += count
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ramBits uint64
+	err = store.groupCount(ctx, "ram_bits", where, args, &ramBits, func(count int64) {
+		stats.CountByRamGb[ramGB(ramBits)] += count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// groupCount counts the laptops matching the where clause by value of the column,
+// and calls add with the count of each value, scanned into value.
+func (store *SQLLaptopStore) groupCount(
+	ctx context.Context,
+	column string,
+	where string,
+	args []interface{},
+	value interface{},
+	add func(count int64),
+) error {
+	query := fmt.Sprintf("SELECT %s, COUNT(*) FROM laptops WHERE %s GROUP BY %s", column, where, column)
+	rows, err := store.db.QueryContext(ctx, store.dialect.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("cannot group laptops by %s: %w", column, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count int64
+		err := rows.Scan(value, &count)
+		if err != nil {
+			return fmt.Errorf("cannot group laptops by %s: %w", column, err)
+		}
+		add(count)
+	}
+	return rows.Err()
+}
+
+// Search searches for laptops with filter, returns one by one via the found function.
+func (store *SQLLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	where, args, err := FilterToSQL(filter)
+	if err != nil {
+		return err
+	}
+
+	query := "SELECT data FROM laptops WHERE tenant = ?"
+	if where != "" {
+		query += " AND " + where
+	}
+	orderBy, err := sqlOrderBy(filter.GetSortBy())
+	if err != nil {
+		return err
+	}
+	query += orderBy
+
+	rows, err := store.db.QueryContext(ctx, store.dialect.Rebind(query), append([]interface{}{store.tenant}, args...)...)
+	if err != nil {
+		return fmt.Errorf("cannot select laptops: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		err := rows.Scan(&data)
+		if err != nil {
+			return fmt.Errorf("cannot scan laptop: %w", err)
+		}
+
+		laptop, err := unmarshalLaptop(data)
+		if err != nil {
+			return err
+		}
+		// The text is matched here, as the dialects have no common full-text search.
+		if !matchesText(filter.GetText(), laptop) {
+			continue
+		}
+
+		err = found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// sqlOrderBy returns the ORDER BY clause of the sort, or an empty string if there is no sort.
+func sqlOrderBy(sortBy []*pb.Sort) (string, error) {
+	if len(sortBy) == 0 {
+		return "", nil
+	}
+	err := ValidateSort(sortBy)
+	if err != nil {
+		return "", err
+	}
+
+	columns := make([]string, 0, len(sortBy)+1)
+	for _, sort := range sortBy {
+		column := filterFields[sortFields[sort.GetField()]].column
+		if sort.GetDescending() {
+			column += " DESC"
+		}
+		columns = append(columns, column)
+	}
+	return " ORDER BY " + strings.Join(append(columns, "id"), ", "), nil
+}
+
+func unmarshalLaptop(data []byte) (*pb.Laptop, error) {
+	laptop := &pb.Laptop{}
+	err := proto.Unmarshal(data, laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal laptop: %w", err)
+	}
+	return laptop, nil
+}
+
+// unmarshalLaptopIfAny unmarshals the laptop like unmarshalLaptop, or returns nil if there is no data.
+func unmarshalLaptopIfAny(data []byte) (*pb.Laptop, error) {
+	if data == nil {
+		return nil, nil
+	}
+	return unmarshalLaptop(data)
+}
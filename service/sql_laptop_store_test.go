@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"grpc_app/migration"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// openSQLiteDatabase opens a new SQLite database with an up to date schema.
+func openSQLiteDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open(service.SQLiteDriver, filepath.Join(t.TempDir(), "laptops.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	migrator, err := migration.NewMigrator(db, migration.SQLite)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func TestSQLLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewSQLLaptopStore(openSQLiteDatabase(t), migration.SQLite)
+	ctx := context.Background()
+
+	cheap := sample.NewLaptop()
+	cheap.PriceUsd = 1000
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 5000
+	require.NoError(t, store.Save(ctx, cheap))
+	require.ErrorIs(t, store.Save(ctx, cheap), service.ErrAlreadyExist)
+	batch := sample.NewLaptop()
+	require.ErrorIs(t, store.SaveBatch(ctx, []*pb.Laptop{batch, cheap}), service.ErrAlreadyExist)
+	found, err := store.Find(ctx, batch.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found, "a failed batch saves none of its laptops")
+	require.NoError(t, store.SaveBatch(ctx, []*pb.Laptop{expensive}))
+
+	require.NoError(t, store.Update(ctx, expensive))
+	require.Equal(t, uint64(1), expensive.GetVersion())
+	stale := proto.Clone(expensive).(*pb.Laptop)
+	stale.Version = 0
+	require.ErrorIs(t, store.Update(ctx, stale), service.ErrVersionConflict)
+	require.ErrorIs(t, store.Update(ctx, sample.NewLaptop()), service.ErrNotFound)
+
+	found, err = store.Find(ctx, expensive.GetId())
+	require.NoError(t, err)
+	require.True(t, proto.Equal(expensive, found))
+	require.ElementsMatch(t, []string{cheap.GetId(), expensive.GetId()}, listAll(t, store, 1))
+
+	other := store.ForTenant("acme")
+	require.NoError(t, other.Save(ctx, sample.NewLaptop()))
+	found, err = other.Find(ctx, cheap.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	var ids []string
+	err = store.Search(ctx, &pb.Filter{MaxPriceUsd: 2000}, func(laptop *pb.Laptop) error {
+		ids = append(ids, laptop.GetId())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{cheap.GetId()}, ids)
+	count, err := store.Count(ctx, &pb.Filter{MaxPriceUsd: 1e6})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+
+	require.NoError(t, store.Delete(ctx, cheap.GetId()))
+	require.ErrorIs(t, store.Delete(ctx, cheap.GetId()), service.ErrNotFound)
+}
+
+func TestSQLAuditSink(t *testing.T) {
+	t.Parallel()
+
+	sink := service.NewSQLAuditSink(openSQLiteDatabase(t), migration.SQLite)
+	ctx := context.Background()
+
+	oldEntry := &pb.AuditEntry{Time: timestamppb.New(time.Now().Add(-100 * 24 * time.Hour)), LaptopId: "laptop-1"}
+	newEntry := &pb.AuditEntry{Time: timestamppb.Now(), LaptopId: "laptop-1"}
+	require.NoError(t, sink.Append(ctx, oldEntry))
+	require.NoError(t, sink.Append(ctx, newEntry))
+	entries, err := sink.Query(ctx, "", "laptop-1")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	before := time.Now().Add(-90 * 24 * time.Hour)
+	pruned, err := sink.Prune(ctx, before, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, pruned)
+	pruned, err = sink.Prune(ctx, before, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, pruned)
+
+	entries, err = sink.Query(ctx, "", "laptop-1")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, newEntry.GetTime().AsTime(), entries[0].GetTime().AsTime())
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"grpc_app/logging"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// StoreHealth sets the status of the services of a health server from the reachability
+// of the laptop store they depend on, so the server stops receiving traffic when its store is out.
+type StoreHealth struct {
+	store    LaptopStore
+	health   *health.Server
+	timeout  time.Duration
+	services []string
+	serving  bool
+	logger   *logging.Logger
+}
+
+// NewStoreHealth returns a new StoreHealth of the services, and of the server as a whole, which
+// are serving while the store answers in time. The health server is only updated by Check and Run.
+func NewStoreHealth(store LaptopStore, healthServer *health.Server, timeout time.Duration, services ...string) *StoreHealth {
+	return &StoreHealth{
+		store:    store,
+		health:   healthServer,
+		timeout:  timeout,
+		services: append([]string{""}, services...),
+		logger:   logging.Default(),
+	}
+}
+
+// SetLogger replaces the logger of the store health, the default logger by default. It must be called before Run.
+func (storeHealth *StoreHealth) SetLogger(logger *logging.Logger) {
+	storeHealth.logger = logger
+}
+
+// Check probes the store by listing a laptop, and sets the status of the services from the result.
+func (storeHealth *StoreHealth) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, storeHealth.timeout)
+	defer cancel()
+
+	_, _, err := storeHealth.store.List(ctx, 1, "")
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, service := range storeHealth.services {
+		storeHealth.health.SetServingStatus(service, status)
+	}
+
+	if serving := err == nil; serving != storeHealth.serving {
+		storeHealth.logger.Info("laptop store reachability changed", "reachable", serving)
+		storeHealth.serving = serving
+	}
+	return err
+}
+
+// Run checks the store every interval until the context is done.
+func (storeHealth *StoreHealth) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		err := storeHealth.Check(ctx)
+		if err != nil && ctx.Err() == nil {
+			storeHealth.logger.Warn("cannot reach laptop store", "error", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"grpc_app/pb"
+	"grpc_app/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// unreachableLaptopStore fails to list the laptops while it is down.
+type unreachableLaptopStore struct {
+	service.LaptopStore
+	down bool
+}
+
+func (store *unreachableLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	if store.down {
+		return nil, "", errors.New("connection refused")
+	}
+	return store.LaptopStore.List(ctx, pageSize, pageToken)
+}
+
+func TestStoreHealth(t *testing.T) {
+	t.Parallel()
+
+	store := &unreachableLaptopStore{LaptopStore: service.NewInMemoryLaptopStore()}
+	healthServer := health.NewServer()
+	storeHealth := service.NewStoreHealth(store, healthServer, time.Second, "laptops")
+
+	requireStatus := func(expected healthpb.HealthCheckResponse_ServingStatus) {
+		for _, name := range []string{"", "laptops"} {
+			res, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: name})
+			require.NoError(t, err)
+			require.Equal(t, expected, res.GetStatus())
+		}
+	}
+
+	require.NoError(t, storeHealth.Check(context.Background()))
+	requireStatus(healthpb.HealthCheckResponse_SERVING)
+
+	store.down = true
+	require.Error(t, storeHealth.Check(context.Background()))
+	requireStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	store.down = false
+	require.NoError(t, storeHealth.Check(context.Background()))
+	requireStatus(healthpb.HealthCheckResponse_SERVING)
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"sync"
+)
+
+// tenantHeader is the metadata key that selects the tenant of unauthenticated calls.
+const tenantHeader = "x-tenant-id"
+
+// maxTenantLength is the maximum length of a tenant ID.
+const maxTenantLength = 64
+
+// validTenant returns whether the tenant ID is made of at most maxTenantLength ASCII letters,
+// digits, dots, dashes and underscores, so that it can safely name the files and keys of its store.
+func validTenant(tenant string) bool {
+	if len(tenant) > maxTenantLength {
+		return false
+	}
+	for _, c := range tenant {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a context holding the tenant ID.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant ID of the context, or the default tenant "" if there is none.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// TenantScopedStore is implemented by laptop stores that isolate the laptops of each tenant.
+type TenantScopedStore interface {
+	// ForTenant returns the laptop store of the tenant.
+	ForTenant(tenant string) LaptopStore
+}
+
+// ErrTooManyTenants is returned by the store of a new tenant when the store already has its maximum
+// number of tenants.
+var ErrTooManyTenants = errors.New("too many tenants")
+
+// TenantLaptopStore keeps a separate laptop store for each tenant,
+// so that one tenant can never find or search the laptops of another one.
+// The tenant is only selected by ForTenant: the LaptopStore methods of
+// TenantLaptopStore itself always use the store of the default tenant "",
+// whatever the tenant of their context.
+type TenantLaptopStore struct {
+	mutex      sync.Mutex
+	newStore   func(tenant string) (LaptopStore, error)
+	stores     map[string]LaptopStore
+	maxTenants int
+}
+
+// NewTenantLaptopStore returns a new TenantLaptopStore that creates
+// the store of each tenant with newStore the first time it's used.
+func NewTenantLaptopStore(newStore func(tenant string) (LaptopStore, error)) *TenantLaptopStore {
+	return &TenantLaptopStore{
+		newStore: newStore,
+		stores:   make(map[string]LaptopStore),
+	}
+}
+
+// SetMaxTenants limits the number of tenants whose store is created, or removes the limit
+// if max is not positive. The stores are never released, so the limit bounds the memory,
+// files and goroutines kept for the tenants requested by the clients.
+func (store *TenantLaptopStore) SetMaxTenants(max int) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.maxTenants = max
+}
+
+// ForTenant returns the laptop store of the tenant. If the store can't be created, the returned store
+// fails every operation with the error, e.g. ErrTooManyTenants, and the creation is retried on the next call.
+func (store *TenantLaptopStore) ForTenant(tenant string) LaptopStore {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	tenantStore := store.stores[tenant]
+	if tenantStore == nil {
+		if store.maxTenants > 0 && len(store.stores) >= store.maxTenants {
+			return failedLaptopStore{err: fmt.Errorf("cannot create the store of tenant %q: %w", tenant, ErrTooManyTenants)}
+		}
+
+		var err error
+		tenantStore, err = store.newStore(tenant)
+		if err != nil {
+			return failedLaptopStore{err: fmt.Errorf("cannot create the store of tenant %q: %w", tenant, err)}
+		}
+		store.stores[tenant] = tenantStore
+	}
+	return tenantStore
+}
+
+// Save saves the laptop to the store of the default tenant.
+func (store *TenantLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	return store.ForTenant("").Save(ctx, laptop)
+}
+
+// Update updates the laptop in the store of the default tenant.
+func (store *TenantLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	return store.ForTenant("").Update(ctx, laptop)
+}
+
+// Delete deletes the laptop from the store of the default tenant.
+func (store *TenantLaptopStore) Delete(ctx context.Context, id string) error {
+	return store.ForTenant("").Delete(ctx, id)
+}
+
+// List lists the laptops of the store of the default tenant.
+func (store *TenantLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	return store.ForTenant("").List(ctx, pageSize, pageToken)
+}
+
+// SaveBatch saves the laptops to the store of the default tenant.
+func (store *TenantLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	return store.ForTenant("").SaveBatch(ctx, laptops)
+}
+
+// Count counts the laptops of the store of the default tenant.
+func (store *TenantLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	return store.ForTenant("").Count(ctx, filter)
+}
+
+// Stats returns the statistics of the laptops of the store of the default tenant.
+func (store *TenantLaptopStore) Stats(ctx context.Context, filter *pb.Filter) (*pb.CatalogStats, error) {
+	return aggregateLaptops(ctx, store.ForTenant(""), filter)
+}
+
+// Tenants returns the tenants whose store was created, in no particular order.
+func (store *TenantLaptopStore) Tenants() []string {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	tenants := make([]string, 0, len(store.stores))
+	for tenant := range store.stores {
+		tenants = append(tenants, tenant)
+	}
+	return tenants
+}
+
+// Reindex rebuilds the indexes of the stores of all the tenants, and returns the number of laptops
+// indexed across them.
+func (store *TenantLaptopStore) Reindex(ctx context.Context) (int, error) {
+	store.mutex.Lock()
+	stores := make([]LaptopStore, 0, len(store.stores))
+	for _, tenantStore := range store.stores {
+		stores = append(stores, tenantStore)
+	}
+	store.mutex.Unlock()
+
+	indexed := 0
+	for _, tenantStore := range stores {
+		n, err := reindexLaptops(ctx, tenantStore)
+		indexed += n
+		if err != nil {
+			return indexed, err
+		}
+	}
+	return indexed, nil
+}
+
+// Find finds a laptop by ID in the store of the default tenant.
+func (store *TenantLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	return store.ForTenant("").Find(ctx, id)
+}
+
+// Search searches for laptops in the store of the default tenant.
+func (store *TenantLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.ForTenant("").Search(ctx, filter, found)
+}
+
+// failedLaptopStore is the store of a tenant that couldn't be created, failing every operation with err.
+type failedLaptopStore struct {
+	err error
+}
+
+func (store failedLaptopStore) Save(context.Context, *pb.Laptop) error        { return store.err }
+func (store failedLaptopStore) SaveBatch(context.Context, []*pb.Laptop) error { return store.err }
+func (store failedLaptopStore) Update(context.Context, *pb.Laptop) error      { return store.err }
+func (store failedLaptopStore) Delete(context.Context, string) error          { return store.err }
+
+func (store failedLaptopStore) Find(context.Context, string) (*pb.Laptop, error) {
+	return nil, store.err
+}
+
+func (store failedLaptopStore) List(context.Context, int, string) ([]*pb.Laptop, string, error) {
+	return nil, "", store.err
+}
+
+func (store failedLaptopStore) Count(context.Context, *pb.Filter) (int64, error) {
+	return 0, store.err
+}
+
+func (store failedLaptopStore) Search(context.Context, *pb.Filter, func(laptop *pb.Laptop) error) error {
+	return store.err
+}
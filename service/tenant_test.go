@@ -0,0 +1,93 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewTenantLaptopStore(func(tenant string) (service.LaptopStore, error) {
+		return service.NewInMemoryLaptopStore(), nil
+	})
+	server := service.NewLaptopServer(store, nil, nil)
+
+	ctxA := service.ContextWithTenant(context.Background(), "tenant-a")
+	ctxB := service.ContextWithTenant(context.Background(), "tenant-b")
+
+	laptop := sample.NewLaptop()
+	res, err := server.CreateLaptop(ctxA, &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	found, err := store.ForTenant("tenant-a").Find(context.Background(), res.GetId())
+	require.NoError(t, err)
+	require.NotNil(t, found)
+
+	found, err = store.ForTenant("tenant-b").Find(context.Background(), res.GetId())
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	// The same ID can be used by another tenant.
+	_, err = server.CreateLaptop(ctxB, &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	filter := &pb.Filter{MaxPriceUsd: 1e6}
+	count := 0
+	err = store.ForTenant("tenant-b").Search(ctxB, filter, func(laptop *pb.Laptop) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func TestTenantLaptopStoreCreationError(t *testing.T) {
+	t.Parallel()
+
+	fail := true
+	store := service.NewTenantLaptopStore(func(tenant string) (service.LaptopStore, error) {
+		if fail {
+			return nil, errors.New("cannot open journal")
+		}
+		return service.NewInMemoryLaptopStore(), nil
+	})
+
+	err := store.ForTenant("acme").Save(context.Background(), sample.NewLaptop())
+	require.ErrorContains(t, err, "cannot open journal")
+
+	// The failed store isn't kept, so the creation is retried.
+	fail = false
+	require.NoError(t, store.ForTenant("acme").Save(context.Background(), sample.NewLaptop()))
+}
+
+func TestTenantLaptopStoreMaxTenants(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewTenantLaptopStore(func(tenant string) (service.LaptopStore, error) {
+		return service.NewInMemoryLaptopStore(), nil
+	})
+	store.SetMaxTenants(2)
+	ctx := context.Background()
+
+	require.NoError(t, store.ForTenant("").Save(ctx, sample.NewLaptop()))
+	require.NoError(t, store.ForTenant("acme").Save(ctx, sample.NewLaptop()))
+	err := store.ForTenant("globex").Save(ctx, sample.NewLaptop())
+	require.ErrorIs(t, err, service.ErrTooManyTenants)
+
+	// The existing tenants keep their stores.
+	count, err := store.ForTenant("acme").Count(ctx, &pb.Filter{MaxPriceUsd: 1e6})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+
+	// The default tenant is used whatever the tenant of the context.
+	count, err = store.Count(service.ContextWithTenant(ctx, "acme"), &pb.Filter{MaxPriceUsd: 1e6})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
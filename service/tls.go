@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// TLSConfig contains the server-side TLS settings.
+type TLSConfig struct {
+	// CertFile is the PEM certificate chain of the server, its own certificate first.
+	CertFile string
+	// KeyFile is the PEM private key of the certificate of the server.
+	KeyFile string
+	// ClientCAFile is a PEM bundle of the CA certificates of the clients. If set, the clients
+	// must present a certificate issued by one of them.
+	ClientCAFile string
+}
+
+// LoadTLSCredentials returns the transport credentials built from the TLS config.
+func LoadTLSCredentials(config TLSConfig) (credentials.TransportCredentials, error) {
+	serverCert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if config.ClientCAFile != "" {
+		pemClientCA, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read client CA file: %w", err)
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(pemClientCA) {
+			return nil, fmt.Errorf("failed to add client CA's certificate")
+		}
+		tlsConfig.ClientCAs = certPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// ClientIdentityFromContext returns the common name of the verified certificate of the client
+// of the call, or "" if the client didn't present one.
+func ClientIdentityFromContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return ""
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+}
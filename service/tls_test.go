@@ -0,0 +1,209 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"grpc_app/client"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestServerTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	serverCredentials, err := service.LoadTLSCredentials(service.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	grpcServer := grpc.NewServer(grpc.Creds(serverCredentials))
+	laptopServer := service.NewLaptopServer(service.NewInMemoryLaptopStore(), nil, nil)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	clientCredentials, err := client.LoadTLSCredentials(client.TLSConfig{CAFile: caFile, ServerName: "localhost"})
+	require.NoError(t, err)
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(clientCredentials))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = pb.NewLaptopServiceClient(conn).CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.NoError(t, err)
+
+	_, err = service.LoadTLSCredentials(service.TLSConfig{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.pem")})
+	require.Error(t, err)
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCertFile, clientKeyFile := ca.issue(t, dir, "importer", x509.ExtKeyUsageClientAuth)
+
+	serverCredentials, err := service.LoadTLSCredentials(service.TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+	})
+	require.NoError(t, err)
+
+	identities := make(chan string, 1)
+	grpcServer := grpc.NewServer(grpc.Creds(serverCredentials), grpc.UnaryInterceptor(func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		identities <- service.ClientIdentityFromContext(ctx)
+		return handler(ctx, req)
+	}))
+	laptopServer := service.NewLaptopServer(service.NewInMemoryLaptopStore(), nil, nil)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	createLaptop := func(config client.TLSConfig) error {
+		clientCredentials, err := client.LoadTLSCredentials(config)
+		require.NoError(t, err)
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(clientCredentials))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = pb.NewLaptopServiceClient(conn).CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+		return err
+	}
+
+	err = createLaptop(client.TLSConfig{CAFile: caFile, ServerName: "localhost", CertFile: clientCertFile, KeyFile: clientKeyFile})
+	require.NoError(t, err)
+	require.Equal(t, "importer", <-identities)
+
+	err = createLaptop(client.TLSConfig{CAFile: caFile, ServerName: "localhost"})
+	require.Error(t, err)
+}
+
+func TestClientSPKIPins(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	serverCredentials, err := service.LoadTLSCredentials(service.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	grpcServer := grpc.NewServer(grpc.Creds(serverCredentials))
+	laptopServer := service.NewLaptopServer(service.NewInMemoryLaptopStore(), nil, nil)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	createLaptop := func(pins ...string) error {
+		clientCredentials, err := client.LoadTLSCredentials(client.TLSConfig{CAFile: caFile, ServerName: "localhost", SPKIPins: pins})
+		require.NoError(t, err)
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(clientCredentials))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = pb.NewLaptopServiceClient(conn).CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+		return err
+	}
+
+	otherPin := client.SPKIPin(newTestCA(t).cert)
+	require.NoError(t, createLaptop(otherPin, client.SPKIPin(ca.cert)), "the CA of the chain matches a pin")
+	err = createLaptop(otherPin)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no certificate matches the SPKI pins")
+
+	_, err = client.LoadTLSCredentials(client.TLSConfig{SPKIPins: []string{"not a pin"}})
+	require.Error(t, err)
+	_, err = client.LoadTLSCredentials(client.TLSConfig{SPKIPins: []string{"c2hvcnQ="}})
+	require.Error(t, err, "the pin is not a SHA-256 hash")
+}
+
+// testCA is a certificate authority issuing the certificates of the TLS tests.
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for localhost with the common name, and its key, to the directory.
+func (ca *testCA) issue(t *testing.T, dir string, commonName string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, commonName+".pem")
+	keyFile := filepath.Join(dir, commonName+"-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
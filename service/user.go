@@ -11,6 +11,7 @@ type User struct {
 	Username       string
 	HashedPassword string
 	Role           string
+	Tenant         string
 }
 
 // NewUser returns a new user
@@ -41,5 +42,6 @@ func (user *User) Clone() *User {
 		Username:       user.Username,
 		HashedPassword: user.HashedPassword,
 		Role:           user.Role,
+		Tenant:         user.Tenant,
 	}
 }
@@ -25,8 +25,8 @@ func NewInMemoryUserStore() *InMemoryUserStore {
 
 // Save saves a user to the store.
 func (store *InMemoryUserStore) Save(user *User) error {
-	store.mutex.RLock()
-	defer store.mutex.RUnlock()
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
 
 	if store.users[user.Username] != nil {
 		return ErrAlreadyExist
@@ -48,3 +48,16 @@ func (store *InMemoryUserStore) Find(username string) (*User, error) {
 
 	return user.Clone(), nil
 }
+
+// EraseUserData deletes the user from the store.
+func (store *InMemoryUserStore) EraseUserData(username string) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.users[username] == nil {
+		return 0, nil
+	}
+
+	delete(store.users, username)
+	return 1, nil
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"grpc_app/logging"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ViewKey identifies a laptop of a tenant in the view counters.
+type ViewKey struct {
+	Tenant   string
+	LaptopID string
+}
+
+// ViewCount is the number of times a laptop was viewed with get laptop,
+// and shown in search results.
+type ViewCount struct {
+	Views       uint64
+	Impressions uint64
+}
+
+// TrendingLaptop is a laptop with its view counts over a window.
+type TrendingLaptop struct {
+	LaptopID string
+	ViewCount
+}
+
+// ViewStore is an interface to store laptop view counts over time.
+type ViewStore interface {
+	// AddViews adds the view counts that happened until the given time.
+	AddViews(at time.Time, counts map[ViewKey]ViewCount) error
+	// Trending returns at most limit laptops of the tenant with the most views since the given time.
+	Trending(tenant string, since time.Time, limit int) ([]TrendingLaptop, error)
+}
+
+// ViewCounter counts laptop views in memory with sharded atomic counters,
+// so that hot laptops don't contend on a single lock, and flushes them to a view store.
+type ViewCounter struct {
+	store  ViewStore
+	shards []*viewShard
+	logger *logging.Logger
+}
+
+type viewShard struct {
+	mutex  sync.RWMutex
+	counts map[ViewKey]*viewCounts
+}
+
+type viewCounts struct {
+	views       uint64
+	impressions uint64
+}
+
+// NewViewCounter returns a new view counter with the given number of shards.
+func NewViewCounter(store ViewStore, shards int) *ViewCounter {
+	if shards < 1 {
+		shards = 1
+	}
+
+	counter := &ViewCounter{store: store, shards: make([]*viewShard, shards), logger: logging.Default()}
+	for i := range counter.shards {
+		counter.shards[i] = &viewShard{counts: make(map[ViewKey]*viewCounts)}
+	}
+	return counter
+}
+
+// SetLogger replaces the logger of the counter, the default logger by default. It must be called before Run.
+func (counter *ViewCounter) SetLogger(logger *logging.Logger) {
+	counter.logger = logger
+}
+
+// View counts a view of the laptop.
+func (counter *ViewCounter) View(key ViewKey) {
+	counter.add(key, 1, 0)
+}
+
+// Impression counts an appearance of the laptop in search results.
+func (counter *ViewCounter) Impression(key ViewKey) {
+	counter.add(key, 0, 1)
+}
+
+func (counter *ViewCounter) add(key ViewKey, views, impressions uint64) {
+	shard := counter.shard(key)
+
+	shard.mutex.RLock()
+	counts := shard.counts[key]
+	if counts != nil {
+		atomic.AddUint64(&counts.views, views)
+		atomic.AddUint64(&counts.impressions, impressions)
+		shard.mutex.RUnlock()
+		return
+	}
+	shard.mutex.RUnlock()
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	counts = shard.counts[key]
+	if counts == nil {
+		counts = &viewCounts{}
+		shard.counts[key] = counts
+	}
+	atomic.AddUint64(&counts.views, views)
+	atomic.AddUint64(&counts.impressions, impressions)
+}
+
+func (counter *ViewCounter) shard(key ViewKey) *viewShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(key.Tenant))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key.LaptopID))
+	return counter.shards[hash.Sum32()%uint32(len(counter.shards))]
+}
+
+// Flush writes the counts to the view store and resets them.
+// The counts are kept for the next flush if the store fails.
+func (counter *ViewCounter) Flush() error {
+	flushed := make(map[ViewKey]ViewCount)
+	for _, shard := range counter.shards {
+		shard.mutex.Lock()
+		counts := shard.counts
+		shard.counts = make(map[ViewKey]*viewCounts)
+		shard.mutex.Unlock()
+
+		for key, c := range counts {
+			flushed[key] = ViewCount{Views: c.views, Impressions: c.impressions}
+		}
+	}
+
+	if len(flushed) == 0 {
+		return nil
+	}
+
+	err := counter.store.AddViews(time.Now(), flushed)
+	if err != nil {
+		for key, c := range flushed {
+			counter.add(key, c.Views, c.Impressions)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Run flushes the counts every interval until the context is done, then flushes them one last time.
+func (counter *ViewCounter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := counter.Flush(); err != nil {
+				counter.logger.Error("cannot flush view counts", "error", err)
+			}
+			return
+		}
+
+		if err := counter.Flush(); err != nil {
+			counter.logger.Error("cannot flush view counts", "error", err)
+		}
+	}
+}
+
+// Trending returns at most limit laptops of the tenant with the most views since the given time.
+// The counts that are not flushed yet are not included.
+func (counter *ViewCounter) Trending(tenant string, since time.Time, limit int) ([]TrendingLaptop, error) {
+	return counter.store.Trending(tenant, since, limit)
+}
+
+// viewBucket is the time resolution of the in-memory view store.
+const viewBucket = time.Minute
+
+// InMemoryViewStore stores view counts in memory by minute, for a retention period.
+type InMemoryViewStore struct {
+	mutex     sync.RWMutex
+	retention time.Duration
+	buckets   map[time.Time]map[ViewKey]ViewCount
+}
+
+// NewInMemoryViewStore returns a new InMemoryViewStore that keeps the counts for the retention period.
+func NewInMemoryViewStore(retention time.Duration) *InMemoryViewStore {
+	return &InMemoryViewStore{
+		retention: retention,
+		buckets:   make(map[time.Time]map[ViewKey]ViewCount),
+	}
+}
+
+// AddViews adds the view counts that happened until the given time.
+func (store *InMemoryViewStore) AddViews(at time.Time, counts map[ViewKey]ViewCount) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	start := at.Truncate(viewBucket)
+	bucket := store.buckets[start]
+	if bucket == nil {
+		bucket = make(map[ViewKey]ViewCount)
+		store.buckets[start] = bucket
+	}
+
+	for key, count := range counts {
+		total := bucket[key]
+		total.Views += count.Views
+		total.Impressions += count.Impressions
+		bucket[key] = total
+	}
+
+	for start := range store.buckets {
+		if start.Add(viewBucket).Before(at.Add(-store.retention)) {
+			delete(store.buckets, start)
+		}
+	}
+
+	return nil
+}
+
+// Trending returns at most limit laptops of the tenant with the most views since the given time.
+// The laptops with the same number of views are sorted by impressions.
+func (store *InMemoryViewStore) Trending(tenant string, since time.Time, limit int) ([]TrendingLaptop, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	totals := make(map[string]ViewCount)
+	for start, bucket := range store.buckets {
+		if !start.Add(viewBucket).After(since) {
+			continue
+		}
+
+		for key, count := range bucket {
+			if key.Tenant != tenant {
+				continue
+			}
+			total := totals[key.LaptopID]
+			total.Views += count.Views
+			total.Impressions += count.Impressions
+			totals[key.LaptopID] = total
+		}
+	}
+
+	trending := make([]TrendingLaptop, 0, len(totals))
+	for id, total := range totals {
+		trending = append(trending, TrendingLaptop{LaptopID: id, ViewCount: total})
+	}
+
+	sort.Slice(trending, func(i, j int) bool {
+		a, b := trending[i], trending[j]
+		if a.Views != b.Views {
+			return a.Views > b.Views
+		}
+		if a.Impressions != b.Impressions {
+			return a.Impressions > b.Impressions
+		}
+		return a.LaptopID < b.LaptopID
+	})
+
+	if len(trending) > limit {
+		trending = trending[:limit]
+	}
+	return trending, nil
+}
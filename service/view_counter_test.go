@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestServerGetTrendingLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := service.NewInMemoryLaptopStore()
+	laptops := make([]*pb.Laptop, 3)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+		require.NoError(t, laptopStore.Save(context.Background(), laptops[i]))
+	}
+
+	viewCounter := service.NewViewCounter(service.NewInMemoryViewStore(time.Hour), 4)
+	server := service.NewLaptopServer(laptopStore, nil, nil, service.WithViewCounter(viewCounter))
+
+	views := []int{5, 20, 1}
+	var wg sync.WaitGroup
+	for i, n := range views {
+		for j := 0; j < n; j++ {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				_, err := server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: id})
+				require.NoError(t, err)
+			}(laptops[i].GetId())
+		}
+	}
+	wg.Wait()
+
+	res, err := server.GetTrendingLaptops(context.Background(), &pb.GetTrendingLaptopsRequest{})
+	require.NoError(t, err)
+	require.Empty(t, res.GetLaptops(), "counts are only visible once flushed")
+
+	require.NoError(t, viewCounter.Flush())
+
+	req := &pb.GetTrendingLaptopsRequest{Window: durationpb.New(time.Minute), Limit: 2}
+	res, err = server.GetTrendingLaptops(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 2)
+	require.Equal(t, laptops[1].GetId(), res.GetLaptops()[0].GetLaptop().GetId())
+	require.Equal(t, uint64(20), res.GetLaptops()[0].GetViews())
+	require.Equal(t, laptops[0].GetId(), res.GetLaptops()[1].GetLaptop().GetId())
+
+	// Another tenant has no views.
+	ctx := service.ContextWithTenant(context.Background(), "other")
+	res, err = server.GetTrendingLaptops(ctx, req)
+	require.NoError(t, err)
+	require.Empty(t, res.GetLaptops())
+}
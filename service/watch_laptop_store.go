@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"grpc_app/pb"
+	"sync"
+	"time"
+)
+
+// ErrInvalidResumeToken is returned when a resume token is not one of the events of the store.
+var ErrInvalidResumeToken = errors.New("invalid resume token")
+
+// ErrResumeTokenExpired is returned when the events after a resume token are no longer kept,
+// or were emitted by another store, e.g. before the server restarted.
+var ErrResumeTokenExpired = errors.New("resume token is expired")
+
+// watchHistorySize is the number of past events kept to resume the watches.
+const watchHistorySize = 1000
+
+// LaptopEventType is the type of change of a laptop.
+type LaptopEventType int
+
+const (
+	// LaptopCreated is the type of the events of the saved or restored laptops.
+	LaptopCreated LaptopEventType = iota + 1
+	// LaptopUpdated is the type of the events of the updated laptops.
+	LaptopUpdated
+	// LaptopDeleted is the type of the events of the deleted laptops, as they were before they were deleted,
+	// or with only an ID if they can't be found.
+	LaptopDeleted
+)
+
+// LaptopEvent is a change of a laptop in a store.
+type LaptopEvent struct {
+	Type   LaptopEventType
+	Tenant string
+	Laptop *pb.Laptop
+	// ResumeToken is the token to watch the store again after the event.
+	ResumeToken string
+}
+
+// LaptopWatcher is implemented by the laptop stores that emit the changes of their laptops.
+type LaptopWatcher interface {
+	// Watch sends the events of the laptops changed from now on to the channel, until stop is called.
+	// The events are not sent while the channel is full, so a slow watcher misses them.
+	Watch(events chan<- LaptopEvent) (stop func())
+	// WatchFrom is Watch, also returning the events emitted after the event of the resume token,
+	// or ErrResumeTokenExpired if they are no longer kept.
+	WatchFrom(resumeToken string, events chan<- LaptopEvent) (missed []LaptopEvent, stop func(), err error)
+}
+
+// WatchLaptopStore is a LaptopStore that emits the changes of the laptops written through it
+// to its watchers. The writes that don't go through the store, e.g. of other servers
+// sharing the same database, are not emitted.
+type WatchLaptopStore struct {
+	backend  LaptopStore
+	tenant   string
+	watchers *laptopWatchers
+}
+
+type laptopWatchers struct {
+	mutex    sync.Mutex
+	channels map[*laptopWatch]bool
+	// epoch identifies the store in the resume tokens, so that the tokens of another store,
+	// e.g. of the server before it restarted, are never mistaken for its own.
+	epoch    int64
+	sequence uint64
+	// history are the last events emitted, oldest first, to resume the watches.
+	history []LaptopEvent
+}
+
+type laptopWatch struct {
+	tenant     string
+	allTenants bool
+	events     chan<- LaptopEvent
+}
+
+// NewWatchLaptopStore returns a new WatchLaptopStore over the backend.
+func NewWatchLaptopStore(backend LaptopStore) *WatchLaptopStore {
+	return &WatchLaptopStore{
+		backend:  backend,
+		watchers: &laptopWatchers{channels: make(map[*laptopWatch]bool), epoch: time.Now().UnixNano()},
+	}
+}
+
+// ForTenant returns the store of the tenant, whose watchers only receive the events of the tenant.
+func (store *WatchLaptopStore) ForTenant(tenant string) LaptopStore {
+	return &WatchLaptopStore{backend: tenantStore(store.backend, tenant), tenant: tenant, watchers: store.watchers}
+}
+
+// Watch sends the events of the laptops of the tenant of the store to the channel
+func (store *WatchLaptopStore) Watch(events chan<- LaptopEvent) (stop func()) {
+	return store.watch(&laptopWatch{tenant: store.tenant, events: events})
+}
+
+// WatchAll sends the events of the laptops of all the tenants to the channel, until stop is called.
+func (store *WatchLaptopStore) WatchAll(events chan<- LaptopEvent) (stop func()) {
+	return store.watch(&laptopWatch{allTenants: true, events: events})
+}
+
+// WatchFrom sends the events of the laptops of the tenant of the store to the channel, and returns the events
+// of the tenant emitted after the event of the resume token, which the channel won't receive.
+func (store *WatchLaptopStore) WatchFrom(
+	resumeToken string,
+	events chan<- LaptopEvent,
+) (missed []LaptopEvent, stop func(), err error) {
+	var epoch int64
+	var sequence uint64
+	_, err = fmt.Sscanf(resumeToken, "%x-%d", &epoch, &sequence)
+	if err != nil || resumeToken != formatResumeToken(epoch, sequence) {
+		return nil, nil, ErrInvalidResumeToken
+	}
+
+	watchers := store.watchers
+	watchers.mutex.Lock()
+	defer watchers.mutex.Unlock()
+
+	if epoch != watchers.epoch {
+		return nil, nil, ErrResumeTokenExpired
+	}
+	if sequence > watchers.sequence {
+		return nil, nil, ErrInvalidResumeToken
+	}
+	// The history holds the events from watchers.sequence-len(history)+1 to watchers.sequence.
+	kept := uint64(len(watchers.history))
+	if watchers.sequence-sequence > kept {
+		return nil, nil, ErrResumeTokenExpired
+	}
+	for _, event := range watchers.history[kept-(watchers.sequence-sequence):] {
+		if event.Tenant == store.tenant {
+			missed = append(missed, event)
+		}
+	}
+	return missed, store.addWatch(&laptopWatch{tenant: store.tenant, events: events}), nil
+}
+
+func (store *WatchLaptopStore) watch(watch *laptopWatch) (stop func()) {
+	store.watchers.mutex.Lock()
+	defer store.watchers.mutex.Unlock()
+
+	return store.addWatch(watch)
+}
+
+// addWatch adds the watch to the watchers, whose mutex must be held.
+func (store *WatchLaptopStore) addWatch(watch *laptopWatch) (stop func()) {
+	store.watchers.channels[watch] = true
+
+	return func() {
+		store.watchers.mutex.Lock()
+		delete(store.watchers.channels, watch)
+		store.watchers.mutex.Unlock()
+	}
+}
+
+// formatResumeToken returns the resume token of the event with the sequence number in the store of the epoch.
+func formatResumeToken(epoch int64, sequence uint64) string {
+	return fmt.Sprintf("%x-%d", epoch, sequence)
+}
+
+// emit sends an event of the laptop to the watchers of the tenant of the store, and keeps it in the history.
+func (store *WatchLaptopStore) emit(eventType LaptopEventType, laptop *pb.Laptop) {
+	event := LaptopEvent{Type: eventType, Tenant: store.tenant, Laptop: deepCopy(laptop)}
+
+	store.watchers.mutex.Lock()
+	defer store.watchers.mutex.Unlock()
+
+	store.watchers.sequence++
+	event.ResumeToken = formatResumeToken(store.watchers.epoch, store.watchers.sequence)
+	if len(store.watchers.history) == watchHistorySize {
+		store.watchers.history = store.watchers.history[1:]
+	}
+	store.watchers.history = append(store.watchers.history, event)
+
+	for watch := range store.watchers.channels {
+		if !watch.allTenants && watch.tenant != store.tenant {
+			continue
+		}
+		select {
+		case watch.events <- event:
+		default:
+		}
+	}
+}
+
+// Save saves the laptop to the backend
+func (store *WatchLaptopStore) Save(ctx context.Context, laptop *pb.Laptop) error {
+	err := store.backend.Save(ctx, laptop)
+	if err != nil {
+		return err
+	}
+	store.emit(LaptopCreated, laptop)
+	return nil
+}
+
+// SaveBatch saves the laptops to the backend
+func (store *WatchLaptopStore) SaveBatch(ctx context.Context, laptops []*pb.Laptop) error {
+	err := store.backend.SaveBatch(ctx, laptops)
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		store.emit(LaptopCreated, laptop)
+	}
+	return nil
+}
+
+// Update updates the laptop in the backend
+func (store *WatchLaptopStore) Update(ctx context.Context, laptop *pb.Laptop) error {
+	err := store.backend.Update(ctx, laptop)
+	if err != nil {
+		return err
+	}
+	store.emit(LaptopUpdated, laptop)
+	return nil
+}
+
+// Delete deletes the laptop from the backend
+func (store *WatchLaptopStore) Delete(ctx context.Context, id string) error {
+	deleted, err := store.backend.Find(ctx, id)
+	if err != nil || deleted == nil {
+		deleted = &pb.Laptop{Id: id}
+	}
+
+	err = store.backend.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	store.emit(LaptopDeleted, deleted)
+	return nil
+}
+
+// Restore restores the deleted laptop in the backend, or returns ErrNotRestorable
+// if the backend doesn't keep the deleted laptops
+func (store *WatchLaptopStore) Restore(ctx context.Context, id string) (*pb.Laptop, error) {
+	restorer, ok := store.backend.(LaptopRestorer)
+	if !ok {
+		return nil, ErrNotRestorable
+	}
+
+	laptop, err := restorer.Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	store.emit(LaptopCreated, laptop)
+	return laptop, nil
+}
+
+// Find finds a laptop by ID in the backend
+func (store *WatchLaptopStore) Find(ctx context.Context, id string) (*pb.Laptop, error) {
+	return store.backend.Find(ctx, id)
+}
+
+// List lists the laptops of the backend
+func (store *WatchLaptopStore) List(ctx context.Context, pageSize int, pageToken string) ([]*pb.Laptop, string, error) {
+	return store.backend.List(ctx, pageSize, pageToken)
+}
+
+// Count counts the laptops of the backend
+func (store *WatchLaptopStore) Count(ctx context.Context, filter *pb.Filter) (int64, error) {
+	return store.backend.Count(ctx, filter)
+}
+
+// Reindex rebuilds the indexes of the backend
+func (store *WatchLaptopStore) Reindex(ctx context.Context) (int, error) {
+	return reindexLaptops(ctx, store.backend)
+}
+
+// Stats returns the statistics of the laptops of the backend
+func (store *WatchLaptopStore) Stats(ctx context.Context, filter *pb.Filter) (*pb.CatalogStats, error) {
+	return aggregateLaptops(ctx, store.backend, filter)
+}
+
+// Search searches for laptops in the backend
+func (store *WatchLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.backend.Search(ctx, filter, found)
+}
@@ -0,0 +1,98 @@
+package service_test
+
+import (
+	"context"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewWatchLaptopStore(service.NewInMemoryLaptopStore())
+	events := make(chan service.LaptopEvent, 10)
+	stop := store.Watch(events)
+
+	other := make(chan service.LaptopEvent, 10)
+	defer store.ForTenant("acme").(service.LaptopWatcher).Watch(other)()
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.NoError(t, store.SaveBatch(context.Background(), []*pb.Laptop{laptop1, laptop2}))
+	require.ErrorIs(t, store.Save(context.Background(), laptop1), service.ErrAlreadyExist)
+	require.NoError(t, store.Update(context.Background(), laptop1))
+	require.NoError(t, store.Delete(context.Background(), laptop2.GetId()))
+
+	expected := []struct {
+		eventType service.LaptopEventType
+		id        string
+	}{
+		{service.LaptopCreated, laptop1.GetId()},
+		{service.LaptopCreated, laptop2.GetId()},
+		{service.LaptopUpdated, laptop1.GetId()},
+		{service.LaptopDeleted, laptop2.GetId()},
+	}
+	require.Len(t, events, len(expected), "the failed save is not emitted")
+	for _, e := range expected {
+		event := <-events
+		require.Equal(t, e.eventType, event.Type)
+		require.Equal(t, e.id, event.Laptop.GetId())
+	}
+	require.Len(t, other, 0, "the events of another tenant are not emitted")
+
+	stop()
+	require.NoError(t, store.Save(context.Background(), sample.NewLaptop()))
+	require.Len(t, events, 0)
+}
+
+func TestWatchLaptopStoreResume(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewWatchLaptopStore(service.NewInMemoryLaptopStore())
+	events := make(chan service.LaptopEvent, 10)
+	defer store.Watch(events)()
+
+	laptop1 := sample.NewLaptop()
+	laptop2 := sample.NewLaptop()
+	require.NoError(t, store.Save(context.Background(), laptop1))
+	require.NoError(t, store.ForTenant("acme").Save(context.Background(), sample.NewLaptop()))
+	require.NoError(t, store.Save(context.Background(), laptop2))
+	first := <-events
+	last := <-events
+	require.NotEqual(t, first.ResumeToken, last.ResumeToken)
+
+	resumed := make(chan service.LaptopEvent, 10)
+	missed, stop, err := store.WatchFrom(first.ResumeToken, resumed)
+	require.NoError(t, err)
+	require.Len(t, missed, 1, "the events of another tenant are not missed")
+	require.Equal(t, laptop2.GetId(), missed[0].Laptop.GetId())
+	require.Equal(t, last.ResumeToken, missed[0].ResumeToken)
+
+	require.NoError(t, store.Update(context.Background(), laptop1))
+	require.Equal(t, laptop1.GetId(), (<-resumed).Laptop.GetId())
+	stop()
+
+	missed, stop, err = store.WatchFrom(last.ResumeToken, resumed)
+	require.NoError(t, err)
+	require.Len(t, missed, 1)
+	stop()
+
+	_, _, err = store.WatchFrom("not-a-token", resumed)
+	require.ErrorIs(t, err, service.ErrInvalidResumeToken)
+
+	other := service.NewWatchLaptopStore(service.NewInMemoryLaptopStore())
+	_, _, err = other.WatchFrom(first.ResumeToken, resumed)
+	require.ErrorIs(t, err, service.ErrResumeTokenExpired, "the token of another store is expired")
+
+	laptops := make([]*pb.Laptop, 1000)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+	}
+	require.NoError(t, store.SaveBatch(context.Background(), laptops))
+	_, _, err = store.WatchFrom(first.ResumeToken, resumed)
+	require.ErrorIs(t, err, service.ErrResumeTokenExpired, "the events after the token are no longer kept")
+}
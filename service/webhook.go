@@ -0,0 +1,307 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"grpc_app/logging"
+	"grpc_app/pb"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// The headers of the webhook requests.
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	// WebhookSignatureHeader is the header of the signature of the payload, see SignWebhookPayload.
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookAttemptTimeout is the time given to a webhook to answer a request.
+const webhookAttemptTimeout = 10 * time.Second
+
+// ErrWebhookNotFound is returned when a webhook is not found.
+var ErrWebhookNotFound = errors.New("webhook is not found")
+
+// WebhookStore is an interface to store the registered webhooks.
+type WebhookStore interface {
+	// Add adds the webhook to the store.
+	Add(webhook *pb.Webhook) error
+	// Delete deletes the webhook by ID, or returns ErrWebhookNotFound.
+	Delete(id string) error
+	// List returns the webhooks, oldest first.
+	List() ([]*pb.Webhook, error)
+}
+
+// InMemoryWebhookStore stores the webhooks in memory, so they must be registered again after a restart.
+type InMemoryWebhookStore struct {
+	mutex    sync.RWMutex
+	webhooks map[string]*pb.Webhook
+}
+
+// NewInMemoryWebhookStore returns a new InMemoryWebhookStore
+func NewInMemoryWebhookStore() *InMemoryWebhookStore {
+	return &InMemoryWebhookStore{webhooks: make(map[string]*pb.Webhook)}
+}
+
+// Add adds a copy of the webhook to the store
+func (store *InMemoryWebhookStore) Add(webhook *pb.Webhook) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.webhooks[webhook.GetId()] != nil {
+		return ErrAlreadyExist
+	}
+	store.webhooks[webhook.GetId()] = proto.Clone(webhook).(*pb.Webhook)
+	return nil
+}
+
+// Delete deletes the webhook from the store
+func (store *InMemoryWebhookStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.webhooks[id] == nil {
+		return ErrWebhookNotFound
+	}
+	delete(store.webhooks, id)
+	return nil
+}
+
+// List returns copies of the webhooks of the store
+func (store *InMemoryWebhookStore) List() ([]*pb.Webhook, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	webhooks := make([]*pb.Webhook, 0, len(store.webhooks))
+	for _, webhook := range store.webhooks {
+		webhooks = append(webhooks, proto.Clone(webhook).(*pb.Webhook))
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].GetCreatedAt().AsTime().Equal(webhooks[j].GetCreatedAt().AsTime()) {
+			return webhooks[i].GetCreatedAt().AsTime().Before(webhooks[j].GetCreatedAt().AsTime())
+		}
+		return webhooks[i].GetId() < webhooks[j].GetId()
+	})
+	return webhooks, nil
+}
+
+// ReadWebhooks reads the webhooks of a JSON file, an array of objects with the url, secret and tenant
+// of each webhook, e.g. to register them at the start of the server.
+func ReadWebhooks(path string) ([]*pb.Webhook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read webhooks file: %w", err)
+	}
+
+	var entries []struct {
+		URL    string `json:"url"`
+		Secret string `json:"secret"`
+		Tenant string `json:"tenant"`
+	}
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse webhooks file %s: %w", path, err)
+	}
+
+	webhooks := make([]*pb.Webhook, 0, len(entries))
+	for i, entry := range entries {
+		if entry.Secret == "" {
+			return nil, fmt.Errorf("invalid webhook %d in file %s, must have a secret", i+1, path)
+		}
+		webhook, err := NewWebhook(entry.URL, entry.Secret, entry.Tenant)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook %d in file %s: %w", i+1, path, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// SignWebhookPayload returns the signature of a payload sent at the Unix timestamp, the hex HMAC-SHA256
+// of "<timestamp>.<payload>" with the secret of the webhook, prefixed with "sha256=".
+// The timestamp is signed so that the receivers can reject the replays of old payloads.
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks that the signature of the payload sent at the timestamp is valid.
+func VerifyWebhookSignature(secret string, timestamp int64, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, timestamp, payload)), []byte(signature))
+}
+
+// WebhookConfig configures a WebhookPublisher.
+type WebhookConfig struct {
+	// HTTPClient is http.DefaultClient if nil.
+	HTTPClient *http.Client
+	// MaxAttempts is the number of attempts to deliver an event, 5 if 0.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled before each next one, 1s if 0.
+	InitialBackoff time.Duration
+	// MaxBackoff is the longest wait between two attempts, 1m if 0.
+	MaxBackoff time.Duration
+	// MaxPending is the number of deliveries in progress beyond which the events are dropped, 1000 if 0.
+	MaxPending int
+	// Logger logs the failed deliveries, the default logger if nil.
+	Logger *logging.Logger
+}
+
+// WebhookPublisher is an EventPublisher posting the events as signed JSON payloads to the webhooks
+// of their tenant. The deliveries are made in the background, so the events of a webhook may arrive
+// out of order, and are retried with an exponential backoff while the webhook fails or is unreachable.
+type WebhookPublisher struct {
+	store      WebhookStore
+	config     WebhookConfig
+	pending    chan struct{}
+	deliveries sync.WaitGroup
+	now        func() time.Time
+}
+
+// NewWebhookPublisher returns a new WebhookPublisher of the webhooks of the store.
+func NewWebhookPublisher(store WebhookStore, config WebhookConfig) *WebhookPublisher {
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+	if config.MaxPending <= 0 {
+		config.MaxPending = 1000
+	}
+	if config.Logger == nil {
+		config.Logger = logging.Default()
+	}
+	return &WebhookPublisher{
+		store:   store,
+		config:  config,
+		pending: make(chan struct{}, config.MaxPending),
+		now:     time.Now,
+	}
+}
+
+// Publish starts the deliveries of the event to the webhooks of its tenant
+func (publisher *WebhookPublisher) Publish(ctx context.Context, event LaptopEvent) error {
+	webhooks, err := publisher.store.List()
+	if err != nil {
+		return fmt.Errorf("cannot list webhooks: %w", err)
+	}
+	payload, err := MarshalLaptopEvent(event)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.GetTenant() != "" && webhook.GetTenant() != event.Tenant {
+			continue
+		}
+		select {
+		case publisher.pending <- struct{}{}:
+		default:
+			return fmt.Errorf("too many pending deliveries, dropped for webhook %s", webhook.GetId())
+		}
+		publisher.deliveries.Add(1)
+		go func(webhook *pb.Webhook) {
+			defer publisher.deliveries.Done()
+			defer func() { <-publisher.pending }()
+			publisher.deliver(webhook, eventTypeNames[event.Type], payload)
+		}(webhook)
+	}
+	return nil
+}
+
+// Flush waits for the deliveries in progress to end, with their retries, or for the context to be done,
+// e.g. to let the pending events reach the webhooks before the server exits.
+func (publisher *WebhookPublisher) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		publisher.deliveries.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cannot flush webhook deliveries: %w", ctx.Err())
+	}
+}
+
+// deliver posts the payload to the webhook until it succeeds or the attempts are exhausted.
+// The responses 2xx are successes, and the client errors but 408 and 429 are not retried.
+func (publisher *WebhookPublisher) deliver(webhook *pb.Webhook, eventType string, payload []byte) {
+	backoff := publisher.config.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := publisher.post(webhook, eventType, payload)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == publisher.config.MaxAttempts {
+			publisher.config.Logger.Error("cannot deliver event to webhook",
+				"type", eventType, "webhook_id", webhook.GetId(), "attempts", attempt, "error", err)
+			return
+		}
+
+		// The jitter spreads the retries of the deliveries that failed together.
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		backoff *= 2
+		if backoff > publisher.config.MaxBackoff {
+			backoff = publisher.config.MaxBackoff
+		}
+	}
+}
+
+// post makes an attempt to deliver the payload, and returns whether it may be retried if it fails.
+func (publisher *WebhookPublisher) post(webhook *pb.Webhook, eventType string, payload []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookAttemptTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.GetUrl(), bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	timestamp := publisher.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, webhook.GetId())
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.GetSecret(), timestamp, payload))
+
+	res, err := publisher.config.HTTPClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, fmt.Errorf("webhook answered %s", res.Status)
+	default:
+		return false, fmt.Errorf("webhook answered %s", res.Status)
+	}
+}
@@ -0,0 +1,120 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"grpc_app/pb"
+	"grpc_app/sample"
+	"grpc_app/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWebhookPublisher(t *testing.T) {
+	t.Parallel()
+
+	type delivery struct {
+		eventType string
+		laptopID  string
+		valid     bool
+	}
+	deliveries := make(chan delivery, 10)
+	var attempts int32
+	var secret string
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails, so the event is delivered by the retry.
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		payload, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		timestamp, err := strconv.ParseInt(r.Header.Get(service.WebhookTimestampHeader), 10, 64)
+		require.NoError(t, err)
+		var event struct {
+			Laptop struct {
+				ID string `json:"id"`
+			} `json:"laptop"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &event))
+		deliveries <- delivery{
+			eventType: r.Header.Get(service.WebhookEventHeader),
+			laptopID:  event.Laptop.ID,
+			valid:     service.VerifyWebhookSignature(secret, timestamp, payload, r.Header.Get(service.WebhookSignatureHeader)),
+		}
+	}))
+	t.Cleanup(httpServer.Close)
+
+	webhookStore := service.NewInMemoryWebhookStore()
+	server := service.NewAdminServer("signing-key", nil, service.WithWebhookStore(webhookStore))
+	res, err := server.CreateWebhook(context.Background(), &pb.CreateWebhookRequest{Url: httpServer.URL, Tenant: "acme"})
+	require.NoError(t, err)
+	secret = res.GetWebhook().GetSecret()
+	require.NotEmpty(t, secret, "the secret is generated")
+
+	_, err = server.CreateWebhook(context.Background(), &pb.CreateWebhookRequest{Url: "ftp://example.com"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	listed, err := server.ListWebhooks(context.Background(), &pb.ListWebhooksRequest{})
+	require.NoError(t, err)
+	require.Len(t, listed.GetWebhooks(), 1)
+	require.Equal(t, httpServer.URL, listed.GetWebhooks()[0].GetUrl())
+	require.Empty(t, listed.GetWebhooks()[0].GetSecret(), "the secrets are not listed")
+
+	publisher := service.NewWebhookPublisher(webhookStore, service.WebhookConfig{InitialBackoff: time.Millisecond})
+	laptop := sample.NewLaptop()
+	require.NoError(t, publisher.Publish(context.Background(), service.LaptopEvent{Type: service.LaptopCreated, Laptop: laptop}))
+	require.NoError(t, publisher.Publish(context.Background(), service.LaptopEvent{Type: service.LaptopUpdated, Tenant: "acme", Laptop: laptop}))
+
+	select {
+	case d := <-deliveries:
+		require.Equal(t, delivery{eventType: "updated", laptopID: laptop.GetId(), valid: true}, d)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event is not delivered")
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts), "the event of another tenant is not delivered")
+
+	_, err = server.DeleteWebhook(context.Background(), &pb.DeleteWebhookRequest{Id: res.GetWebhook().GetId()})
+	require.NoError(t, err)
+	_, err = server.DeleteWebhook(context.Background(), &pb.DeleteWebhookRequest{Id: res.GetWebhook().GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestWebhookPublisherFlush(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	var delivered int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		atomic.AddInt32(&delivered, 1)
+	}))
+	t.Cleanup(httpServer.Close)
+
+	webhookStore := service.NewInMemoryWebhookStore()
+	server := service.NewAdminServer("signing-key", nil, service.WithWebhookStore(webhookStore))
+	_, err := server.CreateWebhook(context.Background(), &pb.CreateWebhookRequest{Url: httpServer.URL})
+	require.NoError(t, err)
+
+	publisher := service.NewWebhookPublisher(webhookStore, service.WebhookConfig{})
+	require.NoError(t, publisher.Flush(context.Background()), "nothing is pending")
+	require.NoError(t, publisher.Publish(context.Background(), service.LaptopEvent{Type: service.LaptopCreated, Laptop: sample.NewLaptop()}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, publisher.Flush(ctx), context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, publisher.Flush(context.Background()))
+	require.Equal(t, int32(1), atomic.LoadInt32(&delivered))
+}
@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"grpc_app/logging"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// otlpMaxBatch is the number of spans sent at once by an OTLP exporter, and otlpMaxQueued
+// the number of spans it keeps waiting to be sent, above which the new spans are dropped.
+const (
+	otlpMaxBatch  = 512
+	otlpMaxQueued = 4096
+)
+
+// otlpSpanKinds are the OTLP values of the span kinds.
+var otlpSpanKinds = map[string]int{
+	KindInternal: 1,
+	KindServer:   2,
+	KindClient:   3,
+}
+
+// OTLPExporter sends the finished spans in batches to an OpenTelemetry collector,
+// with the OTLP/HTTP protocol and JSON encoding.
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+
+	mutex   sync.Mutex
+	queued  []*Span
+	dropped int
+}
+
+// NewOTLPExporter returns a new exporter to the collector at the endpoint, e.g. http://localhost:4318,
+// whose spans are of the service. The spans are only sent by Flush and Run.
+func NewOTLPExporter(endpoint string, serviceName string) *OTLPExporter {
+	return &OTLPExporter{
+		url:         strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ExportSpan queues the span to be sent.
+func (exporter *OTLPExporter) ExportSpan(span *Span) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	if len(exporter.queued) >= otlpMaxQueued {
+		exporter.dropped++
+		return
+	}
+	exporter.queued = append(exporter.queued, span)
+}
+
+// Flush sends the queued spans to the collector.
+func (exporter *OTLPExporter) Flush(ctx context.Context) error {
+	for {
+		exporter.mutex.Lock()
+		batch := exporter.queued
+		if len(batch) > otlpMaxBatch {
+			batch = batch[:otlpMaxBatch]
+		}
+		exporter.queued = exporter.queued[len(batch):]
+		dropped := exporter.dropped
+		exporter.dropped = 0
+		exporter.mutex.Unlock()
+
+		if dropped > 0 {
+			logging.Default().Warn("dropped spans, the OTLP exporter queue is full", "dropped", dropped)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		err := exporter.send(ctx, batch)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Run sends the queued spans every interval until the context is done.
+func (exporter *OTLPExporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		err := exporter.Flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			logging.Default().Error("cannot export spans", "error", err)
+		}
+	}
+}
+
+func (exporter *OTLPExporter) send(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(exporter.request(spans))
+	if err != nil {
+		return fmt.Errorf("cannot marshal spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, exporter.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := exporter.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send spans: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("cannot send spans: collector returned %s", res.Status)
+	}
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue,omitempty"`
+	// IntValue is a decimal int64, which OTLP/JSON encodes as a string.
+	IntValue string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func (exporter *OTLPExporter) request(spans []*Span) otlpRequest {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, toOTLPSpan(span))
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: exporter.serviceName}},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "grpc_app/tracing"},
+			Spans: otlpSpans,
+		}},
+	}}}
+}
+
+func toOTLPSpan(span *Span) otlpSpan {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	otlp := otlpSpan{
+		TraceID:           hex.EncodeToString(span.Context.TraceID[:]),
+		SpanID:            hex.EncodeToString(span.Context.SpanID[:]),
+		Name:              span.Name,
+		Kind:              otlpSpanKinds[span.Kind],
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+	}
+	if span.ParentID != [8]byte{} {
+		otlp.ParentSpanID = hex.EncodeToString(span.ParentID[:])
+	}
+
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		otlp.Attributes = append(otlp.Attributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: span.Attributes[key]}})
+	}
+
+	if span.Kind != KindInternal {
+		otlp.Attributes = append(otlp.Attributes, otlpAttribute{
+			Key:   "rpc.grpc.status_code",
+			Value: otlpValue{IntValue: strconv.Itoa(int(span.Code))},
+		})
+	}
+	if span.Code != codes.OK || span.Err != nil {
+		otlp.Status.Code = 2
+		if span.Err != nil {
+			otlp.Status.Message = span.Err.Error()
+		}
+	}
+	return otlp
+}
@@ -0,0 +1,60 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"grpc_app/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestOTLPExporterFlush(t *testing.T) {
+	t.Parallel()
+
+	requests := make(chan map[string]interface{}, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/traces", r.URL.Path)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests <- body
+	}))
+	defer collector.Close()
+
+	exporter := tracing.NewOTLPExporter(collector.URL, "test-service")
+	tracer := tracing.NewTracer(exporter)
+	ctx, parent := tracer.Start(context.Background(), "/grpc_app.proto.LaptopService/CreateLaptop", tracing.KindServer)
+	_, child := tracer.Start(ctx, "LaptopStore/save", tracing.KindInternal)
+	child.SetAttribute("store.operation", "save")
+	child.Finish()
+	parent.SetStatus(codes.AlreadyExists, nil)
+	parent.Finish()
+
+	require.NoError(t, exporter.Flush(context.Background()))
+	body := <-requests
+
+	resourceSpans := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	resource := resourceSpans["resource"].(map[string]interface{})
+	require.Equal(t, []interface{}{map[string]interface{}{
+		"key": "service.name", "value": map[string]interface{}{"stringValue": "test-service"},
+	}}, resource["attributes"])
+
+	spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	require.Len(t, spans, 2)
+	childSpan := spans[0].(map[string]interface{})
+	parentSpan := spans[1].(map[string]interface{})
+	require.Equal(t, "LaptopStore/save", childSpan["name"])
+	require.Equal(t, float64(1), childSpan["kind"])
+	require.Equal(t, parentSpan["traceId"], childSpan["traceId"])
+	require.Equal(t, parentSpan["spanId"], childSpan["parentSpanId"])
+	require.Equal(t, float64(2), parentSpan["kind"])
+	require.Equal(t, float64(2), parentSpan["status"].(map[string]interface{})["code"])
+
+	require.NoError(t, exporter.Flush(context.Background()))
+	require.Empty(t, requests)
+}
@@ -0,0 +1,224 @@
+// Package tracing provides a minimal tracer compatible with the W3C trace context,
+// so spans created on the client and on the server share the same trace ID.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"grpc_app/logging"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// traceparentHeader is the W3C trace context header, carried in gRPC metadata.
+const traceparentHeader = "traceparent"
+
+// Span kinds.
+const (
+	KindClient   = "client"
+	KindServer   = "server"
+	KindInternal = "internal"
+)
+
+// SpanContext identifies a span and the trace it belongs to.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid returns true if the span context has a trace ID and a span ID.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats the span context as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(value string) (SpanContext, error) {
+	sc := SpanContext{}
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace ID: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid span ID: %w", err)
+	}
+	sc.Sampled = parts[3] == "01"
+
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	return sc, nil
+}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	Name       string
+	Kind       string
+	Context    SpanContext
+	ParentID   [8]byte
+	Start      time.Time
+	End        time.Time
+	Code       codes.Code
+	Err        error
+	Attributes map[string]string
+
+	mutex  sync.Mutex
+	tracer *Tracer
+	ended  bool
+}
+
+// SetAttribute sets an attribute of the span.
+func (span *Span) SetAttribute(key string, value string) {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	span.Attributes[key] = value
+}
+
+// SetStatus sets the gRPC status of the span.
+func (span *Span) SetStatus(code codes.Code, err error) {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	span.Code = code
+	span.Err = err
+}
+
+// Finish ends the span and exports it. Calling it more than once has no effect.
+func (span *Span) Finish() {
+	span.mutex.Lock()
+	if span.ended {
+		span.mutex.Unlock()
+		return
+	}
+	span.ended = true
+	span.End = time.Now()
+	span.mutex.Unlock()
+
+	if span.Context.Sampled && span.tracer.exporter != nil {
+		span.tracer.exporter.ExportSpan(span)
+	}
+}
+
+// Duration returns how long the span lasted.
+func (span *Span) Duration() time.Duration {
+	return span.End.Sub(span.Start)
+}
+
+// Exporter sends finished spans to a tracing backend.
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+// ExporterFunc is a function adapter for Exporter.
+type ExporterFunc func(span *Span)
+
+// ExportSpan calls the function.
+func (f ExporterFunc) ExportSpan(span *Span) {
+	f(span)
+}
+
+// LogExporter writes finished spans to the default logger at the info level.
+var LogExporter = ExporterFunc(func(span *Span) {
+	logging.Default().Info(
+		"span",
+		"name", span.Name,
+		"kind", span.Kind,
+		"trace", hex.EncodeToString(span.Context.TraceID[:]),
+		"span", hex.EncodeToString(span.Context.SpanID[:]),
+		"parent", hex.EncodeToString(span.ParentID[:]),
+		"duration", span.Duration(),
+		"code", span.Code,
+		"attributes", span.Attributes,
+	)
+})
+
+// Tracer creates spans and exports them when they finish.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer returns a new tracer that sends its spans to the exporter.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+type spanKey struct{}
+
+// Start creates a new span, child of the span in the context if any, and returns a context holding it.
+func (tracer *Tracer) Start(ctx context.Context, name string, kind string) (context.Context, *Span) {
+	return tracer.start(ctx, name, kind, SpanFromContext(ctx).Context)
+}
+
+// StartFromIncoming creates a new server span, child of the span propagated in the incoming metadata if any.
+func (tracer *Tracer) StartFromIncoming(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanContext{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(traceparentHeader); len(values) > 0 {
+			if sc, err := ParseTraceparent(values[0]); err == nil {
+				parent = sc
+			}
+		}
+	}
+	return tracer.start(ctx, name, KindServer, parent)
+}
+
+func (tracer *Tracer) start(ctx context.Context, name string, kind string, parent SpanContext) (context.Context, *Span) {
+	span := &Span{
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: make(map[string]string),
+		tracer:     tracer,
+	}
+
+	if parent.IsValid() {
+		span.Context.TraceID = parent.TraceID
+		span.Context.Sampled = parent.Sampled
+		span.ParentID = parent.SpanID
+	} else {
+		rand.Read(span.Context.TraceID[:])
+		span.Context.Sampled = true
+	}
+	rand.Read(span.Context.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the current span of the context, or an empty span if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok {
+		return &Span{Attributes: make(map[string]string), tracer: &Tracer{}}
+	}
+	return span
+}
+
+// Inject propagates the span context of the current span in the outgoing metadata.
+func Inject(ctx context.Context) context.Context {
+	sc := SpanFromContext(ctx).Context
+	if !sc.IsValid() {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, traceparentHeader, sc.Traceparent())
+}
@@ -0,0 +1,50 @@
+package tracing_test
+
+import (
+	"context"
+	"grpc_app/tracing"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTraceparentPropagation(t *testing.T) {
+	t.Parallel()
+
+	var exported []*tracing.Span
+	tracer := tracing.NewTracer(tracing.ExporterFunc(func(span *tracing.Span) {
+		exported = append(exported, span)
+	}))
+
+	ctx, clientSpan := tracer.Start(context.Background(), "client", tracing.KindClient)
+	ctx = tracing.Inject(ctx)
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	require.True(t, ok)
+
+	_, serverSpan := tracer.StartFromIncoming(metadata.NewIncomingContext(context.Background(), md), "server")
+	require.Equal(t, clientSpan.Context.TraceID, serverSpan.Context.TraceID)
+	require.Equal(t, clientSpan.Context.SpanID, serverSpan.ParentID)
+	require.NotEqual(t, clientSpan.Context.SpanID, serverSpan.Context.SpanID)
+
+	serverSpan.Finish()
+	clientSpan.Finish()
+	clientSpan.Finish()
+	require.Len(t, exported, 2)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	sc, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	require.True(t, sc.Sampled)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	_, err = tracing.ParseTraceparent("00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	require.Error(t, err)
+
+	_, err = tracing.ParseTraceparent("invalid")
+	require.Error(t, err)
+}